@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"ozon_task/internal/cluster"
+	"ozon_task/internal/config"
+	pkgtls "ozon_task/pkg/tls"
+	clusterv1 "ozon_task/protos/gen/go/cluster/v1"
+	"text/tabwriter"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+const clusterUsage = "usage: cluster [-addr host:port] [-cert file -key file] " +
+	"members | join -id id -raft host:port -grpc host:port | remove <id>"
+
+// runCluster shows and changes members of the cluster through ClusterService of a node,
+// the local node of storage.cluster by default. Changes are repeated on the leader if the node redirects to it.
+// Connections are authenticated by the certificate of the flags, changes require one of storage.cluster.admin_names.
+func runCluster(ctx context.Context, cfg config.Config, args []string) error {
+	var addr, certFile, keyFile string
+	flags := newFlagSet("cluster")
+	flags.StringVar(&addr, "addr", cfg.Storage.Cluster.GRPCAddress, "Address of ClusterService of a node")
+	flags.StringVar(&certFile, "cert", cfg.Storage.Cluster.TLS.CertFile, "Client certificate signed by the cluster CA")
+	flags.StringVar(&keyFile, "key", cfg.Storage.Cluster.TLS.KeyFile, "Key of the client certificate")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	args = flags.Args()
+	if len(args) == 0 {
+		return errors.New(clusterUsage)
+	}
+	if len(addr) == 0 {
+		return fmt.Errorf("address of the node is required: %s", clusterUsage)
+	}
+
+	var member clusterv1.Member
+	subFlags := newFlagSet("cluster " + args[0])
+	switch args[0] {
+	case "members":
+		if err := parseFlags(subFlags, args[1:], 0); err != nil {
+			return err
+		}
+	case "join":
+		subFlags.StringVar(&member.Id, "id", "", "Id of the joining node")
+		subFlags.StringVar(&member.RaftAddress, "raft", "", "Raft address of the joining node")
+		subFlags.StringVar(&member.GrpcAddress, "grpc", "", "ClusterService address of the joining node")
+		if err := parseFlags(subFlags, args[1:], 0); err != nil {
+			return err
+		}
+	case "remove":
+		if err := parseFlags(subFlags, args[1:], 1); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown cluster command %q: %s", args[0], clusterUsage)
+	}
+
+	tlsCfg, err := pkgtls.NewClientConfig(pkgtls.ClientConfig{
+		CAFile:   cfg.Storage.Cluster.TLS.CAFile,
+		CertFile: certFile,
+		KeyFile:  keyFile,
+	})
+	if err != nil {
+		return err
+	}
+	creds := credentials.NewTLS(tlsCfg)
+
+	if cfg.Storage.Cluster.ApplyTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Storage.Cluster.ApplyTimeout)
+		defer cancel()
+	}
+
+	switch args[0] {
+	case "join":
+		err = callLeader(addr, creds, func(client clusterv1.ClusterServiceClient, opts ...grpc.CallOption) error {
+			_, err := client.Join(ctx, &clusterv1.JoinRequest{Member: &member}, opts...)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("node %s joined\n", member.GetId())
+		return nil
+	case "remove":
+		err = callLeader(addr, creds, func(client clusterv1.ClusterServiceClient, opts ...grpc.CallOption) error {
+			_, err := client.Remove(ctx, &clusterv1.RemoveRequest{Id: subFlags.Arg(0)}, opts...)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("node %s removed\n", subFlags.Arg(0))
+		return nil
+	}
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	resp, err := clusterv1.NewClusterServiceClient(conn).Members(ctx, &clusterv1.MembersRequest{})
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tRAFT\tGRPC\tVOTER\tLEADER")
+	for _, m := range resp.GetMembers() {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%t\n",
+			m.GetId(), m.GetRaftAddress(), m.GetGrpcAddress(), m.GetVoter(), m.GetLeader())
+	}
+	return w.Flush()
+}
+
+// callLeader calls ClusterService of the node at the address and repeats the call on the leader,
+// if the node redirects to it.
+func callLeader(
+	addr string,
+	creds credentials.TransportCredentials,
+	call func(client clusterv1.ClusterServiceClient, opts ...grpc.CallOption) error,
+) error {
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	var trailer metadata.MD
+	err = call(clusterv1.NewClusterServiceClient(conn), grpc.Trailer(&trailer))
+	leader := cluster.LeaderAddress(err, trailer)
+	if len(leader) == 0 || leader == addr {
+		return err
+	}
+
+	leaderConn, err := grpc.NewClient(leader, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return fmt.Errorf("failed to connect to leader %s: %w", leader, err)
+	}
+	defer func() { _ = leaderConn.Close() }()
+	return call(clusterv1.NewClusterServiceClient(leaderConn))
+}
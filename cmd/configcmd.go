@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"ozon_task/internal/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+const configUsage = "usage: config validate | print"
+
+// runConfig checks the config or prints it with secrets redacted. The config is loaded
+// and validated before any command, so validation is done if the command is run.
+func runConfig(_ context.Context, cfg config.Config, args []string) error {
+	if len(args) != 1 {
+		return errors.New(configUsage)
+	}
+
+	switch args[0] {
+	case "validate":
+		fmt.Println("config is valid")
+		return nil
+	case "print":
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(cfg); err != nil {
+			return err
+		}
+		return encoder.Close()
+	default:
+		return fmt.Errorf("unknown config command %q: %s", args[0], configUsage)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"ozon_task/domain"
+	"ozon_task/internal/config"
+	"ozon_task/internal/transfer"
+	pkglog "ozon_task/pkg/log"
+	"path/filepath"
+	"syscall"
+)
+
+// defaultCheckpointEvery is the number of imported records between saves of the checkpoint.
+const defaultCheckpointEvery = 1000
+
+// runExport writes links matching the filter as JSON lines or CSV, newest first.
+func runExport(ctx context.Context, cfg config.Config, args []string) error {
+	var (
+		filter domain.LinkFilter
+		output string
+		format string
+	)
+	flags := newFlagSet("export")
+	flags.StringVar(&filter.OwnerID, "owner", "", "Export links of the owner only")
+	flags.StringVar(&filter.Host, "host", "", "Export links to the host only")
+	flags.StringVar(&filter.Tag, "tag", "", "Export links with the tag only")
+	flags.StringVar(&output, "o", "", "Output file (default is stdout)")
+	flags.StringVar(&format, "format", string(transfer.FormatJSONL), "Output format: jsonl or csv")
+	if err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+	outputFormat, err := transfer.ParseFormat(format)
+	if err != nil {
+		return err
+	}
+
+	log := cliLogger()
+	st, err := initStorage(cfg, log)
+	if err != nil {
+		return err
+	}
+	defer st.close(log)
+
+	out := io.Writer(os.Stdout)
+	if len(output) != 0 {
+		file, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("create output failed: %w", err)
+		}
+		defer func() { _ = file.Close() }()
+		out = file
+	}
+
+	count, err := transfer.Export(ctx, st.urls, filter, transfer.NewWriter(out, outputFormat))
+	if err != nil {
+		return err
+	}
+
+	log.Info("Links exported", slog.Int("count", count))
+	return nil
+}
+
+// runImport creates links from JSON lines or CSV keeping their shortened URLs. Links already imported
+// are counted as existing, conflicting and invalid ones are skipped and reported.
+// With -checkpoint the progress is saved to the file, so the interrupted import is resumed from it.
+func runImport(ctx context.Context, cfg config.Config, args []string) error {
+	var (
+		input, format, checkpointPath string
+		checkpointEvery               int
+	)
+	flags := newFlagSet("import")
+	flags.StringVar(&input, "i", "", "Input file (default is stdin)")
+	flags.StringVar(&format, "format", string(transfer.FormatJSONL), "Input format: jsonl or csv")
+	flags.StringVar(&checkpointPath, "checkpoint", "", "File to save progress to and resume from")
+	flags.IntVar(&checkpointEvery, "checkpoint-every", defaultCheckpointEvery, "Records between checkpoints")
+	if err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+	inputFormat, err := transfer.ParseFormat(format)
+	if err != nil {
+		return err
+	}
+
+	in, inputName := io.Reader(os.Stdin), "stdin"
+	if len(input) != 0 {
+		file, err := os.Open(input)
+		if err != nil {
+			return fmt.Errorf("open input failed: %w", err)
+		}
+		defer func() { _ = file.Close() }()
+		in = file
+		if inputName, err = filepath.Abs(input); err != nil {
+			return err
+		}
+	}
+
+	opts := transfer.ImportOptions{CheckpointEvery: checkpointEvery, MaxOriginalLength: cfg.Links.MaxOriginalLength}
+	if len(checkpointPath) != 0 {
+		if opts.Resume, err = transfer.LoadCheckpoint(checkpointPath, inputName); err != nil {
+			return err
+		}
+		opts.Checkpoint = func(progress transfer.Progress) error {
+			return transfer.SaveCheckpoint(checkpointPath, inputName, progress)
+		}
+	}
+
+	reader, err := transfer.NewReader(in, inputFormat)
+	if err != nil {
+		return err
+	}
+
+	log := cliLogger()
+	st, err := initStorage(cfg, log)
+	if err != nil {
+		return err
+	}
+	defer st.close(log)
+
+	if opts.Resume.Records > 0 {
+		log.Info("Resuming import", slog.Int("records", opts.Resume.Records))
+	}
+	opts.OnConflict = func(conflict transfer.Conflict) {
+		log.Warn("Link skipped, conflicts with existing link",
+			slog.Int("record", conflict.Number),
+			slog.String("shortened", conflict.Record.Shortened),
+			slog.String("original", conflict.Record.Original),
+			slog.String("existing_shortened", conflict.Existing.Shortened),
+			slog.String("existing_original", conflict.Existing.Original))
+	}
+	opts.OnInvalid = func(number int, err error) {
+		log.Warn("Link skipped", slog.Int("record", number), pkglog.Err(err))
+	}
+
+	// interrupted import saves its progress, so it's resumed from the last record
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	progress, err := transfer.Import(ctx, st.urls, reader, opts)
+	if err != nil {
+		if opts.Checkpoint != nil {
+			if saveErr := opts.Checkpoint(progress); saveErr != nil {
+				log.Error("failed to save checkpoint", pkglog.Err(saveErr))
+			}
+			log.Info("Import stopped, run the command again to resume",
+				slog.Int("records", progress.Records), slog.String("checkpoint", checkpointPath))
+		}
+		return err
+	}
+	// the finished import is started over by the next run
+	if len(checkpointPath) != 0 {
+		if err = os.Remove(checkpointPath); err != nil {
+			log.Warn("failed to remove checkpoint", pkglog.Err(err))
+		}
+	}
+
+	log.Info("Links imported",
+		slog.Int("records", progress.Records),
+		slog.Int("imported", progress.Imported),
+		slog.Int("existing", progress.Existing),
+		slog.Int("conflicts", progress.Conflicts),
+		slog.Int("invalid", progress.Invalid))
+	if skipped := progress.Conflicts + progress.Invalid; skipped != 0 {
+		return fmt.Errorf("%d links skipped", skipped)
+	}
+	return nil
+}
+
+// newFlagSet creates flags of the command, errors are returned instead of exiting.
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet(name, flag.ContinueOnError)
+}
+
+// parseFlags parses flags of the command which takes exactly nArgs positional arguments.
+func parseFlags(flags *flag.FlagSet, args []string, nArgs int) error {
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != nArgs {
+		return fmt.Errorf("expected %d arguments, got %q", nArgs, flags.Args())
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"ozon_task/internal/config"
+	"ozon_task/internal/transfer"
+	"ozon_task/internal/usecases/service"
+)
+
+const (
+	linksUsage = "usage: links get | delete <shortened>"
+	keysUsage  = "usage: keys issue -owner id [-name name] [-admin] | revoke <id>"
+)
+
+// runLinks shows or deletes a link by its shortened URL.
+func runLinks(ctx context.Context, cfg config.Config, args []string) error {
+	if len(args) != 2 {
+		return errors.New(linksUsage)
+	}
+
+	log := cliLogger()
+	st, err := initStorage(cfg, log)
+	if err != nil {
+		return err
+	}
+	defer st.close(log)
+
+	urlService := service.NewURLService(st.urls)
+	ctx = cliContext(ctx)
+	shortened := args[1]
+
+	switch args[0] {
+	case "get":
+		link, err := urlService.GetLink(ctx, shortened)
+		if err != nil {
+			return err
+		}
+		return printJSON(transfer.NewRecord(link))
+	case "delete":
+		if err = urlService.DeleteLink(ctx, shortened); err != nil {
+			return err
+		}
+		fmt.Printf("link %s deleted\n", shortened)
+		return nil
+	default:
+		return fmt.Errorf("unknown links command %q: %s", args[0], linksUsage)
+	}
+}
+
+// runKeys issues or revokes api keys, e.g. to bootstrap the first admin key without the static admin token.
+func runKeys(ctx context.Context, cfg config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(keysUsage)
+	}
+
+	var (
+		owner, name string
+		admin       bool
+	)
+	flags := newFlagSet("keys " + args[0])
+	switch args[0] {
+	case "issue":
+		flags.StringVar(&owner, "owner", "", "Owner of the key")
+		flags.StringVar(&name, "name", "", "Name of the key")
+		flags.BoolVar(&admin, "admin", false, "Grant admin access")
+		if err := parseFlags(flags, args[1:], 0); err != nil {
+			return err
+		}
+	case "revoke":
+		if err := parseFlags(flags, args[1:], 1); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown keys command %q: %s", args[0], keysUsage)
+	}
+
+	log := cliLogger()
+	st, err := initStorage(cfg, log)
+	if err != nil {
+		return err
+	}
+	defer st.close(log)
+
+	authService := service.NewAuthService(st.apiKeys, "")
+	ctx = cliContext(ctx)
+
+	if args[0] == "revoke" {
+		key, err := authService.RevokeKey(ctx, flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("key %s revoked\n", key.ID)
+		return nil
+	}
+
+	key, raw, err := authService.IssueKey(ctx, owner, name, admin)
+	if err != nil {
+		return err
+	}
+	// the raw key is shown only once, it's printed alone, so it can be captured by scripts
+	log.Info("Key issued", slog.String("id", key.ID), slog.String("owner", key.OwnerID))
+	fmt.Println(raw)
+	return nil
+}
+
+func printJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
@@ -2,29 +2,14 @@ package main
 
 import (
 	"context"
-	"errors"
+	"flag"
+	"fmt"
 	"log/slog"
+	"os"
 	_ "ozon_task/docs"
-	grpcapp "ozon_task/internal/app/grpc"
-	httpapp "ozon_task/internal/app/http"
+	"ozon_task/domain"
+	"ozon_task/internal/auth"
 	"ozon_task/internal/config"
-	"ozon_task/internal/repository"
-	"ozon_task/internal/repository/inmem"
-	"ozon_task/internal/repository/postgres"
-	"ozon_task/internal/usecases/service"
-	pkgconfig "ozon_task/pkg/config"
-	"ozon_task/pkg/infra"
-	pkgredis "ozon_task/pkg/infra/cache/redis"
-	"ozon_task/pkg/infra/cache/stub"
-	pkginmem "ozon_task/pkg/infra/kv/inmem"
-	pkglog "ozon_task/pkg/log"
-	"ozon_task/pkg/shutdown"
-	"runtime"
-	"time"
-
-	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/redis/go-redis/v9"
-	"golang.org/x/sync/errgroup"
 )
 
 //	@title			URL Shortener API
@@ -33,113 +18,107 @@ import (
 //	@termsOfService	http://swagger.io/terms/
 
 //	@host		localhost:8080
-//	@BasePath	/api/v1/
+//	@BasePath	/api/
+
+//	@securityDefinitions.apikey	BearerAuth
+//	@in							header
+//	@name						Authorization
+//	@description				API key or JWT in format `Bearer <token>`
 
 const (
-	configEnvVar = "SHORTENER_CONFIG"
-	APIPath      = "/api/v1"
+	APIPath = "/api"
+	// cliPrincipalID identifies the operator running commands of the binary.
+	cliPrincipalID = "cli"
 )
 
+// command is a subcommand of the binary, it's run with the loaded config and arguments left after its name.
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, cfg config.Config, args []string) error
+}
+
+var commands = []command{
+	{name: "serve", usage: "serve", run: runServe},
+	{name: "migrate", usage: "migrate up | down [steps] | status", run: runMigrate},
+	{name: "export", usage: "export [-owner id] [-host host] [-tag tag] [-format jsonl|csv] [-o file]", run: runExport},
+	{
+		name:  "import",
+		usage: "import [-i file] [-format jsonl|csv] [-checkpoint file] [-checkpoint-every n]",
+		run:   runImport,
+	},
+	{name: "config", usage: "config validate | print", run: runConfig},
+	{name: "links", usage: "links get | delete <shortened>", run: runLinks},
+	{name: "keys", usage: "keys issue -owner id [-name name] [-admin] | revoke <id>", run: runKeys},
+	{
+		name:  "cluster",
+		usage: "cluster [-addr host:port] members | join -id id -raft host:port -grpc host:port | remove <id>",
+		run:   runCluster,
+	},
+}
+
 // flags
-// -inmem - use inmemory storage instead of postgresql
-// -redis - use redis as cache (works only if inmem disabled and redis is live)
+// -config - path to the config file, SHORTENER_CONFIG by default
+// -inmem - use inmemory storage instead of postgresql, overrides storage.backend
+// -redis - use redis as cache (works only if inmem disabled and redis is live), overrides storage.cache
+// commands are listed in commands, serve is run without a command
 func main() {
+	flag.Usage = usage
 	flags := config.ParseFlags()
-	cfg := config.Config{}
-	pkgconfig.MustLoad(configEnvVar, &cfg)
-
-	log, file := pkglog.NewLogger(cfg.Logger)
-	defer func() { _ = file.Close() }()
-	slog.SetDefault(log)
-	log.Info("Starting URL Shortener", slog.Any("config", cfg))
-
-	urlRepo, dbPool, redisClient := initStorage(flags, cfg, log)
 
-	urlService := service.NewURLService(urlRepo)
-
-	grpcApp := grpcapp.New(log, urlService, cfg.GRPC)
-	httpApp := httpapp.New(log, APIPath, urlService, cfg.HTTPServer)
-
-	g, ctx := errgroup.WithContext(context.Background())
-	g.Go(func() error {
-		return shutdown.ListenSignal(ctx, log)
-	})
-
-	g.Go(func() error {
-		return httpApp.Run()
-	})
-
-	g.Go(func() error {
-		return grpcApp.Run()
-	})
-
-	g.Go(func() error {
-		<-ctx.Done()
-		log.Info("Shutdown signal received, stopping servers")
-		return shutdownServices(grpcApp, httpApp)
-	})
-
-	err := g.Wait()
-
-	if dbPool != nil {
-		dbPool.Close()
+	name, args := "serve", flags.Args
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
 	}
 
-	if redisClient != nil {
-		pkgredis.ShutdownClient(redisClient)
+	cmd, ok := findCommand(name)
+	if !ok {
+		_, _ = fmt.Fprintf(os.Stderr, "unknown command %q\n", name)
+		usage()
+		os.Exit(2)
 	}
 
-	if err != nil && !errors.Is(err, shutdown.ErrOSSignal) {
-		log.Error("Exit reason", slog.String("error", err.Error()))
+	cfg, err := config.Load(flags)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-}
 
-// initStorage inits repository depend on flags.
-func initStorage(
-	flags config.AppFlags,
-	cfg config.Config,
-	log *slog.Logger) (repository.URL, *pgxpool.Pool, *redis.Client) {
-	var (
-		urlRepo     repository.URL
-		dbPool      *pgxpool.Pool
-		redisClient *redis.Client
-	)
-
-	if flags.UseInMemStorage {
-		const threadsFactor = 2
-		partitionsNumber := runtime.GOMAXPROCS(0) * threadsFactor
-		kv := pkginmem.NewPartitionedKVStorage(partitionsNumber)
-		urlRepo = inmem.NewURLRepository(kv)
-		log.Info("Using in-memory storage")
-		return urlRepo, nil, nil
+	if err = cmd.run(context.Background(), cfg, args); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+		os.Exit(1)
 	}
+}
 
-	var err error
-	dbPool, err = infra.NewPostgresPool(cfg.PG)
-	if err != nil {
-		pkglog.Fatal(log, "error while setting new postgres connection: ", err)
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
 	}
+	return command{}, false
+}
 
-	if flags.UseRedis {
-		redisClient, err = pkgredis.NewRedisClient(cfg.Redis)
-		if err != nil {
-			pkglog.Fatal(log, "error while setting new redis connection: ", err)
-		}
-		cacheService := pkgredis.NewRedisService(redisClient, log)
-		urlRepo = postgres.NewURLRepository(dbPool, cacheService, cfg.Redis.TTL, cfg.Redis.WriteTimeout)
-		log.Info("Using Postgres with redis cache")
-	} else {
-		urlRepo = postgres.NewURLRepository(dbPool, stub.NewStub(), 0, 0)
-		log.Info("Using Postgres without redis")
+func usage() {
+	out := flag.CommandLine.Output()
+	_, _ = fmt.Fprintf(out, "Usage: %s [flags] [command]\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		_, _ = fmt.Fprintf(out, "  %s\n", cmd.usage)
 	}
+	_, _ = fmt.Fprintln(out, "\nFlags:")
+	flag.PrintDefaults()
+}
 
-	return urlRepo, dbPool, redisClient
+// cliLogger writes logs of commands to stderr, so their output can be piped.
+func cliLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
 }
 
-// shutdownServices gracefully shutdown apps.
-func shutdownServices(grpcApp *grpcapp.App, httpApp *httpapp.App) error {
-	grpcApp.Stop()
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	return httpApp.Stop(shutdownCtx)
+// cliContext returns context of the operator running the command, who has admin access.
+func cliContext(ctx context.Context) context.Context {
+	return auth.WithPrincipal(ctx, domain.Principal{
+		ID:      cliPrincipalID,
+		OwnerID: cliPrincipalID,
+		Scopes:  []domain.Scope{domain.ScopeAdmin},
+	})
 }
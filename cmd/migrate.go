@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"ozon_task/internal/config"
+	"ozon_task/migration"
+	"ozon_task/pkg/infra"
+	"ozon_task/pkg/migrate"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const migrateUsage = "usage: migrate up | down [steps] | status"
+
+// newMigrator creates migrator of the postgres schema embedded into the binary.
+func newMigrator(pool *pgxpool.Pool, log *slog.Logger) (*migrate.Migrator, error) {
+	migrations, err := migrate.Load(migration.Postgres())
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(pool, migrations, log), nil
+}
+
+// prepareSchema applies pending migrations if it's enabled and checks the schema is up to date,
+// the service can't serve with older schema than it needs.
+func prepareSchema(cfg config.MigrationsConfig, pool *pgxpool.Pool, log *slog.Logger) error {
+	migrator, err := newMigrator(pool, log)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if cfg.Auto {
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		log.Info("Database schema is migrated", slog.Int("applied", applied), slog.Int("version", migrator.Latest()))
+	}
+
+	return migrator.Check(ctx)
+}
+
+// runMigrate manages the schema with its own connection, as storages can't be used with outdated schema.
+func runMigrate(ctx context.Context, cfg config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	if cfg.Storage.Backend != config.StoragePostgres {
+		return errors.New("migrations require postgres storage")
+	}
+
+	pool, err := infra.NewPostgresPool(cfg.PG)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	migrator, err := newMigrator(pool, cliLogger())
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("applied %d migrations\n", applied)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %q: %s", args[1], migrateUsage)
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("reverted %d migrations\n", reverted)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		printStatus(statuses)
+	default:
+		return fmt.Errorf("unknown migrate command %q: %s", args[0], migrateUsage)
+	}
+
+	return nil
+}
+
+func printStatus(statuses []migrate.Status) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.Applied {
+			appliedAt = status.AppliedAt.Format(time.RFC3339)
+		}
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+	}
+	_ = w.Flush()
+}
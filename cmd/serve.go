@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"ozon_task/internal/api/gateway"
+	grpcapp "ozon_task/internal/app/grpc"
+	httpapp "ozon_task/internal/app/http"
+	"ozon_task/internal/auth"
+	"ozon_task/internal/config"
+	"ozon_task/internal/idempotency"
+	"ozon_task/internal/ratelimit"
+	"ozon_task/internal/repository"
+	"ozon_task/internal/repository/degraded"
+	"ozon_task/internal/repository/inmem"
+	"ozon_task/internal/repository/migration"
+	"ozon_task/internal/repository/postgres"
+	redisrepo "ozon_task/internal/repository/redis"
+	"ozon_task/internal/stale"
+	"ozon_task/internal/usecases/service"
+	"ozon_task/pkg/jwt"
+	pkglog "ozon_task/pkg/log"
+	pkgratelimit "ozon_task/pkg/ratelimit"
+	"ozon_task/pkg/shutdown"
+	pkgtls "ozon_task/pkg/tls"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// runServe serves HTTP and gRPC apis until the shutdown signal.
+func runServe(_ context.Context, cfg config.Config, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("unexpected arguments %q", args)
+	}
+
+	log, file := pkglog.NewLogger(cfg.Logger)
+	defer func() { _ = file.Close() }()
+	slog.SetDefault(log)
+	log.Info("Starting URL Shortener", slog.Any("config", cfg))
+
+	st, err := initStorage(cfg, log)
+	if err != nil {
+		pkglog.Fatal(log, "error while setting up storage: ", err)
+	}
+	defer st.close(log)
+	initStaleLinks(&st, cfg, log)
+
+	urlService := service.NewURLService(st.urls)
+	authService := service.NewAuthService(st.apiKeys, cfg.Auth.AdminToken.Value())
+	authorizer, keySource := initAuth(cfg.Auth, authService, log)
+	limiter := initRateLimiter(cfg.RateLimit, st.redisClient, log)
+	keeper := idempotency.NewKeeper(cfg.Idempotency, initIdempotencyStore(cfg.Idempotency, st, log))
+
+	grpcTLS := initTLS(cfg.GRPC.TLS, log)
+	httpTLS := initTLS(cfg.HTTPServer.TLS, log)
+
+	grpcApp := grpcapp.New(log, urlService, authorizer, limiter, keeper, cfg.GRPC, cfg.Links, grpcTLS.ServerConfig())
+
+	gatewayCtx, stopGateway := context.WithCancel(context.Background())
+	defer stopGateway()
+	gatewayHandler := initGateway(gatewayCtx, cfg, grpcApp, log)
+
+	httpApp := httpapp.New(log, APIPath, st.urls, authService, authorizer,
+		cfg.HTTPServer, cfg.Links, httpTLS.ServerConfig(), gatewayHandler, st.dependencies, componentMetrics(st)...)
+
+	g, ctx := errgroup.WithContext(context.Background())
+	g.Go(func() error {
+		return shutdown.ListenSignal(ctx, log)
+	})
+
+	g.Go(func() error {
+		return keySource.Run(ctx)
+	})
+
+	g.Go(func() error {
+		return grpcTLS.Watch(ctx)
+	})
+
+	g.Go(func() error {
+		return httpTLS.Watch(ctx)
+	})
+
+	g.Go(func() error {
+		return runBackfill(ctx, cfg, st, log)
+	})
+
+	if st.replicas != nil {
+		g.Go(func() error {
+			return st.replicas.Run(ctx)
+		})
+	}
+
+	g.Go(func() error {
+		return httpApp.Run()
+	})
+
+	g.Go(func() error {
+		return grpcApp.Run()
+	})
+
+	g.Go(func() error {
+		<-ctx.Done()
+		log.Info("Shutdown signal received, stopping servers")
+		return shutdownServices(grpcApp, httpApp)
+	})
+
+	if err = g.Wait(); err != nil && !errors.Is(err, shutdown.ErrOSSignal) {
+		log.Error("Exit reason", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+// initStaleLinks wraps links of postgres storage to serve recently resolved ones while it's unavailable.
+func initStaleLinks(st *storage, cfg config.Config, log *slog.Logger) {
+	if !cfg.Stale.Enabled || st.dbPool == nil {
+		return
+	}
+
+	var err error
+	st.staleLinks, err = stale.Open(cfg.Stale)
+	if err != nil {
+		pkglog.Fatal(log, "error while opening stale links store: ", err)
+	}
+	st.urls = degraded.NewURLRepository(st.urls, st.staleLinks, log)
+	log.Info("Serving stale links while Postgres is unavailable", slog.Int("links", st.staleLinks.Len()))
+
+	if st.redisClient != nil {
+		go warmStaleLinks(st.staleLinks, st.redisClient, cfg.Stale.WarmTimeout, log)
+	}
+}
+
+// warmStaleLinks adds links cached in redis to the stale links store.
+func warmStaleLinks(store *stale.Store, client *redis.Client, timeout time.Duration, log *slog.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	added, err := store.Warm(ctx, redisrepo.NewLinkScanner(client))
+	if err != nil {
+		log.Warn("failed to warm stale links store from redis", pkglog.Err(err))
+	}
+	log.Info("Stale links store warmed from redis", slog.Int("added", added))
+}
+
+// componentMetrics returns metrics served along with state of the dependencies.
+func componentMetrics(st storage) []httpapp.MetricsWriter {
+	if st.migration == nil {
+		return nil
+	}
+	return []httpapp.MetricsWriter{st.migrationMetrics}
+}
+
+// runBackfill copies links stored before the migration to the target backend. Failed backfill
+// doesn't stop the service, as links keep being written to both backends.
+func runBackfill(ctx context.Context, cfg config.Config, st storage, log *slog.Logger) error {
+	backfillCfg := cfg.Storage.Migration.Backfill
+	if st.migration == nil || !backfillCfg.Enabled {
+		return nil
+	}
+
+	backfiller := migration.NewBackfiller(st.source, st.target, backfillCfg, st.migrationMetrics, log)
+	if err := backfiller.Run(ctx); err != nil && ctx.Err() == nil {
+		log.Error("backfill failed", pkglog.Err(err))
+	}
+	return nil
+}
+
+// initTLS loads certificates for enabled TLS config, returns nil otherwise.
+func initTLS(cfg pkgtls.Config, log *slog.Logger) *pkgtls.Reloader {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	reloader, err := pkgtls.NewReloader(cfg, log)
+	if err != nil {
+		pkglog.Fatal(log, "error while loading tls certificates: ", err)
+	}
+
+	return reloader
+}
+
+// initGateway creates JSON transcoding handler of the gRPC api, which serves links over HTTP.
+// It calls the gRPC server of the process without network unless another address is configured.
+func initGateway(ctx context.Context, cfg config.Config, grpcApp *grpcapp.App, log *slog.Logger) http.Handler {
+	gatewayCfg := cfg.HTTPServer.Gateway
+
+	// the authority matches certificates issued for localhost
+	endpoint := "passthrough:///localhost"
+	opts := []grpc.DialOption{grpcApp.InProcessDialer()}
+	if len(gatewayCfg.GRPCAddress) != 0 {
+		endpoint, opts = gatewayCfg.GRPCAddress, nil
+	}
+
+	creds := insecure.NewCredentials()
+	if cfg.GRPC.TLS.Enabled {
+		tlsConfig, err := pkgtls.NewClientConfig(gatewayCfg.TLS)
+		if err != nil {
+			pkglog.Fatal(log, "error while loading gateway tls certificates: ", err)
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	opts = append(opts, grpc.WithTransportCredentials(creds))
+
+	handler, err := gateway.NewHandler(ctx, endpoint, opts...)
+	if err != nil {
+		pkglog.Fatal(log, "error while creating gateway: ", err)
+	}
+
+	return handler
+}
+
+// initAuth creates authorizer with authenticators enabled by mode.
+// Returns nil key source if jwt validation is disabled.
+func initAuth(cfg auth.Config, authService *service.AuthService, log *slog.Logger) (*auth.Authorizer, *jwt.KeySource) {
+	if !cfg.Enabled {
+		return auth.NewAuthorizer(cfg), nil
+	}
+
+	if !cfg.APIKeysEnabled() && !cfg.JWTEnabled() {
+		pkglog.Fatal(log, "error while setting up auth: ", fmt.Errorf("unknown auth mode %q", cfg.Mode))
+	}
+
+	var authenticators []auth.Authenticator
+	if cfg.APIKeysEnabled() {
+		authenticators = append(authenticators, authService)
+	}
+
+	var keySource *jwt.KeySource
+	if cfg.JWTEnabled() {
+		var err error
+		keySource, err = jwt.NewKeySource(context.Background(),
+			cfg.JWT.JWKSFile, cfg.JWT.JWKSURL, cfg.JWT.RefreshInterval, log)
+		if err != nil {
+			pkglog.Fatal(log, "error while loading jwks: ", err)
+		}
+		authenticators = append(authenticators, auth.NewJWTAuthenticator(keySource, cfg.JWT))
+	}
+
+	return auth.NewAuthorizer(cfg, authenticators...), keySource
+}
+
+// initRateLimiter creates limiter with configured backend.
+// Redis backend shares limits between instances and requires enabled redis.
+func initRateLimiter(cfg ratelimit.Config, redisClient *redis.Client, log *slog.Logger) *ratelimit.Limiter {
+	if !cfg.Enabled {
+		return ratelimit.NewLimiter(cfg, nil, nil, log)
+	}
+
+	switch cfg.Backend {
+	case ratelimit.BackendMemory:
+		backend := pkgratelimit.NewInMem()
+		return ratelimit.NewLimiter(cfg, backend, backend, log)
+	case ratelimit.BackendRedis:
+		if redisClient == nil {
+			pkglog.Fatal(log, "error while setting up rate limiter: ", errors.New("redis backend requires redis cache"))
+		}
+		backend := pkgratelimit.NewRedis(redisClient, cfg.Prefix)
+		return ratelimit.NewLimiter(cfg, backend, backend, log)
+	default:
+		pkglog.Fatal(log, "error while setting up rate limiter: ", fmt.Errorf("unknown backend %q", cfg.Backend))
+		return nil
+	}
+}
+
+// initIdempotencyStore returns store of the configured backend.
+// By default responses are kept in redis if it's enabled, otherwise in the main storage.
+func initIdempotencyStore(cfg idempotency.Config, st storage, log *slog.Logger) repository.Idempotency {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	backend := cfg.Backend
+	if len(backend) == 0 {
+		switch {
+		case st.redisClient != nil:
+			backend = idempotency.BackendRedis
+		case st.dbPool != nil:
+			backend = idempotency.BackendPostgres
+		default:
+			backend = idempotency.BackendMemory
+		}
+	}
+
+	switch {
+	case backend == idempotency.BackendMemory:
+		return inmem.NewIdempotencyRepository()
+	case backend == idempotency.BackendRedis && st.redisClient != nil:
+		return redisrepo.NewIdempotencyRepository(st.redisClient)
+	case backend == idempotency.BackendPostgres && st.dbPool != nil:
+		return postgres.NewIdempotencyRepository(st.dbPool, log)
+	default:
+		pkglog.Fatal(log, "error while setting up idempotency store: ",
+			fmt.Errorf("backend %q is unknown or its storage is disabled", backend))
+		return nil
+	}
+}
+
+// shutdownServices gracefully shutdown apps. HTTP is stopped first, because the gateway forwards its requests
+// to the gRPC server.
+func shutdownServices(grpcApp *grpcapp.App, httpApp *httpapp.App) error {
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := httpApp.Stop(shutdownCtx)
+	grpcApp.Stop()
+	return err
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"ozon_task/pkg/client"
+	pkgtls "ozon_task/pkg/tls"
+)
+
+// newClient creates client of the transport of the profile.
+func newClient(p Profile) (client.Client, error) {
+	opts := []client.Option{client.WithToken(p.Token)}
+	if p.Idempotency {
+		opts = append(opts, client.WithIdempotency())
+	}
+	if p.TLS.enabled() {
+		var (
+			tlsCfg *tls.Config
+			err    error
+		)
+		if tlsCfg, err = pkgtls.NewClientConfig(p.TLS.ClientConfig); err != nil {
+			return nil, fmt.Errorf("newClient: %w", err)
+		}
+		opts = append(opts, client.WithTLS(tlsCfg))
+	}
+
+	if p.Transport == transportHTTP {
+		return client.NewHTTP(p.Address, opts...)
+	}
+	return client.NewGRPC(p.Address, opts...)
+}
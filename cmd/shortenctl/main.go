@@ -0,0 +1,306 @@
+// Command shortenctl is a client of the URL shortener speaking gRPC or HTTP API.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"ozon_task/pkg/client"
+	"strings"
+	"time"
+)
+
+const usageText = `Usage: shortenctl [flags] <command> [args]
+
+Commands:
+  shorten [-tag tag]... [-f file]... [url...]
+  resolve [-f file]... [shortened...]
+
+Items are taken from arguments, otherwise from -f files or stdin ("-" is stdin too),
+one per line. Blank lines and lines starting with # are skipped. The exit code is 1
+if any item failed.
+
+Flags:
+`
+
+// errUsage is returned for invalid arguments, the exit code is 2 then.
+var errUsage = errors.New("invalid usage")
+
+// stringsFlag collects values of the repeated flag.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func main() {
+	os.Exit(run(context.Background(), os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command line and returns the exit code.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	failed, err := execute(ctx, args, stdin, stdout, stderr)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errUsage):
+		_, _ = fmt.Fprintln(stderr, err)
+		return 2
+	case err != nil:
+		_, _ = fmt.Fprintf(stderr, "shortenctl: %s\n", err)
+		return 1
+	case failed > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// execute runs the command and returns the number of failed items.
+func execute(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	flags := flag.NewFlagSet("shortenctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		_, _ = fmt.Fprint(stderr, usageText)
+		flags.PrintDefaults()
+	}
+
+	var (
+		configPath = flags.String("config", "", "Path to the config file (default is $"+configEnvVar+
+			" or shortenctl/config.yml in the user config directory)")
+		profileName = flags.String("profile", "", "Profile of the config file (default is its current profile)")
+		override    Profile
+	)
+	flags.StringVar(&override.Transport, "transport", "", "Transport: grpc or http (default grpc)")
+	flags.StringVar(&override.Address, "addr", "", "Address of the server, host:port or base URL for http")
+	flags.StringVar(&override.Token, "token", "", "Auth token (default is $"+tokenEnvVar+")")
+	flags.StringVar(&override.Output, "o", "", "Output: table, json or plain (default table)")
+	flags.DurationVar(&override.Timeout, "timeout", 0, "Timeout of every request (default 10s)")
+	flags.BoolVar(&override.Idempotency, "idempotency", false,
+		"Server accepts idempotency keys, so failed shortening is retried")
+	flags.BoolVar(&override.TLS.Enabled, "tls", false, "Use TLS with system roots unless -ca is set")
+	flags.StringVar(&override.TLS.CAFile, "ca", "", "CA file to verify the server, enables TLS")
+	flags.StringVar(&override.TLS.CertFile, "cert", "", "Client certificate file for mTLS")
+	flags.StringVar(&override.TLS.KeyFile, "key", "", "Client key file for mTLS")
+	flags.StringVar(&override.TLS.ServerName, "server-name", "", "Server name to verify instead of the host")
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0, err
+		}
+		return 0, fmt.Errorf("%w: %s", errUsage, err)
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 0, fmt.Errorf("%w: command is required", errUsage)
+	}
+
+	path, required := *configPath, true
+	if len(path) == 0 {
+		path, required = defaultConfigPath(), false
+	}
+	file, err := loadFile(path, required)
+	if err != nil {
+		return 0, err
+	}
+	p, err := file.profile(*profileName)
+	if err != nil {
+		return 0, err
+	}
+	flags.Visit(func(f *flag.Flag) {
+		p = p.override(f.Name, override)
+	})
+	if p, err = p.withDefaults(); err != nil {
+		return 0, fmt.Errorf("%w: %s", errUsage, err)
+	}
+
+	name, cmdArgs := flags.Arg(0), flags.Args()[1:]
+	var cmd func(cl client.Client, args []string) (command, error)
+	switch name {
+	case "shorten":
+		cmd = shortenCommand
+	case "resolve":
+		cmd = resolveCommand
+	default:
+		return 0, fmt.Errorf("%w: unknown command %q", errUsage, name)
+	}
+
+	cl, err := newClient(p)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = cl.Close() }()
+
+	c, err := cmd(cl, cmdArgs)
+	if err != nil {
+		return 0, err
+	}
+
+	w := writers[p.Output](stdout, stderr, c.resolve)
+	failed, err := c.run(ctx, p.Timeout, stdin, w)
+	if flushErr := w.flush(); err == nil {
+		err = flushErr
+	}
+	return failed, err
+}
+
+// override sets the field of the profile given by the flag name.
+func (p Profile) override(name string, flags Profile) Profile {
+	switch name {
+	case "transport":
+		p.Transport = flags.Transport
+	case "addr":
+		p.Address = flags.Address
+	case "token":
+		p.Token = flags.Token
+	case "o":
+		p.Output = flags.Output
+	case "timeout":
+		p.Timeout = flags.Timeout
+	case "idempotency":
+		p.Idempotency = flags.Idempotency
+	case "tls":
+		p.TLS.Enabled = flags.TLS.Enabled
+	case "ca":
+		p.TLS.CAFile = flags.TLS.CAFile
+	case "cert":
+		p.TLS.CertFile = flags.TLS.CertFile
+	case "key":
+		p.TLS.KeyFile = flags.TLS.KeyFile
+	case "server-name":
+		p.TLS.ServerName = flags.TLS.ServerName
+	}
+	return p
+}
+
+// command applies do to every item, items are arguments or lines of files.
+type command struct {
+	items   []string
+	files   []string
+	resolve bool
+	do      func(ctx context.Context, item string) result
+}
+
+func shortenCommand(cl client.Client, args []string) (command, error) {
+	flags := flag.NewFlagSet("shorten", flag.ContinueOnError)
+	var tags, files stringsFlag
+	flags.Var(&tags, "tag", "Tag of the links, repeatable")
+	flags.Var(&files, "f", "File with urls, one per line, repeatable")
+	if err := parseCommandFlags(flags, args); err != nil {
+		return command{}, err
+	}
+
+	return command{
+		items: flags.Args(),
+		files: files,
+		do: func(ctx context.Context, original string) result {
+			shortened, err := cl.Shorten(ctx, original, tags...)
+			return result{Original: original, Shortened: shortened, Err: err}
+		},
+	}, nil
+}
+
+func resolveCommand(cl client.Client, args []string) (command, error) {
+	flags := flag.NewFlagSet("resolve", flag.ContinueOnError)
+	var files stringsFlag
+	flags.Var(&files, "f", "File with shortened urls, one per line, repeatable")
+	if err := parseCommandFlags(flags, args); err != nil {
+		return command{}, err
+	}
+
+	return command{
+		items:   flags.Args(),
+		files:   files,
+		resolve: true,
+		do: func(ctx context.Context, shortened string) result {
+			original, err := cl.Resolve(ctx, shortened)
+			return result{Original: original, Shortened: shortened, Err: err}
+		},
+	}, nil
+}
+
+func parseCommandFlags(flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil && !errors.Is(err, flag.ErrHelp) {
+		return fmt.Errorf("%w: %s", errUsage, err)
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// run processes items one by one in order and returns the number of failed ones.
+func (c command) run(ctx context.Context, timeout time.Duration, stdin io.Reader, w resultWriter) (int, error) {
+	failed := 0
+	process := func(item string) error {
+		itemCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		res := c.do(itemCtx, item)
+		if res.Err != nil {
+			failed++
+		}
+		return w.write(res)
+	}
+
+	if len(c.items) != 0 {
+		if len(c.files) != 0 {
+			return 0, fmt.Errorf("%w: items are given both as arguments and files", errUsage)
+		}
+		for _, item := range c.items {
+			if err := process(item); err != nil {
+				return failed, err
+			}
+		}
+		return failed, nil
+	}
+
+	files := c.files
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	for _, path := range files {
+		if err := readLines(path, stdin, process); err != nil {
+			return failed, err
+		}
+	}
+
+	return failed, nil
+}
+
+// readLines calls fn for every meaningful line of the file, "-" is stdin.
+func readLines(path string, stdin io.Reader, fn func(line string) error) error {
+	r := stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = file.Close() }()
+		r = file
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read %s failed: %w", path, err)
+	}
+
+	return nil
+}
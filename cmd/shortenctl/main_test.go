@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"ozon_task/domain"
+	"ozon_task/internal/grpc/grpcerr"
+	"ozon_task/pkg/http/responses"
+	urlshortenerv1 "ozon_task/protos/gen/go"
+)
+
+const validShortened = "AAAAAAAAAA"
+
+func runCLI(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	t.Setenv(configEnvVar, filepath.Join(t.TempDir(), "missing.yml"))
+	t.Setenv(tokenEnvVar, "")
+
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func newHTTPServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/shorten", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		var req struct {
+			OriginalURL domain.URL   `json:"original_url"`
+			Tags        []domain.Tag `json:"tags"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, []domain.Tag{"promo"}, req.Tags)
+
+		if req.OriginalURL == "bad" {
+			w.Header().Set("Content-Type", responses.ProblemContentType)
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(responses.ErrorResponse{
+				Status: http.StatusBadRequest,
+				Detail: "invalid original url",
+				Code:   string(domain.CodeInvalidOriginal),
+			})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"shortened_url": validShortened})
+	})
+	mux.HandleFunc("GET /api/v1/resolve/{shortened}", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"original_url": "https://ozon.ru/" + r.PathValue("shortened")})
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRun_HTTPShortenBatchFromStdin(t *testing.T) {
+	srv := newHTTPServer(t)
+
+	code, stdout, stderr := runCLI(t, "https://ozon.ru/a\n# comment\n\nbad\n",
+		"-transport", "http", "-addr", srv.URL, "-token", "secret", "-o", "json", "shorten", "-tag", "promo")
+
+	require.Equal(t, 1, code, stderr)
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	require.Len(t, lines, 2)
+
+	var ok, failed jsonResult
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &ok))
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &failed))
+	require.Equal(t, jsonResult{OriginalURL: "https://ozon.ru/a", ShortenedURL: validShortened}, ok)
+	require.Equal(t, jsonResult{
+		OriginalURL: "bad",
+		Error:       "invalid original url",
+		Code:        string(domain.CodeInvalidOriginal),
+	}, failed)
+}
+
+func TestRun_HTTPResolvePlain(t *testing.T) {
+	srv := newHTTPServer(t)
+
+	code, stdout, stderr := runCLI(t, "", "-transport", "http", "-addr", srv.URL, "-o", "plain",
+		"resolve", "first", "second")
+
+	require.Equal(t, 0, code, stderr)
+	require.Equal(t, "https://ozon.ru/first\nhttps://ozon.ru/second\n", stdout)
+}
+
+type fakeShortener struct {
+	urlshortenerv1.UnimplementedURLShortenerServer
+	tokens chan string
+}
+
+func (f *fakeShortener) ResolveURL(
+	ctx context.Context,
+	req *urlshortenerv1.ResolveURLRequest,
+) (*urlshortenerv1.ResolveURLResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	f.tokens <- strings.Join(md.Get("authorization"), ",")
+
+	if req.GetShortenedUrl() != validShortened {
+		return nil, grpcerr.Error(domain.ErrOriginalNotFound)
+	}
+	return &urlshortenerv1.ResolveURLResponse{OriginalUrl: "https://ozon.ru"}, nil
+}
+
+func TestRun_GRPCResolveTableWithProfile(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	fake := &fakeShortener{tokens: make(chan string, 2)}
+	urlshortenerv1.RegisterURLShortenerServer(srv, fake)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	t.Setenv("TEST_SHORTENER_TOKEN", "from-env")
+	configPath := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+current: prod
+profiles:
+  prod:
+    address: unreachable:1
+  local:
+    transport: grpc
+    address: `+lis.Addr().String()+`
+    token_env: TEST_SHORTENER_TOKEN
+    output: plain
+`), 0o600))
+
+	code, stdout, stderr := runCLI(t, "", "-config", configPath, "-profile", "local", "-o", "table",
+		"resolve", validShortened, "BBBBBBBBBB")
+
+	require.Equal(t, 1, code, stderr)
+	require.Equal(t, "Bearer from-env", <-fake.tokens)
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	require.Len(t, lines, 3)
+	require.Equal(t, []string{"SHORTENED", "ORIGINAL", "ERROR"}, strings.Fields(lines[0]))
+	require.Equal(t, []string{validShortened, "https://ozon.ru"}, strings.Fields(lines[1]))
+	require.Contains(t, lines[2], string(domain.CodeNotFound))
+}
+
+func TestRun_Usage(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "no command", args: nil},
+		{name: "unknown command", args: []string{"expand"}},
+		{name: "unknown transport", args: []string{"-transport", "ws", "shorten", "x"}},
+		{name: "unknown output", args: []string{"-o", "xml", "shorten", "x"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, stderr := runCLI(t, "", tt.args...)
+			require.Equal(t, 2, code, stderr)
+		})
+	}
+}
+
+func TestRun_UnknownProfile(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, os.WriteFile(configPath, []byte("profiles: {}\n"), 0o600))
+
+	code, _, stderr := runCLI(t, "", "-config", configPath, "-profile", "prod", "shorten", "x")
+
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, `unknown profile "prod"`)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"ozon_task/pkg/client"
+	"text/tabwriter"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputPlain = "plain"
+)
+
+// result of one item of the command, the link is given by the input for the failed items.
+type result struct {
+	Original  string
+	Shortened string
+	Err       error
+}
+
+// resultWriter prints results of the command as they come, flush is called after the last one.
+type resultWriter interface {
+	write(res result) error
+	flush() error
+}
+
+// writers create result writers by output format, resolve tells whether the command resolves links,
+// so the shortened url is the input.
+var writers = map[string]func(stdout, stderr io.Writer, resolve bool) resultWriter{
+	outputTable: newTableWriter,
+	outputJSON:  newJSONWriter,
+	outputPlain: newPlainWriter,
+}
+
+type tableWriter struct {
+	w       *tabwriter.Writer
+	resolve bool
+	header  bool
+}
+
+func newTableWriter(stdout, _ io.Writer, resolve bool) resultWriter {
+	return &tableWriter{w: tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0), resolve: resolve}
+}
+
+func (t *tableWriter) write(res result) error {
+	if !t.header {
+		t.header = true
+		header := "ORIGINAL\tSHORTENED\tERROR"
+		if t.resolve {
+			header = "SHORTENED\tORIGINAL\tERROR"
+		}
+		if _, err := fmt.Fprintln(t.w, header); err != nil {
+			return err
+		}
+	}
+
+	first, second := res.Original, res.Shortened
+	if t.resolve {
+		first, second = second, first
+	}
+
+	_, err := fmt.Fprintf(t.w, "%s\t%s\t%s\n", first, second, errorText(res.Err))
+	return err
+}
+
+func (t *tableWriter) flush() error {
+	return t.w.Flush()
+}
+
+type jsonWriter struct {
+	encoder *json.Encoder
+}
+
+type jsonResult struct {
+	OriginalURL  string `json:"original_url"`
+	ShortenedURL string `json:"shortened_url"`
+	Error        string `json:"error,omitempty"`
+	// Code is the error code of the API, empty for client side failures.
+	Code string `json:"code,omitempty"`
+}
+
+// newJSONWriter prints a JSON object per line.
+func newJSONWriter(stdout, _ io.Writer, _ bool) resultWriter {
+	return &jsonWriter{encoder: json.NewEncoder(stdout)}
+}
+
+func (j *jsonWriter) write(res result) error {
+	out := jsonResult{OriginalURL: res.Original, ShortenedURL: res.Shortened}
+	if res.Err != nil {
+		out.Error = res.Err.Error()
+		var apiErr *client.Error
+		if errors.As(res.Err, &apiErr) {
+			out.Error, out.Code = apiErr.Message, string(apiErr.Code)
+		}
+	}
+	return j.encoder.Encode(out)
+}
+
+func (j *jsonWriter) flush() error {
+	return nil
+}
+
+type plainWriter struct {
+	stdout, stderr io.Writer
+	resolve        bool
+}
+
+// newPlainWriter prints only the result of every item, so it can be piped. Failures are printed to stderr.
+func newPlainWriter(stdout, stderr io.Writer, resolve bool) resultWriter {
+	return &plainWriter{stdout: stdout, stderr: stderr, resolve: resolve}
+}
+
+func (p *plainWriter) write(res result) error {
+	input, output := res.Original, res.Shortened
+	if p.resolve {
+		input, output = output, input
+	}
+
+	if res.Err != nil {
+		_, err := fmt.Fprintf(p.stderr, "%s: %s\n", input, errorText(res.Err))
+		return err
+	}
+	_, err := fmt.Fprintln(p.stdout, output)
+	return err
+}
+
+func (p *plainWriter) flush() error {
+	return nil
+}
+
+// errorText returns the error reported by the service without the context of the client.
+func errorText(err error) string {
+	var apiErr *client.Error
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &apiErr):
+		return apiErr.Error()
+	default:
+		return err.Error()
+	}
+}
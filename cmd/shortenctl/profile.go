@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	pkgtls "ozon_task/pkg/tls"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// configEnvVar overrides the default path of the config file.
+	configEnvVar = "SHORTENCTL_CONFIG"
+	// tokenEnvVar is used as the auth token if neither the flag nor the profile sets it.
+	tokenEnvVar = "SHORTENCTL_TOKEN"
+
+	transportGRPC = "grpc"
+	transportHTTP = "http"
+
+	defaultGRPCAddress = "localhost:5050"
+	defaultHTTPAddress = "localhost:8080"
+	defaultTimeout     = 10 * time.Second
+)
+
+// File is the config file of the client with named profiles, e.g.
+//
+//	current: local
+//	profiles:
+//	  local:
+//	    transport: grpc
+//	    address: localhost:5050
+//	    token_env: SHORTENER_TOKEN
+type File struct {
+	// Current is the profile used without -profile flag.
+	Current  string             `yaml:"current"`
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// Profile describes how to reach the service. Empty fields are filled by defaults,
+// flags override the profile.
+type Profile struct {
+	Transport string `yaml:"transport"`
+	// Address is `host:port` of the server, HTTP address may also be a base URL, e.g. `https://sho.rt`.
+	Address string `yaml:"address"`
+	// Token is sent as `Authorization: Bearer <token>`, TokenEnv names environment variable with it.
+	Token    string        `yaml:"token"`
+	TokenEnv string        `yaml:"token_env"`
+	Output   string        `yaml:"output"`
+	Timeout  time.Duration `yaml:"timeout"`
+	TLS      TLSProfile    `yaml:"tls"`
+	// Idempotency tells that the server accepts idempotency keys, so creations of links are retried.
+	Idempotency bool `yaml:"idempotency"`
+}
+
+// TLSProfile enables TLS when Enabled or CAFile is set, system roots are used without CAFile.
+type TLSProfile struct {
+	Enabled bool `yaml:"enabled"`
+
+	pkgtls.ClientConfig `yaml:",inline"`
+}
+
+func (t TLSProfile) enabled() bool {
+	return t.Enabled || len(t.CAFile) != 0
+}
+
+// defaultConfigPath returns SHORTENCTL_CONFIG or shortenctl/config.yml in the user config directory.
+func defaultConfigPath() string {
+	if path := os.Getenv(configEnvVar); len(path) != 0 {
+		return path
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "shortenctl", "config.yml")
+}
+
+// loadFile reads the config file, missing file is an empty config unless it's required.
+func loadFile(path string, required bool) (File, error) {
+	var file File
+	if len(path) == 0 {
+		return file, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return file, nil
+	} else if err != nil {
+		return file, fmt.Errorf("loadFile: %w", err)
+	}
+
+	if err = yaml.Unmarshal(data, &file); err != nil {
+		return file, fmt.Errorf("loadFile: invalid config %s: %w", path, err)
+	}
+
+	return file, nil
+}
+
+// profile returns the named profile or the current one if name is empty.
+// No profile is selected if the file has none.
+func (f File) profile(name string) (Profile, error) {
+	if len(name) == 0 {
+		name = f.Current
+	}
+	if len(name) == 0 {
+		return Profile{}, nil
+	}
+
+	p, ok := f.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown profile %q", name)
+	}
+	return p, nil
+}
+
+// withDefaults resolves the token and fills empty fields.
+func (p Profile) withDefaults() (Profile, error) {
+	if len(p.Transport) == 0 {
+		p.Transport = transportGRPC
+	}
+	if len(p.Token) == 0 && len(p.TokenEnv) != 0 {
+		p.Token = os.Getenv(p.TokenEnv)
+	}
+	if len(p.Token) == 0 {
+		p.Token = os.Getenv(tokenEnvVar)
+	}
+	if len(p.Output) == 0 {
+		p.Output = outputTable
+	}
+	if p.Timeout <= 0 {
+		p.Timeout = defaultTimeout
+	}
+
+	switch p.Transport {
+	case transportGRPC:
+		if len(p.Address) == 0 {
+			p.Address = defaultGRPCAddress
+		}
+	case transportHTTP:
+		if len(p.Address) == 0 {
+			p.Address = defaultHTTPAddress
+		}
+	default:
+		return p, fmt.Errorf("unknown transport %q, expected %s or %s", p.Transport, transportGRPC, transportHTTP)
+	}
+
+	if _, ok := writers[p.Output]; !ok {
+		return p, fmt.Errorf("unknown output %q, expected %s, %s or %s", p.Output, outputTable, outputJSON, outputPlain)
+	}
+
+	return p, nil
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"ozon_task/internal/cluster"
+	"ozon_task/internal/config"
+	"ozon_task/internal/repository"
+	"ozon_task/internal/repository/inmem"
+	"ozon_task/internal/repository/migration"
+	"ozon_task/internal/repository/postgres"
+	"ozon_task/internal/repository/resilient"
+	"ozon_task/internal/repository/sharded"
+	"ozon_task/internal/stale"
+	"ozon_task/pkg/infra"
+	"ozon_task/pkg/infra/cache"
+	pkgredis "ozon_task/pkg/infra/cache/redis"
+	cacheresilient "ozon_task/pkg/infra/cache/resilient"
+	"ozon_task/pkg/infra/cache/stub"
+	pkginmem "ozon_task/pkg/infra/kv/inmem"
+	pkglog "ozon_task/pkg/log"
+	"ozon_task/pkg/resilience"
+	"runtime"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+)
+
+type storage struct {
+	urls    repository.URL
+	apiKeys repository.APIKey
+	dbPool  *pgxpool.Pool
+	// shardPools keep links if storage.shards are set
+	shardPools []*pgxpool.Pool
+	// pgURLs are postgres repositories of links flushing batched inserts and counted clicks on close
+	pgURLs []*postgres.URLRepository
+	// replicas serve reads of links, their lag is checked by serve
+	replicas    *infra.PostgresReplicas
+	redisClient *redis.Client
+	// dependencies are policies of the storages reported in health and metrics
+	dependencies []*resilience.Policy
+	staleLinks   *stale.Store
+	// node replicates links of cluster backend, clusterURLs flush clicks counted by it on close
+	node        *cluster.Node
+	clusterURLs *cluster.URLRepository
+
+	// migration is set while links are moved from the configured backend to the target one,
+	// source and target are the backends without the dual writes
+	migration        *migration.URLRepository
+	migrationMetrics *migration.Metrics
+	source, target   repository.URL
+	targetPool       *pgxpool.Pool
+}
+
+// initStorage inits repositories of the configured storage and the migration to the target backend
+// if it's enabled. Stale links are used only by serve, so they're opened separately.
+func initStorage(cfg config.Config, log *slog.Logger) (storage, error) {
+	st, err := initBackend(cfg, log)
+	if err != nil {
+		return storage{}, err
+	}
+
+	if cfg.Storage.Migration.Enabled {
+		if err = initMigration(&st, cfg, log); err != nil {
+			st.close(log)
+			return storage{}, fmt.Errorf("error while setting up storage migration: %w", err)
+		}
+	}
+
+	return st, nil
+}
+
+// initBackend inits repositories of storage.backend.
+func initBackend(cfg config.Config, log *slog.Logger) (storage, error) {
+	var st storage
+
+	if cfg.Storage.Backend == config.StorageMemory {
+		st.urls = newInMemURLRepository()
+		st.apiKeys = inmem.NewAPIKeyRepository()
+		log.Info("Using in-memory storage")
+		return st, nil
+	}
+	if cfg.Storage.Backend == config.StorageCluster {
+		node, err := cluster.NewNode(cfg.Storage.Cluster, log)
+		if err != nil {
+			return st, fmt.Errorf("error while starting cluster node: %w", err)
+		}
+		st.node = node
+		st.clusterURLs = cluster.NewURLRepository(node)
+		st.urls = st.clusterURLs
+		st.apiKeys = cluster.NewAPIKeyRepository(node)
+		log.Info("Using replicated in-memory storage", slog.String("node", cfg.Storage.Cluster.NodeID))
+		return st, nil
+	}
+
+	var err error
+	st.dbPool, err = infra.NewPostgresPool(cfg.PG)
+	if err != nil {
+		return st, fmt.Errorf("error while setting new postgres connection: %w", err)
+	}
+	if err = prepareSchema(cfg.Migrations, st.dbPool, log); err != nil {
+		st.close(log)
+		return storage{}, fmt.Errorf("database schema isn't ready: %w", err)
+	}
+	st.apiKeys = postgres.NewAPIKeyRepository(st.dbPool)
+
+	st.replicas, err = infra.NewPostgresReplicas(cfg.PG, log)
+	if err != nil {
+		st.close(log)
+		return storage{}, fmt.Errorf("error while setting postgres replicas: %w", err)
+	}
+
+	var (
+		cacheService      cache.Cache = stub.NewStub()
+		cacheTTL          time.Duration
+		cacheWriteTimeout time.Duration
+	)
+	if cfg.Storage.Cache == config.CacheRedis {
+		st.redisClient, err = pkgredis.NewRedisClient(cfg.Redis)
+		if err != nil {
+			st.close(log)
+			return storage{}, fmt.Errorf("error while setting new redis connection: %w", err)
+		}
+		redisPolicy := resilience.NewPolicy("redis", cfg.Resilience.Redis, pkgredis.Classify)
+		st.dependencies = append(st.dependencies, redisPolicy)
+		cacheService = cacheresilient.New(pkgredis.NewRedisService(st.redisClient, log), redisPolicy)
+		cacheTTL, cacheWriteTimeout = cfg.Redis.TTL, cfg.Redis.WriteTimeout
+		log.Info("Using redis cache")
+	}
+
+	if len(cfg.Storage.Shards) != 0 {
+		if err = initShards(&st, cfg, cacheService, cacheTTL, cacheWriteTimeout, log); err != nil {
+			st.close(log)
+			return storage{}, err
+		}
+		return st, nil
+	}
+
+	pgPolicy := resilience.NewPolicy("postgres", cfg.Resilience.Postgres, resilient.ClassifyStorage)
+	st.dependencies = append(st.dependencies, pgPolicy)
+	urls := postgres.NewURLRepository(st.dbPool, st.replicas, cacheService, cacheTTL, cacheWriteTimeout, cfg.Storage.Batch)
+	st.pgURLs = append(st.pgURLs, urls)
+	st.urls = resilient.NewURLRepository(urls, pgPolicy)
+	log.Info("Using Postgres")
+
+	return st, nil
+}
+
+// initShards spreads links across databases of the shards, the postgres section keeps the rest of data.
+// Every shard has its own pool and circuit breaker, so a failed shard doesn't fail links of others.
+func initShards(
+	st *storage,
+	cfg config.Config,
+	cacheService cache.Cache,
+	cacheTTL, cacheWriteTimeout time.Duration,
+	log *slog.Logger,
+) error {
+	shards := make([]sharded.Shard, 0, len(cfg.Storage.Shards))
+	for _, shardCfg := range cfg.Storage.Shards {
+		pool, err := infra.NewPostgresPool(shardCfg.Postgres)
+		if err != nil {
+			return fmt.Errorf("error while setting new postgres connection of shard %s: %w", shardCfg.Name, err)
+		}
+		st.shardPools = append(st.shardPools, pool)
+		if err = prepareSchema(cfg.Migrations, pool, log); err != nil {
+			return fmt.Errorf("database schema of shard %s isn't ready: %w", shardCfg.Name, err)
+		}
+
+		policy := resilience.NewPolicy("postgres-"+shardCfg.Name, cfg.Resilience.Postgres, resilient.ClassifyStorage)
+		st.dependencies = append(st.dependencies, policy)
+		urls := postgres.NewURLRepository(pool, nil, cacheService, cacheTTL, cacheWriteTimeout, cfg.Storage.Batch)
+		st.pgURLs = append(st.pgURLs, urls)
+		shards = append(shards, sharded.Shard{Name: shardCfg.Name, URLs: resilient.NewURLRepository(urls, policy)})
+	}
+
+	urls, err := sharded.NewURLRepository(shards)
+	if err != nil {
+		return fmt.Errorf("error while setting up shards: %w", err)
+	}
+	st.urls = urls
+	log.Info("Using sharded Postgres", slog.Int("shards", len(shards)))
+
+	return nil
+}
+
+// initMigration opens the target backend and writes links to both of them. The target postgres
+// isn't cached, as the cache of the source would serve links of both backends.
+func initMigration(st *storage, cfg config.Config, log *slog.Logger) error {
+	migrationCfg := cfg.Storage.Migration
+
+	var target repository.URL
+	switch migrationCfg.Target {
+	case config.StorageMemory:
+		target = newInMemURLRepository()
+	case config.StoragePostgres:
+		pgCfg := cfg.PG
+		if migrationCfg.Postgres != nil {
+			pgCfg = *migrationCfg.Postgres
+		}
+
+		var err error
+		st.targetPool, err = infra.NewPostgresPool(pgCfg)
+		if err != nil {
+			return fmt.Errorf("error while setting new target postgres connection: %w", err)
+		}
+		if err = prepareSchema(cfg.Migrations, st.targetPool, log); err != nil {
+			return fmt.Errorf("target database schema isn't ready: %w", err)
+		}
+
+		targetPolicy := resilience.NewPolicy("postgres-target", cfg.Resilience.Postgres, resilient.ClassifyStorage)
+		st.dependencies = append(st.dependencies, targetPolicy)
+		targetURLs := postgres.NewURLRepository(st.targetPool, nil, stub.NewStub(), 0, 0, postgres.BatchConfig{})
+		st.pgURLs = append(st.pgURLs, targetURLs)
+		target = resilient.NewURLRepository(targetURLs, targetPolicy)
+	default:
+		return fmt.Errorf("unknown target backend %q", migrationCfg.Target)
+	}
+
+	st.source, st.target = st.urls, target
+	st.migrationMetrics = migration.NewMetrics()
+	st.migration = migration.NewURLRepository(st.source, st.target, migrationCfg, st.migrationMetrics, log)
+	st.urls = st.migration
+	log.Info("Migrating links",
+		slog.String("source", cfg.Storage.Backend),
+		slog.String("target", migrationCfg.Target),
+		slog.String("primary", migrationCfg.Primary))
+
+	return nil
+}
+
+func newInMemURLRepository() repository.URL {
+	const threadsFactor = 2
+	partitionsNumber := runtime.GOMAXPROCS(0) * threadsFactor
+	return inmem.NewURLRepository(pkginmem.NewPartitionedKVStorage(partitionsNumber))
+}
+
+// close releases connections of the storage.
+func (st storage) close(log *slog.Logger) {
+	// shadow reads in flight use the pools
+	if st.migration != nil {
+		st.migration.Close()
+	}
+
+	// links waiting for a batch and counted clicks are written before the pools are closed
+	for _, urls := range st.pgURLs {
+		urls.Close()
+	}
+
+	if st.clusterURLs != nil {
+		st.clusterURLs.Close()
+	}
+	if st.node != nil {
+		st.node.Close()
+	}
+
+	if st.targetPool != nil {
+		st.targetPool.Close()
+	}
+
+	if st.replicas != nil {
+		st.replicas.Close()
+	}
+
+	for _, pool := range st.shardPools {
+		pool.Close()
+	}
+
+	if st.dbPool != nil {
+		st.dbPool.Close()
+	}
+
+	if st.redisClient != nil {
+		pkgredis.ShutdownClient(st.redisClient)
+	}
+
+	if st.staleLinks != nil {
+		if err := st.staleLinks.Close(); err != nil {
+			log.Error("failed to close stale links store", pkglog.Err(err))
+		}
+	}
+}
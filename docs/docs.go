@@ -16,43 +16,158 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/resolve/{shortened}": {
+        "/v1/admin/links/export": {
             "get": {
-                "description": "Given a shortened URL, returns the corresponding original URL.\n\nThe ` + "`" + `shortened` + "`" + ` URL must be exactly **10 characters long** and consist only of:\n- Uppercase and lowercase English letters (` + "`" + `A-Z, a-z` + "`" + `)\n- Digits (` + "`" + `0-9` + "`" + `)\n- Underscore (` + "`" + `_` + "`" + `)",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Streams all links matching the filter with their metadata as JSON lines or CSV, newest first.\nFailures after the first bytes are sent truncate the output. Requires admin credentials.",
+                "produces": [
+                    "application/x-ndjson",
+                    "text/csv"
+                ],
+                "summary": "Export links",
+                "parameters": [
+                    {
+                        "enum": [
+                            "jsonl",
+                            "csv"
+                        ],
+                        "type": "string",
+                        "default": "jsonl",
+                        "description": "Output format",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Owner of links",
+                        "name": "owner_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Host of original URLs",
+                        "name": "host",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Tag of links",
+                        "name": "tag",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Links",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Unknown format",
+                        "schema": {
+                            "$ref": "#/definitions/responses.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/responses.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin credentials required",
+                        "schema": {
+                            "$ref": "#/definitions/responses.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal service error",
+                        "schema": {
+                            "$ref": "#/definitions/responses.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Storage temporarily unavailable, see Retry-After header",
+                        "schema": {
+                            "$ref": "#/definitions/responses.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/admin/links/import": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Creates links from JSON lines or CSV of the export keeping their shortened URLs.\nImport is idempotent: links already stored with the same original URL are counted as existing.\nRecords whose shortened or original URL is used by another link are reported as conflicts.\nIf the import fails, X-Import-Records header holds the number of processed records,\nthe same body is sent again with ` + "`" + `skip` + "`" + ` set to it to resume. Requires admin credentials.",
+                "consumes": [
+                    "application/x-ndjson",
+                    "text/csv"
+                ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Retrieve the original URL",
+                "summary": "Import links",
                 "parameters": [
                     {
+                        "enum": [
+                            "jsonl",
+                            "csv"
+                        ],
                         "type": "string",
-                        "description": "Shortened URL (must be 10 characters long and follow the defined character set)",
-                        "name": "shortened",
-                        "in": "path",
-                        "required": true
+                        "default": "jsonl",
+                        "description": "Input format",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of records processed by the failed import",
+                        "name": "skip",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successfully retrieved the original URL",
+                        "description": "Result of the import",
                         "schema": {
-                            "$ref": "#/definitions/types.GetOriginalURLResponse"
+                            "$ref": "#/definitions/types.ImportLinksResponse"
+                        },
+                        "headers": {
+                            "X-Import-Records": {
+                                "type": "integer",
+                                "description": "Number of processed records"
+                            }
                         }
                     },
                     "400": {
-                        "description": "Invalid format: incorrect length or invalid characters in the shortened URL",
+                        "description": "Unknown format, invalid skip or malformed CSV header",
                         "schema": {
                             "$ref": "#/definitions/responses.ErrorResponse"
                         }
                     },
-                    "404": {
-                        "description": "Shortened URL not found in the system",
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/responses.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin credentials required",
                         "schema": {
                             "$ref": "#/definitions/responses.ErrorResponse"
                         }
                     },
                     "408": {
-                        "description": "Request timeout: exceeded server execution time or client disconnected",
+                        "description": "Request timeout: client disconnected",
                         "schema": {
                             "$ref": "#/definitions/responses.ErrorResponse"
                         }
@@ -62,46 +177,182 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/responses.ErrorResponse"
                         }
+                    },
+                    "503": {
+                        "description": "Storage temporarily unavailable, see Retry-After header",
+                        "schema": {
+                            "$ref": "#/definitions/responses.ErrorResponse"
+                        }
                     }
                 }
             }
         },
-        "/shorten": {
+        "/v1/keys": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns API keys of the owner, or all keys if owner is not specified. Requires admin credentials.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "List API keys",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Owner of keys",
+                        "name": "owner_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Keys",
+                        "schema": {
+                            "$ref": "#/definitions/types.ListAPIKeysResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/responses.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin credentials required",
+                        "schema": {
+                            "$ref": "#/definitions/responses.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal service error",
+                        "schema": {
+                            "$ref": "#/definitions/responses.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Storage temporarily unavailable, see Retry-After header",
+                        "schema": {
+                            "$ref": "#/definitions/responses.ErrorResponse"
+                        }
+                    }
+                }
+            },
             "post": {
-                "description": "Accepts a JSON payload containing the original URL and returns a generated shortened URL.\n\nThe provided ` + "`" + `original_url` + "`" + ` must be a valid URL with top level domain\n- If the URL does not include an HTTP scheme (` + "`" + `http://` + "`" + ` or ` + "`" + `https://` + "`" + `), the service will automatically prepend ` + "`" + `https://` + "`" + `.\n\nIf a shortened URL already exists for the given original URL, the existing shortened URL will be returned.",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Creates a new API key for the owner. The raw key is returned only once and can't be retrieved later.\nRequires admin credentials.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Create a shortened URL",
+                "summary": "Issue an API key",
                 "parameters": [
                     {
-                        "description": "Original URL (must be publicly accessible; if no HTTP scheme is provided, ` + "`" + `https://` + "`" + ` is added automatically; URLs with more than 10 redirects return the last reachable state).",
-                        "name": "original_url",
+                        "description": "Owner and name of the key",
+                        "name": "key",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/types.PostShortURLRequest"
+                            "$ref": "#/definitions/types.PostAPIKeyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Issued key",
+                        "schema": {
+                            "$ref": "#/definitions/types.PostAPIKeyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request: empty owner or malformed json",
+                        "schema": {
+                            "$ref": "#/definitions/responses.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/responses.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin credentials required",
+                        "schema": {
+                            "$ref": "#/definitions/responses.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal service error",
+                        "schema": {
+                            "$ref": "#/definitions/responses.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Storage temporarily unavailable, see Retry-After header",
+                        "schema": {
+                            "$ref": "#/definitions/responses.ErrorResponse"
                         }
                     }
+                }
+            }
+        },
+        "/v1/keys/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Revokes API key by its id. Revoked keys can't be used for authentication. Requires admin credentials.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Revoke an API key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Key id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successfully created or retrieved an existing shortened URL",
+                        "description": "Revoked key",
                         "schema": {
-                            "$ref": "#/definitions/types.PostShortURLResponse"
+                            "$ref": "#/definitions/types.APIKeyResponse"
                         }
                     },
                     "400": {
-                        "description": "Invalid request: the provided URL is malformed, or empty",
+                        "description": "Invalid key id",
                         "schema": {
                             "$ref": "#/definitions/responses.ErrorResponse"
                         }
                     },
-                    "408": {
-                        "description": "Request timeout: exceeded server execution time or client disconnected",
+                    "401": {
+                        "description": "Missing or invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/responses.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin credentials required",
+                        "schema": {
+                            "$ref": "#/definitions/responses.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Key not found",
                         "schema": {
                             "$ref": "#/definitions/responses.ErrorResponse"
                         }
@@ -111,6 +362,12 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/responses.ErrorResponse"
                         }
+                    },
+                    "503": {
+                        "description": "Storage temporarily unavailable, see Retry-After header",
+                        "schema": {
+                            "$ref": "#/definitions/responses.ErrorResponse"
+                        }
                     }
                 }
             }
@@ -120,34 +377,181 @@ const docTemplate = `{
         "responses.ErrorResponse": {
             "type": "object",
             "properties": {
-                "message": {
+                "code": {
+                    "description": "Code is a stable code of the error, clients should rely on it instead of Detail.",
+                    "type": "string"
+                },
+                "detail": {
+                    "type": "string"
+                },
+                "instance": {
+                    "type": "string"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "type": {
                     "type": "string"
                 }
             }
         },
-        "types.GetOriginalURLResponse": {
+        "types.APIKeyResponse": {
             "type": "object",
             "properties": {
-                "original_url": {
+                "admin": {
+                    "type": "boolean"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "owner_id": {
+                    "type": "string"
+                },
+                "revoked_at": {
                     "type": "string"
                 }
             }
         },
-        "types.PostShortURLRequest": {
+        "types.ImportConflictResponse": {
             "type": "object",
             "properties": {
+                "existing_original_url": {
+                    "type": "string"
+                },
+                "existing_shortened_url": {
+                    "type": "string"
+                },
                 "original_url": {
                     "type": "string"
+                },
+                "record": {
+                    "type": "integer"
+                },
+                "shortened_url": {
+                    "type": "string"
                 }
             }
         },
-        "types.PostShortURLResponse": {
+        "types.ImportInvalidResponse": {
             "type": "object",
             "properties": {
-                "shortened_url": {
+                "code": {
+                    "type": "string"
+                },
+                "detail": {
                     "type": "string"
+                },
+                "record": {
+                    "type": "integer"
+                }
+            }
+        },
+        "types.ImportLinksResponse": {
+            "type": "object",
+            "properties": {
+                "conflicting_links": {
+                    "description": "ConflictingLinks and InvalidRecords list the first skipped records.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.ImportConflictResponse"
+                    }
+                },
+                "conflicts": {
+                    "type": "integer"
+                },
+                "existing": {
+                    "description": "Existing links are already stored with the same original url.",
+                    "type": "integer"
+                },
+                "imported": {
+                    "type": "integer"
+                },
+                "invalid": {
+                    "type": "integer"
+                },
+                "invalid_records": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.ImportInvalidResponse"
+                    }
+                },
+                "records": {
+                    "description": "Records is the number of processed records including skipped ones.",
+                    "type": "integer"
                 }
             }
+        },
+        "types.ListAPIKeysResponse": {
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.APIKeyResponse"
+                    }
+                }
+            }
+        },
+        "types.PostAPIKeyRequest": {
+            "type": "object",
+            "properties": {
+                "admin": {
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "owner_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "types.PostAPIKeyResponse": {
+            "type": "object",
+            "properties": {
+                "admin": {
+                    "type": "boolean"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "key": {
+                    "description": "Key is the raw api key, it is returned only once.",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "owner_id": {
+                    "type": "string"
+                },
+                "revoked_at": {
+                    "type": "string"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "BearerAuth": {
+            "description": "API key or JWT in format ` + "`" + `Bearer \u003ctoken\u003e` + "`" + `",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
@@ -156,7 +560,7 @@ const docTemplate = `{
 var SwaggerInfo = &swag.Spec{
 	Version:          "1.0",
 	Host:             "localhost:8080",
-	BasePath:         "/api/v1/",
+	BasePath:         "/api/",
 	Schemes:          []string{},
 	Title:            "URL Shortener API",
 	Description:      "API for URL Shortener service",
@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"slices"
+	"time"
+)
+
+type OwnerID = string
+
+// APIKey describes issued API key. Raw key is never stored, only its hash.
+type APIKey struct {
+	ID        string     `json:"id"`
+	OwnerID   OwnerID    `json:"owner_id"`
+	Name      string     `json:"name"`
+	Hash      []byte     `json:"-"`
+	Admin     bool       `json:"admin"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (k APIKey) IsRevoked() bool {
+	return k.RevokedAt != nil
+}
+
+type Scope = string
+
+const (
+	ScopeLinksRead  Scope = "links:read"
+	ScopeLinksWrite Scope = "links:write"
+	// ScopeAdmin grants every other scope.
+	ScopeAdmin Scope = "admin"
+)
+
+// Principal is an authenticated caller of the API.
+type Principal struct {
+	ID      string
+	OwnerID OwnerID
+	Scopes  []Scope
+}
+
+func (p Principal) HasScope(scope Scope) bool {
+	return slices.Contains(p.Scopes, ScopeAdmin) || slices.Contains(p.Scopes, scope)
+}
+
+const (
+	APIKeyIDSize     = 10
+	APIKeySecretSize = 32
+	APIKeySeparator  = "."
+)
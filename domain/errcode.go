@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrorCode is a stable machine-readable code of an error. Codes are part of the api
+// and never change, unlike messages of errors.
+type ErrorCode string
+
+const (
+	CodeInvalidRequest        ErrorCode = "INVALID_REQUEST"
+	CodeInvalidOriginal       ErrorCode = "INVALID_ORIGINAL"
+	CodeInvalidShortened      ErrorCode = "INVALID_SHORTENED"
+	CodeInvalidTags           ErrorCode = "INVALID_TAGS"
+	CodeInvalidCursor         ErrorCode = "INVALID_CURSOR"
+	CodeInvalidFilter         ErrorCode = "INVALID_FILTER"
+	CodeInvalidVersion        ErrorCode = "INVALID_VERSION"
+	CodeInvalidExpiration     ErrorCode = "INVALID_EXPIRATION"
+	CodeInvalidLinkUpdate     ErrorCode = "INVALID_LINK_UPDATE"
+	CodeInvalidAPIKey         ErrorCode = "INVALID_API_KEY"
+	CodeInvalidIdempotencyKey ErrorCode = "INVALID_IDEMPOTENCY_KEY"
+	CodeNotFound              ErrorCode = "NOT_FOUND"
+	CodeExpired               ErrorCode = "EXPIRED"
+	// CodeAliasTaken is reserved for custom shortened urls which are already used by another link.
+	CodeAliasTaken               ErrorCode = "ALIAS_TAKEN"
+	CodeUnauthenticated          ErrorCode = "UNAUTHENTICATED"
+	CodePermissionDenied         ErrorCode = "PERMISSION_DENIED"
+	CodeRateLimited              ErrorCode = "RATE_LIMITED"
+	CodeQuotaExceeded            ErrorCode = "QUOTA_EXCEEDED"
+	CodeIdempotencyKeyReused     ErrorCode = "IDEMPOTENCY_KEY_REUSED"
+	CodeIdempotencyKeyInProgress ErrorCode = "IDEMPOTENCY_KEY_IN_PROGRESS"
+	CodeMethodNotAllowed         ErrorCode = "METHOD_NOT_ALLOWED"
+	CodeTimeout                  ErrorCode = "TIMEOUT"
+	CodeCanceled                 ErrorCode = "CANCELED"
+	CodeStorageUnavailable       ErrorCode = "STORAGE_UNAVAILABLE"
+	CodeConflict                 ErrorCode = "CONFLICT"
+	CodeConcurrentChange         ErrorCode = "CONCURRENT_CHANGE"
+	CodeStorageTimeout           ErrorCode = "STORAGE_TIMEOUT"
+	CodeInternal                 ErrorCode = "INTERNAL"
+)
+
+// errorCodes is the catalog of errors with known codes.
+var errorCodes = []struct {
+	err  error
+	code ErrorCode
+}{
+	{ErrInvalidRequest, CodeInvalidRequest},
+	{ErrInvalidOriginal, CodeInvalidOriginal},
+	{ErrInvalidShortened, CodeInvalidShortened},
+	{ErrInvalidTags, CodeInvalidTags},
+	{ErrInvalidCursor, CodeInvalidCursor},
+	{ErrInvalidFilter, CodeInvalidFilter},
+	{ErrInvalidVersion, CodeInvalidVersion},
+	{ErrInvalidExpiration, CodeInvalidExpiration},
+	{ErrInvalidLinkUpdate, CodeInvalidLinkUpdate},
+	{ErrInvalidAPIKey, CodeInvalidAPIKey},
+	{ErrInvalidIdempotencyKey, CodeInvalidIdempotencyKey},
+	{ErrOriginalNotFound, CodeNotFound},
+	{ErrShortenedNotFound, CodeNotFound},
+	{ErrVersionNotFound, CodeNotFound},
+	{ErrAPIKeyNotFound, CodeNotFound},
+	{ErrRouteNotFound, CodeNotFound},
+	{ErrLinkExpired, CodeExpired},
+	{ErrUnauthenticated, CodeUnauthenticated},
+	{ErrPermissionDenied, CodePermissionDenied},
+	{ErrRateLimited, CodeRateLimited},
+	{ErrQuotaExceeded, CodeQuotaExceeded},
+	{ErrIdempotencyKeyReused, CodeIdempotencyKeyReused},
+	{ErrIdempotencyKeyInProgress, CodeIdempotencyKeyInProgress},
+	{ErrMethodNotAllowed, CodeMethodNotAllowed},
+	{ErrConflict, CodeConflict},
+	// errors of the storage wrap their causes, which may be errors of the context
+	{ErrStorageUnavailable, CodeStorageUnavailable},
+	{ErrConcurrentChange, CodeConcurrentChange},
+	{ErrTimeout, CodeStorageTimeout},
+	{context.DeadlineExceeded, CodeTimeout},
+	{context.Canceled, CodeCanceled},
+}
+
+// TransientRetryAfter is the delay clients are asked to retry requests failed with transient errors after.
+const TransientRetryAfter = time.Second
+
+// IsTransient reports whether the code is of an infrastructure failure the request may be retried after.
+func IsTransient(code ErrorCode) bool {
+	switch code {
+	case CodeStorageUnavailable, CodeConcurrentChange, CodeStorageTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// ErrorCodeOf returns code of the error from the catalog, CodeInternal for unknown errors.
+func ErrorCodeOf(err error) ErrorCode {
+	for _, known := range errorCodes {
+		if errors.Is(err, known.err) {
+			return known.code
+		}
+	}
+	return CodeInternal
+}
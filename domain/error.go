@@ -3,8 +3,37 @@ package domain
 import "errors"
 
 var (
+	ErrInvalidRequest    = errors.New("invalid request")
+	ErrRouteNotFound     = errors.New("no such path")
+	ErrMethodNotAllowed  = errors.New("this method is not allowed")
 	ErrInvalidOriginal   = errors.New("invalid original url")
 	ErrInvalidShortened  = errors.New("invalid shortened url")
 	ErrOriginalNotFound  = errors.New("no link found by this shortened link")
 	ErrShortenedNotFound = errors.New("no link found by this original link")
+	ErrLinkExpired       = errors.New("link has expired")
+	ErrUnauthenticated   = errors.New("missing or invalid credentials")
+	ErrPermissionDenied  = errors.New("permission denied")
+	ErrAPIKeyNotFound    = errors.New("no api key found")
+	ErrInvalidAPIKey     = errors.New("invalid api key request")
+	ErrInvalidTags       = errors.New("invalid tags")
+	ErrInvalidCursor     = errors.New("invalid cursor")
+	ErrInvalidFilter     = errors.New("invalid filter")
+	ErrInvalidVersion    = errors.New("invalid link version")
+	ErrVersionNotFound   = errors.New("no version found for this link")
+	ErrInvalidExpiration = errors.New("invalid expiration time")
+	ErrInvalidLinkUpdate = errors.New("invalid link update")
+	ErrRateLimited       = errors.New("rate limit exceeded")
+	ErrQuotaExceeded     = errors.New("daily quota exceeded")
+
+	ErrInvalidIdempotencyKey    = errors.New("invalid idempotency key")
+	ErrIdempotencyKeyReused     = errors.New("idempotency key was used for a different request")
+	ErrIdempotencyKeyInProgress = errors.New("request with this idempotency key is in progress")
+
+	// ErrConflict is returned when a created link collides with an existing one, e.g. by shortened url.
+	ErrConflict = errors.New("link conflicts with an existing one")
+
+	// Errors of the infrastructure are transient, the same request may succeed later.
+	ErrStorageUnavailable = errors.New("storage is unavailable")
+	ErrConcurrentChange   = errors.New("conflicting concurrent change")
+	ErrTimeout            = errors.New("storage operation timed out")
 )
@@ -0,0 +1,22 @@
+package domain
+
+import "time"
+
+// MaxIdempotencyKeyLength limits keys provided by clients.
+const MaxIdempotencyKeyLength = 255
+
+// IdempotencyRecord is a response stored for an idempotency key.
+// Record without status code is reserved by a request which is still in progress.
+type IdempotencyRecord struct {
+	Key string
+	// Fingerprint is a hash of the request payload, used to detect key reuse for a different request.
+	Fingerprint []byte
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+}
+
+func (r IdempotencyRecord) Completed() bool {
+	return r.StatusCode != 0
+}
@@ -0,0 +1,144 @@
+package domain
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+type Tag = string
+
+const (
+	MaxLinkTags  = 10
+	MaxTagLength = 32
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 1000
+)
+
+// Link is a stored pair of original and shortened urls with its metadata.
+// Original is the current destination, it changes with every edit of the link.
+//
+// Links are deduplicated by original url within their owner until their destination or expiration is changed,
+// links created with expiration are never deduplicated.
+type Link struct {
+	ID        int64
+	Original  URL
+	Shortened ShortURL
+	OwnerID   OwnerID
+	Tags      []Tag
+	// Version starts from 1 and is incremented by every change of destination.
+	Version int
+	// Clicks is the number of resolves of the link, counted on best effort basis.
+	Clicks    int64
+	CreatedAt time.Time
+	// ExpiresAt is zero for links that never expire.
+	ExpiresAt time.Time
+}
+
+// Expired reports whether the link can't be resolved at the moment.
+func (l Link) Expired(now time.Time) bool {
+	return !l.ExpiresAt.IsZero() && !now.Before(l.ExpiresAt)
+}
+
+// OriginalKey identifies the deduplicated destination of the owner, owners don't share links.
+func OriginalKey(owner OwnerID, original URL) string {
+	return owner + "\x00" + original
+}
+
+// LinkUpdate holds changed fields of the link, nil fields are kept as is.
+type LinkUpdate struct {
+	Original *URL
+	Tags     *[]Tag
+	// ExpiresAt pointing to zero time removes expiration.
+	ExpiresAt *time.Time
+}
+
+func (u LinkUpdate) Empty() bool {
+	return u.Original == nil && u.Tags == nil && u.ExpiresAt == nil
+}
+
+// LinkVersion is a destination the link had since CreatedAt.
+// Author is the principal who set it, or the owner of the link for the first version.
+type LinkVersion struct {
+	Version   int
+	Original  URL
+	Author    string
+	CreatedAt time.Time
+}
+
+// LinkFilter narrows listing of links. Zero fields are not applied.
+type LinkFilter struct {
+	OwnerID OwnerID
+	// Host of the original url, compared case-insensitively.
+	Host string
+	Tag  Tag
+	// CreatedAfter and CreatedBefore define half-open range [CreatedAfter, CreatedBefore).
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// Search is a case-insensitive substring of the original url.
+	Search string
+}
+
+// Match reports whether link satisfies all conditions of the filter.
+func (f LinkFilter) Match(link Link) bool {
+	switch {
+	case len(f.OwnerID) != 0 && link.OwnerID != f.OwnerID,
+		len(f.Host) != 0 && URLHost(link.Original) != strings.ToLower(f.Host),
+		len(f.Tag) != 0 && !slices.Contains(link.Tags, f.Tag),
+		!f.CreatedAfter.IsZero() && link.CreatedAt.Before(f.CreatedAfter),
+		!f.CreatedBefore.IsZero() && !link.CreatedAt.Before(f.CreatedBefore),
+		len(f.Search) != 0 && !strings.Contains(strings.ToLower(link.Original), strings.ToLower(f.Search)):
+		return false
+	default:
+		return true
+	}
+}
+
+// Page requests links with id less than AfterID, newest first. Zero AfterID starts from the newest link.
+type Page struct {
+	AfterID int64
+	Limit   int
+}
+
+// LinkPage is a result of listing. Empty NextCursor means the last page.
+type LinkPage struct {
+	Links      []Link
+	NextCursor string
+}
+
+// URLHost returns lowercased host of the url or empty string if it can't be parsed.
+func URLHost(original URL) string {
+	parsed, err := url.Parse(original)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+// ValidateExpiration checks that the link expires in the future, zero time means no expiration.
+func ValidateExpiration(expiresAt time.Time, now time.Time) error {
+	if !expiresAt.IsZero() && !expiresAt.After(now) {
+		return fmt.Errorf("ValidateExpiration: expiration %s is in the past: %w", expiresAt.Format(time.RFC3339), ErrInvalidExpiration)
+	}
+
+	return nil
+}
+
+func ValidateTags(tags []Tag) error {
+	if len(tags) > MaxLinkTags {
+		return fmt.Errorf("ValidateTags: got %d tags, max %d: %w", len(tags), MaxLinkTags, ErrInvalidTags)
+	}
+
+	for _, tag := range tags {
+		if len(tag) == 0 || len(tag) > MaxTagLength {
+			return fmt.Errorf("ValidateTags: tag length must be in [1, %d]: %w", MaxTagLength, ErrInvalidTags)
+		}
+	}
+
+	return nil
+}
@@ -6,6 +6,10 @@ import (
 	"strings"
 )
 
+// DefaultMaxOriginalURLLength is the limit of length of original urls in bytes. Browsers and servers
+// commonly refuse longer urls, and links are stored and indexed with their destinations.
+const DefaultMaxOriginalURLLength = 8192
+
 func NormalizeURL(url URL) URL {
 	const prefixSecured = "https://"
 	const prefixInsecure = "http://"
@@ -17,10 +21,19 @@ func NormalizeURL(url URL) URL {
 	return url
 }
 
-func IsValidOriginalURL(original URL) (bool, error) {
+// IsValidOriginalURL checks the original url, which must be normalized and not longer than maxLength bytes.
+// Non-positive maxLength means DefaultMaxOriginalURLLength.
+func IsValidOriginalURL(original URL, maxLength int) (bool, error) {
 	if len(original) == 0 {
 		return false, fmt.Errorf("IsValidOriginalURL: empty string: %w", ErrInvalidOriginal)
 	}
+	if maxLength <= 0 {
+		maxLength = DefaultMaxOriginalURLLength
+	}
+	if len(original) > maxLength {
+		return false, fmt.Errorf("IsValidOriginalURL: length %d exceeds limit %d: %w",
+			len(original), maxLength, ErrInvalidOriginal)
+	}
 
 	rawURL, err := url.ParseRequestURI(original)
 	if err != nil || len(rawURL.Host) == 0 {
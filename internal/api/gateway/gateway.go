@@ -0,0 +1,136 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"ozon_task/domain"
+	apihttp "ozon_task/internal/api/http"
+	"ozon_task/internal/grpc/grpcerr"
+	"ozon_task/pkg/http/handlers"
+	urlshortenerv1 "ozon_task/protos/gen/go"
+	urlshortenerv2 "ozon_task/protos/gen/go/shortener/v2"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Headers passed between HTTP and gRPC as is, Authorization is forwarded by the gateway by default.
+// X-Request-Id of the response is already set by the HTTP router.
+var (
+	incomingHeaders = map[string]struct{}{
+		"Idempotency-Key": {},
+		"X-Request-Id":    {},
+	}
+	outgoingHeaders = map[string]struct{}{
+		"ratelimit-limit":     {},
+		"ratelimit-remaining": {},
+		"ratelimit-reset":     {},
+		"retry-after":         {},
+		"idempotent-replayed": {},
+	}
+)
+
+// NewHandler returns JSON transcoding handler of the shortener services generated from
+// `google.api.http` annotations, it's the only implementation of the HTTP api of links.
+// Calls are forwarded to the gRPC server on the endpoint, so they pass through the same auth,
+// rate limit and idempotency interceptors. Options must set transport credentials.
+func NewHandler(ctx context.Context, endpoint string, opts ...grpc.DialOption) (http.Handler, error) {
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions: protojson.MarshalOptions{
+				UseProtoNames:   true,
+				EmitUnpopulated: true,
+			},
+		}),
+		runtime.WithIncomingHeaderMatcher(incomingHeaderMatcher),
+		runtime.WithOutgoingHeaderMatcher(outgoingHeaderMatcher),
+		runtime.WithErrorHandler(errorHandler),
+		runtime.WithRoutingErrorHandler(routingErrorHandler),
+	)
+
+	if err := urlshortenerv1.RegisterURLShortenerHandlerFromEndpoint(ctx, mux, endpoint, opts); err != nil {
+		return nil, fmt.Errorf("NewHandler: can't register v1 service: %w", err)
+	}
+	if err := urlshortenerv2.RegisterLinkServiceHandlerFromEndpoint(ctx, mux, endpoint, opts); err != nil {
+		return nil, fmt.Errorf("NewHandler: can't register v2 service: %w", err)
+	}
+
+	return mux, nil
+}
+
+func incomingHeaderMatcher(key string) (string, bool) {
+	if _, ok := incomingHeaders[textproto.CanonicalMIMEHeaderKey(key)]; ok {
+		return key, true
+	}
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+func outgoingHeaderMatcher(key string) (string, bool) {
+	if key == "x-request-id" {
+		return "", false
+	}
+	if _, ok := outgoingHeaders[key]; ok {
+		return key, true
+	}
+	return fmt.Sprintf("%s%s", runtime.MetadataHeaderPrefix, key), true
+}
+
+// routingErrorHandler renders unknown paths and methods like the router of the HTTP api does.
+func routingErrorHandler(
+	_ context.Context,
+	_ *runtime.ServeMux,
+	_ runtime.Marshaler,
+	w http.ResponseWriter,
+	r *http.Request,
+	httpStatus int,
+) {
+	err := domain.ErrInvalidRequest
+	switch httpStatus {
+	case http.StatusMethodNotAllowed:
+		err = domain.ErrMethodNotAllowed
+	case http.StatusNotFound:
+		err = domain.ErrRouteNotFound
+	}
+	handlers.WriteProblem(w, r, apihttp.Problem(domain.ErrorCodeOf(err), err))
+}
+
+// errorHandler renders errors as problems of the HTTP api instead of google.rpc.Status,
+// the code of the problem is taken from ErrorInfo details.
+func errorHandler(
+	ctx context.Context,
+	_ *runtime.ServeMux,
+	_ runtime.Marshaler,
+	w http.ResponseWriter,
+	r *http.Request,
+	err error,
+) {
+	var routingErr *runtime.HTTPStatusError
+	if errors.As(err, &routingErr) {
+		err = routingErr.Err
+	}
+	st := status.Convert(err)
+
+	if md, ok := runtime.ServerMetadataFromContext(ctx); ok {
+		for key, values := range md.HeaderMD {
+			if header, ok := outgoingHeaderMatcher(key); ok {
+				for _, value := range values {
+					w.Header().Add(header, value)
+				}
+			}
+		}
+	}
+
+	if st.Code() == codes.Unauthenticated {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+
+	problem := apihttp.Problem(grpcerr.Reason(st), errors.New(st.Message()))
+	problem.RequestID = grpcerr.RequestID(st)
+	handlers.WriteProblem(w, r, problem)
+}
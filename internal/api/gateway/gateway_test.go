@@ -0,0 +1,223 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"ozon_task/domain"
+	"ozon_task/internal/grpc/interceptors"
+	"ozon_task/internal/grpc/url_shortener"
+	"ozon_task/internal/ratelimit"
+	"ozon_task/internal/repository/postgres/pgtest"
+	"ozon_task/internal/usecases"
+	"ozon_task/internal/usecases/mocks"
+	"ozon_task/internal/usecases/service"
+	pkggrpc "ozon_task/pkg/grpc"
+	"ozon_task/pkg/http/responses"
+	pkgratelimit "ozon_task/pkg/ratelimit"
+	urlshortenerv1 "ozon_task/protos/gen/go"
+)
+
+var dummyLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func newTestGateway(t *testing.T, service usecases.URL, chain ...grpc.UnaryServerInterceptor) http.Handler {
+	t.Helper()
+	return newLimitedTestGateway(t, service, 0, chain...)
+}
+
+// newLimitedTestGateway returns the gateway of the server limiting original urls by maxOriginalLength.
+func newLimitedTestGateway(
+	t *testing.T,
+	service usecases.URL,
+	maxOriginalLength int,
+	chain ...grpc.UnaryServerInterceptor,
+) http.Handler {
+	t.Helper()
+
+	l := pkggrpc.NewInProcessListener()
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(chain...))
+	url_shortener.Register(server, service, 5*time.Second, maxOriginalLength, dummyLogger)
+	go func() { _ = server.Serve(l) }()
+	t.Cleanup(server.Stop)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	handler, err := NewHandler(ctx, "passthrough:///localhost",
+		grpc.WithContextDialer(l.Dial), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+
+	return handler
+}
+
+func serve(t *testing.T, handler http.Handler, method, path, body string) (int, map[string]any) {
+	t.Helper()
+
+	rec := serveWithHeader(handler, method, path, body, nil)
+
+	var payload map[string]any
+	if rec.Body.Len() != 0 {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &payload), rec.Body.String())
+	}
+
+	return rec.Code, payload
+}
+
+// serveWithHeader serves JSON request with additional headers and returns the raw response.
+func serveWithHeader(handler http.Handler, method, path, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Request-Id", "req-1")
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestGateway_V1(t *testing.T) {
+	t.Parallel()
+	mockService := new(mocks.URL)
+	handler := newTestGateway(t, mockService, interceptors.RequestIDUnaryServerInterceptor())
+
+	mockService.On("CreateLink", mock.Anything, domain.Link{Original: "https://ozon.ru"}).
+		Return(domain.Link{Original: "https://ozon.ru", Shortened: "abcdefghij"}, nil)
+	mockService.On("ResolveURL", mock.Anything, "abcdefghij").Return("https://ozon.ru", nil)
+	mockService.On("ResolveURL", mock.Anything, "0123456789").Return("", domain.ErrOriginalNotFound)
+
+	code, payload := serve(t, handler, http.MethodPost, "/api/v1/shorten", `{"original_url": "ozon.ru"}`)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "abcdefghij", payload["shortened_url"])
+
+	code, payload = serve(t, handler, http.MethodGet, "/api/v1/resolve/abcdefghij", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "https://ozon.ru", payload["original_url"])
+
+	code, payload = serve(t, handler, http.MethodGet, "/api/v1/resolve/0123456789", "")
+	require.Equal(t, http.StatusNotFound, code)
+	require.Equal(t, string(domain.CodeNotFound), payload["code"])
+	require.Equal(t, domain.ErrOriginalNotFound.Error(), payload["detail"])
+	require.Equal(t, "req-1", payload["request_id"])
+
+	mockService.AssertExpectations(t)
+}
+
+func TestGateway_V2UpdateMaskFromBody(t *testing.T) {
+	t.Parallel()
+	mockService := new(mocks.URL)
+	handler := newTestGateway(t, mockService, interceptors.RequestIDUnaryServerInterceptor())
+
+	tags := []domain.Tag{"promo"}
+	link := domain.Link{Original: "https://ozon.ru", Shortened: "abcdefghij", Tags: tags, Version: 1}
+
+	mockService.On("UpdateLink", mock.Anything, link.Shortened, domain.LinkUpdate{Tags: &tags}).
+		Return(link, nil)
+
+	code, payload := serve(t, handler, http.MethodPatch, "/api/v2/links/abcdefghij", `{"tags": ["promo"]}`)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "abcdefghij", payload["shortened_url"])
+	require.Equal(t, []any{"promo"}, payload["tags"])
+
+	code, _ = serve(t, handler, http.MethodPatch, "/api/v2/links/abcdefghij", `{}`)
+	require.Equal(t, http.StatusBadRequest, code)
+
+	// null value is in the mask and removes expiration
+	noExpiration := time.Time{}
+	mockService.On("UpdateLink", mock.Anything, link.Shortened, domain.LinkUpdate{ExpiresAt: &noExpiration}).
+		Return(link, nil)
+	code, _ = serve(t, handler, http.MethodPatch, "/api/v2/links/abcdefghij", `{"expire_time": null}`)
+	require.Equal(t, http.StatusOK, code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestGateway_ErrorsMatchHTTPRouter(t *testing.T) {
+	t.Parallel()
+	handler := newTestGateway(t, new(mocks.URL), interceptors.RequestIDUnaryServerInterceptor())
+
+	code, payload := serve(t, handler, http.MethodGet, "/api/v1/links/abcdefghij/clicks", "")
+	require.Equal(t, http.StatusNotFound, code)
+	require.Equal(t, string(domain.CodeNotFound), payload["code"])
+	require.Equal(t, domain.ErrRouteNotFound.Error(), payload["detail"])
+	require.Equal(t, "/api/v1/links/abcdefghij/clicks", payload["instance"])
+
+	code, payload = serve(t, handler, http.MethodGet, "/api/v1/links/abcdefghij", "")
+	require.Equal(t, http.StatusMethodNotAllowed, code)
+	require.Equal(t, string(domain.CodeMethodNotAllowed), payload["code"])
+	require.Equal(t, domain.ErrMethodNotAllowed.Error(), payload["detail"])
+
+	code, payload = serve(t, handler, http.MethodPost, "/api/v1/shorten", `{"original_url": `)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Equal(t, string(domain.CodeInvalidRequest), payload["code"])
+}
+
+func TestGateway_StorageUnavailable(t *testing.T) {
+	t.Parallel()
+
+	handler := newTestGateway(t, service.NewURLService(pgtest.UnavailableURLRepository(t)), interceptors.RequestIDUnaryServerInterceptor())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/shorten", bytes.NewBufferString(`{"original_url": "ozon.ru"}`))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+	var problem responses.ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	require.Equal(t, string(domain.CodeStorageUnavailable), problem.Code)
+	require.Equal(t, responses.UnavailableError, problem.Detail)
+}
+
+func TestGateway_RateLimitsHTTPClients(t *testing.T) {
+	t.Parallel()
+	mockService := new(mocks.URL)
+	mockService.On("ResolveURL", mock.Anything, "abcdefghij").Return("https://ozon.ru", nil)
+
+	backend := pkgratelimit.NewInMem()
+	limiter := ratelimit.NewLimiter(ratelimit.Config{
+		Enabled: true,
+		Resolve: pkgratelimit.Limit{Rate: 1, Period: time.Hour, Burst: 1},
+	}, backend, backend, dummyLogger)
+	handler := newTestGateway(t, mockService, interceptors.RateLimitUnaryServerInterceptor(dummyLogger, limiter,
+		map[string]ratelimit.Operation{
+			urlshortenerv1.URLShortener_ResolveURL_FullMethodName: ratelimit.OperationResolve,
+		}))
+
+	resolve := func(remoteAddr, forwardedFor string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/resolve/abcdefghij", nil)
+		req.RemoteAddr = remoteAddr
+		if len(forwardedFor) != 0 {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := resolve("10.0.0.1:1234", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "1", rec.Header().Get("RateLimit-Limit"))
+
+	// addresses sent by clients aren't trusted
+	rec = resolve("10.0.0.1:4321", "10.0.0.9")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "3600", rec.Header().Get("Retry-After"))
+
+	// another client has its own bucket
+	require.Equal(t, http.StatusOK, resolve("10.0.0.2:1234", "").Code)
+}
@@ -0,0 +1,90 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"ozon_task/domain"
+	"ozon_task/internal/grpc/interceptors"
+	"ozon_task/internal/idempotency"
+	"ozon_task/internal/repository/inmem"
+	"ozon_task/internal/usecases/mocks"
+	urlshortenerv1 "ozon_task/protos/gen/go"
+)
+
+func newIdempotentGateway(t *testing.T, mockService *mocks.URL) http.Handler {
+	t.Helper()
+
+	keeper := idempotency.NewKeeper(idempotency.Config{Enabled: true, TTL: time.Minute, LockTTL: time.Second},
+		inmem.NewIdempotencyRepository())
+	return newTestGateway(t, mockService,
+		interceptors.RequestIDUnaryServerInterceptor(),
+		interceptors.IdempotencyUnaryServerInterceptor(dummyLogger, keeper, map[string]func() proto.Message{
+			urlshortenerv1.URLShortener_ShortenURL_FullMethodName: func() proto.Message {
+				return &urlshortenerv1.ShortenURLResponse{}
+			},
+		}))
+}
+
+func TestGateway_IdempotencyReplay(t *testing.T) {
+	t.Parallel()
+	mockService := new(mocks.URL)
+	handler := newIdempotentGateway(t, mockService)
+
+	mockService.On("CreateLink", mock.Anything, domain.Link{Original: "https://example.com"}).
+		Return(domain.Link{Original: "https://example.com", Shortened: "abcdefghij"}, nil).Twice()
+
+	shorten := func(key, body string) *httptest.ResponseRecorder {
+		header := http.Header{}
+		if len(key) != 0 {
+			header.Set("Idempotency-Key", key)
+		}
+		return serveWithHeader(handler, http.MethodPost, "/api/v1/shorten", body, header)
+	}
+
+	first := shorten("key", `{"original_url":"example.com"}`)
+	require.Equal(t, http.StatusOK, first.Code)
+	require.Empty(t, first.Header().Get("Idempotent-Replayed"))
+
+	replay := shorten("key", `{"original_url":"example.com"}`)
+	require.Equal(t, http.StatusOK, replay.Code)
+	require.Equal(t, first.Body.String(), replay.Body.String())
+	require.Equal(t, "true", replay.Header().Get("Idempotent-Replayed"))
+	require.Equal(t, "application/json", replay.Header().Get("Content-Type"))
+	mockService.AssertNumberOfCalls(t, "CreateLink", 1)
+
+	// the key can't be reused for another url, calls without key aren't deduplicated
+	require.Equal(t, http.StatusUnprocessableEntity, shorten("key", `{"original_url":"other.com"}`).Code)
+	require.Equal(t, http.StatusOK, shorten("", `{"original_url":"example.com"}`).Code)
+	mockService.AssertNumberOfCalls(t, "CreateLink", 2)
+}
+
+func TestGateway_IdempotencyReleaseOnFailure(t *testing.T) {
+	t.Parallel()
+	mockService := new(mocks.URL)
+	handler := newIdempotentGateway(t, mockService)
+
+	// transient errors aren't stored, so the call is executed again with the same key
+	mockService.On("CreateLink", mock.Anything, domain.Link{Original: "https://example.com"}).
+		Return(domain.Link{}, domain.ErrStorageUnavailable).Once()
+	mockService.On("CreateLink", mock.Anything, domain.Link{Original: "https://example.com"}).
+		Return(domain.Link{Original: "https://example.com", Shortened: "abcdefghij"}, nil).Once()
+
+	call := func() *httptest.ResponseRecorder {
+		return serveWithHeader(handler, http.MethodPost, "/api/v1/shorten", `{"original_url":"example.com"}`,
+			http.Header{"Idempotency-Key": {"key"}})
+	}
+
+	require.Equal(t, http.StatusServiceUnavailable, call().Code)
+	rec := call()
+	require.Equal(t, http.StatusOK, rec.Code, "failed request is executed again")
+	require.Empty(t, rec.Header().Get("Idempotent-Replayed"))
+
+	mockService.AssertExpectations(t)
+}
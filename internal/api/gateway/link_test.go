@@ -0,0 +1,108 @@
+package gateway
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"ozon_task/domain"
+	"ozon_task/internal/grpc/interceptors"
+	"ozon_task/internal/usecases/mocks"
+)
+
+func TestGateway_V2CreateLink(t *testing.T) {
+	t.Parallel()
+	mockService := new(mocks.URL)
+	handler := newTestGateway(t, mockService, interceptors.RequestIDUnaryServerInterceptor())
+
+	expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	link := domain.Link{
+		Original:  "https://ozon.ru",
+		Shortened: "abcdefghij",
+		Tags:      []domain.Tag{"promo"},
+		Version:   1,
+		ExpiresAt: expiresAt,
+	}
+
+	mockService.
+		On("CreateLink", mock.Anything, domain.Link{Original: link.Original, Tags: link.Tags, ExpiresAt: expiresAt}).
+		Return(link, nil)
+
+	code, payload := serve(t, handler, http.MethodPost, "/api/v2/links",
+		`{"original_url": "ozon.ru", "tags": ["promo"], "expire_time": "2030-01-02T03:04:05Z"}`)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "abcdefghij", payload["shortened_url"])
+	require.Equal(t, "https://ozon.ru", payload["original_url"])
+	require.Equal(t, []any{"promo"}, payload["tags"])
+	require.Equal(t, "2030-01-02T03:04:05Z", payload["expire_time"])
+
+	for _, body := range []string{`{"original_url": "https://ozon"}`, `{"original_url": ""}`} {
+		code, payload = serve(t, handler, http.MethodPost, "/api/v2/links", body)
+		require.Equal(t, http.StatusBadRequest, code, body)
+		require.Equal(t, string(domain.CodeInvalidOriginal), payload["code"], body)
+	}
+
+	mockService.AssertExpectations(t)
+}
+
+func TestGateway_V2GetLink(t *testing.T) {
+	t.Parallel()
+	mockService := new(mocks.URL)
+	handler := newTestGateway(t, mockService, interceptors.RequestIDUnaryServerInterceptor())
+
+	mockService.On("GetLink", mock.Anything, "abcdefghij").
+		Return(domain.Link{Original: "https://ozon.ru", Shortened: "abcdefghij", Clicks: 3, Version: 1}, nil)
+	mockService.On("GetLink", mock.Anything, "0123456789").
+		Return(domain.Link{}, domain.ErrOriginalNotFound)
+
+	code, payload := serve(t, handler, http.MethodGet, "/api/v2/links/abcdefghij", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "3", payload["click_count"])
+	require.Nil(t, payload["expire_time"])
+
+	code, _ = serve(t, handler, http.MethodGet, "/api/v2/links/0123456789", "")
+	require.Equal(t, http.StatusNotFound, code)
+
+	code, payload = serve(t, handler, http.MethodGet, "/api/v2/links/short", "")
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Equal(t, string(domain.CodeInvalidShortened), payload["code"])
+
+	mockService.AssertExpectations(t)
+}
+
+func TestGateway_V2UpdateLink_InvalidBodies(t *testing.T) {
+	t.Parallel()
+	handler := newTestGateway(t, new(mocks.URL), interceptors.RequestIDUnaryServerInterceptor())
+
+	// output only fields can't be updated and the body must be a link
+	for _, body := range []string{`{"owner_id": "other"}`, `{"original_url": "https://ozon"}`, `[]`} {
+		code, _ := serve(t, handler, http.MethodPatch, "/api/v2/links/abcdefghij", body)
+		require.Equal(t, http.StatusBadRequest, code, body)
+	}
+}
+
+func TestGateway_V2DeleteLink(t *testing.T) {
+	t.Parallel()
+	mockService := new(mocks.URL)
+	handler := newTestGateway(t, mockService, interceptors.RequestIDUnaryServerInterceptor())
+
+	mockService.On("DeleteLink", mock.Anything, "abcdefghij").Return(nil)
+	mockService.On("DeleteLink", mock.Anything, "0123456789").Return(domain.ErrOriginalNotFound)
+	mockService.On("DeleteLink", mock.Anything, "forbidden_").Return(domain.ErrPermissionDenied)
+
+	code, _ := serve(t, handler, http.MethodDelete, "/api/v2/links/abcdefghij", "")
+	require.Equal(t, http.StatusOK, code)
+
+	code, payload := serve(t, handler, http.MethodDelete, "/api/v2/links/0123456789", "")
+	require.Equal(t, http.StatusNotFound, code)
+	require.Equal(t, string(domain.CodeNotFound), payload["code"])
+
+	code, payload = serve(t, handler, http.MethodDelete, "/api/v2/links/forbidden_", "")
+	require.Equal(t, http.StatusForbidden, code)
+	require.Equal(t, string(domain.CodePermissionDenied), payload["code"])
+
+	mockService.AssertExpectations(t)
+}
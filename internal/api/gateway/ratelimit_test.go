@@ -0,0 +1,97 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"ozon_task/domain"
+	"ozon_task/internal/grpc/interceptors"
+	"ozon_task/internal/ratelimit"
+	"ozon_task/internal/repository/inmem"
+	"ozon_task/internal/usecases"
+	"ozon_task/internal/usecases/mocks"
+	"ozon_task/internal/usecases/service"
+	pkginmem "ozon_task/pkg/infra/kv/inmem"
+	pkgratelimit "ozon_task/pkg/ratelimit"
+	urlshortenerv1 "ozon_task/protos/gen/go"
+)
+
+func newRateLimitedGateway(t *testing.T, service usecases.URL, cfg ratelimit.Config) http.Handler {
+	t.Helper()
+
+	backend := pkgratelimit.NewInMem()
+	limiter := ratelimit.NewLimiter(cfg, backend, backend, dummyLogger)
+	return newTestGateway(t, service, interceptors.RateLimitUnaryServerInterceptor(dummyLogger, limiter,
+		map[string]ratelimit.Operation{
+			urlshortenerv1.URLShortener_ShortenURL_FullMethodName: ratelimit.OperationShorten,
+		}))
+}
+
+func TestGateway_RateLimitHeaders(t *testing.T) {
+	t.Parallel()
+	mockService := new(mocks.URL)
+	mockService.On("CreateLink", mock.Anything, domain.Link{Original: "https://ozon.ru"}).
+		Return(domain.Link{Original: "https://ozon.ru", Shortened: "abcdefghij"}, nil)
+
+	handler := newRateLimitedGateway(t, mockService, ratelimit.Config{
+		Enabled: true,
+		KeyBy:   ratelimit.KeyByAPIKey,
+		Shorten: pkgratelimit.Limit{Rate: 1, Period: time.Hour, Burst: 2},
+	})
+
+	// anonymous calls are limited by address of the client
+	for i := range 2 {
+		rec := serveWithHeader(handler, http.MethodPost, "/api/v1/shorten", `{"original_url": "ozon.ru"}`, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+		require.Equal(t, []string{"1", "0"}[i], rec.Header().Get("RateLimit-Remaining"))
+	}
+
+	rec := serveWithHeader(handler, http.MethodPost, "/api/v1/shorten", `{"original_url": "ozon.ru"}`, nil)
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "3600", rec.Header().Get("Retry-After"))
+	mockService.AssertNumberOfCalls(t, "CreateLink", 2)
+}
+
+func TestGateway_RateLimitQuota(t *testing.T) {
+	t.Parallel()
+	svc := service.NewURLService(inmem.NewURLRepository(pkginmem.NewPartitionedKVStorage(1)))
+	handler := newRateLimitedGateway(t, svc, ratelimit.Config{
+		Enabled:           true,
+		Shorten:           pkgratelimit.Limit{Rate: 100, Period: time.Second},
+		DailyShortenQuota: 2,
+	})
+
+	// invalid and deduplicated urls don't create links, so they don't spend the quota
+	codes := make([]int, 0, 5)
+	var rec *httptest.ResponseRecorder
+	for _, original := range []string{"ozon.ru/a", "https://ozon", "ozon.ru/a", "ozon.ru/b", "ozon.ru/c"} {
+		rec = serveWithHeader(handler, http.MethodPost, "/api/v1/shorten", `{"original_url": "`+original+`"}`, nil)
+		codes = append(codes, rec.Code)
+	}
+
+	require.Equal(t, []int{http.StatusOK, http.StatusBadRequest, http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		codes)
+	require.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+	require.NotEmpty(t, rec.Header().Get("Retry-After"))
+}
+
+func TestGateway_RateLimitDisabled(t *testing.T) {
+	t.Parallel()
+	mockService := new(mocks.URL)
+	mockService.On("CreateLink", mock.Anything, domain.Link{Original: "https://ozon.ru"}).
+		Return(domain.Link{Original: "https://ozon.ru", Shortened: "abcdefghij"}, nil)
+
+	handler := newRateLimitedGateway(t, mockService, ratelimit.Config{})
+
+	rec := serveWithHeader(handler, http.MethodPost, "/api/v1/shorten", `{"original_url": "ozon.ru"}`, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Empty(t, rec.Header().Get("RateLimit-Limit"))
+
+	mockService.AssertExpectations(t)
+}
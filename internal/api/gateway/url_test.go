@@ -0,0 +1,273 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"ozon_task/domain"
+	"ozon_task/internal/grpc/interceptors"
+	"ozon_task/internal/usecases/mocks"
+)
+
+func TestGateway_ShortenURL(t *testing.T) {
+	t.Parallel()
+	mockService := new(mocks.URL)
+	handler := newTestGateway(t, mockService, interceptors.RequestIDUnaryServerInterceptor())
+
+	mockService.On("CreateLink", mock.Anything, domain.Link{Original: "https://ozon.ru"}).
+		Return(domain.Link{Original: "https://ozon.ru", Shortened: "abcdefghij"}, nil)
+	mockService.On("CreateLink", mock.Anything, domain.Link{Original: "http://ozon.ru"}).
+		Return(domain.Link{Original: "http://ozon.ru", Shortened: "0123456789"}, nil)
+	mockService.On("CreateLink", mock.Anything, domain.Link{Original: "https://ozon.ru/promo", Tags: []domain.Tag{"sale"}}).
+		Return(domain.Link{Original: "https://ozon.ru/promo", Shortened: "promo_link"}, nil)
+
+	tests := []struct {
+		name      string
+		body      string
+		shortened domain.ShortURL
+	}{
+		{name: "Secure scheme", body: `{"original_url": "https://ozon.ru"}`, shortened: "abcdefghij"},
+		{name: "No scheme", body: `{"original_url": "ozon.ru"}`, shortened: "abcdefghij"},
+		{name: "Insecure scheme", body: `{"original_url": "http://ozon.ru"}`, shortened: "0123456789"},
+		{name: "Tags", body: `{"original_url": "https://ozon.ru/promo", "tags": ["sale"]}`, shortened: "promo_link"},
+	}
+
+	for _, tt := range tests {
+		code, payload := serve(t, handler, http.MethodPost, "/api/v1/shorten", tt.body)
+		require.Equal(t, http.StatusOK, code, tt.name)
+		require.Equal(t, tt.shortened, payload["shortened_url"], tt.name)
+	}
+
+	mockService.AssertExpectations(t)
+}
+
+func TestGateway_ShortenURL_Errors(t *testing.T) {
+	t.Parallel()
+	mockService := new(mocks.URL)
+	handler := newTestGateway(t, mockService, interceptors.RequestIDUnaryServerInterceptor())
+
+	mockService.On("CreateLink", mock.Anything, domain.Link{Original: "https://ozon.ru/timeout"}).
+		Return(domain.Link{}, context.DeadlineExceeded)
+	mockService.On("CreateLink", mock.Anything, domain.Link{Original: "https://ozon.ru/db"}).
+		Return(domain.Link{}, errors.New("no connection to the db"))
+
+	tests := []struct {
+		name         string
+		body         string
+		expectedCode int
+		errorCode    domain.ErrorCode
+	}{
+		{
+			name:         "Empty url",
+			body:         `{"original_url": ""}`,
+			expectedCode: http.StatusBadRequest,
+			errorCode:    domain.CodeInvalidOriginal,
+		},
+		{
+			name:         "No top level domain",
+			body:         `{"original_url": "https://ozon"}`,
+			expectedCode: http.StatusBadRequest,
+			errorCode:    domain.CodeInvalidOriginal,
+		},
+		{
+			name:         "Broken JSON",
+			body:         `{invalid json`,
+			expectedCode: http.StatusBadRequest,
+			errorCode:    domain.CodeInvalidRequest,
+		},
+		{
+			name:         "Timeout",
+			body:         `{"original_url": "https://ozon.ru/timeout"}`,
+			expectedCode: http.StatusRequestTimeout,
+			errorCode:    domain.CodeTimeout,
+		},
+		{
+			name:         "Unexpected storage error",
+			body:         `{"original_url": "https://ozon.ru/db"}`,
+			expectedCode: http.StatusInternalServerError,
+			errorCode:    domain.CodeInternal,
+		},
+	}
+
+	for _, tt := range tests {
+		code, payload := serve(t, handler, http.MethodPost, "/api/v1/shorten", tt.body)
+		require.Equal(t, tt.expectedCode, code, tt.name)
+		require.Equal(t, string(tt.errorCode), payload["code"], tt.name)
+	}
+
+	mockService.AssertExpectations(t)
+}
+
+func TestGateway_ResolveURL_Errors(t *testing.T) {
+	t.Parallel()
+	mockService := new(mocks.URL)
+	handler := newTestGateway(t, mockService, interceptors.RequestIDUnaryServerInterceptor())
+
+	mockService.On("ResolveURL", mock.Anything, "expired___").Return("", domain.ErrLinkExpired)
+	mockService.On("ResolveURL", mock.Anything, "db_failure").Return("", errors.New("no connection to the db"))
+
+	tests := []struct {
+		name         string
+		shortened    string
+		expectedCode int
+		errorCode    domain.ErrorCode
+	}{
+		{
+			name:         "Wrong length",
+			shortened:    strings.Repeat("a", domain.ShortenedURLSize+5),
+			expectedCode: http.StatusBadRequest,
+			errorCode:    domain.CodeInvalidShortened,
+		},
+		{
+			name:         "Wrong alphabet",
+			shortened:    "абвгдеёжзи",
+			expectedCode: http.StatusBadRequest,
+			errorCode:    domain.CodeInvalidShortened,
+		},
+		{
+			name:         "Expired",
+			shortened:    "expired___",
+			expectedCode: http.StatusGone,
+			errorCode:    domain.CodeExpired,
+		},
+		{
+			name:         "Unexpected storage error",
+			shortened:    "db_failure",
+			expectedCode: http.StatusInternalServerError,
+			errorCode:    domain.CodeInternal,
+		},
+	}
+
+	for _, tt := range tests {
+		code, payload := serve(t, handler, http.MethodGet, "/api/v1/resolve/"+tt.shortened, "")
+		require.Equal(t, tt.expectedCode, code, tt.name)
+		require.Equal(t, string(tt.errorCode), payload["code"], tt.name)
+	}
+
+	mockService.AssertExpectations(t)
+}
+
+func TestGateway_ListLinks(t *testing.T) {
+	t.Parallel()
+	mockService := new(mocks.URL)
+	handler := newTestGateway(t, mockService, interceptors.RequestIDUnaryServerInterceptor())
+
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	link := domain.Link{ID: 7, Original: "https://ozon.ru", Shortened: "abcdefghij", OwnerID: "team", CreatedAt: createdAt, Version: 1}
+
+	mockService.
+		On("ListLinks", mock.Anything, domain.LinkFilter{Host: "ozon.ru", Tag: "promo", CreatedAfter: createdAt}, "Nw", 10).
+		Return(domain.LinkPage{Links: []domain.Link{link}, NextCursor: "Ng"}, nil)
+
+	code, payload := serve(t, handler, http.MethodGet,
+		"/api/v1/links?host=ozon.ru&tag=promo&created_after=2025-01-02T03:04:05Z&page_token=Nw&page_size=10", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "Ng", payload["next_page_token"])
+	require.Equal(t, []any{map[string]any{
+		"original_url":  link.Original,
+		"shortened_url": link.Shortened,
+		"owner_id":      link.OwnerID,
+		"tags":          []any{},
+		"created_at":    "2025-01-02T03:04:05Z",
+		"version":       float64(1),
+	}}, payload["links"])
+
+	for _, query := range []string{"page_size=abc", "created_before=yesterday"} {
+		code, payload = serve(t, handler, http.MethodGet, "/api/v1/links?"+query, "")
+		require.Equal(t, http.StatusBadRequest, code, query)
+		require.Equal(t, string(domain.CodeInvalidRequest), payload["code"], query)
+	}
+
+	mockService.AssertExpectations(t)
+}
+
+func TestGateway_UpdateLink(t *testing.T) {
+	t.Parallel()
+	mockService := new(mocks.URL)
+	handler := newTestGateway(t, mockService, interceptors.RequestIDUnaryServerInterceptor())
+
+	newURL := "https://finance.ozon.ru"
+	mockService.On("UpdateLink", mock.Anything, "abcdefghij", domain.LinkUpdate{Original: &newURL}).
+		Return(domain.Link{Original: newURL, Shortened: "abcdefghij", Version: 2}, nil)
+
+	code, payload := serve(t, handler, http.MethodPatch, "/api/v1/links/abcdefghij", `{"original_url": "finance.ozon.ru"}`)
+	require.Equal(t, http.StatusOK, code)
+	link := payload["link"].(map[string]any)
+	require.Equal(t, newURL, link["original_url"])
+	require.Equal(t, float64(2), link["version"])
+
+	originalURL := "https://ozon.ru"
+	mockService.On("UpdateLink", mock.Anything, "forbidden_", domain.LinkUpdate{Original: &originalURL}).
+		Return(domain.Link{}, domain.ErrPermissionDenied)
+	mockService.On("UpdateLink", mock.Anything, "0123456789", domain.LinkUpdate{Original: &originalURL}).
+		Return(domain.Link{}, domain.ErrOriginalNotFound)
+
+	tests := []struct {
+		shortened    domain.ShortURL
+		originalURL  domain.URL
+		expectedCode int
+	}{
+		{shortened: "short", originalURL: "https://ozon.ru", expectedCode: http.StatusBadRequest},
+		{shortened: "abcdefghij", originalURL: "https://ozon", expectedCode: http.StatusBadRequest},
+		{shortened: "forbidden_", originalURL: "https://ozon.ru", expectedCode: http.StatusForbidden},
+		{shortened: "0123456789", originalURL: "https://ozon.ru", expectedCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		code, _ = serve(t, handler, http.MethodPatch, "/api/v1/links/"+tt.shortened,
+			`{"original_url": "`+tt.originalURL+`"}`)
+		require.Equal(t, tt.expectedCode, code, tt)
+	}
+
+	mockService.AssertExpectations(t)
+}
+
+func TestGateway_RollbackLink(t *testing.T) {
+	t.Parallel()
+	mockService := new(mocks.URL)
+	handler := newTestGateway(t, mockService, interceptors.RequestIDUnaryServerInterceptor())
+
+	mockService.On("RollbackLink", mock.Anything, "abcdefghij", 3).
+		Return(domain.Link{}, domain.ErrVersionNotFound)
+	// missing version is rejected by the service
+	mockService.On("RollbackLink", mock.Anything, "abcdefghij", 0).
+		Return(domain.Link{}, domain.ErrInvalidVersion)
+
+	code, payload := serve(t, handler, http.MethodPost, "/api/v1/links/abcdefghij/rollback", `{"version": 3}`)
+	require.Equal(t, http.StatusNotFound, code)
+	require.Equal(t, string(domain.CodeNotFound), payload["code"])
+
+	code, payload = serve(t, handler, http.MethodPost, "/api/v1/links/abcdefghij/rollback", `{}`)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Equal(t, string(domain.CodeInvalidVersion), payload["code"])
+
+	mockService.AssertExpectations(t)
+}
+
+func TestGateway_TooLongURL(t *testing.T) {
+	t.Parallel()
+	mockService := new(mocks.URL)
+	handler := newLimitedTestGateway(t, mockService, 32, interceptors.RequestIDUnaryServerInterceptor())
+
+	// the service isn't called for urls over the limit
+	body := `{"original_url": "https://ozon.ru/` + strings.Repeat("a", 17) + `"}`
+	for _, req := range []struct{ method, path string }{
+		{method: http.MethodPost, path: "/api/v1/shorten"},
+		{method: http.MethodPatch, path: "/api/v1/links/abcdefghij"},
+		{method: http.MethodPost, path: "/api/v2/links"},
+		{method: http.MethodPatch, path: "/api/v2/links/abcdefghij"},
+	} {
+		code, payload := serve(t, handler, req.method, req.path, body)
+		require.Equal(t, http.StatusBadRequest, code, req)
+		require.Equal(t, string(domain.CodeInvalidOriginal), payload["code"], req)
+	}
+
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,231 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"ozon_task/domain"
+	"ozon_task/internal/api/http/types"
+	"ozon_task/internal/auth"
+	"ozon_task/internal/usecases"
+	"ozon_task/pkg/http/handlers"
+	resp "ozon_task/pkg/http/responses"
+	pkglog "ozon_task/pkg/log"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// AuthMiddleware checks `Authorization: Bearer` credentials of incoming requests.
+type AuthMiddleware struct {
+	logger     *slog.Logger
+	authorizer *auth.Authorizer
+}
+
+func NewAuthMiddleware(logger *slog.Logger, authorizer *auth.Authorizer) *AuthMiddleware {
+	return &AuthMiddleware{
+		logger:     logger,
+		authorizer: authorizer,
+	}
+}
+
+// AllowAnonymousRead lets anonymous callers through unless resolving requires auth.
+func (m *AuthMiddleware) AllowAnonymousRead() func(http.Handler) http.Handler {
+	return m.Require(auth.Rule{Scope: domain.ScopeLinksRead, Anonymous: true})
+}
+
+func (m *AuthMiddleware) RequireRead() func(http.Handler) http.Handler {
+	return m.Require(auth.Rule{Scope: domain.ScopeLinksRead})
+}
+
+func (m *AuthMiddleware) RequireWrite() func(http.Handler) http.Handler {
+	return m.Require(auth.Rule{Scope: domain.ScopeLinksWrite})
+}
+
+func (m *AuthMiddleware) RequireAdmin() func(http.Handler) http.Handler {
+	return m.Require(auth.Rule{Scope: domain.ScopeAdmin})
+}
+
+func (m *AuthMiddleware) Require(rule auth.Rule) func(http.Handler) http.Handler {
+	const op = "AuthMiddleware.Require"
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx, err := m.authorizer.Authorize(r.Context(), r.Header.Get("Authorization"), rule)
+			if err != nil {
+				m.logger.Warn("request rejected",
+					slog.String("op", op),
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+					slog.String("scope", rule.Scope),
+					pkglog.Err(err),
+				)
+
+				if errors.Is(err, domain.ErrUnauthenticated) {
+					w.Header().Set("WWW-Authenticate", "Bearer")
+				}
+				handlers.Converter(func(*http.Request) resp.Response {
+					return errorResponse(err)
+				})(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+type AuthHandler struct {
+	logger          *slog.Logger
+	service         usecases.Auth
+	responseTimeout time.Duration
+}
+
+func NewAuthHandler(logger *slog.Logger, service usecases.Auth, responseTimeout time.Duration) *AuthHandler {
+	return &AuthHandler{
+		logger:          logger,
+		service:         service,
+		responseTimeout: responseTimeout,
+	}
+}
+
+const keysPath = "/keys"
+const keyPath = "/keys/{id}"
+
+func (h *AuthHandler) WithAuthHandlers(guard *AuthMiddleware) handlers.RouterOption {
+	return func(r chi.Router) {
+		admin := r.With(guard.RequireAdmin())
+		handlers.AddHandler(admin.Post, keysPath, h.postAPIKey)
+		handlers.AddHandler(admin.Get, keysPath, h.getAPIKeys)
+		handlers.AddHandler(admin.Delete, keyPath, h.deleteAPIKey)
+	}
+}
+
+// @Summary		Issue an API key
+// @Description	Creates a new API key for the owner. The raw key is returned only once and can't be retrieved later.
+// @Description	Requires admin credentials.
+//
+// @Security		BearerAuth
+// @Accept			json
+// @Produce		json
+// @Param			key	body		types.PostAPIKeyRequest		true	"Owner and name of the key"
+// @Success		201	{object}	types.PostAPIKeyResponse	"Issued key"
+// @Failure		400	{object}	responses.ErrorResponse		"Invalid request: empty owner or malformed json"
+// @Failure		401	{object}	responses.ErrorResponse		"Missing or invalid credentials"
+// @Failure		403	{object}	responses.ErrorResponse		"Admin credentials required"
+// @Failure		500	{object}	responses.ErrorResponse		"Internal service error"
+// @Failure		503	{object}	responses.ErrorResponse		"Storage temporarily unavailable, see Retry-After header"
+// @Router			/v1/keys [post]
+func (h *AuthHandler) postAPIKey(r *http.Request) resp.Response {
+	const op = "AuthHandler.postAPIKey"
+	log := h.logger.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+	)
+
+	req, err := types.CreatePostAPIKeyRequest(r)
+	if err != nil {
+		log.Error("error while processing request", pkglog.Err(err))
+		return h.handleResult(err, nil)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.responseTimeout)
+	defer cancel()
+
+	key, raw, err := h.service.IssueKey(ctx, req.OwnerID, req.Name, req.Admin)
+	if err != nil {
+		log.Error("failed to issue api key", pkglog.Err(err))
+		return h.handleResult(err, nil)
+	}
+
+	return resp.Created(&types.PostAPIKeyResponse{
+		APIKeyResponse: types.NewAPIKeyResponse(key),
+		Key:            raw,
+	})
+}
+
+// @Summary		List API keys
+// @Description	Returns API keys of the owner, or all keys if owner is not specified. Requires admin credentials.
+//
+// @Security		BearerAuth
+// @Produce		json
+// @Param			owner_id	query		string						false	"Owner of keys"
+// @Success		200			{object}	types.ListAPIKeysResponse	"Keys"
+// @Failure		401			{object}	responses.ErrorResponse		"Missing or invalid credentials"
+// @Failure		403			{object}	responses.ErrorResponse		"Admin credentials required"
+// @Failure		500			{object}	responses.ErrorResponse		"Internal service error"
+// @Failure		503			{object}	responses.ErrorResponse		"Storage temporarily unavailable, see Retry-After header"
+// @Router			/v1/keys [get]
+func (h *AuthHandler) getAPIKeys(r *http.Request) resp.Response {
+	const op = "AuthHandler.getAPIKeys"
+	log := h.logger.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+	)
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.responseTimeout)
+	defer cancel()
+
+	keys, err := h.service.ListKeys(ctx, r.URL.Query().Get("owner_id"))
+	if err != nil {
+		log.Error("failed to list api keys", pkglog.Err(err))
+		return h.handleResult(err, nil)
+	}
+
+	res := &types.ListAPIKeysResponse{Keys: make([]types.APIKeyResponse, 0, len(keys))}
+	for _, key := range keys {
+		res.Keys = append(res.Keys, types.NewAPIKeyResponse(key))
+	}
+
+	return h.handleResult(nil, res)
+}
+
+// @Summary		Revoke an API key
+// @Description	Revokes API key by its id. Revoked keys can't be used for authentication. Requires admin credentials.
+//
+// @Security		BearerAuth
+// @Produce		json
+// @Param			id	path		string					true	"Key id"
+// @Success		200	{object}	types.APIKeyResponse	"Revoked key"
+// @Failure		400	{object}	responses.ErrorResponse	"Invalid key id"
+// @Failure		401	{object}	responses.ErrorResponse	"Missing or invalid credentials"
+// @Failure		403	{object}	responses.ErrorResponse	"Admin credentials required"
+// @Failure		404	{object}	responses.ErrorResponse	"Key not found"
+// @Failure		500	{object}	responses.ErrorResponse	"Internal service error"
+// @Failure		503	{object}	responses.ErrorResponse	"Storage temporarily unavailable, see Retry-After header"
+// @Router			/v1/keys/{id} [delete]
+func (h *AuthHandler) deleteAPIKey(r *http.Request) resp.Response {
+	const op = "AuthHandler.deleteAPIKey"
+	log := h.logger.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+	)
+
+	req, err := types.CreateDeleteAPIKeyRequest(r)
+	if err != nil {
+		log.Error("error while processing request", pkglog.Err(err))
+		return h.handleResult(err, nil)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.responseTimeout)
+	defer cancel()
+
+	key, err := h.service.RevokeKey(ctx, req.ID)
+	if err != nil {
+		log.Error("failed to revoke api key", pkglog.Err(err))
+		return h.handleResult(err, nil)
+	}
+
+	return h.handleResult(nil, types.NewAPIKeyResponse(key))
+}
+
+func (h *AuthHandler) handleResult(err error, r any) resp.Response {
+	if err == nil {
+		return resp.OK(r)
+	}
+
+	return errorResponse(err)
+}
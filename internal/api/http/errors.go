@@ -0,0 +1,70 @@
+package http
+
+import (
+	"net/http"
+	"ozon_task/domain"
+	pkgerr "ozon_task/pkg/error"
+	resp "ozon_task/pkg/http/responses"
+)
+
+// statusCodes maps codes of errors to HTTP statuses, codes absent here are internal errors.
+var statusCodes = map[domain.ErrorCode]int{
+	domain.CodeInvalidRequest:           http.StatusBadRequest,
+	domain.CodeInvalidOriginal:          http.StatusBadRequest,
+	domain.CodeInvalidShortened:         http.StatusBadRequest,
+	domain.CodeInvalidTags:              http.StatusBadRequest,
+	domain.CodeInvalidCursor:            http.StatusBadRequest,
+	domain.CodeInvalidFilter:            http.StatusBadRequest,
+	domain.CodeInvalidVersion:           http.StatusBadRequest,
+	domain.CodeInvalidExpiration:        http.StatusBadRequest,
+	domain.CodeInvalidLinkUpdate:        http.StatusBadRequest,
+	domain.CodeInvalidAPIKey:            http.StatusBadRequest,
+	domain.CodeInvalidIdempotencyKey:    http.StatusBadRequest,
+	domain.CodeNotFound:                 http.StatusNotFound,
+	domain.CodeExpired:                  http.StatusGone,
+	domain.CodeAliasTaken:               http.StatusConflict,
+	domain.CodeConflict:                 http.StatusConflict,
+	domain.CodeUnauthenticated:          http.StatusUnauthorized,
+	domain.CodePermissionDenied:         http.StatusForbidden,
+	domain.CodeRateLimited:              http.StatusTooManyRequests,
+	domain.CodeQuotaExceeded:            http.StatusTooManyRequests,
+	domain.CodeIdempotencyKeyReused:     http.StatusUnprocessableEntity,
+	domain.CodeIdempotencyKeyInProgress: http.StatusConflict,
+	domain.CodeMethodNotAllowed:         http.StatusMethodNotAllowed,
+}
+
+// errorResponse maps the error to the problem with its code from the domain catalog.
+func errorResponse(err error) *resp.ErrorResponse {
+	return Problem(domain.ErrorCodeOf(err), pkgerr.UnwrapAll(err))
+}
+
+// Problem builds the problem of the error with the code, details of internal and infrastructure errors are hidden.
+func Problem(code domain.ErrorCode, err error) *resp.ErrorResponse {
+	if domain.IsTransient(code) {
+		return resp.ServiceUnavailable(string(code), err, domain.TransientRetryAfter)
+	}
+
+	switch code {
+	case domain.CodeTimeout, domain.CodeCanceled:
+		return resp.RequestTimeout(string(code), err)
+	case domain.CodeInternal:
+		return resp.Internal(string(code), err)
+	}
+
+	status, ok := statusCodes[code]
+	if !ok {
+		return resp.Internal(string(code), err)
+	}
+
+	return resp.Error(status, string(code), err)
+}
+
+// NotFound serves requests to unknown paths.
+func NotFound(*http.Request) resp.Response {
+	return errorResponse(domain.ErrRouteNotFound)
+}
+
+// MethodNotAllowed serves requests with methods unsupported by the path.
+func MethodNotAllowed(*http.Request) resp.Response {
+	return errorResponse(domain.ErrMethodNotAllowed)
+}
@@ -0,0 +1,77 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"ozon_task/domain"
+	"ozon_task/pkg/http/handlers"
+	"ozon_task/pkg/http/responses"
+)
+
+func TestErrorResponse_Codes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		err    error
+		status int
+		code   domain.ErrorCode
+		detail string
+	}{
+		{fmt.Errorf("Service.CreateLink: %w", domain.ErrInvalidOriginal), http.StatusBadRequest,
+			domain.CodeInvalidOriginal, domain.ErrInvalidOriginal.Error()},
+		{domain.ErrShortenedNotFound, http.StatusNotFound, domain.CodeNotFound, domain.ErrShortenedNotFound.Error()},
+		{domain.ErrLinkExpired, http.StatusGone, domain.CodeExpired, domain.ErrLinkExpired.Error()},
+		{domain.ErrQuotaExceeded, http.StatusTooManyRequests, domain.CodeQuotaExceeded, domain.ErrQuotaExceeded.Error()},
+		{domain.ErrIdempotencyKeyReused, http.StatusUnprocessableEntity,
+			domain.CodeIdempotencyKeyReused, domain.ErrIdempotencyKeyReused.Error()},
+		{context.Canceled, http.StatusRequestTimeout, domain.CodeCanceled, responses.TimeoutError},
+		{errors.New("connection refused"), http.StatusInternalServerError, domain.CodeInternal, responses.InternalError},
+	}
+
+	for _, tt := range tests {
+		res := errorResponse(tt.err)
+
+		require.Equal(t, tt.status, res.StatusCode(), tt.err)
+		require.Equal(t, string(tt.code), res.Code, tt.err)
+		require.Equal(t, tt.detail, res.Detail, tt.err)
+	}
+}
+
+func TestErrorResponse_ProblemJSON(t *testing.T) {
+	t.Parallel()
+
+	handler := handlers.NewHandler("/api",
+		handlers.WithRequestID(),
+		handlers.WithErrHandlers(NotFound, MethodNotAllowed),
+		handlers.WithHealthHandler(),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/unknown", nil)
+	req.Header.Set("X-Request-Id", "req-1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Equal(t, responses.ProblemContentType, rec.Header().Get("Content-Type"))
+	require.Equal(t, "req-1", rec.Header().Get("X-Request-Id"))
+
+	var problem responses.ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	require.Equal(t, responses.ErrorResponse{
+		Type:      "about:blank",
+		Title:     http.StatusText(http.StatusNotFound),
+		Status:    http.StatusNotFound,
+		Detail:    domain.ErrRouteNotFound.Error(),
+		Instance:  "/api/v1/unknown",
+		Code:      string(domain.CodeNotFound),
+		RequestID: "req-1",
+	}, problem)
+}
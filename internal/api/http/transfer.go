@@ -0,0 +1,210 @@
+package http
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"ozon_task/internal/api/http/types"
+	"ozon_task/internal/repository"
+	"ozon_task/internal/transfer"
+	"ozon_task/pkg/http/handlers"
+	resp "ozon_task/pkg/http/responses"
+	pkglog "ozon_task/pkg/log"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// importRecordsHeader carries the number of processed records of the import, it's passed as `skip`
+// to resume the failed import.
+const importRecordsHeader = "X-Import-Records"
+
+// maxReportedRecords limits conflicting and invalid records listed in the response of the import.
+const maxReportedRecords = 100
+
+// TransferHandler streams links of the storage for backups and moves between environments.
+type TransferHandler struct {
+	logger *slog.Logger
+	repo   repository.URL
+	// recordTimeout limits storing of an imported record
+	recordTimeout time.Duration
+	// maxOriginalLength limits original urls of imported records
+	maxOriginalLength int
+}
+
+func NewTransferHandler(
+	logger *slog.Logger,
+	repo repository.URL,
+	recordTimeout time.Duration,
+	maxOriginalLength int,
+) *TransferHandler {
+	return &TransferHandler{
+		logger:            logger,
+		repo:              repo,
+		recordTimeout:     recordTimeout,
+		maxOriginalLength: maxOriginalLength,
+	}
+}
+
+const exportPath = "/admin/links/export"
+const importPath = "/admin/links/import"
+
+func (h *TransferHandler) WithTransferHandlers(guard *AuthMiddleware) handlers.RouterOption {
+	return func(r chi.Router) {
+		admin := r.With(guard.RequireAdmin())
+		admin.Get(exportPath, h.exportLinks)
+		admin.Post(importPath, h.importLinks)
+	}
+}
+
+// @Summary		Export links
+// @Description	Streams all links matching the filter with their metadata as JSON lines or CSV, newest first.
+// @Description	Failures after the first bytes are sent truncate the output. Requires admin credentials.
+//
+// @Security		BearerAuth
+// @Produce		application/x-ndjson
+// @Produce		text/csv
+// @Param			format		query		string					false	"Output format"	Enums(jsonl, csv)	default(jsonl)
+// @Param			owner_id	query		string					false	"Owner of links"
+// @Param			host		query		string					false	"Host of original URLs"
+// @Param			tag			query		string					false	"Tag of links"
+// @Success		200			{string}	string					"Links"
+// @Failure		400			{object}	responses.ErrorResponse	"Unknown format"
+// @Failure		401			{object}	responses.ErrorResponse	"Missing or invalid credentials"
+// @Failure		403			{object}	responses.ErrorResponse	"Admin credentials required"
+// @Failure		500			{object}	responses.ErrorResponse	"Internal service error"
+// @Failure		503			{object}	responses.ErrorResponse	"Storage temporarily unavailable, see Retry-After header"
+// @Router			/v1/admin/links/export [get]
+func (h *TransferHandler) exportLinks(w http.ResponseWriter, r *http.Request) {
+	const op = "TransferHandler.exportLinks"
+	log := h.logger.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+	)
+
+	req, err := types.CreateExportLinksRequest(r)
+	if err != nil {
+		log.Error("error while processing request", pkglog.Err(err))
+		handlers.WriteProblem(w, r, errorResponse(err))
+		return
+	}
+
+	// exports of the whole storage outlast timeouts of regular requests
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	out := &countingWriter{w: w}
+	header := w.Header()
+	header.Set("Content-Type", req.Format.ContentType())
+	header.Set("Content-Disposition", `attachment; filename="links.`+string(req.Format)+`"`)
+
+	count, err := transfer.Export(r.Context(), h.repo, req.Filter, transfer.NewWriter(out, req.Format))
+	if err != nil {
+		log.Error("failed to export links", slog.Int("exported", count), pkglog.Err(err))
+		// the status can be changed only until the output is started
+		if out.n == 0 {
+			header.Del("Content-Disposition")
+			handlers.WriteProblem(w, r, errorResponse(err))
+		}
+		return
+	}
+
+	log.Info("links exported", slog.Int("count", count))
+}
+
+// @Summary		Import links
+// @Description	Creates links from JSON lines or CSV of the export keeping their shortened URLs.
+// @Description	Import is idempotent: links already stored with the same original URL are counted as existing.
+// @Description	Records whose shortened or original URL is used by another link are reported as conflicts.
+// @Description	If the import fails, X-Import-Records header holds the number of processed records,
+// @Description	the same body is sent again with `skip` set to it to resume. Requires admin credentials.
+//
+// @Security		BearerAuth
+// @Accept			application/x-ndjson
+// @Accept			text/csv
+// @Produce		json
+// @Param			format	query		string						false	"Input format"	Enums(jsonl, csv)	default(jsonl)
+// @Param			skip	query		int							false	"Number of records processed by the failed import"
+// @Success		200		{object}	types.ImportLinksResponse	"Result of the import"
+// @Header			200		{integer}	X-Import-Records			"Number of processed records"
+// @Failure		400		{object}	responses.ErrorResponse		"Unknown format, invalid skip or malformed CSV header"
+// @Failure		401		{object}	responses.ErrorResponse		"Missing or invalid credentials"
+// @Failure		403		{object}	responses.ErrorResponse		"Admin credentials required"
+// @Failure		408		{object}	responses.ErrorResponse		"Request timeout: client disconnected"
+// @Failure		500		{object}	responses.ErrorResponse		"Internal service error"
+// @Failure		503		{object}	responses.ErrorResponse		"Storage temporarily unavailable, see Retry-After header"
+// @Router			/v1/admin/links/import [post]
+func (h *TransferHandler) importLinks(w http.ResponseWriter, r *http.Request) {
+	const op = "TransferHandler.importLinks"
+	log := h.logger.With(
+		slog.String("op", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+	)
+
+	handlers.Converter(func(r *http.Request) resp.Response {
+		req, err := types.CreateImportLinksRequest(r)
+		if err != nil {
+			log.Error("error while processing request", pkglog.Err(err))
+			return errorResponse(err)
+		}
+
+		reader, err := transfer.NewReader(r.Body, req.Format)
+		if err != nil {
+			log.Error("error while processing request", pkglog.Err(err))
+			return errorResponse(err)
+		}
+
+		// large imports outlast timeouts of regular requests
+		controller := http.NewResponseController(w)
+		_ = controller.SetReadDeadline(time.Time{})
+		_ = controller.SetWriteDeadline(time.Time{})
+
+		var (
+			conflicting []types.ImportConflictResponse
+			invalid     []types.ImportInvalidResponse
+		)
+		progress, err := transfer.Import(r.Context(), h.repo, reader, transfer.ImportOptions{
+			Resume:            transfer.Progress{Records: req.Skip},
+			RecordTimeout:     h.recordTimeout,
+			MaxOriginalLength: h.maxOriginalLength,
+			OnConflict: func(conflict transfer.Conflict) {
+				if len(conflicting) < maxReportedRecords {
+					conflicting = append(conflicting, types.NewImportConflictResponse(conflict))
+				}
+			},
+			OnInvalid: func(number int, err error) {
+				if len(invalid) < maxReportedRecords {
+					problem := errorResponse(err)
+					invalid = append(invalid, types.ImportInvalidResponse{
+						Record: number,
+						Code:   problem.Code,
+						Detail: problem.Detail,
+					})
+				}
+			},
+		})
+		w.Header().Set(importRecordsHeader, strconv.Itoa(progress.Records))
+		if err != nil {
+			log.Error("failed to import links", slog.Int("records", progress.Records), pkglog.Err(err))
+			return errorResponse(err)
+		}
+
+		log.Info("links imported", slog.Any("progress", progress))
+		res := types.NewImportLinksResponse(progress)
+		res.ConflictingLinks, res.InvalidRecords = conflicting, invalid
+		return resp.OK(res)
+	})(w, r)
+}
+
+// countingWriter counts bytes written to the response.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,124 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"ozon_task/domain"
+	"ozon_task/internal/api/http/types"
+	"ozon_task/internal/repository/inmem"
+	"ozon_task/internal/repository/mocks"
+	"ozon_task/pkg/http/responses"
+	pkginmem "ozon_task/pkg/infra/kv/inmem"
+)
+
+var dummyLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+const (
+	httpPath        = "http://localhost:8080/"
+	responseTimeout = 5 * time.Second
+)
+
+func TestExportImportLinks(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	source := inmem.NewURLRepository(pkginmem.NewPartitionedKVStorage(4))
+	for _, link := range []domain.Link{
+		{Original: "https://ozon.ru/a", Shortened: "AAAAAAAAAA", OwnerID: "alice", Tags: []domain.Tag{"promo"}},
+		{Original: "https://ozon.ru/b", Shortened: "BBBBBBBBBB", OwnerID: "bob"},
+	} {
+		_, err := source.CreateOrGetShortenedURL(ctx, link)
+		require.NoError(t, err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, httpPath+"api/v1"+exportPath+"?format=csv&owner_id=alice", nil)
+	rec := httptest.NewRecorder()
+	NewTransferHandler(dummyLogger, source, responseTimeout, 0).exportLinks(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	require.Len(t, lines, 2)
+	require.Equal(t, `AAAAAAAAAA,https://ozon.ru/a,alice,"[""promo""]",`, lines[1])
+
+	target := inmem.NewURLRepository(pkginmem.NewPartitionedKVStorage(4))
+	_, err := target.CreateOrGetShortenedURL(ctx, domain.Link{
+		Original:  "https://ozon.ru/a",
+		Shortened: "CCCCCCCCCC",
+		OwnerID:   "alice",
+	})
+	require.NoError(t, err)
+
+	body := rec.Body.String() + "ZZZZZZZZZZ,https://ozon.ru/z,,,\nbad,https://ozon.ru/c,,,\n"
+	req = httptest.NewRequest(http.MethodPost, httpPath+"api/v1"+importPath+"?format=csv", strings.NewReader(body))
+	rec = httptest.NewRecorder()
+	NewTransferHandler(dummyLogger, target, responseTimeout, 0).importLinks(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "3", rec.Header().Get(importRecordsHeader))
+	var res types.ImportLinksResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	require.Equal(t, types.ImportLinksResponse{
+		Records:   3,
+		Imported:  1,
+		Conflicts: 1,
+		Invalid:   1,
+		ConflictingLinks: []types.ImportConflictResponse{{
+			Record:               1,
+			ShortenedURL:         "AAAAAAAAAA",
+			OriginalURL:          "https://ozon.ru/a",
+			ExistingShortenedURL: "CCCCCCCCCC",
+			ExistingOriginalURL:  "https://ozon.ru/a",
+		}},
+		InvalidRecords: []types.ImportInvalidResponse{{
+			Record: 3,
+			Code:   string(domain.CodeInvalidShortened),
+			Detail: res.InvalidRecords[0].Detail,
+		}},
+	}, res)
+}
+
+func TestImportLinks_StorageUnavailable(t *testing.T) {
+	t.Parallel()
+	repo := new(mocks.URL)
+	repo.On("GetLink", mock.Anything, "BBBBBBBBBB").Return(domain.Link{}, domain.ErrOriginalNotFound)
+	repo.On("CreateOrGetShortenedURL", mock.Anything, mock.Anything).Return(domain.Link{}, domain.ErrStorageUnavailable)
+
+	// the first record is skipped as imported by the failed request
+	body := `{"shortened_url":"AAAAAAAAAA","original_url":"https://ozon.ru/a"}` + "\n" +
+		`{"shortened_url":"BBBBBBBBBB","original_url":"https://ozon.ru/b"}` + "\n"
+	req := httptest.NewRequest(http.MethodPost, httpPath+"api/v1"+importPath+"?skip=1", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	NewTransferHandler(dummyLogger, repo, responseTimeout, 0).importLinks(rec, req)
+
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Equal(t, "1", rec.Header().Get(importRecordsHeader))
+	var problem responses.ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	require.Equal(t, string(domain.CodeStorageUnavailable), problem.Code)
+	repo.AssertExpectations(t)
+}
+
+func TestExportLinks_StorageUnavailable(t *testing.T) {
+	t.Parallel()
+	repo := new(mocks.URL)
+	repo.On("ListLinks", mock.Anything, mock.Anything, mock.Anything).Return(nil, domain.ErrStorageUnavailable)
+
+	req := httptest.NewRequest(http.MethodGet, httpPath+"api/v1"+exportPath, nil)
+	rec := httptest.NewRecorder()
+	NewTransferHandler(dummyLogger, repo, responseTimeout, 0).exportLinks(rec, req)
+
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Equal(t, responses.ProblemContentType, rec.Header().Get("Content-Type"))
+	require.Empty(t, rec.Header().Get("Content-Disposition"))
+}
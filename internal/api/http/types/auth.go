@@ -0,0 +1,76 @@
+package types
+
+import (
+	"fmt"
+	"net/http"
+	"ozon_task/domain"
+	"ozon_task/pkg/http/handlers"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type PostAPIKeyRequest struct {
+	OwnerID domain.OwnerID `json:"owner_id"`
+	Name    string         `json:"name"`
+	Admin   bool           `json:"admin"`
+}
+
+func CreatePostAPIKeyRequest(r *http.Request) (*PostAPIKeyRequest, error) {
+	req := &PostAPIKeyRequest{}
+
+	if err := handlers.DecodeRequest(r, req); err != nil {
+		return nil, fmt.Errorf("CreatePostAPIKeyRequest: error while unpacking json: %w", domain.ErrInvalidAPIKey)
+	}
+
+	if len(req.OwnerID) == 0 {
+		return nil, fmt.Errorf("CreatePostAPIKeyRequest: owner_id is required: %w", domain.ErrInvalidAPIKey)
+	}
+
+	return req, nil
+}
+
+type APIKeyResponse struct {
+	ID        string         `json:"id"`
+	OwnerID   domain.OwnerID `json:"owner_id"`
+	Name      string         `json:"name"`
+	Admin     bool           `json:"admin"`
+	CreatedAt time.Time      `json:"created_at"`
+	RevokedAt *time.Time     `json:"revoked_at,omitempty"`
+}
+
+func NewAPIKeyResponse(key domain.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:        key.ID,
+		OwnerID:   key.OwnerID,
+		Name:      key.Name,
+		Admin:     key.Admin,
+		CreatedAt: key.CreatedAt,
+		RevokedAt: key.RevokedAt,
+	}
+}
+
+type PostAPIKeyResponse struct {
+	APIKeyResponse
+	// Key is the raw api key, it is returned only once.
+	Key string `json:"key"`
+}
+
+type ListAPIKeysResponse struct {
+	Keys []APIKeyResponse `json:"keys"`
+}
+
+type DeleteAPIKeyRequest struct {
+	ID string
+}
+
+func CreateDeleteAPIKeyRequest(r *http.Request) (*DeleteAPIKeyRequest, error) {
+	const queryParamName = "id"
+	id := chi.URLParam(r, queryParamName)
+
+	if len(id) != domain.APIKeyIDSize {
+		return nil, fmt.Errorf("CreateDeleteAPIKeyRequest: invalid key id %q: %w", id, domain.ErrInvalidAPIKey)
+	}
+
+	return &DeleteAPIKeyRequest{ID: id}, nil
+}
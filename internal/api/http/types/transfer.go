@@ -0,0 +1,104 @@
+package types
+
+import (
+	"fmt"
+	"net/http"
+	"ozon_task/domain"
+	"ozon_task/internal/transfer"
+	"strconv"
+)
+
+type ExportLinksRequest struct {
+	Filter domain.LinkFilter
+	Format transfer.Format
+}
+
+func CreateExportLinksRequest(r *http.Request) (*ExportLinksRequest, error) {
+	query := r.URL.Query()
+
+	format, err := transfer.ParseFormat(query.Get("format"))
+	if err != nil {
+		return nil, fmt.Errorf("CreateExportLinksRequest: %w", err)
+	}
+
+	return &ExportLinksRequest{
+		Filter: domain.LinkFilter{
+			OwnerID: query.Get("owner_id"),
+			Host:    query.Get("host"),
+			Tag:     query.Get("tag"),
+		},
+		Format: format,
+	}, nil
+}
+
+type ImportLinksRequest struct {
+	Format transfer.Format
+	// Skip is the number of records imported by the interrupted request, they're skipped on resume.
+	Skip int
+}
+
+func CreateImportLinksRequest(r *http.Request) (*ImportLinksRequest, error) {
+	query := r.URL.Query()
+
+	format, err := transfer.ParseFormat(query.Get("format"))
+	if err != nil {
+		return nil, fmt.Errorf("CreateImportLinksRequest: %w", err)
+	}
+
+	req := &ImportLinksRequest{Format: format}
+	if value := query.Get("skip"); len(value) != 0 {
+		if req.Skip, err = strconv.Atoi(value); err != nil || req.Skip < 0 {
+			return nil, fmt.Errorf("CreateImportLinksRequest: invalid skip: %w", domain.ErrInvalidRequest)
+		}
+	}
+
+	return req, nil
+}
+
+type ImportLinksResponse struct {
+	// Records is the number of processed records including skipped ones.
+	Records  int `json:"records"`
+	Imported int `json:"imported"`
+	// Existing links are already stored with the same original url.
+	Existing  int `json:"existing"`
+	Conflicts int `json:"conflicts"`
+	Invalid   int `json:"invalid"`
+	// ConflictingLinks and InvalidRecords list the first skipped records.
+	ConflictingLinks []ImportConflictResponse `json:"conflicting_links,omitempty"`
+	InvalidRecords   []ImportInvalidResponse  `json:"invalid_records,omitempty"`
+}
+
+func NewImportLinksResponse(progress transfer.Progress) *ImportLinksResponse {
+	return &ImportLinksResponse{
+		Records:   progress.Records,
+		Imported:  progress.Imported,
+		Existing:  progress.Existing,
+		Conflicts: progress.Conflicts,
+		Invalid:   progress.Invalid,
+	}
+}
+
+// ImportConflictResponse is a record whose shortened or original url is already used by another link.
+type ImportConflictResponse struct {
+	Record               int             `json:"record"`
+	ShortenedURL         domain.ShortURL `json:"shortened_url"`
+	OriginalURL          domain.URL      `json:"original_url"`
+	ExistingShortenedURL domain.ShortURL `json:"existing_shortened_url"`
+	ExistingOriginalURL  domain.URL      `json:"existing_original_url"`
+}
+
+func NewImportConflictResponse(conflict transfer.Conflict) ImportConflictResponse {
+	return ImportConflictResponse{
+		Record:               conflict.Number,
+		ShortenedURL:         conflict.Record.Shortened,
+		OriginalURL:          conflict.Record.Original,
+		ExistingShortenedURL: conflict.Existing.Shortened,
+		ExistingOriginalURL:  conflict.Existing.Original,
+	}
+}
+
+type ImportInvalidResponse struct {
+	Record int    `json:"record"`
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+}
@@ -1,55 +1,137 @@
 package grpc
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"log/slog"
 	"net"
+	"ozon_task/domain"
+	"ozon_task/internal/auth"
 	"ozon_task/internal/config"
+	"ozon_task/internal/grpc/grpcerr"
+	"ozon_task/internal/grpc/interceptors"
 	"ozon_task/internal/grpc/url_shortener"
+	"ozon_task/internal/idempotency"
+	"ozon_task/internal/ratelimit"
 	"ozon_task/internal/usecases"
 	pkggrpc "ozon_task/pkg/grpc"
+	"ozon_task/pkg/requestid"
+	urlshortenerv1 "ozon_task/protos/gen/go"
+	urlshortenerv2 "ozon_task/protos/gen/go/shortener/v2"
 
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/recovery"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/protobuf/proto"
 )
 
 type App struct {
 	log        *slog.Logger
 	gRPCServer *grpc.Server
 	port       int
+	// inProcess serves the HTTP gateway of the same process
+	inProcess pkggrpc.InProcessListener
 }
 
 func New(
 	log *slog.Logger,
 	service usecases.URL,
+	authorizer *auth.Authorizer,
+	limiter *ratelimit.Limiter,
+	keeper *idempotency.Keeper,
 	cfg config.GRPCConfig,
+	linksCfg config.LinksConfig,
+	tlsConfig *tls.Config,
 ) *App {
 	loggingOpts := []logging.Option{
 		logging.WithLogOnEvents(
 			logging.PayloadReceived, logging.PayloadSent,
 		),
+		logging.WithFieldsFromContext(requestIDFields),
+	}
+
+	// streams carry too many messages to log each of them
+	streamLoggingOpts := []logging.Option{
+		logging.WithLogOnEvents(
+			logging.StartCall, logging.FinishCall,
+		),
+		logging.WithFieldsFromContext(requestIDFields),
 	}
 
 	recoveryOpts := []recovery.Option{
 		recovery.WithRecoveryHandler(func(p interface{}) error {
 			log.Error("Recovered from panic", slog.Any("panic", p))
 
-			return status.Errorf(codes.Internal, "internal error")
+			return grpcerr.Error(fmt.Errorf("panic: %v", p))
 		}),
 	}
 
-	gRPCServer := grpc.NewServer(grpc.ChainUnaryInterceptor(
-		recovery.UnaryServerInterceptor(recoveryOpts...),
-		logging.UnaryServerInterceptor(pkggrpc.InterceptorLogger(log), loggingOpts...),
-	))
+	authPolicy := map[string]auth.Rule{
+		urlshortenerv1.URLShortener_ShortenURL_FullMethodName:       {Scope: domain.ScopeLinksWrite},
+		urlshortenerv1.URLShortener_ResolveURL_FullMethodName:       {Scope: domain.ScopeLinksRead, Anonymous: true},
+		urlshortenerv1.URLShortener_ListLinks_FullMethodName:        {Scope: domain.ScopeLinksRead},
+		urlshortenerv1.URLShortener_UpdateLink_FullMethodName:       {Scope: domain.ScopeLinksWrite},
+		urlshortenerv1.URLShortener_ListLinkVersions_FullMethodName: {Scope: domain.ScopeLinksRead},
+		urlshortenerv1.URLShortener_RollbackLink_FullMethodName:     {Scope: domain.ScopeLinksWrite},
+		urlshortenerv1.URLShortener_StreamShorten_FullMethodName:    {Scope: domain.ScopeLinksWrite},
+		urlshortenerv1.URLShortener_ExportLinks_FullMethodName:      {Scope: domain.ScopeLinksRead},
+
+		urlshortenerv2.LinkService_CreateLink_FullMethodName: {Scope: domain.ScopeLinksWrite},
+		urlshortenerv2.LinkService_GetLink_FullMethodName:    {Scope: domain.ScopeLinksRead},
+		urlshortenerv2.LinkService_ListLinks_FullMethodName:  {Scope: domain.ScopeLinksRead},
+		urlshortenerv2.LinkService_UpdateLink_FullMethodName: {Scope: domain.ScopeLinksWrite},
+		urlshortenerv2.LinkService_DeleteLink_FullMethodName: {Scope: domain.ScopeLinksWrite},
+	}
+
+	rateLimitPolicy := map[string]ratelimit.Operation{
+		urlshortenerv1.URLShortener_ShortenURL_FullMethodName: ratelimit.OperationShorten,
+		urlshortenerv1.URLShortener_ResolveURL_FullMethodName: ratelimit.OperationResolve,
+		urlshortenerv2.LinkService_CreateLink_FullMethodName:  ratelimit.OperationShorten,
+		// streams are rate limited on opening, daily quota is charged per url within them
+		urlshortenerv1.URLShortener_StreamShorten_FullMethodName: ratelimit.OperationShorten,
+	}
+
+	idempotencyPolicy := map[string]func() proto.Message{
+		urlshortenerv1.URLShortener_ShortenURL_FullMethodName: func() proto.Message {
+			return &urlshortenerv1.ShortenURLResponse{}
+		},
+		urlshortenerv2.LinkService_CreateLink_FullMethodName: func() proto.Message {
+			return &urlshortenerv2.Link{}
+		},
+	}
+
+	serverOpts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(
+			interceptors.RequestIDUnaryServerInterceptor(),
+			recovery.UnaryServerInterceptor(recoveryOpts...),
+			logging.UnaryServerInterceptor(pkggrpc.InterceptorLogger(log), loggingOpts...),
+			interceptors.AuthUnaryServerInterceptor(log, authorizer, authPolicy),
+			interceptors.IdempotencyUnaryServerInterceptor(log, keeper, idempotencyPolicy),
+			interceptors.RateLimitUnaryServerInterceptor(log, limiter, rateLimitPolicy),
+		),
+		// idempotency keys aren't supported for streams, their results can't be replayed as a whole
+		grpc.ChainStreamInterceptor(
+			interceptors.RequestIDStreamServerInterceptor(),
+			recovery.StreamServerInterceptor(recoveryOpts...),
+			logging.StreamServerInterceptor(pkggrpc.InterceptorLogger(log), streamLoggingOpts...),
+			interceptors.AuthStreamServerInterceptor(log, authorizer, authPolicy),
+			interceptors.RateLimitStreamServerInterceptor(log, limiter, rateLimitPolicy),
+		),
+	}
+
+	if tlsConfig != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	gRPCServer := grpc.NewServer(serverOpts...)
 
 	url_shortener.Register(
 		gRPCServer,
 		service,
 		cfg.OperationsTimeout,
+		linksCfg.MaxOriginalLength,
 		log,
 	)
 
@@ -57,9 +139,23 @@ func New(
 		log:        log,
 		gRPCServer: gRPCServer,
 		port:       cfg.Port,
+		inProcess:  pkggrpc.NewInProcessListener(),
 	}
 }
 
+// InProcessDialer returns the option connecting clients to the server within the process.
+// The server trusts X-Forwarded-For of such clients, so it's used only by the HTTP gateway.
+func (a *App) InProcessDialer() grpc.DialOption {
+	return grpc.WithContextDialer(a.inProcess.Dial)
+}
+
+func requestIDFields(ctx context.Context) logging.Fields {
+	if id := requestid.FromContext(ctx); len(id) != 0 {
+		return logging.Fields{"request_id", id}
+	}
+	return nil
+}
+
 func (a *App) MustRun() {
 	if err := a.Run(); err != nil {
 		panic(err)
@@ -81,6 +177,9 @@ func (a *App) Run() error {
 
 	log.Info("grpc server has started", slog.String("addr", l.Addr().String()))
 
+	// the server stops serving both listeners on Stop
+	go func() { _ = a.gRPCServer.Serve(a.inProcess) }()
+
 	if err = a.gRPCServer.Serve(l); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
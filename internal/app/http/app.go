@@ -2,42 +2,77 @@ package http
 
 import (
 	"context"
+	"crypto/tls"
+	"io"
 	"log/slog"
 	"net/http"
 	apihttp "ozon_task/internal/api/http"
+	"ozon_task/internal/auth"
 	"ozon_task/internal/config"
+	"ozon_task/internal/repository"
 	"ozon_task/internal/usecases"
 	"ozon_task/pkg/http/handlers"
+	"ozon_task/pkg/resilience"
+	"ozon_task/protos/gen/openapi"
 )
 
+// MetricsWriter writes metrics of a component in Prometheus text format.
+type MetricsWriter interface {
+	WriteMetrics(w io.Writer)
+}
+
 type App struct {
 	log    *slog.Logger
 	server *http.Server
 }
 
+// New creates the HTTP server. Links are served by the gateway to the gRPC api, which checks access,
+// limits and idempotency keys of calls by its interceptors.
 func New(
 	log *slog.Logger,
 	apiPath string,
-	service usecases.URL,
+	urls repository.URL,
+	authService usecases.Auth,
+	authorizer *auth.Authorizer,
 	cfg config.HTTPConfig,
+	linksCfg config.LinksConfig,
+	tlsConfig *tls.Config,
+	gateway http.Handler,
+	dependencies []*resilience.Policy,
+	metrics ...MetricsWriter,
 ) *App {
-	urlHandler := apihttp.NewURLHandler(
-		log,
-		service,
-		cfg.OperationsTimeout,
-	)
+	authMiddleware := apihttp.NewAuthMiddleware(log, authorizer)
+
+	v1Opts := []handlers.RouterOption{
+		handlers.WithSwagger(),
+		handlers.WithHealthHandler(healthChecks(dependencies)...),
+		handlers.WithMount(gateway, "/shorten", "/resolve/*", "/links", "/links/*"),
+	}
 
-	publicHandler := handlers.NewHandler(
-		apiPath,
+	// keys management is available only with enabled auth, otherwise anyone could issue admin keys
+	if authorizer.APIKeysEnabled() {
+		authHandler := apihttp.NewAuthHandler(log, authService, cfg.OperationsTimeout)
+		v1Opts = append(v1Opts, authHandler.WithAuthHandlers(authMiddleware))
+	}
+	// bulk transfer of links requires admin credentials, so it's served only with enabled auth
+	if authorizer.Enabled() {
+		transferHandler := apihttp.NewTransferHandler(log, urls, cfg.OperationsTimeout, linksCfg.MaxOriginalLength)
+		v1Opts = append(v1Opts, transferHandler.WithTransferHandlers(authMiddleware))
+	}
+
+	routerOpts := []handlers.RouterOption{
 		handlers.WithLogging(log),
 		handlers.WithProfilerHandlers(),
 		handlers.WithRequestID(),
 		handlers.WithRecover(),
-		handlers.WithSwagger(),
-		handlers.WithHealthHandler(),
-		handlers.WithErrHandlers(),
-		urlHandler.WithURLHandlers(),
-	)
+		handlers.WithErrHandlers(apihttp.NotFound, apihttp.MethodNotAllowed),
+		handlers.WithOpenAPI("/openapi.json", openapi.Shortener),
+		handlers.WithMount(metricsHandler(dependencies, metrics), "/metrics"),
+		handlers.WithRoute("/v1", v1Opts...),
+		handlers.WithRoute("/v2", handlers.WithMount(gateway, "/links", "/links/*")),
+	}
+
+	publicHandler := handlers.NewHandler(apiPath, routerOpts...)
 
 	srv := &http.Server{
 		Addr:         cfg.Address,
@@ -45,6 +80,7 @@ func New(
 		ReadTimeout:  cfg.ReadTimeout,
 		WriteTimeout: cfg.WriteTimeout,
 		IdleTimeout:  cfg.IdleTimeout,
+		TLSConfig:    tlsConfig,
 	}
 
 	return &App{
@@ -53,6 +89,14 @@ func New(
 	}
 }
 
+func healthChecks(dependencies []*resilience.Policy) []handlers.HealthCheck {
+	checks := make([]handlers.HealthCheck, 0, len(dependencies))
+	for _, dependency := range dependencies {
+		checks = append(checks, dependency)
+	}
+	return checks
+}
+
 func (a *App) Run() error {
 	const op = "http.App"
 
@@ -61,6 +105,12 @@ func (a *App) Run() error {
 		slog.String("address", a.server.Addr),
 	)
 
+	if a.server.TLSConfig != nil {
+		log.Info("HTTPS server starting")
+		// certificates are provided by TLSConfig
+		return a.server.ListenAndServeTLS("", "")
+	}
+
 	log.Info("HTTP server starting")
 	return a.server.ListenAndServe()
 }
@@ -72,3 +122,14 @@ func (a *App) Stop(ctx context.Context) error {
 	log.Info("HTTP server shutting down", slog.String("addr", a.server.Addr))
 	return a.server.Shutdown(ctx)
 }
+
+// metricsHandler serves state of the dependencies followed by metrics of the components.
+func metricsHandler(dependencies []*resilience.Policy, metrics []MetricsWriter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		resilience.WriteMetrics(w, dependencies...)
+		for _, m := range metrics {
+			m.WriteMetrics(w)
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ozon_task/domain"
+)
+
+// Rule describes access required by an endpoint.
+type Rule struct {
+	Scope domain.Scope
+	// Anonymous allows calls without credentials, unless ResolveRequiresAuth is set.
+	Anonymous bool
+}
+
+// Authenticator resolves bearer token to a principal.
+// Returns domain.ErrUnauthenticated if the token is not recognized.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (domain.Principal, error)
+}
+
+// Authorizer authenticates bearer tokens and checks scopes of principals.
+// It is shared by HTTP middleware and gRPC interceptor.
+type Authorizer struct {
+	cfg            Config
+	authenticators []Authenticator
+}
+
+// NewAuthorizer creates authorizer. Authenticators are tried in order until one of them recognizes the token.
+func NewAuthorizer(cfg Config, authenticators ...Authenticator) *Authorizer {
+	return &Authorizer{
+		cfg:            cfg,
+		authenticators: authenticators,
+	}
+}
+
+func (a *Authorizer) Enabled() bool {
+	return a.cfg.Enabled
+}
+
+// APIKeysEnabled reports whether service issues its own api keys.
+func (a *Authorizer) APIKeysEnabled() bool {
+	return a.cfg.Enabled && a.cfg.APIKeysEnabled()
+}
+
+// Authorize checks credentials from authorization header against the rule.
+// Returns context carrying authenticated principal.
+func (a *Authorizer) Authorize(ctx context.Context, authorization string, rule Rule) (context.Context, error) {
+	if !a.cfg.Enabled {
+		return ctx, nil
+	}
+
+	token, err := ParseBearer(authorization)
+	if err != nil {
+		return ctx, fmt.Errorf("Authorize: %w", err)
+	}
+
+	if len(token) == 0 {
+		if rule.Anonymous && !a.cfg.ResolveRequiresAuth {
+			return ctx, nil
+		}
+		return ctx, fmt.Errorf("Authorize: no credentials provided: %w", domain.ErrUnauthenticated)
+	}
+
+	principal, err := a.authenticate(ctx, token)
+	if err != nil {
+		return ctx, fmt.Errorf("Authorize: %w", err)
+	}
+
+	if !principal.HasScope(rule.Scope) {
+		return ctx, fmt.Errorf("Authorize: principal %q has no scope %q: %w",
+			principal.ID, rule.Scope, domain.ErrPermissionDenied)
+	}
+
+	return WithPrincipal(ctx, principal), nil
+}
+
+func (a *Authorizer) authenticate(ctx context.Context, token string) (domain.Principal, error) {
+	err := fmt.Errorf("no authenticator accepted token: %w", domain.ErrUnauthenticated)
+
+	for _, authenticator := range a.authenticators {
+		var principal domain.Principal
+		principal, err = authenticator.Authenticate(ctx, token)
+		if err == nil {
+			return principal, nil
+		}
+		if !errors.Is(err, domain.ErrUnauthenticated) {
+			return domain.Principal{}, err
+		}
+	}
+
+	return domain.Principal{}, err
+}
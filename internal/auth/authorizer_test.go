@@ -0,0 +1,103 @@
+package auth_test
+
+import (
+	"context"
+	"ozon_task/domain"
+	"ozon_task/internal/auth"
+	"ozon_task/internal/usecases/mocks"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	resolveRule = auth.Rule{Scope: domain.ScopeLinksRead, Anonymous: true}
+	readRule    = auth.Rule{Scope: domain.ScopeLinksRead}
+	writeRule   = auth.Rule{Scope: domain.ScopeLinksWrite}
+	adminRule   = auth.Rule{Scope: domain.ScopeAdmin}
+)
+
+func TestAuthorize_Disabled(t *testing.T) {
+	t.Parallel()
+	mockService := new(mocks.Auth)
+	authorizer := auth.NewAuthorizer(auth.Config{Enabled: false}, mockService)
+
+	_, err := authorizer.Authorize(context.Background(), "", writeRule)
+
+	require.NoError(t, err)
+	mockService.AssertExpectations(t)
+}
+
+func TestAuthorize_Anonymous(t *testing.T) {
+	t.Parallel()
+	mockService := new(mocks.Auth)
+
+	tests := []struct {
+		name        string
+		cfg         auth.Config
+		rule        auth.Rule
+		expectedErr error
+	}{
+		{"Anonymous resolve allowed", auth.Config{Enabled: true}, resolveRule, nil},
+		{"Anonymous resolve denied", auth.Config{Enabled: true, ResolveRequiresAuth: true},
+			resolveRule, domain.ErrUnauthenticated},
+		{"Anonymous listing", auth.Config{Enabled: true}, readRule, domain.ErrUnauthenticated},
+		{"Anonymous shorten", auth.Config{Enabled: true}, writeRule, domain.ErrUnauthenticated},
+	}
+
+	for _, test := range tests {
+		authorizer := auth.NewAuthorizer(test.cfg, mockService)
+		_, err := authorizer.Authorize(context.Background(), "", test.rule)
+		if test.expectedErr == nil {
+			require.NoError(t, err, test.name)
+		} else {
+			require.ErrorIs(t, err, test.expectedErr, test.name)
+		}
+	}
+}
+
+func TestAuthorize_Principal(t *testing.T) {
+	t.Parallel()
+	mockService := new(mocks.Auth)
+	authorizer := auth.NewAuthorizer(auth.Config{Enabled: true}, mockService)
+	principal := domain.Principal{
+		ID:      "abcdefghij",
+		OwnerID: "team",
+		Scopes:  []domain.Scope{domain.ScopeLinksRead, domain.ScopeLinksWrite},
+	}
+
+	mockService.On("Authenticate", mock.Anything, "abcdefghij.secret").Return(principal, nil)
+
+	ctx, err := authorizer.Authorize(context.Background(), "Bearer abcdefghij.secret", writeRule)
+	require.NoError(t, err)
+	require.Equal(t, "team", auth.OwnerFromContext(ctx))
+
+	_, err = authorizer.Authorize(context.Background(), "Bearer abcdefghij.secret", adminRule)
+	require.ErrorIs(t, err, domain.ErrPermissionDenied)
+
+	_, err = authorizer.Authorize(context.Background(), "Basic dXNlcjpwYXNz", writeRule)
+	require.ErrorIs(t, err, domain.ErrUnauthenticated)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestAuthorize_Chain(t *testing.T) {
+	t.Parallel()
+	keys := new(mocks.Auth)
+	tokens := new(mocks.Auth)
+	authorizer := auth.NewAuthorizer(auth.Config{Enabled: true}, keys, tokens)
+	principal := domain.Principal{ID: "user", OwnerID: "user", Scopes: []domain.Scope{domain.ScopeLinksRead}}
+
+	keys.On("Authenticate", mock.Anything, "a.b.c").Return(domain.Principal{}, domain.ErrUnauthenticated)
+	tokens.On("Authenticate", mock.Anything, "a.b.c").Return(principal, nil)
+
+	_, err := authorizer.Authorize(context.Background(), "Bearer a.b.c", readRule)
+	require.NoError(t, err)
+
+	_, err = authorizer.Authorize(context.Background(), "Bearer a.b.c", writeRule)
+	require.ErrorIs(t, err, domain.ErrPermissionDenied)
+
+	keys.AssertExpectations(t)
+	tokens.AssertExpectations(t)
+}
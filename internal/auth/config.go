@@ -0,0 +1,44 @@
+package auth
+
+import (
+	pkgconfig "ozon_task/pkg/config"
+	"time"
+)
+
+// Modes define which credentials are accepted.
+const (
+	ModeAPIKeys = "api_keys"
+	ModeJWT     = "jwt"
+	ModeAny     = "any"
+)
+
+type Config struct {
+	Enabled bool   `yaml:"enabled" env-default:"false"`
+	Mode    string `yaml:"mode" env-default:"api_keys"`
+	// ResolveRequiresAuth disables anonymous resolving of links.
+	ResolveRequiresAuth bool `yaml:"resolve_requires_auth" env-default:"false"`
+	// AdminToken is a static token with admin access, used to issue the first api keys.
+	AdminToken pkgconfig.Secret `yaml:"admin_token" env:"SHORTENER_ADMIN_TOKEN"`
+	JWT        JWTConfig        `yaml:"jwt"`
+}
+
+func (c Config) APIKeysEnabled() bool {
+	return c.Mode == ModeAPIKeys || c.Mode == ModeAny
+}
+
+func (c Config) JWTEnabled() bool {
+	return c.Mode == ModeJWT || c.Mode == ModeAny
+}
+
+// JWTConfig describes validation of tokens issued by external identity provider.
+// Keys are loaded from JWKS file or URL.
+type JWTConfig struct {
+	JWKSFile        string        `yaml:"jwks_file"`
+	JWKSURL         string        `yaml:"jwks_url"`
+	RefreshInterval time.Duration `yaml:"refresh_interval" env-default:"5m"`
+	Issuer          string        `yaml:"issuer"`
+	Audience        string        `yaml:"audience"`
+	Leeway          time.Duration `yaml:"leeway" env-default:"30s"`
+	OwnerClaim      string        `yaml:"owner_claim" env-default:"sub"`
+	ScopeClaim      string        `yaml:"scope_claim" env-default:"scope"`
+}
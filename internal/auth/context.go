@@ -0,0 +1,25 @@
+package auth
+
+import (
+	"context"
+	"ozon_task/domain"
+)
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying authenticated principal.
+func WithPrincipal(ctx context.Context, p domain.Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns principal stored by transport middlewares.
+func PrincipalFromContext(ctx context.Context) (domain.Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(domain.Principal)
+	return p, ok
+}
+
+// OwnerFromContext returns owner of the authenticated principal or empty owner for anonymous calls.
+func OwnerFromContext(ctx context.Context) domain.OwnerID {
+	p, _ := PrincipalFromContext(ctx)
+	return p.OwnerID
+}
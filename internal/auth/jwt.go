@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"ozon_task/domain"
+	"ozon_task/pkg/jwt"
+)
+
+// JWTAuthenticator authenticates tokens issued by external OIDC provider.
+type JWTAuthenticator struct {
+	verifier   *jwt.Verifier
+	ownerClaim string
+	scopeClaim string
+}
+
+func NewJWTAuthenticator(keys jwt.KeyProvider, cfg JWTConfig) *JWTAuthenticator {
+	return &JWTAuthenticator{
+		verifier:   jwt.NewVerifier(keys, cfg.Issuer, cfg.Audience, cfg.Leeway),
+		ownerClaim: cfg.OwnerClaim,
+		scopeClaim: cfg.ScopeClaim,
+	}
+}
+
+// Authenticate verifies token and maps its claims to principal.
+// Scopes unknown to the service are ignored.
+func (a *JWTAuthenticator) Authenticate(_ context.Context, token string) (domain.Principal, error) {
+	if !jwt.LooksLikeJWT(token) {
+		return domain.Principal{}, fmt.Errorf("Authenticate: not a jwt: %w", domain.ErrUnauthenticated)
+	}
+
+	claims, err := a.verifier.Verify(token)
+	if err != nil {
+		return domain.Principal{}, fmt.Errorf("Authenticate: %w: %w", domain.ErrUnauthenticated, err)
+	}
+
+	if len(claims.Subject) == 0 {
+		return domain.Principal{}, fmt.Errorf("Authenticate: sub claim is required: %w", domain.ErrUnauthenticated)
+	}
+
+	owner := claims.String(a.ownerClaim)
+	if len(owner) == 0 {
+		return domain.Principal{}, fmt.Errorf("Authenticate: %s claim is required: %w",
+			a.ownerClaim, domain.ErrUnauthenticated)
+	}
+
+	principal := domain.Principal{ID: claims.Subject, OwnerID: owner}
+	for _, scope := range claims.Strings(a.scopeClaim) {
+		switch scope {
+		case domain.ScopeLinksRead, domain.ScopeLinksWrite, domain.ScopeAdmin:
+			principal.Scopes = append(principal.Scopes, scope)
+		}
+	}
+
+	return principal, nil
+}
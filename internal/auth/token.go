@@ -0,0 +1,23 @@
+package auth
+
+import (
+	"fmt"
+	"ozon_task/domain"
+	"strings"
+)
+
+const bearerPrefix = "Bearer "
+
+// ParseBearer extracts token from the value of Authorization header or metadata.
+// Returns empty token if header is not set.
+func ParseBearer(header string) (string, error) {
+	if len(header) == 0 {
+		return "", nil
+	}
+
+	if len(header) <= len(bearerPrefix) || !strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+		return "", fmt.Errorf("ParseBearer: unsupported authorization scheme: %w", domain.ErrUnauthenticated)
+	}
+
+	return strings.TrimSpace(header[len(bearerPrefix):]), nil
+}
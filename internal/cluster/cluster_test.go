@@ -0,0 +1,538 @@
+package cluster_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"ozon_task/domain"
+	"ozon_task/internal/cluster"
+	pkgtls "ozon_task/pkg/tls"
+	clusterv1 "ozon_task/protos/gen/go/cluster/v1"
+)
+
+const (
+	clusterSize  = 3
+	waitTimeout  = 10 * time.Second
+	pollInterval = 20 * time.Millisecond
+)
+
+var dummyLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// testPKI is the CA of the cluster issuing certificates of nodes and clients.
+type testPKI struct {
+	dir    string
+	cert   *x509.Certificate
+	key    *ecdsa.PrivateKey
+	caFile string
+	serial atomic.Int64
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "cluster-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	p := &testPKI{dir: t.TempDir(), cert: cert, key: key}
+	p.serial.Store(1)
+	p.caFile = p.write(t, "ca.crt", "CERTIFICATE", der)
+	return p
+}
+
+// issue writes the certificate of the name for both server and client auth on the local host.
+func (p *testPKI) issue(t *testing.T, name string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(p.serial.Add(1)),
+		Subject:      pkix.Name{CommonName: name},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, p.cert, &key.PublicKey, p.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return p.write(t, name+".crt", "CERTIFICATE", der), p.write(t, name+".key", "EC PRIVATE KEY", keyDER)
+}
+
+func (p *testPKI) write(t *testing.T, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(p.dir, name)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+	return path
+}
+
+// nodeTLS is mutual TLS of the node with the certificate of its id.
+func (p *testPKI) nodeTLS(t *testing.T, id string) pkgtls.Config {
+	t.Helper()
+	certFile, keyFile := p.issue(t, id)
+	return pkgtls.Config{
+		Enabled:    true,
+		CertFile:   certFile,
+		KeyFile:    keyFile,
+		CAFile:     p.caFile,
+		ClientAuth: pkgtls.ClientAuthRequireAndVerify,
+	}
+}
+
+// client connects to ClusterService with the certificate of the name.
+func (p *testPKI) client(t *testing.T, name, addr string) clusterv1.ClusterServiceClient {
+	t.Helper()
+	certFile, keyFile := p.issue(t, name)
+	tlsCfg, err := pkgtls.NewClientConfig(pkgtls.ClientConfig{CAFile: p.caFile, CertFile: certFile, KeyFile: keyFile})
+	require.NoError(t, err)
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(credentials.NewTLS(tlsCfg)))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return clusterv1.NewClusterServiceClient(conn)
+}
+
+type testNode struct {
+	cfg  cluster.Config
+	pki  *testPKI
+	node *cluster.Node
+	urls *cluster.URLRepository
+	keys *cluster.APIKeyRepository
+}
+
+func (n *testNode) start(t *testing.T) {
+	t.Helper()
+	node, err := cluster.NewNode(n.cfg, dummyLogger)
+	require.NoError(t, err)
+	n.node = node
+	n.urls = cluster.NewURLRepository(node)
+	n.keys = cluster.NewAPIKeyRepository(node)
+}
+
+func (n *testNode) stop() {
+	if n.node == nil {
+		return
+	}
+	n.urls.Close()
+	n.node.Close()
+	n.node = nil
+}
+
+// startCluster runs nodes on local ports, the first one bootstraps the cluster and others join it.
+func startCluster(t *testing.T, configure func(cfg *cluster.Config)) []*testNode {
+	t.Helper()
+
+	pki := newTestPKI(t)
+	nodes := make([]*testNode, clusterSize)
+	for i := range nodes {
+		id := "node" + string(rune('1'+i))
+		cfg := cluster.Config{
+			NodeID:             id,
+			RaftAddress:        freeAddr(t),
+			GRPCAddress:        freeAddr(t),
+			TLS:                pki.nodeTLS(t, id),
+			AdminNames:         []string{"admin"},
+			Bootstrap:          i == 0,
+			ApplyTimeout:       5 * time.Second,
+			ClickFlushInterval: 50 * time.Millisecond,
+			HeartbeatTimeout:   100 * time.Millisecond,
+			ElectionTimeout:    100 * time.Millisecond,
+			SnapshotInterval:   time.Minute,
+			SnapshotThreshold:  8192,
+			SnapshotRetain:     2,
+		}
+		if i != 0 {
+			cfg.Join = []string{nodes[0].cfg.GRPCAddress}
+		}
+		if configure != nil {
+			configure(&cfg)
+		}
+		nodes[i] = &testNode{cfg: cfg, pki: pki}
+		nodes[i].start(t)
+	}
+	t.Cleanup(func() {
+		for _, n := range nodes {
+			n.stop()
+		}
+	})
+
+	waitForMembers(t, pki, nodes[0].cfg.GRPCAddress, clusterSize)
+	for _, n := range nodes {
+		waitForLeader(t, n)
+	}
+	return nodes
+}
+
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+	require.NoError(t, lis.Close())
+	return addr
+}
+
+func waitForMembers(t *testing.T, pki *testPKI, addr string, count int) []*clusterv1.Member {
+	t.Helper()
+	client := pki.client(t, "admin", addr)
+
+	var members []*clusterv1.Member
+	require.Eventually(t, func() bool {
+		resp, err := client.Members(context.Background(), &clusterv1.MembersRequest{})
+		if err != nil || len(resp.GetMembers()) != count {
+			return false
+		}
+		for _, m := range resp.GetMembers() {
+			if len(m.GetGrpcAddress()) == 0 {
+				return false
+			}
+		}
+		members = resp.GetMembers()
+		return true
+	}, waitTimeout, pollInterval)
+	return members
+}
+
+func waitForLeader(t *testing.T, n *testNode) string {
+	t.Helper()
+	require.Eventually(t, func() bool { return len(n.node.Leader()) != 0 }, waitTimeout, pollInterval)
+	return n.node.Leader()
+}
+
+func leaderIndex(t *testing.T, nodes []*testNode) int {
+	t.Helper()
+	leader := waitForLeader(t, nodes[0])
+	for i, n := range nodes {
+		if n.cfg.NodeID == leader {
+			return i
+		}
+	}
+	t.Fatalf("leader %s isn't a node of the cluster", leader)
+	return -1
+}
+
+// followers returns running nodes except the leader.
+func followers(nodes []*testNode, leader int) []*testNode {
+	res := make([]*testNode, 0, len(nodes))
+	for i, n := range nodes {
+		if i != leader && n.node != nil {
+			res = append(res, n)
+		}
+	}
+	return res
+}
+
+func TestCluster_ReplicatesWrites(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	nodes := startCluster(t, func(cfg *cluster.Config) { cfg.LinearizableReads = true })
+	followers := followers(nodes, leaderIndex(t, nodes))
+
+	created, err := followers[0].urls.CreateOrGetShortenedURL(ctx, domain.Link{
+		Original:  "https://ozon.ru",
+		Shortened: "ozon",
+		OwnerID:   "alice",
+	})
+	require.NoError(t, err)
+	require.Equal(t, domain.ShortURL("ozon"), created.Shortened)
+
+	for _, n := range nodes {
+		original, err := n.urls.GetOriginalURLByShortened(ctx, "ozon")
+		require.NoError(t, err)
+		require.Equal(t, domain.URL("https://ozon.ru"), original)
+	}
+
+	existing, err := followers[1].urls.CreateOrGetShortenedURL(ctx, domain.Link{
+		Original:  "https://ozon.ru",
+		Shortened: "other",
+		OwnerID:   "alice",
+	})
+	require.NoError(t, err)
+	require.Equal(t, created.ID, existing.ID)
+	require.Equal(t, domain.ShortURL("ozon"), existing.Shortened)
+
+	edited := domain.URL("https://fintech.ozon.ru")
+	updated, err := followers[1].urls.UpdateLink(ctx, "ozon", domain.LinkUpdate{Original: &edited}, "bob")
+	require.NoError(t, err)
+	require.Equal(t, 2, updated.Version)
+	for _, n := range nodes {
+		link, err := n.urls.GetLink(ctx, "ozon")
+		require.NoError(t, err)
+		require.Equal(t, edited, link.Original)
+		require.Equal(t, created.CreatedAt, link.CreatedAt)
+	}
+
+	_, err = followers[0].urls.UpdateLink(ctx, "missing", domain.LinkUpdate{Original: &edited}, "bob")
+	require.ErrorIs(t, err, domain.ErrOriginalNotFound)
+	_, err = followers[0].urls.CreateOrGetShortenedURL(ctx, domain.Link{Original: "https://ozon.ru/taken", Shortened: "ozon"})
+	require.ErrorIs(t, err, domain.ErrConflict)
+
+	require.NoError(t, followers[0].urls.DeleteLink(ctx, "ozon"))
+	for _, n := range nodes {
+		_, err = n.urls.GetLink(ctx, "ozon")
+		require.ErrorIs(t, err, domain.ErrOriginalNotFound)
+	}
+}
+
+func TestCluster_ReplicatesAPIKeys(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	nodes := startCluster(t, func(cfg *cluster.Config) { cfg.LinearizableReads = true })
+	followers := followers(nodes, leaderIndex(t, nodes))
+
+	key := domain.APIKey{
+		ID:        "key1",
+		OwnerID:   "alice",
+		Name:      "ci",
+		Hash:      []byte("hash"),
+		CreatedAt: time.Now().UTC(),
+	}
+	require.NoError(t, followers[0].keys.CreateAPIKey(ctx, key))
+	for _, n := range nodes {
+		stored, err := n.keys.GetAPIKeyByHash(ctx, key.Hash)
+		require.NoError(t, err)
+		require.Equal(t, key.ID, stored.ID)
+	}
+
+	revoked, err := followers[1].keys.RevokeAPIKey(ctx, key.ID)
+	require.NoError(t, err)
+	require.True(t, revoked.IsRevoked())
+	require.Equal(t, key.Hash, revoked.Hash)
+	for _, n := range nodes {
+		keys, err := n.keys.ListAPIKeys(ctx, "alice")
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		require.True(t, keys[0].IsRevoked())
+	}
+
+	_, err = followers[0].keys.RevokeAPIKey(ctx, "missing")
+	require.ErrorIs(t, err, domain.ErrAPIKeyNotFound)
+}
+
+func TestCluster_FlushesClicks(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	nodes := startCluster(t, nil)
+
+	_, err := nodes[0].urls.CreateOrGetShortenedURL(ctx, domain.Link{Original: "https://ozon.ru", Shortened: "ozon"})
+	require.NoError(t, err)
+	for _, n := range nodes {
+		require.NoError(t, n.urls.RecordClick(ctx, "ozon"))
+	}
+
+	for _, n := range nodes {
+		require.Eventually(t, func() bool {
+			link, err := n.urls.GetLink(ctx, "ozon")
+			return err == nil && link.Clicks == clusterSize
+		}, waitTimeout, pollInterval)
+	}
+}
+
+func TestCluster_Failover(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	nodes := startCluster(t, func(cfg *cluster.Config) { cfg.LinearizableReads = true })
+	leader := leaderIndex(t, nodes)
+
+	_, err := nodes[leader].urls.CreateOrGetShortenedURL(ctx, domain.Link{Original: "https://ozon.ru", Shortened: "ozon"})
+	require.NoError(t, err)
+
+	oldLeader := nodes[leader].cfg.NodeID
+	nodes[leader].stop()
+	survivors := followers(nodes, leader)
+	require.Eventually(t, func() bool {
+		id := survivors[0].node.Leader()
+		return len(id) != 0 && id != oldLeader
+	}, waitTimeout, pollInterval)
+
+	// the new leader may register its address a bit later, writes are retried until then
+	var link domain.Link
+	require.Eventually(t, func() bool {
+		link, err = survivors[0].urls.CreateOrGetShortenedURL(ctx, domain.Link{
+			Original:  "https://fintech.ozon.ru",
+			Shortened: "fintech",
+		})
+		return err == nil
+	}, waitTimeout, pollInterval)
+	require.Equal(t, domain.ShortURL("fintech"), link.Shortened)
+
+	for _, n := range survivors {
+		original, err := n.urls.GetOriginalURLByShortened(ctx, "ozon")
+		require.NoError(t, err)
+		require.Equal(t, domain.URL("https://ozon.ru"), original)
+		original, err = n.urls.GetOriginalURLByShortened(ctx, "fintech")
+		require.NoError(t, err)
+		require.Equal(t, domain.URL("https://fintech.ozon.ru"), original)
+	}
+}
+
+func TestCluster_Membership(t *testing.T) {
+	t.Parallel()
+	nodes := startCluster(t, nil)
+	leader := nodes[leaderIndex(t, nodes)]
+	followers := followers(nodes, leaderIndex(t, nodes))
+	pki := leader.pki
+
+	members := waitForMembers(t, pki, followers[0].cfg.GRPCAddress, clusterSize)
+	leaders := 0
+	for _, m := range members {
+		require.True(t, m.GetVoter())
+		if m.GetLeader() {
+			leaders++
+		}
+	}
+	require.Equal(t, 1, leaders)
+
+	// followers redirect changes to the leader, which authorizes the caller itself
+	removed := followers[1]
+	var trailer metadata.MD
+	_, err := pki.client(t, "admin", followers[0].cfg.GRPCAddress).Remove(context.Background(),
+		&clusterv1.RemoveRequest{Id: removed.cfg.NodeID}, grpc.Trailer(&trailer))
+	require.Equal(t, leader.cfg.GRPCAddress, cluster.LeaderAddress(err, trailer))
+
+	_, err = pki.client(t, "node9", leader.cfg.GRPCAddress).Remove(context.Background(),
+		&clusterv1.RemoveRequest{Id: removed.cfg.NodeID})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	admin := pki.client(t, "admin", leader.cfg.GRPCAddress)
+	_, err = admin.Remove(context.Background(), &clusterv1.RemoveRequest{Id: removed.cfg.NodeID})
+	require.NoError(t, err)
+	members = waitForMembers(t, pki, followers[0].cfg.GRPCAddress, clusterSize-1)
+	for _, m := range members {
+		require.NotEqual(t, removed.cfg.NodeID, m.GetId())
+	}
+
+	// nodes may add only themselves
+	rejoin := &clusterv1.JoinRequest{Member: &clusterv1.Member{
+		Id:          removed.cfg.NodeID,
+		RaftAddress: removed.cfg.RaftAddress,
+		GrpcAddress: removed.cfg.GRPCAddress,
+	}}
+	_, err = pki.client(t, followers[0].cfg.NodeID, leader.cfg.GRPCAddress).Join(context.Background(), rejoin)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = pki.client(t, removed.cfg.NodeID, leader.cfg.GRPCAddress).Join(context.Background(), rejoin)
+	require.NoError(t, err)
+	waitForMembers(t, pki, followers[0].cfg.GRPCAddress, clusterSize)
+
+	_, err = admin.Join(context.Background(), &clusterv1.JoinRequest{Member: &clusterv1.Member{Id: "node4"}})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestCluster_RequiresClusterCertificates(t *testing.T) {
+	t.Parallel()
+	nodes := startCluster(t, nil)
+	leader := nodes[leaderIndex(t, nodes)]
+
+	// certificates of another CA aren't accepted by ClusterService
+	stranger := newTestPKI(t)
+	_, err := stranger.client(t, "admin", leader.cfg.GRPCAddress).Apply(context.Background(),
+		&clusterv1.ApplyRequest{Command: []byte(`{"op":"delete_link","shortened":"ozon"}`)})
+	require.Equal(t, codes.Unavailable, status.Code(err))
+
+	// nor by the raft transport, so the node of another CA can't join the cluster
+	cfg := leader.cfg
+	cfg.NodeID = "node4"
+	cfg.RaftAddress = freeAddr(t)
+	cfg.GRPCAddress = freeAddr(t)
+	cfg.TLS = stranger.nodeTLS(t, cfg.NodeID)
+	cfg.Bootstrap = false
+	cfg.Join = []string{leader.cfg.GRPCAddress}
+	node, err := cluster.NewNode(cfg, dummyLogger)
+	require.NoError(t, err)
+	t.Cleanup(node.Close)
+	time.Sleep(200 * time.Millisecond)
+	waitForMembers(t, leader.pki, leader.cfg.GRPCAddress, clusterSize)
+
+	cfg.TLS.ClientAuth = pkgtls.ClientAuthNone
+	_, err = cluster.NewNode(cfg, dummyLogger)
+	require.Error(t, err)
+}
+
+func TestCluster_RestoresSnapshotOnRestart(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	dir := t.TempDir()
+	nodes := startCluster(t, func(cfg *cluster.Config) {
+		cfg.DataDir = filepath.Join(dir, cfg.NodeID)
+		cfg.SnapshotInterval = 50 * time.Millisecond
+		cfg.SnapshotThreshold = 1
+	})
+	followers := followers(nodes, leaderIndex(t, nodes))
+
+	_, err := followers[0].urls.CreateOrGetShortenedURL(ctx, domain.Link{Original: "https://ozon.ru", Shortened: "ozon"})
+	require.NoError(t, err)
+
+	restarted := followers[1]
+	require.Eventually(t, func() bool {
+		_, err := restarted.urls.GetLink(ctx, "ozon")
+		return err == nil
+	}, waitTimeout, pollInterval)
+	require.Eventually(t, func() bool {
+		return snapshotContains(t, restarted.cfg.DataDir, "https://ozon.ru")
+	}, waitTimeout, pollInterval)
+
+	// the node restarts alone, so the link can be restored only from its data dir
+	for _, n := range nodes {
+		n.stop()
+	}
+	restarted.cfg.Join = nil
+	restarted.start(t)
+	original, err := restarted.urls.GetOriginalURLByShortened(ctx, "ozon")
+	require.NoError(t, err)
+	require.Equal(t, domain.URL("https://ozon.ru"), original)
+}
+
+// snapshotContains checks if the latest snapshot in the data dir has the value.
+func snapshotContains(t *testing.T, dir, value string) bool {
+	t.Helper()
+	store, err := raft.NewFileSnapshotStore(dir, 1, io.Discard)
+	require.NoError(t, err)
+	snapshots, err := store.List()
+	if err != nil || len(snapshots) == 0 {
+		return false
+	}
+	_, rc, err := store.Open(snapshots[0].ID)
+	if err != nil {
+		return false
+	}
+	defer func() { _ = rc.Close() }()
+	data, err := io.ReadAll(rc)
+	return err == nil && bytes.Contains(data, []byte(value))
+}
@@ -0,0 +1,141 @@
+package cluster
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ozon_task/domain"
+	"time"
+)
+
+// Operations of commands of the log.
+const (
+	opCreateLink   = "create_link"
+	opUpdateLink   = "update_link"
+	opDeleteLink   = "delete_link"
+	opAddClicks    = "add_clicks"
+	opCreateAPIKey = "create_api_key"
+	opRevokeAPIKey = "revoke_api_key"
+	opSetMember    = "set_member"
+	opRemoveMember = "remove_member"
+)
+
+// command is an entry of the raft log. Commands are applied to storages of all nodes in the same order,
+// so they must not depend on the node applying them.
+type command struct {
+	Op string `json:"op"`
+	// Time is taken on the node proposing the command, it stamps links and keys on all nodes.
+	Time time.Time `json:"time"`
+
+	Link      *domain.Link              `json:"link,omitempty"`
+	Shortened domain.ShortURL           `json:"shortened,omitempty"`
+	Update    *domain.LinkUpdate        `json:"update,omitempty"`
+	Author    string                    `json:"author,omitempty"`
+	Clicks    map[domain.ShortURL]int64 `json:"clicks,omitempty"`
+	Key       *apiKey                   `json:"key,omitempty"`
+	KeyID     string                    `json:"key_id,omitempty"`
+	// Member is the node whose ClusterService address is set or removed.
+	Member *member `json:"member,omitempty"`
+}
+
+// apiKey keeps the hash of the key, which isn't encoded with the key itself.
+type apiKey struct {
+	domain.APIKey
+	Hash []byte `json:"hash"`
+}
+
+func newAPIKey(key domain.APIKey) *apiKey {
+	return &apiKey{APIKey: key, Hash: key.Hash}
+}
+
+func (k apiKey) key() domain.APIKey {
+	key := k.APIKey
+	key.Hash = k.Hash
+	return key
+}
+
+type member struct {
+	ID          string `json:"id"`
+	GRPCAddress string `json:"grpc_address"`
+}
+
+// result of the applied command, errors of storages are encoded by their messages and the domain
+// errors they wrap.
+type result struct {
+	Link  domain.Link `json:"link"`
+	Key   *apiKey     `json:"key,omitempty"`
+	Error string      `json:"error,omitempty"`
+	// Kind is the message of the domain error wrapped by Error, it's decoded back to the error.
+	Kind string `json:"kind,omitempty"`
+}
+
+// resultErrors are domain errors returned by commands, they're decoded back on the proposing node,
+// so errors of forwarded writes are handled the same way as errors of local ones.
+var resultErrors = []error{
+	domain.ErrInvalidRequest,
+	domain.ErrInvalidOriginal,
+	domain.ErrInvalidShortened,
+	domain.ErrOriginalNotFound,
+	domain.ErrShortenedNotFound,
+	domain.ErrLinkExpired,
+	domain.ErrPermissionDenied,
+	domain.ErrAPIKeyNotFound,
+	domain.ErrInvalidAPIKey,
+	domain.ErrInvalidTags,
+	domain.ErrInvalidCursor,
+	domain.ErrInvalidFilter,
+	domain.ErrInvalidVersion,
+	domain.ErrVersionNotFound,
+	domain.ErrInvalidExpiration,
+	domain.ErrInvalidLinkUpdate,
+	domain.ErrConflict,
+	domain.ErrStorageUnavailable,
+	domain.ErrConcurrentChange,
+	domain.ErrTimeout,
+}
+
+// resultError is the error of the command applied by another node.
+type resultError struct {
+	msg  string
+	kind error
+}
+
+func (e *resultError) Error() string { return e.msg }
+
+func (e *resultError) Unwrap() error { return e.kind }
+
+func newResult(link domain.Link, key *apiKey, err error) result {
+	res := result{Link: link, Key: key}
+	if err == nil {
+		return res
+	}
+
+	res.Error = err.Error()
+	for _, known := range resultErrors {
+		if errors.Is(err, known) {
+			res.Kind = known.Error()
+			break
+		}
+	}
+	return res
+}
+
+func (r result) err() error {
+	if len(r.Error) == 0 {
+		return nil
+	}
+	for _, known := range resultErrors {
+		if r.Kind == known.Error() || r.Error == known.Error() {
+			return &resultError{msg: r.Error, kind: known}
+		}
+	}
+	return errors.New(r.Error)
+}
+
+func encodeCommand(cmd command) ([]byte, error) {
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("encodeCommand: %w", err)
+	}
+	return data, nil
+}
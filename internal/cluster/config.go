@@ -0,0 +1,71 @@
+// Package cluster replicates the in-memory storage between nodes of the service through a raft log.
+package cluster
+
+import (
+	pkgtls "ozon_task/pkg/tls"
+	"time"
+)
+
+// Config of the node of the cluster. Every change of links and api keys is a command of the raft log,
+// which is applied to the in-memory storage of every node. Followers forward writes to the leader and
+// serve reads from their own storage.
+type Config struct {
+	// NodeID identifies the node in the cluster, it must be kept across restarts of the node.
+	NodeID string `yaml:"node_id" env:"SHORTENER_CLUSTER_NODE_ID"`
+	// RaftAddress is the address the raft transport listens on.
+	RaftAddress string `yaml:"raft_address"`
+	// RaftAdvertise is the address of the raft transport for other nodes, RaftAddress by default.
+	RaftAdvertise string `yaml:"raft_advertise"`
+	// GRPCAddress is the address ClusterService listens on, writes of followers are forwarded to it.
+	GRPCAddress string `yaml:"grpc_address"`
+	// GRPCAdvertise is the address of ClusterService for other nodes, GRPCAddress by default.
+	GRPCAdvertise string `yaml:"grpc_advertise"`
+	// TLS secures ClusterService and the raft transport by mutual TLS, it's required. The CA must be dedicated
+	// to the cluster, as every certificate issued by it is trusted as a node. Nodes present their certificate
+	// to each other, so it's issued for both server and client auth with the node id as the common name.
+	TLS pkgtls.Config `yaml:"tls"`
+	// AdminNames are common names of certificates allowed to remove nodes and to add nodes other than
+	// themselves, e.g. the one of the cluster command.
+	AdminNames []string `yaml:"admin_names"`
+	// DataDir keeps the raft log and snapshots, they're kept in memory if it's empty,
+	// so the node restarted without it catches up from other nodes.
+	DataDir string `yaml:"data_dir"`
+	// Bootstrap starts a new cluster of this node, it's set on the first node only.
+	Bootstrap bool `yaml:"bootstrap" env-default:"false"`
+	// Join are addresses of ClusterService of nodes asked to add this node to the cluster on start.
+	Join []string `yaml:"join"`
+	// LinearizableReads confirm with the leader that reads of the node see all completed writes,
+	// otherwise followers may serve reads lagging behind the leader.
+	LinearizableReads bool `yaml:"linearizable_reads" env-default:"false"`
+	// ApplyTimeout limits waiting for a write to be committed.
+	ApplyTimeout time.Duration `yaml:"apply_timeout" env-default:"5s"`
+	// ClickFlushInterval is the period clicks counted by the node are written to the log.
+	ClickFlushInterval time.Duration `yaml:"click_flush_interval" env-default:"1s"`
+	// HeartbeatTimeout and ElectionTimeout define how fast a failed leader is replaced.
+	HeartbeatTimeout time.Duration `yaml:"heartbeat_timeout" env-default:"1s"`
+	ElectionTimeout  time.Duration `yaml:"election_timeout" env-default:"1s"`
+	// The log is compacted by a snapshot every SnapshotInterval if it has SnapshotThreshold new entries.
+	SnapshotInterval  time.Duration `yaml:"snapshot_interval" env-default:"2m"`
+	SnapshotThreshold uint64        `yaml:"snapshot_threshold" env-default:"8192"`
+	// SnapshotRetain is the number of snapshots kept in DataDir.
+	SnapshotRetain int `yaml:"snapshot_retain" env-default:"2"`
+}
+
+func (c Config) raftAdvertise() string {
+	if len(c.RaftAdvertise) != 0 {
+		return c.RaftAdvertise
+	}
+	return c.RaftAddress
+}
+
+func (c Config) grpcAdvertise() string {
+	if len(c.GRPCAdvertise) != 0 {
+		return c.GRPCAdvertise
+	}
+	return c.GRPCAddress
+}
+
+// MutualTLS reports whether the config verifies certificates of both sides of connections.
+func (c Config) MutualTLS() bool {
+	return c.TLS.Enabled && c.TLS.ClientAuth == pkgtls.ClientAuthRequireAndVerify
+}
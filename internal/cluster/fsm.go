@@ -0,0 +1,211 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"ozon_task/domain"
+	"ozon_task/internal/repository/inmem"
+	pkginmem "ozon_task/pkg/infra/kv/inmem"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/raft"
+)
+
+// fsm applies commands of the log to the in-memory storages of the node.
+// Raft calls Apply, Snapshot and Restore sequentially, reads of the storages go concurrently with them.
+type fsm struct {
+	urls *inmem.URLRepository
+	keys *inmem.APIKeyRepository
+	// now is the time of the command being applied, it's the clock of the storages
+	now time.Time
+
+	mu sync.Mutex
+	// members are addresses of ClusterService of nodes by their ids
+	members map[string]string
+	// applied is the index of the last applied command
+	applied uint64
+	// notify is closed and replaced when a command is applied
+	notify chan struct{}
+}
+
+func newFSM() *fsm {
+	const threadsFactor = 2
+	f := &fsm{
+		members: make(map[string]string),
+		notify:  make(chan struct{}),
+	}
+	clock := func() time.Time { return f.now }
+	f.urls = inmem.NewURLRepositoryWithClock(pkginmem.NewPartitionedKVStorage(runtime.GOMAXPROCS(0)*threadsFactor), clock)
+	f.keys = inmem.NewAPIKeyRepositoryWithClock(clock)
+	return f
+}
+
+func (f *fsm) Apply(entry *raft.Log) any {
+	res := f.apply(entry.Data)
+	f.setApplied(entry.Index)
+	return res
+}
+
+func (f *fsm) apply(data []byte) result {
+	var cmd command
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return newResult(domain.Link{}, nil, fmt.Errorf("failed to decode command: %w", err))
+	}
+	f.now = cmd.Time
+
+	// storages don't use contexts
+	ctx := context.Background()
+	switch {
+	case cmd.Op == opCreateLink && cmd.Link != nil:
+		link, err := f.urls.CreateOrGetShortenedURL(ctx, *cmd.Link)
+		return newResult(link, nil, err)
+	case cmd.Op == opUpdateLink && cmd.Update != nil:
+		link, err := f.urls.UpdateLink(ctx, cmd.Shortened, *cmd.Update, cmd.Author)
+		return newResult(link, nil, err)
+	case cmd.Op == opDeleteLink:
+		return newResult(domain.Link{}, nil, f.urls.DeleteLink(ctx, cmd.Shortened))
+	case cmd.Op == opAddClicks:
+		for shortened, clicks := range cmd.Clicks {
+			f.urls.AddClicks(shortened, clicks)
+		}
+		return result{}
+	case cmd.Op == opCreateAPIKey && cmd.Key != nil:
+		return newResult(domain.Link{}, nil, f.keys.CreateAPIKey(ctx, cmd.Key.key()))
+	case cmd.Op == opRevokeAPIKey:
+		key, err := f.keys.RevokeAPIKey(ctx, cmd.KeyID)
+		return newResult(domain.Link{}, newAPIKey(key), err)
+	case cmd.Op == opSetMember && cmd.Member != nil:
+		f.mu.Lock()
+		f.members[cmd.Member.ID] = cmd.Member.GRPCAddress
+		f.mu.Unlock()
+		return result{}
+	case cmd.Op == opRemoveMember && cmd.Member != nil:
+		f.mu.Lock()
+		delete(f.members, cmd.Member.ID)
+		f.mu.Unlock()
+		return result{}
+	default:
+		return newResult(domain.Link{}, nil, fmt.Errorf("unknown command %q", cmd.Op))
+	}
+}
+
+func (f *fsm) setApplied(index uint64) {
+	f.mu.Lock()
+	f.applied = index
+	close(f.notify)
+	f.notify = make(chan struct{})
+	f.mu.Unlock()
+}
+
+func (f *fsm) appliedIndex() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.applied
+}
+
+// waitApplied waits for the command with the index to be applied to the storages of the node.
+func (f *fsm) waitApplied(ctx context.Context, index uint64) error {
+	for {
+		f.mu.Lock()
+		applied, notify := f.applied, f.notify
+		f.mu.Unlock()
+		if applied >= index {
+			return nil
+		}
+
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (f *fsm) member(id string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.members[id]
+}
+
+// fsmState is the content of snapshots.
+type fsmState struct {
+	Applied uint64            `json:"applied"`
+	Members map[string]string `json:"members"`
+	URLs    json.RawMessage   `json:"urls"`
+	Keys    json.RawMessage   `json:"keys"`
+}
+
+// Snapshot copies the storages, as commands applied after it change them in place. The copy is encoded
+// by Persist, which runs concurrently with commands.
+func (f *fsm) Snapshot() (raft.FSMSnapshot, error) {
+	urls, keys := f.urls.Snapshot(), f.keys.Snapshot()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &snapshot{applied: f.applied, members: maps.Clone(f.members), urls: urls, keys: keys}, nil
+}
+
+func (f *fsm) Restore(rc io.ReadCloser) error {
+	defer func() { _ = rc.Close() }()
+
+	var state fsmState
+	if err := json.NewDecoder(rc).Decode(&state); err != nil {
+		return fmt.Errorf("Restore: failed to decode: %w", err)
+	}
+	if err := f.urls.Restore(bytes.NewReader(state.URLs)); err != nil {
+		return fmt.Errorf("Restore: links: %w", err)
+	}
+	if err := f.keys.Restore(bytes.NewReader(state.Keys)); err != nil {
+		return fmt.Errorf("Restore: api keys: %w", err)
+	}
+
+	f.mu.Lock()
+	f.members = state.Members
+	if f.members == nil {
+		f.members = make(map[string]string)
+	}
+	f.mu.Unlock()
+	f.setApplied(state.Applied)
+
+	return nil
+}
+
+type snapshot struct {
+	applied uint64
+	members map[string]string
+	urls    inmem.URLSnapshot
+	keys    inmem.APIKeySnapshot
+}
+
+func (s *snapshot) Persist(sink raft.SnapshotSink) error {
+	if err := s.persist(sink); err != nil {
+		_ = sink.Cancel()
+		return fmt.Errorf("Persist: %w", err)
+	}
+	return sink.Close()
+}
+
+func (s *snapshot) persist(w io.Writer) error {
+	var urls, keys bytes.Buffer
+	if err := s.urls.Encode(&urls); err != nil {
+		return fmt.Errorf("links: %w", err)
+	}
+	if err := s.keys.Encode(&keys); err != nil {
+		return fmt.Errorf("api keys: %w", err)
+	}
+
+	return json.NewEncoder(w).Encode(fsmState{
+		Applied: s.applied,
+		Members: s.members,
+		URLs:    urls.Bytes(),
+		Keys:    keys.Bytes(),
+	})
+}
+
+func (s *snapshot) Release() {}
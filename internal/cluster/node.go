@@ -0,0 +1,604 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"ozon_task/domain"
+	pkglog "ozon_task/pkg/log"
+	pkgtls "ozon_task/pkg/tls"
+	clusterv1 "ozon_task/protos/gen/go/cluster/v1"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/raft"
+	raftboltdb "github.com/hashicorp/raft-boltdb/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	transportMaxPool = 3
+	transportTimeout = 10 * time.Second
+	// joinRetryInterval is the pause between attempts to join the cluster on start.
+	joinRetryInterval = time.Second
+)
+
+// Node is a member of the cluster replicating the in-memory storage.
+type Node struct {
+	cfg    Config
+	log    *slog.Logger
+	fsm    *fsm
+	raft   *raft.Raft
+	closer []io.Closer
+
+	server   *grpc.Server
+	listener net.Listener
+	// tls serves certificates of the node to ClusterService, the raft transport and their clients
+	tls *pkgtls.Reloader
+
+	connsMu sync.Mutex
+	// conns to ClusterService of other nodes by their addresses
+	conns map[string]*grpc.ClientConn
+
+	leaderMu sync.Mutex
+	// ready is set once the leader has applied commands of previous leaders,
+	// term is incremented on every change of leadership
+	ready bool
+	term  uint64
+
+	stop      chan struct{}
+	cancelTLS context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+// NewNode starts raft and ClusterService of the node. The node bootstraps a new cluster or joins
+// the existing one in background, depending on the config.
+func NewNode(cfg Config, log *slog.Logger) (*Node, error) {
+	if !cfg.MutualTLS() {
+		return nil, fmt.Errorf("NewNode: mutual tls with client_auth %q is required", pkgtls.ClientAuthRequireAndVerify)
+	}
+
+	n := &Node{
+		cfg:   cfg,
+		log:   log.With(slog.String("node", cfg.NodeID)),
+		fsm:   newFSM(),
+		conns: make(map[string]*grpc.ClientConn),
+		stop:  make(chan struct{}),
+	}
+
+	var err error
+	n.tls, err = pkgtls.NewReloader(cfg.TLS, n.log)
+	if err != nil {
+		return nil, fmt.Errorf("NewNode: %w", err)
+	}
+	tlsCtx, cancelTLS := context.WithCancel(context.Background())
+	n.cancelTLS = cancelTLS
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		_ = n.tls.Watch(tlsCtx)
+	}()
+
+	if err = n.startRaft(); err != nil {
+		n.Close()
+		return nil, err
+	}
+
+	n.listener, err = net.Listen("tcp", cfg.GRPCAddress)
+	if err != nil {
+		n.Close()
+		return nil, fmt.Errorf("NewNode: failed to listen on %s: %w", cfg.GRPCAddress, err)
+	}
+	n.server = grpc.NewServer(grpc.Creds(credentials.NewTLS(n.tls.ServerConfig())))
+	clusterv1.RegisterClusterServiceServer(n.server, &server{node: n})
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		if err := n.server.Serve(n.listener); err != nil {
+			n.log.Error("cluster service stopped", pkglog.Err(err))
+		}
+	}()
+
+	if len(cfg.Join) != 0 {
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			n.joinCluster()
+		}()
+	}
+
+	return n, nil
+}
+
+func (n *Node) startRaft() error {
+	raftCfg := raft.DefaultConfig()
+	raftCfg.LocalID = raft.ServerID(n.cfg.NodeID)
+	raftCfg.HeartbeatTimeout = n.cfg.HeartbeatTimeout
+	raftCfg.ElectionTimeout = n.cfg.ElectionTimeout
+	raftCfg.LeaderLeaseTimeout = n.cfg.HeartbeatTimeout / 2
+	raftCfg.SnapshotInterval = n.cfg.SnapshotInterval
+	raftCfg.SnapshotThreshold = n.cfg.SnapshotThreshold
+	raftCfg.Logger = hclog.FromStandardLogger(
+		slog.NewLogLogger(n.log.Handler(), slog.LevelWarn),
+		&hclog.LoggerOptions{Name: "raft", Level: hclog.Warn},
+	)
+
+	// notifications are buffered, as raft blocks on sending them
+	leaderCh := make(chan bool, 16)
+	raftCfg.NotifyCh = leaderCh
+
+	logs, stable, snapshots, err := n.openStores()
+	if err != nil {
+		return err
+	}
+
+	advertise, err := net.ResolveTCPAddr("tcp", n.cfg.raftAdvertise())
+	if err != nil {
+		return fmt.Errorf("startRaft: invalid raft address %q: %w", n.cfg.raftAdvertise(), err)
+	}
+	stream, err := newTLSStreamLayer(n.cfg.RaftAddress, advertise, n.tls.ServerConfig(), n.tls.ClientConfig())
+	if err != nil {
+		return fmt.Errorf("startRaft: failed to listen on %s: %w", n.cfg.RaftAddress, err)
+	}
+	transport := raft.NewNetworkTransport(stream, transportMaxPool, transportTimeout, raftLogWriter(n.log))
+	n.closer = append(n.closer, transport)
+
+	n.raft, err = raft.NewRaft(raftCfg, n.fsm, logs, stable, snapshots, transport)
+	if err != nil {
+		return fmt.Errorf("startRaft: %w", err)
+	}
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		n.watchLeadership(leaderCh)
+	}()
+
+	if !n.cfg.Bootstrap {
+		return nil
+	}
+	existing, err := raft.HasExistingState(logs, stable, snapshots)
+	if err != nil {
+		return fmt.Errorf("startRaft: failed to check state: %w", err)
+	}
+	if existing {
+		return nil
+	}
+	err = n.raft.BootstrapCluster(raft.Configuration{Servers: []raft.Server{{
+		Suffrage: raft.Voter,
+		ID:       raft.ServerID(n.cfg.NodeID),
+		Address:  raft.ServerAddress(n.cfg.raftAdvertise()),
+	}}}).Error()
+	if err != nil {
+		return fmt.Errorf("startRaft: failed to bootstrap: %w", err)
+	}
+	n.log.Info("Bootstrapped cluster")
+
+	return nil
+}
+
+// openStores opens the log and snapshots in DataDir, or in memory if it's empty.
+func (n *Node) openStores() (raft.LogStore, raft.StableStore, raft.SnapshotStore, error) {
+	if len(n.cfg.DataDir) == 0 {
+		store := raft.NewInmemStore()
+		return store, store, raft.NewInmemSnapshotStore(), nil
+	}
+
+	if err := os.MkdirAll(n.cfg.DataDir, 0o750); err != nil {
+		return nil, nil, nil, fmt.Errorf("openStores: failed to create data dir: %w", err)
+	}
+	store, err := raftboltdb.New(raftboltdb.Options{Path: filepath.Join(n.cfg.DataDir, "raft.db")})
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("openStores: failed to open log: %w", err)
+	}
+	n.closer = append(n.closer, store)
+
+	snapshots, err := raft.NewFileSnapshotStore(n.cfg.DataDir, n.cfg.SnapshotRetain, raftLogWriter(n.log))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("openStores: failed to open snapshots: %w", err)
+	}
+
+	return store, store, snapshots, nil
+}
+
+// watchLeadership makes the node ready to serve as the leader after the barrier, which waits for
+// commands of previous leaders to be applied. The leader registers its address for forwarding then.
+func (n *Node) watchLeadership(leaderCh <-chan bool) {
+	for {
+		select {
+		case <-n.stop:
+			return
+		case leader := <-leaderCh:
+			n.leaderMu.Lock()
+			n.term++
+			term := n.term
+			n.ready = false
+			n.leaderMu.Unlock()
+
+			if !leader {
+				n.log.Info("Lost leadership")
+				continue
+			}
+			go n.prepareLeadership(term)
+		}
+	}
+}
+
+func (n *Node) prepareLeadership(term uint64) {
+	if err := n.raft.Barrier(n.cfg.ApplyTimeout).Error(); err != nil {
+		n.log.Warn("leader failed to apply previous commands", pkglog.Err(err))
+		return
+	}
+
+	n.leaderMu.Lock()
+	if n.term == term {
+		n.ready = true
+	}
+	n.leaderMu.Unlock()
+	n.log.Info("Became leader")
+
+	if n.fsm.member(n.cfg.NodeID) == n.cfg.grpcAdvertise() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), n.cfg.ApplyTimeout)
+	defer cancel()
+	_, _, err := n.applyLocal(ctx, command{
+		Op:     opSetMember,
+		Time:   time.Now().UTC(),
+		Member: &member{ID: n.cfg.NodeID, GRPCAddress: n.cfg.grpcAdvertise()},
+	})
+	if err != nil {
+		n.log.Warn("leader failed to register its address", pkglog.Err(err))
+	}
+}
+
+func (n *Node) isReadyLeader() bool {
+	n.leaderMu.Lock()
+	defer n.leaderMu.Unlock()
+	return n.ready && n.raft.State() == raft.Leader
+}
+
+// joinCluster asks nodes from the config to add this one until one of them succeeds.
+func (n *Node) joinCluster() {
+	self := &clusterv1.Member{
+		Id:          n.cfg.NodeID,
+		RaftAddress: n.cfg.raftAdvertise(),
+		GrpcAddress: n.cfg.grpcAdvertise(),
+	}
+
+	for {
+		for _, addr := range n.cfg.Join {
+			if addr == n.cfg.grpcAdvertise() {
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), n.cfg.ApplyTimeout)
+			err := n.requestJoin(ctx, addr, self)
+			cancel()
+			if err == nil {
+				n.log.Info("Joined cluster", slog.String("via", addr))
+				return
+			}
+			n.log.Warn("failed to join cluster", slog.String("via", addr), pkglog.Err(err))
+		}
+
+		select {
+		case <-n.stop:
+			return
+		case <-time.After(joinRetryInterval):
+		}
+	}
+}
+
+// requestJoin asks the node at the address to add this one, the request is repeated on the leader
+// if the node redirects to it.
+func (n *Node) requestJoin(ctx context.Context, addr string, self *clusterv1.Member) error {
+	client, err := n.client(addr)
+	if err != nil {
+		return err
+	}
+
+	var trailer metadata.MD
+	_, err = client.Join(ctx, &clusterv1.JoinRequest{Member: self}, grpc.Trailer(&trailer))
+	if leader := LeaderAddress(err, trailer); len(leader) != 0 && leader != addr {
+		if client, err = n.client(leader); err != nil {
+			return err
+		}
+		_, err = client.Join(ctx, &clusterv1.JoinRequest{Member: self})
+	}
+	return err
+}
+
+// apply commits the command and returns its result. Followers forward the command to the leader
+// and wait for it to be applied locally, so the node reads its own writes.
+func (n *Node) apply(ctx context.Context, cmd command) (result, error) {
+	if n.raft.State() == raft.Leader {
+		res, _, err := n.applyLocal(ctx, cmd)
+		return res, err
+	}
+
+	data, err := encodeCommand(cmd)
+	if err != nil {
+		return result{}, err
+	}
+	client, err := n.leaderClient()
+	if err != nil {
+		return result{}, err
+	}
+	resp, err := client.Apply(ctx, &clusterv1.ApplyRequest{Command: data})
+	if err != nil {
+		return result{}, unavailable("apply", err)
+	}
+
+	var res result
+	if err = json.Unmarshal(resp.GetResult(), &res); err != nil {
+		return result{}, fmt.Errorf("apply: failed to decode result: %w", err)
+	}
+	// the command is committed even if the node doesn't catch up in time
+	_ = n.fsm.waitApplied(ctx, resp.GetIndex())
+
+	return res, nil
+}
+
+// applyLocal appends the command to the log of the leader, the result is ready once it's applied.
+func (n *Node) applyLocal(ctx context.Context, cmd command) (result, uint64, error) {
+	data, err := encodeCommand(cmd)
+	if err != nil {
+		return result{}, 0, err
+	}
+
+	future := n.raft.Apply(data, n.cfg.ApplyTimeout)
+	if err = wait(ctx, future); err != nil {
+		return result{}, 0, unavailable("apply", err)
+	}
+
+	res, ok := future.Response().(result)
+	if !ok {
+		return result{}, 0, fmt.Errorf("apply: unexpected response %T", future.Response())
+	}
+	return res, future.Index(), nil
+}
+
+// read prepares the storage of the node for a read, reads are linearized only if it's configured.
+func (n *Node) read(ctx context.Context) error {
+	if !n.cfg.LinearizableReads {
+		return nil
+	}
+	return n.linearize(ctx)
+}
+
+// linearize waits until the storage of the node has all commands committed before the call.
+func (n *Node) linearize(ctx context.Context) error {
+	var index uint64
+	if n.raft.State() == raft.Leader {
+		var err error
+		if index, err = n.readIndex(ctx); err != nil {
+			return err
+		}
+	} else {
+		client, err := n.leaderClient()
+		if err != nil {
+			return err
+		}
+		resp, err := client.ReadIndex(ctx, &clusterv1.ReadIndexRequest{})
+		if err != nil {
+			return unavailable("linearize", err)
+		}
+		index = resp.GetIndex()
+	}
+
+	if err := n.fsm.waitApplied(ctx, index); err != nil {
+		return fmt.Errorf("linearize: %w: %w", domain.ErrTimeout, err)
+	}
+	return nil
+}
+
+// readIndex confirms leadership and returns the index of the last command applied by the leader.
+// Writes are acknowledged once the leader applies them, so it covers all completed writes.
+func (n *Node) readIndex(ctx context.Context) (uint64, error) {
+	if !n.isReadyLeader() {
+		return 0, unavailable("readIndex", errors.New("node isn't a ready leader"))
+	}
+	if err := wait(ctx, n.raft.VerifyLeader()); err != nil {
+		return 0, unavailable("readIndex", err)
+	}
+	return n.fsm.appliedIndex(), nil
+}
+
+// join adds the node to the cluster as a voter, its address is registered first, so it can be
+// reached as soon as it becomes the leader.
+func (n *Node) join(ctx context.Context, m *clusterv1.Member) error {
+	if n.raft.State() != raft.Leader {
+		return n.notLeader()
+	}
+
+	_, _, err := n.applyLocal(ctx, command{
+		Op:     opSetMember,
+		Time:   time.Now().UTC(),
+		Member: &member{ID: m.GetId(), GRPCAddress: m.GetGrpcAddress()},
+	})
+	if err != nil {
+		return err
+	}
+
+	future := n.raft.AddVoter(raft.ServerID(m.GetId()), raft.ServerAddress(m.GetRaftAddress()), 0, n.cfg.ApplyTimeout)
+	if err = wait(ctx, future); err != nil {
+		return unavailable("join", err)
+	}
+	n.log.Info("Node joined", slog.String("id", m.GetId()), slog.String("raft_address", m.GetRaftAddress()))
+
+	return nil
+}
+
+// remove removes the node from the cluster, the removed node stops participating in it.
+func (n *Node) remove(ctx context.Context, id string) error {
+	if n.raft.State() != raft.Leader {
+		return n.notLeader()
+	}
+
+	// the address is removed first, as the leader may remove itself and step down
+	_, _, err := n.applyLocal(ctx, command{
+		Op:     opRemoveMember,
+		Time:   time.Now().UTC(),
+		Member: &member{ID: id},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = wait(ctx, n.raft.RemoveServer(raft.ServerID(id), 0, n.cfg.ApplyTimeout)); err != nil {
+		return unavailable("remove", err)
+	}
+	n.log.Info("Node removed", slog.String("id", id))
+
+	return nil
+}
+
+// members returns nodes of the current configuration of the cluster.
+func (n *Node) members(ctx context.Context) ([]*clusterv1.Member, error) {
+	future := n.raft.GetConfiguration()
+	if err := wait(ctx, future); err != nil {
+		return nil, unavailable("members", err)
+	}
+	_, leader := n.raft.LeaderWithID()
+
+	servers := future.Configuration().Servers
+	members := make([]*clusterv1.Member, 0, len(servers))
+	for _, s := range servers {
+		members = append(members, &clusterv1.Member{
+			Id:          string(s.ID),
+			RaftAddress: string(s.Address),
+			GrpcAddress: n.fsm.member(string(s.ID)),
+			Voter:       s.Suffrage == raft.Voter,
+			Leader:      s.ID == leader,
+		})
+	}
+	return members, nil
+}
+
+// notLeaderError is returned by changes of membership on followers. They aren't forwarded, so the leader
+// authorizes the caller itself, which is redirected to the address of ClusterService of the leader.
+type notLeaderError struct {
+	// leader is empty if it's unknown
+	leader string
+}
+
+func (e *notLeaderError) Error() string {
+	if len(e.leader) == 0 {
+		return "node isn't the leader, leader is unknown"
+	}
+	return fmt.Sprintf("node isn't the leader, leader is %s", e.leader)
+}
+
+func (n *Node) notLeader() error {
+	_, id := n.raft.LeaderWithID()
+	if len(id) == 0 {
+		return &notLeaderError{}
+	}
+	return &notLeaderError{leader: n.fsm.member(string(id))}
+}
+
+// Leader returns the id of the current leader, empty if it's unknown.
+func (n *Node) Leader() string {
+	_, id := n.raft.LeaderWithID()
+	return string(id)
+}
+
+func (n *Node) leaderClient() (clusterv1.ClusterServiceClient, error) {
+	_, id := n.raft.LeaderWithID()
+	if len(id) == 0 {
+		return nil, unavailable("leaderClient", errors.New("no leader"))
+	}
+	addr := n.fsm.member(string(id))
+	if len(addr) == 0 {
+		return nil, unavailable("leaderClient", fmt.Errorf("address of leader %s is unknown", id))
+	}
+	return n.client(addr)
+}
+
+func (n *Node) client(addr string) (clusterv1.ClusterServiceClient, error) {
+	n.connsMu.Lock()
+	defer n.connsMu.Unlock()
+
+	conn, ok := n.conns[addr]
+	if !ok {
+		var err error
+		conn, err = grpc.NewClient(addr, grpc.WithTransportCredentials(credentials.NewTLS(n.tls.ClientConfig())))
+		if err != nil {
+			return nil, fmt.Errorf("client: %w", err)
+		}
+		n.conns[addr] = conn
+	}
+	return clusterv1.NewClusterServiceClient(conn), nil
+}
+
+// Close stops the node without leaving the cluster, so it rejoins it on restart.
+func (n *Node) Close() {
+	close(n.stop)
+	if n.cancelTLS != nil {
+		n.cancelTLS()
+	}
+
+	if n.server != nil {
+		n.server.Stop()
+	}
+	if n.raft != nil {
+		if err := n.raft.Shutdown().Error(); err != nil {
+			n.log.Error("failed to shutdown raft", pkglog.Err(err))
+		}
+	}
+	n.wg.Wait()
+
+	n.connsMu.Lock()
+	for _, conn := range n.conns {
+		_ = conn.Close()
+	}
+	n.connsMu.Unlock()
+
+	n.closeStores()
+}
+
+func (n *Node) closeStores() {
+	for _, closer := range n.closer {
+		if err := closer.Close(); err != nil {
+			n.log.Error("failed to close raft store", pkglog.Err(err))
+		}
+	}
+}
+
+// wait waits for the future of raft until the context is done.
+func wait(ctx context.Context, future raft.Future) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- future.Error()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// unavailable reports failures of the cluster as transient errors of the storage.
+func unavailable(op string, err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%s: %w: %w", op, domain.ErrTimeout, err)
+	}
+	return fmt.Errorf("%s: %w: %w", op, domain.ErrStorageUnavailable, err)
+}
+
+// raftLogWriter writes logs of the raft transport and snapshots as warnings of the node.
+func raftLogWriter(log *slog.Logger) io.Writer {
+	return slog.NewLogLogger(log.Handler(), slog.LevelWarn).Writer()
+}
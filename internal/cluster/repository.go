@@ -0,0 +1,204 @@
+package cluster
+
+import (
+	"context"
+	"log/slog"
+	"ozon_task/domain"
+	pkglog "ozon_task/pkg/log"
+	"sync"
+	"time"
+)
+
+// URLRepository writes links through the raft log and reads them from the storage of the node.
+type URLRepository struct {
+	node *Node
+
+	clicksMu sync.Mutex
+	// clicks are counted by the node since the last flush
+	clicks map[domain.ShortURL]int64
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// NewURLRepository returns links of the cluster, it must be closed before the node to flush clicks.
+func NewURLRepository(node *Node) *URLRepository {
+	r := &URLRepository{
+		node:   node,
+		clicks: make(map[domain.ShortURL]int64),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go r.flushClicksLoop()
+	return r
+}
+
+func (r *URLRepository) CreateOrGetShortenedURL(ctx context.Context, link domain.Link) (domain.Link, error) {
+	res, err := r.node.apply(ctx, command{Op: opCreateLink, Time: time.Now().UTC(), Link: &link})
+	if err != nil {
+		return domain.Link{}, err
+	}
+	return res.Link, res.err()
+}
+
+func (r *URLRepository) GetOriginalURLByShortened(
+	ctx context.Context,
+	shortened domain.ShortURL,
+) (domain.URL, error) {
+	if err := r.node.read(ctx); err != nil {
+		return "", err
+	}
+	return r.node.fsm.urls.GetOriginalURLByShortened(ctx, shortened)
+}
+
+func (r *URLRepository) GetShortenedURLByOriginal(
+	ctx context.Context,
+	owner domain.OwnerID,
+	original domain.URL,
+) (domain.ShortURL, error) {
+	if err := r.node.read(ctx); err != nil {
+		return "", err
+	}
+	return r.node.fsm.urls.GetShortenedURLByOriginal(ctx, owner, original)
+}
+
+func (r *URLRepository) ListLinks(
+	ctx context.Context,
+	filter domain.LinkFilter,
+	page domain.Page,
+) ([]domain.Link, error) {
+	if err := r.node.read(ctx); err != nil {
+		return nil, err
+	}
+	return r.node.fsm.urls.ListLinks(ctx, filter, page)
+}
+
+func (r *URLRepository) GetLink(ctx context.Context, shortened domain.ShortURL) (domain.Link, error) {
+	if err := r.node.read(ctx); err != nil {
+		return domain.Link{}, err
+	}
+	return r.node.fsm.urls.GetLink(ctx, shortened)
+}
+
+func (r *URLRepository) UpdateLink(
+	ctx context.Context,
+	shortened domain.ShortURL,
+	update domain.LinkUpdate,
+	author string,
+) (domain.Link, error) {
+	res, err := r.node.apply(ctx, command{
+		Op:        opUpdateLink,
+		Time:      time.Now().UTC(),
+		Shortened: shortened,
+		Update:    &update,
+		Author:    author,
+	})
+	if err != nil {
+		return domain.Link{}, err
+	}
+	return res.Link, res.err()
+}
+
+func (r *URLRepository) ListLinkVersions(ctx context.Context, shortened domain.ShortURL) ([]domain.LinkVersion, error) {
+	if err := r.node.read(ctx); err != nil {
+		return nil, err
+	}
+	return r.node.fsm.urls.ListLinkVersions(ctx, shortened)
+}
+
+func (r *URLRepository) DeleteLink(ctx context.Context, shortened domain.ShortURL) error {
+	res, err := r.node.apply(ctx, command{Op: opDeleteLink, Time: time.Now().UTC(), Shortened: shortened})
+	if err != nil {
+		return err
+	}
+	return res.err()
+}
+
+// RecordClick counts the click on the node, counts are written to the log by flushClicksLoop.
+func (r *URLRepository) RecordClick(_ context.Context, shortened domain.ShortURL) error {
+	r.clicksMu.Lock()
+	r.clicks[shortened]++
+	r.clicksMu.Unlock()
+	return nil
+}
+
+// Close stops counting clicks and flushes the counted ones.
+func (r *URLRepository) Close() {
+	close(r.stop)
+	<-r.done
+}
+
+func (r *URLRepository) flushClicksLoop() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.node.cfg.ClickFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			r.flushClicks()
+			return
+		case <-ticker.C:
+			r.flushClicks()
+		}
+	}
+}
+
+// flushClicks writes counted clicks by one command. Clicks are dropped if the write fails,
+// as it may be committed anyway and they're counted on best effort basis.
+func (r *URLRepository) flushClicks() {
+	r.clicksMu.Lock()
+	clicks := r.clicks
+	r.clicks = make(map[domain.ShortURL]int64)
+	r.clicksMu.Unlock()
+	if len(clicks) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.node.cfg.ApplyTimeout)
+	defer cancel()
+	if _, err := r.node.apply(ctx, command{Op: opAddClicks, Time: time.Now().UTC(), Clicks: clicks}); err != nil {
+		r.node.log.Warn("failed to flush clicks", slog.Int("links", len(clicks)), pkglog.Err(err))
+	}
+}
+
+// APIKeyRepository writes api keys through the raft log and reads them from the storage of the node.
+type APIKeyRepository struct {
+	node *Node
+}
+
+func NewAPIKeyRepository(node *Node) *APIKeyRepository {
+	return &APIKeyRepository{node: node}
+}
+
+func (r *APIKeyRepository) CreateAPIKey(ctx context.Context, key domain.APIKey) error {
+	res, err := r.node.apply(ctx, command{Op: opCreateAPIKey, Time: time.Now().UTC(), Key: newAPIKey(key)})
+	if err != nil {
+		return err
+	}
+	return res.err()
+}
+
+func (r *APIKeyRepository) GetAPIKeyByHash(ctx context.Context, hash []byte) (domain.APIKey, error) {
+	if err := r.node.read(ctx); err != nil {
+		return domain.APIKey{}, err
+	}
+	return r.node.fsm.keys.GetAPIKeyByHash(ctx, hash)
+}
+
+func (r *APIKeyRepository) ListAPIKeys(ctx context.Context, owner domain.OwnerID) ([]domain.APIKey, error) {
+	if err := r.node.read(ctx); err != nil {
+		return nil, err
+	}
+	return r.node.fsm.keys.ListAPIKeys(ctx, owner)
+}
+
+func (r *APIKeyRepository) RevokeAPIKey(ctx context.Context, id string) (domain.APIKey, error) {
+	res, err := r.node.apply(ctx, command{Op: opRevokeAPIKey, Time: time.Now().UTC(), KeyID: id})
+	if err != nil {
+		return domain.APIKey{}, err
+	}
+	if err = res.err(); err != nil {
+		return domain.APIKey{}, err
+	}
+	return res.Key.key(), nil
+}
@@ -0,0 +1,157 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"ozon_task/domain"
+	clusterv1 "ozon_task/protos/gen/go/cluster/v1"
+	"slices"
+
+	"github.com/hashicorp/raft"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// LeaderTrailer is the trailer with the address of ClusterService of the leader, followers send it
+// with FailedPrecondition to callers changing membership.
+const LeaderTrailer = "cluster-leader"
+
+// LeaderAddress returns the address of the leader the failed call must be repeated on,
+// empty if the call isn't redirected.
+func LeaderAddress(err error, trailer metadata.MD) string {
+	if status.Code(err) != codes.FailedPrecondition {
+		return ""
+	}
+	if values := trailer.Get(LeaderTrailer); len(values) != 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// server is ClusterService of the node. Callers are authenticated by certificates of the cluster CA:
+// every verified certificate belongs to a node, changes of membership require an admin certificate.
+type server struct {
+	clusterv1.UnimplementedClusterServiceServer
+	node *Node
+}
+
+func (s *server) Apply(ctx context.Context, req *clusterv1.ApplyRequest) (*clusterv1.ApplyResponse, error) {
+	if _, err := peerName(ctx); err != nil {
+		return nil, err
+	}
+	if s.node.raft.State() != raft.Leader {
+		return nil, status.Error(codes.FailedPrecondition, "node isn't the leader")
+	}
+
+	var cmd command
+	if err := json.Unmarshal(req.GetCommand(), &cmd); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid command: %s", err)
+	}
+	res, index, err := s.node.applyLocal(ctx, cmd)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	data, err := json.Marshal(res)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to encode result: %s", err)
+	}
+	return &clusterv1.ApplyResponse{Result: data, Index: index}, nil
+}
+
+func (s *server) ReadIndex(ctx context.Context, _ *clusterv1.ReadIndexRequest) (*clusterv1.ReadIndexResponse, error) {
+	if _, err := peerName(ctx); err != nil {
+		return nil, err
+	}
+	index, err := s.node.readIndex(ctx)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return &clusterv1.ReadIndexResponse{Index: index}, nil
+}
+
+// Join adds the member on behalf of an admin or of the member itself, so nodes join the cluster on start.
+func (s *server) Join(ctx context.Context, req *clusterv1.JoinRequest) (*clusterv1.JoinResponse, error) {
+	name, err := peerName(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m := req.GetMember()
+	if name != m.GetId() && !s.isAdmin(name) {
+		return nil, status.Errorf(codes.PermissionDenied, "%s may add only itself", name)
+	}
+	if len(m.GetId()) == 0 || len(m.GetRaftAddress()) == 0 || len(m.GetGrpcAddress()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "id, raft and grpc addresses of the member are required")
+	}
+	if err = s.node.join(ctx, m); err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return &clusterv1.JoinResponse{}, nil
+}
+
+func (s *server) Remove(ctx context.Context, req *clusterv1.RemoveRequest) (*clusterv1.RemoveResponse, error) {
+	name, err := peerName(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !s.isAdmin(name) {
+		return nil, status.Errorf(codes.PermissionDenied, "%s isn't an admin", name)
+	}
+	if len(req.GetId()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "id of the member is required")
+	}
+	if err = s.node.remove(ctx, req.GetId()); err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return &clusterv1.RemoveResponse{}, nil
+}
+
+func (s *server) Members(ctx context.Context, _ *clusterv1.MembersRequest) (*clusterv1.MembersResponse, error) {
+	if _, err := peerName(ctx); err != nil {
+		return nil, err
+	}
+	members, err := s.node.members(ctx)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return &clusterv1.MembersResponse{Members: members}, nil
+}
+
+func (s *server) isAdmin(name string) bool {
+	return slices.Contains(s.node.cfg.AdminNames, name)
+}
+
+// peerName returns the common name of the verified certificate of the caller.
+func peerName(ctx context.Context) (string, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "caller is unknown")
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return "", status.Error(codes.Unauthenticated, "caller has no verified certificate")
+	}
+	return info.State.VerifiedChains[0][0].Subject.CommonName, nil
+}
+
+func statusError(ctx context.Context, err error) error {
+	var notLeader *notLeaderError
+	switch {
+	case errors.As(err, &notLeader):
+		if len(notLeader.leader) != 0 {
+			_ = grpc.SetTrailer(ctx, metadata.Pairs(LeaderTrailer, notLeader.leader))
+		}
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, domain.ErrTimeout):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, domain.ErrStorageUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
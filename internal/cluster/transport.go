@@ -0,0 +1,45 @@
+package cluster
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/hashicorp/raft"
+)
+
+// tlsStreamLayer is the raft transport over mutual TLS, nodes verify certificates of each other
+// on every connection. Accepted connections complete the handshake on the first read of raft.
+type tlsStreamLayer struct {
+	net.Listener
+	advertise net.Addr
+	client    *tls.Config
+}
+
+func newTLSStreamLayer(bind string, advertise net.Addr, server, client *tls.Config) (*tlsStreamLayer, error) {
+	// other nodes can't dial an unspecified address, as raft.NewTCPTransport checks too
+	addr, ok := advertise.(*net.TCPAddr)
+	if !ok || addr.IP == nil || addr.IP.IsUnspecified() {
+		return nil, fmt.Errorf("newTLSStreamLayer: address %s isn't advertisable, raft_advertise is required", advertise)
+	}
+
+	lis, err := net.Listen("tcp", bind)
+	if err != nil {
+		return nil, fmt.Errorf("newTLSStreamLayer: %w", err)
+	}
+	return &tlsStreamLayer{Listener: tls.NewListener(lis, server), advertise: advertise, client: client}, nil
+}
+
+func (l *tlsStreamLayer) Dial(address raft.ServerAddress, timeout time.Duration) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", string(address), l.client)
+	if err != nil {
+		return nil, fmt.Errorf("Dial: %w", err)
+	}
+	return conn, nil
+}
+
+func (l *tlsStreamLayer) Addr() net.Addr {
+	return l.advertise
+}
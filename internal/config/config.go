@@ -1,9 +1,23 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"ozon_task/internal/auth"
+	"ozon_task/internal/cluster"
+	"ozon_task/internal/idempotency"
+	"ozon_task/internal/ratelimit"
+	"ozon_task/internal/repository/migration"
+	"ozon_task/internal/repository/postgres"
+	"ozon_task/internal/repository/sharded"
+	"ozon_task/internal/stale"
+	pkgconfig "ozon_task/pkg/config"
 	"ozon_task/pkg/infra"
 	"ozon_task/pkg/infra/cache/redis"
 	pkglog "ozon_task/pkg/log"
+	"ozon_task/pkg/resilience"
+	pkgtls "ozon_task/pkg/tls"
 	"time"
 )
 
@@ -13,17 +27,275 @@ type HTTPConfig struct {
 	WriteTimeout      time.Duration `yaml:"write_timeout" env-default:"5s"`
 	IdleTimeout       time.Duration `yaml:"idle_timeout" env-default:"30s"`
 	OperationsTimeout time.Duration `yaml:"operations_timeout" env-default:"4s"`
+	TLS               pkgtls.Config `yaml:"tls"`
+	Gateway           GatewayConfig `yaml:"gateway"`
 }
 
+// GatewayConfig configures serving of the URL api over HTTP by JSON transcoding of the gRPC services.
+type GatewayConfig struct {
+	// GRPCAddress is the address of the gRPC server, the server of the same process is called
+	// without network by default.
+	GRPCAddress string `yaml:"grpc_address"`
+	// TLS is used to connect to the gRPC server when it has TLS enabled.
+	TLS pkgtls.ClientConfig `yaml:"tls"`
+}
+
+// PathEnvVar is the environment variable with path of the config file.
+const PathEnvVar = "SHORTENER_CONFIG"
+
+// Storages of links and caches of them.
+const (
+	StoragePostgres = "postgres"
+	StorageMemory   = "memory"
+	// StorageCluster is the in-memory storage replicated between instances of the service.
+	StorageCluster = "cluster"
+
+	CacheNone  = "none"
+	CacheRedis = "redis"
+)
+
 type Config struct {
-	HTTPServer HTTPConfig           `yaml:"http_server" env-required:"true"`
-	GRPC       GRPCConfig           `yaml:"grpc" env-required:"true"`
-	PG         infra.PostgresConfig `yaml:"postgres"`
-	Redis      redis.Config         `yaml:"redis"`
-	Logger     pkglog.Config        `yaml:"logger" env-required:"true"`
+	Storage     StorageConfig        `yaml:"storage"`
+	HTTPServer  HTTPConfig           `yaml:"http_server" env-required:"true"`
+	GRPC        GRPCConfig           `yaml:"grpc" env-required:"true"`
+	PG          infra.PostgresConfig `yaml:"postgres"`
+	Redis       redis.Config         `yaml:"redis"`
+	Logger      pkglog.Config        `yaml:"logger" env-required:"true"`
+	Auth        auth.Config          `yaml:"auth"`
+	RateLimit   ratelimit.Config     `yaml:"rate_limit"`
+	Idempotency idempotency.Config   `yaml:"idempotency"`
+	Resilience  ResilienceConfig     `yaml:"resilience"`
+	Stale       stale.Config         `yaml:"stale"`
+	Migrations  MigrationsConfig     `yaml:"migrations"`
+	Links       LinksConfig          `yaml:"links"`
+}
+
+// StorageConfig selects storages of the service, -inmem and -redis flags override it.
+// Redis cache is used only with postgres storage.
+type StorageConfig struct {
+	Backend string `yaml:"backend" env-default:"postgres"`
+	Cache   string `yaml:"cache" env-default:"none"`
+	// Migration moves links from the backend to another one without downtime.
+	Migration migration.Config `yaml:"migration"`
+	// Shards spread links of postgres backend across databases by short code, the postgres section
+	// keeps api keys and idempotency keys then. Shards are placed by names, so renaming a shard moves its links.
+	Shards []ShardConfig `yaml:"shards"`
+	// Batch combines concurrent creations of links in postgres into multi-row inserts.
+	Batch postgres.BatchConfig `yaml:"batch"`
+	// Cluster is the node of cluster backend.
+	Cluster cluster.Config `yaml:"cluster"`
+}
+
+// ShardConfig is a database keeping a part of links.
+type ShardConfig struct {
+	Name     string               `yaml:"name"`
+	Postgres infra.PostgresConfig `yaml:"postgres"`
+}
+
+// LinksConfig limits links accepted by the service.
+type LinksConfig struct {
+	// MaxOriginalLength is the limit of length of original urls in bytes.
+	MaxOriginalLength int `yaml:"max_original_length" env-default:"8192"`
+}
+
+// MigrationsConfig controls migrations of the postgres schema embedded into the binary.
+type MigrationsConfig struct {
+	// Auto applies pending migrations on start, otherwise the service refuses to start with outdated schema.
+	Auto bool `yaml:"auto" env-default:"false"`
+}
+
+// ResilienceConfig configures retries and circuit breakers of calls to storages.
+type ResilienceConfig struct {
+	Postgres resilience.Config `yaml:"postgres"`
+	Redis    resilience.Config `yaml:"redis"`
 }
 
 type GRPCConfig struct {
 	Port              int           `yaml:"port" env-required:"true"`
 	OperationsTimeout time.Duration `yaml:"operations_timeout" env-default:"5s"`
+	TLS               pkgtls.Config `yaml:"tls"`
+}
+
+// Load reads the config file from the path of the flags or PathEnvVar, applies the flags and validates the result.
+func Load(flags AppFlags) (Config, error) {
+	path := flags.ConfigPath
+	if len(path) == 0 {
+		path = os.Getenv(PathEnvVar)
+	}
+
+	var cfg Config
+	if err := pkgconfig.Load(path, &cfg); err != nil {
+		return Config{}, err
+	}
+	flags.Override(&cfg)
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// Validate checks what the loader can't: names of backends and storages they depend on.
+func (c Config) Validate() error {
+	var errs []error
+	invalid := func(field, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	switch c.Storage.Backend {
+	case StoragePostgres, StorageMemory:
+	case StorageCluster:
+		c.validateCluster(invalid)
+	default:
+		invalid("storage.backend", "unknown backend %q", c.Storage.Backend)
+	}
+	if c.Links.MaxOriginalLength < 0 {
+		invalid("links.max_original_length", "must not be negative")
+	}
+	switch c.Storage.Cache {
+	case CacheNone, CacheRedis:
+	default:
+		invalid("storage.cache", "unknown cache %q", c.Storage.Cache)
+	}
+	if c.Storage.Migration.Enabled {
+		c.validateMigration(invalid)
+	}
+	if len(c.Storage.Shards) != 0 {
+		c.validateShards(invalid)
+	}
+	if c.Storage.Batch.Enabled {
+		c.validateBatch(invalid)
+	}
+	redisEnabled := c.Storage.Backend == StoragePostgres && c.Storage.Cache == CacheRedis
+
+	if c.Auth.Enabled && !c.Auth.APIKeysEnabled() && !c.Auth.JWTEnabled() {
+		invalid("auth.mode", "unknown mode %q", c.Auth.Mode)
+	}
+
+	if c.RateLimit.Enabled {
+		switch c.RateLimit.Backend {
+		case ratelimit.BackendMemory:
+		case ratelimit.BackendRedis:
+			if !redisEnabled {
+				invalid("rate_limit.backend", "redis backend requires redis cache")
+			}
+		default:
+			invalid("rate_limit.backend", "unknown backend %q", c.RateLimit.Backend)
+		}
+		if _, err := ratelimit.ParseProxies(c.RateLimit.TrustedProxies); err != nil {
+			invalid("rate_limit.trusted_proxies", "%v", err)
+		}
+	}
+
+	if c.Idempotency.Enabled {
+		switch c.Idempotency.Backend {
+		case "", idempotency.BackendMemory:
+		case idempotency.BackendRedis:
+			if !redisEnabled {
+				invalid("idempotency.backend", "redis backend requires redis cache")
+			}
+		case idempotency.BackendPostgres:
+			if c.Storage.Backend != StoragePostgres {
+				invalid("idempotency.backend", "postgres backend requires postgres storage")
+			}
+		default:
+			invalid("idempotency.backend", "unknown backend %q", c.Idempotency.Backend)
+		}
+		if c.Idempotency.LockTTL <= 0 || c.Idempotency.LockTTL > c.Idempotency.TTL {
+			invalid("idempotency.lock_ttl", "must be positive and not longer than ttl")
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c Config) validateMigration(invalid func(field, format string, args ...any)) {
+	m := c.Storage.Migration
+	switch m.Target {
+	case StoragePostgres:
+		// the same database may be the target only if it's another one
+		if c.Storage.Backend == StoragePostgres && m.Postgres == nil {
+			invalid("storage.migration.postgres", "database of the target is required with postgres storage")
+		}
+	case StorageMemory:
+		if c.Storage.Backend == StorageMemory {
+			invalid("storage.migration.target", "target must differ from storage.backend")
+		}
+	default:
+		invalid("storage.migration.target", "unknown backend %q", m.Target)
+	}
+
+	switch m.Primary {
+	case migration.PrimarySource, migration.PrimaryTarget:
+	default:
+		invalid("storage.migration.primary", "unknown primary %q", m.Primary)
+	}
+	if m.Backfill.Enabled && m.Backfill.BatchSize <= 0 {
+		invalid("storage.migration.backfill.batch_size", "must be positive")
+	}
+}
+
+func (c Config) validateBatch(invalid func(field, format string, args ...any)) {
+	b := c.Storage.Batch
+	if c.Storage.Backend != StoragePostgres {
+		invalid("storage.batch", "batching requires postgres storage")
+	}
+	if b.MaxSize <= 0 {
+		invalid("storage.batch.max_size", "must be positive")
+	}
+	if b.MaxDelay <= 0 {
+		invalid("storage.batch.max_delay", "must be positive")
+	}
+	if b.QueueSize <= 0 {
+		invalid("storage.batch.queue_size", "must be positive")
+	}
+	if b.Timeout <= 0 {
+		invalid("storage.batch.timeout", "must be positive")
+	}
+}
+
+func (c Config) validateCluster(invalid func(field, format string, args ...any)) {
+	cl := c.Storage.Cluster
+	if len(cl.NodeID) == 0 {
+		invalid("storage.cluster.node_id", "node id is required")
+	}
+	if len(cl.RaftAddress) == 0 {
+		invalid("storage.cluster.raft_address", "address is required")
+	}
+	if len(cl.GRPCAddress) == 0 {
+		invalid("storage.cluster.grpc_address", "address is required")
+	}
+	if !cl.MutualTLS() {
+		invalid("storage.cluster.tls", "mutual tls with client_auth %q is required", pkgtls.ClientAuthRequireAndVerify)
+	}
+	if !cl.Bootstrap && len(cl.Join) == 0 && len(cl.DataDir) == 0 {
+		invalid("storage.cluster.join", "node must bootstrap the cluster, join it or restart from data_dir")
+	}
+	if cl.ApplyTimeout <= 0 {
+		invalid("storage.cluster.apply_timeout", "must be positive")
+	}
+	if cl.ClickFlushInterval <= 0 {
+		invalid("storage.cluster.click_flush_interval", "must be positive")
+	}
+}
+
+func (c Config) validateShards(invalid func(field, format string, args ...any)) {
+	if c.Storage.Backend != StoragePostgres {
+		invalid("storage.shards", "shards require postgres storage")
+	}
+	if len(c.Storage.Shards) > sharded.MaxShards {
+		invalid("storage.shards", "at most %d shards are supported", sharded.MaxShards)
+	}
+
+	names := make(map[string]bool, len(c.Storage.Shards))
+	for i, shard := range c.Storage.Shards {
+		switch {
+		case len(shard.Name) == 0:
+			invalid(fmt.Sprintf("storage.shards[%d].name", i), "name is required")
+		case names[shard.Name]:
+			invalid(fmt.Sprintf("storage.shards[%d].name", i), "duplicate shard %q", shard.Name)
+		}
+		names[shard.Name] = true
+	}
 }
@@ -0,0 +1,225 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"ozon_task/internal/cluster"
+	"ozon_task/internal/idempotency"
+	"ozon_task/internal/ratelimit"
+	"ozon_task/internal/repository/migration"
+	"ozon_task/internal/repository/postgres"
+	pkgtls "ozon_task/pkg/tls"
+)
+
+func validConfig() Config {
+	return Config{Storage: StorageConfig{Backend: StoragePostgres, Cache: CacheRedis}}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	t.Parallel()
+	clusterTLS := pkgtls.Config{Enabled: true, ClientAuth: pkgtls.ClientAuthRequireAndVerify}
+
+	tests := []struct {
+		name    string
+		modify  func(cfg *Config)
+		wantErr string
+	}{
+		{name: "valid", modify: func(*Config) {}},
+		{
+			name:    "unknown storage",
+			modify:  func(cfg *Config) { cfg.Storage.Backend = "mysql" },
+			wantErr: `storage.backend: unknown backend "mysql"`,
+		},
+		{
+			name:    "unknown cache",
+			modify:  func(cfg *Config) { cfg.Storage.Cache = "memcached" },
+			wantErr: `storage.cache: unknown cache "memcached"`,
+		},
+		{
+			name: "redis rate limit without redis",
+			modify: func(cfg *Config) {
+				cfg.Storage.Cache = CacheNone
+				cfg.RateLimit.Enabled = true
+				cfg.RateLimit.Backend = ratelimit.BackendRedis
+			},
+			wantErr: "rate_limit.backend: redis backend requires redis cache",
+		},
+		{
+			name: "invalid trusted proxy",
+			modify: func(cfg *Config) {
+				cfg.RateLimit.Enabled = true
+				cfg.RateLimit.Backend = ratelimit.BackendMemory
+				cfg.RateLimit.TrustedProxies = []string{"10.0.0.1"}
+			},
+			wantErr: "rate_limit.trusted_proxies",
+		},
+		{
+			name: "postgres idempotency with memory storage",
+			modify: func(cfg *Config) {
+				cfg.Storage.Backend = StorageMemory
+				cfg.Idempotency.Enabled = true
+				cfg.Idempotency.Backend = idempotency.BackendPostgres
+			},
+			wantErr: "idempotency.backend: postgres backend requires postgres storage",
+		},
+		{
+			name: "unknown auth mode",
+			modify: func(cfg *Config) {
+				cfg.Auth.Enabled = true
+				cfg.Auth.Mode = "basic"
+			},
+			wantErr: `auth.mode: unknown mode "basic"`,
+		},
+		{
+			name: "migration to memory",
+			modify: func(cfg *Config) {
+				cfg.Storage.Migration = migration.Config{Enabled: true, Target: StorageMemory, Primary: migration.PrimaryTarget}
+			},
+		},
+		{
+			name: "migration to the same database",
+			modify: func(cfg *Config) {
+				cfg.Storage.Migration = migration.Config{Enabled: true, Target: StoragePostgres, Primary: migration.PrimarySource}
+			},
+			wantErr: "storage.migration.postgres: database of the target is required with postgres storage",
+		},
+		{
+			name: "migration with unknown primary",
+			modify: func(cfg *Config) {
+				cfg.Storage.Backend = StorageMemory
+				cfg.Storage.Migration = migration.Config{Enabled: true, Target: StoragePostgres, Primary: "both"}
+			},
+			wantErr: `storage.migration.primary: unknown primary "both"`,
+		},
+		{
+			name: "shards",
+			modify: func(cfg *Config) {
+				cfg.Storage.Shards = []ShardConfig{{Name: "a"}, {Name: "b"}}
+			},
+		},
+		{
+			name: "duplicate shards",
+			modify: func(cfg *Config) {
+				cfg.Storage.Shards = []ShardConfig{{Name: "a"}, {Name: "a"}}
+			},
+			wantErr: `storage.shards[1].name: duplicate shard "a"`,
+		},
+		{
+			name: "shards with memory storage",
+			modify: func(cfg *Config) {
+				cfg.Storage.Backend = StorageMemory
+				cfg.Storage.Shards = []ShardConfig{{Name: "a"}}
+			},
+			wantErr: "storage.shards: shards require postgres storage",
+		},
+		{
+			name: "batching without delay",
+			modify: func(cfg *Config) {
+				cfg.Storage.Batch = postgres.BatchConfig{Enabled: true, MaxSize: 100, QueueSize: 1000, Timeout: time.Second}
+			},
+			wantErr: "storage.batch.max_delay: must be positive",
+		},
+		{
+			name: "cluster",
+			modify: func(cfg *Config) {
+				cfg.Storage.Backend = StorageCluster
+				cfg.Storage.Cluster = cluster.Config{
+					NodeID:             "node1",
+					RaftAddress:        ":7000",
+					GRPCAddress:        ":7001",
+					TLS:                clusterTLS,
+					Join:               []string{"node2:7001"},
+					ApplyTimeout:       time.Second,
+					ClickFlushInterval: time.Second,
+				}
+			},
+		},
+		{
+			name: "cluster without mutual tls",
+			modify: func(cfg *Config) {
+				cfg.Storage.Backend = StorageCluster
+				cfg.Storage.Cluster = cluster.Config{
+					NodeID:             "node1",
+					RaftAddress:        ":7000",
+					GRPCAddress:        ":7001",
+					TLS:                pkgtls.Config{Enabled: true, ClientAuth: pkgtls.ClientAuthNone},
+					Join:               []string{"node2:7001"},
+					ApplyTimeout:       time.Second,
+					ClickFlushInterval: time.Second,
+				}
+			},
+			wantErr: `storage.cluster.tls: mutual tls with client_auth "require_and_verify" is required`,
+		},
+		{
+			name: "cluster node without peers",
+			modify: func(cfg *Config) {
+				cfg.Storage.Backend = StorageCluster
+				cfg.Storage.Cluster = cluster.Config{
+					NodeID:             "node1",
+					RaftAddress:        ":7000",
+					GRPCAddress:        ":7001",
+					TLS:                clusterTLS,
+					ApplyTimeout:       time.Second,
+					ClickFlushInterval: time.Second,
+				}
+			},
+			wantErr: "storage.cluster.join: node must bootstrap the cluster, join it or restart from data_dir",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := validConfig()
+			tt.modify(&cfg)
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestLoad_FlagsOverrideConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+storage:
+  backend: postgres
+  cache: none
+http_server:
+  address: localhost:8080
+grpc:
+  port: 5050
+postgres:
+  host: localhost
+  port: 5432
+  user: postgres
+  password: password
+  db_name: postgres
+redis:
+  host: localhost
+  port: 6379
+logger:
+  level: info
+`), 0o600))
+
+	cfg, err := Load(AppFlags{ConfigPath: path})
+	require.NoError(t, err)
+	require.Equal(t, StoragePostgres, cfg.Storage.Backend)
+	require.Equal(t, CacheNone, cfg.Storage.Cache)
+	require.False(t, cfg.Storage.Migration.Enabled)
+	require.Equal(t, migration.PrimarySource, cfg.Storage.Migration.Primary)
+
+	cfg, err = Load(AppFlags{ConfigPath: path, UseInMemStorage: true, UseRedis: true})
+	require.NoError(t, err)
+	require.Equal(t, StorageMemory, cfg.Storage.Backend)
+	require.Equal(t, CacheRedis, cfg.Storage.Cache)
+}
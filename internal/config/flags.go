@@ -3,17 +3,34 @@ package config
 import "flag"
 
 type AppFlags struct {
+	// ConfigPath is the path of the config file, SHORTENER_CONFIG is used if it's empty.
+	ConfigPath      string
 	UseRedis        bool
 	UseInMemStorage bool
+	// Args are the command and its arguments left after flags.
+	Args []string
 }
 
 func ParseFlags() AppFlags {
-	redis := flag.Bool("redis", false, "Use redis as app's cache (doesn't work if inmem is true)")
-	inMem := flag.Bool("inmem", false, "Use inmemory storage instead of postgres")
+	configPath := flag.String("config", "", "Path to the config file (default is SHORTENER_CONFIG environment variable)")
+	redis := flag.Bool("redis", false, "Use redis as app's cache, overrides storage.cache (doesn't work if inmem is true)")
+	inMem := flag.Bool("inmem", false, "Use inmemory storage instead of postgres, overrides storage.backend")
 	flag.Parse()
 
 	return AppFlags{
+		ConfigPath:      *configPath,
 		UseRedis:        *redis,
 		UseInMemStorage: *inMem,
+		Args:            flag.Args(),
+	}
+}
+
+// Override applies flags to the config, flags which aren't set keep values of the config.
+func (f AppFlags) Override(cfg *Config) {
+	if f.UseInMemStorage {
+		cfg.Storage.Backend = StorageMemory
+	}
+	if f.UseRedis {
+		cfg.Storage.Cache = CacheRedis
 	}
 }
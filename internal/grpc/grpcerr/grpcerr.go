@@ -0,0 +1,189 @@
+// Package grpcerr converts errors of the domain to gRPC statuses with
+// google.rpc.ErrorInfo and google.rpc.BadRequest details.
+package grpcerr
+
+import (
+	"ozon_task/domain"
+	pkgerr "ozon_task/pkg/error"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Domain is the domain of ErrorInfo details.
+const Domain = "shortener"
+
+// RequestIDKey is the key of id of the request in metadata of ErrorInfo.
+const RequestIDKey = "request_id"
+
+var grpcCodes = map[domain.ErrorCode]codes.Code{
+	domain.CodeInvalidRequest:           codes.InvalidArgument,
+	domain.CodeInvalidOriginal:          codes.InvalidArgument,
+	domain.CodeInvalidShortened:         codes.InvalidArgument,
+	domain.CodeInvalidTags:              codes.InvalidArgument,
+	domain.CodeInvalidCursor:            codes.InvalidArgument,
+	domain.CodeInvalidFilter:            codes.InvalidArgument,
+	domain.CodeInvalidVersion:           codes.InvalidArgument,
+	domain.CodeInvalidExpiration:        codes.InvalidArgument,
+	domain.CodeInvalidLinkUpdate:        codes.InvalidArgument,
+	domain.CodeInvalidAPIKey:            codes.InvalidArgument,
+	domain.CodeInvalidIdempotencyKey:    codes.InvalidArgument,
+	domain.CodeNotFound:                 codes.NotFound,
+	domain.CodeExpired:                  codes.NotFound,
+	domain.CodeAliasTaken:               codes.AlreadyExists,
+	domain.CodeConflict:                 codes.AlreadyExists,
+	domain.CodeUnauthenticated:          codes.Unauthenticated,
+	domain.CodePermissionDenied:         codes.PermissionDenied,
+	domain.CodeRateLimited:              codes.ResourceExhausted,
+	domain.CodeQuotaExceeded:            codes.ResourceExhausted,
+	domain.CodeIdempotencyKeyReused:     codes.FailedPrecondition,
+	domain.CodeIdempotencyKeyInProgress: codes.Aborted,
+	domain.CodeMethodNotAllowed:         codes.Unimplemented,
+}
+
+// fields are names of request fields reported in BadRequest details of invalid arguments.
+var fields = map[domain.ErrorCode]string{
+	domain.CodeInvalidOriginal:       "original_url",
+	domain.CodeInvalidShortened:      "shortened_url",
+	domain.CodeInvalidTags:           "tags",
+	domain.CodeInvalidCursor:         "page_token",
+	domain.CodeInvalidVersion:        "version",
+	domain.CodeInvalidExpiration:     "expire_time",
+	domain.CodeInvalidLinkUpdate:     "update_mask",
+	domain.CodeInvalidIdempotencyKey: "idempotency-key",
+}
+
+// Status converts the error to status with its code from the domain catalog.
+// Messages of internal errors are hidden.
+func Status(err error) *status.Status {
+	code := domain.ErrorCodeOf(err)
+	err = pkgerr.UnwrapAll(err)
+
+	var st *status.Status
+	switch {
+	case domain.IsTransient(code):
+		st = status.New(codes.Unavailable, "service is temporarily unavailable")
+	case code == domain.CodeTimeout:
+		st = status.New(codes.DeadlineExceeded, "deadline of operation exceeded")
+	case code == domain.CodeCanceled:
+		st = status.New(codes.Canceled, "operation was cancelled")
+	default:
+		grpcCode, ok := grpcCodes[code]
+		if !ok {
+			code = domain.CodeInternal
+			st = status.New(codes.Internal, "internal server error")
+			break
+		}
+		st = status.New(grpcCode, err.Error())
+	}
+
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{Reason: string(code), Domain: Domain}}
+	if domain.IsTransient(code) {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(domain.TransientRetryAfter)})
+	}
+	if field, ok := fields[code]; ok {
+		details = append(details, &errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{
+				{Field: field, Description: err.Error()},
+			},
+		})
+	}
+
+	return withDetails(st, details...)
+}
+
+// RequestID returns id of the request from ErrorInfo of the status.
+func RequestID(st *status.Status) string {
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.GetDomain() == Domain {
+			return info.GetMetadata()[RequestIDKey]
+		}
+	}
+	return ""
+}
+
+// Error converts the error to status error, see Status.
+func Error(err error) error {
+	return Status(err).Err()
+}
+
+// WithRequestID adds id of the request to ErrorInfo of the status error.
+// Errors without status and statuses without ErrorInfo get it as well.
+func WithRequestID(err error, requestID string) error {
+	if err == nil || requestID == "" {
+		return err
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		st = Status(err)
+	}
+
+	details := make([]protoadapt.MessageV1, 0, len(st.Details())+1)
+	found := false
+	for _, detail := range st.Details() {
+		msg, ok := detail.(protoadapt.MessageV1)
+		if !ok {
+			continue
+		}
+		if info, ok := msg.(*errdetails.ErrorInfo); ok {
+			if info.Metadata == nil {
+				info.Metadata = make(map[string]string, 1)
+			}
+			info.Metadata[RequestIDKey] = requestID
+			found = true
+		}
+		details = append(details, msg)
+	}
+	if !found {
+		details = append([]protoadapt.MessageV1{&errdetails.ErrorInfo{
+			Reason:   string(Reason(st)),
+			Domain:   Domain,
+			Metadata: map[string]string{RequestIDKey: requestID},
+		}}, details...)
+	}
+
+	return withDetails(status.New(st.Code(), st.Message()), details...).Err()
+}
+
+// Reason returns code of the error from ErrorInfo of the status. Statuses created outside
+// of the package, e.g. by the transport, get the code closest to their status code.
+func Reason(st *status.Status) domain.ErrorCode {
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.GetDomain() == Domain {
+			return domain.ErrorCode(info.GetReason())
+		}
+	}
+
+	switch st.Code() {
+	case codes.DeadlineExceeded:
+		return domain.CodeTimeout
+	case codes.Canceled:
+		return domain.CodeCanceled
+	case codes.InvalidArgument:
+		return domain.CodeInvalidRequest
+	case codes.Unauthenticated:
+		return domain.CodeUnauthenticated
+	case codes.PermissionDenied:
+		return domain.CodePermissionDenied
+	case codes.NotFound:
+		return domain.CodeNotFound
+	case codes.Unimplemented:
+		return domain.CodeMethodNotAllowed
+	case codes.Unavailable:
+		return domain.CodeStorageUnavailable
+	default:
+		return domain.CodeInternal
+	}
+}
+
+func withDetails(st *status.Status, details ...protoadapt.MessageV1) *status.Status {
+	detailed, err := st.WithDetails(details...)
+	if err != nil {
+		return st
+	}
+	return detailed
+}
@@ -0,0 +1,76 @@
+package grpcerr
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"ozon_task/domain"
+)
+
+func errorInfo(t *testing.T, st *status.Status) *errdetails.ErrorInfo {
+	t.Helper()
+
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info
+		}
+	}
+	require.FailNow(t, "no ErrorInfo in details", st.Details())
+	return nil
+}
+
+func TestStatus_InvalidArgument(t *testing.T) {
+	t.Parallel()
+
+	st := Status(fmt.Errorf("Service.CreateLink: %w", domain.ErrInvalidTags))
+
+	require.Equal(t, codes.InvalidArgument, st.Code())
+	require.Equal(t, domain.ErrInvalidTags.Error(), st.Message())
+	require.Equal(t, string(domain.CodeInvalidTags), errorInfo(t, st).GetReason())
+	require.Equal(t, Domain, errorInfo(t, st).GetDomain())
+
+	var badRequest *errdetails.BadRequest
+	for _, detail := range st.Details() {
+		if br, ok := detail.(*errdetails.BadRequest); ok {
+			badRequest = br
+		}
+	}
+	require.NotNil(t, badRequest)
+	require.Len(t, badRequest.GetFieldViolations(), 1)
+	require.Equal(t, "tags", badRequest.GetFieldViolations()[0].GetField())
+}
+
+func TestStatus_HidesInternalErrors(t *testing.T) {
+	t.Parallel()
+
+	st := Status(errors.New("password authentication failed"))
+
+	require.Equal(t, codes.Internal, st.Code())
+	require.NotContains(t, st.Message(), "password")
+	require.Equal(t, string(domain.CodeInternal), errorInfo(t, st).GetReason())
+}
+
+func TestWithRequestID(t *testing.T) {
+	t.Parallel()
+
+	err := WithRequestID(Error(domain.ErrLinkExpired), "req-1")
+	st := status.Convert(err)
+	require.Equal(t, codes.NotFound, st.Code())
+	require.Equal(t, string(domain.CodeExpired), errorInfo(t, st).GetReason())
+	require.Equal(t, "req-1", errorInfo(t, st).GetMetadata()[RequestIDKey])
+
+	// statuses of the transport don't have ErrorInfo
+	err = WithRequestID(status.Error(codes.Unavailable, "transport is closing"), "req-2")
+	st = status.Convert(err)
+	require.Equal(t, codes.Unavailable, st.Code())
+	require.Equal(t, "transport is closing", st.Message())
+	require.Equal(t, "req-2", errorInfo(t, st).GetMetadata()[RequestIDKey])
+
+	require.NoError(t, WithRequestID(nil, "req-3"))
+}
@@ -0,0 +1,74 @@
+package interceptors
+
+import (
+	"context"
+	"log/slog"
+	"ozon_task/domain"
+	"ozon_task/internal/auth"
+	"ozon_task/internal/grpc/grpcerr"
+	pkglog "ozon_task/pkg/log"
+
+	middleware "github.com/grpc-ecosystem/go-grpc-middleware/v2"
+	"google.golang.org/grpc"
+)
+
+const authorizationHeader = "authorization"
+
+// AuthUnaryServerInterceptor authenticates `authorization: Bearer` metadata
+// and checks rule of the called method. Methods missing in the policy require admin scope.
+func AuthUnaryServerInterceptor(
+	log *slog.Logger,
+	authorizer *auth.Authorizer,
+	policy map[string]auth.Rule,
+) grpc.UnaryServerInterceptor {
+	const op = "interceptors.Auth"
+	log = log.With(slog.String("op", op))
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		rule, ok := policy[info.FullMethod]
+		if !ok {
+			rule = auth.Rule{Scope: domain.ScopeAdmin}
+		}
+
+		ctx, err := authorizer.Authorize(ctx, metadataValue(ctx, authorizationHeader), rule)
+		if err != nil {
+			log.Warn("request rejected", slog.String("method", info.FullMethod), pkglog.Err(err))
+			return nil, authError(err)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// AuthStreamServerInterceptor checks the same policy as AuthUnaryServerInterceptor once per stream
+// and passes the authenticated principal in the context of the stream.
+func AuthStreamServerInterceptor(
+	log *slog.Logger,
+	authorizer *auth.Authorizer,
+	policy map[string]auth.Rule,
+) grpc.StreamServerInterceptor {
+	const op = "interceptors.AuthStream"
+	log = log.With(slog.String("op", op))
+
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		rule, ok := policy[info.FullMethod]
+		if !ok {
+			rule = auth.Rule{Scope: domain.ScopeAdmin}
+		}
+
+		ctx := stream.Context()
+		ctx, err := authorizer.Authorize(ctx, metadataValue(ctx, authorizationHeader), rule)
+		if err != nil {
+			log.Warn("stream rejected", slog.String("method", info.FullMethod), pkglog.Err(err))
+			return authError(err)
+		}
+
+		wrapped := middleware.WrapServerStream(stream)
+		wrapped.WrappedContext = ctx
+		return handler(srv, wrapped)
+	}
+}
+
+func authError(err error) error {
+	return grpcerr.Error(err)
+}
@@ -0,0 +1,146 @@
+package interceptors
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"ozon_task/domain"
+	"ozon_task/internal/grpc/grpcerr"
+	"ozon_task/internal/idempotency"
+	pkglog "ozon_task/pkg/log"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	idempotencyKeyHeader     = "idempotency-key"
+	idempotentReplayedHeader = "idempotent-replayed"
+	idempotencyStoreTimeout  = 3 * time.Second
+	protoContentType         = "application/grpc+proto"
+	// statusContentType marks stored errors, their body is google.rpc.Status
+	statusContentType = "application/grpc-status+proto"
+)
+
+// IdempotencyUnaryServerInterceptor replays stored responses for calls with `idempotency-key` metadata.
+// Policy maps supported methods to constructors of their responses. Must be chained after auth interceptor.
+func IdempotencyUnaryServerInterceptor(
+	log *slog.Logger,
+	keeper *idempotency.Keeper,
+	policy map[string]func() proto.Message,
+) grpc.UnaryServerInterceptor {
+	const op = "interceptors.Idempotency"
+	log = log.With(slog.String("op", op))
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		newResponse, ok := policy[info.FullMethod]
+		if !ok || !keeper.Enabled() {
+			return handler(ctx, req)
+		}
+
+		key := metadataValue(ctx, idempotencyKeyHeader)
+		if len(key) == 0 {
+			return handler(ctx, req)
+		}
+
+		payload, err := proto.MarshalOptions{Deterministic: true}.Marshal(req.(proto.Message))
+		if err != nil {
+			return nil, grpcerr.Error(err)
+		}
+
+		fingerprint := idempotency.Fingerprint([]byte(info.FullMethod), payload)
+		record, replay, err := keeper.Begin(ctx, key, fingerprint)
+		if err != nil {
+			log.Warn("idempotency key rejected", slog.String("method", info.FullMethod), pkglog.Err(err))
+			return nil, idempotencyError(err)
+		}
+
+		if replay {
+			res, callErr, err := replayed(record, newResponse)
+			if err != nil {
+				log.Error("failed to unmarshal stored response", pkglog.Err(err))
+				return nil, grpcerr.Error(err)
+			}
+			_ = grpc.SetHeader(ctx, metadata.Pairs(idempotentReplayedHeader, "true"))
+			return res, callErr
+		}
+
+		storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), idempotencyStoreTimeout)
+		defer cancel()
+
+		res, callErr := handler(ctx, req)
+
+		// errors are stored like responses of HTTP handlers with the matching status,
+		// transient ones are not and such calls can be retried with the same key
+		record = domain.IdempotencyRecord{Fingerprint: fingerprint, StatusCode: http.StatusOK}
+		if callErr != nil {
+			st := status.Convert(callErr)
+			record.StatusCode = runtime.HTTPStatusFromCode(st.Code())
+			if !idempotency.Storable(record.StatusCode) {
+				release(storeCtx, log, keeper, key)
+				return nil, callErr
+			}
+			record.ContentType = statusContentType
+			record.Body, err = proto.Marshal(st.Proto())
+		} else {
+			record.ContentType = protoContentType
+			record.Body, err = proto.Marshal(res.(proto.Message))
+		}
+		if err == nil {
+			err = keeper.Complete(storeCtx, key, record)
+		}
+		if err != nil {
+			log.Error("failed to store idempotent response", pkglog.Err(err))
+			release(storeCtx, log, keeper, key)
+		}
+
+		return res, callErr
+	}
+}
+
+// replayed restores stored response or error of the call.
+func replayed(
+	record domain.IdempotencyRecord,
+	newResponse func() proto.Message,
+) (res proto.Message, callErr error, err error) {
+	if record.ContentType == statusContentType {
+		var st spb.Status
+		if err = proto.Unmarshal(record.Body, &st); err != nil {
+			return nil, nil, err
+		}
+		return nil, status.ErrorProto(&st), nil
+	}
+
+	res = newResponse()
+	if err = proto.Unmarshal(record.Body, res); err != nil {
+		return nil, nil, err
+	}
+	return res, nil, nil
+}
+
+func release(ctx context.Context, log *slog.Logger, keeper *idempotency.Keeper, key string) {
+	if err := keeper.Release(ctx, key); err != nil {
+		log.Error("failed to release idempotency key", pkglog.Err(err))
+	}
+}
+
+func metadataValue(ctx context.Context, name string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	if values := md.Get(name); len(values) != 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func idempotencyError(err error) error {
+	return grpcerr.Error(err)
+}
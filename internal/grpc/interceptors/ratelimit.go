@@ -0,0 +1,139 @@
+package interceptors
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net"
+	"ozon_task/internal/grpc/grpcerr"
+	"ozon_task/internal/ratelimit"
+	"ozon_task/internal/usecases"
+	pkggrpc "ozon_task/pkg/grpc"
+	pkglog "ozon_task/pkg/log"
+	pkgratelimit "ozon_task/pkg/ratelimit"
+	"strconv"
+	"time"
+
+	middleware "github.com/grpc-ecosystem/go-grpc-middleware/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+const forwardedForHeader = "x-forwarded-for"
+
+// RateLimitUnaryServerInterceptor limits calls of methods from the policy per client
+// and reports limits in `ratelimit-*` response headers. Daily quota reserved for the call is spent
+// only if the call creates a link. Must be chained after auth interceptor.
+func RateLimitUnaryServerInterceptor(
+	log *slog.Logger,
+	limiter *ratelimit.Limiter,
+	policy map[string]ratelimit.Operation,
+) grpc.UnaryServerInterceptor {
+	const op = "interceptors.RateLimit"
+	log = log.With(slog.String("op", op))
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		operation, ok := policy[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		ctx, res, err := limiter.Check(ctx, operation, peerIP(ctx, limiter))
+		if md := rateLimitMetadata(res, err != nil); md != nil {
+			// headers are best effort, failure to send them must not fail the call
+			_ = grpc.SetHeader(ctx, md)
+		}
+
+		if err != nil {
+			log.Warn("request rejected", slog.String("method", info.FullMethod), pkglog.Err(err))
+			return nil, rateLimitError(err)
+		}
+		ctx = countCreatedLinks(ctx)
+		defer limiter.Release(ctx)
+
+		return handler(ctx, req)
+	}
+}
+
+// RateLimitStreamServerInterceptor counts opening of a stream as one call of its operation.
+// Daily quota isn't taken on opening, handlers charge it per item with ratelimit.ReserveItem.
+// Must be chained after auth interceptor.
+func RateLimitStreamServerInterceptor(
+	log *slog.Logger,
+	limiter *ratelimit.Limiter,
+	policy map[string]ratelimit.Operation,
+) grpc.StreamServerInterceptor {
+	const op = "interceptors.RateLimitStream"
+	log = log.With(slog.String("op", op))
+
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		operation, ok := policy[info.FullMethod]
+		if !ok {
+			return handler(srv, stream)
+		}
+
+		clientIP := peerIP(stream.Context(), limiter)
+		res, err := limiter.Allow(stream.Context(), operation, clientIP)
+		if md := rateLimitMetadata(res, err != nil); md != nil {
+			_ = stream.SetHeader(md)
+		}
+
+		if err != nil {
+			log.Warn("stream rejected", slog.String("method", info.FullMethod), pkglog.Err(err))
+			return rateLimitError(err)
+		}
+
+		wrapped := middleware.WrapServerStream(stream)
+		wrapped.WrappedContext = countCreatedLinks(limiter.WithStreamQuota(stream.Context(), clientIP))
+		return handler(srv, wrapped)
+	}
+}
+
+// countCreatedLinks spends the quota reserved for a link once the link is created with the context,
+// reservations of calls which return existing links are refunded by Release.
+func countCreatedLinks(ctx context.Context) context.Context {
+	return usecases.WithLinkCreatedHook(ctx, ratelimit.SpendReservation)
+}
+
+func rateLimitMetadata(res pkgratelimit.Result, rejected bool) metadata.MD {
+	if res.Limit == 0 {
+		return nil
+	}
+
+	md := metadata.Pairs(
+		"ratelimit-limit", strconv.Itoa(res.Limit),
+		"ratelimit-remaining", strconv.Itoa(res.Remaining),
+		"ratelimit-reset", ceilSeconds(res.ResetAfter),
+	)
+	if rejected {
+		md.Set("retry-after", ceilSeconds(res.RetryAfter))
+	}
+
+	return md
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// peerIP returns address of the client. Calls of the gateway of the same process and of trusted proxies
+// carry address of the HTTP client in `x-forwarded-for`.
+func peerIP(ctx context.Context, limiter *ratelimit.Limiter) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	_, gateway := p.Addr.(pkggrpc.InProcessAddr)
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+
+	return limiter.ClientIP(host, gateway, metadata.ValueFromIncomingContext(ctx, forwardedForHeader))
+}
+
+func rateLimitError(err error) error {
+	return grpcerr.Error(err)
+}
@@ -0,0 +1,45 @@
+package interceptors
+
+import (
+	"context"
+	"ozon_task/internal/grpc/grpcerr"
+	"ozon_task/pkg/requestid"
+
+	middleware "github.com/grpc-ecosystem/go-grpc-middleware/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const requestIDHeader = "x-request-id"
+
+// RequestIDUnaryServerInterceptor takes id of the request from `x-request-id` metadata or generates it,
+// returns it in the header of the response and in ErrorInfo details of errors.
+func RequestIDUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, id := withRequestID(ctx)
+
+		res, err := handler(ctx, req)
+		return res, grpcerr.WithRequestID(err, id)
+	}
+}
+
+// RequestIDStreamServerInterceptor is the same as RequestIDUnaryServerInterceptor for streams.
+func RequestIDStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, id := withRequestID(stream.Context())
+
+		wrapped := middleware.WrapServerStream(stream)
+		wrapped.WrappedContext = ctx
+		return grpcerr.WithRequestID(handler(srv, wrapped), id)
+	}
+}
+
+func withRequestID(ctx context.Context) (context.Context, string) {
+	id := metadataValue(ctx, requestIDHeader)
+	if len(id) == 0 {
+		id = requestid.New()
+	}
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDHeader, id))
+	return requestid.With(ctx, id), id
+}
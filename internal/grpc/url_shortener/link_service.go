@@ -0,0 +1,251 @@
+package url_shortener
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"ozon_task/domain"
+	"ozon_task/internal/usecases"
+	pkglog "ozon_task/pkg/log"
+	urlshortenerv2 "ozon_task/protos/gen/go/shortener/v2"
+	"time"
+
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// linkServiceAPI serves resource-oriented shortener.v2 api over the same service as v1.
+type linkServiceAPI struct {
+	urlshortenerv2.UnimplementedLinkServiceServer
+	service           usecases.URL
+	operationsTimeout time.Duration
+	maxOriginalLength int
+	logger            *slog.Logger
+}
+
+func (s *linkServiceAPI) CreateLink(
+	ctx context.Context,
+	req *urlshortenerv2.CreateLinkRequest,
+) (*urlshortenerv2.Link, error) {
+	const op = "linkServiceAPI.CreateLink"
+	log := s.logger.With(
+		slog.String("op", op),
+	)
+
+	spec := domain.Link{
+		Original: domain.NormalizeURL(req.GetLink().GetOriginalUrl()),
+		Tags:     req.GetLink().GetTags(),
+	}
+	if req.GetLink().GetExpireTime() != nil {
+		spec.ExpiresAt = req.GetLink().GetExpireTime().AsTime()
+	}
+
+	if ok, err := domain.IsValidOriginalURL(spec.Original, s.maxOriginalLength); !ok {
+		log.Error("error while validating req", pkglog.Err(err))
+		return nil, handleError(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.operationsTimeout)
+	defer cancel()
+
+	link, err := s.service.CreateLink(ctx, spec)
+	if err != nil {
+		log.Error("failed to create link", pkglog.Err(err))
+		return nil, handleError(err)
+	}
+
+	return newLinkV2(link), nil
+}
+
+func (s *linkServiceAPI) GetLink(
+	ctx context.Context,
+	req *urlshortenerv2.GetLinkRequest,
+) (*urlshortenerv2.Link, error) {
+	const op = "linkServiceAPI.GetLink"
+	log := s.logger.With(
+		slog.String("op", op),
+	)
+
+	if ok, err := domain.IsValidShortenedURL(req.GetShortenedUrl()); !ok {
+		log.Error("error while validating req", pkglog.Err(err))
+		return nil, handleError(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.operationsTimeout)
+	defer cancel()
+
+	link, err := s.service.GetLink(ctx, req.GetShortenedUrl())
+	if err != nil {
+		log.Error("failed to get link", pkglog.Err(err))
+		return nil, handleError(err)
+	}
+
+	return newLinkV2(link), nil
+}
+
+func (s *linkServiceAPI) ListLinks(
+	ctx context.Context,
+	req *urlshortenerv2.ListLinksRequest,
+) (*urlshortenerv2.ListLinksResponse, error) {
+	const op = "linkServiceAPI.ListLinks"
+	log := s.logger.With(
+		slog.String("op", op),
+	)
+
+	filter := domain.LinkFilter{
+		OwnerID: req.GetOwnerId(),
+		Host:    req.GetHost(),
+		Tag:     req.GetTag(),
+		Search:  req.GetQuery(),
+	}
+	if req.GetCreatedAfter() != nil {
+		filter.CreatedAfter = req.GetCreatedAfter().AsTime()
+	}
+	if req.GetCreatedBefore() != nil {
+		filter.CreatedBefore = req.GetCreatedBefore().AsTime()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.operationsTimeout)
+	defer cancel()
+
+	page, err := s.service.ListLinks(ctx, filter, req.GetPageToken(), int(req.GetPageSize()))
+	if err != nil {
+		log.Error("failed to list links", pkglog.Err(err))
+		return nil, handleError(err)
+	}
+
+	res := &urlshortenerv2.ListLinksResponse{
+		Links:         make([]*urlshortenerv2.Link, 0, len(page.Links)),
+		NextPageToken: page.NextCursor,
+	}
+	for _, link := range page.Links {
+		res.Links = append(res.Links, newLinkV2(link))
+	}
+
+	return res, nil
+}
+
+func (s *linkServiceAPI) UpdateLink(
+	ctx context.Context,
+	req *urlshortenerv2.UpdateLinkRequest,
+) (*urlshortenerv2.Link, error) {
+	const op = "linkServiceAPI.UpdateLink"
+	log := s.logger.With(
+		slog.String("op", op),
+	)
+
+	if ok, err := domain.IsValidShortenedURL(req.GetLink().GetShortenedUrl()); !ok {
+		log.Error("error while validating req", pkglog.Err(err))
+		return nil, handleError(err)
+	}
+
+	update, err := newLinkUpdate(req.GetLink(), req.GetUpdateMask(), s.maxOriginalLength)
+	if err != nil {
+		log.Error("error while validating req", pkglog.Err(err))
+		return nil, handleError(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.operationsTimeout)
+	defer cancel()
+
+	link, err := s.service.UpdateLink(ctx, req.GetLink().GetShortenedUrl(), update)
+	if err != nil {
+		log.Error("failed to update link", pkglog.Err(err))
+		return nil, handleError(err)
+	}
+
+	return newLinkV2(link), nil
+}
+
+func (s *linkServiceAPI) DeleteLink(
+	ctx context.Context,
+	req *urlshortenerv2.DeleteLinkRequest,
+) (*emptypb.Empty, error) {
+	const op = "linkServiceAPI.DeleteLink"
+	log := s.logger.With(
+		slog.String("op", op),
+	)
+
+	if ok, err := domain.IsValidShortenedURL(req.GetShortenedUrl()); !ok {
+		log.Error("error while validating req", pkglog.Err(err))
+		return nil, handleError(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.operationsTimeout)
+	defer cancel()
+
+	if err := s.service.DeleteLink(ctx, req.GetShortenedUrl()); err != nil {
+		log.Error("failed to delete link", pkglog.Err(err))
+		return nil, handleError(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// newLinkUpdate picks fields of the link listed in the mask, or populated fields if the mask is empty.
+func newLinkUpdate(
+	link *urlshortenerv2.Link,
+	mask *fieldmaskpb.FieldMask,
+	maxOriginalLength int,
+) (domain.LinkUpdate, error) {
+	paths := mask.GetPaths()
+	if len(paths) == 0 {
+		if len(link.GetOriginalUrl()) != 0 {
+			paths = append(paths, "original_url")
+		}
+		if len(link.GetTags()) != 0 {
+			paths = append(paths, "tags")
+		}
+		if link.GetExpireTime() != nil {
+			paths = append(paths, "expire_time")
+		}
+	}
+
+	var update domain.LinkUpdate
+	for _, path := range paths {
+		switch path {
+		case "original_url":
+			original := domain.NormalizeURL(link.GetOriginalUrl())
+			if ok, err := domain.IsValidOriginalURL(original, maxOriginalLength); !ok {
+				return domain.LinkUpdate{}, fmt.Errorf("newLinkUpdate: %w", err)
+			}
+			update.Original = &original
+		case "tags":
+			tags := link.GetTags()
+			update.Tags = &tags
+		case "expire_time":
+			var expiresAt time.Time
+			if link.GetExpireTime() != nil {
+				expiresAt = link.GetExpireTime().AsTime()
+			}
+			update.ExpiresAt = &expiresAt
+		default:
+			return domain.LinkUpdate{}, fmt.Errorf("newLinkUpdate: field %q can't be updated: %w",
+				path, domain.ErrInvalidLinkUpdate)
+		}
+	}
+
+	if update.Empty() {
+		return domain.LinkUpdate{}, fmt.Errorf("newLinkUpdate: no fields to update: %w", domain.ErrInvalidLinkUpdate)
+	}
+
+	return update, nil
+}
+
+func newLinkV2(link domain.Link) *urlshortenerv2.Link {
+	res := &urlshortenerv2.Link{
+		ShortenedUrl: link.Shortened,
+		OriginalUrl:  link.Original,
+		OwnerId:      link.OwnerID,
+		Tags:         link.Tags,
+		Version:      int32(link.Version),
+		ClickCount:   link.Clicks,
+		CreateTime:   timestamppb.New(link.CreatedAt),
+	}
+	if !link.ExpiresAt.IsZero() {
+		res.ExpireTime = timestamppb.New(link.ExpiresAt)
+	}
+
+	return res
+}
@@ -2,35 +2,44 @@ package url_shortener
 
 import (
 	"context"
-	"errors"
 	"log/slog"
 	"ozon_task/domain"
+	"ozon_task/internal/grpc/grpcerr"
 	"ozon_task/internal/usecases"
-	pkgerr "ozon_task/pkg/error"
 	pkglog "ozon_task/pkg/log"
 	urlshortenerv1 "ozon_task/protos/gen/go"
+	urlshortenerv2 "ozon_task/protos/gen/go/shortener/v2"
 	"time"
 
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type gRPCServerAPI struct {
 	urlshortenerv1.UnimplementedURLShortenerServer
 	service           usecases.URL
 	operationsTimeout time.Duration
+	// maxOriginalLength limits original urls of requests, see domain.IsValidOriginalURL
+	maxOriginalLength int
 	logger            *slog.Logger
 }
 
 func Register(gRPC *grpc.Server,
 	service usecases.URL,
 	operationsTimeout time.Duration,
+	maxOriginalLength int,
 	logger *slog.Logger,
 ) {
 	urlshortenerv1.RegisterURLShortenerServer(gRPC, &gRPCServerAPI{
 		service:           service,
 		operationsTimeout: operationsTimeout,
+		maxOriginalLength: maxOriginalLength,
+		logger:            logger,
+	})
+	urlshortenerv2.RegisterLinkServiceServer(gRPC, &linkServiceAPI{
+		service:           service,
+		operationsTimeout: operationsTimeout,
+		maxOriginalLength: maxOriginalLength,
 		logger:            logger,
 	})
 }
@@ -46,22 +55,22 @@ func (s *gRPCServerAPI) ShortenURL(
 
 	originalURL := domain.NormalizeURL(req.GetOriginalUrl())
 
-	if ok, err := domain.IsValidOriginalURL(originalURL); !ok {
+	if ok, err := domain.IsValidOriginalURL(originalURL, s.maxOriginalLength); !ok {
 		log.Error("error while validating req", pkglog.Err(err))
-		return nil, s.handleError(err)
+		return nil, handleError(err)
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, s.operationsTimeout)
 	defer cancel()
 
-	shortened, err := s.service.ShortenURL(ctx, originalURL)
+	link, err := s.service.CreateLink(ctx, domain.Link{Original: originalURL, Tags: req.GetTags()})
 	if err != nil {
 		log.Error("failed to generate shortened url", pkglog.Err(err))
-		return nil, s.handleError(err)
+		return nil, handleError(err)
 	}
 
 	return &urlshortenerv1.ShortenURLResponse{
-		ShortenedUrl: shortened,
+		ShortenedUrl: link.Shortened,
 	}, nil
 }
 
@@ -76,7 +85,7 @@ func (s *gRPCServerAPI) ResolveURL(
 
 	if ok, err := domain.IsValidShortenedURL(req.GetShortenedUrl()); !ok {
 		log.Error("error while validating req", pkglog.Err(err))
-		return nil, s.handleError(err)
+		return nil, handleError(err)
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, s.operationsTimeout)
@@ -85,7 +94,7 @@ func (s *gRPCServerAPI) ResolveURL(
 	original, err := s.service.ResolveURL(ctx, req.GetShortenedUrl())
 	if err != nil {
 		log.Error("failed to get original url", pkglog.Err(err))
-		return nil, s.handleError(err)
+		return nil, handleError(err)
 	}
 
 	return &urlshortenerv1.ResolveURLResponse{
@@ -93,21 +102,160 @@ func (s *gRPCServerAPI) ResolveURL(
 	}, nil
 }
 
-func (s *gRPCServerAPI) handleError(err error) error {
-	err = pkgerr.UnwrapAll(err)
-
-	switch {
-	case errors.Is(err, context.DeadlineExceeded):
-		return status.Error(codes.DeadlineExceeded, "deadline of operation exceeded")
-	case errors.Is(err, context.Canceled):
-		return status.Error(codes.Canceled, "operation was cancelled")
-	case errors.Is(err, domain.ErrOriginalNotFound),
-		errors.Is(err, domain.ErrShortenedNotFound):
-		return status.Error(codes.NotFound, err.Error())
-	case errors.Is(err, domain.ErrInvalidOriginal),
-		errors.Is(err, domain.ErrInvalidShortened):
-		return status.Error(codes.InvalidArgument, err.Error())
-	default:
-		return status.Error(codes.Internal, "internal server error")
+func (s *gRPCServerAPI) ListLinks(
+	ctx context.Context,
+	req *urlshortenerv1.ListLinksRequest,
+) (*urlshortenerv1.ListLinksResponse, error) {
+	const op = "gRPCServerAPI.ListLinks"
+	log := s.logger.With(
+		slog.String("op", op),
+	)
+
+	filter := domain.LinkFilter{
+		OwnerID: req.GetOwnerId(),
+		Host:    req.GetHost(),
+		Tag:     req.GetTag(),
+		Search:  req.GetQuery(),
+	}
+	if req.GetCreatedAfter() != nil {
+		filter.CreatedAfter = req.GetCreatedAfter().AsTime()
+	}
+	if req.GetCreatedBefore() != nil {
+		filter.CreatedBefore = req.GetCreatedBefore().AsTime()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.operationsTimeout)
+	defer cancel()
+
+	page, err := s.service.ListLinks(ctx, filter, req.GetPageToken(), int(req.GetPageSize()))
+	if err != nil {
+		log.Error("failed to list links", pkglog.Err(err))
+		return nil, handleError(err)
+	}
+
+	res := &urlshortenerv1.ListLinksResponse{
+		Links:         make([]*urlshortenerv1.Link, 0, len(page.Links)),
+		NextPageToken: page.NextCursor,
+	}
+	for _, link := range page.Links {
+		res.Links = append(res.Links, newLink(link))
+	}
+
+	return res, nil
+}
+
+func (s *gRPCServerAPI) UpdateLink(
+	ctx context.Context,
+	req *urlshortenerv1.UpdateLinkRequest,
+) (*urlshortenerv1.UpdateLinkResponse, error) {
+	const op = "gRPCServerAPI.UpdateLink"
+	log := s.logger.With(
+		slog.String("op", op),
+	)
+
+	if ok, err := domain.IsValidShortenedURL(req.GetShortenedUrl()); !ok {
+		log.Error("error while validating req", pkglog.Err(err))
+		return nil, handleError(err)
+	}
+
+	originalURL := domain.NormalizeURL(req.GetOriginalUrl())
+
+	if ok, err := domain.IsValidOriginalURL(originalURL, s.maxOriginalLength); !ok {
+		log.Error("error while validating req", pkglog.Err(err))
+		return nil, handleError(err)
 	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.operationsTimeout)
+	defer cancel()
+
+	link, err := s.service.UpdateLink(ctx, req.GetShortenedUrl(), domain.LinkUpdate{Original: &originalURL})
+	if err != nil {
+		log.Error("failed to update link", pkglog.Err(err))
+		return nil, handleError(err)
+	}
+
+	return &urlshortenerv1.UpdateLinkResponse{
+		Link: newLink(link),
+	}, nil
+}
+
+func (s *gRPCServerAPI) ListLinkVersions(
+	ctx context.Context,
+	req *urlshortenerv1.ListLinkVersionsRequest,
+) (*urlshortenerv1.ListLinkVersionsResponse, error) {
+	const op = "gRPCServerAPI.ListLinkVersions"
+	log := s.logger.With(
+		slog.String("op", op),
+	)
+
+	if ok, err := domain.IsValidShortenedURL(req.GetShortenedUrl()); !ok {
+		log.Error("error while validating req", pkglog.Err(err))
+		return nil, handleError(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.operationsTimeout)
+	defer cancel()
+
+	versions, err := s.service.ListLinkVersions(ctx, req.GetShortenedUrl())
+	if err != nil {
+		log.Error("failed to list link versions", pkglog.Err(err))
+		return nil, handleError(err)
+	}
+
+	res := &urlshortenerv1.ListLinkVersionsResponse{
+		Versions: make([]*urlshortenerv1.LinkVersion, 0, len(versions)),
+	}
+	for _, version := range versions {
+		res.Versions = append(res.Versions, &urlshortenerv1.LinkVersion{
+			Version:     int32(version.Version),
+			OriginalUrl: version.Original,
+			Author:      version.Author,
+			CreatedAt:   timestamppb.New(version.CreatedAt),
+		})
+	}
+
+	return res, nil
+}
+
+func (s *gRPCServerAPI) RollbackLink(
+	ctx context.Context,
+	req *urlshortenerv1.RollbackLinkRequest,
+) (*urlshortenerv1.RollbackLinkResponse, error) {
+	const op = "gRPCServerAPI.RollbackLink"
+	log := s.logger.With(
+		slog.String("op", op),
+	)
+
+	if ok, err := domain.IsValidShortenedURL(req.GetShortenedUrl()); !ok {
+		log.Error("error while validating req", pkglog.Err(err))
+		return nil, handleError(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.operationsTimeout)
+	defer cancel()
+
+	link, err := s.service.RollbackLink(ctx, req.GetShortenedUrl(), int(req.GetVersion()))
+	if err != nil {
+		log.Error("failed to rollback link", pkglog.Err(err))
+		return nil, handleError(err)
+	}
+
+	return &urlshortenerv1.RollbackLinkResponse{
+		Link: newLink(link),
+	}, nil
+}
+
+func newLink(link domain.Link) *urlshortenerv1.Link {
+	return &urlshortenerv1.Link{
+		OriginalUrl:  link.Original,
+		ShortenedUrl: link.Shortened,
+		OwnerId:      link.OwnerID,
+		Tags:         link.Tags,
+		CreatedAt:    timestamppb.New(link.CreatedAt),
+		Version:      int32(link.Version),
+	}
+}
+
+func handleError(err error) error {
+	return grpcerr.Error(err)
 }
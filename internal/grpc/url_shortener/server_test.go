@@ -0,0 +1,56 @@
+package url_shortener
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"ozon_task/domain"
+	"ozon_task/internal/grpc/grpcerr"
+	"ozon_task/internal/usecases/mocks"
+	urlshortenerv1 "ozon_task/protos/gen/go"
+)
+
+func TestServer_TooLongURL(t *testing.T) {
+	t.Parallel()
+	mockService := new(mocks.URL)
+	client := newLimitedTestClient(t, mockService, 32)
+	ctx := context.Background()
+
+	tooLong := "https://ozon.ru/" + strings.Repeat("a", 17)
+	allowed := "https://ozon.ru/" + strings.Repeat("a", 16)
+	mockService.On("CreateLink", mock.Anything, domain.Link{Original: allowed}).
+		Return(domain.Link{Original: allowed, Shortened: "abcdefghij"}, nil)
+
+	requireInvalidOriginal := func(err error) {
+		t.Helper()
+		st := status.Convert(err)
+		require.Equal(t, codes.InvalidArgument, st.Code())
+		require.Equal(t, domain.CodeInvalidOriginal, grpcerr.Reason(st))
+	}
+
+	// the limit is checked before the service is called
+	_, err := client.ShortenURL(ctx, &urlshortenerv1.ShortenURLRequest{OriginalUrl: tooLong})
+	requireInvalidOriginal(err)
+	_, err = client.UpdateLink(ctx, &urlshortenerv1.UpdateLinkRequest{ShortenedUrl: "abcdefghij", OriginalUrl: tooLong})
+	requireInvalidOriginal(err)
+
+	stream, err := client.StreamShorten(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&urlshortenerv1.ShortenURLRequest{OriginalUrl: tooLong}))
+	require.NoError(t, stream.CloseSend())
+	item, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, int32(codes.InvalidArgument), item.GetStatus().GetCode())
+
+	res, err := client.ShortenURL(ctx, &urlshortenerv1.ShortenURLRequest{OriginalUrl: allowed})
+	require.NoError(t, err)
+	require.Equal(t, "abcdefghij", res.GetShortenedUrl())
+
+	mockService.AssertExpectations(t)
+}
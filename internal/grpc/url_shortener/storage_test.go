@@ -0,0 +1,42 @@
+package url_shortener
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"ozon_task/domain"
+	"ozon_task/internal/repository/postgres/pgtest"
+	"ozon_task/internal/usecases/service"
+	urlshortenerv1 "ozon_task/protos/gen/go"
+)
+
+func TestShortenURL_StorageUnavailable(t *testing.T) {
+	t.Parallel()
+
+	client := newTestClient(t, service.NewURLService(pgtest.UnavailableURLRepository(t)))
+
+	_, err := client.ShortenURL(context.Background(), &urlshortenerv1.ShortenURLRequest{OriginalUrl: "https://ozon.ru"})
+
+	st := status.Convert(err)
+	require.Equal(t, codes.Unavailable, st.Code())
+
+	var (
+		info  *errdetails.ErrorInfo
+		retry *errdetails.RetryInfo
+	)
+	for _, detail := range st.Details() {
+		switch detail := detail.(type) {
+		case *errdetails.ErrorInfo:
+			info = detail
+		case *errdetails.RetryInfo:
+			retry = detail
+		}
+	}
+	require.Equal(t, string(domain.CodeStorageUnavailable), info.GetReason())
+	require.Equal(t, domain.TransientRetryAfter, retry.GetRetryDelay().AsDuration())
+}
@@ -0,0 +1,174 @@
+package url_shortener
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"ozon_task/domain"
+	"ozon_task/internal/grpc/grpcerr"
+	"ozon_task/internal/ratelimit"
+	pkglog "ozon_task/pkg/log"
+	"ozon_task/pkg/requestid"
+	urlshortenerv1 "ozon_task/protos/gen/go"
+
+	"golang.org/x/sync/errgroup"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// streamShortenWindow bounds number of urls shortened concurrently within one stream.
+// Requests aren't read further while the oldest result isn't sent, so a slow client
+// holds back the sender through gRPC flow control.
+const streamShortenWindow = 16
+
+func (s *gRPCServerAPI) StreamShorten(stream urlshortenerv1.URLShortener_StreamShortenServer) error {
+	const op = "gRPCServerAPI.StreamShorten"
+	log := s.logger.With(
+		slog.String("op", op),
+	)
+
+	g, ctx := errgroup.WithContext(stream.Context())
+	// results are queued in order of requests
+	pending := make(chan chan *urlshortenerv1.StreamShortenResponse, streamShortenWindow)
+	// quotaErr stops reading once daily quota is exhausted, urls read before it are still answered
+	var quotaErr error
+
+	g.Go(func() error {
+		defer close(pending)
+
+		for {
+			req, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			itemCtx, release, err := ratelimit.ReserveItem(ctx)
+			if err != nil {
+				quotaErr = err
+				return nil
+			}
+
+			result := make(chan *urlshortenerv1.StreamShortenResponse, 1)
+			select {
+			case pending <- result:
+			case <-ctx.Done():
+				release()
+				return ctx.Err()
+			}
+
+			go func() {
+				defer release()
+				result <- s.shortenItem(itemCtx, req)
+			}()
+		}
+	})
+
+	g.Go(func() error {
+		for result := range pending {
+			select {
+			case res := <-result:
+				if err := stream.Send(res); err != nil {
+					return err
+				}
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+
+	if err := g.Wait(); err != nil {
+		log.Error("stream was interrupted", pkglog.Err(err))
+		return streamError(err)
+	}
+	if quotaErr != nil {
+		log.Warn("stream stopped", pkglog.Err(quotaErr))
+		return handleError(quotaErr)
+	}
+
+	return nil
+}
+
+// shortenItem shortens one url of the stream, failures are reported in the status of the result.
+func (s *gRPCServerAPI) shortenItem(
+	ctx context.Context,
+	req *urlshortenerv1.ShortenURLRequest,
+) *urlshortenerv1.StreamShortenResponse {
+	res := &urlshortenerv1.StreamShortenResponse{OriginalUrl: req.GetOriginalUrl()}
+
+	original := domain.NormalizeURL(req.GetOriginalUrl())
+	if ok, err := domain.IsValidOriginalURL(original, s.maxOriginalLength); !ok {
+		res.Status = itemStatus(ctx, err)
+		return res
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.operationsTimeout)
+	defer cancel()
+
+	link, err := s.service.CreateLink(ctx, domain.Link{Original: original, Tags: req.GetTags()})
+	if err != nil {
+		res.Status = itemStatus(ctx, err)
+		return res
+	}
+
+	res.ShortenedUrl = link.Shortened
+	res.Status = status.New(codes.OK, "").Proto()
+	return res
+}
+
+func (s *gRPCServerAPI) ExportLinks(
+	req *urlshortenerv1.ExportLinksRequest,
+	stream urlshortenerv1.URLShortener_ExportLinksServer,
+) error {
+	const op = "gRPCServerAPI.ExportLinks"
+	log := s.logger.With(
+		slog.String("op", op),
+	)
+
+	filter := domain.LinkFilter{
+		OwnerID: req.GetOwnerId(),
+		Host:    req.GetHost(),
+		Tag:     req.GetTag(),
+	}
+	if req.GetCreatedAfter() != nil {
+		filter.CreatedAfter = req.GetCreatedAfter().AsTime()
+	}
+	if req.GetCreatedBefore() != nil {
+		filter.CreatedBefore = req.GetCreatedBefore().AsTime()
+	}
+
+	// Send blocks while the client doesn't read, so pages are fetched no faster than they are consumed
+	var sendErr error
+	err := s.service.ExportLinks(stream.Context(), filter, func(link domain.Link) error {
+		sendErr = stream.Send(newLink(link))
+		return sendErr
+	})
+	if sendErr != nil {
+		log.Error("stream was interrupted", pkglog.Err(sendErr))
+		return streamError(sendErr)
+	}
+	if err != nil {
+		log.Error("failed to export links", pkglog.Err(err))
+		return handleError(err)
+	}
+
+	return nil
+}
+
+// itemStatus reports failure of one url of the stream with id of the stream request.
+func itemStatus(ctx context.Context, err error) *spb.Status {
+	return status.Convert(grpcerr.WithRequestID(handleError(err), requestid.FromContext(ctx))).Proto()
+}
+
+// streamError keeps statuses of transport errors and maps cancellation of the stream context.
+func streamError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return handleError(err)
+}
@@ -0,0 +1,189 @@
+package url_shortener
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"ozon_task/domain"
+	"ozon_task/internal/grpc/interceptors"
+	"ozon_task/internal/ratelimit"
+	"ozon_task/internal/usecases"
+	"ozon_task/internal/usecases/mocks"
+	pkgratelimit "ozon_task/pkg/ratelimit"
+	urlshortenerv1 "ozon_task/protos/gen/go"
+)
+
+var dummyLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func newTestClient(t *testing.T, service usecases.URL, opts ...grpc.ServerOption) urlshortenerv1.URLShortenerClient {
+	t.Helper()
+	return newLimitedTestClient(t, service, 0, opts...)
+}
+
+// newLimitedTestClient returns the client of the server limiting original urls by maxOriginalLength.
+func newLimitedTestClient(
+	t *testing.T,
+	service usecases.URL,
+	maxOriginalLength int,
+	opts ...grpc.ServerOption,
+) urlshortenerv1.URLShortenerClient {
+	t.Helper()
+
+	l := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(opts...)
+	Register(server, service, 5*time.Second, maxOriginalLength, dummyLogger)
+	go func() { _ = server.Serve(l) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return l.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return urlshortenerv1.NewURLShortenerClient(conn)
+}
+
+func TestStreamShorten_KeepsOrder(t *testing.T) {
+	t.Parallel()
+	mockService := new(mocks.URL)
+	client := newTestClient(t, mockService)
+
+	mockService.On("CreateLink", mock.Anything, mock.Anything).
+		Return(func(_ context.Context, spec domain.Link) (domain.Link, error) {
+			// results are ready out of order
+			time.Sleep(time.Duration(rand.IntN(5)) * time.Millisecond)
+			if spec.Original == "https://ozon.ru/fail" {
+				return domain.Link{}, domain.ErrInvalidTags
+			}
+			return domain.Link{Original: spec.Original, Shortened: "s" + spec.Original[len(spec.Original)-9:]}, nil
+		})
+
+	stream, err := client.StreamShorten(context.Background())
+	require.NoError(t, err)
+
+	const count = 100
+	originals := make([]string, 0, count)
+	for i := range count {
+		originals = append(originals, fmt.Sprintf("https://ozon.ru/%09d", i))
+	}
+	originals[10] = "https://ozon"
+	originals[20] = "https://ozon.ru/fail"
+
+	go func() {
+		for _, original := range originals {
+			_ = stream.Send(&urlshortenerv1.ShortenURLRequest{OriginalUrl: original})
+		}
+		_ = stream.CloseSend()
+	}()
+
+	for i, original := range originals {
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, original, res.GetOriginalUrl())
+
+		switch i {
+		case 10, 20:
+			require.Equal(t, int32(codes.InvalidArgument), res.GetStatus().GetCode())
+			require.Empty(t, res.GetShortenedUrl())
+			require.NotEmpty(t, res.GetStatus().GetDetails())
+		default:
+			require.Equal(t, int32(codes.OK), res.GetStatus().GetCode())
+			require.Equal(t, "s"+original[len(original)-9:], res.GetShortenedUrl())
+		}
+	}
+
+	_, err = stream.Recv()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestStreamShorten_ChargesQuotaPerURL(t *testing.T) {
+	t.Parallel()
+	mockService := new(mocks.URL)
+	mockService.On("CreateLink", mock.Anything, mock.Anything).
+		Return(func(ctx context.Context, spec domain.Link) (domain.Link, error) {
+			usecases.LinkCreated(ctx)
+			return domain.Link{Original: spec.Original, Shortened: "s" + spec.Original[len(spec.Original)-9:]}, nil
+		})
+
+	backend := pkgratelimit.NewInMem()
+	limiter := ratelimit.NewLimiter(ratelimit.Config{
+		Enabled:           true,
+		Shorten:           pkgratelimit.Limit{Rate: 1, Period: time.Hour, Burst: 1},
+		DailyShortenQuota: 3,
+	}, backend, backend, dummyLogger)
+	client := newTestClient(t, mockService, grpc.StreamInterceptor(
+		interceptors.RateLimitStreamServerInterceptor(dummyLogger, limiter, map[string]ratelimit.Operation{
+			urlshortenerv1.URLShortener_StreamShorten_FullMethodName: ratelimit.OperationShorten,
+		})))
+
+	stream, err := client.StreamShorten(context.Background())
+	require.NoError(t, err)
+
+	// urls beyond the quota aren't read
+	for i := range 5 {
+		_ = stream.Send(&urlshortenerv1.ShortenURLRequest{OriginalUrl: fmt.Sprintf("https://ozon.ru/%09d", i)})
+	}
+	_ = stream.CloseSend()
+
+	for range 3 {
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, int32(codes.OK), res.GetStatus().GetCode())
+	}
+	_, err = stream.Recv()
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	mockService.AssertNumberOfCalls(t, "CreateLink", 3)
+}
+
+func TestExportLinks(t *testing.T) {
+	t.Parallel()
+	mockService := new(mocks.URL)
+	client := newTestClient(t, mockService)
+
+	links := []domain.Link{
+		{Original: "https://ozon.ru/b", Shortened: "bbbbbbbbbb"},
+		{Original: "https://ozon.ru/a", Shortened: "aaaaaaaaaa"},
+	}
+
+	mockService.On("ExportLinks", mock.Anything, domain.LinkFilter{Tag: "promo"}, mock.Anything).
+		Return(func(_ context.Context, _ domain.LinkFilter, yield func(domain.Link) error) error {
+			for _, link := range links {
+				if err := yield(link); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+	stream, err := client.ExportLinks(context.Background(), &urlshortenerv1.ExportLinksRequest{Tag: "promo"})
+	require.NoError(t, err)
+
+	for _, link := range links {
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, link.Shortened, res.GetShortenedUrl())
+	}
+
+	_, err = stream.Recv()
+	require.ErrorIs(t, err, io.EOF)
+
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,19 @@
+package idempotency
+
+import "time"
+
+// Backends of the idempotency store. Empty backend selects the main storage of the service.
+const (
+	BackendMemory   = "memory"
+	BackendRedis    = "redis"
+	BackendPostgres = "postgres"
+)
+
+type Config struct {
+	Enabled bool          `yaml:"enabled" env-default:"false"`
+	Backend string        `yaml:"backend"`
+	TTL     time.Duration `yaml:"ttl" env-default:"24h"`
+	// LockTTL limits reservation of a key by a request in progress, so a key of a crashed request is freed soon.
+	// It's extended to TTL when the response is stored.
+	LockTTL time.Duration `yaml:"lock_ttl" env-default:"1m"`
+}
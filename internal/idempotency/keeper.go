@@ -0,0 +1,122 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"ozon_task/domain"
+	"ozon_task/internal/auth"
+	"ozon_task/internal/repository"
+	"time"
+)
+
+// Keeper reserves idempotency keys and stores responses for replay. It is used by the gRPC interceptor only:
+// stored responses are proto messages replayed byte for byte over gRPC, HTTP requests get them through
+// the gateway, which marshals the replayed message by protojson again, so HTTP bodies may differ in formatting.
+type Keeper struct {
+	cfg   Config
+	store repository.Idempotency
+}
+
+func NewKeeper(cfg Config, store repository.Idempotency) *Keeper {
+	return &Keeper{
+		cfg:   cfg,
+		store: store,
+	}
+}
+
+func (k *Keeper) Enabled() bool {
+	return k.cfg.Enabled
+}
+
+// Fingerprint hashes parts of the request which must match for a replay.
+func Fingerprint(parts ...[]byte) []byte {
+	h := sha256.New()
+	for _, part := range parts {
+		// length prefix keeps boundaries between parts
+		_, _ = fmt.Fprintf(h, "%d:", len(part))
+		h.Write(part)
+	}
+	return h.Sum(nil)
+}
+
+// Begin reserves key for the request of the caller from ctx.
+// Returns completed record and true if the request was already served and its response must be replayed.
+// Returns domain.ErrIdempotencyKeyReused if the key was used for a different request
+// and domain.ErrIdempotencyKeyInProgress if the first request is not finished yet.
+func (k *Keeper) Begin(ctx context.Context, key string, fingerprint []byte) (domain.IdempotencyRecord, bool, error) {
+	if len(key) == 0 || len(key) > domain.MaxIdempotencyKeyLength {
+		return domain.IdempotencyRecord{}, false,
+			fmt.Errorf("Begin: key length must be in [1, %d]: %w", domain.MaxIdempotencyKeyLength,
+				domain.ErrInvalidIdempotencyKey)
+	}
+
+	record := domain.IdempotencyRecord{
+		Key:         scopedKey(ctx, key),
+		Fingerprint: fingerprint,
+		CreatedAt:   time.Now().UTC(),
+	}
+
+	existing, reserved, err := k.store.ReserveIdempotencyKey(ctx, record, k.cfg.LockTTL)
+	if err != nil {
+		return domain.IdempotencyRecord{}, false, fmt.Errorf("Begin: %w", err)
+	}
+
+	if reserved {
+		return domain.IdempotencyRecord{}, false, nil
+	}
+
+	if !bytes.Equal(existing.Fingerprint, fingerprint) {
+		return domain.IdempotencyRecord{}, false, fmt.Errorf("Begin: %w", domain.ErrIdempotencyKeyReused)
+	}
+
+	if !existing.Completed() {
+		return domain.IdempotencyRecord{}, false, fmt.Errorf("Begin: %w", domain.ErrIdempotencyKeyInProgress)
+	}
+
+	return existing, true, nil
+}
+
+// Storable reports whether response with the HTTP status is final and must be replayed.
+// Retries of transient failures are executed again.
+func Storable(status int) bool {
+	switch {
+	case status >= http.StatusInternalServerError,
+		status == http.StatusRequestTimeout,
+		status == http.StatusTooManyRequests:
+		return false
+	default:
+		return true
+	}
+}
+
+// Complete stores response of the request reserved by Begin for TTL.
+func (k *Keeper) Complete(ctx context.Context, key string, record domain.IdempotencyRecord) error {
+	record.Key = scopedKey(ctx, key)
+	record.CreatedAt = time.Now().UTC()
+
+	if err := k.store.CompleteIdempotencyKey(ctx, record, k.cfg.TTL); err != nil {
+		return fmt.Errorf("Complete: %w", err)
+	}
+
+	return nil
+}
+
+// Release frees key reserved by Begin, so a failed request can be retried with the same key.
+func (k *Keeper) Release(ctx context.Context, key string) error {
+	if err := k.store.ReleaseIdempotencyKey(ctx, scopedKey(ctx, key)); err != nil {
+		return fmt.Errorf("Release: %w", err)
+	}
+
+	return nil
+}
+
+// scopedKey prevents collisions of keys chosen by different callers.
+func scopedKey(ctx context.Context, key string) string {
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		return principal.ID + ":" + key
+	}
+	return ":" + key
+}
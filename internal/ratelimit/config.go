@@ -0,0 +1,30 @@
+package ratelimit
+
+import pkgratelimit "ozon_task/pkg/ratelimit"
+
+const (
+	BackendMemory = "memory"
+	BackendRedis  = "redis"
+)
+
+// Clients are identified by one of these keys. Anonymous clients are always identified by ip.
+const (
+	KeyByIP     = "ip"
+	KeyByAPIKey = "api_key"
+	KeyByTenant = "tenant"
+)
+
+type Config struct {
+	Enabled bool   `yaml:"enabled" env-default:"false"`
+	Backend string `yaml:"backend" env-default:"memory"`
+	KeyBy   string `yaml:"key_by" env-default:"api_key"`
+	// Prefix of redis keys.
+	Prefix  string             `yaml:"prefix" env-default:"ratelimit:"`
+	Shorten pkgratelimit.Limit `yaml:"shorten"`
+	Resolve pkgratelimit.Limit `yaml:"resolve"`
+	// DailyShortenQuota limits links created by a single key per UTC day, zero disables quota.
+	DailyShortenQuota int `yaml:"daily_shorten_quota"`
+	// TrustedProxies are CIDRs of proxies, e.g. load balancers or remote HTTP gateways, whose
+	// X-Forwarded-For is used to identify clients. The gateway of the same process is always trusted.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
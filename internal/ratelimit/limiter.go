@@ -0,0 +1,224 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"ozon_task/domain"
+	"ozon_task/internal/auth"
+	pkglog "ozon_task/pkg/log"
+	pkgratelimit "ozon_task/pkg/ratelimit"
+	"sync/atomic"
+)
+
+// Operation is a group of endpoints sharing limits.
+type Operation string
+
+const (
+	OperationShorten Operation = "shorten"
+	OperationResolve Operation = "resolve"
+)
+
+// Limiter applies configured limits and quotas to callers.
+// It is used by gRPC interceptors, which serve the HTTP api through the gateway as well.
+type Limiter struct {
+	cfg     Config
+	limiter pkgratelimit.Limiter
+	quota   pkgratelimit.Quota
+	logger  *slog.Logger
+	// proxies are parsed TrustedProxies
+	proxies []netip.Prefix
+}
+
+func NewLimiter(
+	cfg Config,
+	limiter pkgratelimit.Limiter,
+	quota pkgratelimit.Quota,
+	logger *slog.Logger,
+) *Limiter {
+	// proxies are validated with the config
+	proxies, _ := ParseProxies(cfg.TrustedProxies)
+
+	return &Limiter{
+		cfg:     cfg,
+		limiter: limiter,
+		quota:   quota,
+		logger:  logger,
+		proxies: proxies,
+	}
+}
+
+// Check takes a token for the operation and, for shorten, reserves a unit of daily quota.
+// The reservation is kept in the returned context, which must be passed to the handler
+// of the call and to Release when the call ends.
+// Returns domain.ErrRateLimited or domain.ErrQuotaExceeded if the caller must back off.
+// Zero result means that no limit was applied.
+// Backend failures are logged and let the request through.
+func (l *Limiter) Check(
+	ctx context.Context,
+	op Operation,
+	clientIP string,
+) (context.Context, pkgratelimit.Result, error) {
+	res, err := l.Allow(ctx, op, clientIP)
+	if err != nil || op != OperationShorten {
+		return ctx, res, err
+	}
+
+	ctx, quotaRes, err := l.Reserve(ctx, clientIP)
+	if err != nil {
+		return ctx, quotaRes, fmt.Errorf("Check: %w", err)
+	}
+
+	return ctx, res, nil
+}
+
+// Allow takes a token for the operation without touching the daily quota.
+// Returns domain.ErrRateLimited if the caller must back off.
+func (l *Limiter) Allow(ctx context.Context, op Operation, clientIP string) (pkgratelimit.Result, error) {
+	const fn = "Limiter.Allow"
+
+	if !l.cfg.Enabled {
+		return pkgratelimit.Result{}, nil
+	}
+
+	limit := l.limit(op)
+	if !limit.Enabled() {
+		return pkgratelimit.Result{}, nil
+	}
+
+	key := string(op) + ":" + l.clientKey(ctx, clientIP)
+	res, err := l.limiter.Allow(ctx, key, limit)
+	if err != nil {
+		l.logger.Error("rate limiter is unavailable, request is not limited",
+			slog.String("op", fn), slog.String("operation", string(op)), pkglog.Err(err))
+		return pkgratelimit.Result{}, nil
+	}
+
+	if !res.Allowed {
+		return res, fmt.Errorf("Allow: key %q: %w", key, domain.ErrRateLimited)
+	}
+
+	return res, nil
+}
+
+// Reserve takes a unit of daily quota for a link created with the returned context.
+// The unit is refunded by Release unless SpendReservation is called with the context.
+func (l *Limiter) Reserve(ctx context.Context, clientIP string) (context.Context, pkgratelimit.Result, error) {
+	const fn = "Limiter.Reserve"
+
+	if !l.cfg.Enabled || l.cfg.DailyShortenQuota <= 0 {
+		return ctx, pkgratelimit.Result{}, nil
+	}
+
+	key := quotaClientKey(ctx, clientIP)
+	res, err := l.quota.Consume(ctx, key, l.cfg.DailyShortenQuota)
+	if err != nil {
+		l.logger.Error("quota storage is unavailable, request is not limited",
+			slog.String("op", fn), pkglog.Err(err))
+		return ctx, pkgratelimit.Result{}, nil
+	}
+
+	if !res.Allowed {
+		return ctx, res, fmt.Errorf("Reserve: key %q: %w", key, domain.ErrQuotaExceeded)
+	}
+
+	return context.WithValue(ctx, reservationKey{}, &reservation{key: key}), res, nil
+}
+
+// Release refunds the unit of quota reserved in the context unless a link was created with it,
+// so calls failed by validation, storage or deduplication don't spend the quota.
+func (l *Limiter) Release(ctx context.Context) {
+	const fn = "Limiter.Release"
+
+	r, ok := ctx.Value(reservationKey{}).(*reservation)
+	if !ok || r.created.Load() || !r.released.CompareAndSwap(false, true) {
+		return
+	}
+
+	if err := l.quota.Refund(context.WithoutCancel(ctx), r.key); err != nil {
+		l.logger.Error("failed to refund quota", slog.String("op", fn), pkglog.Err(err))
+	}
+}
+
+type reservationKey struct{}
+
+// reservation is a unit of daily quota taken for a link.
+type reservation struct {
+	key      string
+	created  atomic.Bool
+	released atomic.Bool
+}
+
+type streamQuotaKey struct{}
+
+// streamQuota lets handlers of a stream reserve daily quota of its caller per item.
+type streamQuota struct {
+	limiter  *Limiter
+	clientIP string
+}
+
+// WithStreamQuota returns context of a stream whose items are charged by ReserveItem.
+func (l *Limiter) WithStreamQuota(ctx context.Context, clientIP string) context.Context {
+	return context.WithValue(ctx, streamQuotaKey{}, streamQuota{limiter: l, clientIP: clientIP})
+}
+
+// ReserveItem reserves a unit of daily quota for one item of the stream, the returned context
+// must be used to create its link and release must be called once the item is done.
+// Streams without quota in the context aren't limited.
+func ReserveItem(ctx context.Context) (context.Context, func(), error) {
+	q, ok := ctx.Value(streamQuotaKey{}).(streamQuota)
+	if !ok {
+		return ctx, func() {}, nil
+	}
+
+	ctx, _, err := q.limiter.Reserve(ctx, q.clientIP)
+	if err != nil {
+		return ctx, func() {}, fmt.Errorf("ReserveItem: %w", err)
+	}
+
+	return ctx, func() { q.limiter.Release(ctx) }, nil
+}
+
+// SpendReservation spends the unit of quota reserved in the context, if any, on the created link,
+// so Release keeps it taken.
+func SpendReservation(ctx context.Context) {
+	if r, ok := ctx.Value(reservationKey{}).(*reservation); ok {
+		r.created.Store(true)
+	}
+}
+
+func (l *Limiter) limit(op Operation) pkgratelimit.Limit {
+	switch op {
+	case OperationShorten:
+		return l.cfg.Shorten
+	case OperationResolve:
+		return l.cfg.Resolve
+	default:
+		return pkgratelimit.Limit{}
+	}
+}
+
+func (l *Limiter) clientKey(ctx context.Context, clientIP string) string {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return "ip:" + clientIP
+	}
+
+	switch l.cfg.KeyBy {
+	case KeyByAPIKey:
+		return "key:" + principal.ID
+	case KeyByTenant:
+		return "tenant:" + principal.OwnerID
+	default:
+		return "ip:" + clientIP
+	}
+}
+
+// quotaClientKey identifies callers for quotas, which are always issued per key.
+func quotaClientKey(ctx context.Context, clientIP string) string {
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		return "key:" + principal.ID
+	}
+	return "ip:" + clientIP
+}
@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"net/netip"
+	"strings"
+)
+
+// ParseProxies parses CIDRs of trusted proxies.
+func ParseProxies(cidrs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// ClientIP returns address of the client behind trusted proxies. Hops of X-Forwarded-For are taken
+// from the right while the previous one is a trusted proxy, the peer is trusted if it's one of
+// TrustedProxies or if the caller vouches for it, e.g. for the gateway of the same process.
+func (l *Limiter) ClientIP(peerIP string, peerTrusted bool, forwardedFor []string) string {
+	if !peerTrusted && !l.trusted(peerIP) {
+		return peerIP
+	}
+
+	hops := strings.Split(strings.Join(forwardedFor, ","), ",")
+	ip := peerIP
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if len(hop) == 0 {
+			break
+		}
+
+		ip = hop
+		if !l.trusted(hop) {
+			break
+		}
+	}
+
+	return ip
+}
+
+func (l *Limiter) trusted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+
+	addr = addr.Unmap()
+	for _, prefix := range l.proxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
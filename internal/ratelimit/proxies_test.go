@@ -0,0 +1,36 @@
+package ratelimit
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter_ClientIP(t *testing.T) {
+	t.Parallel()
+	limiter := NewLimiter(Config{TrustedProxies: []string{"10.0.0.0/8", "::1/128"}}, nil, nil,
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	tests := []struct {
+		name         string
+		peer         string
+		peerTrusted  bool
+		forwardedFor []string
+		want         string
+	}{
+		{"untrusted peer", "127.0.0.1", false, []string{"1.1.1.1"}, "127.0.0.1"},
+		{"trusted proxy", "10.0.0.1", false, []string{"2.2.2.2, 1.1.1.1"}, "1.1.1.1"},
+		{"chain of trusted proxies", "::1", false, []string{"2.2.2.2, 1.1.1.1, 10.0.0.2"}, "1.1.1.1"},
+		{"peer trusted by caller", "inprocess", true, []string{"1.1.1.1"}, "1.1.1.1"},
+		{"trusted peer without header", "10.0.0.1", false, nil, "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, limiter.ClientIP(tt.peer, tt.peerTrusted, tt.forwardedFor))
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"context"
+	"ozon_task/domain"
+)
+
+// APIKey defines the interface for data layer of api keys.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.52.1 --name=APIKey --filename=apikey_repository_mock.go
+type APIKey interface {
+	// CreateAPIKey stores new api key.
+	CreateAPIKey(ctx context.Context, key domain.APIKey) error
+
+	// GetAPIKeyByHash retrieves api key by the hash of its raw value.
+	// Returns `domain.ErrAPIKeyNotFound` if there is no such key.
+	GetAPIKeyByHash(ctx context.Context, hash []byte) (domain.APIKey, error)
+
+	// ListAPIKeys returns keys of the owner ordered by creation time, or all keys if owner is empty.
+	ListAPIKeys(ctx context.Context, owner domain.OwnerID) ([]domain.APIKey, error)
+
+	// RevokeAPIKey marks key as revoked and returns its updated state.
+	// Returns `domain.ErrAPIKeyNotFound` if there is no such key.
+	RevokeAPIKey(ctx context.Context, id string) (domain.APIKey, error)
+}
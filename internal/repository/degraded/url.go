@@ -0,0 +1,118 @@
+// Package degraded serves resolves of recently resolved links while the storage is unavailable.
+package degraded
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"ozon_task/domain"
+	"ozon_task/internal/repository"
+	"ozon_task/internal/stale"
+	pkglog "ozon_task/pkg/log"
+)
+
+// URLRepository remembers links confirmed by the wrapped repository in the stale store
+// and resolves them from it when the repository fails with unavailable storage, e.g. its
+// circuit breaker is open. Other operations fail as usual, so shortening gets 503 meanwhile.
+type URLRepository struct {
+	repository.URL
+	store *stale.Store
+	log   *slog.Logger
+}
+
+func NewURLRepository(repo repository.URL, store *stale.Store, log *slog.Logger) repository.URL {
+	return &URLRepository{
+		URL:   repo,
+		store: store,
+		log:   log,
+	}
+}
+
+func (r *URLRepository) CreateOrGetShortenedURL(ctx context.Context, link domain.Link) (domain.Link, error) {
+	link, err := r.URL.CreateOrGetShortenedURL(ctx, link)
+	if err == nil {
+		r.remember(link)
+	}
+	return link, err
+}
+
+func (r *URLRepository) GetOriginalURLByShortened(
+	ctx context.Context,
+	shortened domain.ShortURL,
+) (domain.URL, error) {
+	original, err := r.URL.GetOriginalURLByShortened(ctx, shortened)
+	switch {
+	case err == nil:
+		r.remember(domain.Link{Shortened: shortened, Original: original})
+		return original, nil
+	case errors.Is(err, domain.ErrOriginalNotFound), errors.Is(err, domain.ErrLinkExpired):
+		r.forget(shortened)
+		return "", err
+	case !unavailable(err):
+		return "", err
+	}
+
+	staleOriginal, staleErr := r.store.Get(shortened)
+	switch {
+	case staleErr == nil:
+		r.log.Debug("resolved from stale store", slog.String("shortened", shortened), pkglog.Err(err))
+		return staleOriginal, nil
+	case errors.Is(staleErr, domain.ErrLinkExpired):
+		return "", staleErr
+	default:
+		return "", err
+	}
+}
+
+func (r *URLRepository) GetLink(ctx context.Context, shortened domain.ShortURL) (domain.Link, error) {
+	link, err := r.URL.GetLink(ctx, shortened)
+	if err == nil {
+		r.remember(link)
+	}
+	return link, err
+}
+
+func (r *URLRepository) UpdateLink(
+	ctx context.Context,
+	shortened domain.ShortURL,
+	update domain.LinkUpdate,
+	author string,
+) (domain.Link, error) {
+	// the link is forgotten even on failure, as the update may be applied with its result lost,
+	// and before remembering it again, as removed expiration isn't distinguishable from unknown one
+	link, err := r.URL.UpdateLink(ctx, shortened, update, author)
+	r.forget(shortened)
+	if err == nil {
+		r.remember(link)
+	}
+	return link, err
+}
+
+func (r *URLRepository) DeleteLink(ctx context.Context, shortened domain.ShortURL) error {
+	err := r.URL.DeleteLink(ctx, shortened)
+	r.forget(shortened)
+	return err
+}
+
+// remember stores the link, expiration of links known only by resolves is unknown.
+func (r *URLRepository) remember(link domain.Link) {
+	if err := r.store.Put(link.Shortened, link.Original, link.ExpiresAt); err != nil {
+		r.log.Warn("failed to remember link in stale store", pkglog.Err(err))
+	}
+}
+
+func (r *URLRepository) forget(shortened domain.ShortURL) {
+	if err := r.store.Delete(shortened); err != nil {
+		r.log.Warn("failed to forget link in stale store", pkglog.Err(err))
+	}
+}
+
+// unavailable reports failures of the storage itself, the stale store isn't used for conflicts.
+func unavailable(err error) bool {
+	switch domain.ErrorCodeOf(err) {
+	case domain.CodeStorageUnavailable, domain.CodeStorageTimeout:
+		return true
+	default:
+		return false
+	}
+}
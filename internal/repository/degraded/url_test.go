@@ -0,0 +1,85 @@
+package degraded
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"ozon_task/domain"
+	"ozon_task/internal/repository/mocks"
+	"ozon_task/internal/stale"
+	"ozon_task/pkg/resilience"
+)
+
+var dummyLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+var errUnavailable = fmt.Errorf("%w: %w", domain.ErrStorageUnavailable, resilience.ErrCircuitOpen)
+
+func newTestRepository(t *testing.T) (*URLRepository, *mocks.URL) {
+	t.Helper()
+	store, err := stale.Open(stale.Config{Path: filepath.Join(t.TempDir(), "links.log"), Capacity: 10})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	mockRepo := new(mocks.URL)
+	repo := NewURLRepository(mockRepo, store, dummyLogger).(*URLRepository)
+	return repo, mockRepo
+}
+
+func TestURLRepository_ServesStale(t *testing.T) {
+	t.Parallel()
+	repo, mockRepo := newTestRepository(t)
+	ctx := context.Background()
+
+	mockRepo.On("GetOriginalURLByShortened", mock.Anything, domain.ShortURL("abcdefghij")).
+		Return(domain.URL("https://example.com"), nil).Once()
+	mockRepo.On("GetOriginalURLByShortened", mock.Anything, mock.Anything).Return(domain.URL(""), errUnavailable)
+	mockRepo.On("CreateOrGetShortenedURL", mock.Anything, mock.Anything).Return(domain.Link{}, errUnavailable)
+
+	original, err := repo.GetOriginalURLByShortened(ctx, "abcdefghij")
+	require.NoError(t, err)
+	require.Equal(t, domain.URL("https://example.com"), original)
+
+	original, err = repo.GetOriginalURLByShortened(ctx, "abcdefghij")
+	require.NoError(t, err, "remembered link is served while the storage is unavailable")
+	require.Equal(t, domain.URL("https://example.com"), original)
+
+	_, err = repo.GetOriginalURLByShortened(ctx, "klmnopqrst")
+	require.ErrorIs(t, err, domain.ErrStorageUnavailable, "unknown links aren't served")
+
+	_, err = repo.CreateOrGetShortenedURL(ctx, domain.Link{Original: "https://other.com", Shortened: "uvwxyzABCD"})
+	require.ErrorIs(t, err, domain.ErrStorageUnavailable, "shortening fails while the storage is unavailable")
+}
+
+func TestURLRepository_ForgetsChangedLinks(t *testing.T) {
+	t.Parallel()
+	repo, mockRepo := newTestRepository(t)
+	ctx := context.Background()
+	expiresAt := time.Now().Add(-time.Minute)
+
+	mockRepo.On("CreateOrGetShortenedURL", mock.Anything, mock.Anything).
+		Return(domain.Link{Original: "https://a.com", Shortened: "aaaaaaaaaa"}, nil)
+	mockRepo.On("GetLink", mock.Anything, domain.ShortURL("bbbbbbbbbb")).
+		Return(domain.Link{Original: "https://b.com", Shortened: "bbbbbbbbbb", ExpiresAt: expiresAt}, nil)
+	mockRepo.On("DeleteLink", mock.Anything, domain.ShortURL("aaaaaaaaaa")).Return(errUnavailable)
+	mockRepo.On("GetOriginalURLByShortened", mock.Anything, mock.Anything).Return(domain.URL(""), errUnavailable)
+
+	_, err := repo.CreateOrGetShortenedURL(ctx, domain.Link{})
+	require.NoError(t, err)
+	_, err = repo.GetLink(ctx, "bbbbbbbbbb")
+	require.NoError(t, err)
+
+	_, err = repo.GetOriginalURLByShortened(ctx, "bbbbbbbbbb")
+	require.ErrorIs(t, err, domain.ErrLinkExpired)
+
+	require.ErrorIs(t, repo.DeleteLink(ctx, "aaaaaaaaaa"), domain.ErrStorageUnavailable)
+	_, err = repo.GetOriginalURLByShortened(ctx, "aaaaaaaaaa")
+	require.ErrorIs(t, err, domain.ErrStorageUnavailable, "link may be deleted, so it isn't served")
+}
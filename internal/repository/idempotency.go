@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+	"ozon_task/domain"
+	"time"
+)
+
+// Idempotency defines the interface for storage of responses to requests with idempotency keys.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.52.1 --name=Idempotency --filename=idempotency_repository_mock.go
+type Idempotency interface {
+	// ReserveIdempotencyKey stores record without response if the key is free or expired.
+	// Otherwise returns existing record and false.
+	ReserveIdempotencyKey(ctx context.Context, record domain.IdempotencyRecord, ttl time.Duration) (
+		domain.IdempotencyRecord, bool, error)
+
+	// CompleteIdempotencyKey stores response of the reserved key.
+	CompleteIdempotencyKey(ctx context.Context, record domain.IdempotencyRecord, ttl time.Duration) error
+
+	// ReleaseIdempotencyKey deletes reservation, so the request can be retried.
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+}
@@ -0,0 +1,93 @@
+package inmem
+
+import (
+	"context"
+	"ozon_task/domain"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type APIKeyRepository struct {
+	keys   map[string]domain.APIKey
+	byHash map[string]string
+	m      sync.RWMutex
+	// now stamps revocations of keys
+	now func() time.Time
+}
+
+func NewAPIKeyRepository() *APIKeyRepository {
+	return NewAPIKeyRepositoryWithClock(time.Now)
+}
+
+// NewAPIKeyRepositoryWithClock returns the repository stamping revocations by the clock, so replicas
+// of it applying the same changes get the same keys.
+func NewAPIKeyRepositoryWithClock(now func() time.Time) *APIKeyRepository {
+	return &APIKeyRepository{
+		keys:   make(map[string]domain.APIKey),
+		byHash: make(map[string]string),
+		now:    now,
+	}
+}
+
+func (r *APIKeyRepository) CreateAPIKey(_ context.Context, key domain.APIKey) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.keys[key.ID] = key
+	r.byHash[string(key.Hash)] = key.ID
+
+	return nil
+}
+
+func (r *APIKeyRepository) GetAPIKeyByHash(_ context.Context, hash []byte) (domain.APIKey, error) {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	id, ok := r.byHash[string(hash)]
+	if !ok {
+		return domain.APIKey{}, domain.ErrAPIKeyNotFound
+	}
+
+	return r.keys[id], nil
+}
+
+func (r *APIKeyRepository) ListAPIKeys(_ context.Context, owner domain.OwnerID) ([]domain.APIKey, error) {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	keys := make([]domain.APIKey, 0)
+	for _, key := range r.keys {
+		if len(owner) == 0 || key.OwnerID == owner {
+			keys = append(keys, key)
+		}
+	}
+
+	slices.SortFunc(keys, func(a, b domain.APIKey) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	return keys, nil
+}
+
+func (r *APIKeyRepository) RevokeAPIKey(_ context.Context, id string) (domain.APIKey, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	key, ok := r.keys[id]
+	if !ok {
+		return domain.APIKey{}, domain.ErrAPIKeyNotFound
+	}
+
+	if !key.IsRevoked() {
+		now := r.now().UTC()
+		key.RevokedAt = &now
+		r.keys[id] = key
+	}
+
+	return key, nil
+}
@@ -0,0 +1,53 @@
+package inmem_test
+
+import (
+	"context"
+	"ozon_task/domain"
+	"ozon_task/internal/repository/inmem"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyRepository_CreateAndRevoke(t *testing.T) {
+	ctx := context.Background()
+	repo := inmem.NewAPIKeyRepository()
+
+	key := domain.APIKey{ID: "abcdefghij", OwnerID: "team", Hash: []byte("hash"), CreatedAt: time.Now()}
+	require.NoError(t, repo.CreateAPIKey(ctx, key))
+
+	got, err := repo.GetAPIKeyByHash(ctx, []byte("hash"))
+	require.NoError(t, err)
+	require.Equal(t, key.ID, got.ID)
+
+	revoked, err := repo.RevokeAPIKey(ctx, key.ID)
+	require.NoError(t, err)
+	require.True(t, revoked.IsRevoked())
+
+	_, err = repo.RevokeAPIKey(ctx, "unknownkey")
+	require.ErrorIs(t, err, domain.ErrAPIKeyNotFound)
+
+	_, err = repo.GetAPIKeyByHash(ctx, []byte("other"))
+	require.ErrorIs(t, err, domain.ErrAPIKeyNotFound)
+}
+
+func TestAPIKeyRepository_ListByOwner(t *testing.T) {
+	ctx := context.Background()
+	repo := inmem.NewAPIKeyRepository()
+	now := time.Now()
+
+	require.NoError(t, repo.CreateAPIKey(ctx, domain.APIKey{ID: "a", OwnerID: "first", Hash: []byte("a"), CreatedAt: now}))
+	require.NoError(t, repo.CreateAPIKey(ctx, domain.APIKey{ID: "b", OwnerID: "second", Hash: []byte("b"), CreatedAt: now}))
+	require.NoError(t, repo.CreateAPIKey(ctx, domain.APIKey{ID: "c", OwnerID: "first", Hash: []byte("c"), CreatedAt: now.Add(time.Second)}))
+
+	keys, err := repo.ListAPIKeys(ctx, "first")
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	require.Equal(t, "a", keys[0].ID)
+	require.Equal(t, "c", keys[1].ID)
+
+	all, err := repo.ListAPIKeys(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+}
@@ -0,0 +1,86 @@
+package inmem
+
+import (
+	"context"
+	"ozon_task/domain"
+	"ozon_task/internal/repository"
+	"sync"
+	"time"
+)
+
+type idempotencyEntry struct {
+	record    domain.IdempotencyRecord
+	expiresAt time.Time
+}
+
+// purgeEvery is number of reservations between removals of expired records.
+const purgeEvery = 1024
+
+type IdempotencyRepository struct {
+	records      map[string]idempotencyEntry
+	reservations int
+	m            sync.Mutex
+	now          func() time.Time
+}
+
+func NewIdempotencyRepository() repository.Idempotency {
+	return &IdempotencyRepository{
+		records: make(map[string]idempotencyEntry),
+		now:     time.Now,
+	}
+}
+
+func (r *IdempotencyRepository) ReserveIdempotencyKey(
+	_ context.Context,
+	record domain.IdempotencyRecord,
+	ttl time.Duration,
+) (domain.IdempotencyRecord, bool, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	now := r.now()
+	if entry, ok := r.records[record.Key]; ok && now.Before(entry.expiresAt) {
+		return entry.record, false, nil
+	}
+
+	r.purge(now)
+	r.records[record.Key] = idempotencyEntry{record: record, expiresAt: now.Add(ttl)}
+
+	return record, true, nil
+}
+
+func (r *IdempotencyRepository) CompleteIdempotencyKey(
+	_ context.Context,
+	record domain.IdempotencyRecord,
+	ttl time.Duration,
+) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.records[record.Key] = idempotencyEntry{record: record, expiresAt: r.now().Add(ttl)}
+
+	return nil
+}
+
+func (r *IdempotencyRepository) ReleaseIdempotencyKey(_ context.Context, key string) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	delete(r.records, key)
+
+	return nil
+}
+
+// purge periodically removes expired records, so memory is bounded by keys used within ttl.
+func (r *IdempotencyRepository) purge(now time.Time) {
+	r.reservations++
+	if r.reservations%purgeEvery != 0 {
+		return
+	}
+
+	for key, entry := range r.records {
+		if !now.Before(entry.expiresAt) {
+			delete(r.records, key)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package inmem_test
+
+import (
+	"context"
+	"ozon_task/domain"
+	"ozon_task/internal/repository/inmem"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyRepository_Reserve(t *testing.T) {
+	ctx := context.Background()
+	repo := inmem.NewIdempotencyRepository()
+	record := domain.IdempotencyRecord{Key: "key", Fingerprint: []byte("first")}
+
+	_, reserved, err := repo.ReserveIdempotencyKey(ctx, record, time.Minute)
+	require.NoError(t, err)
+	require.True(t, reserved)
+
+	existing, reserved, err := repo.ReserveIdempotencyKey(ctx, record, time.Minute)
+	require.NoError(t, err)
+	require.False(t, reserved)
+	require.False(t, existing.Completed())
+
+	record.StatusCode = 200
+	record.Body = []byte(`{"shortened_url":"abcdefghij"}`)
+	require.NoError(t, repo.CompleteIdempotencyKey(ctx, record, time.Minute))
+
+	existing, reserved, err = repo.ReserveIdempotencyKey(ctx, record, time.Minute)
+	require.NoError(t, err)
+	require.False(t, reserved)
+	require.Equal(t, record, existing)
+
+	require.NoError(t, repo.ReleaseIdempotencyKey(ctx, record.Key))
+	_, reserved, err = repo.ReserveIdempotencyKey(ctx, record, time.Minute)
+	require.NoError(t, err)
+	require.True(t, reserved)
+}
+
+func TestIdempotencyRepository_Expired(t *testing.T) {
+	ctx := context.Background()
+	repo := inmem.NewIdempotencyRepository()
+	record := domain.IdempotencyRecord{Key: "key", Fingerprint: []byte("first")}
+
+	_, reserved, err := repo.ReserveIdempotencyKey(ctx, record, time.Nanosecond)
+	require.NoError(t, err)
+	require.True(t, reserved)
+
+	time.Sleep(time.Millisecond)
+
+	_, reserved, err = repo.ReserveIdempotencyKey(ctx, record, time.Minute)
+	require.NoError(t, err)
+	require.True(t, reserved)
+}
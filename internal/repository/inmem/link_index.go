@@ -0,0 +1,198 @@
+package inmem
+
+import (
+	"ozon_task/domain"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// linkIndex keeps ids of links in order with postings by owner, host and tag, so listing seeks from the cursor
+// instead of scanning the storage. Writers of link records change it while they hold the partition of the record,
+// so the index doesn't miss a stored link.
+// Ids are assigned sequentially, so ids and posting lists stay sorted mostly by appending.
+type linkIndex struct {
+	m sync.RWMutex
+	// ids of all links, ascending
+	ids     []int64
+	byID    map[int64]domain.ShortURL
+	byOwner map[domain.OwnerID][]int64
+	byHost  map[string][]int64
+	byTag   map[domain.Tag][]int64
+}
+
+// indexedLink is a position of the index, the link itself is read from the storage.
+type indexedLink struct {
+	id        int64
+	shortened domain.ShortURL
+}
+
+func newLinkIndex() *linkIndex {
+	return &linkIndex{
+		byID:    make(map[int64]domain.ShortURL),
+		byOwner: make(map[domain.OwnerID][]int64),
+		byHost:  make(map[string][]int64),
+		byTag:   make(map[domain.Tag][]int64),
+	}
+}
+
+func (ix *linkIndex) add(link domain.Link) {
+	ix.m.Lock()
+	defer ix.m.Unlock()
+
+	ix.insert(link)
+}
+
+func (ix *linkIndex) insert(link domain.Link) {
+	ix.ids = insertID(ix.ids, link.ID)
+	ix.byID[link.ID] = link.Shortened
+
+	if len(link.OwnerID) != 0 {
+		ix.byOwner[link.OwnerID] = insertID(ix.byOwner[link.OwnerID], link.ID)
+	}
+	if host := domain.URLHost(link.Original); len(host) != 0 {
+		ix.byHost[host] = insertID(ix.byHost[host], link.ID)
+	}
+	for _, tag := range link.Tags {
+		ix.byTag[tag] = insertID(ix.byTag[tag], link.ID)
+	}
+}
+
+func (ix *linkIndex) remove(link domain.Link) {
+	ix.m.Lock()
+	defer ix.m.Unlock()
+
+	ix.ids = removeID(ix.ids, link.ID)
+	delete(ix.byID, link.ID)
+
+	if len(link.OwnerID) != 0 {
+		removePosting(ix.byOwner, link.OwnerID, link.ID)
+	}
+	if host := domain.URLHost(link.Original); len(host) != 0 {
+		removePosting(ix.byHost, host, link.ID)
+	}
+	for _, tag := range link.Tags {
+		removePosting(ix.byTag, tag, link.ID)
+	}
+}
+
+// update moves the link between postings of host and tags, owners of links aren't changed.
+func (ix *linkIndex) update(previous, updated domain.Link) {
+	ix.m.Lock()
+	defer ix.m.Unlock()
+
+	if oldHost, newHost := domain.URLHost(previous.Original), domain.URLHost(updated.Original); oldHost != newHost {
+		if len(oldHost) != 0 {
+			removePosting(ix.byHost, oldHost, previous.ID)
+		}
+		if len(newHost) != 0 {
+			ix.byHost[newHost] = insertID(ix.byHost[newHost], updated.ID)
+		}
+	}
+
+	for _, tag := range previous.Tags {
+		if !slices.Contains(updated.Tags, tag) {
+			removePosting(ix.byTag, tag, previous.ID)
+		}
+	}
+	for _, tag := range updated.Tags {
+		ix.byTag[tag] = insertID(ix.byTag[tag], updated.ID)
+	}
+}
+
+// reset replaces the content of the index with the links.
+func (ix *linkIndex) reset(links []domain.Link) {
+	ix.m.Lock()
+	defer ix.m.Unlock()
+
+	ix.ids = make([]int64, 0, len(links))
+	clear(ix.byID)
+	clear(ix.byOwner)
+	clear(ix.byHost)
+	clear(ix.byTag)
+	for _, link := range links {
+		ix.insert(link)
+	}
+}
+
+// seek walks the most selective posting list backwards from afterID, checks the other postings of the filter
+// and returns at most limit links. Zero afterID starts from the newest link.
+func (ix *linkIndex) seek(filter domain.LinkFilter, afterID int64, limit int) []indexedLink {
+	ix.m.RLock()
+	defer ix.m.RUnlock()
+
+	ids, others := ix.postings(filter)
+
+	end := len(ids)
+	if afterID > 0 {
+		end, _ = slices.BinarySearch(ids, afterID)
+	}
+
+	res := make([]indexedLink, 0, min(limit, end))
+	for i := end - 1; i >= 0 && len(res) < limit; i-- {
+		if containsAll(others, ids[i]) {
+			res = append(res, indexedLink{id: ids[i], shortened: ix.byID[ids[i]]})
+		}
+	}
+
+	return res
+}
+
+// postings returns the shortest posting list for equality conditions of the filter and the rest of them,
+// or all ids if the filter has none of them.
+func (ix *linkIndex) postings(filter domain.LinkFilter) (ids []int64, others [][]int64) {
+	lists := make([][]int64, 0, 3)
+	if len(filter.OwnerID) != 0 {
+		lists = append(lists, ix.byOwner[filter.OwnerID])
+	}
+	if len(filter.Host) != 0 {
+		lists = append(lists, ix.byHost[strings.ToLower(filter.Host)])
+	}
+	if len(filter.Tag) != 0 {
+		lists = append(lists, ix.byTag[filter.Tag])
+	}
+	if len(lists) == 0 {
+		return ix.ids, nil
+	}
+
+	shortest := 0
+	for i := range lists {
+		if len(lists[i]) < len(lists[shortest]) {
+			shortest = i
+		}
+	}
+	ids = lists[shortest]
+	return ids, slices.Delete(lists, shortest, shortest+1)
+}
+
+func containsAll(lists [][]int64, id int64) bool {
+	for _, list := range lists {
+		if _, found := slices.BinarySearch(list, id); !found {
+			return false
+		}
+	}
+	return true
+}
+
+// removePosting removes the id from the posting list of the key, empty lists are dropped.
+func removePosting[K comparable](postings map[K][]int64, key K, id int64) {
+	if ids := removeID(postings[key], id); len(ids) != 0 {
+		postings[key] = ids
+	} else {
+		delete(postings, key)
+	}
+}
+
+func removeID(ids []int64, id int64) []int64 {
+	if i, found := slices.BinarySearch(ids, id); found {
+		return slices.Delete(ids, i, i+1)
+	}
+	return ids
+}
+
+func insertID(ids []int64, id int64) []int64 {
+	if i, found := slices.BinarySearch(ids, id); !found {
+		return slices.Insert(ids, i, id)
+	}
+	return ids
+}
@@ -0,0 +1,133 @@
+package inmem
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"ozon_task/domain"
+	"strings"
+	"sync/atomic"
+)
+
+// urlState is the content of URLRepository in snapshots.
+type urlState struct {
+	// KV is the content of the storage with records of links and references of deduplicated ones
+	KV     map[string]string         `json:"kv"`
+	LastID int64                     `json:"last_id"`
+	Clicks map[domain.ShortURL]int64 `json:"clicks"`
+}
+
+// URLSnapshot is a copy of the content of URLRepository, it's encoded apart from taking it,
+// so changes of the repository don't wait for the encoding.
+type URLSnapshot struct {
+	state urlState
+}
+
+// Snapshot copies the content of the repository. Changes made concurrently may be partially included.
+func (r *URLRepository) Snapshot() URLSnapshot {
+	// the id is loaded after the storage is copied, so it's not behind ids of copied links
+	pairs := r.storage.Snapshot()
+	state := urlState{
+		KV:     pairs,
+		LastID: r.lastID.Load(),
+		Clicks: make(map[domain.ShortURL]int64),
+	}
+	r.clicks.Range(func(key, value any) bool {
+		state.Clicks[key.(domain.ShortURL)] = value.(*atomic.Int64).Load()
+		return true
+	})
+	return URLSnapshot{state: state}
+}
+
+// Encode writes the snapshot in the format read by URLRepository.Restore.
+func (s URLSnapshot) Encode(w io.Writer) error {
+	if err := json.NewEncoder(w).Encode(s.state); err != nil {
+		return fmt.Errorf("Encode: %w", err)
+	}
+	return nil
+}
+
+// Restore replaces the content of the repository with the snapshot. Lookups may miss links while it's restored.
+func (r *URLRepository) Restore(rd io.Reader) error {
+	var state urlState
+	if err := json.NewDecoder(rd).Decode(&state); err != nil {
+		return fmt.Errorf("Restore: failed to decode: %w", err)
+	}
+
+	r.storage.Restore(state.KV)
+	r.index.reset(r.storedLinks())
+	r.lastID.Store(state.LastID)
+	r.clicks.Clear()
+	for shortened, clicks := range state.Clicks {
+		r.AddClicks(shortened, clicks)
+	}
+
+	return nil
+}
+
+// storedLinks decodes all records of the storage.
+func (r *URLRepository) storedLinks() []domain.Link {
+	links := make([]domain.Link, 0)
+	r.storage.Range(func(key, value string) bool {
+		if !strings.HasPrefix(key, linkKeyPrefix) {
+			return true
+		}
+		if record, ok := decodeRecord(value); ok {
+			links = append(links, record.Link)
+		}
+		return true
+	})
+	return links
+}
+
+// storedAPIKey keeps the hash of the key, which isn't encoded with the key itself.
+type storedAPIKey struct {
+	domain.APIKey
+	Hash []byte `json:"hash"`
+}
+
+// APIKeySnapshot is a copy of keys of APIKeyRepository, it's encoded apart from taking it.
+type APIKeySnapshot struct {
+	keys []storedAPIKey
+}
+
+// Snapshot copies all keys of the repository.
+func (r *APIKeyRepository) Snapshot() APIKeySnapshot {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	keys := make([]storedAPIKey, 0, len(r.keys))
+	for _, key := range r.keys {
+		keys = append(keys, storedAPIKey{APIKey: key, Hash: key.Hash})
+	}
+	return APIKeySnapshot{keys: keys}
+}
+
+// Encode writes the snapshot in the format read by APIKeyRepository.Restore.
+func (s APIKeySnapshot) Encode(w io.Writer) error {
+	if err := json.NewEncoder(w).Encode(s.keys); err != nil {
+		return fmt.Errorf("Encode: %w", err)
+	}
+	return nil
+}
+
+// Restore replaces keys of the repository with the snapshot.
+func (r *APIKeyRepository) Restore(rd io.Reader) error {
+	var keys []storedAPIKey
+	if err := json.NewDecoder(rd).Decode(&keys); err != nil {
+		return fmt.Errorf("Restore: failed to decode: %w", err)
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.keys = make(map[string]domain.APIKey, len(keys))
+	r.byHash = make(map[string]string, len(keys))
+	for _, key := range keys {
+		key.APIKey.Hash = key.Hash
+		r.keys[key.ID] = key.APIKey
+		r.byHash[string(key.Hash)] = key.ID
+	}
+
+	return nil
+}
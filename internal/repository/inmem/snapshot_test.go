@@ -0,0 +1,98 @@
+package inmem_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"ozon_task/domain"
+	"ozon_task/internal/repository/inmem"
+	pkginmem "ozon_task/pkg/infra/kv/inmem"
+)
+
+func TestURLRepository_SnapshotRestore(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := inmem.NewURLRepository(pkginmem.NewPartitionedKVStorage(partitionsCount))
+
+	kept, err := repo.CreateOrGetShortenedURL(ctx, domain.Link{Original: "https://ozon.ru", Shortened: "kept", OwnerID: "alice"})
+	require.NoError(t, err)
+	_, err = repo.CreateOrGetShortenedURL(ctx, domain.Link{Original: "https://ozon.ru/deleted", Shortened: "deleted"})
+	require.NoError(t, err)
+	expiresAt := time.Now().Add(-time.Minute).UTC()
+	_, err = repo.CreateOrGetShortenedURL(ctx, domain.Link{Original: "https://ozon.ru/expired", Shortened: "expired", ExpiresAt: expiresAt})
+	require.NoError(t, err)
+
+	edited := "https://fintech.ozon.ru"
+	_, err = repo.UpdateLink(ctx, "kept", domain.LinkUpdate{Original: &edited}, "bob")
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteLink(ctx, "deleted"))
+	repo.AddClicks("kept", 3)
+
+	snapshot := repo.Snapshot()
+	// changes after the snapshot is taken aren't encoded
+	_, err = repo.CreateOrGetShortenedURL(ctx, domain.Link{Original: "https://ozon.ru/late", Shortened: "late"})
+	require.NoError(t, err)
+	repo.AddClicks("kept", 5)
+
+	var buf bytes.Buffer
+	require.NoError(t, snapshot.Encode(&buf))
+
+	restored := inmem.NewURLRepository(pkginmem.NewPartitionedKVStorage(partitionsCount))
+	_, err = restored.CreateOrGetShortenedURL(ctx, domain.Link{Original: "https://ozon.ru/stale", Shortened: "stale"})
+	require.NoError(t, err)
+	require.NoError(t, restored.Restore(&buf))
+
+	link, err := restored.GetLink(ctx, "kept")
+	require.NoError(t, err)
+	require.Equal(t, kept.ID, link.ID)
+	require.Equal(t, edited, link.Original)
+	require.Equal(t, 2, link.Version)
+	require.Equal(t, int64(3), link.Clicks)
+
+	versions, err := restored.ListLinkVersions(ctx, "kept")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	require.Equal(t, "bob", versions[0].Author)
+
+	_, err = restored.GetLink(ctx, "deleted")
+	require.ErrorIs(t, err, domain.ErrOriginalNotFound)
+	_, err = restored.GetLink(ctx, "stale")
+	require.ErrorIs(t, err, domain.ErrOriginalNotFound)
+	_, err = restored.GetLink(ctx, "late")
+	require.ErrorIs(t, err, domain.ErrOriginalNotFound)
+	_, err = restored.GetOriginalURLByShortened(ctx, "expired")
+	require.ErrorIs(t, err, domain.ErrLinkExpired)
+
+	links, err := restored.ListLinks(ctx, domain.LinkFilter{OwnerID: "alice"}, domain.Page{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+
+	// ids continue after restored links
+	created, err := restored.CreateOrGetShortenedURL(ctx, domain.Link{Original: "https://ozon.ru/new", Shortened: "new"})
+	require.NoError(t, err)
+	require.Equal(t, int64(4), created.ID)
+}
+
+func TestAPIKeyRepository_SnapshotRestore(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := inmem.NewAPIKeyRepository()
+
+	key := domain.APIKey{ID: "key-1", OwnerID: "alice", Hash: []byte("hash"), CreatedAt: time.Now().UTC()}
+	require.NoError(t, repo.CreateAPIKey(ctx, key))
+
+	var buf bytes.Buffer
+	require.NoError(t, repo.Snapshot().Encode(&buf))
+
+	restored := inmem.NewAPIKeyRepository()
+	require.NoError(t, restored.Restore(&buf))
+
+	got, err := restored.GetAPIKeyByHash(ctx, []byte("hash"))
+	require.NoError(t, err)
+	require.Equal(t, key.ID, got.ID)
+	require.Equal(t, key.Hash, got.Hash)
+}
@@ -2,52 +2,340 @@ package inmem
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"ozon_task/domain"
-	"ozon_task/internal/repository"
 	"ozon_task/pkg/infra/kv"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// URLRepository keeps links in the partitioned storage, every change locks only partitions of the changed keys.
+// A link is stored as a record under linkKey, a deduplicated link is also referenced by its domain.OriginalKey.
+// Records are listed by the index, which is changed along with them.
 type URLRepository struct {
-	storage kv.Storage
+	storage kv.AtomicStorage
+	index   *linkIndex
+	// lastID is the id of the latest created link, ids of deleted links aren't reused
+	lastID atomic.Int64
+	// clicks are written on every resolve, so they are kept outside of records
+	clicks sync.Map // domain.ShortURL -> *atomic.Int64
+	// now stamps creations and versions of links
+	now func() time.Time
 }
 
-func NewURLRepository(storage kv.Storage) repository.URL {
+func NewURLRepository(storage kv.AtomicStorage) *URLRepository {
+	return NewURLRepositoryWithClock(storage, time.Now)
+}
+
+// NewURLRepositoryWithClock returns the repository stamping links by the clock, so replicas of it
+// applying the same changes get the same links.
+func NewURLRepositoryWithClock(storage kv.AtomicStorage, now func() time.Time) *URLRepository {
 	return &URLRepository{
 		storage: storage,
+		index:   newLinkIndex(),
+		now:     now,
 	}
 }
 
-func (r *URLRepository) CreateOrGetShortenedURL(
-	_ context.Context,
-	original domain.URL,
-	shortened domain.ShortURL,
-) (domain.ShortURL, error) {
-	if existingShort, ok := r.storage.Get(original); ok {
-		return existingShort, nil
+// linkRecord is the value of a link in the storage.
+type linkRecord struct {
+	domain.Link
+	// Deduplicated is set while the original of the owner references the link, it's dropped
+	// by changes of destination or expiration.
+	Deduplicated bool `json:"deduplicated"`
+	// Versions of edited links, oldest first
+	Versions []domain.LinkVersion `json:"versions,omitempty"`
+}
+
+// linkKeyPrefix can't clash with original keys, which contain zero byte, or other prefixes.
+const linkKeyPrefix = "link:"
+
+func linkKey(shortened domain.ShortURL) string {
+	return linkKeyPrefix + shortened
+}
+
+func (r *URLRepository) CreateOrGetShortenedURL(_ context.Context, link domain.Link) (domain.Link, error) {
+	deduplicated := link.ExpiresAt.IsZero()
+	originalKey := domain.OriginalKey(link.OwnerID, link.Original)
+
+	if deduplicated {
+		if existing, ok := r.deduplicated(originalKey); ok {
+			return r.withClicks(existing.Link), nil
+		}
+	}
+
+	link.ID = r.lastID.Add(1)
+	link.Version = 1
+	link.Tags = slices.Clone(link.Tags)
+	link.CreatedAt = r.now().UTC()
+	taken := false
+	r.storage.Compute(linkKey(link.Shortened), func(value string, ok bool) (string, bool) {
+		if ok {
+			taken = true
+			return value, true
+		}
+		r.index.add(link)
+		// clicks of a deleted link with the same shortened url don't pass to the new one
+		r.clicks.Delete(link.Shortened)
+		return encodeRecord(linkRecord{Link: link, Deduplicated: deduplicated}), true
+	})
+	if taken {
+		return domain.Link{}, fmt.Errorf("CreateOrGetShortenedURL: shortened url %q is taken: %w",
+			link.Shortened, domain.ErrConflict)
+	}
+	if !deduplicated {
+		return link, nil
 	}
 
-	r.storage.Set(original, shortened)
-	r.storage.Set(shortened, original)
+	// concurrent creation of the same destination wins if its reference is set first
+	for !r.storage.SetIfAbsent(originalKey, link.Shortened) {
+		if existing, ok := r.deduplicated(originalKey); ok {
+			r.deleteRecord(link.Shortened)
+			return r.withClicks(existing.Link), nil
+		}
+	}
+
+	return link, nil
+}
+
+// deduplicated returns the link referenced by the original key. Stale references to deleted or changed links
+// are removed.
+func (r *URLRepository) deduplicated(originalKey string) (linkRecord, bool) {
+	shortened, ok := r.storage.Get(originalKey)
+	if !ok {
+		return linkRecord{}, false
+	}
 
-	return shortened, nil
+	record, ok := r.record(shortened)
+	if !ok || !record.Deduplicated || domain.OriginalKey(record.OwnerID, record.Original) != originalKey {
+		r.storage.CompareAndDelete(originalKey, shortened)
+		return linkRecord{}, false
+	}
+	return record, true
 }
 
 func (r *URLRepository) GetOriginalURLByShortened(
 	_ context.Context,
 	shortened domain.ShortURL,
 ) (domain.URL, error) {
-	if original, ok := r.storage.Get(shortened); ok {
-		return original, nil
+	record, ok := r.record(shortened)
+	if !ok {
+		return "", domain.ErrOriginalNotFound
+	}
+	if record.Expired(time.Now()) {
+		return "", domain.ErrLinkExpired
 	}
-	return "", domain.ErrOriginalNotFound
+
+	return record.Original, nil
 }
 
 func (r *URLRepository) GetShortenedURLByOriginal(
 	_ context.Context,
+	owner domain.OwnerID,
 	original domain.URL,
 ) (domain.ShortURL, error) {
-	if shortened, ok := r.storage.Get(original); ok {
+	if shortened, ok := r.storage.Get(domain.OriginalKey(owner, original)); ok {
 		return shortened, nil
 	}
 	return "", domain.ErrShortenedNotFound
 }
+
+// ListLinks seeks the index from the cursor and checks the rest of the filter on records, newest first.
+func (r *URLRepository) ListLinks(
+	_ context.Context,
+	filter domain.LinkFilter,
+	page domain.Page,
+) ([]domain.Link, error) {
+	links := make([]domain.Link, 0, page.Limit)
+	afterID := page.AfterID
+	for len(links) < page.Limit {
+		limit := page.Limit - len(links)
+		indexed := r.index.seek(filter, afterID, limit)
+		for _, entry := range indexed {
+			// the link may be changed or deleted after the index is read
+			record, ok := r.record(entry.shortened)
+			if ok && record.ID == entry.id && filter.Match(record.Link) {
+				links = append(links, r.withClicks(record.Link))
+			}
+		}
+		if len(indexed) < limit {
+			break
+		}
+		afterID = indexed[len(indexed)-1].id
+	}
+
+	return links, nil
+}
+
+func (r *URLRepository) GetLink(_ context.Context, shortened domain.ShortURL) (domain.Link, error) {
+	record, ok := r.record(shortened)
+	if !ok {
+		return domain.Link{}, domain.ErrOriginalNotFound
+	}
+	return r.withClicks(record.Link), nil
+}
+
+func (r *URLRepository) UpdateLink(
+	_ context.Context,
+	shortened domain.ShortURL,
+	update domain.LinkUpdate,
+	author string,
+) (domain.Link, error) {
+	now := r.now().UTC()
+
+	var previous, updated linkRecord
+	var decoded bool
+	found := r.storage.Update(linkKey(shortened), func(value string) (string, bool) {
+		previous, decoded = decodeRecord(value)
+		if !decoded {
+			return "", false
+		}
+		updated = applyUpdate(previous, update, author, now)
+		r.index.update(previous.Link, updated.Link)
+		return encodeRecord(updated), true
+	})
+	if !found || !decoded {
+		return domain.Link{}, domain.ErrOriginalNotFound
+	}
+
+	// changed destination or expiration stops deduplication of the link
+	if previous.Deduplicated && !updated.Deduplicated {
+		r.storage.CompareAndDelete(domain.OriginalKey(previous.OwnerID, previous.Original), shortened)
+	}
+
+	return r.withClicks(updated.Link), nil
+}
+
+// applyUpdate applies non-nil fields of the update and records new version if destination is changed.
+func applyUpdate(record linkRecord, update domain.LinkUpdate, author string, now time.Time) linkRecord {
+	if update.Original != nil {
+		record.Versions = append(recordVersions(record), domain.LinkVersion{
+			Version:   record.Version + 1,
+			Original:  *update.Original,
+			Author:    author,
+			CreatedAt: now,
+		})
+		record.Original = *update.Original
+		record.Version++
+	}
+	if update.Tags != nil {
+		record.Tags = slices.Clone(*update.Tags)
+	}
+	if update.ExpiresAt != nil {
+		record.ExpiresAt = *update.ExpiresAt
+	}
+	if update.Original != nil || update.ExpiresAt != nil {
+		record.Deduplicated = false
+	}
+
+	return record
+}
+
+func (r *URLRepository) ListLinkVersions(
+	_ context.Context,
+	shortened domain.ShortURL,
+) ([]domain.LinkVersion, error) {
+	record, ok := r.record(shortened)
+	if !ok {
+		return nil, domain.ErrOriginalNotFound
+	}
+
+	versions := recordVersions(record)
+	slices.Reverse(versions)
+	return versions, nil
+}
+
+// recordVersions returns a copy of recorded versions, links that were never edited have only the first one.
+func recordVersions(record linkRecord) []domain.LinkVersion {
+	if len(record.Versions) != 0 {
+		return slices.Clone(record.Versions)
+	}
+
+	return []domain.LinkVersion{{
+		Version:   record.Version,
+		Original:  record.Original,
+		Author:    record.OwnerID,
+		CreatedAt: record.CreatedAt,
+	}}
+}
+
+func (r *URLRepository) DeleteLink(_ context.Context, shortened domain.ShortURL) error {
+	record, ok := r.deleteRecord(shortened)
+	if !ok {
+		return domain.ErrOriginalNotFound
+	}
+
+	if record.Deduplicated {
+		r.storage.CompareAndDelete(domain.OriginalKey(record.OwnerID, record.Original), shortened)
+	}
+
+	return nil
+}
+
+func (r *URLRepository) RecordClick(_ context.Context, shortened domain.ShortURL) error {
+	r.AddClicks(shortened, 1)
+	return nil
+}
+
+// AddClicks adds clicks counted elsewhere to the counter of the link. Clicks of missing links are dropped,
+// e.g. clicks flushed by followers after the link is deleted.
+func (r *URLRepository) AddClicks(shortened domain.ShortURL, clicks int64) {
+	// the record is locked, so the counter can't be added after deleteRecord drops it
+	r.storage.Compute(linkKey(shortened), func(value string, ok bool) (string, bool) {
+		if ok {
+			counter, _ := r.clicks.LoadOrStore(shortened, new(atomic.Int64))
+			counter.(*atomic.Int64).Add(clicks)
+		}
+		return value, ok
+	})
+}
+
+// deleteRecord deletes the record along with its index entries and clicks and reports whether it existed.
+func (r *URLRepository) deleteRecord(shortened domain.ShortURL) (linkRecord, bool) {
+	var record linkRecord
+	found := false
+	r.storage.Compute(linkKey(shortened), func(value string, ok bool) (string, bool) {
+		found = ok
+		if decoded, ok := decodeRecord(value); found && ok {
+			record = decoded
+			r.index.remove(record.Link)
+		}
+		if found {
+			r.clicks.Delete(shortened)
+		}
+		return "", false
+	})
+	return record, found
+}
+
+func (r *URLRepository) record(shortened domain.ShortURL) (linkRecord, bool) {
+	value, ok := r.storage.Get(linkKey(shortened))
+	if !ok {
+		return linkRecord{}, false
+	}
+	return decodeRecord(value)
+}
+
+func (r *URLRepository) withClicks(link domain.Link) domain.Link {
+	if counter, ok := r.clicks.Load(link.Shortened); ok {
+		link.Clicks = counter.(*atomic.Int64).Load()
+	}
+	return link
+}
+
+func encodeRecord(record linkRecord) string {
+	// record consists of plain values, so encoding can't fail
+	data, _ := json.Marshal(record)
+	return string(data)
+}
+
+func decodeRecord(value string) (linkRecord, bool) {
+	var record linkRecord
+	if err := json.Unmarshal([]byte(value), &record); err != nil {
+		return linkRecord{}, false
+	}
+	return record, true
+}
@@ -1,9 +1,14 @@
 package inmem_test
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"sync"
 	"testing"
+	"time"
 
 	"ozon_task/domain"
 	"ozon_task/internal/repository/inmem"
@@ -20,24 +25,47 @@ func TestURLRepository_CreateOrGetShortenedURL(t *testing.T) {
 	originalURL := "https://ozon.ru"
 	shortenedURL := "abc123XYZ"
 
-	result, err := repo.CreateOrGetShortenedURL(ctx, originalURL, shortenedURL)
+	result, err := repo.CreateOrGetShortenedURL(ctx, domain.Link{Original: originalURL, Shortened: shortenedURL})
 	require.NoError(t, err)
-	require.Equal(t, shortenedURL, result)
+	require.Equal(t, shortenedURL, result.Shortened)
+	require.Equal(t, 1, result.Version)
 
-	result2, err := repo.CreateOrGetShortenedURL(ctx, originalURL, "differentShort")
+	result2, err := repo.CreateOrGetShortenedURL(ctx, domain.Link{Original: originalURL, Shortened: "differentShort"})
 	require.NoError(t, err)
-	require.Equal(t, shortenedURL, result2)
+	require.Equal(t, shortenedURL, result2.Shortened)
+}
+
+func TestURLRepository_CreateOrGetShortenedURL_ScopedToOwner(t *testing.T) {
+	ctx := context.Background()
+	repo := inmem.NewURLRepository(pkginmem.NewPartitionedKVStorage(partitionsCount))
+
+	originalURL := "https://ozon.ru"
+	first, err := repo.CreateOrGetShortenedURL(ctx, domain.Link{Original: originalURL, Shortened: "aaaaaaaaaa", OwnerID: "first"})
+	require.NoError(t, err)
+	second, err := repo.CreateOrGetShortenedURL(ctx, domain.Link{Original: originalURL, Shortened: "bbbbbbbbbb", OwnerID: "second"})
+	require.NoError(t, err)
+	require.Equal(t, "bbbbbbbbbb", second.Shortened)
+
+	again, err := repo.CreateOrGetShortenedURL(ctx, domain.Link{Original: originalURL, Shortened: "cccccccccc", OwnerID: "first"})
+	require.NoError(t, err)
+	require.Equal(t, first.Shortened, again.Shortened)
+
+	found, err := repo.GetShortenedURLByOriginal(ctx, "second", originalURL)
+	require.NoError(t, err)
+	require.Equal(t, second.Shortened, found)
+	_, err = repo.GetShortenedURLByOriginal(ctx, "", originalURL)
+	require.ErrorIs(t, err, domain.ErrShortenedNotFound)
 }
 
 func TestURLRepository_GetOriginalURLByShortened(t *testing.T) {
 	ctx := context.Background()
-	storage := pkginmem.NewPartitionedKVStorage(partitionsCount)
-	repo := inmem.NewURLRepository(storage)
+	repo := inmem.NewURLRepository(pkginmem.NewPartitionedKVStorage(partitionsCount))
 
 	originalURL := "https://ozon.ru"
 	shortenedURL := "abc123XYZ"
 
-	storage.Set(shortenedURL, originalURL)
+	_, err := repo.CreateOrGetShortenedURL(ctx, domain.Link{Original: originalURL, Shortened: shortenedURL})
+	require.NoError(t, err)
 	result, err := repo.GetOriginalURLByShortened(ctx, shortenedURL)
 	require.NoError(t, err)
 	require.Equal(t, originalURL, result)
@@ -55,11 +83,337 @@ func TestURLRepository_GetShortenedURLByOriginal(t *testing.T) {
 	shortenedURL := "abc123XYZ"
 	nonExistURL := "https://fintech.ozon.ru"
 
-	storage.Set(originalURL, shortenedURL)
-	result, err := repo.GetShortenedURLByOriginal(ctx, originalURL)
+	storage.Set(domain.OriginalKey("", originalURL), shortenedURL)
+	result, err := repo.GetShortenedURLByOriginal(ctx, "", originalURL)
 	require.NoError(t, err)
 	require.Equal(t, shortenedURL, result)
 
-	_, err = repo.GetShortenedURLByOriginal(ctx, nonExistURL)
+	_, err = repo.GetShortenedURLByOriginal(ctx, "", nonExistURL)
+	require.ErrorIs(t, err, domain.ErrShortenedNotFound)
+}
+
+func TestURLRepository_ListLinks(t *testing.T) {
+	ctx := context.Background()
+	storage := pkginmem.NewPartitionedKVStorage(partitionsCount)
+	repo := inmem.NewURLRepository(storage)
+
+	links := []domain.Link{
+		{Original: "https://ozon.ru/a", Shortened: "aaaaaaaaaa", OwnerID: "first", Tags: []domain.Tag{"promo"}},
+		{Original: "https://fintech.ozon.ru/b", Shortened: "bbbbbbbbbb", OwnerID: "second"},
+		{Original: "https://OZON.ru/Sale", Shortened: "cccccccccc", OwnerID: "first", Tags: []domain.Tag{"promo", "sale"}},
+		{Original: "https://example.com", Shortened: "dddddddddd", OwnerID: "first"},
+	}
+	for _, link := range links {
+		_, err := repo.CreateOrGetShortenedURL(ctx, link)
+		require.NoError(t, err)
+	}
+
+	shortened := func(links []domain.Link) []domain.ShortURL {
+		res := make([]domain.ShortURL, 0, len(links))
+		for _, link := range links {
+			res = append(res, link.Shortened)
+		}
+		return res
+	}
+
+	tests := []struct {
+		name   string
+		filter domain.LinkFilter
+		page   domain.Page
+		want   []domain.ShortURL
+	}{
+		{
+			name: "All newest first",
+			page: domain.Page{Limit: 10},
+			want: []domain.ShortURL{"dddddddddd", "cccccccccc", "bbbbbbbbbb", "aaaaaaaaaa"},
+		},
+		{
+			name: "Limit and cursor",
+			page: domain.Page{AfterID: 3, Limit: 1},
+			want: []domain.ShortURL{"bbbbbbbbbb"},
+		},
+		{
+			name:   "Owner with cursor",
+			filter: domain.LinkFilter{OwnerID: "first"},
+			page:   domain.Page{AfterID: 4, Limit: 10},
+			want:   []domain.ShortURL{"cccccccccc", "aaaaaaaaaa"},
+		},
+		{
+			name:   "Host is case-insensitive",
+			filter: domain.LinkFilter{Host: "Ozon.RU"},
+			page:   domain.Page{Limit: 10},
+			want:   []domain.ShortURL{"cccccccccc", "aaaaaaaaaa"},
+		},
+		{
+			name:   "Tag and owner",
+			filter: domain.LinkFilter{OwnerID: "first", Tag: "sale"},
+			page:   domain.Page{Limit: 10},
+			want:   []domain.ShortURL{"cccccccccc"},
+		},
+		{
+			name:   "Search",
+			filter: domain.LinkFilter{Search: "sale"},
+			page:   domain.Page{Limit: 10},
+			want:   []domain.ShortURL{"cccccccccc"},
+		},
+		{
+			name:   "Created in the future",
+			filter: domain.LinkFilter{CreatedAfter: time.Now().Add(time.Hour)},
+			page:   domain.Page{Limit: 10},
+			want:   []domain.ShortURL{},
+		},
+		{
+			name:   "Unknown tag",
+			filter: domain.LinkFilter{Tag: "unknown"},
+			page:   domain.Page{Limit: 10},
+			want:   []domain.ShortURL{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.ListLinks(ctx, tt.filter, tt.page)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, shortened(got))
+		})
+	}
+}
+
+func TestURLRepository_ListLinks_Pages(t *testing.T) {
+	ctx := context.Background()
+	repo := inmem.NewURLRepository(pkginmem.NewPartitionedKVStorage(partitionsCount))
+
+	const count = 50
+	for i := range count {
+		original := fmt.Sprintf("https://ozon.ru/%d", i)
+		if i%10 == 0 {
+			original = fmt.Sprintf("https://ozon.ru/sale/%d", i)
+		}
+		_, err := repo.CreateOrGetShortenedURL(ctx, domain.Link{Original: original, Shortened: fmt.Sprintf("short%05d", i)})
+		require.NoError(t, err)
+	}
+	require.NoError(t, repo.DeleteLink(ctx, "short00040"))
+
+	// pages are read by the cursor until one is shorter than the limit
+	var got []domain.ShortURL
+	page := domain.Page{Limit: 2}
+	for {
+		links, err := repo.ListLinks(ctx, domain.LinkFilter{Search: "sale"}, page)
+		require.NoError(t, err)
+		for _, link := range links {
+			got = append(got, link.Shortened)
+		}
+		if len(links) < page.Limit {
+			break
+		}
+		page.AfterID = links[len(links)-1].ID
+	}
+
+	require.Equal(t, []domain.ShortURL{"short00030", "short00020", "short00010", "short00000"}, got)
+}
+
+func TestURLRepository_UpdateLink_Index(t *testing.T) {
+	ctx := context.Background()
+	repo := inmem.NewURLRepository(pkginmem.NewPartitionedKVStorage(partitionsCount))
+
+	_, err := repo.CreateOrGetShortenedURL(ctx, domain.Link{
+		Original:  "https://ozon.ru",
+		Shortened: "aaaaaaaaaa",
+		OwnerID:   "team",
+		Tags:      []domain.Tag{"promo"},
+	})
+	require.NoError(t, err)
+
+	newURL := "https://fintech.ozon.ru"
+	tags := []domain.Tag{"sale"}
+	_, err = repo.UpdateLink(ctx, "aaaaaaaaaa", domain.LinkUpdate{Original: &newURL, Tags: &tags}, "key")
+	require.NoError(t, err)
+
+	for _, filter := range []domain.LinkFilter{{Host: "ozon.ru"}, {Tag: "promo"}} {
+		links, err := repo.ListLinks(ctx, filter, domain.Page{Limit: 10})
+		require.NoError(t, err)
+		require.Empty(t, links, filter)
+	}
+	links, err := repo.ListLinks(ctx, domain.LinkFilter{OwnerID: "team", Host: "fintech.ozon.ru", Tag: "sale"}, domain.Page{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+}
+
+func TestURLRepository_UpdateLink(t *testing.T) {
+	ctx := context.Background()
+	storage := pkginmem.NewPartitionedKVStorage(partitionsCount)
+	repo := inmem.NewURLRepository(storage)
+
+	originalURL := "https://ozon.ru/old"
+	newURL := "https://fintech.ozon.ru/new"
+	shortenedURL := "abc123XYZ_"
+
+	_, err := repo.CreateOrGetShortenedURL(ctx, domain.Link{Original: originalURL, Shortened: shortenedURL, OwnerID: "team"})
+	require.NoError(t, err)
+
+	link, err := repo.UpdateLink(ctx, shortenedURL, domain.LinkUpdate{Original: &newURL}, "key")
+	require.NoError(t, err)
+	require.Equal(t, newURL, link.Original)
+	require.Equal(t, 2, link.Version)
+
+	original, err := repo.GetOriginalURLByShortened(ctx, shortenedURL)
+	require.NoError(t, err)
+	require.Equal(t, newURL, original)
+
+	// edited link is not deduplicated by any of its destinations
+	_, err = repo.GetShortenedURLByOriginal(ctx, "team", originalURL)
+	require.ErrorIs(t, err, domain.ErrShortenedNotFound)
+	_, err = repo.GetShortenedURLByOriginal(ctx, "team", newURL)
+	require.ErrorIs(t, err, domain.ErrShortenedNotFound)
+
+	result, err := repo.CreateOrGetShortenedURL(ctx, domain.Link{Original: originalURL, Shortened: "otherShort", OwnerID: "team"})
+	require.NoError(t, err)
+	require.Equal(t, "otherShort", result.Shortened)
+
+	links, err := repo.ListLinks(ctx, domain.LinkFilter{Host: "fintech.ozon.ru"}, domain.Page{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	require.Equal(t, shortenedURL, links[0].Shortened)
+
+	versions, err := repo.ListLinkVersions(ctx, shortenedURL)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	require.Equal(t, domain.LinkVersion{Version: 2, Original: newURL, Author: "key", CreatedAt: versions[0].CreatedAt}, versions[0])
+	require.Equal(t, domain.LinkVersion{Version: 1, Original: originalURL, Author: "team", CreatedAt: versions[1].CreatedAt}, versions[1])
+
+	_, err = repo.UpdateLink(ctx, "nonexistent", domain.LinkUpdate{Original: &newURL}, "key")
+	require.ErrorIs(t, err, domain.ErrOriginalNotFound)
+	_, err = repo.ListLinkVersions(ctx, "nonexistent")
+	require.ErrorIs(t, err, domain.ErrOriginalNotFound)
+}
+
+func TestURLRepository_Expiration(t *testing.T) {
+	ctx := context.Background()
+	storage := pkginmem.NewPartitionedKVStorage(partitionsCount)
+	repo := inmem.NewURLRepository(storage)
+
+	originalURL := "https://ozon.ru"
+
+	expired, err := repo.CreateOrGetShortenedURL(ctx, domain.Link{
+		Original:  originalURL,
+		Shortened: "expired___",
+		ExpiresAt: time.Now().Add(-time.Minute),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "expired___", expired.Shortened)
+
+	// links with expiration are never deduplicated
+	other, err := repo.CreateOrGetShortenedURL(ctx, domain.Link{
+		Original:  originalURL,
+		Shortened: "other_____",
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "other_____", other.Shortened)
+	_, err = repo.GetShortenedURLByOriginal(ctx, "", originalURL)
+	require.ErrorIs(t, err, domain.ErrShortenedNotFound)
+
+	_, err = repo.GetOriginalURLByShortened(ctx, "expired___")
+	require.ErrorIs(t, err, domain.ErrLinkExpired)
+
+	noExpiration := time.Time{}
+	link, err := repo.UpdateLink(ctx, "expired___", domain.LinkUpdate{ExpiresAt: &noExpiration}, "key")
+	require.NoError(t, err)
+	require.True(t, link.ExpiresAt.IsZero())
+
+	original, err := repo.GetOriginalURLByShortened(ctx, "expired___")
+	require.NoError(t, err)
+	require.Equal(t, originalURL, original)
+}
+
+func TestURLRepository_DeleteLink(t *testing.T) {
+	ctx := context.Background()
+	storage := pkginmem.NewPartitionedKVStorage(partitionsCount)
+	repo := inmem.NewURLRepository(storage)
+
+	originalURL := "https://ozon.ru"
+	shortenedURL := "abc123XYZ_"
+
+	_, err := repo.CreateOrGetShortenedURL(ctx, domain.Link{Original: originalURL, Shortened: shortenedURL})
+	require.NoError(t, err)
+
+	require.NoError(t, repo.RecordClick(ctx, shortenedURL))
+	require.NoError(t, repo.RecordClick(ctx, shortenedURL))
+	link, err := repo.GetLink(ctx, shortenedURL)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), link.Clicks)
+
+	require.NoError(t, repo.DeleteLink(ctx, shortenedURL))
+
+	_, err = repo.GetLink(ctx, shortenedURL)
+	require.ErrorIs(t, err, domain.ErrOriginalNotFound)
+	_, err = repo.GetOriginalURLByShortened(ctx, shortenedURL)
+	require.ErrorIs(t, err, domain.ErrOriginalNotFound)
+	_, err = repo.GetShortenedURLByOriginal(ctx, "", originalURL)
 	require.ErrorIs(t, err, domain.ErrShortenedNotFound)
+
+	links, err := repo.ListLinks(ctx, domain.LinkFilter{}, domain.Page{Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, links)
+
+	require.ErrorIs(t, repo.DeleteLink(ctx, shortenedURL), domain.ErrOriginalNotFound)
+}
+
+func TestURLRepository_DeleteLink_LateClicks(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := inmem.NewURLRepository(pkginmem.NewPartitionedKVStorage(partitionsCount))
+
+	alias := domain.Link{Original: "https://ozon.ru", Shortened: "vanity"}
+	_, err := repo.CreateOrGetShortenedURL(ctx, alias)
+	require.NoError(t, err)
+	require.NoError(t, repo.RecordClick(ctx, alias.Shortened))
+	require.NoError(t, repo.DeleteLink(ctx, alias.Shortened))
+
+	// clicks counted by a follower before the deletion are flushed after it
+	repo.AddClicks(alias.Shortened, 5)
+	var snapshot bytes.Buffer
+	require.NoError(t, repo.Snapshot().Encode(&snapshot))
+	require.NotContains(t, snapshot.String(), `"vanity":`)
+
+	_, err = repo.CreateOrGetShortenedURL(ctx, alias)
+	require.NoError(t, err)
+	link, err := repo.GetLink(ctx, alias.Shortened)
+	require.NoError(t, err)
+	require.Zero(t, link.Clicks)
+}
+
+func TestURLRepository_ConcurrentCreations(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := inmem.NewURLRepository(pkginmem.NewPartitionedKVStorage(partitionsCount))
+
+	const workers = 16
+	results := make([]domain.Link, workers)
+	var wg sync.WaitGroup
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			link, err := repo.CreateOrGetShortenedURL(ctx, domain.Link{
+				Original:  "https://ozon.ru",
+				Shortened: fmt.Sprintf("short%05d", i),
+			})
+			assert.NoError(t, err)
+			results[i] = link
+		}()
+	}
+	wg.Wait()
+
+	// all creations of the destination get the same link
+	for _, link := range results {
+		require.Equal(t, results[0].Shortened, link.Shortened)
+		require.Equal(t, results[0].ID, link.ID)
+	}
+	links, err := repo.ListLinks(ctx, domain.LinkFilter{}, domain.Page{Limit: workers})
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+
+	_, err = repo.CreateOrGetShortenedURL(ctx, domain.Link{Original: "https://ozon.ru/other", Shortened: results[0].Shortened})
+	require.ErrorIs(t, err, domain.ErrConflict)
 }
@@ -0,0 +1,179 @@
+package migration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"ozon_task/domain"
+	"ozon_task/internal/repository"
+	"ozon_task/internal/transfer"
+	pkglog "ozon_task/pkg/log"
+	"ozon_task/pkg/resilience"
+	"time"
+)
+
+// Backfiller copies links stored in the source backend before the migration to the target one.
+// Links are copied newest first, links created after the start are written to both backends anyway.
+// Copying is idempotent, so the backfill may be restarted from any position.
+type Backfiller struct {
+	source  repository.URL
+	target  repository.URL
+	cfg     BackfillConfig
+	metrics *Metrics
+	log     *slog.Logger
+}
+
+func NewBackfiller(
+	source, target repository.URL,
+	cfg BackfillConfig,
+	metrics *Metrics,
+	log *slog.Logger,
+) *Backfiller {
+	return &Backfiller{
+		source:  source,
+		target:  target,
+		cfg:     cfg,
+		metrics: metrics,
+		log:     log.With(slog.String("component", "backfill")),
+	}
+}
+
+// backfillCheckpoint is the position of the backfill, links with ids less than AfterID aren't copied yet.
+type backfillCheckpoint struct {
+	AfterID   int64     `json:"after_id"`
+	Done      bool      `json:"done"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Run copies links until all of them are copied or the context is done. Failures of the storages
+// are retried, the batch is copied again.
+func (b *Backfiller) Run(ctx context.Context) error {
+	position, err := b.loadCheckpoint()
+	if err != nil {
+		return fmt.Errorf("Backfiller.Run: %w", err)
+	}
+	if position.Done {
+		b.metrics.backfillDone.Store(true)
+		b.log.Info("backfill is already done")
+		return nil
+	}
+
+	b.log.Info("backfill started", slog.Int64("after_id", position.AfterID))
+	page := domain.Page{AfterID: position.AfterID, Limit: min(max(b.cfg.BatchSize, 1), domain.MaxPageSize)}
+	for {
+		links, err := b.copyBatch(ctx, page)
+		if err != nil {
+			if !domain.IsTransient(domain.ErrorCodeOf(err)) {
+				return fmt.Errorf("Backfiller.Run: %w", err)
+			}
+
+			b.log.Warn("backfill batch failed, retrying", slog.Int64("after_id", page.AfterID), pkglog.Err(err))
+			if !resilience.Sleep(ctx, domain.TransientRetryAfter) {
+				return fmt.Errorf("Backfiller.Run: %w", ctx.Err())
+			}
+			continue
+		}
+
+		done := len(links) < page.Limit
+		if !done {
+			page.AfterID = links[len(links)-1].ID
+		}
+		if err = b.saveCheckpoint(backfillCheckpoint{AfterID: page.AfterID, Done: done}); err != nil {
+			return fmt.Errorf("Backfiller.Run: %w", err)
+		}
+		if done {
+			break
+		}
+
+		if !resilience.Sleep(ctx, b.cfg.Pause) {
+			return fmt.Errorf("Backfiller.Run: %w", ctx.Err())
+		}
+	}
+
+	b.metrics.backfillDone.Store(true)
+	b.log.Info("backfill done")
+	return nil
+}
+
+// copyBatch copies the page of links, the copied ones are counted even if the batch fails.
+func (b *Backfiller) copyBatch(ctx context.Context, page domain.Page) ([]domain.Link, error) {
+	links, err := b.source.ListLinks(ctx, domain.LinkFilter{}, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list links: %w", err)
+	}
+
+	for _, listed := range links {
+		// the link is read again, so links deleted or changed after listing aren't copied in the stale state
+		link, err := b.source.GetLink(ctx, listed.Shortened)
+		if errors.Is(err, domain.ErrOriginalNotFound) {
+			b.log.Debug("link is deleted before copying", slog.String("shortened", listed.Shortened))
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read link %s: %w", listed.Shortened, err)
+		}
+
+		result, existing, err := transfer.Store(ctx, b.target, transfer.NewRecord(link))
+		switch {
+		case err != nil && (domain.IsTransient(domain.ErrorCodeOf(err)) || ctx.Err() != nil):
+			return nil, fmt.Errorf("failed to copy link %s: %w", link.Shortened, err)
+		case err != nil:
+			b.metrics.backfilled[copyError].Add(1)
+			b.log.Warn("failed to copy link", slog.String("shortened", link.Shortened), pkglog.Err(err))
+		case result == transfer.Conflicting:
+			b.metrics.backfilled[copyConflict].Add(1)
+			b.log.Warn("target backend has conflicting link",
+				slog.String("shortened", link.Shortened),
+				slog.String("original", link.Original),
+				slog.String("target_shortened", existing.Shortened),
+				slog.String("target_original", existing.Original))
+		case result == transfer.Existing:
+			b.metrics.backfilled[copyExisting].Add(1)
+		default:
+			b.metrics.backfilled[copyOK].Add(1)
+		}
+	}
+	return links, nil
+}
+
+func (b *Backfiller) loadCheckpoint() (backfillCheckpoint, error) {
+	if b.cfg.Checkpoint == "" {
+		return backfillCheckpoint{}, nil
+	}
+
+	data, err := os.ReadFile(b.cfg.Checkpoint)
+	if errors.Is(err, fs.ErrNotExist) {
+		return backfillCheckpoint{}, nil
+	}
+	if err != nil {
+		return backfillCheckpoint{}, fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+
+	var position backfillCheckpoint
+	if err = json.Unmarshal(data, &position); err != nil {
+		return backfillCheckpoint{}, fmt.Errorf("malformed checkpoint %s: %w", b.cfg.Checkpoint, err)
+	}
+	return position, nil
+}
+
+// saveCheckpoint replaces the checkpoint file atomically, so an interrupted write keeps the previous one.
+func (b *Backfiller) saveCheckpoint(position backfillCheckpoint) error {
+	if b.cfg.Checkpoint == "" {
+		return nil
+	}
+
+	position.UpdatedAt = time.Now().UTC()
+	data, err := json.Marshal(position)
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+
+	if err = transfer.WriteFileAtomic(b.cfg.Checkpoint, data); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package migration
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"ozon_task/domain"
+	"ozon_task/internal/repository"
+)
+
+// flakyRepo fails listing of links once after the limit of calls.
+type flakyRepo struct {
+	repository.URL
+	limit int
+}
+
+func (r *flakyRepo) ListLinks(ctx context.Context, filter domain.LinkFilter, page domain.Page) ([]domain.Link, error) {
+	r.limit--
+	if r.limit == 0 {
+		return nil, fmt.Errorf("connection refused: %w", domain.ErrStorageUnavailable)
+	}
+	return r.URL.ListLinks(ctx, filter, page)
+}
+
+func TestBackfiller_Run(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	source, target := newRepo(), newRepo()
+	for i := range 7 {
+		_, err := source.CreateOrGetShortenedURL(ctx, domain.Link{
+			Original:  fmt.Sprintf("https://ozon.ru/%d", i),
+			Shortened: fmt.Sprintf("AAAAAAA%03d", i),
+		})
+		require.NoError(t, err)
+	}
+	// written by the dual writes already
+	_, err := target.CreateOrGetShortenedURL(ctx, domain.Link{Original: "https://ozon.ru/6", Shortened: "AAAAAAA006"})
+	require.NoError(t, err)
+	_, err = target.CreateOrGetShortenedURL(ctx, domain.Link{Original: "https://ozon.ru/other", Shortened: "AAAAAAA005"})
+	require.NoError(t, err)
+
+	cfg := BackfillConfig{Enabled: true, BatchSize: 3, Checkpoint: filepath.Join(t.TempDir(), "backfill.json")}
+	metrics := NewMetrics()
+	err = NewBackfiller(&flakyRepo{URL: source, limit: 2}, target, cfg, metrics, dummyLogger).Run(ctx)
+	require.NoError(t, err)
+
+	for i := range 5 {
+		original, err := target.GetOriginalURLByShortened(ctx, fmt.Sprintf("AAAAAAA%03d", i))
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("https://ozon.ru/%d", i), original)
+	}
+	// listing of the second batch fails and is retried
+	require.Equal(t, uint64(5), metrics.backfilled[copyOK].Load())
+	require.Equal(t, uint64(1), metrics.backfilled[copyExisting].Load())
+	require.Equal(t, uint64(1), metrics.backfilled[copyConflict].Load())
+	require.True(t, metrics.backfillDone.Load())
+
+	var buf bytes.Buffer
+	metrics.WriteMetrics(&buf)
+	require.Contains(t, buf.String(), `storage_migration_backfilled_links_total{result="ok"} 5`)
+	require.Contains(t, buf.String(), "storage_migration_backfill_done 1")
+
+	// the finished backfill isn't repeated after restart
+	metrics = NewMetrics()
+	err = NewBackfiller(source, target, cfg, metrics, dummyLogger).Run(ctx)
+	require.NoError(t, err)
+	require.Zero(t, metrics.backfilled[copyExisting].Load())
+	require.True(t, metrics.backfillDone.Load())
+}
+
+// deletingRepo deletes the listed links right after listing, as concurrent requests would do.
+type deletingRepo struct {
+	repository.URL
+}
+
+func (r *deletingRepo) ListLinks(ctx context.Context, filter domain.LinkFilter, page domain.Page) ([]domain.Link, error) {
+	links, err := r.URL.ListLinks(ctx, filter, page)
+	for _, link := range links {
+		if link.Shortened == "AAAAAAA001" {
+			_ = r.URL.DeleteLink(ctx, link.Shortened)
+		}
+	}
+	return links, err
+}
+
+func TestBackfiller_SkipsDeletedLinks(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	source, target := newRepo(), newRepo()
+	for i := range 3 {
+		_, err := source.CreateOrGetShortenedURL(ctx, domain.Link{
+			Original:  fmt.Sprintf("https://ozon.ru/%d", i),
+			Shortened: fmt.Sprintf("AAAAAAA%03d", i),
+		})
+		require.NoError(t, err)
+	}
+
+	metrics := NewMetrics()
+	err := NewBackfiller(&deletingRepo{URL: source}, target, BackfillConfig{Enabled: true, BatchSize: 10}, metrics,
+		dummyLogger).Run(ctx)
+	require.NoError(t, err)
+
+	_, err = target.GetLink(ctx, "AAAAAAA001")
+	require.ErrorIs(t, err, domain.ErrOriginalNotFound)
+	require.Equal(t, uint64(2), metrics.backfilled[copyOK].Load())
+}
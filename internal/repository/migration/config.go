@@ -0,0 +1,47 @@
+package migration
+
+import (
+	"ozon_task/pkg/infra"
+	"time"
+)
+
+// Roles of backends during the migration.
+const (
+	// PrimarySource serves reads from storage.backend, it's the mode the migration is started in.
+	PrimarySource = "source"
+	// PrimaryTarget serves reads from the target backend, switching to it is the cut-over.
+	PrimaryTarget = "target"
+)
+
+// Config enables online migration of links from storage.backend to the target backend.
+// Writes go to both backends, reads are served by the primary one and compared with the other one.
+// After the cut-over is stable, storage.backend is set to the target and the migration is disabled.
+type Config struct {
+	Enabled bool `yaml:"enabled" env-default:"false"`
+	// Target is the backend links are moved to: postgres or memory.
+	Target string `yaml:"target"`
+	// Postgres is the database of the postgres target, postgres section is used if it's omitted
+	// and storage.backend isn't postgres.
+	Postgres *infra.PostgresConfig `yaml:"postgres"`
+	// Primary is the backend serving reads: source or target.
+	Primary string `yaml:"primary" env-default:"source"`
+	// SkipShadowReads disables comparing reads with the secondary backend.
+	SkipShadowReads bool `yaml:"skip_shadow_reads" env-default:"false"`
+	// SecondaryTimeout limits a write to the secondary backend, it's done before the response.
+	SecondaryTimeout time.Duration `yaml:"secondary_timeout" env-default:"1s"`
+	// ShadowTimeout limits a read of the secondary backend, it's done in background.
+	ShadowTimeout time.Duration `yaml:"shadow_timeout" env-default:"1s"`
+	// ShadowConcurrency limits shadow reads in flight, reads beyond it aren't compared.
+	ShadowConcurrency int            `yaml:"shadow_concurrency" env-default:"16"`
+	Backfill          BackfillConfig `yaml:"backfill"`
+}
+
+// BackfillConfig configures copying of links stored before the migration to the target backend.
+type BackfillConfig struct {
+	Enabled   bool `yaml:"enabled" env-default:"false"`
+	BatchSize int  `yaml:"batch_size" env-default:"500"`
+	// Pause between batches limits load of the backfill on the storages.
+	Pause time.Duration `yaml:"pause" env-default:"0s"`
+	// Checkpoint is the file keeping the position of the backfill between restarts, empty disables it.
+	Checkpoint string `yaml:"checkpoint"`
+}
@@ -0,0 +1,68 @@
+package migration
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// Results of shadow reads.
+const (
+	shadowMatch = iota
+	shadowMismatch
+	// shadowMissing is a link found by the primary backend only, e.g. one not backfilled yet.
+	shadowMissing
+	shadowError
+	// shadowSkipped is a read not compared as too many shadow reads are in flight.
+	shadowSkipped
+	shadowResults
+)
+
+var shadowResultNames = [shadowResults]string{"match", "mismatch", "missing", "error", "skipped"}
+
+// Results of writes to the secondary backend and of copying links by the backfill.
+const (
+	copyOK = iota
+	copyExisting
+	copyConflict
+	copyError
+	copyResults
+)
+
+var copyResultNames = [copyResults]string{"ok", "existing", "conflict", "error"}
+
+// Metrics counts results of the migration, they tell whether the target backend is ready for the cut-over.
+type Metrics struct {
+	shadowReads     [shadowResults]atomic.Uint64
+	secondaryWrites [copyResults]atomic.Uint64
+	backfilled      [copyResults]atomic.Uint64
+	backfillDone    atomic.Bool
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{}
+}
+
+// WriteMetrics writes the counters in Prometheus text format.
+func (m *Metrics) WriteMetrics(w io.Writer) {
+	writeCounters(w, "storage_migration_shadow_reads_total", "Reads of the secondary backend by result of comparing.",
+		m.shadowReads[:], shadowResultNames[:])
+	writeCounters(w, "storage_migration_secondary_writes_total", "Writes mirrored to the secondary backend by result.",
+		m.secondaryWrites[:], copyResultNames[:])
+	writeCounters(w, "storage_migration_backfilled_links_total", "Links copied to the target backend by result.",
+		m.backfilled[:], copyResultNames[:])
+
+	done := 0
+	if m.backfillDone.Load() {
+		done = 1
+	}
+	_, _ = fmt.Fprintf(w, "# HELP %[1]s Whether all links stored before the migration are copied.\n"+
+		"# TYPE %[1]s gauge\n%[1]s %d\n", "storage_migration_backfill_done", done)
+}
+
+func writeCounters(w io.Writer, name, help string, counters []atomic.Uint64, results []string) {
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	for i := range counters {
+		_, _ = fmt.Fprintf(w, "%s{result=%q} %d\n", name, results[i], counters[i].Load())
+	}
+}
@@ -0,0 +1,294 @@
+// Package migration moves links between backends online: writes go to both of them, reads of the
+// secondary backend are compared with the primary one in background, and the backfill copies links
+// stored before the migration.
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"ozon_task/domain"
+	"ozon_task/internal/repository"
+	"ozon_task/internal/transfer"
+	pkglog "ozon_task/pkg/log"
+	"slices"
+	"sync"
+	"time"
+)
+
+// URLRepository writes links to both backends and reads them from the primary one. Failed writes
+// of the secondary backend don't fail requests, they're logged and counted, as the backfill repairs them.
+// Listing and versions are read only from the primary backend, as ids and history are backend specific.
+type URLRepository struct {
+	primary   repository.URL
+	secondary repository.URL
+	cfg       Config
+	metrics   *Metrics
+	log       *slog.Logger
+
+	// shadows limits shadow reads in flight
+	shadows chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewURLRepository creates the repository migrating links from the source to the target backend,
+// the primary one is chosen by the config.
+func NewURLRepository(
+	source, target repository.URL,
+	cfg Config,
+	metrics *Metrics,
+	log *slog.Logger,
+) *URLRepository {
+	primary, secondary := source, target
+	if cfg.Primary == PrimaryTarget {
+		primary, secondary = target, source
+	}
+
+	return &URLRepository{
+		primary:   primary,
+		secondary: secondary,
+		cfg:       cfg,
+		metrics:   metrics,
+		log:       log.With(slog.String("primary", cfg.Primary)),
+		shadows:   make(chan struct{}, max(cfg.ShadowConcurrency, 1)),
+	}
+}
+
+// Close waits for shadow reads in flight.
+func (r *URLRepository) Close() {
+	r.wg.Wait()
+}
+
+func (r *URLRepository) CreateOrGetShortenedURL(ctx context.Context, link domain.Link) (domain.Link, error) {
+	created, err := r.primary.CreateOrGetShortenedURL(ctx, link)
+	if err != nil {
+		return created, err
+	}
+
+	ctx, cancel := r.secondaryContext(ctx)
+	defer cancel()
+
+	// the secondary backend keeps the shortened url chosen by the primary one
+	r.mirrorLink(ctx, "CreateOrGetShortenedURL", created)
+	return created, nil
+}
+
+func (r *URLRepository) GetOriginalURLByShortened(
+	ctx context.Context,
+	shortened domain.ShortURL,
+) (domain.URL, error) {
+	original, err := r.primary.GetOriginalURLByShortened(ctx, shortened)
+	r.shadow(ctx, "GetOriginalURLByShortened", shortened, original, err, func(ctx context.Context) (string, error) {
+		return r.secondary.GetOriginalURLByShortened(ctx, shortened)
+	})
+	return original, err
+}
+
+func (r *URLRepository) GetShortenedURLByOriginal(
+	ctx context.Context,
+	owner domain.OwnerID,
+	original domain.URL,
+) (domain.ShortURL, error) {
+	shortened, err := r.primary.GetShortenedURLByOriginal(ctx, owner, original)
+	r.shadow(ctx, "GetShortenedURLByOriginal", original, shortened, err, func(ctx context.Context) (string, error) {
+		return r.secondary.GetShortenedURLByOriginal(ctx, owner, original)
+	})
+	return shortened, err
+}
+
+func (r *URLRepository) ListLinks(
+	ctx context.Context,
+	filter domain.LinkFilter,
+	page domain.Page,
+) ([]domain.Link, error) {
+	return r.primary.ListLinks(ctx, filter, page)
+}
+
+func (r *URLRepository) GetLink(ctx context.Context, shortened domain.ShortURL) (domain.Link, error) {
+	link, err := r.primary.GetLink(ctx, shortened)
+	r.shadow(ctx, "GetLink", shortened, linkState(link), err, func(ctx context.Context) (string, error) {
+		link, err := r.secondary.GetLink(ctx, shortened)
+		return linkState(link), err
+	})
+	return link, err
+}
+
+func (r *URLRepository) UpdateLink(
+	ctx context.Context,
+	shortened domain.ShortURL,
+	update domain.LinkUpdate,
+	author string,
+) (domain.Link, error) {
+	link, err := r.primary.UpdateLink(ctx, shortened, update, author)
+	if err != nil {
+		return link, err
+	}
+
+	ctx, cancel := r.secondaryContext(ctx)
+	defer cancel()
+
+	_, err = r.secondary.UpdateLink(ctx, shortened, update, author)
+	switch {
+	case errors.Is(err, domain.ErrOriginalNotFound):
+		// the link isn't backfilled yet, it's copied in its updated state
+		r.mirrorLink(ctx, "UpdateLink", link)
+	default:
+		r.countWrite("UpdateLink", shortened, err)
+	}
+	return link, nil
+}
+
+func (r *URLRepository) ListLinkVersions(ctx context.Context, shortened domain.ShortURL) ([]domain.LinkVersion, error) {
+	return r.primary.ListLinkVersions(ctx, shortened)
+}
+
+func (r *URLRepository) DeleteLink(ctx context.Context, shortened domain.ShortURL) error {
+	if err := r.primary.DeleteLink(ctx, shortened); err != nil {
+		return err
+	}
+
+	ctx, cancel := r.secondaryContext(ctx)
+	defer cancel()
+
+	err := r.secondary.DeleteLink(ctx, shortened)
+	if errors.Is(err, domain.ErrOriginalNotFound) {
+		err = nil
+	}
+	r.countWrite("DeleteLink", shortened, err)
+	return nil
+}
+
+func (r *URLRepository) RecordClick(ctx context.Context, shortened domain.ShortURL) error {
+	if err := r.primary.RecordClick(ctx, shortened); err != nil {
+		return err
+	}
+
+	ctx, cancel := r.secondaryContext(ctx)
+	defer cancel()
+
+	// clicks aren't copied by the backfill, so they're counted on best effort basis
+	err := r.secondary.RecordClick(ctx, shortened)
+	if errors.Is(err, domain.ErrOriginalNotFound) {
+		err = nil
+	}
+	r.countWrite("RecordClick", shortened, err)
+	return nil
+}
+
+// mirrorLink stores the link of the primary backend in the secondary one.
+func (r *URLRepository) mirrorLink(ctx context.Context, op string, link domain.Link) {
+	result, existing, err := transfer.Store(ctx, r.secondary, transfer.NewRecord(link))
+	if result == transfer.Conflicting {
+		r.metrics.secondaryWrites[copyConflict].Add(1)
+		r.log.Warn("secondary backend has conflicting link",
+			slog.String("op", op),
+			slog.String("shortened", link.Shortened),
+			slog.String("original", link.Original),
+			slog.String("secondary_shortened", existing.Shortened),
+			slog.String("secondary_original", existing.Original))
+		return
+	}
+	r.countWrite(op, link.Shortened, err)
+}
+
+// secondaryContext limits the write to the secondary backend. The write isn't canceled with the request,
+// as the change is already stored by the primary backend.
+func (r *URLRepository) secondaryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), r.cfg.SecondaryTimeout)
+}
+
+func (r *URLRepository) countWrite(op string, shortened domain.ShortURL, err error) {
+	if err == nil {
+		r.metrics.secondaryWrites[copyOK].Add(1)
+		return
+	}
+
+	r.metrics.secondaryWrites[copyError].Add(1)
+	r.log.Warn("failed to write to secondary backend",
+		slog.String("op", op), slog.String("shortened", shortened), pkglog.Err(err))
+}
+
+// shadow compares the result of the primary backend with the read of the secondary one in background.
+// Failures of the primary backend aren't compared.
+func (r *URLRepository) shadow(
+	ctx context.Context,
+	op, key, want string,
+	wantErr error,
+	read func(ctx context.Context) (string, error),
+) {
+	if r.cfg.SkipShadowReads || (wantErr != nil && !lookupError(wantErr)) {
+		return
+	}
+
+	select {
+	case r.shadows <- struct{}{}:
+	default:
+		r.metrics.shadowReads[shadowSkipped].Add(1)
+		return
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer func() {
+			<-r.shadows
+			r.wg.Done()
+		}()
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.cfg.ShadowTimeout)
+		defer cancel()
+
+		got, err := read(ctx)
+		result := compare(want, wantErr, got, err)
+		r.metrics.shadowReads[result].Add(1)
+
+		switch result {
+		case shadowMismatch:
+			r.log.Warn("shadow read mismatch",
+				slog.String("op", op),
+				slog.String("key", key),
+				slog.String("primary", describe(want, wantErr)),
+				slog.String("secondary", describe(got, err)))
+		case shadowError:
+			r.log.Debug("shadow read failed", slog.String("op", op), slog.String("key", key), pkglog.Err(err))
+		}
+	}()
+}
+
+// lookupError reports whether the error is a result of the lookup rather than a failure of the backend.
+func lookupError(err error) bool {
+	code := domain.ErrorCodeOf(err)
+	return code == domain.CodeNotFound || code == domain.CodeExpired
+}
+
+func compare(want string, wantErr error, got string, err error) int {
+	switch {
+	case err != nil && !lookupError(err):
+		return shadowError
+	case wantErr == nil && domain.ErrorCodeOf(err) == domain.CodeNotFound:
+		return shadowMissing
+	case domain.ErrorCodeOf(wantErr) != domain.ErrorCodeOf(err) || want != got:
+		return shadowMismatch
+	default:
+		return shadowMatch
+	}
+}
+
+func describe(value string, err error) string {
+	if err != nil {
+		return string(domain.ErrorCodeOf(err))
+	}
+	return value
+}
+
+// linkState is the part of the link both backends must agree on, ids, versions and clicks differ.
+func linkState(link domain.Link) string {
+	tags := slices.Clone(link.Tags)
+	slices.Sort(tags)
+
+	var expiresAt string
+	if !link.ExpiresAt.IsZero() {
+		expiresAt = link.ExpiresAt.UTC().Format(time.RFC3339Nano)
+	}
+	return fmt.Sprintf("original=%s owner=%s tags=%q expires_at=%s", link.Original, link.OwnerID, tags, expiresAt)
+}
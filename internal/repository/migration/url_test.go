@@ -0,0 +1,190 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"ozon_task/domain"
+	"ozon_task/internal/repository"
+	"ozon_task/internal/repository/inmem"
+	pkginmem "ozon_task/pkg/infra/kv/inmem"
+)
+
+var dummyLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func newRepo() repository.URL {
+	return inmem.NewURLRepository(pkginmem.NewPartitionedKVStorage(4))
+}
+
+func testConfig(primary string) Config {
+	return Config{Enabled: true, Primary: primary, SecondaryTimeout: time.Second, ShadowTimeout: time.Second,
+		ShadowConcurrency: 4}
+}
+
+// failingRepo fails all writes and reads of links.
+type failingRepo struct {
+	repository.URL
+}
+
+func (failingRepo) CreateOrGetShortenedURL(context.Context, domain.Link) (domain.Link, error) {
+	return domain.Link{}, fmt.Errorf("connection refused: %w", domain.ErrStorageUnavailable)
+}
+
+func (failingRepo) GetLink(context.Context, domain.ShortURL) (domain.Link, error) {
+	return domain.Link{}, fmt.Errorf("connection refused: %w", domain.ErrStorageUnavailable)
+}
+
+func TestURLRepository_WritesToBothBackends(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	source, target := newRepo(), newRepo()
+	metrics := NewMetrics()
+	repo := NewURLRepository(source, target, testConfig(PrimarySource), metrics, dummyLogger)
+
+	created, err := repo.CreateOrGetShortenedURL(ctx, domain.Link{Original: "https://ozon.ru/a", OwnerID: "alice"})
+	require.NoError(t, err)
+
+	// the target keeps the shortened url chosen by the source
+	link, err := target.GetLink(ctx, created.Shortened)
+	require.NoError(t, err)
+	require.Equal(t, "https://ozon.ru/a", link.Original)
+	require.Equal(t, "alice", link.OwnerID)
+
+	tags := []domain.Tag{"promo"}
+	_, err = repo.UpdateLink(ctx, created.Shortened, domain.LinkUpdate{Tags: &tags}, "alice")
+	require.NoError(t, err)
+	link, err = target.GetLink(ctx, created.Shortened)
+	require.NoError(t, err)
+	require.Equal(t, tags, link.Tags)
+
+	require.NoError(t, repo.DeleteLink(ctx, created.Shortened))
+	_, err = target.GetLink(ctx, created.Shortened)
+	require.ErrorIs(t, err, domain.ErrOriginalNotFound)
+
+	require.Equal(t, uint64(3), metrics.secondaryWrites[copyOK].Load())
+}
+
+func TestURLRepository_UpdateCopiesMissingLink(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	source, target := newRepo(), newRepo()
+	created, err := source.CreateOrGetShortenedURL(ctx, domain.Link{Original: "https://ozon.ru/a"})
+	require.NoError(t, err)
+
+	repo := NewURLRepository(source, target, testConfig(PrimarySource), NewMetrics(), dummyLogger)
+	original := "https://ozon.ru/b"
+	_, err = repo.UpdateLink(ctx, created.Shortened, domain.LinkUpdate{Original: &original}, "alice")
+	require.NoError(t, err)
+
+	link, err := target.GetLink(ctx, created.Shortened)
+	require.NoError(t, err)
+	require.Equal(t, original, link.Original)
+}
+
+func TestURLRepository_SecondaryFailuresDontFailRequests(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	metrics := NewMetrics()
+	repo := NewURLRepository(newRepo(), failingRepo{newRepo()}, testConfig(PrimarySource), metrics, dummyLogger)
+
+	created, err := repo.CreateOrGetShortenedURL(ctx, domain.Link{Original: "https://ozon.ru/a"})
+	require.NoError(t, err)
+	_, err = repo.GetLink(ctx, created.Shortened)
+	require.NoError(t, err)
+	repo.Close()
+
+	require.Equal(t, uint64(1), metrics.secondaryWrites[copyError].Load())
+	require.Equal(t, uint64(1), metrics.shadowReads[shadowError].Load())
+}
+
+// hangingRepo blocks reads of links until the context is done.
+type hangingRepo struct {
+	repository.URL
+}
+
+func (hangingRepo) GetLink(ctx context.Context, _ domain.ShortURL) (domain.Link, error) {
+	<-ctx.Done()
+	return domain.Link{}, fmt.Errorf("query canceled: %w", ctx.Err())
+}
+
+func TestURLRepository_SecondaryWritesTimeOut(t *testing.T) {
+	t.Parallel()
+	cfg := testConfig(PrimarySource)
+	cfg.SecondaryTimeout = 50 * time.Millisecond
+	metrics := NewMetrics()
+	repo := NewURLRepository(newRepo(), hangingRepo{newRepo()}, cfg, metrics, dummyLogger)
+
+	start := time.Now()
+	_, err := repo.CreateOrGetShortenedURL(context.Background(), domain.Link{Original: "https://ozon.ru/a"})
+	require.NoError(t, err)
+	require.Less(t, time.Since(start), time.Second)
+	require.Equal(t, uint64(1), metrics.secondaryWrites[copyError].Load())
+}
+
+func TestURLRepository_ShadowReads(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	source, target := newRepo(), newRepo()
+	for _, link := range []domain.Link{
+		{Original: "https://ozon.ru/a", Shortened: "AAAAAAAAAA"},
+		{Original: "https://ozon.ru/b", Shortened: "BBBBBBBBBB"},
+	} {
+		_, err := source.CreateOrGetShortenedURL(ctx, link)
+		require.NoError(t, err)
+	}
+	_, err := target.CreateOrGetShortenedURL(ctx, domain.Link{Original: "https://ozon.ru/a", Shortened: "AAAAAAAAAA"})
+	require.NoError(t, err)
+	_, err = target.CreateOrGetShortenedURL(ctx, domain.Link{Original: "https://ozon.ru/c", Shortened: "CCCCCCCCCC"})
+	require.NoError(t, err)
+
+	metrics := NewMetrics()
+	repo := NewURLRepository(source, target, testConfig(PrimarySource), metrics, dummyLogger)
+
+	original, err := repo.GetOriginalURLByShortened(ctx, "AAAAAAAAAA")
+	require.NoError(t, err)
+	require.Equal(t, "https://ozon.ru/a", original)
+	// the link isn't backfilled yet
+	_, err = repo.GetLink(ctx, "BBBBBBBBBB")
+	require.NoError(t, err)
+	// the link is found only by the secondary backend
+	_, err = repo.GetOriginalURLByShortened(ctx, "CCCCCCCCCC")
+	require.ErrorIs(t, err, domain.ErrOriginalNotFound)
+	repo.Close()
+
+	require.Equal(t, uint64(1), metrics.shadowReads[shadowMatch].Load())
+	require.Equal(t, uint64(1), metrics.shadowReads[shadowMissing].Load())
+	require.Equal(t, uint64(1), metrics.shadowReads[shadowMismatch].Load())
+}
+
+func TestURLRepository_CutOver(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	source, target := newRepo(), newRepo()
+	_, err := target.CreateOrGetShortenedURL(ctx, domain.Link{Original: "https://ozon.ru/a", Shortened: "AAAAAAAAAA"})
+	require.NoError(t, err)
+
+	cfg := testConfig(PrimaryTarget)
+	cfg.SkipShadowReads = true
+	metrics := NewMetrics()
+	repo := NewURLRepository(source, target, cfg, metrics, dummyLogger)
+
+	original, err := repo.GetOriginalURLByShortened(ctx, "AAAAAAAAAA")
+	require.NoError(t, err)
+	require.Equal(t, "https://ozon.ru/a", original)
+
+	// writes keep going to the source, so the cut-over can be rolled back
+	created, err := repo.CreateOrGetShortenedURL(ctx, domain.Link{Original: "https://ozon.ru/b"})
+	require.NoError(t, err)
+	original, err = source.GetOriginalURLByShortened(ctx, created.Shortened)
+	require.NoError(t, err)
+	require.Equal(t, "https://ozon.ru/b", original)
+
+	repo.Close()
+	require.Zero(t, metrics.shadowReads[shadowMatch].Load())
+}
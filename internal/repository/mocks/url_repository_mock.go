@@ -4,6 +4,7 @@ package mocks
 
 import (
 	context "context"
+	domain "ozon_task/domain"
 
 	mock "github.com/stretchr/testify/mock"
 )
@@ -13,27 +14,73 @@ type URL struct {
 	mock.Mock
 }
 
-// CreateOrGetShortenedURL provides a mock function with given fields: ctx, original, shortened
-func (_m *URL) CreateOrGetShortenedURL(ctx context.Context, original string, shortened string) (string, error) {
-	ret := _m.Called(ctx, original, shortened)
+// CreateOrGetShortenedURL provides a mock function with given fields: ctx, link
+func (_m *URL) CreateOrGetShortenedURL(ctx context.Context, link domain.Link) (domain.Link, error) {
+	ret := _m.Called(ctx, link)
 
 	if len(ret) == 0 {
 		panic("no return value specified for CreateOrGetShortenedURL")
 	}
 
-	var r0 string
+	var r0 domain.Link
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, string) (string, error)); ok {
-		return rf(ctx, original, shortened)
+	if rf, ok := ret.Get(0).(func(context.Context, domain.Link) (domain.Link, error)); ok {
+		return rf(ctx, link)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string, string) string); ok {
-		r0 = rf(ctx, original, shortened)
+	if rf, ok := ret.Get(0).(func(context.Context, domain.Link) domain.Link); ok {
+		r0 = rf(ctx, link)
 	} else {
-		r0 = ret.Get(0).(string)
+		r0 = ret.Get(0).(domain.Link)
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
-		r1 = rf(ctx, original, shortened)
+	if rf, ok := ret.Get(1).(func(context.Context, domain.Link) error); ok {
+		r1 = rf(ctx, link)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteLink provides a mock function with given fields: ctx, shortened
+func (_m *URL) DeleteLink(ctx context.Context, shortened string) error {
+	ret := _m.Called(ctx, shortened)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteLink")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, shortened)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetLink provides a mock function with given fields: ctx, shortened
+func (_m *URL) GetLink(ctx context.Context, shortened string) (domain.Link, error) {
+	ret := _m.Called(ctx, shortened)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetLink")
+	}
+
+	var r0 domain.Link
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (domain.Link, error)); ok {
+		return rf(ctx, shortened)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) domain.Link); ok {
+		r0 = rf(ctx, shortened)
+	} else {
+		r0 = ret.Get(0).(domain.Link)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, shortened)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -69,9 +116,9 @@ func (_m *URL) GetOriginalURLByShortened(ctx context.Context, shortened string)
 	return r0, r1
 }
 
-// GetShortenedURLByOriginal provides a mock function with given fields: ctx, original
-func (_m *URL) GetShortenedURLByOriginal(ctx context.Context, original string) (string, error) {
-	ret := _m.Called(ctx, original)
+// GetShortenedURLByOriginal provides a mock function with given fields: ctx, owner, original
+func (_m *URL) GetShortenedURLByOriginal(ctx context.Context, owner string, original string) (string, error) {
+	ret := _m.Called(ctx, owner, original)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetShortenedURLByOriginal")
@@ -79,17 +126,123 @@ func (_m *URL) GetShortenedURLByOriginal(ctx context.Context, original string) (
 
 	var r0 string
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) (string, error)); ok {
-		return rf(ctx, original)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (string, error)); ok {
+		return rf(ctx, owner, original)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
-		r0 = rf(ctx, original)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) string); ok {
+		r0 = rf(ctx, owner, original)
 	} else {
 		r0 = ret.Get(0).(string)
 	}
 
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, owner, original)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListLinkVersions provides a mock function with given fields: ctx, shortened
+func (_m *URL) ListLinkVersions(ctx context.Context, shortened string) ([]domain.LinkVersion, error) {
+	ret := _m.Called(ctx, shortened)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListLinkVersions")
+	}
+
+	var r0 []domain.LinkVersion
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]domain.LinkVersion, error)); ok {
+		return rf(ctx, shortened)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []domain.LinkVersion); ok {
+		r0 = rf(ctx, shortened)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.LinkVersion)
+		}
+	}
+
 	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, original)
+		r1 = rf(ctx, shortened)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListLinks provides a mock function with given fields: ctx, filter, page
+func (_m *URL) ListLinks(ctx context.Context, filter domain.LinkFilter, page domain.Page) ([]domain.Link, error) {
+	ret := _m.Called(ctx, filter, page)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListLinks")
+	}
+
+	var r0 []domain.Link
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.LinkFilter, domain.Page) ([]domain.Link, error)); ok {
+		return rf(ctx, filter, page)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.LinkFilter, domain.Page) []domain.Link); ok {
+		r0 = rf(ctx, filter, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.Link)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.LinkFilter, domain.Page) error); ok {
+		r1 = rf(ctx, filter, page)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RecordClick provides a mock function with given fields: ctx, shortened
+func (_m *URL) RecordClick(ctx context.Context, shortened string) error {
+	ret := _m.Called(ctx, shortened)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RecordClick")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, shortened)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateLink provides a mock function with given fields: ctx, shortened, update, author
+func (_m *URL) UpdateLink(ctx context.Context, shortened string, update domain.LinkUpdate, author string) (domain.Link, error) {
+	ret := _m.Called(ctx, shortened, update, author)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateLink")
+	}
+
+	var r0 domain.Link
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, domain.LinkUpdate, string) (domain.Link, error)); ok {
+		return rf(ctx, shortened, update, author)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, domain.LinkUpdate, string) domain.Link); ok {
+		r0 = rf(ctx, shortened, update, author)
+	} else {
+		r0 = ret.Get(0).(domain.Link)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, domain.LinkUpdate, string) error); ok {
+		r1 = rf(ctx, shortened, update, author)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -0,0 +1,104 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ozon_task/domain"
+	"ozon_task/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type APIKeyRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewAPIKeyRepository(pool *pgxpool.Pool) repository.APIKey {
+	return &APIKeyRepository{
+		pool: pool,
+	}
+}
+
+const apiKeyColumns = `id, owner_id, name, key_hash, is_admin, created_at, revoked_at`
+
+func scanAPIKey(row pgx.Row) (domain.APIKey, error) {
+	var key domain.APIKey
+	err := row.Scan(&key.ID, &key.OwnerID, &key.Name, &key.Hash, &key.Admin, &key.CreatedAt, &key.RevokedAt)
+	return key, err
+}
+
+func (r *APIKeyRepository) CreateAPIKey(ctx context.Context, key domain.APIKey) error {
+	query := `
+        INSERT INTO api_keys (id, owner_id, name, key_hash, is_admin, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+    `
+
+	_, err := r.pool.Exec(ctx, query, key.ID, key.OwnerID, key.Name, key.Hash, key.Admin, key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("CreateAPIKey: query failed: %w", classify(err))
+	}
+
+	return nil
+}
+
+func (r *APIKeyRepository) GetAPIKeyByHash(ctx context.Context, hash []byte) (domain.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = $1`
+
+	key, err := scanAPIKey(r.pool.QueryRow(ctx, query, hash))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.APIKey{}, domain.ErrAPIKeyNotFound
+		}
+		return domain.APIKey{}, fmt.Errorf("GetAPIKeyByHash: query failed: %w", classify(err))
+	}
+
+	return key, nil
+}
+
+func (r *APIKeyRepository) ListAPIKeys(ctx context.Context, owner domain.OwnerID) ([]domain.APIKey, error) {
+	query := `
+        SELECT ` + apiKeyColumns + ` FROM api_keys
+        WHERE $1 = '' OR owner_id = $1
+        ORDER BY created_at, id
+    `
+
+	rows, err := r.pool.Query(ctx, query, owner)
+	if err != nil {
+		return nil, fmt.Errorf("ListAPIKeys: query failed: %w", classify(err))
+	}
+	defer rows.Close()
+
+	keys := make([]domain.APIKey, 0)
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ListAPIKeys: scan failed: %w", classify(err))
+		}
+		keys = append(keys, key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ListAPIKeys: rows iteration failed: %w", classify(err))
+	}
+
+	return keys, nil
+}
+
+func (r *APIKeyRepository) RevokeAPIKey(ctx context.Context, id string) (domain.APIKey, error) {
+	query := `
+        UPDATE api_keys SET revoked_at = COALESCE(revoked_at, now())
+        WHERE id = $1
+        RETURNING ` + apiKeyColumns
+
+	key, err := scanAPIKey(r.pool.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.APIKey{}, domain.ErrAPIKeyNotFound
+		}
+		return domain.APIKey{}, fmt.Errorf("RevokeAPIKey: query failed: %w", classify(err))
+	}
+
+	return key, nil
+}
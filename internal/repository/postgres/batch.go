@@ -0,0 +1,350 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ozon_task/domain"
+	"sync"
+	"time"
+)
+
+// BatchConfig enables write-behind batching of creations of links: concurrent creations wait a few
+// milliseconds for each other and are inserted by one statement.
+type BatchConfig struct {
+	Enabled bool `yaml:"enabled" env-default:"false"`
+	// MaxSize flushes the batch before MaxDelay once it has so many links.
+	MaxSize int `yaml:"max_size" env-default:"100"`
+	// MaxDelay is the longest time the first link of the batch waits for others.
+	MaxDelay time.Duration `yaml:"max_delay" env-default:"2ms"`
+	// QueueSize bounds links waiting for a batch, creations beyond it wait for a place in the queue.
+	QueueSize int `yaml:"queue_size" env-default:"1000"`
+	// Timeout limits the insert of a batch, callers may give up waiting for it earlier.
+	Timeout time.Duration `yaml:"timeout" env-default:"3s"`
+}
+
+// errBatcherClosed is returned by submit after close, the link is created without batching then.
+var errBatcherClosed = errors.New("batcher is closed")
+
+type createResult struct {
+	link domain.Link
+	err  error
+}
+
+type createRequest struct {
+	ctx  context.Context
+	link domain.Link
+	// result is buffered, so the batcher never waits for callers that gave up
+	result chan createResult
+}
+
+// batcher collects creations of links into batches by size and delay. Batches are flushed one at a time,
+// so a slow database makes batches bigger instead of opening more connections.
+type batcher struct {
+	cfg BatchConfig
+	// flush creates the links, the results are in the order of the links
+	flush func(ctx context.Context, links []domain.Link) []createResult
+	queue chan *createRequest
+	done  chan struct{}
+
+	// mu guards sends to the queue against closing it
+	mu     sync.RWMutex
+	closed bool
+}
+
+func newBatcher(cfg BatchConfig, flush func(ctx context.Context, links []domain.Link) []createResult) *batcher {
+	b := &batcher{
+		cfg:   cfg,
+		flush: flush,
+		queue: make(chan *createRequest, cfg.QueueSize),
+		done:  make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// submit waits for the link to be created by a batch. The link may still be created after
+// the context is done, if the batch is already sent.
+func (b *batcher) submit(ctx context.Context, link domain.Link) (domain.Link, error) {
+	req := &createRequest{ctx: ctx, link: link, result: make(chan createResult, 1)}
+	if err := b.enqueue(req); err != nil {
+		return domain.Link{}, err
+	}
+
+	select {
+	case res := <-req.result:
+		return res.link, res.err
+	case <-ctx.Done():
+		return domain.Link{}, ctx.Err()
+	}
+}
+
+func (b *batcher) enqueue(req *createRequest) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return errBatcherClosed
+	}
+
+	select {
+	case b.queue <- req:
+		return nil
+	case <-req.ctx.Done():
+		return req.ctx.Err()
+	}
+}
+
+// close flushes links in the queue and stops the batcher.
+func (b *batcher) close() {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.queue)
+	}
+	b.mu.Unlock()
+
+	<-b.done
+}
+
+func (b *batcher) run() {
+	defer close(b.done)
+
+	for req := range b.queue {
+		batch := []*createRequest{req}
+		timer := time.NewTimer(b.cfg.MaxDelay)
+	collect:
+		for len(batch) < b.cfg.MaxSize {
+			select {
+			case req, ok := <-b.queue:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, req)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+
+		b.flushBatch(batch)
+	}
+}
+
+func (b *batcher) flushBatch(batch []*createRequest) {
+	// callers that gave up aren't inserted
+	pending := batch[:0]
+	for _, req := range batch {
+		if err := req.ctx.Err(); err != nil {
+			req.result <- createResult{err: err}
+			continue
+		}
+		pending = append(pending, req)
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	links := make([]domain.Link, len(pending))
+	for i, req := range pending {
+		links[i] = req.link
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), b.cfg.Timeout)
+	defer cancel()
+	results := b.flush(ctx, links)
+	for i, req := range pending {
+		req.result <- results[i]
+	}
+}
+
+// createLinks inserts the batch of links by one statement. If the statement fails, e.g. by a conflict of
+// short codes, the links are created one by one, so only the failing ones fail.
+func (r *URLRepository) createLinks(ctx context.Context, links []domain.Link) []createResult {
+	results := make([]createResult, len(links))
+
+	// the same destination is created once, other creations of it get the same link
+	leaders := batchLeaders(links)
+	unique := make([]domain.Link, 0, len(links))
+	for i, leader := range leaders {
+		if leader == i {
+			unique = append(unique, links[i])
+		}
+	}
+
+	var created []createResult
+	if len(unique) == 1 {
+		link, err := r.createLink(ctx, unique[0])
+		created = []createResult{{link: link, err: err}}
+	} else {
+		created = r.insertLinks(ctx, unique)
+	}
+
+	next := 0
+	for i, leader := range leaders {
+		if leader == i {
+			results[i] = created[next]
+			next++
+		} else {
+			results[i] = results[leader]
+		}
+	}
+	return results
+}
+
+// batchLeaders returns the index of the first link of the same destination and owner for every link of the batch.
+// Links with expiration aren't deduplicated, so they lead themselves.
+func batchLeaders(links []domain.Link) []int {
+	leaders := make([]int, len(links))
+	first := make(map[string]int, len(links))
+	for i, link := range links {
+		leaders[i] = i
+		if !link.ExpiresAt.IsZero() {
+			continue
+		}
+		key := domain.OriginalKey(link.OwnerID, link.Original)
+		if leader, ok := first[key]; ok {
+			leaders[i] = leader
+			continue
+		}
+		first[key] = i
+	}
+	return leaders
+}
+
+// insertLinks creates links of distinct destinations by one statement, it has the semantics of createLink
+// for every link.
+func (r *URLRepository) insertLinks(ctx context.Context, links []domain.Link) []createResult {
+	var (
+		originals = make([]string, len(links))
+		shortened = make([]string, len(links))
+		owners    = make([]string, len(links))
+		tags      = make([]string, len(links))
+		digests   = make([][]byte, len(links))
+		expiresAt = make([]*time.Time, len(links))
+	)
+	for i, link := range links {
+		originals[i], shortened[i], owners[i] = link.Original, link.Shortened, link.OwnerID
+		digests[i] = originalDigest(link.OwnerID, link.Original)
+		if !link.ExpiresAt.IsZero() {
+			expiresAt[i] = &link.ExpiresAt
+		}
+
+		linkTags := link.Tags
+		if linkTags == nil {
+			linkTags = []domain.Tag{}
+		}
+		encoded, err := json.Marshal(linkTags)
+		if err != nil {
+			return failAll(len(links), fmt.Errorf("insertLinks: failed to encode tags: %w", err))
+		}
+		tags[i] = string(encoded)
+	}
+
+	// destinations are reserved as in createLink, links of destinations reserved before aren't inserted.
+	// Rows are locked in the order of unique keys, so concurrent batches wait for each other instead of deadlocking.
+	query := `
+        WITH input AS (
+            SELECT * FROM unnest($1::TEXT[], $2::TEXT[], $3::TEXT[], $4::JSONB[], $5::BYTEA[], $6::TIMESTAMPTZ[])
+                WITH ORDINALITY AS t(original_link, shortened_link, owner_id, tags, original_sha256, expires_at, n)
+        ),
+        reserved AS (
+            INSERT INTO link_originals (original_sha256, shortened_link)
+            SELECT original_sha256, shortened_link FROM input WHERE expires_at IS NULL ORDER BY original_sha256
+            ON CONFLICT (original_sha256) DO NOTHING
+            RETURNING shortened_link
+        )
+        INSERT INTO links (original_link, shortened_link, owner_id, tags, expires_at, deduplicated)
+        SELECT original_link, shortened_link, NULLIF(owner_id, ''),
+            ARRAY(SELECT jsonb_array_elements_text(tags)), expires_at, expires_at IS NULL
+        FROM input
+        WHERE expires_at IS NOT NULL OR shortened_link IN (SELECT shortened_link FROM reserved)
+        ORDER BY shortened_link
+        RETURNING ` + linkColumns
+
+	inserted, err := queryLinks(ctx, r.pool, query, len(links), originals, shortened, owners, tags, digests, expiresAt)
+	if err != nil {
+		// a shortened url taken by a single link fails the whole statement, so the links are retried
+		// one by one. Transient failures would fail every link again, so they're returned as is.
+		if errors.Is(err, domain.ErrConflict) {
+			return r.createEach(ctx, links)
+		}
+		return failAll(len(links), fmt.Errorf("CreateOrGetShortenedURL: batch insert: %w", err))
+	}
+
+	byShortened := make(map[domain.ShortURL]domain.Link, len(inserted))
+	for _, link := range inserted {
+		byShortened[link.Shortened] = link
+	}
+
+	results := make([]createResult, len(links))
+	var existing []int
+	for i, link := range links {
+		created, ok := byShortened[link.Shortened]
+		if !ok {
+			existing = append(existing, i)
+			continue
+		}
+		results[i] = createResult{link: created}
+	}
+	if len(existing) != 0 {
+		r.resolveExisting(ctx, links, existing, results)
+	}
+
+	return results
+}
+
+// resolveExisting finds links of the destinations reserved before the batch.
+func (r *URLRepository) resolveExisting(ctx context.Context, links []domain.Link, indices []int, results []createResult) {
+	digests := make([][]byte, len(indices))
+	originals := make([]string, len(indices))
+	for i, index := range indices {
+		link := links[index]
+		digests[i], originals[i] = originalDigest(link.OwnerID, link.Original), link.Original
+	}
+
+	query := `
+        SELECT ` + linkColumns + ` FROM links
+        WHERE shortened_link IN (SELECT shortened_link FROM link_originals WHERE original_sha256 = ANY($1))
+            AND original_link = ANY($2)
+    `
+	found, err := queryLinks(ctx, r.pool, query, len(indices), digests, originals)
+	if err != nil {
+		err = fmt.Errorf("CreateOrGetShortenedURL: batch lookup: %w", err)
+		for _, index := range indices {
+			results[index] = createResult{err: err}
+		}
+		return
+	}
+
+	byOriginal := make(map[string]domain.Link, len(found))
+	for _, link := range found {
+		byOriginal[domain.OriginalKey(link.OwnerID, link.Original)] = link
+	}
+	for _, index := range indices {
+		link, ok := byOriginal[domain.OriginalKey(links[index].OwnerID, links[index].Original)]
+		if !ok {
+			// the existing link is deleted concurrently, so the creation can be retried
+			results[index] = createResult{err: fmt.Errorf(
+				"CreateOrGetShortenedURL: deduplicated link is deleted: %w", domain.ErrConflict)}
+			continue
+		}
+		results[index] = createResult{link: link}
+	}
+}
+
+func (r *URLRepository) createEach(ctx context.Context, links []domain.Link) []createResult {
+	results := make([]createResult, len(links))
+	for i, link := range links {
+		results[i].link, results[i].err = r.createLink(ctx, link)
+	}
+	return results
+}
+
+func failAll(n int, err error) []createResult {
+	results := make([]createResult, n)
+	for i := range results {
+		results[i].err = err
+	}
+	return results
+}
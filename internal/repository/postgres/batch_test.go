@@ -0,0 +1,203 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"ozon_task/domain"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+)
+
+// echoFlush creates every link as is and records sizes of batches.
+type echoFlush struct {
+	mu      sync.Mutex
+	batches []int
+}
+
+func (f *echoFlush) flush(_ context.Context, links []domain.Link) []createResult {
+	f.mu.Lock()
+	f.batches = append(f.batches, len(links))
+	f.mu.Unlock()
+
+	results := make([]createResult, len(links))
+	for i, link := range links {
+		results[i] = createResult{link: link}
+	}
+	return results
+}
+
+func (f *echoFlush) sizes() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]int(nil), f.batches...)
+}
+
+func testLink(i int) domain.Link {
+	return domain.Link{Original: fmt.Sprintf("https://ozon.ru/%d", i), Shortened: fmt.Sprintf("AAAAAAA%03d", i)}
+}
+
+func TestBatcher_FlushesFullBatch(t *testing.T) {
+	t.Parallel()
+	f := &echoFlush{}
+	b := newBatcher(BatchConfig{MaxSize: 4, MaxDelay: time.Hour, QueueSize: 10, Timeout: time.Second}, f.flush)
+	t.Cleanup(b.close)
+
+	results := make([]chan createResult, 4)
+	for i := range results {
+		results[i] = make(chan createResult, 1)
+		go func() {
+			link, err := b.submit(context.Background(), testLink(i))
+			results[i] <- createResult{link: link, err: err}
+		}()
+	}
+
+	for i, result := range results {
+		res := <-result
+		require.NoError(t, res.err)
+		require.Equal(t, testLink(i), res.link)
+	}
+
+	require.Equal(t, []int{4}, f.sizes())
+}
+
+func TestBatcher_FlushesAfterDelay(t *testing.T) {
+	t.Parallel()
+	f := &echoFlush{}
+	b := newBatcher(BatchConfig{MaxSize: 100, MaxDelay: time.Millisecond, QueueSize: 10, Timeout: time.Second}, f.flush)
+	t.Cleanup(b.close)
+
+	link, err := b.submit(context.Background(), testLink(1))
+	require.NoError(t, err)
+	require.Equal(t, testLink(1), link)
+	require.Equal(t, []int{1}, f.sizes())
+}
+
+func TestBatcher_CallerCancellation(t *testing.T) {
+	t.Parallel()
+	f := &echoFlush{}
+	flushing, release := make(chan struct{}), make(chan struct{})
+	flush := func(ctx context.Context, links []domain.Link) []createResult {
+		flushing <- struct{}{}
+		<-release
+		return f.flush(ctx, links)
+	}
+	b := newBatcher(BatchConfig{MaxSize: 1, MaxDelay: time.Hour, QueueSize: 2, Timeout: time.Second}, flush)
+
+	first := make(chan error, 1)
+	go func() {
+		_, err := b.submit(context.Background(), testLink(1))
+		first <- err
+	}()
+	<-flushing
+
+	// the caller stops waiting for the blocked flush
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := b.submit(ctx, testLink(2))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// the caller doesn't get a place in the full queue
+	require.NoError(t, b.enqueue(&createRequest{ctx: context.Background(), link: testLink(3), result: make(chan createResult, 1)}))
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = b.submit(ctx, testLink(4))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	release <- struct{}{}
+	require.NoError(t, <-first)
+
+	// the canceled caller isn't flushed, the queued one is flushed on close
+	closed := make(chan struct{})
+	go func() {
+		b.close()
+		close(closed)
+	}()
+	<-flushing
+	release <- struct{}{}
+	<-closed
+	require.Equal(t, []int{1, 1}, f.sizes())
+}
+
+func TestBatcher_SkipsCanceledCallers(t *testing.T) {
+	t.Parallel()
+	f := &echoFlush{}
+	b := newBatcher(BatchConfig{MaxSize: 2, MaxDelay: time.Hour, QueueSize: 10, Timeout: time.Second}, f.flush)
+	t.Cleanup(b.close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := &createRequest{ctx: ctx, link: testLink(1), result: make(chan createResult, 1)}
+	require.NoError(t, b.enqueue(canceled))
+	cancel()
+
+	link, err := b.submit(context.Background(), testLink(2))
+	require.NoError(t, err)
+	require.Equal(t, testLink(2), link)
+
+	require.ErrorIs(t, (<-canceled.result).err, context.Canceled)
+	require.Equal(t, []int{1}, f.sizes())
+}
+
+func TestBatcher_CloseFlushesQueue(t *testing.T) {
+	t.Parallel()
+	f := &echoFlush{}
+	b := newBatcher(BatchConfig{MaxSize: 100, MaxDelay: time.Hour, QueueSize: 10, Timeout: time.Second}, f.flush)
+
+	requests := make([]*createRequest, 3)
+	for i := range requests {
+		requests[i] = &createRequest{ctx: context.Background(), link: testLink(i), result: make(chan createResult, 1)}
+		require.NoError(t, b.enqueue(requests[i]))
+	}
+	b.close()
+
+	for i, req := range requests {
+		res := <-req.result
+		require.NoError(t, res.err)
+		require.Equal(t, testLink(i), res.link)
+	}
+	require.Equal(t, []int{3}, f.sizes())
+
+	_, err := b.submit(context.Background(), testLink(4))
+	require.ErrorIs(t, err, errBatcherClosed)
+	b.close()
+}
+
+func TestBatchLeaders(t *testing.T) {
+	t.Parallel()
+	expiring := time.Now().Add(time.Hour)
+	links := []domain.Link{
+		{Original: "https://ozon.ru/a"},
+		{Original: "https://ozon.ru/b"},
+		{Original: "https://ozon.ru/a"},
+		{Original: "https://ozon.ru/a", ExpiresAt: expiring},
+		{Original: "https://ozon.ru/b"},
+	}
+
+	require.Equal(t, []int{0, 1, 0, 3, 1}, batchLeaders(links))
+}
+
+func TestInsertLinks_TransientFailure(t *testing.T) {
+	t.Parallel()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	pool, err := pgxpool.New(context.Background(),
+		fmt.Sprintf("postgres://user:password@%s/links?sslmode=disable&connect_timeout=1", addr))
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	r := &URLRepository{pool: pool}
+
+	// links aren't retried one by one against the unavailable storage
+	results := r.insertLinks(context.Background(), []domain.Link{testLink(1), testLink(2)})
+	require.Len(t, results, 2)
+	for _, res := range results {
+		require.ErrorIs(t, res.err, domain.ErrStorageUnavailable)
+		require.ErrorContains(t, res.err, "batch insert")
+	}
+}
@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"context"
+	"maps"
+	"ozon_task/domain"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	clickFlushInterval = time.Second
+	clickWriteTimeout  = 3 * time.Second
+	// maxPendingClicks bounds links with counted clicks, the counts are flushed early when it's reached
+	maxPendingClicks = 10000
+)
+
+// clickCounter counts clicks in memory and adds them to links by one statement per flush,
+// so resolving neither waits for the write nor starts a goroutine per click.
+type clickCounter struct {
+	pool *pgxpool.Pool
+
+	mu     sync.Mutex
+	clicks map[domain.ShortURL]int64
+
+	full chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newClickCounter(pool *pgxpool.Pool) *clickCounter {
+	c := &clickCounter{
+		pool:   pool,
+		clicks: make(map[domain.ShortURL]int64),
+		full:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go c.run()
+	return c
+}
+
+func (c *clickCounter) add(shortened domain.ShortURL) {
+	c.mu.Lock()
+	c.clicks[shortened]++
+	full := len(c.clicks) >= maxPendingClicks
+	c.mu.Unlock()
+
+	if full {
+		select {
+		case c.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// close stops counting and flushes the counted clicks.
+func (c *clickCounter) close() {
+	close(c.stop)
+	<-c.done
+}
+
+func (c *clickCounter) run() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(clickFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			c.flush()
+			return
+		case <-ticker.C:
+			c.flush()
+		case <-c.full:
+			c.flush()
+		}
+	}
+}
+
+// flush writes counted clicks. Clicks are dropped if the write fails, they're counted on best effort basis.
+func (c *clickCounter) flush() {
+	c.mu.Lock()
+	clicks := c.clicks
+	c.clicks = make(map[domain.ShortURL]int64)
+	c.mu.Unlock()
+	if len(clicks) == 0 {
+		return
+	}
+
+	// rows are updated in the same order by all instances, so their flushes don't deadlock
+	shortened := slices.Sorted(maps.Keys(clicks))
+	counts := make([]int64, 0, len(clicks))
+	for _, link := range shortened {
+		counts = append(counts, clicks[link])
+	}
+
+	query := `
+        UPDATE links SET clicks = links.clicks + c.count
+        FROM unnest($1::TEXT[], $2::BIGINT[]) AS c(shortened_link, count)
+        WHERE links.shortened_link = c.shortened_link
+    `
+
+	ctx, cancel := context.WithTimeout(context.Background(), clickWriteTimeout)
+	defer cancel()
+	_, _ = c.pool.Exec(ctx, query, shortened, counts)
+}
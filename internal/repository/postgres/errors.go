@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"ozon_task/domain"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// SQLSTATE codes of conflicts and transient failures, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	codeUniqueViolation      = "23505"
+	codeSerializationFailure = "40001"
+	codeDeadlockDetected     = "40P01"
+	codeLockNotAvailable     = "55P03"
+	codeQueryCanceled        = "57014"
+	codeAdminShutdown        = "57P01"
+	codeCrashShutdown        = "57P02"
+	codeCannotConnectNow     = "57P03"
+
+	classConnectionException  = "08"
+	classInsufficientResource = "53"
+)
+
+// classify wraps errors of pgx into infrastructure errors of the domain, so they aren't
+// reported as internal ones. Errors of the context of the request and unknown errors are kept.
+func classify(err error) error {
+	if err == nil ||
+		errors.Is(err, pgx.ErrNoRows) ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	if kind := kindOf(err); kind != nil {
+		return fmt.Errorf("%w: %w", kind, err)
+	}
+
+	return err
+}
+
+func kindOf(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case pgErr.Code == codeUniqueViolation:
+			return domain.ErrConflict
+		case pgErr.Code == codeSerializationFailure,
+			pgErr.Code == codeDeadlockDetected:
+			return domain.ErrConcurrentChange
+		case pgErr.Code == codeQueryCanceled,
+			pgErr.Code == codeLockNotAvailable:
+			return domain.ErrTimeout
+		case pgErr.Code == codeAdminShutdown,
+			pgErr.Code == codeCrashShutdown,
+			pgErr.Code == codeCannotConnectNow,
+			strings.HasPrefix(pgErr.Code, classConnectionException),
+			strings.HasPrefix(pgErr.Code, classInsufficientResource):
+			return domain.ErrStorageUnavailable
+		default:
+			return nil
+		}
+	}
+
+	if pgconn.Timeout(err) {
+		return domain.ErrTimeout
+	}
+
+	var (
+		connectErr *pgconn.ConnectError
+		netErr     net.Error
+	)
+	if errors.As(err, &connectErr) ||
+		errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		pgconn.SafeToRetry(err) {
+		return domain.ErrStorageUnavailable
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+
+	"ozon_task/domain"
+)
+
+func TestClassify(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		kind error
+	}{
+		{name: "serialization failure", err: &pgconn.PgError{Code: codeSerializationFailure}, kind: domain.ErrConcurrentChange},
+		{name: "unique violation", err: &pgconn.PgError{Code: codeUniqueViolation}, kind: domain.ErrConflict},
+		{name: "statement timeout", err: &pgconn.PgError{Code: codeQueryCanceled}, kind: domain.ErrTimeout},
+		{name: "admin shutdown", err: &pgconn.PgError{Code: codeAdminShutdown}, kind: domain.ErrStorageUnavailable},
+		{name: "too many connections", err: &pgconn.PgError{Code: "53300"}, kind: domain.ErrStorageUnavailable},
+		{name: "connection failure", err: &pgconn.PgError{Code: "08006"}, kind: domain.ErrStorageUnavailable},
+		{name: "broken connection", err: fmt.Errorf("read: %w", io.ErrUnexpectedEOF), kind: domain.ErrStorageUnavailable},
+	}
+
+	for _, tt := range tests {
+		err := classify(tt.err)
+
+		require.ErrorIs(t, err, tt.kind, tt.name)
+		require.ErrorIs(t, err, tt.err, tt.name)
+	}
+}
+
+func TestClassify_KeepsOtherErrors(t *testing.T) {
+	t.Parallel()
+
+	syntaxErr := &pgconn.PgError{Code: "42601"}
+	for _, err := range []error{nil, pgx.ErrNoRows, context.Canceled, context.DeadlineExceeded, syntaxErr} {
+		require.Equal(t, err, classify(err))
+	}
+
+	require.Equal(t, domain.CodeInternal, domain.ErrorCodeOf(classify(errors.New("unknown"))))
+}
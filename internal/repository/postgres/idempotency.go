@@ -0,0 +1,151 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"ozon_task/domain"
+	"ozon_task/internal/repository"
+	pkglog "ozon_task/pkg/log"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// purgeEvery is number of reservations between removals of expired records.
+	purgeEvery = 1000
+	// purgeBatchSize bounds records removed by one statement, so a purge doesn't hold many row locks at once.
+	purgeBatchSize = 1000
+	// purgeTimeout limits the whole purge, records left after it are removed by the next one.
+	purgeTimeout = 10 * time.Second
+)
+
+type IdempotencyRepository struct {
+	pool         *pgxpool.Pool
+	log          *slog.Logger
+	reservations atomic.Int64
+	// purging is set while expired records are removed in background
+	purging atomic.Bool
+}
+
+func NewIdempotencyRepository(pool *pgxpool.Pool, log *slog.Logger) repository.Idempotency {
+	return &IdempotencyRepository{
+		pool: pool,
+		log:  log,
+	}
+}
+
+func (r *IdempotencyRepository) ReserveIdempotencyKey(
+	ctx context.Context,
+	record domain.IdempotencyRecord,
+	ttl time.Duration,
+) (domain.IdempotencyRecord, bool, error) {
+	// expired records are removed out of the request, so the purge neither slows nor fails it
+	if r.reservations.Add(1)%purgeEvery == 0 && r.purging.CompareAndSwap(false, true) {
+		go r.purgeExpired()
+	}
+
+	// expired record is replaced as if the key was free
+	query := `
+        INSERT INTO idempotency_keys (key, fingerprint, created_at, expires_at)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (key) DO UPDATE
+        SET fingerprint = EXCLUDED.fingerprint,
+            status_code = NULL,
+            content_type = NULL,
+            body = NULL,
+            created_at = EXCLUDED.created_at,
+            expires_at = EXCLUDED.expires_at
+        WHERE idempotency_keys.expires_at <= now()
+        RETURNING key
+    `
+
+	var key string
+	err := r.pool.QueryRow(ctx, query, record.Key, record.Fingerprint, record.CreatedAt, record.CreatedAt.Add(ttl)).
+		Scan(&key)
+	if err == nil {
+		return record, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return domain.IdempotencyRecord{}, false, fmt.Errorf("ReserveIdempotencyKey: insert failed: %w", classify(err))
+	}
+
+	query = `
+        SELECT key, fingerprint, COALESCE(status_code, 0), COALESCE(content_type, ''), body, created_at
+        FROM idempotency_keys
+        WHERE key = $1
+    `
+
+	var existing domain.IdempotencyRecord
+	err = r.pool.QueryRow(ctx, query, record.Key).Scan(
+		&existing.Key, &existing.Fingerprint, &existing.StatusCode,
+		&existing.ContentType, &existing.Body, &existing.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// record was released between queries, caller may retry
+		return domain.IdempotencyRecord{}, false,
+			fmt.Errorf("ReserveIdempotencyKey: %w", domain.ErrIdempotencyKeyInProgress)
+	} else if err != nil {
+		return domain.IdempotencyRecord{}, false, fmt.Errorf("ReserveIdempotencyKey: select failed: %w", classify(err))
+	}
+
+	return existing, false, nil
+}
+
+func (r *IdempotencyRepository) CompleteIdempotencyKey(
+	ctx context.Context,
+	record domain.IdempotencyRecord,
+	ttl time.Duration,
+) error {
+	query := `
+        UPDATE idempotency_keys
+        SET status_code = $2, content_type = $3, body = $4, expires_at = $5
+        WHERE key = $1
+    `
+
+	_, err := r.pool.Exec(ctx, query,
+		record.Key, record.StatusCode, record.ContentType, record.Body, time.Now().Add(ttl))
+	if err != nil {
+		return fmt.Errorf("CompleteIdempotencyKey: query failed: %w", classify(err))
+	}
+
+	return nil
+}
+
+func (r *IdempotencyRepository) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	_, err := r.pool.Exec(ctx, `DELETE FROM idempotency_keys WHERE key = $1`, key)
+	if err != nil {
+		return fmt.Errorf("ReleaseIdempotencyKey: query failed: %w", classify(err))
+	}
+
+	return nil
+}
+
+// purgeExpired removes expired records in batches. It's best effort: failures are logged and the records
+// are left for the next purge, reservations replace expired records anyway.
+func (r *IdempotencyRepository) purgeExpired() {
+	const op = "IdempotencyRepository.purgeExpired"
+	defer r.purging.Store(false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), purgeTimeout)
+	defer cancel()
+
+	query := `
+        DELETE FROM idempotency_keys
+        WHERE ctid IN (SELECT ctid FROM idempotency_keys WHERE expires_at <= now() LIMIT $1)
+    `
+	for {
+		tag, err := r.pool.Exec(ctx, query, purgeBatchSize)
+		if err != nil {
+			r.log.Warn("failed to purge expired idempotency keys", slog.String("op", op), pkglog.Err(classify(err)))
+			return
+		}
+		if tag.RowsAffected() < purgeBatchSize {
+			return
+		}
+	}
+}
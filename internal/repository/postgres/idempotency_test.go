@@ -0,0 +1,47 @@
+package postgres_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"ozon_task/domain"
+	"ozon_task/internal/repository/postgres"
+	"ozon_task/internal/repository/postgres/pgtest"
+)
+
+func TestIdempotencyRepository_PurgesExpiredKeys(t *testing.T) {
+	t.Parallel()
+	pool, _ := pgtest.NewMigratedDatabase(t)
+	repo := postgres.NewIdempotencyRepository(pool, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ctx := context.Background()
+
+	// more expired records than one batch of the purge removes
+	_, err := pool.Exec(ctx, `
+        INSERT INTO idempotency_keys (key, fingerprint, expires_at)
+        SELECT 'expired-' || i, '\x00'::BYTEA, now() - INTERVAL '1 hour' FROM generate_series(1, 2500) AS i`)
+	require.NoError(t, err)
+
+	now := time.Now().UTC()
+	for i := range 1000 {
+		record := domain.IdempotencyRecord{Key: fmt.Sprintf("key-%d", i), Fingerprint: []byte{1}, CreatedAt: now}
+		_, reserved, err := repo.ReserveIdempotencyKey(ctx, record, time.Hour)
+		require.NoError(t, err)
+		require.True(t, reserved)
+	}
+
+	require.Eventually(t, func() bool {
+		var expired int
+		err := pool.QueryRow(ctx, `SELECT count(*) FROM idempotency_keys WHERE expires_at <= now()`).Scan(&expired)
+		return err == nil && expired == 0
+	}, 10*time.Second, 50*time.Millisecond)
+
+	var live int
+	require.NoError(t, pool.QueryRow(ctx, `SELECT count(*) FROM idempotency_keys`).Scan(&live))
+	require.Equal(t, 1000, live)
+}
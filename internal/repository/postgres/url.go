@@ -2,9 +2,12 @@ package postgres
 
 import (
 	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"ozon_task/pkg/infra"
 	"ozon_task/pkg/infra/cache"
+	"strings"
 	"time"
 
 	"ozon_task/domain"
@@ -15,49 +18,122 @@ import (
 )
 
 type URLRepository struct {
-	pool              *pgxpool.Pool
+	pool *pgxpool.Pool
+	// replicas serve resolving and listing of links, nil if there are none
+	replicas          *infra.PostgresReplicas
 	cache             cache.Cache
 	cacheTTL          time.Duration
 	cacheWriteTimeout time.Duration
+	// batcher combines concurrent creations of links into multi-row inserts, nil if batching is disabled
+	batcher *batcher
+	clicks  *clickCounter
 }
 
+// NewURLRepository returns the repository of links, it must be closed to flush counted clicks.
 func NewURLRepository(
 	pool *pgxpool.Pool,
+	replicas *infra.PostgresReplicas,
 	cache cache.Cache,
 	cacheTTL,
 	cacheWriteTimeout time.Duration,
-) repository.URL {
-	return &URLRepository{
+	batch BatchConfig,
+) *URLRepository {
+	r := &URLRepository{
 		pool:              pool,
+		replicas:          replicas,
 		cache:             cache,
 		cacheTTL:          cacheTTL,
 		cacheWriteTimeout: cacheWriteTimeout,
+		clicks:            newClickCounter(pool),
 	}
+	if batch.Enabled {
+		r.batcher = newBatcher(batch, r.createLinks)
+	}
+	return r
 }
 
-func (r *URLRepository) CreateOrGetShortenedURL(
-	ctx context.Context,
-	original domain.URL,
-	shortened domain.ShortURL,
-) (domain.ShortURL, error) {
-	var result domain.URL
+// Close flushes creations of links waiting for a batch and counted clicks. The repository creates links
+// one by one after it and doesn't count clicks.
+func (r *URLRepository) Close() {
+	if r.batcher != nil {
+		r.batcher.close()
+	}
+	r.clicks.close()
+}
+
+func (r *URLRepository) CreateOrGetShortenedURL(ctx context.Context, link domain.Link) (domain.Link, error) {
+	result, err := r.createOrGet(ctx, link)
+	if err != nil {
+		return domain.Link{}, err
+	}
+
+	r.cacheURLs(result)
+	return result, nil
+}
+
+func (r *URLRepository) createOrGet(ctx context.Context, link domain.Link) (domain.Link, error) {
+	if r.batcher != nil {
+		result, err := r.batcher.submit(ctx, link)
+		if !errors.Is(err, errBatcherClosed) {
+			return result, err
+		}
+	}
+	return r.createLink(ctx, link)
+}
+
+func (r *URLRepository) createLink(ctx context.Context, link domain.Link) (domain.Link, error) {
+	tags := link.Tags
+	if tags == nil {
+		tags = []domain.Tag{}
+	}
+
+	// links with expiration aren't deduplicated
+	if !link.ExpiresAt.IsZero() {
+		query := `
+            INSERT INTO links (original_link, shortened_link, owner_id, tags, expires_at, deduplicated)
+            VALUES ($1, $2, NULLIF($3, ''), $4, $5, FALSE)
+            RETURNING ` + linkColumns
+
+		result, err := scanLink(r.pool.QueryRow(ctx, query,
+			link.Original, link.Shortened, link.OwnerID, tags, link.ExpiresAt))
+		if err != nil {
+			return domain.Link{}, fmt.Errorf("CreateOrGetShortenedURL: query failed: %w", classify(err))
+		}
+
+		return result, nil
+	}
 
-	// used update on in case of concurrent inserting
+	// the destination is reserved by its digest first, concurrent inserts of it wait for the reserving
+	// transaction and get the link it created
+	digest := originalDigest(link.OwnerID, link.Original)
 	query := `
-        INSERT INTO links (original_link, shortened_link)
-		VALUES ($1, $2)
-		ON CONFLICT (original_link)
-		DO UPDATE SET shortened_link = links.shortened_link
-		RETURNING shortened_link;
-    `
+        WITH reserved AS (
+            INSERT INTO link_originals (original_sha256, shortened_link) VALUES ($5, $2)
+            ON CONFLICT (original_sha256) DO NOTHING
+            RETURNING shortened_link
+        )
+        INSERT INTO links (original_link, shortened_link, owner_id, tags, deduplicated)
+        SELECT $1, shortened_link, NULLIF($3, ''), $4, TRUE FROM reserved
+        RETURNING ` + linkColumns
 
-	err := r.pool.QueryRow(ctx, query, original, shortened).Scan(&result)
+	result, err := scanLink(r.pool.QueryRow(ctx, query, link.Original, link.Shortened, link.OwnerID, tags, digest))
+	if errors.Is(err, pgx.ErrNoRows) {
+		existingQuery := `
+            SELECT ` + linkColumns + ` FROM links
+            WHERE shortened_link = (SELECT shortened_link FROM link_originals WHERE original_sha256 = $1)
+                AND original_link = $2
+        `
+		result, err = scanLink(r.pool.QueryRow(ctx, existingQuery, digest, link.Original))
+		if errors.Is(err, pgx.ErrNoRows) {
+			// the existing link is deleted concurrently, so the creation can be retried
+			return domain.Link{}, fmt.Errorf("CreateOrGetShortenedURL: deduplicated link is deleted: %w",
+				domain.ErrConflict)
+		}
+	}
 	if err != nil {
-		return "", nil
+		return domain.Link{}, fmt.Errorf("CreateOrGetShortenedURL: query failed: %w", classify(err))
 	}
 
-	go r.cacheURLs(original, shortened)
-
 	return result, nil
 }
 
@@ -65,22 +141,30 @@ func (r *URLRepository) GetOriginalURLByShortened(
 	ctx context.Context,
 	shortened domain.ShortURL,
 ) (domain.URL, error) {
-	var original domain.URL
-	if err := r.cache.Get(ctx, shortened, &original); err == nil {
-		return original, nil
+	var cached repository.CachedOriginal
+	if err := r.cache.Get(ctx, shortened, &cached); err == nil && len(cached.Original) != 0 {
+		return cached.Original, nil
 	}
 
+	var original domain.URL
 	query := `
-        SELECT original_link FROM links
+        SELECT original_link, expires_at FROM links
         WHERE shortened_link = $1
     `
 
-	err := r.pool.QueryRow(ctx, query, shortened).Scan(&original)
+	var expiresAt *time.Time
+	err := r.read(ctx, func(pool *pgxpool.Pool) error {
+		return pool.QueryRow(ctx, query, shortened).Scan(&original, &expiresAt)
+	})
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return "", domain.ErrOriginalNotFound
 		}
-		return "", fmt.Errorf("GetOriginalURLByShortened: query failed: %w", err)
+		return "", fmt.Errorf("GetOriginalURLByShortened: query failed: %w", classify(err))
+	}
+
+	if expiresAt != nil && !time.Now().Before(*expiresAt) {
+		return "", domain.ErrLinkExpired
 	}
 
 	return original, nil
@@ -88,34 +172,382 @@ func (r *URLRepository) GetOriginalURLByShortened(
 
 func (r *URLRepository) GetShortenedURLByOriginal(
 	ctx context.Context,
+	owner domain.OwnerID,
 	original domain.URL,
 ) (domain.ShortURL, error) {
 	var shortened domain.ShortURL
-	if err := r.cache.Get(ctx, original, &shortened); err == nil {
+	if err := r.cache.Get(ctx, domain.OriginalKey(owner, original), &shortened); err == nil {
 		return shortened, nil
 	}
 
+	// the digest finds the partition and the row, the text of the link guards against collisions
 	query := `
         SELECT shortened_link FROM links
-        WHERE original_link = $1
+        WHERE shortened_link = (SELECT shortened_link FROM link_originals WHERE original_sha256 = $1)
+            AND original_link = $2
     `
 
-	err := r.pool.QueryRow(ctx, query, original).Scan(&shortened)
+	err := r.pool.QueryRow(ctx, query, originalDigest(owner, original), original).Scan(&shortened)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return "", domain.ErrShortenedNotFound
 		}
-		return "", fmt.Errorf("GetShortenedURLByOriginal: query failed: %w", err)
+		return "", fmt.Errorf("GetShortenedURLByOriginal: query failed: %w", classify(err))
 	}
 
 	return shortened, nil
 }
 
-func (r *URLRepository) cacheURLs(original domain.URL, shortened domain.ShortURL) {
+func (r *URLRepository) ListLinks(
+	ctx context.Context,
+	filter domain.LinkFilter,
+	page domain.Page,
+) ([]domain.Link, error) {
+	var (
+		conditions []string
+		args       []any
+	)
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if page.AfterID > 0 {
+		where("id < $%d", page.AfterID)
+	}
+	if len(filter.OwnerID) != 0 {
+		where("owner_id = $%d", filter.OwnerID)
+	}
+	if len(filter.Host) != 0 {
+		where("original_host = lower($%d)", filter.Host)
+	}
+	if len(filter.Tag) != 0 {
+		where("tags @> ARRAY[$%d::TEXT]", filter.Tag)
+	}
+	if !filter.CreatedAfter.IsZero() {
+		where("created_at >= $%d", filter.CreatedAfter)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		where("created_at < $%d", filter.CreatedBefore)
+	}
+	if len(filter.Search) != 0 {
+		where(`original_link ILIKE '%%' || $%d || '%%'`, escapeLike(filter.Search))
+	}
+
+	query := `SELECT ` + linkColumns + ` FROM links`
+	if len(conditions) != 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, page.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	var links []domain.Link
+	err := r.read(ctx, func(pool *pgxpool.Pool) error {
+		var err error
+		links, err = queryLinks(ctx, pool, query, page.Limit, args...)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ListLinks: %w", err)
+	}
+
+	return links, nil
+}
+
+func queryLinks(ctx context.Context, pool *pgxpool.Pool, query string, limit int, args ...any) ([]domain.Link, error) {
+	rows, err := pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", classify(err))
+	}
+	defer rows.Close()
+
+	links := make([]domain.Link, 0, limit)
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan failed: %w", classify(err))
+		}
+		links = append(links, link)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows failed: %w", classify(err))
+	}
+
+	return links, nil
+}
+
+func (r *URLRepository) GetLink(ctx context.Context, shortened domain.ShortURL) (domain.Link, error) {
+	query := `SELECT ` + linkColumns + ` FROM links WHERE shortened_link = $1`
+
+	link, err := scanLink(r.pool.QueryRow(ctx, query, shortened))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.Link{}, domain.ErrOriginalNotFound
+		}
+		return domain.Link{}, fmt.Errorf("GetLink: query failed: %w", classify(err))
+	}
+
+	return link, nil
+}
+
+func (r *URLRepository) UpdateLink(
+	ctx context.Context,
+	shortened domain.ShortURL,
+	update domain.LinkUpdate,
+	author string,
+) (domain.Link, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return domain.Link{}, fmt.Errorf("UpdateLink: failed to begin transaction: %w", classify(err))
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// row lock keeps versions of concurrent updates sequential
+	selectQuery := `SELECT ` + linkColumns + ` FROM links WHERE shortened_link = $1 FOR UPDATE`
+
+	previous, err := scanLink(tx.QueryRow(ctx, selectQuery, shortened))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.Link{}, domain.ErrOriginalNotFound
+		}
+		return domain.Link{}, fmt.Errorf("UpdateLink: failed to lock link: %w", classify(err))
+	}
+
+	var (
+		assignments []string
+		args        = []any{shortened}
+	)
+	set := func(assignment string, arg any) {
+		args = append(args, arg)
+		assignments = append(assignments, fmt.Sprintf(assignment, len(args)))
+	}
+
+	if update.Original != nil {
+		archiveQuery := `
+            INSERT INTO link_versions (shortened_link, version, original_link, author, created_at)
+            SELECT shortened_link, version, original_link, COALESCE(updated_by, owner_id, ''),
+                COALESCE(updated_at, created_at)
+            FROM links
+            WHERE shortened_link = $1
+        `
+		if _, err = tx.Exec(ctx, archiveQuery, shortened); err != nil {
+			return domain.Link{}, fmt.Errorf("UpdateLink: failed to archive version: %w", classify(err))
+		}
+
+		set("original_link = $%d", *update.Original)
+		set("updated_by = $%d", author)
+		assignments = append(assignments, "version = version + 1", "updated_at = now()")
+	}
+	if update.Tags != nil {
+		tags := *update.Tags
+		if tags == nil {
+			tags = []domain.Tag{}
+		}
+		set("tags = $%d", tags)
+	}
+	if update.ExpiresAt != nil {
+		set("expires_at = $%d", nullTime(*update.ExpiresAt))
+	}
+	if update.Original != nil || update.ExpiresAt != nil {
+		assignments = append(assignments, "deduplicated = FALSE")
+
+		// the previous destination may be shortened again
+		unreserveQuery := `DELETE FROM link_originals WHERE original_sha256 = $1 AND shortened_link = $2`
+		digest := originalDigest(previous.OwnerID, previous.Original)
+		if _, err = tx.Exec(ctx, unreserveQuery, digest, shortened); err != nil {
+			return domain.Link{}, fmt.Errorf("UpdateLink: failed to release destination: %w", classify(err))
+		}
+	}
+
+	if len(assignments) == 0 {
+		return previous, nil
+	}
+
+	updateQuery := `UPDATE links SET ` + strings.Join(assignments, ", ") +
+		` WHERE shortened_link = $1 RETURNING ` + linkColumns
+
+	link, err := scanLink(tx.QueryRow(ctx, updateQuery, args...))
+	if err != nil {
+		return domain.Link{}, fmt.Errorf("UpdateLink: failed to update link: %w", classify(err))
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return domain.Link{}, fmt.Errorf("UpdateLink: failed to commit: %w", classify(err))
+	}
+
+	r.invalidateURLs(previous.OwnerID, previous.Original, shortened)
+
+	return link, nil
+}
+
+func (r *URLRepository) ListLinkVersions(
+	ctx context.Context,
+	shortened domain.ShortURL,
+) ([]domain.LinkVersion, error) {
+	query := `
+        SELECT version, original_link, COALESCE(updated_by, owner_id, ''), COALESCE(updated_at, created_at)
+        FROM links
+        WHERE shortened_link = $1
+        UNION ALL
+        SELECT version, original_link, author, created_at
+        FROM link_versions
+        WHERE shortened_link = $1
+        ORDER BY version DESC
+    `
+
+	rows, err := r.pool.Query(ctx, query, shortened)
+	if err != nil {
+		return nil, fmt.Errorf("ListLinkVersions: query failed: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var versions []domain.LinkVersion
+	for rows.Next() {
+		var version domain.LinkVersion
+		err = rows.Scan(&version.Version, &version.Original, &version.Author, &version.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("ListLinkVersions: scan failed: %w", classify(err))
+		}
+		versions = append(versions, version)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ListLinkVersions: rows failed: %w", classify(err))
+	}
+
+	if len(versions) == 0 {
+		return nil, domain.ErrOriginalNotFound
+	}
+
+	return versions, nil
+}
+
+func (r *URLRepository) DeleteLink(ctx context.Context, shortened domain.ShortURL) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("DeleteLink: failed to begin transaction: %w", classify(err))
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// versions are deleted by the cascade
+	query := `DELETE FROM links WHERE shortened_link = $1 RETURNING original_link, COALESCE(owner_id, '')`
+
+	var (
+		original domain.URL
+		owner    domain.OwnerID
+	)
+	if err = tx.QueryRow(ctx, query, shortened).Scan(&original, &owner); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.ErrOriginalNotFound
+		}
+		return fmt.Errorf("DeleteLink: query failed: %w", classify(err))
+	}
+
+	unreserveQuery := `DELETE FROM link_originals WHERE original_sha256 = $1 AND shortened_link = $2`
+	if _, err = tx.Exec(ctx, unreserveQuery, originalDigest(owner, original), shortened); err != nil {
+		return fmt.Errorf("DeleteLink: failed to release destination: %w", classify(err))
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("DeleteLink: failed to commit: %w", classify(err))
+	}
+
+	r.invalidateURLs(owner, original, shortened)
+
+	return nil
+}
+
+// RecordClick counts the click in memory, counts are written in background by clickCounter.
+func (r *URLRepository) RecordClick(_ context.Context, shortened domain.ShortURL) error {
+	r.clicks.add(shortened)
+	return nil
+}
+
+// read runs the query on a replica if there is an available one. Failed queries and missing rows are
+// repeated on the primary, as the row could be written after the last replicated transaction.
+// Lookups before writes, e.g. GetLink, aren't routed to replicas to see the latest state.
+func (r *URLRepository) read(ctx context.Context, query func(pool *pgxpool.Pool) error) error {
+	if r.replicas != nil {
+		if replica := r.replicas.Reader(); replica != nil {
+			if err := query(replica); err == nil || ctx.Err() != nil {
+				return err
+			}
+		}
+	}
+	return query(r.pool)
+}
+
+const linkColumns = `id, original_link, shortened_link, COALESCE(owner_id, ''), tags, version, clicks, created_at, expires_at`
+
+func scanLink(row pgx.Row) (domain.Link, error) {
+	var (
+		link      domain.Link
+		expiresAt *time.Time
+	)
+
+	err := row.Scan(&link.ID, &link.Original, &link.Shortened, &link.OwnerID, &link.Tags,
+		&link.Version, &link.Clicks, &link.CreatedAt, &expiresAt)
+	if expiresAt != nil {
+		link.ExpiresAt = *expiresAt
+	}
+
+	return link, err
+}
+
+// originalDigest is the key of deduplicated destinations of the owner in link_originals, it's equal to
+// the digest computed by the database:
+//
+//	sha256(convert_to(COALESCE(owner_id, ''), 'UTF8') || '\x00'::BYTEA || convert_to(original_link, 'UTF8'))
+func originalDigest(owner domain.OwnerID, original domain.URL) []byte {
+	sum := sha256.Sum256([]byte(domain.OriginalKey(owner, original)))
+	return sum[:]
+}
+
+// nullTime maps zero time to NULL.
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// escapeLike escapes wildcards of LIKE patterns, so search is a plain substring match.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// cacheURLs caches lookups of the created link. It runs before the creation returns rather than in background,
+// so it can't overwrite invalidation by a change of the link made after the creation.
+func (r *URLRepository) cacheURLs(link domain.Link) {
 	// r.cacheWriteTimeout*2 because we have two write operations
 	const operationsCount = 2
 	ctx, cancel := context.WithTimeout(context.Background(), r.cacheWriteTimeout*operationsCount)
 	defer cancel()
-	_ = r.cache.Set(ctx, original, shortened, r.cacheTTL)
-	_ = r.cache.Set(ctx, shortened, original, r.cacheTTL)
+
+	cached := repository.CachedOriginal{Original: link.Original, CachedAt: time.Now().UTC()}
+
+	// links with expiration aren't deduplicated, and can't be resolved from cache after expiration
+	if link.ExpiresAt.IsZero() {
+		_ = r.cache.Set(ctx, domain.OriginalKey(link.OwnerID, link.Original), link.Shortened, r.cacheTTL)
+		_ = r.cache.Set(ctx, link.Shortened, cached, r.cacheTTL)
+		return
+	}
+	cached.ExpiresAt = &link.ExpiresAt
+
+	ttl := time.Until(link.ExpiresAt)
+	if r.cacheTTL > 0 && r.cacheTTL < ttl {
+		ttl = r.cacheTTL
+	}
+	if ttl > 0 {
+		_ = r.cache.Set(ctx, link.Shortened, cached, ttl)
+	}
+}
+
+// invalidateURLs drops cached lookups of the edited or deleted link, so the next resolve after the update
+// can't return the previous destination.
+func (r *URLRepository) invalidateURLs(owner domain.OwnerID, original domain.URL, shortened domain.ShortURL) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.cacheWriteTimeout)
+	defer cancel()
+	_ = r.cache.Delete(ctx, domain.OriginalKey(owner, original), shortened)
 }
@@ -0,0 +1,251 @@
+package postgres_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	"ozon_task/domain"
+	"ozon_task/internal/repository/postgres"
+	"ozon_task/internal/repository/postgres/pgtest"
+	pkgconfig "ozon_task/pkg/config"
+	"ozon_task/pkg/infra"
+	"ozon_task/pkg/infra/cache/stub"
+)
+
+// digestQuery computes the digest of deduplicated destinations as the migrations do.
+const digestQuery = `sha256(convert_to(COALESCE($1::TEXT, ''), 'UTF8') || '\x00'::BYTEA || convert_to($2::TEXT, 'UTF8'))`
+
+func newRepository(t *testing.T) (*postgres.URLRepository, *pgxpool.Pool) {
+	t.Helper()
+
+	pool, _ := pgtest.NewMigratedDatabase(t)
+	repo := postgres.NewURLRepository(pool, nil, stub.NewStub(), time.Minute, time.Second, postgres.BatchConfig{})
+	t.Cleanup(repo.Close)
+
+	return repo, pool
+}
+
+func TestURLRepository_GetShortenedURLByOriginal_Digest(t *testing.T) {
+	t.Parallel()
+	repo, pool := newRepository(t)
+	ctx := context.Background()
+
+	// the link is stored with the digest computed by the database
+	_, err := pool.Exec(ctx, `
+        WITH reserved AS (
+            INSERT INTO link_originals (original_sha256, shortened_link) VALUES (`+digestQuery+`, 'AAAAAAA001')
+            RETURNING shortened_link
+        )
+        INSERT INTO links (original_link, shortened_link, owner_id) SELECT $2, shortened_link, $1 FROM reserved`,
+		"alice", "https://ozon.ru/digest")
+	require.NoError(t, err)
+
+	shortened, err := repo.GetShortenedURLByOriginal(ctx, "alice", "https://ozon.ru/digest")
+	require.NoError(t, err)
+	require.Equal(t, "AAAAAAA001", shortened)
+
+	_, err = repo.GetShortenedURLByOriginal(ctx, "bob", "https://ozon.ru/digest")
+	require.ErrorIs(t, err, domain.ErrShortenedNotFound)
+	_, err = repo.GetShortenedURLByOriginal(ctx, "", "https://ozon.ru/digest")
+	require.ErrorIs(t, err, domain.ErrShortenedNotFound)
+
+	link, err := repo.CreateOrGetShortenedURL(ctx,
+		domain.Link{Original: "https://ozon.ru/digest", Shortened: "AAAAAAA002", OwnerID: "alice"})
+	require.NoError(t, err)
+	require.Equal(t, "AAAAAAA001", link.Shortened)
+}
+
+func TestURLRepository_DigestCollision(t *testing.T) {
+	t.Parallel()
+	repo, pool := newRepository(t)
+	ctx := context.Background()
+
+	_, err := repo.CreateOrGetShortenedURL(ctx, domain.Link{Original: "https://ozon.ru/stored", Shortened: "AAAAAAA001"})
+	require.NoError(t, err)
+
+	// the digest of another destination leads to the stored link, as if their digests collided
+	tag, err := pool.Exec(ctx,
+		`UPDATE link_originals SET original_sha256 = `+digestQuery+` WHERE shortened_link = $3`,
+		"", "https://ozon.ru/colliding", "AAAAAAA001")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), tag.RowsAffected())
+
+	_, err = repo.GetShortenedURLByOriginal(ctx, "", "https://ozon.ru/colliding")
+	require.ErrorIs(t, err, domain.ErrShortenedNotFound)
+
+	_, err = repo.CreateOrGetShortenedURL(ctx, domain.Link{Original: "https://ozon.ru/colliding", Shortened: "AAAAAAA002"})
+	require.ErrorIs(t, err, domain.ErrConflict)
+
+	original, err := repo.GetOriginalURLByShortened(ctx, "AAAAAAA001")
+	require.NoError(t, err)
+	require.Equal(t, "https://ozon.ru/stored", original)
+	_, err = repo.GetOriginalURLByShortened(ctx, "AAAAAAA002")
+	require.ErrorIs(t, err, domain.ErrOriginalNotFound)
+}
+
+func TestURLRepository_BatchConflict(t *testing.T) {
+	t.Parallel()
+	pool, _ := pgtest.NewMigratedDatabase(t)
+	repo := postgres.NewURLRepository(pool, nil, stub.NewStub(), time.Minute, time.Second,
+		postgres.BatchConfig{Enabled: true, MaxSize: 2, MaxDelay: time.Second, QueueSize: 10, Timeout: time.Second})
+	t.Cleanup(repo.Close)
+	ctx := context.Background()
+
+	taken := domain.Link{Original: "https://ozon.ru/taken", Shortened: "AAAAAAA001"}
+	_, err := repo.CreateOrGetShortenedURL(ctx, taken)
+	require.NoError(t, err)
+
+	// the taken shortened url fails the batch, its links are created one by one
+	results := make(chan error, 2)
+	for _, link := range []domain.Link{
+		{Original: "https://ozon.ru/other", Shortened: "AAAAAAA001"},
+		{Original: "https://ozon.ru/new", Shortened: "AAAAAAA002"},
+	} {
+		go func() {
+			_, err := repo.CreateOrGetShortenedURL(ctx, link)
+			results <- err
+		}()
+	}
+
+	var conflicts int
+	for range 2 {
+		if err := <-results; err != nil {
+			require.ErrorIs(t, err, domain.ErrConflict)
+			conflicts++
+		}
+	}
+	require.Equal(t, 1, conflicts)
+
+	original, err := repo.GetOriginalURLByShortened(ctx, "AAAAAAA002")
+	require.NoError(t, err)
+	require.Equal(t, "https://ozon.ru/new", original)
+}
+
+func TestURLRepository_Partitions(t *testing.T) {
+	t.Parallel()
+	repo, pool := newRepository(t)
+	ctx := context.Background()
+
+	// enough links to fill every partition of links and link_originals
+	const count = 200
+	links := make([]domain.Link, count)
+	for i := range links {
+		link, err := repo.CreateOrGetShortenedURL(ctx, domain.Link{
+			Original:  fmt.Sprintf("https://ozon.ru/%d", i),
+			Shortened: fmt.Sprintf("AAAAAA%04d", i),
+			OwnerID:   []domain.OwnerID{"", "alice", "bob"}[i%3],
+		})
+		require.NoError(t, err)
+		links[i] = link
+	}
+
+	for _, table := range []string{"links", "link_originals"} {
+		var partitions int
+		require.NoError(t, pool.QueryRow(ctx, `SELECT count(DISTINCT tableoid) FROM `+table).Scan(&partitions))
+		require.Equal(t, 16, partitions, table)
+	}
+
+	for _, link := range links {
+		original, err := repo.GetOriginalURLByShortened(ctx, link.Shortened)
+		require.NoError(t, err)
+		require.Equal(t, link.Original, original)
+
+		shortened, err := repo.GetShortenedURLByOriginal(ctx, link.OwnerID, link.Original)
+		require.NoError(t, err)
+		require.Equal(t, link.Shortened, shortened)
+
+		// the destination is deduplicated within its owner only
+		existing, err := repo.CreateOrGetShortenedURL(ctx,
+			domain.Link{Original: link.Original, Shortened: "BBBBBBBBBB", OwnerID: link.OwnerID})
+		require.NoError(t, err)
+		require.Equal(t, link, existing)
+	}
+
+	other, err := repo.CreateOrGetShortenedURL(ctx,
+		domain.Link{Original: links[0].Original, Shortened: "CCCCCCCCCC", OwnerID: "carol"})
+	require.NoError(t, err)
+	require.Equal(t, "CCCCCCCCCC", other.Shortened)
+
+	listed, err := repo.ListLinks(ctx, domain.LinkFilter{OwnerID: "alice"}, domain.Page{Limit: count})
+	require.NoError(t, err)
+	require.Len(t, listed, count/3)
+}
+
+func TestURLRepository_ListLinks_Replica(t *testing.T) {
+	t.Parallel()
+	primary, _ := pgtest.NewMigratedDatabase(t)
+	// the replica is a database with links created before the last one, as if it lagged behind
+	replica, replicaDSN := pgtest.NewMigratedDatabase(t)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+
+	replicas, err := infra.NewPostgresReplicas(infra.PostgresConfig{Replicas: infra.PostgresReplicasConfig{
+		DSNs:          []pkgconfig.Secret{pkgconfig.Secret(replicaDSN)},
+		MaxLag:        time.Minute,
+		CheckInterval: 10 * time.Millisecond,
+	}}, log)
+	require.NoError(t, err)
+	t.Cleanup(replicas.Close)
+	runCtx, stop := context.WithCancel(ctx)
+	t.Cleanup(stop)
+	go func() { _ = replicas.Run(runCtx) }()
+	require.Eventually(t, func() bool { return replicas.Reader() != nil }, 5*time.Second, 10*time.Millisecond)
+
+	repo := postgres.NewURLRepository(primary, replicas, stub.NewStub(), time.Minute, time.Second,
+		postgres.BatchConfig{})
+	t.Cleanup(repo.Close)
+	replicated := postgres.NewURLRepository(replica, nil, stub.NewStub(), time.Minute, time.Second,
+		postgres.BatchConfig{})
+	t.Cleanup(replicated.Close)
+
+	// ids of links created in the same order are equal in both databases
+	var want []domain.ShortURL
+	for i := range 30 {
+		link := domain.Link{
+			Original:  fmt.Sprintf("https://ozon.ru/%d", i),
+			Shortened: fmt.Sprintf("AAAAAA%04d", i),
+			OwnerID:   []domain.OwnerID{"alice", "bob"}[i%2],
+		}
+		_, err = repo.CreateOrGetShortenedURL(ctx, link)
+		require.NoError(t, err)
+		_, err = replicated.CreateOrGetShortenedURL(ctx, link)
+		require.NoError(t, err)
+		if link.OwnerID == "alice" {
+			want = append([]domain.ShortURL{link.Shortened}, want...)
+		}
+	}
+	lagging := domain.Link{Original: "https://ozon.ru/lagging", Shortened: "LAGGING000", OwnerID: "alice"}
+	_, err = repo.CreateOrGetShortenedURL(ctx, lagging)
+	require.NoError(t, err)
+
+	// pages are read from the replica and continue after the last id of the previous page
+	var (
+		got  []domain.ShortURL
+		page = domain.Page{Limit: 4}
+	)
+	for {
+		links, err := repo.ListLinks(ctx, domain.LinkFilter{OwnerID: "alice"}, page)
+		require.NoError(t, err)
+		for _, link := range links {
+			require.Equal(t, "alice", link.OwnerID)
+			got = append(got, link.Shortened)
+		}
+		if len(links) < page.Limit {
+			break
+		}
+		page.AfterID = links[len(links)-1].ID
+	}
+	require.Equal(t, want, got)
+
+	// links missing on the replica are resolved by the primary
+	original, err := repo.GetOriginalURLByShortened(ctx, lagging.Shortened)
+	require.NoError(t, err)
+	require.Equal(t, lagging.Original, original)
+}
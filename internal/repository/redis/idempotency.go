@@ -0,0 +1,85 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ozon_task/domain"
+	"ozon_task/internal/repository"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const idempotencyPrefix = "idempotency:"
+
+type IdempotencyRepository struct {
+	client *redis.Client
+}
+
+func NewIdempotencyRepository(client *redis.Client) repository.Idempotency {
+	return &IdempotencyRepository{
+		client: client,
+	}
+}
+
+func (r *IdempotencyRepository) ReserveIdempotencyKey(
+	ctx context.Context,
+	record domain.IdempotencyRecord,
+	ttl time.Duration,
+) (domain.IdempotencyRecord, bool, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return domain.IdempotencyRecord{}, false, fmt.Errorf("ReserveIdempotencyKey: marshal failed: %w", err)
+	}
+
+	key := idempotencyPrefix + record.Key
+	err = r.client.SetArgs(ctx, key, data, redis.SetArgs{Mode: "NX", TTL: ttl}).Err()
+	if err == nil {
+		return record, true, nil
+	}
+	if !errors.Is(err, redis.Nil) {
+		return domain.IdempotencyRecord{}, false, fmt.Errorf("ReserveIdempotencyKey: set failed: %w", err)
+	}
+
+	existing, err := r.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// reservation expired or released between commands, caller may retry
+		return domain.IdempotencyRecord{}, false,
+			fmt.Errorf("ReserveIdempotencyKey: %w", domain.ErrIdempotencyKeyInProgress)
+	} else if err != nil {
+		return domain.IdempotencyRecord{}, false, fmt.Errorf("ReserveIdempotencyKey: get failed: %w", err)
+	}
+
+	if err = json.Unmarshal(existing, &record); err != nil {
+		return domain.IdempotencyRecord{}, false, fmt.Errorf("ReserveIdempotencyKey: unmarshal failed: %w", err)
+	}
+
+	return record, false, nil
+}
+
+func (r *IdempotencyRepository) CompleteIdempotencyKey(
+	ctx context.Context,
+	record domain.IdempotencyRecord,
+	ttl time.Duration,
+) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("CompleteIdempotencyKey: marshal failed: %w", err)
+	}
+
+	if err = r.client.Set(ctx, idempotencyPrefix+record.Key, data, ttl).Err(); err != nil {
+		return fmt.Errorf("CompleteIdempotencyKey: set failed: %w", err)
+	}
+
+	return nil
+}
+
+func (r *IdempotencyRepository) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	if err := r.client.Del(ctx, idempotencyPrefix+key).Err(); err != nil {
+		return fmt.Errorf("ReleaseIdempotencyKey: del failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package redis_test
+
+import (
+	"context"
+	"ozon_task/domain"
+	redisrepo "ozon_task/internal/repository/redis"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyRepository(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	ctx := context.Background()
+	repo := redisrepo.NewIdempotencyRepository(client)
+	record := domain.IdempotencyRecord{Key: "key", Fingerprint: []byte("first"), CreatedAt: time.Now().UTC()}
+
+	_, reserved, err := repo.ReserveIdempotencyKey(ctx, record, time.Minute)
+	require.NoError(t, err)
+	require.True(t, reserved)
+
+	record.StatusCode = 200
+	record.Body = []byte(`{"shortened_url":"abcdefghij"}`)
+	require.NoError(t, repo.CompleteIdempotencyKey(ctx, record, time.Minute))
+
+	existing, reserved, err := repo.ReserveIdempotencyKey(ctx, record, time.Minute)
+	require.NoError(t, err)
+	require.False(t, reserved)
+	require.Equal(t, record.Body, existing.Body)
+	require.Equal(t, record.Fingerprint, existing.Fingerprint)
+
+	server.FastForward(time.Minute)
+
+	_, reserved, err = repo.ReserveIdempotencyKey(ctx, record, time.Minute)
+	require.NoError(t, err)
+	require.True(t, reserved, "expired key is free")
+}
@@ -0,0 +1,65 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ozon_task/domain"
+	"ozon_task/internal/repository"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// scanBatch is the number of keys requested by a single SCAN.
+const scanBatch = 500
+
+// LinkScanner lists resolve lookups cached by the URL repository, they are stored by shortened URL
+// as repository.CachedOriginal encoded to JSON. Other keys of the database are skipped.
+type LinkScanner struct {
+	client *redis.Client
+}
+
+func NewLinkScanner(client *redis.Client) *LinkScanner {
+	return &LinkScanner{
+		client: client,
+	}
+}
+
+func (s *LinkScanner) ScanLinks(
+	ctx context.Context,
+	fn func(link domain.Link, confirmedAt time.Time) bool,
+) error {
+	iter := s.client.Scan(ctx, 0, "*", scanBatch).Iterator()
+	for iter.Next(ctx) {
+		shortened := iter.Val()
+		if ok, _ := domain.IsValidShortenedURL(shortened); !ok {
+			continue
+		}
+
+		data, err := s.client.Get(ctx, shortened).Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("ScanLinks: get failed: %w", err)
+		}
+
+		var cached repository.CachedOriginal
+		if err = json.Unmarshal(data, &cached); err != nil || cached.Original == "" {
+			continue
+		}
+		link := domain.Link{Shortened: shortened, Original: cached.Original}
+		if cached.ExpiresAt != nil {
+			link.ExpiresAt = *cached.ExpiresAt
+		}
+		if !fn(link, cached.CachedAt) {
+			return nil
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("ScanLinks: scan failed: %w", err)
+	}
+
+	return nil
+}
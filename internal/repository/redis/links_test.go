@@ -0,0 +1,47 @@
+package redis_test
+
+import (
+	"context"
+	"ozon_task/domain"
+	redisrepo "ozon_task/internal/repository/redis"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkScanner(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	require.NoError(t, server.Set("abcdefghij",
+		`{"original": "https://example.com", "cached_at": "2025-01-01T12:00:00Z"}`))
+	require.NoError(t, server.Set("uvwxyzabcd",
+		`{"original": "https://ozon.ru", "expires_at": "2025-02-01T00:00:00Z", "cached_at": "2025-01-02T12:00:00Z"}`))
+	require.NoError(t, server.Set("https://example.com", `"abcdefghij"`))
+	require.NoError(t, server.Set("idempotency:key", `{}`))
+	require.NoError(t, server.Set("klmnopqrst", `not json`))
+	// lookups cached before they kept the time of confirmation
+	require.NoError(t, server.Set("mnopqrstuv", `"https://example.com"`))
+
+	links := make(map[domain.ShortURL]domain.Link)
+	confirmed := make(map[domain.ShortURL]time.Time)
+	err := redisrepo.NewLinkScanner(client).ScanLinks(context.Background(),
+		func(link domain.Link, confirmedAt time.Time) bool {
+			links[link.Shortened], confirmed[link.Shortened] = link, confirmedAt
+			return true
+		})
+	require.NoError(t, err)
+	require.Equal(t, map[domain.ShortURL]domain.Link{
+		"abcdefghij": {Shortened: "abcdefghij", Original: "https://example.com"},
+		"uvwxyzabcd": {
+			Shortened: "uvwxyzabcd",
+			Original:  "https://ozon.ru",
+			ExpiresAt: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}, links)
+	require.Equal(t, time.Date(2025, 1, 2, 12, 0, 0, 0, time.UTC), confirmed["uvwxyzabcd"])
+}
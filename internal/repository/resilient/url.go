@@ -0,0 +1,140 @@
+// Package resilient decorates repositories with retries and circuit breakers.
+package resilient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ozon_task/domain"
+	"ozon_task/internal/repository"
+	"ozon_task/pkg/resilience"
+)
+
+// ClassifyStorage classifies infrastructure errors of repositories: unavailable storage and timeouts
+// are failures of the storage, conflicts of concurrent transactions are only retried.
+// Conflicts with existing links are permanent, the same request would collide again.
+func ClassifyStorage(err error) resilience.Class {
+	switch domain.ErrorCodeOf(err) {
+	case domain.CodeStorageUnavailable, domain.CodeStorageTimeout:
+		return resilience.Failure
+	case domain.CodeConcurrentChange:
+		return resilience.Retryable
+	default:
+		return resilience.Permanent
+	}
+}
+
+// URLRepository retries reads of the wrapped repository.
+// Creations, updates and deletions are called once, as their result is unknown after a broken connection.
+type URLRepository struct {
+	repo   repository.URL
+	policy *resilience.Policy
+}
+
+func NewURLRepository(repo repository.URL, policy *resilience.Policy) repository.URL {
+	return &URLRepository{
+		repo:   repo,
+		policy: policy,
+	}
+}
+
+func (r *URLRepository) CreateOrGetShortenedURL(ctx context.Context, link domain.Link) (domain.Link, error) {
+	var res domain.Link
+	err := r.policy.DoOnce(ctx, func(ctx context.Context) (err error) {
+		res, err = r.repo.CreateOrGetShortenedURL(ctx, link)
+		return err
+	})
+	return res, unavailable(err)
+}
+
+func (r *URLRepository) GetOriginalURLByShortened(
+	ctx context.Context,
+	shortened domain.ShortURL,
+) (domain.URL, error) {
+	var res domain.URL
+	err := r.policy.Do(ctx, func(ctx context.Context) (err error) {
+		res, err = r.repo.GetOriginalURLByShortened(ctx, shortened)
+		return err
+	})
+	return res, unavailable(err)
+}
+
+func (r *URLRepository) GetShortenedURLByOriginal(
+	ctx context.Context,
+	owner domain.OwnerID,
+	original domain.URL,
+) (domain.ShortURL, error) {
+	var res domain.ShortURL
+	err := r.policy.Do(ctx, func(ctx context.Context) (err error) {
+		res, err = r.repo.GetShortenedURLByOriginal(ctx, owner, original)
+		return err
+	})
+	return res, unavailable(err)
+}
+
+func (r *URLRepository) ListLinks(
+	ctx context.Context,
+	filter domain.LinkFilter,
+	page domain.Page,
+) ([]domain.Link, error) {
+	var res []domain.Link
+	err := r.policy.Do(ctx, func(ctx context.Context) (err error) {
+		res, err = r.repo.ListLinks(ctx, filter, page)
+		return err
+	})
+	return res, unavailable(err)
+}
+
+func (r *URLRepository) GetLink(ctx context.Context, shortened domain.ShortURL) (domain.Link, error) {
+	var res domain.Link
+	err := r.policy.Do(ctx, func(ctx context.Context) (err error) {
+		res, err = r.repo.GetLink(ctx, shortened)
+		return err
+	})
+	return res, unavailable(err)
+}
+
+func (r *URLRepository) UpdateLink(
+	ctx context.Context,
+	shortened domain.ShortURL,
+	update domain.LinkUpdate,
+	author string,
+) (domain.Link, error) {
+	var res domain.Link
+	err := r.policy.DoOnce(ctx, func(ctx context.Context) (err error) {
+		res, err = r.repo.UpdateLink(ctx, shortened, update, author)
+		return err
+	})
+	return res, unavailable(err)
+}
+
+func (r *URLRepository) ListLinkVersions(
+	ctx context.Context,
+	shortened domain.ShortURL,
+) ([]domain.LinkVersion, error) {
+	var res []domain.LinkVersion
+	err := r.policy.Do(ctx, func(ctx context.Context) (err error) {
+		res, err = r.repo.ListLinkVersions(ctx, shortened)
+		return err
+	})
+	return res, unavailable(err)
+}
+
+func (r *URLRepository) DeleteLink(ctx context.Context, shortened domain.ShortURL) error {
+	return unavailable(r.policy.DoOnce(ctx, func(ctx context.Context) error {
+		return r.repo.DeleteLink(ctx, shortened)
+	}))
+}
+
+// RecordClick isn't protected, clicks are written in background on best effort basis.
+func (r *URLRepository) RecordClick(ctx context.Context, shortened domain.ShortURL) error {
+	return r.repo.RecordClick(ctx, shortened)
+}
+
+// unavailable reports calls rejected by the open breaker as unavailable storage.
+func unavailable(err error) error {
+	if errors.Is(err, resilience.ErrCircuitOpen) {
+		return fmt.Errorf("%w: %w", domain.ErrStorageUnavailable, err)
+	}
+	return err
+}
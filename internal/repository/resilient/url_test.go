@@ -0,0 +1,98 @@
+package resilient
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"ozon_task/domain"
+	"ozon_task/internal/repository/mocks"
+	"ozon_task/pkg/resilience"
+)
+
+func newTestPolicy() *resilience.Policy {
+	return resilience.NewPolicy("postgres", resilience.Config{
+		Retry:   resilience.RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, Multiplier: 2},
+		Breaker: resilience.BreakerConfig{FailureThreshold: 2, OpenTimeout: time.Minute, HalfOpenRequests: 1},
+	}, ClassifyStorage)
+}
+
+func TestURLRepository_RetriesReads(t *testing.T) {
+	t.Parallel()
+	mockRepo := new(mocks.URL)
+	unavailableErr := fmt.Errorf("%w: connection refused", domain.ErrStorageUnavailable)
+	mockRepo.On("GetOriginalURLByShortened", mock.Anything, domain.ShortURL("abc")).
+		Return(domain.URL(""), unavailableErr).Once()
+	mockRepo.On("GetOriginalURLByShortened", mock.Anything, domain.ShortURL("abc")).
+		Return(domain.URL("https://example.com"), nil).Once()
+
+	repo := NewURLRepository(mockRepo, newTestPolicy())
+	original, err := repo.GetOriginalURLByShortened(context.Background(), "abc")
+	require.NoError(t, err)
+	require.Equal(t, domain.URL("https://example.com"), original)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestURLRepository_DoesNotRetryUpdates(t *testing.T) {
+	t.Parallel()
+	mockRepo := new(mocks.URL)
+	unavailableErr := fmt.Errorf("%w: unexpected EOF", domain.ErrStorageUnavailable)
+	mockRepo.On("DeleteLink", mock.Anything, domain.ShortURL("abc")).Return(unavailableErr).Once()
+
+	repo := NewURLRepository(mockRepo, newTestPolicy())
+	err := repo.DeleteLink(context.Background(), "abc")
+	require.ErrorIs(t, err, domain.ErrStorageUnavailable)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestURLRepository_DoesNotRetryCreates(t *testing.T) {
+	t.Parallel()
+	mockRepo := new(mocks.URL)
+	unavailableErr := fmt.Errorf("%w: unexpected EOF", domain.ErrStorageUnavailable)
+	mockRepo.On("CreateOrGetShortenedURL", mock.Anything, mock.Anything).Return(domain.Link{}, unavailableErr).Once()
+
+	repo := NewURLRepository(mockRepo, newTestPolicy())
+	_, err := repo.CreateOrGetShortenedURL(context.Background(), domain.Link{Original: "https://example.com"})
+	require.ErrorIs(t, err, domain.ErrStorageUnavailable)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestClassifyStorage(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, resilience.Failure, ClassifyStorage(fmt.Errorf("%w: eof", domain.ErrStorageUnavailable)))
+	require.Equal(t, resilience.Retryable, ClassifyStorage(fmt.Errorf("%w: deadlock", domain.ErrConcurrentChange)))
+	require.Equal(t, resilience.Permanent, ClassifyStorage(fmt.Errorf("%w: duplicate key", domain.ErrConflict)))
+	require.Equal(t, resilience.Permanent, ClassifyStorage(domain.ErrOriginalNotFound))
+}
+
+func TestURLRepository_OpenBreaker(t *testing.T) {
+	t.Parallel()
+	mockRepo := new(mocks.URL)
+	timeoutErr := fmt.Errorf("%w: canceling statement", domain.ErrTimeout)
+	mockRepo.On("GetLink", mock.Anything, domain.ShortURL("abc")).Return(domain.Link{}, timeoutErr).Times(2)
+
+	repo := NewURLRepository(mockRepo, newTestPolicy())
+	_, err := repo.GetLink(context.Background(), "abc")
+	require.ErrorIs(t, err, resilience.ErrCircuitOpen, "retries stop once the breaker is opened")
+
+	_, err = repo.GetLink(context.Background(), "abc")
+	require.ErrorIs(t, err, resilience.ErrCircuitOpen)
+	require.Equal(t, domain.CodeStorageUnavailable, domain.ErrorCodeOf(err))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestURLRepository_PermanentErrors(t *testing.T) {
+	t.Parallel()
+	mockRepo := new(mocks.URL)
+	mockRepo.On("GetLink", mock.Anything, domain.ShortURL("abc")).Return(domain.Link{}, domain.ErrOriginalNotFound).Once()
+
+	repo := NewURLRepository(mockRepo, newTestPolicy())
+	_, err := repo.GetLink(context.Background(), "abc")
+	require.ErrorIs(t, err, domain.ErrOriginalNotFound)
+	mockRepo.AssertExpectations(t)
+}
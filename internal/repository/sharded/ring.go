@@ -0,0 +1,61 @@
+package sharded
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"slices"
+	"strconv"
+)
+
+// virtualNodes is the number of points of a shard on the ring, more points spread keys more evenly.
+const virtualNodes = 128
+
+// ring maps keys to shards by consistent hashing: adding a shard moves only keys of its points.
+type ring struct {
+	points []uint64
+	// shards are indices of shards owning the points
+	shards []int
+}
+
+func newRing(names []string) *ring {
+	type point struct {
+		hash  uint64
+		shard int
+	}
+	points := make([]point, 0, len(names)*virtualNodes)
+	for shard, name := range names {
+		for node := range virtualNodes {
+			points = append(points, point{hash: hashKey(name + "#" + strconv.Itoa(node)), shard: shard})
+		}
+	}
+	slices.SortFunc(points, func(a, b point) int {
+		if a.hash == b.hash {
+			return a.shard - b.shard
+		}
+		if a.hash < b.hash {
+			return -1
+		}
+		return 1
+	})
+
+	r := &ring{points: make([]uint64, len(points)), shards: make([]int, len(points))}
+	for i, p := range points {
+		r.points[i], r.shards[i] = p.hash, p.shard
+	}
+	return r
+}
+
+// shard returns the index of the shard owning the key: the one of the first point after its hash.
+func (r *ring) shard(key string) int {
+	i, _ := slices.BinarySearch(r.points, hashKey(key))
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.shards[i]
+}
+
+// hashKey must be stable across processes and versions, as it defines placement of stored links.
+func hashKey(key string) uint64 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
@@ -0,0 +1,232 @@
+// Package sharded spreads links across independent storages by consistent hashing of short codes.
+package sharded
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ozon_task/domain"
+	"ozon_task/internal/repository"
+	"slices"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// shardBits is the number of low bits of link ids keeping the index of the shard, ids of shards overlap.
+const shardBits = 8
+
+// MaxShards is the number of shards distinguishable by link ids.
+const MaxShards = 1 << shardBits
+
+// Shard is a storage of a part of links. Its name places it on the ring, so renaming the shard moves links.
+type Shard struct {
+	Name string
+	URLs repository.URL
+}
+
+// URLRepository routes operations on a link to the shard of its short code. Lookups by destination and
+// listing query all shards. Deduplication of destinations across shards is best effort: concurrent
+// creations of the same destination may get links on different shards.
+type URLRepository struct {
+	shards []Shard
+	ring   *ring
+}
+
+func NewURLRepository(shards []Shard) (*URLRepository, error) {
+	if len(shards) == 0 || len(shards) > MaxShards {
+		return nil, fmt.Errorf("NewURLRepository: number of shards must be from 1 to %d, got %d", MaxShards, len(shards))
+	}
+
+	names := make([]string, len(shards))
+	for i, shard := range shards {
+		if slices.Contains(names[:i], shard.Name) {
+			return nil, fmt.Errorf("NewURLRepository: duplicate shard %q", shard.Name)
+		}
+		names[i] = shard.Name
+	}
+
+	return &URLRepository{shards: shards, ring: newRing(names)}, nil
+}
+
+func (r *URLRepository) CreateOrGetShortenedURL(ctx context.Context, link domain.Link) (domain.Link, error) {
+	// the deduplicated link of the destination may be stored on another shard
+	if link.ExpiresAt.IsZero() {
+		shortened, err := r.GetShortenedURLByOriginal(ctx, link.OwnerID, link.Original)
+		switch {
+		case err == nil:
+			existing, err := r.GetLink(ctx, shortened)
+			if err == nil || !errors.Is(err, domain.ErrOriginalNotFound) {
+				return existing, err
+			}
+		case !errors.Is(err, domain.ErrShortenedNotFound):
+			return domain.Link{}, fmt.Errorf("CreateOrGetShortenedURL: %w", err)
+		}
+	}
+
+	i := r.ring.shard(link.Shortened)
+	created, err := r.shards[i].URLs.CreateOrGetShortenedURL(ctx, link)
+	if err != nil {
+		return domain.Link{}, r.wrap("CreateOrGetShortenedURL", i, err)
+	}
+	return globalize(created, i), nil
+}
+
+func (r *URLRepository) GetOriginalURLByShortened(
+	ctx context.Context,
+	shortened domain.ShortURL,
+) (domain.URL, error) {
+	i := r.ring.shard(shortened)
+	original, err := r.shards[i].URLs.GetOriginalURLByShortened(ctx, shortened)
+	return original, r.wrap("GetOriginalURLByShortened", i, err)
+}
+
+func (r *URLRepository) GetShortenedURLByOriginal(
+	ctx context.Context,
+	owner domain.OwnerID,
+	original domain.URL,
+) (domain.ShortURL, error) {
+	found := make([]domain.ShortURL, len(r.shards))
+	errs := make([]error, len(r.shards))
+	r.each(func(i int) {
+		found[i], errs[i] = r.shards[i].URLs.GetShortenedURLByOriginal(ctx, owner, original)
+	})
+
+	for i, shortened := range found {
+		if errs[i] == nil {
+			return shortened, nil
+		}
+	}
+	for i, err := range errs {
+		if !errors.Is(err, domain.ErrShortenedNotFound) {
+			return "", r.wrap("GetShortenedURLByOriginal", i, err)
+		}
+	}
+	return "", domain.ErrShortenedNotFound
+}
+
+// ListLinks merges pages of all shards. Links are ordered by their ids in shards, so links of
+// different shards are newest first only approximately.
+func (r *URLRepository) ListLinks(
+	ctx context.Context,
+	filter domain.LinkFilter,
+	page domain.Page,
+) ([]domain.Link, error) {
+	pages := make([][]domain.Link, len(r.shards))
+	g, ctx := errgroup.WithContext(ctx)
+	for i, shard := range r.shards {
+		shardPage, ok := localPage(page, i)
+		if !ok {
+			continue
+		}
+
+		g.Go(func() error {
+			links, err := shard.URLs.ListLinks(ctx, filter, shardPage)
+			if err != nil {
+				return r.wrap("ListLinks", i, err)
+			}
+			for j := range links {
+				links[j] = globalize(links[j], i)
+			}
+			pages[i] = links
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	links := slices.Concat(pages...)
+	slices.SortFunc(links, func(a, b domain.Link) int {
+		switch {
+		case a.ID > b.ID:
+			return -1
+		case a.ID < b.ID:
+			return 1
+		default:
+			return 0
+		}
+	})
+	return links[:min(len(links), page.Limit)], nil
+}
+
+func (r *URLRepository) GetLink(ctx context.Context, shortened domain.ShortURL) (domain.Link, error) {
+	i := r.ring.shard(shortened)
+	link, err := r.shards[i].URLs.GetLink(ctx, shortened)
+	if err != nil {
+		return domain.Link{}, r.wrap("GetLink", i, err)
+	}
+	return globalize(link, i), nil
+}
+
+func (r *URLRepository) UpdateLink(
+	ctx context.Context,
+	shortened domain.ShortURL,
+	update domain.LinkUpdate,
+	author string,
+) (domain.Link, error) {
+	i := r.ring.shard(shortened)
+	link, err := r.shards[i].URLs.UpdateLink(ctx, shortened, update, author)
+	if err != nil {
+		return domain.Link{}, r.wrap("UpdateLink", i, err)
+	}
+	return globalize(link, i), nil
+}
+
+func (r *URLRepository) ListLinkVersions(ctx context.Context, shortened domain.ShortURL) ([]domain.LinkVersion, error) {
+	i := r.ring.shard(shortened)
+	versions, err := r.shards[i].URLs.ListLinkVersions(ctx, shortened)
+	return versions, r.wrap("ListLinkVersions", i, err)
+}
+
+func (r *URLRepository) DeleteLink(ctx context.Context, shortened domain.ShortURL) error {
+	i := r.ring.shard(shortened)
+	return r.wrap("DeleteLink", i, r.shards[i].URLs.DeleteLink(ctx, shortened))
+}
+
+func (r *URLRepository) RecordClick(ctx context.Context, shortened domain.ShortURL) error {
+	i := r.ring.shard(shortened)
+	return r.wrap("RecordClick", i, r.shards[i].URLs.RecordClick(ctx, shortened))
+}
+
+// each calls fn for every shard concurrently and waits for the calls.
+func (r *URLRepository) each(fn func(i int)) {
+	var g errgroup.Group
+	for i := range r.shards {
+		g.Go(func() error {
+			fn(i)
+			return nil
+		})
+	}
+	_ = g.Wait()
+}
+
+// wrap adds the shard to failures, lookup errors are returned as is.
+func (r *URLRepository) wrap(op string, i int, err error) error {
+	code := domain.ErrorCodeOf(err)
+	if err == nil || (code != domain.CodeInternal && !domain.IsTransient(code)) {
+		return err
+	}
+	return fmt.Errorf("%s: shard %s: %w", op, r.shards[i].Name, err)
+}
+
+// globalize makes the id of the link unique across shards.
+func globalize(link domain.Link, i int) domain.Link {
+	link.ID = link.ID<<shardBits | int64(i)
+	return link
+}
+
+// localPage converts the page of global ids to the page of the shard. Returns false if the shard has
+// no links before the cursor.
+func localPage(page domain.Page, i int) (domain.Page, bool) {
+	if page.AfterID == 0 {
+		return page, true
+	}
+
+	// ids of the shard less than the cursor: local<<shardBits | i < AfterID
+	after := page.AfterID - int64(i)
+	if after <= 0 {
+		return domain.Page{}, false
+	}
+	page.AfterID = (after + MaxShards - 1) >> shardBits
+	return page, page.AfterID > 0
+}
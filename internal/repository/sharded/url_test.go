@@ -0,0 +1,161 @@
+package sharded
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"ozon_task/domain"
+	"ozon_task/internal/repository"
+	"ozon_task/internal/repository/inmem"
+	pkginmem "ozon_task/pkg/infra/kv/inmem"
+)
+
+func newShards(t *testing.T, names ...string) (*URLRepository, []repository.URL) {
+	t.Helper()
+	shards := make([]Shard, len(names))
+	repos := make([]repository.URL, len(names))
+	for i, name := range names {
+		repos[i] = inmem.NewURLRepository(pkginmem.NewPartitionedKVStorage(4))
+		shards[i] = Shard{Name: name, URLs: repos[i]}
+	}
+
+	repo, err := NewURLRepository(shards)
+	require.NoError(t, err)
+	return repo, repos
+}
+
+func shortened(i int) domain.ShortURL {
+	return fmt.Sprintf("AAAAAAA%03d", i)
+}
+
+func TestURLRepository_RoutesByShortCode(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo, shards := newShards(t, "a", "b", "c")
+
+	for i := range 30 {
+		_, err := repo.CreateOrGetShortenedURL(ctx, domain.Link{
+			Original:  fmt.Sprintf("https://ozon.ru/%d", i),
+			Shortened: shortened(i),
+		})
+		require.NoError(t, err)
+	}
+
+	for i := range 30 {
+		owner := repo.ring.shard(shortened(i))
+		for j, shard := range shards {
+			_, err := shard.GetOriginalURLByShortened(ctx, shortened(i))
+			if j == owner {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, domain.ErrOriginalNotFound)
+			}
+		}
+
+		original, err := repo.GetOriginalURLByShortened(ctx, shortened(i))
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("https://ozon.ru/%d", i), original)
+	}
+
+	// every shard gets links
+	for _, shard := range shards {
+		links, err := shard.ListLinks(ctx, domain.LinkFilter{}, domain.Page{Limit: 30})
+		require.NoError(t, err)
+		require.NotEmpty(t, links)
+	}
+}
+
+func TestURLRepository_DeduplicatesAcrossShards(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo, _ := newShards(t, "a", "b", "c", "d")
+
+	first, err := repo.CreateOrGetShortenedURL(ctx, domain.Link{Original: "https://ozon.ru", Shortened: shortened(1)})
+	require.NoError(t, err)
+
+	// the short code of another shard gets the existing link
+	other := 2
+	for repo.ring.shard(shortened(other)) == repo.ring.shard(shortened(1)) {
+		other++
+	}
+	second, err := repo.CreateOrGetShortenedURL(ctx, domain.Link{Original: "https://ozon.ru", Shortened: shortened(other)})
+	require.NoError(t, err)
+	require.Equal(t, first, second)
+
+	found, err := repo.GetShortenedURLByOriginal(ctx, "", "https://ozon.ru")
+	require.NoError(t, err)
+	require.Equal(t, shortened(1), found)
+
+	_, err = repo.GetShortenedURLByOriginal(ctx, "", "https://ozon.ru/missing")
+	require.ErrorIs(t, err, domain.ErrShortenedNotFound)
+}
+
+func TestURLRepository_ListLinksPaginatesAcrossShards(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo, _ := newShards(t, "a", "b", "c")
+
+	for i := range 25 {
+		_, err := repo.CreateOrGetShortenedURL(ctx, domain.Link{
+			Original:  fmt.Sprintf("https://ozon.ru/%d", i),
+			Shortened: shortened(i),
+			OwnerID:   "alice",
+		})
+		require.NoError(t, err)
+	}
+
+	seen := make(map[domain.ShortURL]bool)
+	page := domain.Page{Limit: 4}
+	for {
+		links, err := repo.ListLinks(ctx, domain.LinkFilter{OwnerID: "alice"}, page)
+		require.NoError(t, err)
+		for _, link := range links {
+			require.False(t, seen[link.Shortened], "link %s is listed twice", link.Shortened)
+			seen[link.Shortened] = true
+			require.Less(t, len(seen), 26)
+
+			got, err := repo.GetLink(ctx, link.Shortened)
+			require.NoError(t, err)
+			require.Equal(t, link.ID, got.ID)
+			if page.AfterID != 0 {
+				require.Less(t, link.ID, page.AfterID)
+			}
+		}
+		if len(links) < page.Limit {
+			break
+		}
+		page.AfterID = links[len(links)-1].ID
+	}
+	require.Len(t, seen, 25)
+}
+
+func TestNewURLRepository_InvalidShards(t *testing.T) {
+	t.Parallel()
+	_, err := NewURLRepository(nil)
+	require.Error(t, err)
+
+	repo := inmem.NewURLRepository(pkginmem.NewPartitionedKVStorage(1))
+	_, err = NewURLRepository([]Shard{{Name: "a", URLs: repo}, {Name: "a", URLs: repo}})
+	require.ErrorContains(t, err, `duplicate shard "a"`)
+}
+
+func TestRing_AddingShardMovesFewKeys(t *testing.T) {
+	t.Parallel()
+	before := newRing([]string{"a", "b", "c"})
+	after := newRing([]string{"a", "b", "c", "d"})
+
+	moved := 0
+	const keys = 10000
+	for i := range keys {
+		key := fmt.Sprintf("key-%d", i)
+		if before.shard(key) != after.shard(key) {
+			moved++
+			// keys move only to the new shard
+			require.Equal(t, 3, after.shard(key))
+		}
+	}
+	require.InDelta(t, keys/4, moved, keys/10)
+}
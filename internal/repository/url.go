@@ -3,22 +3,68 @@ package repository
 import (
 	"context"
 	"ozon_task/domain"
+	"time"
 )
 
-// URL defines the interface for data layer of url domain
+// URL defines the interface for data layer of url domain.
+// Transient failures of the storage are returned wrapped into `domain.ErrStorageUnavailable`,
+// `domain.ErrConcurrentChange` or `domain.ErrTimeout`. Links colliding with existing ones aren't created,
+// `domain.ErrConflict` is returned.
 //
 //go:generate go run github.com/vektra/mockery/v2@v2.52.1 --name=URL --filename=url_repository_mock.go
 type URL interface {
 	// CreateOrGetShortenedURL creates a new shortened URL or returns an existing one(if concurrent execution happened).
-	// Takes the original URL and its shortened version.
-	// Returns the shortened URL or an error.
-	CreateOrGetShortenedURL(ctx context.Context, original domain.URL, shortened domain.ShortURL) (domain.ShortURL, error)
+	// Only deduplicated links of the same owner are considered existing, links with expiration are always created.
+	// Takes the link with original URL, its shortened version, owner (empty for anonymous), tags and expiration.
+	// Id and creation time are assigned by the storage.
+	// Returns the created or existing link or an error.
+	CreateOrGetShortenedURL(ctx context.Context, link domain.Link) (domain.Link, error)
 
 	// GetOriginalURLByShortened retrieves the original URL by its shortened version.
-	// Returns `domain.ErrOriginalNotFound` if the shortened URL is not found.
+	// Returns `domain.ErrOriginalNotFound` if the shortened URL is not found
+	// and `domain.ErrLinkExpired` if the link has expired.
 	GetOriginalURLByShortened(ctx context.Context, shortened domain.ShortURL) (domain.URL, error)
 
-	// GetShortenedURLByOriginal retrieves the shortened URL by its original version.
+	// GetShortenedURLByOriginal retrieves the shortened URL of the owner (empty for anonymous) by its original version.
+	// Only deduplicated links are looked up, so shortening destinations of edited links creates new ones.
 	// Returns `domain.ErrShortenedNotFound` if the original URL is not found.
-	GetShortenedURLByOriginal(ctx context.Context, original domain.URL) (domain.ShortURL, error)
+	GetShortenedURLByOriginal(ctx context.Context, owner domain.OwnerID, original domain.URL) (domain.ShortURL, error)
+
+	// ListLinks returns up to page.Limit links matching the filter with id less than page.AfterID,
+	// ordered by id descending.
+	ListLinks(ctx context.Context, filter domain.LinkFilter, page domain.Page) ([]domain.Link, error)
+
+	// GetLink retrieves the link by its shortened URL, expired links are returned as well.
+	// Returns `domain.ErrOriginalNotFound` if the shortened URL is not found.
+	GetLink(ctx context.Context, shortened domain.ShortURL) (domain.Link, error)
+
+	// UpdateLink applies non-nil fields of the update to the link.
+	// Changed destination increments version of the link and archives the previous one to its versions.
+	// Author is the principal who made the change. Cached lookups of the link are invalidated.
+	// Returns the updated link or `domain.ErrOriginalNotFound` if the shortened URL is not found.
+	UpdateLink(
+		ctx context.Context,
+		shortened domain.ShortURL,
+		update domain.LinkUpdate,
+		author string,
+	) (domain.Link, error)
+
+	// ListLinkVersions returns all versions of the link including the current one, newest first.
+	// Returns `domain.ErrOriginalNotFound` if the shortened URL is not found.
+	ListLinkVersions(ctx context.Context, shortened domain.ShortURL) ([]domain.LinkVersion, error)
+
+	// DeleteLink removes the link with its versions and invalidates cached lookups of it.
+	// Returns `domain.ErrOriginalNotFound` if the shortened URL is not found.
+	DeleteLink(ctx context.Context, shortened domain.ShortURL) error
+
+	// RecordClick increments click counter of the link. Counting may be applied asynchronously.
+	RecordClick(ctx context.Context, shortened domain.ShortURL) error
+}
+
+// CachedOriginal is the resolve lookup of a link cached by its shortened URL. Cached lookups are also read
+// by warming of stale links, so they keep when the storage confirmed the link.
+type CachedOriginal struct {
+	Original  domain.URL `json:"original"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CachedAt  time.Time  `json:"cached_at"`
 }
@@ -0,0 +1,16 @@
+package stale
+
+import "time"
+
+type Config struct {
+	Enabled bool `yaml:"enabled" env-default:"false"`
+	// Path of the file keeping the links between restarts.
+	Path string `yaml:"path" env-default:"data/stale-links.log"`
+	// Capacity is the number of recently resolved links kept, least recently resolved are evicted.
+	Capacity int `yaml:"capacity" env-default:"100000"`
+	// MaxAge limits how long after the last confirmation by the storage a link is served, 0 disables the limit.
+	// It bounds serving of links edited or expired while the storage is down.
+	MaxAge time.Duration `yaml:"max_age" env-default:"24h"`
+	// WarmTimeout limits warming of the store from the cache on start.
+	WarmTimeout time.Duration `yaml:"warm_timeout" env-default:"30s"`
+}
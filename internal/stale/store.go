@@ -0,0 +1,411 @@
+// Package stale keeps recently resolved links on disk, so redirects are served while
+// the main storage is unavailable.
+package stale
+
+import (
+	"bufio"
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"ozon_task/domain"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by changes of the closed store.
+var ErrClosed = errors.New("stale store is closed")
+
+// touchInterval limits how often confirmations of the same link are written to the log.
+const touchInterval = time.Minute
+
+// record is a line of the log, record without original removes the link.
+type record struct {
+	Shortened   domain.ShortURL `json:"s"`
+	Original    domain.URL      `json:"o,omitempty"`
+	ExpiresAt   time.Time       `json:"e"`
+	ConfirmedAt time.Time       `json:"c"`
+}
+
+type entry struct {
+	record
+	// persistedAt is confirmation time of the link written to the log
+	persistedAt time.Time
+}
+
+// Store is an append-only log of links with their recency kept in memory. The log is compacted
+// in background once it's twice as long as the capacity and on Close, so it's ordered by recency after restart.
+type Store struct {
+	cfg Config
+	now func() time.Time
+
+	mu      sync.Mutex
+	file    *os.File
+	links   map[domain.ShortURL]*list.Element
+	recency *list.List // of *entry, most recently resolved first
+	written int        // records in the log
+
+	// compacting is set while the log is rewritten in background, lines appended meanwhile
+	// are kept in pending to be copied to the new log
+	compacting  bool
+	pending     [][]byte
+	closing     bool
+	compactions sync.WaitGroup
+}
+
+// Open loads the store from the log at cfg.Path, the file and its directory are created if missing.
+func Open(cfg Config) (*Store, error) {
+	s := &Store{
+		cfg:     cfg,
+		now:     time.Now,
+		links:   make(map[domain.ShortURL]*list.Element),
+		recency: list.New(),
+	}
+	s.cfg.Capacity = max(s.cfg.Capacity, 1)
+
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0o755); err != nil {
+		return nil, fmt.Errorf("Open: create directory failed: %w", err)
+	}
+	// logs of compactions interrupted by a crash
+	if leftovers, err := filepath.Glob(cfg.Path + ".*.tmp"); err == nil {
+		for _, path := range leftovers {
+			_ = os.Remove(path)
+		}
+	}
+	if err := s.load(); err != nil {
+		return nil, fmt.Errorf("Open: %w", err)
+	}
+	if err := s.compact(); err != nil {
+		return nil, fmt.Errorf("Open: %w", err)
+	}
+
+	return s, nil
+}
+
+// load replays the log, the last line may be torn by a crash and is skipped then.
+func (s *Store) load() error {
+	file, err := os.Open(s.cfg.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("load: open failed: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var rec record
+		if err = json.Unmarshal(scanner.Bytes(), &rec); err != nil || rec.Shortened == "" {
+			continue
+		}
+		if rec.Original == "" {
+			s.remove(rec.Shortened)
+			continue
+		}
+		s.set(rec).persistedAt = rec.ConfirmedAt
+	}
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("load: read failed: %w", err)
+	}
+
+	return nil
+}
+
+// Put remembers the link confirmed by the storage. Links without expiration time have either
+// no expiration or unknown one, they are limited only by MaxAge.
+func (s *Store) Put(shortened domain.ShortURL, original domain.URL, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if elem, ok := s.links[shortened]; ok {
+		e := elem.Value.(*entry)
+		if e.Original == original && (expiresAt.IsZero() || e.ExpiresAt.Equal(expiresAt)) {
+			e.ConfirmedAt = now
+			s.recency.MoveToFront(elem)
+			if now.Sub(e.persistedAt) < touchInterval {
+				return nil
+			}
+			return s.append(e)
+		}
+	}
+
+	return s.append(s.set(record{
+		Shortened:   shortened,
+		Original:    original,
+		ExpiresAt:   expiresAt,
+		ConfirmedAt: now,
+	}))
+}
+
+// Add remembers the link confirmed by the storage at confirmedAt, it's used to warm the store.
+// Links which are known, expired or too old aren't added, neither are links beyond the capacity.
+// Returns whether the link was added.
+func (s *Store) Add(link domain.Link, confirmedAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.links[link.Shortened]; ok || len(s.links) >= s.cfg.Capacity {
+		return false, nil
+	}
+
+	rec := record{
+		Shortened:   link.Shortened,
+		Original:    link.Original,
+		ExpiresAt:   link.ExpiresAt,
+		ConfirmedAt: confirmedAt,
+	}
+	if s.check(rec, s.now()) != nil {
+		return false, nil
+	}
+
+	e := s.set(rec)
+	s.recency.MoveToBack(s.links[link.Shortened])
+	return true, s.append(e)
+}
+
+// Get returns the original URL of the link.
+// Returns `domain.ErrOriginalNotFound` if the link isn't known or is too old
+// and `domain.ErrLinkExpired` if the link has expired.
+func (s *Store) Get(shortened domain.ShortURL) (domain.URL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.links[shortened]
+	if !ok {
+		return "", domain.ErrOriginalNotFound
+	}
+
+	e := elem.Value.(*entry)
+	if err := s.check(e.record, s.now()); err != nil {
+		return "", err
+	}
+
+	return e.Original, nil
+}
+
+// check returns the error of resolving the link at now, if it may not be served.
+func (s *Store) check(rec record, now time.Time) error {
+	if !rec.ExpiresAt.IsZero() && !now.Before(rec.ExpiresAt) {
+		return domain.ErrLinkExpired
+	}
+	if s.cfg.MaxAge > 0 && now.Sub(rec.ConfirmedAt) > s.cfg.MaxAge {
+		return domain.ErrOriginalNotFound
+	}
+	return nil
+}
+
+// Delete forgets the link, e.g. when it's edited or deleted.
+func (s *Store) Delete(shortened domain.ShortURL) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.links[shortened]; !ok {
+		return nil
+	}
+	s.remove(shortened)
+
+	return s.append(&entry{record: record{Shortened: shortened}})
+}
+
+// Len returns the number of kept links.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.links)
+}
+
+// Close waits for compaction in background, compacts the log and closes it.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	s.closing = true
+	s.mu.Unlock()
+	s.compactions.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.compact()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	s.file = nil
+
+	return err
+}
+
+// set stores the record as the most recently resolved link and evicts the least recently resolved one
+// if the store is full.
+func (s *Store) set(rec record) *entry {
+	if elem, ok := s.links[rec.Shortened]; ok {
+		e := elem.Value.(*entry)
+		e.record = rec
+		s.recency.MoveToFront(elem)
+		return e
+	}
+
+	e := &entry{record: rec}
+	s.links[rec.Shortened] = s.recency.PushFront(e)
+	if len(s.links) > s.cfg.Capacity {
+		s.remove(s.recency.Back().Value.(*entry).Shortened)
+	}
+
+	return e
+}
+
+func (s *Store) remove(shortened domain.ShortURL) {
+	if elem, ok := s.links[shortened]; ok {
+		s.recency.Remove(elem)
+		delete(s.links, shortened)
+	}
+}
+
+func (s *Store) append(e *entry) error {
+	if s.file == nil {
+		return ErrClosed
+	}
+
+	data, err := json.Marshal(e.record)
+	if err != nil {
+		return fmt.Errorf("append: marshal failed: %w", err)
+	}
+	line := append(data, '\n')
+	if _, err = s.file.Write(line); err != nil {
+		return fmt.Errorf("append: write failed: %w", err)
+	}
+	if s.compacting {
+		s.pending = append(s.pending, line)
+	}
+	e.persistedAt = e.ConfirmedAt
+	s.written++
+
+	if s.written > 2*s.cfg.Capacity && !s.compacting && !s.closing {
+		s.compactInBackground()
+	}
+	return nil
+}
+
+// compact rewrites the log synchronously, it's used on opening and closing.
+func (s *Store) compact() error {
+	tmp, err := s.writeLog(s.snapshot())
+	if err != nil {
+		return fmt.Errorf("compact: %w", err)
+	}
+	if err = s.swapLog(tmp, len(s.links), nil); err != nil {
+		return fmt.Errorf("compact: %w", err)
+	}
+	return nil
+}
+
+// compactInBackground rewrites the log without holding the lock. Lines appended to the current log
+// meanwhile are copied to the new one before it replaces the current log. The current log is complete,
+// so it's kept if the compaction fails and the next append tries again.
+func (s *Store) compactInBackground() {
+	records := s.snapshot()
+	s.compacting = true
+	s.compactions.Add(1)
+
+	go func() {
+		defer s.compactions.Done()
+
+		tmp, err := s.writeLog(records)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		pending := s.pending
+		s.compacting, s.pending = false, nil
+		if err != nil {
+			return
+		}
+		if s.file == nil {
+			discardLog(tmp)
+			return
+		}
+		_ = s.swapLog(tmp, len(records), pending)
+	}()
+}
+
+// snapshot copies the kept links from the least recently resolved one.
+func (s *Store) snapshot() []record {
+	records := make([]record, 0, len(s.links))
+	for elem := s.recency.Back(); elem != nil; elem = elem.Prev() {
+		e := elem.Value.(*entry)
+		records = append(records, e.record)
+		e.persistedAt = e.ConfirmedAt
+	}
+	return records
+}
+
+// writeLog writes the records to a new temporary log next to the current one.
+func (s *Store) writeLog(records []record) (*os.File, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(s.cfg.Path), filepath.Base(s.cfg.Path)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("writeLog: create failed: %w", err)
+	}
+
+	w := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(w)
+	for _, rec := range records {
+		if err = encoder.Encode(rec); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		discardLog(tmp)
+		return nil, fmt.Errorf("writeLog: write failed: %w", err)
+	}
+
+	return tmp, nil
+}
+
+// swapLog appends the pending lines to the written log, syncs it and replaces the current log by renaming,
+// so it's never left partially written. Written is the number of records in the new log.
+func (s *Store) swapLog(tmp *os.File, written int, pending [][]byte) error {
+	var err error
+	for _, line := range pending {
+		if _, err = tmp.Write(line); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err != nil {
+		discardLog(tmp)
+		return fmt.Errorf("swapLog: write failed: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("swapLog: close failed: %w", err)
+	}
+
+	if err = os.Rename(tmp.Name(), s.cfg.Path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("swapLog: rename failed: %w", err)
+	}
+	file, err := os.OpenFile(s.cfg.Path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("swapLog: open failed: %w", err)
+	}
+	if s.file != nil {
+		_ = s.file.Close()
+	}
+	s.file = file
+	s.written = written + len(pending)
+
+	return nil
+}
+
+func discardLog(tmp *os.File) {
+	_ = tmp.Close()
+	_ = os.Remove(tmp.Name())
+}
@@ -0,0 +1,177 @@
+package stale
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"ozon_task/domain"
+)
+
+func openTestStore(t *testing.T, path string, capacity int) *Store {
+	t.Helper()
+	store, err := Open(Config{Path: path, Capacity: capacity, MaxAge: time.Hour})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+func TestStore_PutGet(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := openTestStore(t, filepath.Join(t.TempDir(), "links.log"), 10)
+	store.now = func() time.Time { return now }
+
+	require.NoError(t, store.Put("aaaaaaaaaa", "https://a.com", time.Time{}))
+	require.NoError(t, store.Put("bbbbbbbbbb", "https://b.com", now.Add(time.Minute)))
+
+	original, err := store.Get("aaaaaaaaaa")
+	require.NoError(t, err)
+	require.Equal(t, domain.URL("https://a.com"), original)
+
+	_, err = store.Get("cccccccccc")
+	require.ErrorIs(t, err, domain.ErrOriginalNotFound)
+
+	now = now.Add(time.Minute)
+	_, err = store.Get("bbbbbbbbbb")
+	require.ErrorIs(t, err, domain.ErrLinkExpired)
+
+	require.NoError(t, store.Put("bbbbbbbbbb", "https://b.com", time.Time{}))
+	_, err = store.Get("bbbbbbbbbb")
+	require.ErrorIs(t, err, domain.ErrLinkExpired, "unknown expiration doesn't clear the known one")
+
+	now = now.Add(time.Hour + time.Second)
+	_, err = store.Get("aaaaaaaaaa")
+	require.ErrorIs(t, err, domain.ErrOriginalNotFound, "links unconfirmed for max age aren't served")
+
+	require.NoError(t, store.Delete("aaaaaaaaaa"))
+	require.Equal(t, 1, store.Len())
+}
+
+func TestStore_EvictsLeastRecentlyResolved(t *testing.T) {
+	t.Parallel()
+	store := openTestStore(t, filepath.Join(t.TempDir(), "links.log"), 2)
+
+	require.NoError(t, store.Put("aaaaaaaaaa", "https://a.com", time.Time{}))
+	require.NoError(t, store.Put("bbbbbbbbbb", "https://b.com", time.Time{}))
+	require.NoError(t, store.Put("aaaaaaaaaa", "https://a.com", time.Time{}))
+	require.NoError(t, store.Put("cccccccccc", "https://c.com", time.Time{}))
+
+	_, err := store.Get("bbbbbbbbbb")
+	require.ErrorIs(t, err, domain.ErrOriginalNotFound)
+	_, err = store.Get("aaaaaaaaaa")
+	require.NoError(t, err)
+
+	added, err := store.Add(domain.Link{Shortened: "dddddddddd", Original: "https://d.com"}, time.Now())
+	require.NoError(t, err)
+	require.False(t, added, "warming doesn't evict resolved links")
+}
+
+func TestStore_Reopen(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "data", "links.log")
+
+	store, err := Open(Config{Path: path, Capacity: 2})
+	require.NoError(t, err)
+	require.NoError(t, store.Put("aaaaaaaaaa", "https://a.com", time.Time{}))
+	require.NoError(t, store.Put("bbbbbbbbbb", "https://b.com", time.Time{}))
+	require.NoError(t, store.Put("bbbbbbbbbb", "https://b2.com", time.Time{}))
+	require.NoError(t, store.Put("cccccccccc", "https://c.com", time.Time{}))
+	require.NoError(t, store.Delete("cccccccccc"))
+	store.compactions.Wait()
+
+	// simulate a crash in the middle of a write
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"s":"dddddd`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	reopened := openTestStore(t, path, 2)
+	require.Equal(t, 1, reopened.Len())
+	original, err := reopened.Get("bbbbbbbbbb")
+	require.NoError(t, err)
+	require.Equal(t, domain.URL("https://b2.com"), original)
+	require.NoError(t, store.Close())
+}
+
+// sliceSource lists links confirmed by the storage at the time of their creation.
+type sliceSource []domain.Link
+
+func (s sliceSource) ScanLinks(_ context.Context, fn func(domain.Link, time.Time) bool) error {
+	for _, link := range s {
+		if !fn(link, link.CreatedAt) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func TestStore_Warm(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := openTestStore(t, filepath.Join(t.TempDir(), "links.log"), 3)
+	store.now = func() time.Time { return now }
+	require.NoError(t, store.Put("aaaaaaaaaa", "https://a.com", time.Time{}))
+
+	added, err := store.Warm(context.Background(), sliceSource{
+		{Shortened: "aaaaaaaaaa", Original: "https://old.com", CreatedAt: now},
+		{Shortened: "eeeeeeeeee", Original: "https://e.com", CreatedAt: now.Add(-2 * time.Hour)},
+		{Shortened: "ffffffffff", Original: "https://f.com", CreatedAt: now, ExpiresAt: now},
+		{Shortened: "bbbbbbbbbb", Original: "https://b.com", CreatedAt: now.Add(-30 * time.Minute)},
+		{Shortened: "cccccccccc", Original: "https://c.com", CreatedAt: now, ExpiresAt: now.Add(time.Minute)},
+		{Shortened: "dddddddddd", Original: "https://d.com", CreatedAt: now},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, added, "expired and too old links are skipped, the rest is limited by capacity")
+
+	original, err := store.Get("aaaaaaaaaa")
+	require.NoError(t, err)
+	require.Equal(t, domain.URL("https://a.com"), original, "known links aren't overwritten")
+
+	// warmed links keep the time of confirmation and expiration
+	now = now.Add(time.Minute)
+	_, err = store.Get("cccccccccc")
+	require.ErrorIs(t, err, domain.ErrLinkExpired)
+	_, err = store.Get("bbbbbbbbbb")
+	require.NoError(t, err)
+	now = now.Add(30 * time.Minute)
+	_, err = store.Get("bbbbbbbbbb")
+	require.ErrorIs(t, err, domain.ErrOriginalNotFound)
+}
+
+func TestStore_CompactsInBackground(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "links.log")
+	store, err := Open(Config{Path: path, Capacity: 2})
+	require.NoError(t, err)
+
+	require.NoError(t, store.Put("aaaaaaaaaa", "https://a.com", time.Time{}))
+	require.NoError(t, store.Put("bbbbbbbbbb", "https://b.com", time.Time{}))
+
+	// links changed while the log is rewritten are kept in the new log
+	store.mu.Lock()
+	store.compactInBackground()
+	store.mu.Unlock()
+	require.NoError(t, store.Put("cccccccccc", "https://c.com", time.Time{}))
+	require.NoError(t, store.Delete("aaaaaaaaaa"))
+	store.compactions.Wait()
+
+	leftovers, err := filepath.Glob(path + ".*.tmp")
+	require.NoError(t, err)
+	require.Empty(t, leftovers)
+
+	// the log is read without closing, as after a crash
+	reopened := openTestStore(t, path, 2)
+	require.Equal(t, 2, reopened.Len())
+	_, err = reopened.Get("aaaaaaaaaa")
+	require.ErrorIs(t, err, domain.ErrOriginalNotFound)
+	original, err := reopened.Get("cccccccccc")
+	require.NoError(t, err)
+	require.Equal(t, domain.URL("https://c.com"), original)
+	require.NoError(t, store.Close())
+}
@@ -0,0 +1,35 @@
+package stale
+
+import (
+	"context"
+	"ozon_task/domain"
+	"time"
+)
+
+// Source lists links cached elsewhere, e.g. lookups of the URL repository kept in redis.
+type Source interface {
+	// ScanLinks calls fn for every cached link with the time the storage confirmed it until fn returns false.
+	// Expiration time of links without it is unknown.
+	ScanLinks(ctx context.Context, fn func(link domain.Link, confirmedAt time.Time) bool) error
+}
+
+// Warm adds links of the source unknown to the store until it's full.
+// Returns the number of added links, which are kept even if scanning fails.
+func (s *Store) Warm(ctx context.Context, src Source) (int, error) {
+	var (
+		added int
+		err   error
+	)
+	scanErr := src.ScanLinks(ctx, func(link domain.Link, confirmedAt time.Time) bool {
+		var ok bool
+		if ok, err = s.Add(link, confirmedAt); ok {
+			added++
+		}
+		return err == nil && s.Len() < s.cfg.Capacity
+	})
+	if err != nil {
+		return added, err
+	}
+
+	return added, scanErr
+}
@@ -0,0 +1,79 @@
+package transfer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// checkpoint is the saved progress of the import of the input.
+type checkpoint struct {
+	// Input identifies the imported data, e.g. the path of the file.
+	Input string `json:"input"`
+	Progress
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LoadCheckpoint reads the progress of the interrupted import of the input, zero progress if there is
+// no checkpoint file. Checkpoints of other inputs are refused, as their records don't match.
+func LoadCheckpoint(path, input string) (Progress, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Progress{}, nil
+	}
+	if err != nil {
+		return Progress{}, fmt.Errorf("LoadCheckpoint: %w", err)
+	}
+
+	var saved checkpoint
+	if err = json.Unmarshal(data, &saved); err != nil {
+		return Progress{}, fmt.Errorf("LoadCheckpoint: malformed checkpoint %s: %w", path, err)
+	}
+	if saved.Input != input {
+		return Progress{}, fmt.Errorf("LoadCheckpoint: checkpoint %s is of input %q, not %q", path, saved.Input, input)
+	}
+
+	return saved.Progress, nil
+}
+
+// SaveCheckpoint replaces the checkpoint file atomically, so an interrupted write keeps the previous one.
+func SaveCheckpoint(path, input string, progress Progress) error {
+	data, err := json.Marshal(checkpoint{Input: input, Progress: progress, UpdatedAt: time.Now().UTC()})
+	if err != nil {
+		return fmt.Errorf("SaveCheckpoint: %w", err)
+	}
+
+	if err = WriteFileAtomic(path, data); err != nil {
+		return fmt.Errorf("SaveCheckpoint: %w", err)
+	}
+	return nil
+}
+
+// WriteFileAtomic replaces the file with the data synced to disk, so a crash leaves either the previous
+// content or the new one.
+func WriteFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("WriteFileAtomic: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("WriteFileAtomic: %w", err)
+	}
+
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("WriteFileAtomic: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,295 @@
+package transfer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"ozon_task/domain"
+	"slices"
+	"time"
+)
+
+// Format is the encoding of links in exports and imports.
+type Format string
+
+const (
+	// FormatJSONL is a JSON object per line.
+	FormatJSONL Format = "jsonl"
+	// FormatCSV is a table with the header, tags are a JSON array in a cell.
+	FormatCSV Format = "csv"
+)
+
+func ParseFormat(value string) (Format, error) {
+	switch format := Format(value); format {
+	case FormatJSONL, FormatCSV:
+		return format, nil
+	case "":
+		return FormatJSONL, nil
+	default:
+		return "", fmt.Errorf("ParseFormat: unknown format %q: %w", value, domain.ErrInvalidRequest)
+	}
+}
+
+// ContentType is the media type of the format for HTTP.
+func (f Format) ContentType() string {
+	if f == FormatCSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// Record is a link as it's exported. Version, clicks and creation time aren't exported,
+// the storage assigns them to imported links.
+type Record struct {
+	Shortened domain.ShortURL `json:"shortened_url"`
+	Original  domain.URL      `json:"original_url"`
+	OwnerID   domain.OwnerID  `json:"owner_id,omitempty"`
+	Tags      []domain.Tag    `json:"tags,omitempty"`
+	ExpiresAt *time.Time      `json:"expires_at,omitempty"`
+}
+
+func NewRecord(link domain.Link) Record {
+	record := Record{
+		Shortened: link.Shortened,
+		Original:  link.Original,
+		OwnerID:   link.OwnerID,
+		Tags:      link.Tags,
+	}
+	if !link.ExpiresAt.IsZero() {
+		record.ExpiresAt = &link.ExpiresAt
+	}
+	return record
+}
+
+// Link returns the link to create keeping the shortened url of the record.
+func (r Record) Link() domain.Link {
+	link := domain.Link{
+		Original:  r.Original,
+		Shortened: r.Shortened,
+		OwnerID:   r.OwnerID,
+		Tags:      r.Tags,
+	}
+	if r.ExpiresAt != nil {
+		link.ExpiresAt = *r.ExpiresAt
+	}
+	return link
+}
+
+// Validate checks the record the same way as links created by the api, maxOriginalLength is the limit
+// of length of the original url, see domain.IsValidOriginalURL. Expired links are valid,
+// so backups are restored as they were.
+func (r Record) Validate(maxOriginalLength int) error {
+	if _, err := domain.IsValidShortenedURL(r.Shortened); err != nil {
+		return fmt.Errorf("Validate: %w", err)
+	}
+	if _, err := domain.IsValidOriginalURL(r.Original, maxOriginalLength); err != nil {
+		return fmt.Errorf("Validate: %w", err)
+	}
+	if err := domain.ValidateTags(r.Tags); err != nil {
+		return fmt.Errorf("Validate: %w", err)
+	}
+	return nil
+}
+
+// Writer encodes records in the format, Flush must be called after the last one.
+type Writer interface {
+	Write(record Record) error
+	Flush() error
+}
+
+// Reader decodes records of the format. It returns io.EOF after the last record and errors wrapping
+// domain errors of validation for malformed records, reading may go on after them.
+type Reader interface {
+	Read() (Record, error)
+}
+
+func NewWriter(w io.Writer, format Format) Writer {
+	if format == FormatCSV {
+		return &csvWriter{w: csv.NewWriter(w)}
+	}
+
+	buf := bufio.NewWriter(w)
+	return &jsonlWriter{buf: buf, encoder: json.NewEncoder(buf)}
+}
+
+// NewReader creates the reader of the format, CSV header is read at once and its errors are returned.
+func NewReader(r io.Reader, format Format) (Reader, error) {
+	if format == FormatCSV {
+		reader := &csvReader{r: csv.NewReader(r)}
+		reader.r.ReuseRecord = true
+		if err := reader.readHeader(); err != nil {
+			return nil, fmt.Errorf("NewReader: %w", err)
+		}
+		return reader, nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLineLength)
+	return &jsonlReader{scanner: scanner}, nil
+}
+
+// maxLineLength limits a JSON line, it's far longer than any valid link.
+const maxLineLength = 1 << 20
+
+type jsonlWriter struct {
+	buf     *bufio.Writer
+	encoder *json.Encoder
+}
+
+func (w *jsonlWriter) Write(record Record) error {
+	return w.encoder.Encode(record)
+}
+
+func (w *jsonlWriter) Flush() error {
+	return w.buf.Flush()
+}
+
+type jsonlReader struct {
+	scanner *bufio.Scanner
+}
+
+// Read skips blank lines, so they aren't counted as records.
+func (r *jsonlReader) Read() (Record, error) {
+	for r.scanner.Scan() {
+		line := bytes.TrimSpace(r.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(line, &record); err != nil {
+			return Record{}, fmt.Errorf("Read: malformed json: %v: %w", err, domain.ErrInvalidRequest)
+		}
+		return record, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return Record{}, fmt.Errorf("Read: %w", err)
+	}
+	return Record{}, io.EOF
+}
+
+// columns of CSV in the order they're written, only the first two are required to read.
+var columns = []string{"shortened_url", "original_url", "owner_id", "tags", "expires_at"}
+
+type csvWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func (w *csvWriter) Write(record Record) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	var tags, expiresAt string
+	if len(record.Tags) != 0 {
+		data, err := json.Marshal(record.Tags)
+		if err != nil {
+			return fmt.Errorf("Write: %w", err)
+		}
+		tags = string(data)
+	}
+	if record.ExpiresAt != nil {
+		expiresAt = record.ExpiresAt.Format(time.RFC3339Nano)
+	}
+
+	return w.w.Write([]string{
+		record.Shortened,
+		record.Original,
+		record.OwnerID,
+		tags,
+		expiresAt,
+	})
+}
+
+// Flush writes the header for empty exports as well.
+func (w *csvWriter) Flush() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func (w *csvWriter) writeHeader() error {
+	if w.header {
+		return nil
+	}
+	w.header = true
+	return w.w.Write(columns)
+}
+
+type csvReader struct {
+	r *csv.Reader
+	// index of columns in the header, -1 for absent ones, nil for empty input
+	index []int
+}
+
+func (r *csvReader) Read() (Record, error) {
+	if r.index == nil {
+		return Record{}, io.EOF
+	}
+
+	row, err := r.r.Read()
+	var parseErr *csv.ParseError
+	switch {
+	case errors.Is(err, io.EOF):
+		return Record{}, io.EOF
+	case errors.As(err, &parseErr):
+		return Record{}, fmt.Errorf("Read: malformed csv: %v: %w", err, domain.ErrInvalidRequest)
+	case err != nil:
+		return Record{}, fmt.Errorf("Read: %w", err)
+	}
+
+	cell := func(column int) string {
+		if i := r.index[column]; i >= 0 {
+			return row[i]
+		}
+		return ""
+	}
+
+	record := Record{Shortened: cell(0), Original: cell(1), OwnerID: cell(2)}
+	if tags := cell(3); len(tags) != 0 {
+		if err = json.Unmarshal([]byte(tags), &record.Tags); err != nil {
+			return Record{}, fmt.Errorf("Read: malformed tags: %w", domain.ErrInvalidTags)
+		}
+	}
+	if expiresAt := cell(4); len(expiresAt) != 0 {
+		parsed, err := time.Parse(time.RFC3339Nano, expiresAt)
+		if err != nil {
+			return Record{}, fmt.Errorf("Read: malformed expires_at: %w", domain.ErrInvalidExpiration)
+		}
+		record.ExpiresAt = &parsed
+	}
+
+	return record, nil
+}
+
+// readHeader maps known columns to their positions, unknown columns are ignored,
+// so exports of older versions with version, clicks and created_at are read as well.
+func (r *csvReader) readHeader() error {
+	header, err := r.r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("malformed csv header: %v: %w", err, domain.ErrInvalidRequest)
+	}
+
+	index := make([]int, len(columns))
+	for i, column := range columns {
+		index[i] = slices.Index(header, column)
+	}
+	if index[0] < 0 || index[1] < 0 {
+		return fmt.Errorf("csv header must have %s and %s columns: %w", columns[0], columns[1], domain.ErrInvalidRequest)
+	}
+	r.index = index
+	// rows are checked to have as many cells as the header
+	r.r.FieldsPerRecord = len(header)
+	return nil
+}
@@ -0,0 +1,217 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"ozon_task/domain"
+	"ozon_task/internal/repository"
+	"time"
+)
+
+// Export writes links of the repository matching the filter, newest first. It returns the number
+// of written links, the writer is flushed.
+func Export(ctx context.Context, repo repository.URL, filter domain.LinkFilter, w Writer) (int, error) {
+	count := 0
+	page := domain.Page{Limit: domain.MaxPageSize}
+	for {
+		links, err := repo.ListLinks(ctx, filter, page)
+		if err != nil {
+			return count, fmt.Errorf("Export: failed to list links: %w", err)
+		}
+
+		for _, link := range links {
+			if err = w.Write(NewRecord(link)); err != nil {
+				return count, fmt.Errorf("Export: failed to write link: %w", err)
+			}
+			count++
+		}
+
+		if len(links) < page.Limit {
+			break
+		}
+		page.AfterID = links[len(links)-1].ID
+	}
+
+	if err := w.Flush(); err != nil {
+		return count, fmt.Errorf("Export: failed to flush: %w", err)
+	}
+	return count, nil
+}
+
+// Progress counts records of the import. It's saved as the checkpoint to resume the import.
+type Progress struct {
+	// Records is the number of processed records of the input, they're skipped on resume.
+	Records  int `json:"records"`
+	Imported int `json:"imported"`
+	// Existing records are already stored with the same original url, e.g. by the previous run.
+	Existing  int `json:"existing"`
+	Conflicts int `json:"conflicts"`
+	Invalid   int `json:"invalid"`
+}
+
+// Conflict is a record which can't be imported as its shortened url maps to another original url,
+// or its original url is deduplicated to another shortened url. Existing is the stored link.
+type Conflict struct {
+	// Number is the position of the record in the input starting from 1.
+	Number   int
+	Record   Record
+	Existing domain.Link
+}
+
+// ImportOptions tunes the import. Zero options import all records without checkpoints.
+type ImportOptions struct {
+	// Resume is the checkpoint of the interrupted import, its records are skipped and counts are continued.
+	Resume Progress
+	// Checkpoint is called with the progress every CheckpointEvery records and after the last one.
+	Checkpoint      func(Progress) error
+	CheckpointEvery int
+	// OnConflict and OnInvalid report records which aren't imported, number starts from 1.
+	OnConflict func(Conflict)
+	OnInvalid  func(number int, err error)
+	// RecordTimeout limits storing of a record, zero means no limit.
+	RecordTimeout time.Duration
+	// MaxOriginalLength limits original urls of records, zero means domain.DefaultMaxOriginalURLLength.
+	MaxOriginalLength int
+}
+
+// Import creates links of the records keeping their shortened urls. It's idempotent: records already
+// stored are counted as existing, so an import can be repeated or resumed from any checkpoint.
+// Invalid records and conflicts are skipped. Import stops on failures of the storage and the reader,
+// the returned progress is the last consistent one to resume from.
+func Import(ctx context.Context, repo repository.URL, r Reader, opts ImportOptions) (Progress, error) {
+	progress := opts.Resume
+	for number := 1; ; number++ {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil && fatal(err) {
+			return progress, fmt.Errorf("Import: record %d: %w", number, err)
+		}
+		if number <= opts.Resume.Records {
+			continue
+		}
+
+		if err == nil {
+			err = record.Validate(opts.MaxOriginalLength)
+		}
+		if err == nil {
+			err = importRecord(ctx, repo, number, record, &progress, opts)
+		} else {
+			err = skipInvalid(number, err, &progress, opts)
+		}
+		if err != nil {
+			return progress, fmt.Errorf("Import: record %d: %w", number, err)
+		}
+
+		progress.Records = number
+		if opts.Checkpoint != nil && opts.CheckpointEvery > 0 && number%opts.CheckpointEvery == 0 {
+			if err = opts.Checkpoint(progress); err != nil {
+				return progress, fmt.Errorf("Import: checkpoint failed: %w", err)
+			}
+		}
+	}
+
+	if opts.Checkpoint != nil {
+		if err := opts.Checkpoint(progress); err != nil {
+			return progress, fmt.Errorf("Import: checkpoint failed: %w", err)
+		}
+	}
+	return progress, nil
+}
+
+// importRecord stores the valid record and counts it.
+func importRecord(
+	ctx context.Context,
+	repo repository.URL,
+	number int,
+	record Record,
+	progress *Progress,
+	opts ImportOptions,
+) error {
+	if opts.RecordTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.RecordTimeout)
+		defer cancel()
+	}
+
+	result, existing, err := Store(ctx, repo, record)
+	if err != nil {
+		return skipInvalid(number, err, progress, opts)
+	}
+
+	switch result {
+	case Imported:
+		progress.Imported++
+	case Existing:
+		progress.Existing++
+	case Conflicting:
+		progress.Conflicts++
+		if opts.OnConflict != nil {
+			opts.OnConflict(Conflict{Number: number, Record: record, Existing: existing})
+		}
+	}
+	return nil
+}
+
+// Result of storing a record.
+type Result int
+
+const (
+	// Imported record is stored as a new link.
+	Imported Result = iota
+	// Existing record is already stored with the same original url.
+	Existing
+	// Conflicting record isn't stored, as its shortened or original url is used by another link.
+	Conflicting
+)
+
+// Store creates the link of the record keeping its shortened url, the record isn't validated.
+// The stored link is returned for conflicting records.
+func Store(ctx context.Context, repo repository.URL, record Record) (Result, domain.Link, error) {
+	// shortened urls aren't unique in every storage, so they're looked up before creation
+	existing, err := repo.GetLink(ctx, record.Shortened)
+	switch {
+	case err == nil && existing.Original == record.Original:
+		return Existing, domain.Link{}, nil
+	case err == nil:
+		return Conflicting, existing, nil
+	case !errors.Is(err, domain.ErrOriginalNotFound):
+		return 0, domain.Link{}, fmt.Errorf("Store: %w", err)
+	}
+
+	link, err := repo.CreateOrGetShortenedURL(ctx, record.Link())
+	if err != nil {
+		return 0, domain.Link{}, fmt.Errorf("Store: %w", err)
+	}
+	if link.Shortened != record.Shortened {
+		return Conflicting, link, nil
+	}
+	return Imported, domain.Link{}, nil
+}
+
+// skipInvalid counts the record failed with the error as invalid, fatal errors are returned.
+func skipInvalid(number int, err error, progress *Progress, opts ImportOptions) error {
+	if fatal(err) {
+		return err
+	}
+
+	progress.Invalid++
+	if opts.OnInvalid != nil {
+		opts.OnInvalid(number, err)
+	}
+	return nil
+}
+
+// fatal reports whether the error isn't caused by the record, e.g. a failure of the storage, the reader
+// or the context, so the import can't go on.
+func fatal(err error) bool {
+	switch code := domain.ErrorCodeOf(err); code {
+	case domain.CodeInternal, domain.CodeTimeout, domain.CodeCanceled:
+		return true
+	default:
+		return domain.IsTransient(code)
+	}
+}
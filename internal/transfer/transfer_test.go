@@ -0,0 +1,234 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"ozon_task/domain"
+	"ozon_task/internal/repository"
+	"ozon_task/internal/repository/inmem"
+	pkginmem "ozon_task/pkg/infra/kv/inmem"
+)
+
+func newRepo() repository.URL {
+	return inmem.NewURLRepository(pkginmem.NewPartitionedKVStorage(4))
+}
+
+func shortened(i int) domain.ShortURL {
+	return fmt.Sprintf("AAAAAAA%03d", i)
+}
+
+func seed(t *testing.T, repo repository.URL, n int) {
+	t.Helper()
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	for i := range n {
+		link := domain.Link{
+			Original:  fmt.Sprintf("https://ozon.ru/%d", i),
+			Shortened: shortened(i),
+			OwnerID:   "alice",
+			Tags:      []domain.Tag{"promo", "a,b"},
+		}
+		if i%2 == 0 {
+			link.ExpiresAt = expiresAt
+		}
+		_, err := repo.CreateOrGetShortenedURL(context.Background(), link)
+		require.NoError(t, err)
+	}
+}
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range []Format{FormatJSONL, FormatCSV} {
+		t.Run(string(format), func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			source := newRepo()
+			seed(t, source, 5)
+
+			var buf bytes.Buffer
+			count, err := Export(ctx, source, domain.LinkFilter{}, NewWriter(&buf, format))
+			require.NoError(t, err)
+			require.Equal(t, 5, count)
+
+			target := newRepo()
+			reader, err := NewReader(bytes.NewReader(buf.Bytes()), format)
+			require.NoError(t, err)
+			progress, err := Import(ctx, target, reader, ImportOptions{})
+			require.NoError(t, err)
+			require.Equal(t, Progress{Records: 5, Imported: 5}, progress)
+
+			for i := range 5 {
+				want, err := source.GetLink(ctx, shortened(i))
+				require.NoError(t, err)
+				got, err := target.GetLink(ctx, shortened(i))
+				require.NoError(t, err)
+				require.Equal(t, want.Original, got.Original)
+				require.Equal(t, want.OwnerID, got.OwnerID)
+				require.Equal(t, want.Tags, got.Tags)
+				require.True(t, want.ExpiresAt.Equal(got.ExpiresAt))
+			}
+
+			// the second run changes nothing
+			reader, err = NewReader(bytes.NewReader(buf.Bytes()), format)
+			require.NoError(t, err)
+			progress, err = Import(ctx, target, reader, ImportOptions{})
+			require.NoError(t, err)
+			require.Equal(t, Progress{Records: 5, Existing: 5}, progress)
+		})
+	}
+}
+
+func TestImport_ConflictsAndInvalidRecords(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := newRepo()
+	seed(t, repo, 2)
+	// the deduplicated link of the record below is stored under another shortened url
+	_, err := repo.CreateOrGetShortenedURL(ctx, domain.Link{Original: "https://ozon.ru/dedup", Shortened: shortened(9)})
+	require.NoError(t, err)
+
+	input := strings.Join([]string{
+		`{"shortened_url":"AAAAAAA001","original_url":"https://ozon.ru/1"}`,
+		`{"shortened_url":"AAAAAAA000","original_url":"https://ozon.ru/other"}`,
+		`{"shortened_url":"AAAAAAA005","original_url":"https://ozon.ru/dedup"}`,
+		`{"shortened_url":"short","original_url":"https://ozon.ru/5"}`,
+		`not json`,
+		``,
+		`{"shortened_url":"AAAAAAA006","original_url":"https://ozon.ru/6"}`,
+	}, "\n")
+
+	var (
+		conflicts []Conflict
+		invalid   = map[int]error{}
+	)
+	reader, err := NewReader(strings.NewReader(input), FormatJSONL)
+	require.NoError(t, err)
+	progress, err := Import(ctx, repo, reader, ImportOptions{
+		OnConflict: func(conflict Conflict) { conflicts = append(conflicts, conflict) },
+		OnInvalid:  func(number int, err error) { invalid[number] = err },
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, Progress{Records: 6, Imported: 1, Existing: 1, Conflicts: 2, Invalid: 2}, progress)
+
+	require.Len(t, conflicts, 2)
+	require.Equal(t, 2, conflicts[0].Number)
+	require.Equal(t, "https://ozon.ru/0", conflicts[0].Existing.Original)
+	require.Equal(t, 3, conflicts[1].Number)
+	require.Equal(t, shortened(9), conflicts[1].Existing.Shortened)
+
+	require.ErrorIs(t, invalid[4], domain.ErrInvalidShortened)
+	require.ErrorIs(t, invalid[5], domain.ErrInvalidRequest)
+}
+
+func TestImport_TooLongOriginal(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := newRepo()
+
+	input := `{"shortened_url":"AAAAAAA001","original_url":"https://ozon.ru/` + strings.Repeat("a", 17) + `"}`
+	invalid := map[int]error{}
+	reader, err := NewReader(strings.NewReader(input), FormatJSONL)
+	require.NoError(t, err)
+	progress, err := Import(ctx, repo, reader, ImportOptions{
+		MaxOriginalLength: 32,
+		OnInvalid:         func(number int, err error) { invalid[number] = err },
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, Progress{Records: 1, Invalid: 1}, progress)
+	require.ErrorIs(t, invalid[1], domain.ErrInvalidOriginal)
+}
+
+// failingRepo fails creation of links after the limit.
+type failingRepo struct {
+	repository.URL
+	limit int
+}
+
+func (r *failingRepo) CreateOrGetShortenedURL(ctx context.Context, link domain.Link) (domain.Link, error) {
+	if r.limit == 0 {
+		return domain.Link{}, fmt.Errorf("connection refused: %w", domain.ErrStorageUnavailable)
+	}
+	r.limit--
+	return r.URL.CreateOrGetShortenedURL(ctx, link)
+}
+
+func TestImport_ResumesFromCheckpoint(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	source := newRepo()
+	seed(t, source, 10)
+
+	var buf bytes.Buffer
+	_, err := Export(ctx, source, domain.LinkFilter{}, NewWriter(&buf, FormatCSV))
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "import.checkpoint")
+	save := func(progress Progress) error { return SaveCheckpoint(path, "links.csv", progress) }
+	target := &failingRepo{URL: newRepo(), limit: 7}
+
+	reader, err := NewReader(bytes.NewReader(buf.Bytes()), FormatCSV)
+	require.NoError(t, err)
+	progress, err := Import(ctx, target, reader, ImportOptions{Checkpoint: save, CheckpointEvery: 3})
+	require.ErrorIs(t, err, domain.ErrStorageUnavailable)
+	require.Equal(t, Progress{Records: 7, Imported: 7}, progress)
+
+	// the checkpoint of the 6th record is saved, the 7th one is imported again as existing
+	resume, err := LoadCheckpoint(path, "links.csv")
+	require.NoError(t, err)
+	require.Equal(t, Progress{Records: 6, Imported: 6}, resume)
+	_, err = LoadCheckpoint(path, "other.csv")
+	require.Error(t, err)
+
+	target.limit = -1
+	reader, err = NewReader(bytes.NewReader(buf.Bytes()), FormatCSV)
+	require.NoError(t, err)
+	progress, err = Import(ctx, target, reader, ImportOptions{Resume: resume, Checkpoint: save, CheckpointEvery: 3})
+	require.NoError(t, err)
+	require.Equal(t, Progress{Records: 10, Imported: 9, Existing: 1}, progress)
+
+	resume, err = LoadCheckpoint(path, "links.csv")
+	require.NoError(t, err)
+	require.Equal(t, progress, resume)
+}
+
+func TestNewReader_CSVHeader(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewReader(strings.NewReader("original_url,owner_id\nhttps://ozon.ru,alice\n"), FormatCSV)
+	require.ErrorIs(t, err, domain.ErrInvalidRequest)
+
+	// columns are matched by name, unknown ones are ignored
+	reader, err := NewReader(strings.NewReader("note,original_url,shortened_url\nx,https://ozon.ru,AAAAAAAAAA\n"),
+		FormatCSV)
+	require.NoError(t, err)
+	record, err := reader.Read()
+	require.NoError(t, err)
+	require.Equal(t, Record{Shortened: "AAAAAAAAAA", Original: "https://ozon.ru"}, record)
+
+	// exports with version, clicks and created_at are read as well
+	reader, err = NewReader(strings.NewReader(
+		"shortened_url,original_url,owner_id,tags,version,clicks,created_at,expires_at\n"+
+			"AAAAAAAAAA,https://ozon.ru,alice,,2,17,2025-01-10T12:00:00Z,2030-01-10T12:00:00Z\n"), FormatCSV)
+	require.NoError(t, err)
+	record, err = reader.Read()
+	require.NoError(t, err)
+	expiresAt := time.Date(2030, 1, 10, 12, 0, 0, 0, time.UTC)
+	require.Equal(t, Record{Shortened: "AAAAAAAAAA", Original: "https://ozon.ru", OwnerID: "alice", ExpiresAt: &expiresAt},
+		record)
+
+	reader, err = NewReader(strings.NewReader(""), FormatCSV)
+	require.NoError(t, err)
+	_, err = reader.Read()
+	require.ErrorIs(t, err, io.EOF)
+}
@@ -0,0 +1,26 @@
+package usecases
+
+import (
+	"context"
+	"ozon_task/domain"
+)
+
+// Auth defines the interface for the service layer of api keys and authentication.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.50 --name=Auth --filename=auth_service_mock.go
+type Auth interface {
+	// Authenticate resolves principal by the raw bearer token.
+	// Returns `domain.ErrUnauthenticated` if token is unknown or revoked.
+	Authenticate(ctx context.Context, token string) (domain.Principal, error)
+
+	// IssueKey creates new api key for the owner.
+	// Returns stored key and its raw value, which is shown only once.
+	IssueKey(ctx context.Context, owner domain.OwnerID, name string, admin bool) (domain.APIKey, string, error)
+
+	// ListKeys returns keys of the owner, or all keys if owner is empty.
+	ListKeys(ctx context.Context, owner domain.OwnerID) ([]domain.APIKey, error)
+
+	// RevokeKey revokes api key by its id.
+	// Returns `domain.ErrAPIKeyNotFound` if key does not exist.
+	RevokeKey(ctx context.Context, id string) (domain.APIKey, error)
+}
@@ -4,6 +4,7 @@ package mocks
 
 import (
 	context "context"
+	domain "ozon_task/domain"
 
 	mock "github.com/stretchr/testify/mock"
 )
@@ -13,27 +14,91 @@ type URL struct {
 	mock.Mock
 }
 
-// ResolveURL provides a mock function with given fields: ctx, shorted
-func (_m *URL) ResolveURL(ctx context.Context, shorted string) (string, error) {
-	ret := _m.Called(ctx, shorted)
+// CreateLink provides a mock function with given fields: ctx, spec
+func (_m *URL) CreateLink(ctx context.Context, spec domain.Link) (domain.Link, error) {
+	ret := _m.Called(ctx, spec)
 
 	if len(ret) == 0 {
-		panic("no return value specified for ResolveURL")
+		panic("no return value specified for CreateLink")
 	}
 
-	var r0 string
+	var r0 domain.Link
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) (string, error)); ok {
-		return rf(ctx, shorted)
+	if rf, ok := ret.Get(0).(func(context.Context, domain.Link) (domain.Link, error)); ok {
+		return rf(ctx, spec)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
-		r0 = rf(ctx, shorted)
+	if rf, ok := ret.Get(0).(func(context.Context, domain.Link) domain.Link); ok {
+		r0 = rf(ctx, spec)
 	} else {
-		r0 = ret.Get(0).(string)
+		r0 = ret.Get(0).(domain.Link)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.Link) error); ok {
+		r1 = rf(ctx, spec)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteLink provides a mock function with given fields: ctx, shortened
+func (_m *URL) DeleteLink(ctx context.Context, shortened string) error {
+	ret := _m.Called(ctx, shortened)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteLink")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, shortened)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ExportLinks provides a mock function with given fields: ctx, filter, yield
+func (_m *URL) ExportLinks(ctx context.Context, filter domain.LinkFilter, yield func(domain.Link) error) error {
+	ret := _m.Called(ctx, filter, yield)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ExportLinks")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.LinkFilter, func(domain.Link) error) error); ok {
+		r0 = rf(ctx, filter, yield)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetLink provides a mock function with given fields: ctx, shortened
+func (_m *URL) GetLink(ctx context.Context, shortened string) (domain.Link, error) {
+	ret := _m.Called(ctx, shortened)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetLink")
+	}
+
+	var r0 domain.Link
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (domain.Link, error)); ok {
+		return rf(ctx, shortened)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) domain.Link); ok {
+		r0 = rf(ctx, shortened)
+	} else {
+		r0 = ret.Get(0).(domain.Link)
 	}
 
 	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, shorted)
+		r1 = rf(ctx, shortened)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -41,27 +106,141 @@ func (_m *URL) ResolveURL(ctx context.Context, shorted string) (string, error) {
 	return r0, r1
 }
 
-// ShortenURL provides a mock function with given fields: ctx, original
-func (_m *URL) ShortenURL(ctx context.Context, original string) (string, error) {
-	ret := _m.Called(ctx, original)
+// ListLinkVersions provides a mock function with given fields: ctx, shortened
+func (_m *URL) ListLinkVersions(ctx context.Context, shortened string) ([]domain.LinkVersion, error) {
+	ret := _m.Called(ctx, shortened)
 
 	if len(ret) == 0 {
-		panic("no return value specified for ShortenURL")
+		panic("no return value specified for ListLinkVersions")
+	}
+
+	var r0 []domain.LinkVersion
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]domain.LinkVersion, error)); ok {
+		return rf(ctx, shortened)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []domain.LinkVersion); ok {
+		r0 = rf(ctx, shortened)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.LinkVersion)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, shortened)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListLinks provides a mock function with given fields: ctx, filter, cursor, limit
+func (_m *URL) ListLinks(ctx context.Context, filter domain.LinkFilter, cursor string, limit int) (domain.LinkPage, error) {
+	ret := _m.Called(ctx, filter, cursor, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListLinks")
+	}
+
+	var r0 domain.LinkPage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.LinkFilter, string, int) (domain.LinkPage, error)); ok {
+		return rf(ctx, filter, cursor, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.LinkFilter, string, int) domain.LinkPage); ok {
+		r0 = rf(ctx, filter, cursor, limit)
+	} else {
+		r0 = ret.Get(0).(domain.LinkPage)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.LinkFilter, string, int) error); ok {
+		r1 = rf(ctx, filter, cursor, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ResolveURL provides a mock function with given fields: ctx, shortened
+func (_m *URL) ResolveURL(ctx context.Context, shortened string) (string, error) {
+	ret := _m.Called(ctx, shortened)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ResolveURL")
 	}
 
 	var r0 string
 	var r1 error
 	if rf, ok := ret.Get(0).(func(context.Context, string) (string, error)); ok {
-		return rf(ctx, original)
+		return rf(ctx, shortened)
 	}
 	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
-		r0 = rf(ctx, original)
+		r0 = rf(ctx, shortened)
 	} else {
 		r0 = ret.Get(0).(string)
 	}
 
 	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, original)
+		r1 = rf(ctx, shortened)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RollbackLink provides a mock function with given fields: ctx, shortened, version
+func (_m *URL) RollbackLink(ctx context.Context, shortened string, version int) (domain.Link, error) {
+	ret := _m.Called(ctx, shortened, version)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RollbackLink")
+	}
+
+	var r0 domain.Link
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) (domain.Link, error)); ok {
+		return rf(ctx, shortened, version)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) domain.Link); ok {
+		r0 = rf(ctx, shortened, version)
+	} else {
+		r0 = ret.Get(0).(domain.Link)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, int) error); ok {
+		r1 = rf(ctx, shortened, version)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateLink provides a mock function with given fields: ctx, shortened, update
+func (_m *URL) UpdateLink(ctx context.Context, shortened string, update domain.LinkUpdate) (domain.Link, error) {
+	ret := _m.Called(ctx, shortened, update)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateLink")
+	}
+
+	var r0 domain.Link
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, domain.LinkUpdate) (domain.Link, error)); ok {
+		return rf(ctx, shortened, update)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, domain.LinkUpdate) domain.Link); ok {
+		r0 = rf(ctx, shortened, update)
+	} else {
+		r0 = ret.Get(0).(domain.Link)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, domain.LinkUpdate) error); ok {
+		r1 = rf(ctx, shortened, update)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"ozon_task/domain"
+	"ozon_task/internal/repository"
+	pkgrandom "ozon_task/pkg/random"
+	"strings"
+	"time"
+)
+
+// AdminPrincipalID identifies principal authenticated by static admin token.
+const AdminPrincipalID = "admin"
+
+type AuthService struct {
+	repo       repository.APIKey
+	adminToken string
+}
+
+// NewAuthService creates auth service. Static admin token is optional and allows
+// bootstrapping the first keys.
+func NewAuthService(repo repository.APIKey, adminToken string) *AuthService {
+	return &AuthService{
+		repo:       repo,
+		adminToken: adminToken,
+	}
+}
+
+func hashKey(raw string) []byte {
+	sum := sha256.Sum256([]byte(raw))
+	return sum[:]
+}
+
+func (s *AuthService) Authenticate(ctx context.Context, token string) (domain.Principal, error) {
+	if len(token) == 0 {
+		return domain.Principal{}, fmt.Errorf("Authenticate: empty token: %w", domain.ErrUnauthenticated)
+	}
+
+	if len(s.adminToken) != 0 && subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) == 1 {
+		return domain.Principal{
+			ID:      AdminPrincipalID,
+			OwnerID: AdminPrincipalID,
+			Scopes:  []domain.Scope{domain.ScopeAdmin},
+		}, nil
+	}
+
+	if !strings.Contains(token, domain.APIKeySeparator) {
+		return domain.Principal{}, fmt.Errorf("Authenticate: malformed api key: %w", domain.ErrUnauthenticated)
+	}
+
+	key, err := s.repo.GetAPIKeyByHash(ctx, hashKey(token))
+	if errors.Is(err, domain.ErrAPIKeyNotFound) {
+		return domain.Principal{}, fmt.Errorf("Authenticate: unknown api key: %w", domain.ErrUnauthenticated)
+	} else if err != nil {
+		return domain.Principal{}, fmt.Errorf("Authenticate: failed to get api key: %w", err)
+	}
+
+	if key.IsRevoked() {
+		return domain.Principal{}, fmt.Errorf("Authenticate: api key %q is revoked: %w", key.ID, domain.ErrUnauthenticated)
+	}
+
+	return domain.Principal{ID: key.ID, OwnerID: key.OwnerID, Scopes: keyScopes(key)}, nil
+}
+
+func keyScopes(key domain.APIKey) []domain.Scope {
+	if key.Admin {
+		return []domain.Scope{domain.ScopeAdmin}
+	}
+	return []domain.Scope{domain.ScopeLinksRead, domain.ScopeLinksWrite}
+}
+
+func (s *AuthService) IssueKey(
+	ctx context.Context,
+	owner domain.OwnerID,
+	name string,
+	admin bool,
+) (domain.APIKey, string, error) {
+	if len(owner) == 0 {
+		return domain.APIKey{}, "", fmt.Errorf("IssueKey: empty owner: %w", domain.ErrInvalidAPIKey)
+	}
+
+	id, err := pkgrandom.NewRandomString(domain.APIKeyIDSize, domain.AllowedSymbols)
+	if err != nil {
+		return domain.APIKey{}, "", fmt.Errorf("IssueKey: failed to generate key id: %w", err)
+	}
+
+	secret, err := pkgrandom.NewRandomString(domain.APIKeySecretSize, domain.AllowedSymbols)
+	if err != nil {
+		return domain.APIKey{}, "", fmt.Errorf("IssueKey: failed to generate key secret: %w", err)
+	}
+
+	raw := id + domain.APIKeySeparator + secret
+	key := domain.APIKey{
+		ID:        id,
+		OwnerID:   owner,
+		Name:      name,
+		Hash:      hashKey(raw),
+		Admin:     admin,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if err = s.repo.CreateAPIKey(ctx, key); err != nil {
+		return domain.APIKey{}, "", fmt.Errorf("IssueKey: failed to store api key for %q: %w", owner, err)
+	}
+
+	return key, raw, nil
+}
+
+func (s *AuthService) ListKeys(ctx context.Context, owner domain.OwnerID) ([]domain.APIKey, error) {
+	keys, err := s.repo.ListAPIKeys(ctx, owner)
+	if err != nil {
+		return nil, fmt.Errorf("ListKeys: failed to list api keys of %q: %w", owner, err)
+	}
+
+	return keys, nil
+}
+
+func (s *AuthService) RevokeKey(ctx context.Context, id string) (domain.APIKey, error) {
+	key, err := s.repo.RevokeAPIKey(ctx, id)
+	if err != nil {
+		return domain.APIKey{}, fmt.Errorf("RevokeKey: failed to revoke api key %q: %w", id, err)
+	}
+
+	return key, nil
+}
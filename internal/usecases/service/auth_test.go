@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"ozon_task/domain"
+	"ozon_task/internal/repository/mocks"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssueKey_Success(t *testing.T) {
+	t.Parallel()
+	mockRepo := new(mocks.APIKey)
+	svc := NewAuthService(mockRepo, "")
+
+	mockRepo.On("CreateAPIKey", mock.Anything, mock.MatchedBy(func(key domain.APIKey) bool {
+		return key.OwnerID == "team" && len(key.ID) == domain.APIKeyIDSize && len(key.Hash) != 0
+	})).Return(nil)
+
+	key, raw, err := svc.IssueKey(context.Background(), "team", "ci", false)
+
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(raw, key.ID+domain.APIKeySeparator))
+	require.Equal(t, hashKey(raw), key.Hash)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestIssueKey_EmptyOwner(t *testing.T) {
+	t.Parallel()
+	mockRepo := new(mocks.APIKey)
+	svc := NewAuthService(mockRepo, "")
+
+	_, _, err := svc.IssueKey(context.Background(), "", "ci", false)
+
+	require.ErrorIs(t, err, domain.ErrInvalidAPIKey)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAuthenticate_ValidKey(t *testing.T) {
+	t.Parallel()
+	mockRepo := new(mocks.APIKey)
+	svc := NewAuthService(mockRepo, "")
+
+	const raw = "abcdefghij.secret"
+	mockRepo.On("GetAPIKeyByHash", mock.Anything, hashKey(raw)).
+		Return(domain.APIKey{ID: "abcdefghij", OwnerID: "team"}, nil)
+
+	principal, err := svc.Authenticate(context.Background(), raw)
+
+	require.NoError(t, err)
+	require.Equal(t, "team", principal.OwnerID)
+	require.True(t, principal.HasScope(domain.ScopeLinksWrite))
+	require.False(t, principal.HasScope(domain.ScopeAdmin))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAuthenticate_RevokedKey(t *testing.T) {
+	t.Parallel()
+	mockRepo := new(mocks.APIKey)
+	svc := NewAuthService(mockRepo, "")
+
+	const raw = "abcdefghij.secret"
+	revokedAt := time.Now()
+	mockRepo.On("GetAPIKeyByHash", mock.Anything, hashKey(raw)).
+		Return(domain.APIKey{ID: "abcdefghij", OwnerID: "team", RevokedAt: &revokedAt}, nil)
+
+	_, err := svc.Authenticate(context.Background(), raw)
+
+	require.ErrorIs(t, err, domain.ErrUnauthenticated)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAuthenticate_UnknownKey(t *testing.T) {
+	t.Parallel()
+	mockRepo := new(mocks.APIKey)
+	svc := NewAuthService(mockRepo, "")
+
+	mockRepo.On("GetAPIKeyByHash", mock.Anything, mock.Anything).
+		Return(domain.APIKey{}, domain.ErrAPIKeyNotFound)
+
+	_, err := svc.Authenticate(context.Background(), "abcdefghij.secret")
+
+	require.ErrorIs(t, err, domain.ErrUnauthenticated)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAuthenticate_AdminToken(t *testing.T) {
+	t.Parallel()
+	mockRepo := new(mocks.APIKey)
+	svc := NewAuthService(mockRepo, "bootstrap")
+
+	principal, err := svc.Authenticate(context.Background(), "bootstrap")
+
+	require.NoError(t, err)
+	require.True(t, principal.HasScope(domain.ScopeAdmin))
+	mockRepo.AssertExpectations(t)
+}
@@ -2,11 +2,17 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"ozon_task/domain"
+	"ozon_task/internal/auth"
 	"ozon_task/internal/repository"
+	"ozon_task/internal/usecases"
 	pkgrandom "ozon_task/pkg/random"
+	"slices"
+	"strconv"
+	"time"
 )
 
 type URLService struct {
@@ -33,34 +39,88 @@ func (s *URLService) generateShortURL(ctx context.Context) (domain.ShortURL, err
 			}
 
 			_, err = s.repo.GetOriginalURLByShortened(ctx, newURL)
-			if !errors.Is(err, domain.ErrOriginalNotFound) {
+			switch {
+			case errors.Is(err, domain.ErrOriginalNotFound):
+				return newURL, nil
+			case err == nil, errors.Is(err, domain.ErrLinkExpired):
+				// the shortened url is taken
 				continue
+			default:
+				return "", fmt.Errorf("generateShortURL: failed to check shortened URL %q: %w", newURL, err)
 			}
-
-			return newURL, nil
 		}
 	}
 }
 
-func (s *URLService) ShortenURL(ctx context.Context, original domain.URL) (domain.ShortURL, error) {
-	shortened, err := s.repo.GetShortenedURLByOriginal(ctx, original)
-	if err == nil {
-		return shortened, nil
-	} else if ok := errors.Is(err, domain.ErrShortenedNotFound); !ok {
-		return "", fmt.Errorf("ShortenURL: failed to check for existing shortened URL for %q: %w", original, err)
+func (s *URLService) CreateLink(ctx context.Context, spec domain.Link) (domain.Link, error) {
+	if err := domain.ValidateTags(spec.Tags); err != nil {
+		return domain.Link{}, fmt.Errorf("CreateLink: %w", err)
+	}
+	if err := domain.ValidateExpiration(spec.ExpiresAt, time.Now()); err != nil {
+		return domain.Link{}, fmt.Errorf("CreateLink: %w", err)
+	}
+
+	// links with expiration are never deduplicated
+	if spec.ExpiresAt.IsZero() {
+		shortened, err := s.repo.GetShortenedURLByOriginal(ctx, auth.OwnerFromContext(ctx), spec.Original)
+		if err == nil {
+			return s.existingLink(ctx, shortened)
+		} else if ok := errors.Is(err, domain.ErrShortenedNotFound); !ok {
+			return domain.Link{}, fmt.Errorf("CreateLink: failed to check for existing shortened URL for %q: %w",
+				spec.Original, err)
+		}
+	}
+
+	var err error
+	for range createAttempts {
+		var link domain.Link
+		link, err = s.createLink(ctx, spec)
+		if !errors.Is(err, domain.ErrConflict) {
+			return link, err
+		}
 	}
 
+	return domain.Link{}, err
+}
+
+// createAttempts is how many shortened urls are generated for a link before giving up on conflicts,
+// which happen when a concurrent creation takes the same url.
+const createAttempts = 3
+
+// createLink creates the link with a newly generated shortened url.
+func (s *URLService) createLink(ctx context.Context, spec domain.Link) (domain.Link, error) {
 	newURL, err := s.generateShortURL(ctx)
 	if err != nil {
-		return "", fmt.Errorf("ShortenURL: %w", err)
+		return domain.Link{}, fmt.Errorf("CreateLink: %w", err)
+	}
+
+	link, err := s.repo.CreateOrGetShortenedURL(ctx, domain.Link{
+		Original:  spec.Original,
+		Shortened: newURL,
+		OwnerID:   auth.OwnerFromContext(ctx),
+		Tags:      spec.Tags,
+		ExpiresAt: spec.ExpiresAt,
+	})
+	if err != nil {
+		return domain.Link{}, fmt.Errorf("CreateLink: failed to put new shortened URL %q for original %q: %w",
+			newURL, spec.Original, err)
+	}
+	// the link deduplicated by a concurrent creation is returned with its own shortened url
+	if link.Shortened == newURL {
+		usecases.LinkCreated(ctx)
 	}
 
-	newURL, err = s.repo.CreateOrGetShortenedURL(ctx, original, newURL)
+	return link, nil
+}
+
+// existingLink returns the deduplicated link found by original URL.
+func (s *URLService) existingLink(ctx context.Context, shortened domain.ShortURL) (domain.Link, error) {
+	link, err := s.repo.GetLink(ctx, shortened)
 	if err != nil {
-		return "", fmt.Errorf("ShortenURL: failed to put new shortened URL %q for original %q: %w", newURL, original, err)
+		return domain.Link{}, fmt.Errorf("existingLink: failed to get link %q: %w", shortened, err)
 	}
 
-	return newURL, nil
+	return link, nil
 }
 
 func (s *URLService) ResolveURL(ctx context.Context, shortened domain.ShortURL) (domain.URL, error) {
@@ -69,5 +129,236 @@ func (s *URLService) ResolveURL(ctx context.Context, shortened domain.ShortURL)
 		return "", fmt.Errorf("ResolveURL: failed to resolve original URL for shortened %q: %w", shortened, err)
 	}
 
+	// clicks are counted on best effort basis, resolving doesn't fail because of them
+	_ = s.repo.RecordClick(ctx, shortened)
+
 	return original, nil
 }
+
+func (s *URLService) GetLink(ctx context.Context, shortened domain.ShortURL) (domain.Link, error) {
+	link, err := s.ownedLink(ctx, shortened)
+	if err != nil {
+		return domain.Link{}, fmt.Errorf("GetLink: %w", err)
+	}
+
+	return link, nil
+}
+
+func (s *URLService) DeleteLink(ctx context.Context, shortened domain.ShortURL) error {
+	if _, err := s.ownedLink(ctx, shortened); err != nil {
+		return fmt.Errorf("DeleteLink: %w", err)
+	}
+
+	if err := s.repo.DeleteLink(ctx, shortened); err != nil {
+		return fmt.Errorf("DeleteLink: failed to delete link %q: %w", shortened, err)
+	}
+
+	return nil
+}
+
+func (s *URLService) ListLinks(
+	ctx context.Context,
+	filter domain.LinkFilter,
+	cursor string,
+	limit int,
+) (domain.LinkPage, error) {
+	switch {
+	case limit == 0:
+		limit = domain.DefaultPageSize
+	case limit < 0 || limit > domain.MaxPageSize:
+		return domain.LinkPage{}, fmt.Errorf("ListLinks: limit must be in [1, %d]: %w",
+			domain.MaxPageSize, domain.ErrInvalidFilter)
+	}
+
+	after, before := filter.CreatedAfter, filter.CreatedBefore
+	if !after.IsZero() && !before.IsZero() && !after.Before(before) {
+		return domain.LinkPage{}, fmt.Errorf("ListLinks: empty time range: %w", domain.ErrInvalidFilter)
+	}
+
+	if principal, ok := auth.PrincipalFromContext(ctx); ok && !principal.HasScope(domain.ScopeAdmin) {
+		if len(filter.OwnerID) != 0 && filter.OwnerID != principal.OwnerID {
+			return domain.LinkPage{}, fmt.Errorf("ListLinks: links of %q: %w", filter.OwnerID, domain.ErrPermissionDenied)
+		}
+		filter.OwnerID = principal.OwnerID
+	}
+
+	afterID, err := decodeCursor(cursor)
+	if err != nil {
+		return domain.LinkPage{}, fmt.Errorf("ListLinks: %w", err)
+	}
+
+	// one extra link tells whether there is a next page
+	links, err := s.repo.ListLinks(ctx, filter, domain.Page{AfterID: afterID, Limit: limit + 1})
+	if err != nil {
+		return domain.LinkPage{}, fmt.Errorf("ListLinks: failed to list links: %w", err)
+	}
+
+	page := domain.LinkPage{Links: links}
+	if len(links) > limit {
+		page.Links = links[:limit]
+		page.NextCursor = encodeCursor(page.Links[limit-1].ID)
+	}
+
+	return page, nil
+}
+
+func (s *URLService) ExportLinks(
+	ctx context.Context,
+	filter domain.LinkFilter,
+	yield func(domain.Link) error,
+) error {
+	var cursor string
+	for {
+		page, err := s.ListLinks(ctx, filter, cursor, domain.MaxPageSize)
+		if err != nil {
+			return fmt.Errorf("ExportLinks: %w", err)
+		}
+
+		for _, link := range page.Links {
+			if err = yield(link); err != nil {
+				return fmt.Errorf("ExportLinks: %w", err)
+			}
+		}
+
+		if len(page.NextCursor) == 0 {
+			return nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+func (s *URLService) UpdateLink(
+	ctx context.Context,
+	shortened domain.ShortURL,
+	update domain.LinkUpdate,
+) (domain.Link, error) {
+	if update.Tags != nil {
+		if err := domain.ValidateTags(*update.Tags); err != nil {
+			return domain.Link{}, fmt.Errorf("UpdateLink: %w", err)
+		}
+	}
+	if update.ExpiresAt != nil {
+		if err := domain.ValidateExpiration(*update.ExpiresAt, time.Now()); err != nil {
+			return domain.Link{}, fmt.Errorf("UpdateLink: %w", err)
+		}
+	}
+
+	link, err := s.ownedLink(ctx, shortened)
+	if err != nil {
+		return domain.Link{}, fmt.Errorf("UpdateLink: %w", err)
+	}
+
+	link, err = s.updateLink(ctx, link, update)
+	if err != nil {
+		return domain.Link{}, fmt.Errorf("UpdateLink: %w", err)
+	}
+
+	return link, nil
+}
+
+func (s *URLService) ListLinkVersions(
+	ctx context.Context,
+	shortened domain.ShortURL,
+) ([]domain.LinkVersion, error) {
+	if _, err := s.ownedLink(ctx, shortened); err != nil {
+		return nil, fmt.Errorf("ListLinkVersions: %w", err)
+	}
+
+	versions, err := s.repo.ListLinkVersions(ctx, shortened)
+	if err != nil {
+		return nil, fmt.Errorf("ListLinkVersions: failed to list versions of %q: %w", shortened, err)
+	}
+
+	return versions, nil
+}
+
+func (s *URLService) RollbackLink(
+	ctx context.Context,
+	shortened domain.ShortURL,
+	version int,
+) (domain.Link, error) {
+	if version < 1 {
+		return domain.Link{}, fmt.Errorf("RollbackLink: version must be positive: %w", domain.ErrInvalidVersion)
+	}
+
+	link, err := s.ownedLink(ctx, shortened)
+	if err != nil {
+		return domain.Link{}, fmt.Errorf("RollbackLink: %w", err)
+	}
+
+	versions, err := s.repo.ListLinkVersions(ctx, shortened)
+	if err != nil {
+		return domain.Link{}, fmt.Errorf("RollbackLink: failed to list versions of %q: %w", shortened, err)
+	}
+
+	idx := slices.IndexFunc(versions, func(v domain.LinkVersion) bool { return v.Version == version })
+	if idx < 0 {
+		return domain.Link{}, fmt.Errorf("RollbackLink: version %d of %q: %w", version, shortened, domain.ErrVersionNotFound)
+	}
+
+	link, err = s.updateLink(ctx, link, domain.LinkUpdate{Original: &versions[idx].Original})
+	if err != nil {
+		return domain.Link{}, fmt.Errorf("RollbackLink: %w", err)
+	}
+
+	return link, nil
+}
+
+// ownedLink returns the link if the principal from the context may change it.
+// Links without owner can be changed only by admins.
+func (s *URLService) ownedLink(ctx context.Context, shortened domain.ShortURL) (domain.Link, error) {
+	link, err := s.repo.GetLink(ctx, shortened)
+	if err != nil {
+		return domain.Link{}, fmt.Errorf("ownedLink: failed to get link %q: %w", shortened, err)
+	}
+
+	if principal, ok := auth.PrincipalFromContext(ctx); ok && !principal.HasScope(domain.ScopeAdmin) {
+		if len(link.OwnerID) == 0 || link.OwnerID != principal.OwnerID {
+			return domain.Link{}, fmt.Errorf("ownedLink: link %q: %w", shortened, domain.ErrPermissionDenied)
+		}
+	}
+
+	return link, nil
+}
+
+// updateLink applies the update to the link, the same destination doesn't create a version.
+func (s *URLService) updateLink(ctx context.Context, link domain.Link, update domain.LinkUpdate) (domain.Link, error) {
+	if update.Original != nil && *update.Original == link.Original {
+		update.Original = nil
+	}
+	if update.Empty() {
+		return link, nil
+	}
+
+	principal, _ := auth.PrincipalFromContext(ctx)
+
+	updated, err := s.repo.UpdateLink(ctx, link.Shortened, update, principal.ID)
+	if err != nil {
+		return domain.Link{}, fmt.Errorf("updateLink: failed to update link %q: %w", link.Shortened, err)
+	}
+
+	return updated, nil
+}
+
+// encodeCursor makes opaque cursor from the id of the last link of the page.
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodeCursor(cursor string) (int64, error) {
+	if len(cursor) == 0 {
+		return 0, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("decodeCursor: %w", domain.ErrInvalidCursor)
+	}
+
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("decodeCursor: %w", domain.ErrInvalidCursor)
+	}
+
+	return id, nil
+}
@@ -3,16 +3,19 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/stretchr/testify/require"
 	"ozon_task/domain"
+	"ozon_task/internal/auth"
 	"ozon_task/internal/repository/mocks"
+	"ozon_task/internal/usecases"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/mock"
 )
 
-func TestShortenURL_NewURL(t *testing.T) {
+func TestCreateLink_NewURL(t *testing.T) {
 	t.Parallel()
 	mockRepo := new(mocks.URL)
 	svc := NewURLService(mockRepo)
@@ -20,21 +23,21 @@ func TestShortenURL_NewURL(t *testing.T) {
 	ctx := context.Background()
 	originalURL := "https://finance.ozon.ru"
 
-	mockRepo.On("GetShortenedURLByOriginal", mock.Anything, originalURL).
+	mockRepo.On("GetShortenedURLByOriginal", mock.Anything, mock.Anything, originalURL).
 		Return("", domain.ErrShortenedNotFound)
 	mockRepo.On("GetOriginalURLByShortened", mock.Anything, mock.Anything).
 		Return("", domain.ErrOriginalNotFound)
-	mockRepo.On("CreateOrGetShortenedURL", mock.Anything, originalURL, mock.Anything).
-		Return(mock.Anything, nil)
+	mockRepo.On("CreateOrGetShortenedURL", mock.Anything, linkWithOriginal(originalURL)).
+		Return(domain.Link{Original: originalURL}, nil)
 
-	_, err := svc.ShortenURL(ctx, originalURL)
+	_, err := svc.CreateLink(ctx, domain.Link{Original: originalURL})
 
 	require.NoError(t, err)
 
 	mockRepo.AssertExpectations(t)
 }
 
-func TestShortenURL_ExistedURL(t *testing.T) {
+func TestCreateLink_ExistedURL(t *testing.T) {
 	t.Parallel()
 	mockRepo := new(mocks.URL)
 	svc := NewURLService(mockRepo)
@@ -43,18 +46,49 @@ func TestShortenURL_ExistedURL(t *testing.T) {
 	originalURL := "https://finance.ozon.ru"
 	shortenedURL := "abc123"
 
-	mockRepo.On("GetShortenedURLByOriginal", mock.Anything, originalURL).
+	mockRepo.On("GetShortenedURLByOriginal", mock.Anything, mock.Anything, originalURL).
 		Return(shortenedURL, nil)
+	mockRepo.On("GetLink", mock.Anything, shortenedURL).
+		Return(domain.Link{Original: originalURL, Shortened: shortenedURL}, nil)
 
-	result, err := svc.ShortenURL(ctx, originalURL)
+	result, err := svc.CreateLink(ctx, domain.Link{Original: originalURL})
 
 	require.NoError(t, err)
-	require.Equal(t, shortenedURL, result)
+	require.Equal(t, shortenedURL, result.Shortened)
 
 	mockRepo.AssertExpectations(t)
 }
 
-func TestShortenURL_RetryOnCollision(t *testing.T) {
+func TestCreateLink_LinkCreatedHook(t *testing.T) {
+	t.Parallel()
+	mockRepo := new(mocks.URL)
+	svc := NewURLService(mockRepo)
+
+	var created int
+	ctx := usecases.WithLinkCreatedHook(context.Background(), func(context.Context) { created++ })
+
+	mockRepo.On("GetShortenedURLByOriginal", mock.Anything, mock.Anything, mock.Anything).
+		Return("", domain.ErrShortenedNotFound)
+	mockRepo.On("GetOriginalURLByShortened", mock.Anything, mock.Anything).
+		Return("", domain.ErrOriginalNotFound)
+	mockRepo.On("CreateOrGetShortenedURL", mock.Anything, linkWithOriginal("https://ozon.ru")).
+		Return(func(_ context.Context, link domain.Link) (domain.Link, error) { return link, nil })
+	// the concurrent creation of the same original stored its own link first
+	mockRepo.On("CreateOrGetShortenedURL", mock.Anything, linkWithOriginal("https://finance.ozon.ru")).
+		Return(domain.Link{Original: "https://finance.ozon.ru", Shortened: "abc123"}, nil)
+
+	_, err := svc.CreateLink(ctx, domain.Link{Original: "https://ozon.ru"})
+	require.NoError(t, err)
+	require.Equal(t, 1, created)
+
+	_, err = svc.CreateLink(ctx, domain.Link{Original: "https://finance.ozon.ru"})
+	require.NoError(t, err)
+	require.Equal(t, 1, created, "deduplicated link isn't counted")
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateLink_RetryOnCollision(t *testing.T) {
 	t.Parallel()
 	mockRepo := new(mocks.URL)
 	svc := NewURLService(mockRepo)
@@ -62,23 +96,71 @@ func TestShortenURL_RetryOnCollision(t *testing.T) {
 	ctx := context.Background()
 	originalURL := "https://finance.ozon.ru"
 
-	mockRepo.On("GetShortenedURLByOriginal", mock.Anything, originalURL).
+	mockRepo.On("GetShortenedURLByOriginal", mock.Anything, mock.Anything, originalURL).
 		Return("", domain.ErrShortenedNotFound)
 	mockRepo.On("GetOriginalURLByShortened", mock.Anything, mock.Anything).
 		Return("https://ozon.ru", nil).Once()
 	mockRepo.On("GetOriginalURLByShortened", mock.Anything, mock.Anything).
 		Return("", domain.ErrOriginalNotFound)
-	mockRepo.On("CreateOrGetShortenedURL", mock.Anything, originalURL, mock.Anything).
-		Return(mock.Anything, nil)
+	mockRepo.On("CreateOrGetShortenedURL", mock.Anything, linkWithOriginal(originalURL)).
+		Return(domain.Link{Original: originalURL}, nil)
+
+	_, err := svc.CreateLink(ctx, domain.Link{Original: originalURL})
+
+	require.NoError(t, err)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateLink_RegeneratesOnConflict(t *testing.T) {
+	t.Parallel()
+	mockRepo := new(mocks.URL)
+	svc := NewURLService(mockRepo)
+
+	ctx := context.Background()
+	originalURL := "https://finance.ozon.ru"
+
+	mockRepo.On("GetShortenedURLByOriginal", mock.Anything, mock.Anything, originalURL).
+		Return("", domain.ErrShortenedNotFound)
+	mockRepo.On("GetOriginalURLByShortened", mock.Anything, mock.Anything).
+		Return("", domain.ErrOriginalNotFound)
+	// a concurrent creation takes the generated url
+	mockRepo.On("CreateOrGetShortenedURL", mock.Anything, linkWithOriginal(originalURL)).
+		Return(domain.Link{}, fmt.Errorf("CreateOrGetShortenedURL: %w", domain.ErrConflict)).Once()
+	mockRepo.On("CreateOrGetShortenedURL", mock.Anything, linkWithOriginal(originalURL)).
+		Return(domain.Link{Original: originalURL}, nil).Once()
 
-	_, err := svc.ShortenURL(ctx, originalURL)
+	_, err := svc.CreateLink(ctx, domain.Link{Original: originalURL})
 
 	require.NoError(t, err)
 
 	mockRepo.AssertExpectations(t)
 }
 
-func TestShortenURL_ContextTimeout(t *testing.T) {
+func TestCreateLink_PersistentConflict(t *testing.T) {
+	t.Parallel()
+	mockRepo := new(mocks.URL)
+	svc := NewURLService(mockRepo)
+
+	ctx := context.Background()
+	originalURL := "https://finance.ozon.ru"
+
+	mockRepo.On("GetShortenedURLByOriginal", mock.Anything, mock.Anything, originalURL).
+		Return("", domain.ErrShortenedNotFound)
+	mockRepo.On("GetOriginalURLByShortened", mock.Anything, mock.Anything).
+		Return("", domain.ErrOriginalNotFound)
+	mockRepo.On("CreateOrGetShortenedURL", mock.Anything, linkWithOriginal(originalURL)).
+		Return(domain.Link{}, fmt.Errorf("CreateOrGetShortenedURL: %w", domain.ErrConflict))
+
+	_, err := svc.CreateLink(ctx, domain.Link{Original: originalURL})
+
+	require.ErrorIs(t, err, domain.ErrConflict)
+	require.Equal(t, domain.CodeConflict, domain.ErrorCodeOf(err))
+	require.False(t, domain.IsTransient(domain.ErrorCodeOf(err)))
+	mockRepo.AssertNumberOfCalls(t, "CreateOrGetShortenedURL", createAttempts)
+}
+
+func TestCreateLink_ContextTimeout(t *testing.T) {
 	t.Parallel()
 	const operationTimeout = time.Second * 5
 
@@ -89,18 +171,18 @@ func TestShortenURL_ContextTimeout(t *testing.T) {
 	defer cancel()
 	originalURL := "https://finance.ozon.ru"
 
-	mockRepo.On("GetShortenedURLByOriginal", mock.Anything, originalURL).
+	mockRepo.On("GetShortenedURLByOriginal", mock.Anything, mock.Anything, originalURL).
 		Return("", domain.ErrOriginalNotFound)
 	mockRepo.On("GetOriginalURLByShortened", mock.Anything, mock.Anything).
 		Return("", context.DeadlineExceeded)
 
-	result, err := svc.ShortenURL(ctx, originalURL)
+	result, err := svc.CreateLink(ctx, domain.Link{Original: originalURL})
 
 	require.Error(t, err)
 	require.Empty(t, result)
 }
 
-func TestShortenURL_UnexpectedDBError(t *testing.T) {
+func TestCreateLink_UnexpectedDBError(t *testing.T) {
 	t.Parallel()
 	mockRepo := new(mocks.URL)
 	svc := NewURLService(mockRepo)
@@ -108,10 +190,10 @@ func TestShortenURL_UnexpectedDBError(t *testing.T) {
 	ctx := context.Background()
 	originalURL := "https://finance.ozon.ru"
 
-	mockRepo.On("GetShortenedURLByOriginal", mock.Anything, originalURL).
+	mockRepo.On("GetShortenedURLByOriginal", mock.Anything, mock.Anything, originalURL).
 		Return("", errors.New("no connection to the db"))
 
-	result, err := svc.ShortenURL(ctx, originalURL)
+	result, err := svc.CreateLink(ctx, domain.Link{Original: originalURL})
 
 	require.Error(t, err)
 	require.Empty(t, result)
@@ -119,6 +201,55 @@ func TestShortenURL_UnexpectedDBError(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestCreateLink_StorageUnavailable(t *testing.T) {
+	t.Parallel()
+	mockRepo := new(mocks.URL)
+	svc := NewURLService(mockRepo)
+
+	ctx := context.Background()
+	originalURL := "https://finance.ozon.ru"
+
+	mockRepo.On("GetShortenedURLByOriginal", mock.Anything, mock.Anything, originalURL).
+		Return("", domain.ErrShortenedNotFound)
+	// generation of shortened url doesn't retry failures of the storage
+	mockRepo.On("GetOriginalURLByShortened", mock.Anything, mock.Anything).
+		Return("", fmt.Errorf("GetOriginalURLByShortened: %w", domain.ErrStorageUnavailable)).Once()
+
+	result, err := svc.CreateLink(ctx, domain.Link{Original: originalURL})
+
+	require.ErrorIs(t, err, domain.ErrStorageUnavailable)
+	require.Empty(t, result)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateLink_WithExpiration(t *testing.T) {
+	t.Parallel()
+	mockRepo := new(mocks.URL)
+	svc := NewURLService(mockRepo)
+
+	ctx := context.Background()
+	originalURL := "https://finance.ozon.ru"
+	expiresAt := time.Now().Add(time.Hour)
+
+	mockRepo.On("GetOriginalURLByShortened", mock.Anything, mock.Anything).
+		Return("", domain.ErrOriginalNotFound)
+	mockRepo.On("CreateOrGetShortenedURL", mock.Anything, mock.MatchedBy(func(link domain.Link) bool {
+		return link.Original == originalURL && link.ExpiresAt.Equal(expiresAt)
+	})).Return(domain.Link{Original: originalURL, ExpiresAt: expiresAt}, nil)
+
+	result, err := svc.CreateLink(ctx, domain.Link{Original: originalURL, ExpiresAt: expiresAt})
+	require.NoError(t, err)
+	require.Equal(t, expiresAt, result.ExpiresAt)
+
+	_, err = svc.CreateLink(ctx, domain.Link{Original: originalURL, ExpiresAt: time.Now().Add(-time.Hour)})
+	require.ErrorIs(t, err, domain.ErrInvalidExpiration)
+
+	// links with expiration are not deduplicated
+	mockRepo.AssertNotCalled(t, "GetShortenedURLByOriginal", mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
 func TestResolveURL_ExistedURL(t *testing.T) {
 	t.Parallel()
 	mockRepo := new(mocks.URL)
@@ -130,6 +261,8 @@ func TestResolveURL_ExistedURL(t *testing.T) {
 
 	mockRepo.On("GetOriginalURLByShortened", mock.Anything, shortenedURL).
 		Return(originalURL, nil)
+	mockRepo.On("RecordClick", mock.Anything, shortenedURL).
+		Return(errors.New("no connection to the db"))
 
 	result, err := svc.ResolveURL(ctx, shortenedURL)
 
@@ -158,3 +291,203 @@ func TestResolveURL_NotFound(t *testing.T) {
 
 	mockRepo.AssertExpectations(t)
 }
+
+func TestListLinks_Pagination(t *testing.T) {
+	t.Parallel()
+	mockRepo := new(mocks.URL)
+	svc := NewURLService(mockRepo)
+
+	ctx := context.Background()
+	links := []domain.Link{{ID: 5}, {ID: 4}, {ID: 3}}
+
+	mockRepo.On("ListLinks", mock.Anything, domain.LinkFilter{}, domain.Page{Limit: 3}).
+		Return(links, nil).Once()
+	mockRepo.On("ListLinks", mock.Anything, domain.LinkFilter{}, domain.Page{AfterID: 4, Limit: 3}).
+		Return(links[2:], nil).Once()
+
+	page, err := svc.ListLinks(ctx, domain.LinkFilter{}, "", 2)
+	require.NoError(t, err)
+	require.Equal(t, links[:2], page.Links)
+	require.NotEmpty(t, page.NextCursor)
+
+	page, err = svc.ListLinks(ctx, domain.LinkFilter{}, page.NextCursor, 2)
+	require.NoError(t, err)
+	require.Equal(t, links[2:], page.Links)
+	require.Empty(t, page.NextCursor)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestExportLinks(t *testing.T) {
+	t.Parallel()
+	mockRepo := new(mocks.URL)
+	svc := NewURLService(mockRepo)
+
+	ctx := auth.WithPrincipal(context.Background(), domain.Principal{ID: "key", OwnerID: "team"})
+	links := make([]domain.Link, domain.MaxPageSize+2)
+	for i := range links {
+		links[i] = domain.Link{ID: int64(len(links) - i), OwnerID: "team"}
+	}
+	filter := domain.LinkFilter{OwnerID: "team"}
+
+	mockRepo.On("ListLinks", mock.Anything, filter, domain.Page{Limit: domain.MaxPageSize + 1}).
+		Return(links[:domain.MaxPageSize+1], nil).Once()
+	mockRepo.On("ListLinks", mock.Anything, filter, domain.Page{AfterID: 3, Limit: domain.MaxPageSize + 1}).
+		Return(links[domain.MaxPageSize:], nil).Once()
+
+	var exported []domain.Link
+	err := svc.ExportLinks(ctx, domain.LinkFilter{}, func(link domain.Link) error {
+		exported = append(exported, link)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, links, exported)
+
+	// error of yield stops the export
+	stop := errors.New("client is gone")
+	mockRepo.On("ListLinks", mock.Anything, filter, domain.Page{Limit: domain.MaxPageSize + 1}).
+		Return(links[:domain.MaxPageSize+1], nil).Once()
+	err = svc.ExportLinks(ctx, domain.LinkFilter{}, func(domain.Link) error {
+		return stop
+	})
+	require.ErrorIs(t, err, stop)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListLinks_OwnerScope(t *testing.T) {
+	t.Parallel()
+	mockRepo := new(mocks.URL)
+	svc := NewURLService(mockRepo)
+
+	ctx := auth.WithPrincipal(context.Background(), domain.Principal{
+		ID:      "key",
+		OwnerID: "team",
+		Scopes:  []domain.Scope{domain.ScopeLinksRead},
+	})
+
+	mockRepo.On("ListLinks", mock.Anything, domain.LinkFilter{OwnerID: "team"}, mock.Anything).
+		Return([]domain.Link{}, nil).Once()
+
+	_, err := svc.ListLinks(ctx, domain.LinkFilter{}, "", 0)
+	require.NoError(t, err)
+
+	_, err = svc.ListLinks(ctx, domain.LinkFilter{OwnerID: "other"}, "", 0)
+	require.ErrorIs(t, err, domain.ErrPermissionDenied)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListLinks_InvalidRequest(t *testing.T) {
+	t.Parallel()
+	mockRepo := new(mocks.URL)
+	svc := NewURLService(mockRepo)
+
+	ctx := context.Background()
+	now := time.Now()
+
+	_, err := svc.ListLinks(ctx, domain.LinkFilter{}, "", domain.MaxPageSize+1)
+	require.ErrorIs(t, err, domain.ErrInvalidFilter)
+
+	_, err = svc.ListLinks(ctx, domain.LinkFilter{CreatedAfter: now, CreatedBefore: now}, "", 0)
+	require.ErrorIs(t, err, domain.ErrInvalidFilter)
+
+	_, err = svc.ListLinks(ctx, domain.LinkFilter{}, "not a cursor", 0)
+	require.ErrorIs(t, err, domain.ErrInvalidCursor)
+
+	mockRepo.AssertNotCalled(t, "ListLinks", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func linkWithOriginal(original domain.URL) interface{} {
+	return mock.MatchedBy(func(link domain.Link) bool {
+		return link.Original == original
+	})
+}
+
+func TestUpdateLink_Success(t *testing.T) {
+	t.Parallel()
+	mockRepo := new(mocks.URL)
+	svc := NewURLService(mockRepo)
+
+	ctx := auth.WithPrincipal(context.Background(), domain.Principal{ID: "key", OwnerID: "team"})
+	link := domain.Link{Original: "https://ozon.ru", Shortened: "abc123", OwnerID: "team", Version: 1}
+	updated := domain.Link{Original: "https://finance.ozon.ru", Shortened: "abc123", OwnerID: "team", Version: 2}
+
+	mockRepo.On("GetLink", mock.Anything, link.Shortened).Return(link, nil)
+	mockRepo.On("UpdateLink", mock.Anything, link.Shortened, domain.LinkUpdate{Original: &updated.Original}, "key").
+		Return(updated, nil).Once()
+
+	result, err := svc.UpdateLink(ctx, link.Shortened, domain.LinkUpdate{Original: &updated.Original})
+	require.NoError(t, err)
+	require.Equal(t, updated, result)
+
+	// the same destination doesn't create a version
+	result, err = svc.UpdateLink(ctx, link.Shortened, domain.LinkUpdate{Original: &link.Original})
+	require.NoError(t, err)
+	require.Equal(t, link, result)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateLink_PermissionDenied(t *testing.T) {
+	t.Parallel()
+	mockRepo := new(mocks.URL)
+	svc := NewURLService(mockRepo)
+
+	ctx := auth.WithPrincipal(context.Background(), domain.Principal{ID: "key", OwnerID: "team"})
+
+	mockRepo.On("GetLink", mock.Anything, "others").
+		Return(domain.Link{Shortened: "others", OwnerID: "other"}, nil)
+	mockRepo.On("GetLink", mock.Anything, "anonymous").
+		Return(domain.Link{Shortened: "anonymous"}, nil)
+
+	original := "https://ozon.ru"
+	_, err := svc.UpdateLink(ctx, "others", domain.LinkUpdate{Original: &original})
+	require.ErrorIs(t, err, domain.ErrPermissionDenied)
+
+	_, err = svc.GetLink(ctx, "others")
+	require.ErrorIs(t, err, domain.ErrPermissionDenied)
+
+	err = svc.DeleteLink(ctx, "anonymous")
+	require.ErrorIs(t, err, domain.ErrPermissionDenied)
+
+	_, err = svc.RollbackLink(ctx, "anonymous", 1)
+	require.ErrorIs(t, err, domain.ErrPermissionDenied)
+
+	_, err = svc.ListLinkVersions(ctx, "others")
+	require.ErrorIs(t, err, domain.ErrPermissionDenied)
+
+	mockRepo.AssertNotCalled(t, "UpdateLink", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "DeleteLink", mock.Anything, mock.Anything)
+}
+
+func TestRollbackLink(t *testing.T) {
+	t.Parallel()
+	mockRepo := new(mocks.URL)
+	svc := NewURLService(mockRepo)
+
+	ctx := context.Background()
+	link := domain.Link{Original: "https://finance.ozon.ru", Shortened: "abc123", Version: 2}
+	versions := []domain.LinkVersion{
+		{Version: 2, Original: "https://finance.ozon.ru"},
+		{Version: 1, Original: "https://ozon.ru"},
+	}
+	rolledBack := domain.Link{Original: "https://ozon.ru", Shortened: "abc123", Version: 3}
+
+	mockRepo.On("GetLink", mock.Anything, link.Shortened).Return(link, nil)
+	mockRepo.On("ListLinkVersions", mock.Anything, link.Shortened).Return(versions, nil)
+	mockRepo.On("UpdateLink", mock.Anything, link.Shortened, domain.LinkUpdate{Original: &rolledBack.Original}, "").
+		Return(rolledBack, nil).Once()
+
+	result, err := svc.RollbackLink(ctx, link.Shortened, 1)
+	require.NoError(t, err)
+	require.Equal(t, rolledBack, result)
+
+	_, err = svc.RollbackLink(ctx, link.Shortened, 5)
+	require.ErrorIs(t, err, domain.ErrVersionNotFound)
+
+	_, err = svc.RollbackLink(ctx, link.Shortened, 0)
+	require.ErrorIs(t, err, domain.ErrInvalidVersion)
+
+	mockRepo.AssertExpectations(t)
+}
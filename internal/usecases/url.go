@@ -9,12 +9,63 @@ import (
 //
 //go:generate go run github.com/vektra/mockery/v2@v2.50 --name=URL --filename=url_service_mock.go
 type URL interface {
-	// ShortenURL generates a shortened version of the given original URL.
-	// If the URL has already been shortened, it returns the existing shortened URL.
-	// Returns a shortened URL or an error.
-	ShortenURL(ctx context.Context, original domain.URL) (domain.ShortURL, error)
+	// CreateLink generates a shortened version of the original URL of spec,
+	// only Original, Tags and ExpiresAt of spec are used.
+	// If the URL has already been shortened, it returns the existing link, links with expiration are always new.
+	// New links are owned by the principal from the context, if any.
+	// Returns `domain.ErrInvalidTags` or `domain.ErrInvalidExpiration` for malformed spec.
+	CreateLink(ctx context.Context, spec domain.Link) (domain.Link, error)
 
-	// ResolveURL retrieves the original URL from its shortened version.
-	// Returns `domain.ErrOriginalNotFound` if the shortened URL does not exist.
+	// ResolveURL retrieves the original URL from its shortened version and counts the click.
+	// Returns `domain.ErrOriginalNotFound` if the shortened URL does not exist
+	// and `domain.ErrLinkExpired` if the link has expired.
 	ResolveURL(ctx context.Context, shortened domain.ShortURL) (domain.URL, error)
+
+	// GetLink returns the link by its shortened URL.
+	// Callers without admin scope can see only their own links.
+	GetLink(ctx context.Context, shortened domain.ShortURL) (domain.Link, error)
+
+	// DeleteLink removes the link, its shortened URL stops resolving.
+	// Callers without admin scope can delete only their own links.
+	DeleteLink(ctx context.Context, shortened domain.ShortURL) error
+
+	// ListLinks returns a page of links matching the filter, newest first.
+	// Cursor is taken from the previous page, empty cursor starts from the newest link.
+	// Callers without admin scope see only their own links.
+	// Returns `domain.ErrInvalidCursor` or `domain.ErrInvalidFilter` for malformed requests.
+	ListLinks(ctx context.Context, filter domain.LinkFilter, cursor string, limit int) (domain.LinkPage, error)
+
+	// ExportLinks walks all links matching the filter page by page, newest first, and passes them to yield.
+	// Stops on the first error of yield and returns it. Scoped by owner the same way as ListLinks.
+	ExportLinks(ctx context.Context, filter domain.LinkFilter, yield func(domain.Link) error) error
+
+	// UpdateLink applies non-nil fields of the update keeping the shortened URL.
+	// Previous destination stays in versions of the link.
+	// Callers without admin scope can update only their own links.
+	// Returns `domain.ErrOriginalNotFound` if the shortened URL does not exist.
+	UpdateLink(ctx context.Context, shortened domain.ShortURL, update domain.LinkUpdate) (domain.Link, error)
+
+	// ListLinkVersions returns destinations of the link, newest first.
+	// Callers without admin scope can see only their own links.
+	ListLinkVersions(ctx context.Context, shortened domain.ShortURL) ([]domain.LinkVersion, error)
+
+	// RollbackLink sets destination of the given version as a new version of the link.
+	// Returns `domain.ErrVersionNotFound` if the link has no such version.
+	RollbackLink(ctx context.Context, shortened domain.ShortURL, version int) (domain.Link, error)
+}
+
+type linkCreatedHookKey struct{}
+
+// WithLinkCreatedHook returns the context whose CreateLink calls hook with the context of the call
+// once a new link is stored. Links returned by deduplication don't call it, so callers can charge
+// per created link, e.g. interceptors spend daily quota with it.
+func WithLinkCreatedHook(ctx context.Context, hook func(ctx context.Context)) context.Context {
+	return context.WithValue(ctx, linkCreatedHookKey{}, hook)
+}
+
+// LinkCreated calls the hook of the context, if any. Implementations of URL call it for every stored link.
+func LinkCreated(ctx context.Context) {
+	if hook, ok := ctx.Value(linkCreatedHookKey{}).(func(ctx context.Context)); ok {
+		hook(ctx)
+	}
 }
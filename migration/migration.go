@@ -0,0 +1,20 @@
+// Package migration embeds migrations of the schema, so the binary always carries the schema it needs.
+package migration
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed migrations/postgres/*.sql
+var files embed.FS
+
+// Postgres returns migrations of the PostgreSQL storage.
+func Postgres() fs.FS {
+	postgres, err := fs.Sub(files, "migrations/postgres")
+	if err != nil {
+		// the directory is embedded, so it always exists
+		panic(err)
+	}
+	return postgres
+}
@@ -0,0 +1,18 @@
+package migration
+
+import (
+	"ozon_task/pkg/migrate"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgres(t *testing.T) {
+	migrations, err := migrate.Load(Postgres())
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	for i, m := range migrations {
+		require.Equal(t, i+1, m.Version, "versions are sequential")
+	}
+}
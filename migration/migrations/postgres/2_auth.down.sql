@@ -0,0 +1,4 @@
+-- +migrate Down
+ALTER TABLE links DROP COLUMN IF EXISTS owner_id;
+
+DROP TABLE IF EXISTS api_keys;
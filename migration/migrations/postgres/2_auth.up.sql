@@ -0,0 +1,14 @@
+-- +migrate Up
+CREATE TABLE api_keys(
+    id CHAR(10) PRIMARY KEY,
+    owner_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    key_hash BYTEA NOT NULL UNIQUE,
+    is_admin BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_api_keys_owner_id ON api_keys (owner_id);
+
+ALTER TABLE links ADD COLUMN owner_id TEXT;
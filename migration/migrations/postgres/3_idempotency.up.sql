@@ -0,0 +1,12 @@
+-- +migrate Up
+CREATE TABLE idempotency_keys(
+    key TEXT PRIMARY KEY,
+    fingerprint BYTEA NOT NULL,
+    status_code INT,
+    content_type TEXT,
+    body BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
@@ -0,0 +1,11 @@
+-- +migrate Down
+DROP INDEX IF EXISTS idx_links_original_link_trgm;
+DROP INDEX IF EXISTS idx_links_tags;
+DROP INDEX IF EXISTS idx_links_created_at;
+DROP INDEX IF EXISTS idx_links_original_host_id;
+DROP INDEX IF EXISTS idx_links_owner_id_id;
+
+ALTER TABLE links
+    DROP COLUMN IF EXISTS original_host,
+    DROP COLUMN IF EXISTS tags,
+    DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,16 @@
+-- +migrate Up
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE links
+    ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN original_host TEXT GENERATED ALWAYS AS (
+        lower(substring(original_link FROM '^[a-zA-Z][a-zA-Z0-9+.-]*://(?:[^/?#@]*@)?([^/?#:]+)'))
+    ) STORED;
+
+-- listing is ordered by id, so filtered indexes end with it for keyset pagination
+CREATE INDEX idx_links_owner_id_id ON links (owner_id, id DESC);
+CREATE INDEX idx_links_original_host_id ON links (original_host, id DESC);
+CREATE INDEX idx_links_created_at ON links (created_at);
+CREATE INDEX idx_links_tags ON links USING GIN (tags);
+CREATE INDEX idx_links_original_link_trgm ON links USING GIN (original_link gin_trgm_ops);
@@ -0,0 +1,11 @@
+-- +migrate Down
+DROP TABLE IF EXISTS link_versions;
+
+-- fails if edited links share destinations with other links
+DROP INDEX IF EXISTS idx_links_original_link_unedited;
+ALTER TABLE links ADD CONSTRAINT links_original_link_key UNIQUE (original_link);
+
+ALTER TABLE links
+    DROP COLUMN IF EXISTS updated_by,
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS version;
@@ -0,0 +1,19 @@
+-- +migrate Up
+ALTER TABLE links
+    ADD COLUMN version INT NOT NULL DEFAULT 1,
+    ADD COLUMN updated_at TIMESTAMPTZ,
+    ADD COLUMN updated_by TEXT;
+
+-- edited links keep their short codes for new destinations, so only never edited links are deduplicated by original
+ALTER TABLE links DROP CONSTRAINT links_original_link_key;
+CREATE UNIQUE INDEX idx_links_original_link_unedited ON links (original_link) WHERE version = 1;
+
+-- previous destinations of links, the current one is stored in links
+CREATE TABLE link_versions(
+    link_id INT NOT NULL REFERENCES links (id) ON DELETE CASCADE,
+    version INT NOT NULL,
+    original_link TEXT NOT NULL,
+    author TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (link_id, version)
+);
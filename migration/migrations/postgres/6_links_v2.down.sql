@@ -0,0 +1,9 @@
+-- +migrate Down
+DROP INDEX IF EXISTS idx_links_original_link_deduplicated;
+-- fails if not deduplicated links of the first version share destinations with other links
+CREATE UNIQUE INDEX idx_links_original_link_unedited ON links (original_link) WHERE version = 1;
+
+ALTER TABLE links
+    DROP COLUMN IF EXISTS deduplicated,
+    DROP COLUMN IF EXISTS clicks,
+    DROP COLUMN IF EXISTS expires_at;
@@ -0,0 +1,12 @@
+-- +migrate Up
+ALTER TABLE links
+    ADD COLUMN expires_at TIMESTAMPTZ,
+    ADD COLUMN clicks BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN deduplicated BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- deduplication is stopped by changes of destination or expiration and is never restored,
+-- so clearing expiration can't conflict with another link of the same original
+UPDATE links SET deduplicated = FALSE WHERE version > 1;
+
+DROP INDEX IF EXISTS idx_links_original_link_unedited;
+CREATE UNIQUE INDEX idx_links_original_link_deduplicated ON links (original_link) WHERE deduplicated;
@@ -0,0 +1,61 @@
+-- +migrate Down
+ALTER TABLE link_versions RENAME TO link_versions_partitioned;
+ALTER TABLE links RENAME TO links_partitioned;
+ALTER SEQUENCE links_id_seq OWNED BY NONE;
+
+CREATE TABLE links(
+    id INT NOT NULL DEFAULT nextval('links_id_seq'),
+    original_link TEXT NOT NULL,
+    shortened_link CHAR(10) NOT NULL,
+    owner_id TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    original_host TEXT GENERATED ALWAYS AS (
+        lower(substring(original_link FROM '^[a-zA-Z][a-zA-Z0-9+.-]*://(?:[^/?#@]*@)?([^/?#:]+)'))
+    ) STORED,
+    version INT NOT NULL DEFAULT 1,
+    updated_at TIMESTAMPTZ,
+    updated_by TEXT,
+    expires_at TIMESTAMPTZ,
+    clicks BIGINT NOT NULL DEFAULT 0,
+    deduplicated BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+CREATE TABLE link_versions(
+    link_id INT NOT NULL,
+    version INT NOT NULL,
+    original_link TEXT NOT NULL,
+    author TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+INSERT INTO links (id, original_link, shortened_link, owner_id, created_at, tags,
+                   version, updated_at, updated_by, expires_at, clicks, deduplicated)
+SELECT id, original_link, shortened_link, owner_id, created_at, tags,
+       version, updated_at, updated_by, expires_at, clicks, deduplicated
+FROM links_partitioned;
+
+INSERT INTO link_versions (link_id, version, original_link, author, created_at)
+SELECT l.id, v.version, v.original_link, v.author, v.created_at
+FROM link_versions_partitioned v JOIN links_partitioned l ON l.shortened_link = v.shortened_link;
+
+DROP TABLE link_versions_partitioned;
+DROP TABLE link_originals;
+DROP TABLE links_partitioned;
+-- fails if ids don't fit INT
+ALTER SEQUENCE links_id_seq AS INT;
+ALTER SEQUENCE links_id_seq OWNED BY links.id;
+
+ALTER TABLE links ADD PRIMARY KEY (id);
+ALTER TABLE links ADD CONSTRAINT links_shortened_link_key UNIQUE (shortened_link);
+ALTER TABLE link_versions ADD PRIMARY KEY (link_id, version);
+ALTER TABLE link_versions ADD FOREIGN KEY (link_id) REFERENCES links (id) ON DELETE CASCADE;
+
+CREATE INDEX idx_links_original_link ON links USING HASH (original_link);
+CREATE INDEX idx_links_shortened_link ON links USING HASH (shortened_link);
+CREATE UNIQUE INDEX idx_links_original_link_deduplicated ON links (original_link) WHERE deduplicated;
+CREATE INDEX idx_links_owner_id_id ON links (owner_id, id DESC);
+CREATE INDEX idx_links_original_host_id ON links (original_host, id DESC);
+CREATE INDEX idx_links_created_at ON links (created_at);
+CREATE INDEX idx_links_tags ON links USING GIN (tags);
+CREATE INDEX idx_links_original_link_trgm ON links USING GIN (original_link gin_trgm_ops);
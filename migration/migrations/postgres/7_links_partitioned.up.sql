@@ -0,0 +1,82 @@
+-- +migrate Up
+-- links are partitioned by short code, so resolving reads a single partition. Uniqueness of deduplicated
+-- destinations can't be enforced across partitions of links, it's kept by link_originals partitioned
+-- by destination, which also serves lookups of short codes by destination.
+ALTER TABLE link_versions RENAME TO link_versions_unpartitioned;
+ALTER TABLE links RENAME TO links_unpartitioned;
+ALTER SEQUENCE links_id_seq OWNED BY NONE;
+ALTER SEQUENCE links_id_seq AS BIGINT;
+
+CREATE TABLE links(
+    id BIGINT NOT NULL DEFAULT nextval('links_id_seq'),
+    original_link TEXT NOT NULL,
+    shortened_link CHAR(10) NOT NULL,
+    owner_id TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    original_host TEXT GENERATED ALWAYS AS (
+        lower(substring(original_link FROM '^[a-zA-Z][a-zA-Z0-9+.-]*://(?:[^/?#@]*@)?([^/?#:]+)'))
+    ) STORED,
+    version INT NOT NULL DEFAULT 1,
+    updated_at TIMESTAMPTZ,
+    updated_by TEXT,
+    expires_at TIMESTAMPTZ,
+    clicks BIGINT NOT NULL DEFAULT 0,
+    deduplicated BOOLEAN NOT NULL DEFAULT TRUE
+) PARTITION BY HASH (shortened_link);
+
+-- short codes of deduplicated links by destination
+CREATE TABLE link_originals(
+    original_link TEXT NOT NULL,
+    shortened_link CHAR(10) NOT NULL
+) PARTITION BY HASH (original_link);
+
+-- versions are stored next to their links
+CREATE TABLE link_versions(
+    shortened_link CHAR(10) NOT NULL,
+    version INT NOT NULL,
+    original_link TEXT NOT NULL,
+    author TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+) PARTITION BY HASH (shortened_link);
+
+DO $$
+BEGIN
+    FOR i IN 0..15 LOOP
+        EXECUTE format('CREATE TABLE links_p%s PARTITION OF links FOR VALUES WITH (MODULUS 16, REMAINDER %s)', i, i);
+        EXECUTE format('CREATE TABLE link_originals_p%s PARTITION OF link_originals FOR VALUES WITH (MODULUS 16, REMAINDER %s)', i, i);
+        EXECUTE format('CREATE TABLE link_versions_p%s PARTITION OF link_versions FOR VALUES WITH (MODULUS 16, REMAINDER %s)', i, i);
+    END LOOP;
+END
+$$;
+
+INSERT INTO links (id, original_link, shortened_link, owner_id, created_at, tags,
+                   version, updated_at, updated_by, expires_at, clicks, deduplicated)
+SELECT id, original_link, shortened_link, owner_id, created_at, tags,
+       version, updated_at, updated_by, expires_at, clicks, deduplicated
+FROM links_unpartitioned;
+
+INSERT INTO link_originals (original_link, shortened_link)
+SELECT original_link, shortened_link FROM links_unpartitioned WHERE deduplicated;
+
+INSERT INTO link_versions (shortened_link, version, original_link, author, created_at)
+SELECT l.shortened_link, v.version, v.original_link, v.author, v.created_at
+FROM link_versions_unpartitioned v JOIN links_unpartitioned l ON l.id = v.link_id;
+
+-- names of constraints and indexes are reused, so they're created after the old tables are dropped
+DROP TABLE link_versions_unpartitioned;
+DROP TABLE links_unpartitioned;
+ALTER SEQUENCE links_id_seq OWNED BY links.id;
+
+ALTER TABLE links ADD PRIMARY KEY (shortened_link);
+ALTER TABLE link_originals ADD PRIMARY KEY (original_link);
+ALTER TABLE link_versions ADD PRIMARY KEY (shortened_link, version);
+ALTER TABLE link_versions ADD FOREIGN KEY (shortened_link) REFERENCES links (shortened_link) ON DELETE CASCADE;
+
+-- listing is ordered by id, so filtered indexes end with it for keyset pagination
+CREATE INDEX idx_links_id ON links (id DESC);
+CREATE INDEX idx_links_owner_id_id ON links (owner_id, id DESC);
+CREATE INDEX idx_links_original_host_id ON links (original_host, id DESC);
+CREATE INDEX idx_links_created_at ON links (created_at);
+CREATE INDEX idx_links_tags ON links USING GIN (tags);
+CREATE INDEX idx_links_original_link_trgm ON links USING GIN (original_link gin_trgm_ops);
@@ -0,0 +1,21 @@
+-- +migrate Down
+DROP TABLE link_originals;
+
+CREATE TABLE link_originals(
+    original_link TEXT NOT NULL,
+    shortened_link CHAR(10) NOT NULL
+) PARTITION BY HASH (original_link);
+
+DO $$
+BEGIN
+    FOR i IN 0..15 LOOP
+        EXECUTE format('CREATE TABLE link_originals_p%s PARTITION OF link_originals FOR VALUES WITH (MODULUS 16, REMAINDER %s)', i, i);
+    END LOOP;
+END
+$$;
+
+INSERT INTO link_originals (original_link, shortened_link)
+SELECT original_link, shortened_link FROM links WHERE deduplicated;
+
+-- fails if destinations are too long to be indexed
+ALTER TABLE link_originals ADD PRIMARY KEY (original_link);
@@ -0,0 +1,23 @@
+-- +migrate Up
+-- btree entries are limited to a third of a page, so long destinations can't be unique by their text.
+-- Deduplicated destinations are unique by SHA-256 digests of their UTF-8 text instead,
+-- lookups by digest verify the full text in links. The partition key changes, so the table is rebuilt.
+DROP TABLE link_originals;
+
+CREATE TABLE link_originals(
+    original_sha256 BYTEA NOT NULL,
+    shortened_link CHAR(10) NOT NULL
+) PARTITION BY HASH (original_sha256);
+
+DO $$
+BEGIN
+    FOR i IN 0..15 LOOP
+        EXECUTE format('CREATE TABLE link_originals_p%s PARTITION OF link_originals FOR VALUES WITH (MODULUS 16, REMAINDER %s)', i, i);
+    END LOOP;
+END
+$$;
+
+INSERT INTO link_originals (original_sha256, shortened_link)
+SELECT sha256(convert_to(original_link, 'UTF8')), shortened_link FROM links WHERE deduplicated;
+
+ALTER TABLE link_originals ADD PRIMARY KEY (original_sha256);
@@ -0,0 +1,9 @@
+-- +migrate Down
+-- Destinations shared by several owners keep the oldest link deduplicated.
+DELETE FROM link_originals;
+
+UPDATE links SET deduplicated = FALSE
+WHERE deduplicated AND id NOT IN (SELECT min(id) FROM links WHERE deduplicated GROUP BY original_link);
+
+INSERT INTO link_originals (original_sha256, shortened_link)
+SELECT sha256(convert_to(original_link, 'UTF8')), shortened_link FROM links WHERE deduplicated;
@@ -0,0 +1,8 @@
+-- +migrate Up
+-- Destinations are deduplicated within owners, so the digest covers the owner of the link as well.
+-- Owners can't contain NUL, it separates the owner from the destination.
+UPDATE link_originals lo
+SET original_sha256 = sha256(
+    convert_to(COALESCE(l.owner_id, ''), 'UTF8') || '\x00'::BYTEA || convert_to(l.original_link, 'UTF8'))
+FROM links l
+WHERE l.shortened_link = lo.shortened_link;
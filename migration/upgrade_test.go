@@ -0,0 +1,83 @@
+package migration_test
+
+import (
+	"context"
+	"ozon_task/domain"
+	"ozon_task/internal/repository/postgres"
+	"ozon_task/internal/repository/postgres/pgtest"
+	"ozon_task/migration"
+	"ozon_task/pkg/infra/cache/stub"
+	"ozon_task/pkg/migrate"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgres_UpgradeKeepsDeduplication(t *testing.T) {
+	t.Parallel()
+	pool, _ := pgtest.NewDatabase(t)
+	ctx := context.Background()
+
+	migrations, err := migrate.Load(migration.Postgres())
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(migrations), 9)
+
+	// links are seeded before partitioning (7), digests (8) and digests of owners (9),
+	// when destinations were deduplicated across owners
+	pgtest.Migrate(t, pool, migrations[:6])
+	_, err = pool.Exec(ctx, `
+        INSERT INTO links (original_link, shortened_link, owner_id, version, deduplicated) VALUES
+            ('https://ozon.ru/shared', 'ALICE00001', 'alice', 1, TRUE),
+            ('https://ozon.ru/shared', 'BOB0000001', 'bob', 1, FALSE),
+            ('https://ozon.ru/anonymous', 'ANONYMOUS1', NULL, 1, TRUE),
+            ('https://ozon.ru/edited', 'EDITED0001', 'alice', 2, FALSE)`)
+	require.NoError(t, err)
+
+	pgtest.Migrate(t, pool, migrations[:9])
+
+	var deduplicated int
+	require.NoError(t, pool.QueryRow(ctx, `SELECT count(*) FROM link_originals`).Scan(&deduplicated))
+	require.Equal(t, 2, deduplicated)
+
+	repo := postgres.NewURLRepository(pool, nil, stub.NewStub(), time.Minute, time.Second, postgres.BatchConfig{})
+	t.Cleanup(repo.Close)
+
+	tests := []struct {
+		owner    domain.OwnerID
+		original domain.URL
+		// existing is the seeded link of the owner, empty if a new one is created
+		existing domain.ShortURL
+	}{
+		{owner: "alice", original: "https://ozon.ru/shared", existing: "ALICE00001"},
+		{owner: "", original: "https://ozon.ru/anonymous", existing: "ANONYMOUS1"},
+		{owner: "bob", original: "https://ozon.ru/shared"},
+		{owner: "alice", original: "https://ozon.ru/anonymous"},
+		{owner: "alice", original: "https://ozon.ru/edited"},
+	}
+
+	for i, tt := range tests {
+		shortened, err := repo.GetShortenedURLByOriginal(ctx, tt.owner, tt.original)
+		if len(tt.existing) == 0 {
+			require.ErrorIs(t, err, domain.ErrShortenedNotFound, tt)
+		} else {
+			require.NoError(t, err, tt)
+			require.Equal(t, tt.existing, shortened, tt)
+		}
+
+		created := domain.ShortURL("NEWLINK00" + string(rune('0'+i)))
+		link, err := repo.CreateOrGetShortenedURL(ctx,
+			domain.Link{Original: tt.original, Shortened: created, OwnerID: tt.owner})
+		require.NoError(t, err, tt)
+		if len(tt.existing) == 0 {
+			require.Equal(t, created, link.Shortened, tt)
+		} else {
+			require.Equal(t, tt.existing, link.Shortened, tt)
+		}
+
+		// the new links are deduplicated as well
+		shortened, err = repo.GetShortenedURLByOriginal(ctx, tt.owner, tt.original)
+		require.NoError(t, err, tt)
+		require.Equal(t, link.Shortened, shortened, tt)
+	}
+}
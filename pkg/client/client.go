@@ -0,0 +1,162 @@
+// Package client is the Go SDK of the URL shortener. Client has HTTP and gRPC implementations
+// with the same behavior and Fake for unit tests of consumers.
+//
+// Errors reported by the service are returned as *Error, which matches the domain errors
+// of its code with errors.Is:
+//
+//	original, err := c.Resolve(ctx, shortened)
+//	if errors.Is(err, domain.ErrOriginalNotFound) {
+//		...
+//	}
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"ozon_task/domain"
+	"ozon_task/pkg/resilience"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// Client of the shortener. Calls send id of the request from the context (see requestid.With)
+// or a generated one, the same id is sent by all attempts of the call.
+type Client interface {
+	// Shorten returns shortened url of the original one, an already shortened url gets the same link.
+	Shorten(ctx context.Context, original domain.URL, tags ...domain.Tag) (domain.ShortURL, error)
+	// Resolve returns the original url of the link.
+	Resolve(ctx context.Context, shortened domain.ShortURL) (domain.URL, error)
+	// CreateLink creates the link, Original, Tags and ExpiresAt of the spec are used.
+	CreateLink(ctx context.Context, spec domain.Link) (domain.Link, error)
+	GetLink(ctx context.Context, shortened domain.ShortURL) (domain.Link, error)
+	// ListLinks returns a page of links matching the filter, newest first.
+	ListLinks(ctx context.Context, req ListLinksRequest) (LinkPage, error)
+	// UpdateLink applies non-nil fields of the update, ExpiresAt pointing to zero time removes expiration.
+	UpdateLink(ctx context.Context, shortened domain.ShortURL, update domain.LinkUpdate) (domain.Link, error)
+	DeleteLink(ctx context.Context, shortened domain.ShortURL) error
+	// Close releases connections of the client.
+	Close() error
+}
+
+type ListLinksRequest struct {
+	Filter domain.LinkFilter
+	// PageSize is chosen by the service if zero.
+	PageSize int
+	// PageToken is NextPageToken of the previous page, empty for the first page.
+	PageToken string
+}
+
+type LinkPage struct {
+	Links []domain.Link
+	// NextPageToken is empty on the last page.
+	NextPageToken string
+}
+
+const (
+	requestIDHeader      = "X-Request-Id"
+	idempotencyKeyHeader = "Idempotency-Key"
+
+	defaultMaxIdleConns = 100
+)
+
+// DefaultRetry is the retry policy of clients created without WithRetry.
+var DefaultRetry = resilience.RetryConfig{
+	MaxAttempts:    3,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+type options struct {
+	token        string
+	tlsConfig    *tls.Config
+	retry        resilience.RetryConfig
+	httpClient   *http.Client
+	maxIdleConns int
+	dialOptions  []grpc.DialOption
+	idempotency  bool
+}
+
+// Option configures the client.
+type Option func(*options)
+
+// WithToken makes the client send `Authorization: Bearer <token>`, e.g. an api key or JWT.
+func WithToken(token string) Option {
+	return func(o *options) {
+		o.token = token
+	}
+}
+
+// WithTLS makes the client use TLS with the given config, e.g. with a client certificate for mTLS.
+func WithTLS(cfg *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = cfg
+	}
+}
+
+// WithRetry sets the retry policy, MaxAttempts 1 disables retries.
+//
+// Only transient failures are retried: unavailable storage, network errors and idempotency
+// keys in progress. Reads and deletes are retried as is. Creations are retried only with
+// WithIdempotency, with the same generated Idempotency-Key, so the service replays the result
+// of the first attempt. Updates aren't retried.
+func WithRetry(cfg resilience.RetryConfig) Option {
+	return func(o *options) {
+		o.retry = cfg
+	}
+}
+
+// WithIdempotency tells the client that the service accepts idempotency keys (`idempotency.enabled`),
+// so creations can be retried. Without it a repeated creation could create a second link.
+func WithIdempotency() Option {
+	return func(o *options) {
+		o.idempotency = true
+	}
+}
+
+// WithHTTPClient makes HTTP client send requests by the given client, its transport
+// is used as is, so WithTLS and WithMaxIdleConns don't apply.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = client
+	}
+}
+
+// WithMaxIdleConns limits idle connections kept by HTTP client for reuse, 100 by default.
+// gRPC client multiplexes calls over a single connection.
+func WithMaxIdleConns(n int) Option {
+	return func(o *options) {
+		o.maxIdleConns = n
+	}
+}
+
+// WithDialOptions adds options of the gRPC connection, e.g. interceptors.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(o *options) {
+		o.dialOptions = append(o.dialOptions, opts...)
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		retry:        DefaultRetry,
+		maxIdleConns: defaultMaxIdleConns,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.retry.MaxAttempts = max(o.retry.MaxAttempts, 1)
+
+	return o
+}
+
+// createRetry is the retry policy of creations, which are repeated safely only with idempotency keys.
+func (o options) createRetry() resilience.RetryConfig {
+	if !o.idempotency {
+		return withoutRetries(o.retry)
+	}
+	return o.retry
+}
@@ -0,0 +1,330 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"ozon_task/domain"
+	"ozon_task/internal/grpc/grpcerr"
+	"ozon_task/pkg/http/responses"
+	"ozon_task/pkg/requestid"
+	"ozon_task/pkg/resilience"
+	urlshortenerv1 "ozon_task/protos/gen/go"
+	urlshortenerv2 "ozon_task/protos/gen/go/shortener/v2"
+)
+
+const validShortened = "AAAAAAAAAA"
+
+var fastRetry = resilience.RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, Multiplier: 1}
+
+func writeProblem(w http.ResponseWriter, status int, code domain.ErrorCode) {
+	w.Header().Set("Content-Type", responses.ProblemContentType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(responses.ErrorResponse{
+		Status:    status,
+		Detail:    "failed",
+		Code:      string(code),
+		RequestID: "req-1",
+	})
+}
+
+func TestHTTP_ErrorsMatchDomainErrors(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeProblem(w, http.StatusNotFound, domain.CodeNotFound)
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := NewHTTP(srv.URL)
+	require.NoError(t, err)
+
+	_, err = c.Resolve(context.Background(), validShortened)
+
+	require.ErrorIs(t, err, domain.ErrOriginalNotFound)
+	require.NotErrorIs(t, err, domain.ErrLinkExpired)
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, "req-1", apiErr.RequestID)
+}
+
+func TestHTTP_CreateRetriesWithSameIdempotencyKey(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu         sync.Mutex
+		keys       []string
+		requestIDs []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get(idempotencyKeyHeader))
+		requestIDs = append(requestIDs, r.Header.Get(requestIDHeader))
+		attempt := len(keys)
+		mu.Unlock()
+
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		if attempt == 1 {
+			writeProblem(w, http.StatusServiceUnavailable, domain.CodeStorageUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"shortened_url": validShortened})
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := NewHTTP(srv.URL, WithToken("secret"), WithRetry(fastRetry), WithIdempotency())
+	require.NoError(t, err)
+
+	shortened, err := c.Shorten(requestid.With(context.Background(), "trace-1"), "https://ozon.ru")
+
+	require.NoError(t, err)
+	require.Equal(t, validShortened, shortened)
+	require.Len(t, keys, 2)
+	require.NotEmpty(t, keys[0])
+	require.Equal(t, keys[0], keys[1])
+	require.Equal(t, []string{"trace-1", "trace-1"}, requestIDs)
+}
+
+func TestHTTP_CreateIsNotRetriedWithoutIdempotency(t *testing.T) {
+	t.Parallel()
+
+	keys := make(chan string, fastRetry.MaxAttempts)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys <- r.Header.Get(idempotencyKeyHeader)
+		writeProblem(w, http.StatusServiceUnavailable, domain.CodeStorageUnavailable)
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := NewHTTP(srv.URL, WithRetry(fastRetry))
+	require.NoError(t, err)
+
+	_, err = c.CreateLink(context.Background(), domain.Link{Original: "https://ozon.ru"})
+
+	require.ErrorIs(t, err, domain.ErrStorageUnavailable)
+	require.Len(t, keys, 1)
+	require.Empty(t, <-keys)
+}
+
+func TestHTTP_UpdateIsNotRetried(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu      sync.Mutex
+		patches []map[string]any
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPatch, r.Method)
+		assert.Equal(t, "/api/v2/links/"+validShortened, r.URL.Path)
+
+		var patch map[string]any
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&patch))
+		mu.Lock()
+		patches = append(patches, patch)
+		mu.Unlock()
+
+		writeProblem(w, http.StatusServiceUnavailable, domain.CodeStorageUnavailable)
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := NewHTTP(srv.URL, WithRetry(fastRetry))
+	require.NoError(t, err)
+
+	original := "https://ozon.ru/new"
+	noExpiration := time.Time{}
+	_, err = c.UpdateLink(context.Background(), validShortened, domain.LinkUpdate{
+		Original:  &original,
+		ExpiresAt: &noExpiration,
+	})
+
+	require.ErrorIs(t, err, domain.ErrStorageUnavailable)
+	require.Equal(t, []map[string]any{{"original_url": original, "expire_time": nil}}, patches)
+}
+
+func TestHTTP_ResponsesWithoutProblemDetails(t *testing.T) {
+	t.Parallel()
+
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		attempts.Add(1)
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := NewHTTP(srv.URL, WithRetry(fastRetry))
+	require.NoError(t, err)
+
+	_, err = c.GetLink(context.Background(), validShortened)
+
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, domain.CodeStorageUnavailable, apiErr.Code)
+	require.EqualValues(t, fastRetry.MaxAttempts, attempts.Load())
+}
+
+// recorder keeps metadata of calls to fake servers.
+type recorder struct {
+	mu       sync.Mutex
+	metadata []metadata.MD
+}
+
+func (r *recorder) record(ctx context.Context) int {
+	md, _ := metadata.FromIncomingContext(ctx)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metadata = append(r.metadata, md)
+	return len(r.metadata)
+}
+
+type fakeURLServer struct {
+	urlshortenerv1.UnimplementedURLShortenerServer
+	*recorder
+}
+
+func (s fakeURLServer) ResolveURL(
+	ctx context.Context,
+	_ *urlshortenerv1.ResolveURLRequest,
+) (*urlshortenerv1.ResolveURLResponse, error) {
+	s.record(ctx)
+	return nil, grpcerr.WithRequestID(grpcerr.Error(domain.ErrOriginalNotFound), "req-1")
+}
+
+type fakeLinkServer struct {
+	urlshortenerv2.UnimplementedLinkServiceServer
+	*recorder
+}
+
+func (s fakeLinkServer) CreateLink(
+	ctx context.Context,
+	req *urlshortenerv2.CreateLinkRequest,
+) (*urlshortenerv2.Link, error) {
+	if s.record(ctx) == 1 {
+		return nil, grpcerr.Error(domain.ErrStorageUnavailable)
+	}
+	return &urlshortenerv2.Link{
+		ShortenedUrl: validShortened,
+		OriginalUrl:  req.GetLink().GetOriginalUrl(),
+		Version:      1,
+	}, nil
+}
+
+func newGRPCServer(t *testing.T) (*recorder, string) {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	rec := &recorder{}
+	srv := grpc.NewServer()
+	urlshortenerv1.RegisterURLShortenerServer(srv, fakeURLServer{recorder: rec})
+	urlshortenerv2.RegisterLinkServiceServer(srv, fakeLinkServer{recorder: rec})
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	return rec, lis.Addr().String()
+}
+
+func TestGRPC_ErrorsMatchDomainErrors(t *testing.T) {
+	t.Parallel()
+	_, addr := newGRPCServer(t)
+
+	c, err := NewGRPC(addr, WithRetry(fastRetry))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+
+	_, err = c.Resolve(context.Background(), validShortened)
+
+	require.ErrorIs(t, err, domain.ErrOriginalNotFound)
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, "req-1", apiErr.RequestID)
+	require.Zero(t, apiErr.RetryAfter)
+}
+
+func TestGRPC_CreateRetriesWithSameIdempotencyKey(t *testing.T) {
+	t.Parallel()
+	fake, addr := newGRPCServer(t)
+
+	// RetryInfo of transient errors asks to wait a second, it's awaited despite the shorter backoff
+	c, err := NewGRPC(addr, WithToken("secret"), WithRetry(fastRetry), WithIdempotency())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+
+	started := time.Now()
+	link, err := c.CreateLink(requestid.With(context.Background(), "trace-1"), domain.Link{Original: "https://ozon.ru"})
+
+	require.NoError(t, err)
+	require.Equal(t, domain.Link{Original: "https://ozon.ru", Shortened: validShortened, Version: 1,
+		CreatedAt: link.CreatedAt}, link)
+	require.GreaterOrEqual(t, time.Since(started), domain.TransientRetryAfter)
+
+	require.Len(t, fake.metadata, 2)
+	for _, md := range fake.metadata {
+		require.Equal(t, []string{"trace-1"}, md.Get("x-request-id"))
+		require.Equal(t, []string{"Bearer secret"}, md.Get("authorization"))
+		require.Equal(t, fake.metadata[0].Get("idempotency-key"), md.Get("idempotency-key"))
+	}
+	require.Len(t, fake.metadata[0].Get("idempotency-key"), 1)
+}
+
+func TestFake(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	var c Client = NewFake()
+
+	shortened, err := c.Shorten(ctx, "finance.ozon.ru", "promo")
+	require.NoError(t, err)
+	again, err := c.Shorten(ctx, "https://finance.ozon.ru")
+	require.NoError(t, err)
+	require.Equal(t, shortened, again)
+
+	original, err := c.Resolve(ctx, shortened)
+	require.NoError(t, err)
+	require.Equal(t, "https://finance.ozon.ru", original)
+
+	_, err = c.Shorten(ctx, "https://ozon")
+	require.ErrorIs(t, err, domain.ErrInvalidOriginal)
+
+	updated := "https://fintech.ozon.ru"
+	link, err := c.UpdateLink(ctx, shortened, domain.LinkUpdate{Original: &updated})
+	require.NoError(t, err)
+	require.Equal(t, 2, link.Version)
+
+	page, err := c.ListLinks(ctx, ListLinksRequest{Filter: domain.LinkFilter{Tag: "promo"}})
+	require.NoError(t, err)
+	require.Len(t, page.Links, 1)
+	require.Equal(t, updated, page.Links[0].Original)
+
+	require.NoError(t, c.DeleteLink(ctx, shortened))
+	_, err = c.Resolve(ctx, shortened)
+	require.ErrorIs(t, err, domain.ErrOriginalNotFound)
+}
+
+func TestFake_Fail(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	fake := NewFake()
+
+	fake.Fail(domain.ErrStorageUnavailable)
+	_, err := fake.Shorten(ctx, "https://ozon.ru")
+	require.ErrorIs(t, err, domain.ErrStorageUnavailable)
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, domain.CodeStorageUnavailable, apiErr.Code)
+
+	fake.Fail(nil)
+	_, err = fake.Shorten(ctx, "https://ozon.ru")
+	require.NoError(t, err)
+}
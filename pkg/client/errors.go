@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"ozon_task/domain"
+	"ozon_task/pkg/requestid"
+	"ozon_task/pkg/resilience"
+	"time"
+)
+
+// Error is a failure reported by the service.
+type Error struct {
+	// Code is the stable code of the error, see domain.ErrorCode.
+	Code    domain.ErrorCode
+	Message string
+	// RequestID identifies the request in logs of the service.
+	RequestID string
+	// RetryAfter is the delay the service asked to retry the request after, zero if not given.
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Is matches the error with domain errors of its code, e.g. an error with domain.CodeNotFound
+// is both domain.ErrOriginalNotFound and domain.ErrShortenedNotFound.
+func (e *Error) Is(target error) bool {
+	code := domain.ErrorCodeOf(target)
+	return code == e.Code && code != domain.CodeInternal
+}
+
+// retryable reports whether the failed attempt may be repeated: transient errors of the service
+// and network failures. Errors of the context aren't retried.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return domain.IsTransient(apiErr.Code) || apiErr.Code == domain.CodeIdempotencyKeyInProgress
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// call runs attempts of the call until it succeeds, fails permanently or attempts run out.
+// The delay before a retry is at least RetryAfter of the error.
+func call(ctx context.Context, retry resilience.RetryConfig, attempt func(ctx context.Context) error) error {
+	if len(requestid.FromContext(ctx)) == 0 {
+		ctx = requestid.With(ctx, requestid.New())
+	}
+
+	for n := 1; ; n++ {
+		err := attempt(ctx)
+		if err == nil || n >= retry.MaxAttempts || !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		delay := retry.Backoff(n)
+		var apiErr *Error
+		if errors.As(err, &apiErr) {
+			delay = max(delay, apiErr.RetryAfter)
+		}
+		if !resilience.Sleep(ctx, delay) {
+			return err
+		}
+	}
+}
+
+// withoutRetries returns the policy for calls which can't be repeated safely.
+func withoutRetries(retry resilience.RetryConfig) resilience.RetryConfig {
+	retry.MaxAttempts = 1
+	return retry
+}
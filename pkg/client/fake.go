@@ -0,0 +1,157 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"ozon_task/domain"
+	"ozon_task/internal/repository/inmem"
+	"ozon_task/internal/usecases/service"
+	pkginmem "ozon_task/pkg/infra/kv/inmem"
+	"ozon_task/pkg/requestid"
+	"sync"
+)
+
+// fakePartitions is the number of partitions of the storage of Fake, it's small as tests store few links.
+const fakePartitions = 4
+
+// Fake is an in-memory Client for unit tests of consumers. It runs the service of the shortener
+// over in-memory storage without auth, so validation and errors are the same as of the service.
+// Original urls are limited by domain.DefaultMaxOriginalURLLength.
+type Fake struct {
+	service *service.URLService
+
+	mu  sync.Mutex
+	err error
+}
+
+func NewFake() *Fake {
+	return &Fake{
+		service: service.NewURLService(inmem.NewURLRepository(pkginmem.NewPartitionedKVStorage(fakePartitions))),
+	}
+}
+
+// Fail makes the following calls fail with the error, e.g. &Error{Code: domain.CodeStorageUnavailable}.
+// Domain errors are converted to Error with their code. Nil error restores normal work.
+func (f *Fake) Fail(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+func (f *Fake) Shorten(ctx context.Context, original domain.URL, tags ...domain.Tag) (domain.ShortURL, error) {
+	link, err := f.CreateLink(ctx, domain.Link{Original: original, Tags: tags})
+	if err != nil {
+		return "", err
+	}
+	return link.Shortened, nil
+}
+
+func (f *Fake) Resolve(ctx context.Context, shortened domain.ShortURL) (domain.URL, error) {
+	if err := f.check(ctx, shortened); err != nil {
+		return "", err
+	}
+
+	original, err := f.service.ResolveURL(ctx, shortened)
+	return original, fakeError(ctx, err)
+}
+
+func (f *Fake) CreateLink(ctx context.Context, spec domain.Link) (domain.Link, error) {
+	if err := f.failure(ctx); err != nil {
+		return domain.Link{}, err
+	}
+
+	spec.Original = domain.NormalizeURL(spec.Original)
+	if ok, err := domain.IsValidOriginalURL(spec.Original, domain.DefaultMaxOriginalURLLength); !ok {
+		return domain.Link{}, fakeError(ctx, err)
+	}
+
+	link, err := f.service.CreateLink(ctx, spec)
+	return link, fakeError(ctx, err)
+}
+
+func (f *Fake) GetLink(ctx context.Context, shortened domain.ShortURL) (domain.Link, error) {
+	if err := f.check(ctx, shortened); err != nil {
+		return domain.Link{}, err
+	}
+
+	link, err := f.service.GetLink(ctx, shortened)
+	return link, fakeError(ctx, err)
+}
+
+func (f *Fake) ListLinks(ctx context.Context, req ListLinksRequest) (LinkPage, error) {
+	if err := f.failure(ctx); err != nil {
+		return LinkPage{}, err
+	}
+
+	page, err := f.service.ListLinks(ctx, req.Filter, req.PageToken, req.PageSize)
+	if err != nil {
+		return LinkPage{}, fakeError(ctx, err)
+	}
+	return LinkPage{Links: page.Links, NextPageToken: page.NextCursor}, nil
+}
+
+func (f *Fake) UpdateLink(
+	ctx context.Context,
+	shortened domain.ShortURL,
+	update domain.LinkUpdate,
+) (domain.Link, error) {
+	if err := f.check(ctx, shortened); err != nil {
+		return domain.Link{}, err
+	}
+
+	if update.Original != nil {
+		original := domain.NormalizeURL(*update.Original)
+		if ok, err := domain.IsValidOriginalURL(original, domain.DefaultMaxOriginalURLLength); !ok {
+			return domain.Link{}, fakeError(ctx, err)
+		}
+		update.Original = &original
+	}
+	if update.Empty() {
+		return domain.Link{}, fakeError(ctx, domain.ErrInvalidLinkUpdate)
+	}
+
+	link, err := f.service.UpdateLink(ctx, shortened, update)
+	return link, fakeError(ctx, err)
+}
+
+func (f *Fake) DeleteLink(ctx context.Context, shortened domain.ShortURL) error {
+	if err := f.check(ctx, shortened); err != nil {
+		return err
+	}
+
+	return fakeError(ctx, f.service.DeleteLink(ctx, shortened))
+}
+
+func (f *Fake) Close() error {
+	return nil
+}
+
+// check returns the injected failure or the error of the invalid shortened url.
+func (f *Fake) check(ctx context.Context, shortened domain.ShortURL) error {
+	if err := f.failure(ctx); err != nil {
+		return err
+	}
+	if ok, err := domain.IsValidShortenedURL(shortened); !ok {
+		return fakeError(ctx, err)
+	}
+	return nil
+}
+
+func (f *Fake) failure(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return fakeError(ctx, f.err)
+}
+
+// fakeError converts the error to Error the same way the service reports it.
+func fakeError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return err
+	}
+	return &Error{Code: domain.ErrorCodeOf(err), Message: err.Error(), RequestID: requestid.FromContext(ctx)}
+}
@@ -0,0 +1,283 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ozon_task/domain"
+	"ozon_task/internal/grpc/grpcerr"
+	"ozon_task/pkg/requestid"
+	urlshortenerv1 "ozon_task/protos/gen/go"
+	urlshortenerv2 "ozon_task/protos/gen/go/shortener/v2"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type grpcClient struct {
+	conn  *grpc.ClientConn
+	urls  urlshortenerv1.URLShortenerClient
+	links urlshortenerv2.LinkServiceClient
+	opts  options
+}
+
+// NewGRPC creates client of the gRPC API at the target, e.g. `localhost:5050`.
+// The connection is established lazily by the first call.
+func NewGRPC(target string, opts ...Option) (Client, error) {
+	o := newOptions(opts)
+
+	creds := insecure.NewCredentials()
+	if o.tlsConfig != nil {
+		creds = credentials.NewTLS(o.tlsConfig)
+	}
+
+	dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	if len(o.token) != 0 {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(bearerCredentials{
+			token:  o.token,
+			secure: o.tlsConfig != nil,
+		}))
+	}
+	dialOpts = append(dialOpts, o.dialOptions...)
+
+	conn, err := grpc.NewClient(target, dialOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("NewGRPC: %w", err)
+	}
+
+	return &grpcClient{
+		conn:  conn,
+		urls:  urlshortenerv1.NewURLShortenerClient(conn),
+		links: urlshortenerv2.NewLinkServiceClient(conn),
+		opts:  o,
+	}, nil
+}
+
+func (c *grpcClient) Shorten(ctx context.Context, original domain.URL, tags ...domain.Tag) (domain.ShortURL, error) {
+	var res *urlshortenerv1.ShortenURLResponse
+	err := c.create(ctx, func(ctx context.Context) (err error) {
+		res, err = c.urls.ShortenURL(ctx, &urlshortenerv1.ShortenURLRequest{OriginalUrl: original, Tags: tags})
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("Shorten: %w", err)
+	}
+	return res.GetShortenedUrl(), nil
+}
+
+func (c *grpcClient) Resolve(ctx context.Context, shortened domain.ShortURL) (domain.URL, error) {
+	var res *urlshortenerv1.ResolveURLResponse
+	err := c.retried(ctx, func(ctx context.Context) (err error) {
+		res, err = c.urls.ResolveURL(ctx, &urlshortenerv1.ResolveURLRequest{ShortenedUrl: shortened})
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("Resolve: %w", err)
+	}
+	return res.GetOriginalUrl(), nil
+}
+
+func (c *grpcClient) CreateLink(ctx context.Context, spec domain.Link) (domain.Link, error) {
+	req := &urlshortenerv2.CreateLinkRequest{Link: &urlshortenerv2.Link{
+		OriginalUrl: spec.Original,
+		Tags:        spec.Tags,
+	}}
+	if !spec.ExpiresAt.IsZero() {
+		req.Link.ExpireTime = timestamppb.New(spec.ExpiresAt)
+	}
+
+	var res *urlshortenerv2.Link
+	err := c.create(ctx, func(ctx context.Context) (err error) {
+		res, err = c.links.CreateLink(ctx, req)
+		return err
+	})
+	if err != nil {
+		return domain.Link{}, fmt.Errorf("CreateLink: %w", err)
+	}
+	return newLinkV2(res), nil
+}
+
+func (c *grpcClient) GetLink(ctx context.Context, shortened domain.ShortURL) (domain.Link, error) {
+	var res *urlshortenerv2.Link
+	err := c.retried(ctx, func(ctx context.Context) (err error) {
+		res, err = c.links.GetLink(ctx, &urlshortenerv2.GetLinkRequest{ShortenedUrl: shortened})
+		return err
+	})
+	if err != nil {
+		return domain.Link{}, fmt.Errorf("GetLink: %w", err)
+	}
+	return newLinkV2(res), nil
+}
+
+func (c *grpcClient) ListLinks(ctx context.Context, req ListLinksRequest) (LinkPage, error) {
+	listReq := &urlshortenerv2.ListLinksRequest{
+		OwnerId:   req.Filter.OwnerID,
+		Host:      req.Filter.Host,
+		Tag:       req.Filter.Tag,
+		Query:     req.Filter.Search,
+		PageSize:  int32(req.PageSize),
+		PageToken: req.PageToken,
+	}
+	if !req.Filter.CreatedAfter.IsZero() {
+		listReq.CreatedAfter = timestamppb.New(req.Filter.CreatedAfter)
+	}
+	if !req.Filter.CreatedBefore.IsZero() {
+		listReq.CreatedBefore = timestamppb.New(req.Filter.CreatedBefore)
+	}
+
+	var res *urlshortenerv2.ListLinksResponse
+	err := c.retried(ctx, func(ctx context.Context) (err error) {
+		res, err = c.links.ListLinks(ctx, listReq)
+		return err
+	})
+	if err != nil {
+		return LinkPage{}, fmt.Errorf("ListLinks: %w", err)
+	}
+
+	page := LinkPage{Links: make([]domain.Link, 0, len(res.GetLinks())), NextPageToken: res.GetNextPageToken()}
+	for _, link := range res.GetLinks() {
+		page.Links = append(page.Links, newLinkV2(link))
+	}
+	return page, nil
+}
+
+func (c *grpcClient) UpdateLink(
+	ctx context.Context,
+	shortened domain.ShortURL,
+	update domain.LinkUpdate,
+) (domain.Link, error) {
+	req := &urlshortenerv2.UpdateLinkRequest{
+		Link:       &urlshortenerv2.Link{ShortenedUrl: shortened},
+		UpdateMask: &fieldmaskpb.FieldMask{},
+	}
+	if update.Original != nil {
+		req.Link.OriginalUrl = *update.Original
+		req.UpdateMask.Paths = append(req.UpdateMask.Paths, "original_url")
+	}
+	if update.Tags != nil {
+		req.Link.Tags = *update.Tags
+		req.UpdateMask.Paths = append(req.UpdateMask.Paths, "tags")
+	}
+	if update.ExpiresAt != nil {
+		// expire_time in the mask without value removes expiration
+		if !update.ExpiresAt.IsZero() {
+			req.Link.ExpireTime = timestamppb.New(*update.ExpiresAt)
+		}
+		req.UpdateMask.Paths = append(req.UpdateMask.Paths, "expire_time")
+	}
+
+	var res *urlshortenerv2.Link
+	err := call(ctx, withoutRetries(c.opts.retry), func(ctx context.Context) (err error) {
+		res, err = c.links.UpdateLink(outgoingContext(ctx, ""), req)
+		return grpcError(err)
+	})
+	if err != nil {
+		return domain.Link{}, fmt.Errorf("UpdateLink: %w", err)
+	}
+	return newLinkV2(res), nil
+}
+
+func (c *grpcClient) DeleteLink(ctx context.Context, shortened domain.ShortURL) error {
+	err := c.retried(ctx, func(ctx context.Context) error {
+		_, err := c.links.DeleteLink(ctx, &urlshortenerv2.DeleteLinkRequest{ShortenedUrl: shortened})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("DeleteLink: %w", err)
+	}
+	return nil
+}
+
+func (c *grpcClient) Close() error {
+	return c.conn.Close()
+}
+
+// retried runs the idempotent call with retries.
+func (c *grpcClient) retried(ctx context.Context, fn func(ctx context.Context) error) error {
+	return call(ctx, c.opts.retry, func(ctx context.Context) error {
+		return grpcError(fn(outgoingContext(ctx, "")))
+	})
+}
+
+// create runs the creating call, it's retried only if the service accepts idempotency keys
+// and all attempts have the same key then.
+func (c *grpcClient) create(ctx context.Context, fn func(ctx context.Context) error) error {
+	retry := c.opts.createRetry()
+	key := ""
+	if retry.MaxAttempts > 1 {
+		key = requestid.New()
+	}
+
+	return call(ctx, retry, func(ctx context.Context) error {
+		return grpcError(fn(outgoingContext(ctx, key)))
+	})
+}
+
+// outgoingContext adds id of the request and the idempotency key if set to metadata of the call.
+func outgoingContext(ctx context.Context, idempotencyKey string) context.Context {
+	pairs := []string{strings.ToLower(requestIDHeader), requestid.FromContext(ctx)}
+	if len(idempotencyKey) != 0 {
+		pairs = append(pairs, strings.ToLower(idempotencyKeyHeader), idempotencyKey)
+	}
+	return metadata.AppendToOutgoingContext(ctx, pairs...)
+}
+
+// grpcError converts status errors to Error, errors of the context are returned as is.
+func grpcError(err error) error {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	apiErr := &Error{
+		Code:      grpcerr.Reason(st),
+		Message:   st.Message(),
+		RequestID: grpcerr.RequestID(st),
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			apiErr.RetryAfter = info.GetRetryDelay().AsDuration()
+		}
+	}
+	return apiErr
+}
+
+type bearerCredentials struct {
+	token  string
+	secure bool
+}
+
+func (c bearerCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + c.token}, nil
+}
+
+func (c bearerCredentials) RequireTransportSecurity() bool {
+	return c.secure
+}
+
+func newLinkV2(link *urlshortenerv2.Link) domain.Link {
+	res := domain.Link{
+		Original:  link.GetOriginalUrl(),
+		Shortened: link.GetShortenedUrl(),
+		OwnerID:   link.GetOwnerId(),
+		Tags:      link.GetTags(),
+		Version:   int(link.GetVersion()),
+		Clicks:    link.GetClickCount(),
+		CreatedAt: link.GetCreateTime().AsTime(),
+	}
+	if link.GetExpireTime() != nil {
+		res.ExpiresAt = link.GetExpireTime().AsTime()
+	}
+	return res
+}
@@ -0,0 +1,322 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"ozon_task/domain"
+	"ozon_task/pkg/http/responses"
+	"ozon_task/pkg/requestid"
+	urlshortenerv1 "ozon_task/protos/gen/go"
+	urlshortenerv2 "ozon_task/protos/gen/go/shortener/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type httpClient struct {
+	client  *http.Client
+	baseURL string
+	opts    options
+}
+
+// NewHTTP creates client of the HTTP API. Address is a base URL, e.g. `https://sho.rt`,
+// or `host:port` with the scheme chosen by WithTLS.
+func NewHTTP(address string, opts ...Option) (Client, error) {
+	o := newOptions(opts)
+
+	baseURL := strings.TrimSuffix(address, "/")
+	if !strings.Contains(baseURL, "://") {
+		scheme := "http"
+		if o.tlsConfig != nil {
+			scheme = "https"
+		}
+		baseURL = scheme + "://" + baseURL
+	}
+	if _, err := url.Parse(baseURL); err != nil {
+		return nil, fmt.Errorf("NewHTTP: invalid address: %w", err)
+	}
+
+	client := o.httpClient
+	if client == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = o.tlsConfig
+		transport.MaxIdleConns = o.maxIdleConns
+		transport.MaxIdleConnsPerHost = o.maxIdleConns
+		client = &http.Client{Transport: transport}
+	}
+
+	return &httpClient{client: client, baseURL: baseURL, opts: o}, nil
+}
+
+func (c *httpClient) Shorten(ctx context.Context, original domain.URL, tags ...domain.Tag) (domain.ShortURL, error) {
+	var res urlshortenerv1.ShortenURLResponse
+	err := c.create(ctx, "/api/v1/shorten", &urlshortenerv1.ShortenURLRequest{OriginalUrl: original, Tags: tags}, &res)
+	if err != nil {
+		return "", fmt.Errorf("Shorten: %w", err)
+	}
+	return res.GetShortenedUrl(), nil
+}
+
+func (c *httpClient) Resolve(ctx context.Context, shortened domain.ShortURL) (domain.URL, error) {
+	var res urlshortenerv1.ResolveURLResponse
+	err := c.retried(ctx, http.MethodGet, "/api/v1/resolve/"+url.PathEscape(shortened), nil, &res)
+	if err != nil {
+		return "", fmt.Errorf("Resolve: %w", err)
+	}
+	return res.GetOriginalUrl(), nil
+}
+
+func (c *httpClient) CreateLink(ctx context.Context, spec domain.Link) (domain.Link, error) {
+	// the body is the link of CreateLinkRequest
+	req := &urlshortenerv2.Link{OriginalUrl: spec.Original, Tags: spec.Tags}
+	if !spec.ExpiresAt.IsZero() {
+		req.ExpireTime = timestamppb.New(spec.ExpiresAt)
+	}
+
+	var res urlshortenerv2.Link
+	if err := c.create(ctx, "/api/v2/links", req, &res); err != nil {
+		return domain.Link{}, fmt.Errorf("CreateLink: %w", err)
+	}
+	return newLinkV2(&res), nil
+}
+
+func (c *httpClient) GetLink(ctx context.Context, shortened domain.ShortURL) (domain.Link, error) {
+	var res urlshortenerv2.Link
+	if err := c.retried(ctx, http.MethodGet, "/api/v2/links/"+url.PathEscape(shortened), nil, &res); err != nil {
+		return domain.Link{}, fmt.Errorf("GetLink: %w", err)
+	}
+	return newLinkV2(&res), nil
+}
+
+func (c *httpClient) ListLinks(ctx context.Context, req ListLinksRequest) (LinkPage, error) {
+	query := url.Values{}
+	setQuery(query, "owner_id", req.Filter.OwnerID)
+	setQuery(query, "host", req.Filter.Host)
+	setQuery(query, "tag", req.Filter.Tag)
+	setQuery(query, "query", req.Filter.Search)
+	setQuery(query, "page_token", req.PageToken)
+	if !req.Filter.CreatedAfter.IsZero() {
+		query.Set("created_after", req.Filter.CreatedAfter.Format(time.RFC3339Nano))
+	}
+	if !req.Filter.CreatedBefore.IsZero() {
+		query.Set("created_before", req.Filter.CreatedBefore.Format(time.RFC3339Nano))
+	}
+	if req.PageSize > 0 {
+		query.Set("page_size", strconv.Itoa(req.PageSize))
+	}
+
+	path := "/api/v2/links"
+	if len(query) != 0 {
+		path += "?" + query.Encode()
+	}
+
+	var res urlshortenerv2.ListLinksResponse
+	if err := c.retried(ctx, http.MethodGet, path, nil, &res); err != nil {
+		return LinkPage{}, fmt.Errorf("ListLinks: %w", err)
+	}
+
+	page := LinkPage{Links: make([]domain.Link, 0, len(res.GetLinks())), NextPageToken: res.GetNextPageToken()}
+	for _, link := range res.GetLinks() {
+		page.Links = append(page.Links, newLinkV2(link))
+	}
+	return page, nil
+}
+
+func (c *httpClient) UpdateLink(
+	ctx context.Context,
+	shortened domain.ShortURL,
+	update domain.LinkUpdate,
+) (domain.Link, error) {
+	// fields of the body are the update mask, null expire_time removes expiration
+	patch := make(map[string]any, 3)
+	if update.Original != nil {
+		patch["original_url"] = *update.Original
+	}
+	if update.Tags != nil {
+		patch["tags"] = *update.Tags
+	}
+	if update.ExpiresAt != nil {
+		patch["expire_time"] = nil
+		if !update.ExpiresAt.IsZero() {
+			patch["expire_time"] = update.ExpiresAt.UTC().Format(time.RFC3339Nano)
+		}
+	}
+
+	var res urlshortenerv2.Link
+	err := call(ctx, withoutRetries(c.opts.retry), func(ctx context.Context) error {
+		return c.do(ctx, http.MethodPatch, "/api/v2/links/"+url.PathEscape(shortened), patch, nil, &res)
+	})
+	if err != nil {
+		return domain.Link{}, fmt.Errorf("UpdateLink: %w", err)
+	}
+	return newLinkV2(&res), nil
+}
+
+func (c *httpClient) DeleteLink(ctx context.Context, shortened domain.ShortURL) error {
+	if err := c.retried(ctx, http.MethodDelete, "/api/v2/links/"+url.PathEscape(shortened), nil, nil); err != nil {
+		return fmt.Errorf("DeleteLink: %w", err)
+	}
+	return nil
+}
+
+func (c *httpClient) Close() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+// retried sends the idempotent request with retries.
+func (c *httpClient) retried(ctx context.Context, method, path string, payload any, result proto.Message) error {
+	return call(ctx, c.opts.retry, func(ctx context.Context) error {
+		return c.do(ctx, method, path, payload, nil, result)
+	})
+}
+
+// create sends POST request, it's retried only if the service accepts idempotency keys
+// and all attempts have the same key then.
+func (c *httpClient) create(ctx context.Context, path string, payload, result proto.Message) error {
+	retry := c.opts.createRetry()
+	header := http.Header{}
+	if retry.MaxAttempts > 1 {
+		header.Set(idempotencyKeyHeader, requestid.New())
+	}
+
+	return call(ctx, retry, func(ctx context.Context) error {
+		return c.do(ctx, http.MethodPost, path, payload, header, result)
+	})
+}
+
+// do sends the request with the payload, messages are encoded like the gateway does, other values as JSON.
+func (c *httpClient) do(
+	ctx context.Context,
+	method, path string,
+	payload any,
+	header http.Header,
+	result proto.Message,
+) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := marshalPayload(payload)
+		if err != nil {
+			return fmt.Errorf("marshal request failed: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("create request failed: %w", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if len(c.opts.token) != 0 {
+		req.Header.Set("Authorization", "Bearer "+c.opts.token)
+	}
+	req.Header.Set(requestIDHeader, requestid.FromContext(ctx))
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return httpError(res)
+	}
+	if result == nil {
+		return nil
+	}
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("read response failed: %w", err)
+	}
+	if err = (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, result); err != nil {
+		return fmt.Errorf("decode response failed: %w", err)
+	}
+	return nil
+}
+
+func marshalPayload(payload any) ([]byte, error) {
+	if msg, ok := payload.(proto.Message); ok {
+		return protojson.MarshalOptions{UseProtoNames: true}.Marshal(msg)
+	}
+	return json.Marshal(payload)
+}
+
+// httpError reads problem details of the failed response. Responses without them, e.g. of proxies,
+// get the code closest to their status.
+func httpError(res *http.Response) error {
+	const maxBody = 64 << 10
+	data, _ := io.ReadAll(io.LimitReader(res.Body, maxBody))
+
+	apiErr := &Error{RequestID: res.Header.Get(requestIDHeader)}
+	if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mediaType == responses.ProblemContentType || mediaType == "application/json" {
+		var problem responses.ErrorResponse
+		if err := json.Unmarshal(data, &problem); err == nil && len(problem.Code) != 0 {
+			apiErr.Code, apiErr.Message = domain.ErrorCode(problem.Code), problem.Detail
+			if len(problem.RequestID) != 0 {
+				apiErr.RequestID = problem.RequestID
+			}
+			return apiErr
+		}
+	}
+
+	apiErr.Code = statusCode(res.StatusCode)
+	apiErr.Message = strings.TrimSpace(string(data))
+	if len(apiErr.Message) == 0 {
+		apiErr.Message = res.Status
+	}
+	return apiErr
+}
+
+func statusCode(status int) domain.ErrorCode {
+	switch status {
+	case http.StatusBadRequest:
+		return domain.CodeInvalidRequest
+	case http.StatusUnauthorized:
+		return domain.CodeUnauthenticated
+	case http.StatusForbidden:
+		return domain.CodePermissionDenied
+	case http.StatusNotFound:
+		return domain.CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return domain.CodeMethodNotAllowed
+	case http.StatusRequestTimeout:
+		return domain.CodeTimeout
+	case http.StatusConflict:
+		return domain.CodeConflict
+	case http.StatusGone:
+		return domain.CodeExpired
+	case http.StatusTooManyRequests:
+		return domain.CodeRateLimited
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return domain.CodeStorageUnavailable
+	default:
+		return domain.CodeInternal
+	}
+}
+
+func setQuery(query url.Values, key, value string) {
+	if len(value) != 0 {
+		query.Set(key, value)
+	}
+}
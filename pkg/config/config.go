@@ -1,23 +1,33 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"os"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
-func MustLoad(configEnv string, cfg any) {
-	configPath := os.Getenv(configEnv)
-	if configPath == "" {
-		log.Fatal("Config path is not set")
+// Load reads the config file at path into cfg, environment variables override its values.
+func Load(path string, cfg any) error {
+	if path == "" {
+		return errors.New("config path is not set")
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return fmt.Errorf("config file does not exist by this path: %s", path)
 	}
 
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		log.Fatalf("config file does not exist by this path: %s", configPath)
+	if err := cleanenv.ReadConfig(path, cfg); err != nil {
+		return fmt.Errorf("error reading config: %w", err)
 	}
 
-	if err := cleanenv.ReadConfig(configPath, cfg); err != nil {
-		log.Fatalf("error reading config: %s", err)
+	return nil
+}
+
+func MustLoad(configEnv string, cfg any) {
+	if err := Load(os.Getenv(configEnv), cfg); err != nil {
+		log.Fatal(err)
 	}
 }
@@ -0,0 +1,28 @@
+package config
+
+import "encoding/json"
+
+const redacted = "[REDACTED]"
+
+// Secret is a config value which must not leak to logs.
+type Secret string
+
+func (s Secret) String() string {
+	if len(s) == 0 {
+		return ""
+	}
+	return redacted
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+func (s Secret) MarshalYAML() (any, error) {
+	return s.String(), nil
+}
+
+// Value returns raw secret.
+func (s Secret) Value() string {
+	return string(s)
+}
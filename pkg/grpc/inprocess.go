@@ -0,0 +1,45 @@
+package grpc
+
+import (
+	"context"
+	"net"
+
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const inProcessBufferSize = 256 << 10
+
+// InProcessAddr is the remote address of connections accepted by InProcessListener.
+// Servers can tell calls of components of the same process, e.g. of a gateway, by it.
+type InProcessAddr struct{}
+
+func (InProcessAddr) Network() string { return "inprocess" }
+func (InProcessAddr) String() string  { return "inprocess" }
+
+// InProcessListener accepts connections dialed within the process without network.
+type InProcessListener struct {
+	*bufconn.Listener
+}
+
+func NewInProcessListener() InProcessListener {
+	return InProcessListener{Listener: bufconn.Listen(inProcessBufferSize)}
+}
+
+func (l InProcessListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return inProcessConn{Conn: conn}, nil
+}
+
+// Dial connects to the listener, it's used as a context dialer of gRPC clients.
+func (l InProcessListener) Dial(ctx context.Context, _ string) (net.Conn, error) {
+	return l.DialContext(ctx)
+}
+
+type inProcessConn struct {
+	net.Conn
+}
+
+func (inProcessConn) RemoteAddr() net.Addr { return InProcessAddr{} }
@@ -1,11 +1,14 @@
 package handlers
 
 import (
-	"errors"
+	"bytes"
+	"encoding/json"
 	"log/slog"
+	"math"
 	"net/http"
 	pkgmiddleware "ozon_task/pkg/http/middleware"
 	"ozon_task/pkg/http/responses"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -45,16 +48,53 @@ func Converter(h Handler) http.HandlerFunc {
 }
 
 func writeResponse(w http.ResponseWriter, r *http.Request, response responses.Response) {
+	if response.StatusCode() == http.StatusNoContent {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if problem, ok := response.(*responses.ErrorResponse); ok {
+		WriteProblem(w, r, problem)
+		return
+	}
+
 	render.Status(r, response.StatusCode())
 	render.JSON(w, r, response.GetPayload())
 }
 
+// WriteProblem writes the problem as application/problem+json identifying the request.
+func WriteProblem(w http.ResponseWriter, r *http.Request, problem *responses.ErrorResponse) {
+	problem.WithRequest(r.URL.Path, middleware.GetReqID(r.Context()))
+	if retryAfter := problem.RetryAfter(); retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	}
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(true)
+	if err := enc.Encode(problem); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", responses.ProblemContentType)
+	w.WriteHeader(problem.StatusCode())
+	_, _ = w.Write(buf.Bytes())
+}
+
 func DecodeRequest(r *http.Request, v interface{}) error {
 	return render.Decode(r, v)
 }
 
 type RouterOption func(chi.Router)
 
+// WithRoute mounts options as a subrouter on the pattern, e.g. a version of the api.
+func WithRoute(pattern string, options ...RouterOption) RouterOption {
+	return func(r chi.Router) {
+		r.Route(pattern, RouterOptions(options...))
+	}
+}
+
 func RouterOptions(options ...RouterOption) func(chi.Router) {
 	return func(r chi.Router) {
 		for _, option := range options {
@@ -63,11 +103,39 @@ func RouterOptions(options ...RouterOption) func(chi.Router) {
 	}
 }
 
-func WithHealthHandler() RouterOption {
+// HealthCheck reports state of a dependency of the service.
+type HealthCheck interface {
+	Name() string
+	// Health returns state of the dependency and whether it's usable.
+	Health() (state string, healthy bool)
+}
+
+// HealthResponse lists states of dependencies, status is DEGRADED if any of them is unhealthy.
+type HealthResponse struct {
+	Status       string            `json:"status"`
+	Dependencies map[string]string `json:"dependencies"`
+}
+
+// WithHealthHandler serves liveness of the service. Unhealthy dependencies don't fail the check,
+// as the service still answers with errors of them, but they are reported in the response.
+func WithHealthHandler(checks ...HealthCheck) RouterOption {
 	return func(r chi.Router) {
 		r.Mount("/health", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			render.Status(r, http.StatusOK)
-			render.PlainText(w, r, "OK")
+			if len(checks) == 0 {
+				render.PlainText(w, r, "OK")
+				return
+			}
+
+			res := HealthResponse{Status: "OK", Dependencies: make(map[string]string, len(checks))}
+			for _, check := range checks {
+				state, healthy := check.Health()
+				res.Dependencies[check.Name()] = state
+				if !healthy {
+					res.Status = "DEGRADED"
+				}
+			}
+			render.JSON(w, r, res)
 		}))
 	}
 }
@@ -80,16 +148,30 @@ func WithSwagger() RouterOption {
 	}
 }
 
-func WithErrHandlers() RouterOption {
+// WithOpenAPI serves the OpenAPI document as is.
+func WithOpenAPI(pattern string, doc []byte) RouterOption {
+	return func(r chi.Router) {
+		r.Get(pattern, func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(doc)
+		})
+	}
+}
+
+// WithMount serves patterns by the handler, it sees full path of the request.
+func WithMount(handler http.Handler, patterns ...string) RouterOption {
+	return func(r chi.Router) {
+		for _, pattern := range patterns {
+			r.Handle(pattern, handler)
+		}
+	}
+}
+
+// WithErrHandlers serves requests to unknown paths and with unsupported methods.
+func WithErrHandlers(notFound, methodNotAllowed Handler) RouterOption {
 	return func(r chi.Router) {
-		r.NotFound(Converter(
-			func(r *http.Request) responses.Response {
-				return responses.NotFound(errors.New("no such path"))
-			}))
-		r.MethodNotAllowed(Converter(
-			func(r *http.Request) responses.Response {
-				return responses.MethodNotAllowed(errors.New("this method is not allowed"))
-			}))
+		r.NotFound(Converter(notFound))
+		r.MethodNotAllowed(Converter(methodNotAllowed))
 	}
 }
 
@@ -111,8 +193,19 @@ func WithProfilerHandlers() RouterOption {
 	}
 }
 
+// WithRequestID takes id of the request from X-Request-Id header or generates it
+// and returns it in the same header of the response.
 func WithRequestID() RouterOption {
 	return func(r chi.Router) {
 		r.Use(middleware.RequestID)
+		r.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				id := middleware.GetReqID(r.Context())
+				w.Header().Set(middleware.RequestIDHeader, id)
+				// generated id is passed to proxied services, e.g. to the gRPC gateway
+				r.Header.Set(middleware.RequestIDHeader, id)
+				next.ServeHTTP(w, r)
+			})
+		})
 	}
 }
@@ -1,6 +1,9 @@
 package responses
 
-import "net/http"
+import (
+	"net/http"
+	"time"
+)
 
 type Response interface {
 	StatusCode() int
@@ -27,60 +30,96 @@ func OK(payload any) *BasicResponse {
 	}
 }
 
+func Created(payload any) *BasicResponse {
+	return &BasicResponse{
+		statusCode: http.StatusCreated,
+		Payload:    payload,
+	}
+}
+
+// NoContent is written without body.
+func NoContent() *BasicResponse {
+	return &BasicResponse{
+		statusCode: http.StatusNoContent,
+	}
+}
+
+// ProblemContentType is the media type of ErrorResponse.
+const ProblemContentType = "application/problem+json"
+
+// ErrorResponse is a problem details object of RFC 7807 extended with a machine-readable code
+// of the error and id of the request.
 type ErrorResponse struct {
-	Message    string `json:"message"`
-	err        error
-	statusCode int
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail"`
+	Instance string `json:"instance,omitempty"`
+	// Code is a stable code of the error, clients should rely on it instead of Detail.
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+	err       error
+	// retryAfter is sent in Retry-After header
+	retryAfter time.Duration
 }
 
 func (r ErrorResponse) StatusCode() int {
-	return r.statusCode
+	return r.Status
 }
 
 func (r ErrorResponse) GetPayload() any {
 	return r
 }
 
-func BadRequest(err error) *ErrorResponse {
-	return &ErrorResponse{
-		statusCode: http.StatusBadRequest,
-		Message:    err.Error(),
-		err:        err,
-	}
+// RetryAfter returns delay the request may be retried after, zero if it shouldn't be retried as is.
+func (r ErrorResponse) RetryAfter() time.Duration {
+	return r.retryAfter
 }
 
-func NotFound(err error) *ErrorResponse {
-	return &ErrorResponse{
-		statusCode: http.StatusNotFound,
-		Message:    err.Error(),
-		err:        err,
+// WithRequest identifies the request the problem occurred in, empty id keeps the current one.
+func (r *ErrorResponse) WithRequest(instance, requestID string) *ErrorResponse {
+	r.Instance = instance
+	if len(requestID) != 0 {
+		r.RequestID = requestID
 	}
+	return r
 }
 
-func MethodNotAllowed(err error) *ErrorResponse {
+// Error builds problem with detail taken from message of the error.
+func Error(status int, code string, err error) *ErrorResponse {
 	return &ErrorResponse{
-		statusCode: http.StatusMethodNotAllowed,
-		Message:    err.Error(),
-		err:        err,
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: err.Error(),
+		Code:   code,
+		err:    err,
 	}
 }
 
 const InternalError = "Internal server error"
 
-func Unknown(err error) *ErrorResponse {
-	return &ErrorResponse{
-		statusCode: http.StatusInternalServerError,
-		Message:    InternalError,
-		err:        err,
-	}
+// Internal hides details of the error, clients can report id of the request instead.
+func Internal(code string, err error) *ErrorResponse {
+	res := Error(http.StatusInternalServerError, code, err)
+	res.Detail = InternalError
+	return res
 }
 
 const TimeoutError = "Request timeout"
 
-func RequestTimeout(err error) *ErrorResponse {
-	return &ErrorResponse{
-		statusCode: http.StatusRequestTimeout,
-		Message:    TimeoutError,
-		err:        err,
-	}
+func RequestTimeout(code string, err error) *ErrorResponse {
+	res := Error(http.StatusRequestTimeout, code, err)
+	res.Detail = TimeoutError
+	return res
+}
+
+const UnavailableError = "Service temporarily unavailable"
+
+// ServiceUnavailable hides details of the error and asks to retry the request after the delay.
+func ServiceUnavailable(code string, err error, retryAfter time.Duration) *ErrorResponse {
+	res := Error(http.StatusServiceUnavailable, code, err)
+	res.Detail = UnavailableError
+	res.retryAfter = retryAfter
+	return res
 }
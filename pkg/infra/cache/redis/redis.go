@@ -3,10 +3,14 @@ package redis
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"net"
 	"ozon_task/pkg/infra/cache"
 	pkglog "ozon_task/pkg/log"
+	"ozon_task/pkg/resilience"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -23,7 +27,7 @@ func NewRedisClient(cfg Config) (*redis.Client, error) {
 	address := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
 	rdb := redis.NewClient(&redis.Options{
 		Addr:         address,
-		Password:     cfg.Password,
+		Password:     cfg.Password.Value(),
 		WriteTimeout: cfg.WriteTimeout,
 		ReadTimeout:  cfg.ReadTimeout,
 		DB:           0,
@@ -105,8 +109,27 @@ func (r *Redis) Delete(ctx context.Context, keys ...string) error {
 	return nil
 }
 
+// ShutdownClient closes connections of the client. Unlike client.Shutdown it doesn't stop the redis server.
 func ShutdownClient(client *redis.Client) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	_ = client.Shutdown(ctx)
+	_ = client.Close()
+}
+
+// Classify reports network failures of redis for resilience policies. Misses and malformed
+// values are permanent errors, so they neither are retried nor open the breaker.
+// Timeouts are failures: the policy ignores them only when the context of the caller is done.
+func Classify(err error) resilience.Class {
+	if errors.Is(err, redis.Nil) || errors.Is(err, context.Canceled) {
+		return resilience.Permanent
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, redis.ErrClosed) {
+		return resilience.Failure
+	}
+
+	return resilience.Permanent
 }
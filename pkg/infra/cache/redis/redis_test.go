@@ -0,0 +1,33 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+
+	"ozon_task/pkg/resilience"
+)
+
+func TestClassify(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		err   error
+		class resilience.Class
+	}{
+		{name: "miss", err: redis.Nil, class: resilience.Permanent},
+		{name: "canceled", err: fmt.Errorf("get: %w", context.Canceled), class: resilience.Permanent},
+		{name: "deadline", err: fmt.Errorf("get: %w", context.DeadlineExceeded), class: resilience.Failure},
+		{name: "broken connection", err: fmt.Errorf("read: %w", io.EOF), class: resilience.Failure},
+		{name: "closed client", err: redis.ErrClosed, class: resilience.Failure},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.class, Classify(tt.err), tt.name)
+	}
+}
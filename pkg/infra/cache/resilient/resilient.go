@@ -0,0 +1,41 @@
+// Package resilient decorates cache with retries and circuit breaker, so unavailable cache
+// is skipped instead of slowing down every request to its timeout.
+package resilient
+
+import (
+	"context"
+	"ozon_task/pkg/infra/cache"
+	"ozon_task/pkg/resilience"
+	"time"
+)
+
+type Cache struct {
+	cache  cache.Cache
+	policy *resilience.Policy
+}
+
+func New(cache cache.Cache, policy *resilience.Policy) cache.Cache {
+	return &Cache{
+		cache:  cache,
+		policy: policy,
+	}
+}
+
+func (c *Cache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	return c.policy.Do(ctx, func(ctx context.Context) error {
+		return c.cache.Set(ctx, key, value, ttl)
+	})
+}
+
+// Get returns resilience.ErrCircuitOpen while the cache is unavailable, callers treat it as a miss.
+func (c *Cache) Get(ctx context.Context, key string, value interface{}) error {
+	return c.policy.Do(ctx, func(ctx context.Context) error {
+		return c.cache.Get(ctx, key, value)
+	})
+}
+
+func (c *Cache) Delete(ctx context.Context, keys ...string) error {
+	return c.policy.Do(ctx, func(ctx context.Context) error {
+		return c.cache.Delete(ctx, keys...)
+	})
+}
@@ -10,7 +10,7 @@ type PartitionedKVStorage struct {
 	numPartitions int
 }
 
-func NewPartitionedKVStorage(numPartitions int) kv.Storage {
+func NewPartitionedKVStorage(numPartitions int) kv.AtomicStorage {
 	partitions := make([]*Partition, numPartitions)
 	for i := range numPartitions {
 		partitions[i] = NewPartition()
@@ -38,3 +38,60 @@ func (ps *PartitionedKVStorage) Get(key string) (val string, ok bool) {
 	partition := ps.getPartition(key)
 	return partition.Get(key)
 }
+
+func (ps *PartitionedKVStorage) Delete(key string) {
+	partition := ps.getPartition(key)
+	partition.Delete(key)
+}
+
+func (ps *PartitionedKVStorage) SetIfAbsent(key, val string) bool {
+	partition := ps.getPartition(key)
+	return partition.SetIfAbsent(key, val)
+}
+
+func (ps *PartitionedKVStorage) Update(key string, fn func(val string) (string, bool)) bool {
+	partition := ps.getPartition(key)
+	return partition.Update(key, fn)
+}
+
+func (ps *PartitionedKVStorage) Compute(key string, fn func(val string, ok bool) (string, bool)) {
+	partition := ps.getPartition(key)
+	partition.Compute(key, fn)
+}
+
+func (ps *PartitionedKVStorage) GetAndDelete(key string) (val string, ok bool) {
+	partition := ps.getPartition(key)
+	return partition.GetAndDelete(key)
+}
+
+func (ps *PartitionedKVStorage) CompareAndDelete(key, val string) bool {
+	partition := ps.getPartition(key)
+	return partition.CompareAndDelete(key, val)
+}
+
+// Range walks partitions one by one holding the read lock of the current one.
+func (ps *PartitionedKVStorage) Range(fn func(key, val string) bool) {
+	for _, partition := range ps.partitions {
+		if !partition.Range(fn) {
+			return
+		}
+	}
+}
+
+// Snapshot copies partitions one by one, so it's consistent only if there are no concurrent writes.
+func (ps *PartitionedKVStorage) Snapshot() map[string]string {
+	pairs := make(map[string]string)
+	for _, partition := range ps.partitions {
+		partition.copyTo(pairs)
+	}
+	return pairs
+}
+
+func (ps *PartitionedKVStorage) Restore(pairs map[string]string) {
+	for _, partition := range ps.partitions {
+		partition.clear()
+	}
+	for key, val := range pairs {
+		ps.Set(key, val)
+	}
+}
@@ -3,27 +3,19 @@ package inmem
 import "sync"
 
 type Partition struct {
-	bucket        map[string]string
-	reverseBucket map[string]string
-	m             sync.RWMutex
+	bucket map[string]string
+	m      sync.RWMutex
 }
 
 func NewPartition() *Partition {
 	return &Partition{
-		bucket:        make(map[string]string),
-		reverseBucket: make(map[string]string),
+		bucket: make(map[string]string),
 	}
 }
 
 func (p *Partition) Set(key, val string) {
 	p.m.Lock()
-
-	if oldVal, exists := p.bucket[key]; exists {
-		delete(p.reverseBucket, oldVal)
-	}
-
 	p.bucket[key] = val
-	p.reverseBucket[val] = key
 	p.m.Unlock()
 }
 
@@ -33,3 +25,98 @@ func (p *Partition) Get(key string) (string, bool) {
 	p.m.RUnlock()
 	return val, ok
 }
+
+func (p *Partition) Delete(key string) {
+	p.m.Lock()
+	delete(p.bucket, key)
+	p.m.Unlock()
+}
+
+func (p *Partition) SetIfAbsent(key, val string) bool {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if _, exists := p.bucket[key]; exists {
+		return false
+	}
+
+	p.bucket[key] = val
+	return true
+}
+
+func (p *Partition) Update(key string, fn func(val string) (string, bool)) bool {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	oldVal, exists := p.bucket[key]
+	if !exists {
+		return false
+	}
+
+	if val, ok := fn(oldVal); ok {
+		p.bucket[key] = val
+	}
+	return true
+}
+
+func (p *Partition) Compute(key string, fn func(val string, ok bool) (string, bool)) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	oldVal, exists := p.bucket[key]
+	if val, ok := fn(oldVal, exists); ok {
+		p.bucket[key] = val
+	} else if exists {
+		delete(p.bucket, key)
+	}
+}
+
+func (p *Partition) GetAndDelete(key string) (string, bool) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	val, exists := p.bucket[key]
+	if exists {
+		delete(p.bucket, key)
+	}
+	return val, exists
+}
+
+func (p *Partition) CompareAndDelete(key, val string) bool {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if oldVal, exists := p.bucket[key]; !exists || oldVal != val {
+		return false
+	}
+
+	delete(p.bucket, key)
+	return true
+}
+
+// Range calls fn for pairs of the partition and reports whether fn asked to continue.
+func (p *Partition) Range(fn func(key, val string) bool) bool {
+	p.m.RLock()
+	defer p.m.RUnlock()
+
+	for key, val := range p.bucket {
+		if !fn(key, val) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Partition) copyTo(pairs map[string]string) {
+	p.m.RLock()
+	for key, val := range p.bucket {
+		pairs[key] = val
+	}
+	p.m.RUnlock()
+}
+
+func (p *Partition) clear() {
+	p.m.Lock()
+	clear(p.bucket)
+	p.m.Unlock()
+}
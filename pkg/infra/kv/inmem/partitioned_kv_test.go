@@ -1,10 +1,13 @@
 package inmem
 
 import (
+	"maps"
 	"math/rand/v2"
 	"runtime"
 	"sync"
 	"testing"
+
+	"ozon_task/pkg/infra/kv"
 )
 
 const TestsPartitionCount = 6
@@ -46,6 +49,95 @@ func TestPartitionedKVStorage_MissingKey(t *testing.T) {
 	}
 }
 
+func TestPartitionedKVStorage_Delete(t *testing.T) {
+	t.Parallel()
+	storage := NewPartitionedKVStorage(TestsPartitionCount)
+
+	storage.Set("key", "value")
+	storage.Delete("key")
+	storage.Delete("non-existent-key")
+
+	if _, ok := storage.Get("key"); ok {
+		t.Errorf("Expected deleted key to return false, but got true")
+	}
+}
+
+func TestPartitionedKVStorage_SnapshotRestore(t *testing.T) {
+	t.Parallel()
+	storage := NewPartitionedKVStorage(TestsPartitionCount).(kv.Snapshotter)
+	storage.(kv.Storage).Set("key1", "value1")
+	storage.(kv.Storage).Set("key2", "value2")
+
+	snapshot := storage.Snapshot()
+	if !maps.Equal(snapshot, map[string]string{"key1": "value1", "key2": "value2"}) {
+		t.Errorf("Unexpected snapshot %v", snapshot)
+	}
+
+	restored := NewPartitionedKVStorage(TestsPartitionCount)
+	restored.Set("stale", "value")
+	restored.(kv.Snapshotter).Restore(snapshot)
+
+	if _, ok := restored.Get("stale"); ok {
+		t.Errorf("Expected restore to drop existing keys")
+	}
+	for k, expectedVal := range snapshot {
+		if val, ok := restored.Get(k); !ok || val != expectedVal {
+			t.Errorf("Expected value %s for key %s, got %s", expectedVal, k, val)
+		}
+	}
+}
+
+func TestPartitionedKVStorage_AtomicOperations(t *testing.T) {
+	t.Parallel()
+	storage := NewPartitionedKVStorage(TestsPartitionCount)
+
+	if !storage.SetIfAbsent("key", "value") || storage.SetIfAbsent("key", "other") {
+		t.Errorf("Expected only the first SetIfAbsent to set the key")
+	}
+
+	updated := storage.Update("key", func(val string) (string, bool) { return val + "!", true })
+	if val, _ := storage.Get("key"); !updated || val != "value!" {
+		t.Errorf("Expected updated value, got %s", val)
+	}
+	if storage.Update("missing", func(val string) (string, bool) { return val, true }) {
+		t.Errorf("Expected update of missing key to report false")
+	}
+
+	if storage.CompareAndDelete("key", "value") {
+		t.Errorf("Expected CompareAndDelete with another value to keep the key")
+	}
+	if !storage.CompareAndDelete("key", "value!") {
+		t.Errorf("Expected CompareAndDelete with the value to delete the key")
+	}
+
+	storage.Compute("computed", func(val string, ok bool) (string, bool) { return val + "value", !ok })
+	if val, ok := storage.Get("computed"); !ok || val != "value" {
+		t.Errorf("Expected Compute to set missing key, got %s", val)
+	}
+	storage.Compute("computed", func(val string, ok bool) (string, bool) { return val, !ok })
+	if _, ok := storage.Get("computed"); ok {
+		t.Errorf("Expected Compute to delete the key")
+	}
+
+	storage.Set("key1", "value1")
+	storage.Set("key2", "value2")
+	if val, ok := storage.GetAndDelete("key1"); !ok || val != "value1" {
+		t.Errorf("Expected GetAndDelete to return value1, got %s", val)
+	}
+	if _, ok := storage.Get("key1"); ok {
+		t.Errorf("Expected GetAndDelete to delete the key")
+	}
+
+	pairs := make(map[string]string)
+	storage.Range(func(key, val string) bool {
+		pairs[key] = val
+		return true
+	})
+	if !maps.Equal(pairs, map[string]string{"key2": "value2"}) {
+		t.Errorf("Unexpected pairs %v", pairs)
+	}
+}
+
 func TestPartitionedKVStorage_ConcurrentRead(t *testing.T) {
 	t.Parallel()
 	storage := NewPartitionedKVStorage(TestsPartitionCount)
@@ -3,4 +3,35 @@ package kv
 type Storage interface {
 	Set(key, value string)
 	Get(key string) (val string, ok bool)
+	Delete(key string)
+}
+
+// Snapshotter is a storage whose content can be copied and replaced as a whole, e.g. to replicate it.
+type Snapshotter interface {
+	// Snapshot returns a copy of all pairs of the storage.
+	Snapshot() map[string]string
+	// Restore replaces the content of the storage with the pairs.
+	Restore(pairs map[string]string)
+}
+
+// AtomicStorage changes single keys atomically, so writers of a key don't need a lock of their own.
+type AtomicStorage interface {
+	Storage
+	Snapshotter
+	// SetIfAbsent sets the value unless the key exists and reports whether it's set.
+	SetIfAbsent(key, value string) bool
+	// Update replaces the value of the existing key by fn. The value is kept if fn returns false.
+	// Reports whether the key exists. fn must not call the storage.
+	Update(key string, fn func(value string) (string, bool)) bool
+	// Compute sets the key to the value returned by fn, or deletes it if fn returns false. fn gets the current
+	// value and whether the key exists, it's called under the lock of the key, so changes made by it along with
+	// the key are atomic for other writers of the key. fn must not call the storage.
+	Compute(key string, fn func(value string, ok bool) (string, bool))
+	// GetAndDelete deletes the key and returns its value.
+	GetAndDelete(key string) (val string, ok bool)
+	// CompareAndDelete deletes the key only if it has the value and reports whether it's deleted.
+	CompareAndDelete(key, value string) bool
+	// Range calls fn for the pairs until it returns false. Pairs changed concurrently may be missed.
+	// fn must not call the storage.
+	Range(fn func(key, value string) bool)
 }
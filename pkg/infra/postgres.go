@@ -3,28 +3,119 @@ package infra
 import (
 	"context"
 	"fmt"
+	pkgconfig "ozon_task/pkg/config"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type PostgresConfig struct {
-	Host     string `yaml:"host" env-required:"true"`
-	Port     int    `yaml:"port" env-required:"true"`
-	User     string `yaml:"user" env-required:"true"`
-	Password string `yaml:"password" env-required:"true"`
-	DBName   string `yaml:"db_name" env-required:"true"`
+	Host     string           `yaml:"host" env-required:"true"`
+	Port     int              `yaml:"port" env-required:"true"`
+	User     string           `yaml:"user" env-required:"true"`
+	Password pkgconfig.Secret `yaml:"password" env-required:"true"`
+	DBName   string           `yaml:"db_name" env-required:"true"`
+
+	// SSLMode is sslmode of libpq: disable, allow, prefer, require, verify-ca or verify-full.
+	SSLMode string `yaml:"ssl_mode" env-default:"disable"`
+	// SSLRootCert verifies the server with verify-ca and verify-full modes.
+	SSLRootCert string `yaml:"ssl_root_cert"`
+	// SSLCert and SSLKey authenticate the client by certificate.
+	SSLCert string `yaml:"ssl_cert"`
+	SSLKey  string `yaml:"ssl_key"`
+
+	// MaxConns and MinConns size the pool, zero MaxConns keeps the default of pgx: max(4, number of CPUs).
+	MaxConns int32 `yaml:"max_conns" env-default:"0"`
+	MinConns int32 `yaml:"min_conns" env-default:"0"`
+	// MaxConnLifetime and MaxConnIdleTime close old and idle connections, zero keeps defaults of pgx.
+	MaxConnLifetime time.Duration `yaml:"max_conn_lifetime" env-default:"0s"`
+	MaxConnIdleTime time.Duration `yaml:"max_conn_idle_time" env-default:"0s"`
+	// StatementTimeout aborts statements running longer on the server, zero disables it.
+	StatementTimeout time.Duration `yaml:"statement_timeout" env-default:"0s"`
+	// ApplicationName tells connections of the service in pg_stat_activity.
+	ApplicationName string `yaml:"application_name" env-default:"url-shortener"`
+
+	Replicas PostgresReplicasConfig `yaml:"replicas"`
+}
+
+// PostgresReplicasConfig lists replicas serving reads which tolerate replication lag.
+type PostgresReplicasConfig struct {
+	// DSNs of the replicas in URL or keyword/value format. Pool settings of the primary are applied to them,
+	// TLS is configured by the DSNs.
+	DSNs []pkgconfig.Secret `yaml:"dsns"`
+	// MaxLag is the replication lag the replica stops serving reads after.
+	MaxLag time.Duration `yaml:"max_lag" env-default:"5s"`
+	// CheckInterval is the interval of checking the lag of replicas.
+	CheckInterval time.Duration `yaml:"check_interval" env-default:"1s"`
 }
 
 func NewPostgresPool(cfg PostgresConfig) (*pgxpool.Pool, error) {
-	psqlInfo := fmt.Sprintf(
-		"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName,
-	)
+	sslMode := cfg.SSLMode
+	if len(sslMode) == 0 {
+		sslMode = "disable"
+	}
+
+	params := []string{
+		connParam("host", cfg.Host),
+		connParam("port", strconv.Itoa(cfg.Port)),
+		connParam("user", cfg.User),
+		connParam("password", cfg.Password.Value()),
+		connParam("dbname", cfg.DBName),
+		connParam("sslmode", sslMode),
+	}
+	for _, file := range []struct{ key, path string }{
+		{"sslrootcert", cfg.SSLRootCert},
+		{"sslcert", cfg.SSLCert},
+		{"sslkey", cfg.SSLKey},
+	} {
+		if len(file.path) != 0 {
+			params = append(params, connParam(file.key, file.path))
+		}
+	}
+
+	return newPostgresPool(strings.Join(params, " "), cfg)
+}
+
+// newPostgresPool creates the pool of the DSN with settings of the config.
+func newPostgresPool(dsn string, cfg PostgresConfig) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid postgres connection config: %w", err)
+	}
+
+	if cfg.MaxConns > 0 {
+		poolConfig.MaxConns = cfg.MaxConns
+	}
+	if cfg.MinConns > 0 {
+		poolConfig.MinConns = cfg.MinConns
+	}
+	if cfg.MaxConnLifetime > 0 {
+		poolConfig.MaxConnLifetime = cfg.MaxConnLifetime
+	}
+	if cfg.MaxConnIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = cfg.MaxConnIdleTime
+	}
+
+	runtimeParams := poolConfig.ConnConfig.RuntimeParams
+	if _, ok := runtimeParams["application_name"]; !ok && len(cfg.ApplicationName) != 0 {
+		runtimeParams["application_name"] = cfg.ApplicationName
+	}
+	if cfg.StatementTimeout > 0 {
+		runtimeParams["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+	}
 
-	dbPool, err := pgxpool.New(context.Background(), psqlInfo)
+	dbPool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
 	if err != nil {
 		return nil, fmt.Errorf("can't create connection to postgres: %w", err)
 	}
 
 	return dbPool, nil
 }
+
+// connParam quotes the value of the keyword/value connection string.
+func connParam(key, value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+	return key + "='" + value + "'"
+}
@@ -0,0 +1,89 @@
+package infra
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	pkgconfig "ozon_task/pkg/config"
+)
+
+func TestNewPostgresPool_AppliesConfig(t *testing.T) {
+	t.Parallel()
+	pool, err := NewPostgresPool(PostgresConfig{
+		Host:             "localhost",
+		Port:             5432,
+		User:             "postgres",
+		Password:         "pass'word",
+		DBName:           "links",
+		SSLMode:          "require",
+		MaxConns:         20,
+		MinConns:         2,
+		MaxConnLifetime:  time.Hour,
+		MaxConnIdleTime:  time.Minute,
+		StatementTimeout: 1500 * time.Millisecond,
+		ApplicationName:  "url-shortener",
+	})
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	cfg := pool.Config()
+	require.Equal(t, "pass'word", cfg.ConnConfig.Password)
+	require.NotNil(t, cfg.ConnConfig.TLSConfig)
+	require.Equal(t, int32(20), cfg.MaxConns)
+	require.Equal(t, int32(2), cfg.MinConns)
+	require.Equal(t, time.Hour, cfg.MaxConnLifetime)
+	require.Equal(t, time.Minute, cfg.MaxConnIdleTime)
+	require.Equal(t, "1500", cfg.ConnConfig.RuntimeParams["statement_timeout"])
+	require.Equal(t, "url-shortener", cfg.ConnConfig.RuntimeParams["application_name"])
+}
+
+func TestNewPostgresPool_DisablesTLSByDefault(t *testing.T) {
+	t.Parallel()
+	pool, err := NewPostgresPool(PostgresConfig{Host: "localhost", Port: 5432, User: "postgres", DBName: "links"})
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	require.Nil(t, pool.Config().ConnConfig.TLSConfig)
+	require.Empty(t, pool.Config().ConnConfig.RuntimeParams["statement_timeout"])
+}
+
+func TestPostgresReplicas_Reader(t *testing.T) {
+	t.Parallel()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	replicas, err := NewPostgresReplicas(PostgresConfig{}, log)
+	require.NoError(t, err)
+	require.Nil(t, replicas)
+
+	replicas, err = NewPostgresReplicas(PostgresConfig{
+		MaxConns:        5,
+		ApplicationName: "url-shortener",
+		Replicas: PostgresReplicasConfig{DSNs: []pkgconfig.Secret{
+			"postgres://postgres@replica-1:5432/links?sslmode=disable",
+			"postgres://postgres@replica-2:5432/links?sslmode=disable&application_name=reports",
+		}},
+	}, log)
+	require.NoError(t, err)
+	t.Cleanup(replicas.Close)
+
+	first, second := replicas.replicas[0], replicas.replicas[1]
+	require.Equal(t, "replica-1:5432", first.name)
+	require.Equal(t, int32(5), first.pool.Config().MaxConns)
+	require.Equal(t, "url-shortener", first.pool.Config().ConnConfig.RuntimeParams["application_name"])
+	require.Equal(t, "reports", second.pool.Config().ConnConfig.RuntimeParams["application_name"])
+
+	// replicas don't serve reads before the check of their lag
+	require.Nil(t, replicas.Reader())
+
+	first.available.Store(true)
+	second.available.Store(true)
+	require.NotSame(t, replicas.Reader(), replicas.Reader())
+
+	second.available.Store(false)
+	require.Same(t, first.pool, replicas.Reader())
+	require.Same(t, first.pool, replicas.Reader())
+}
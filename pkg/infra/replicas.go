@@ -0,0 +1,137 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	pkglog "ozon_task/pkg/log"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// lagQuery returns the replication lag in seconds, zero if the replica replayed all received changes,
+// as the time of the last replayed transaction grows while the primary is idle. It also reports whether
+// the replica streams changes: a replica without WAL receiver replayed everything it got, but it isn't
+// up to date. Roles without pg_read_all_stats see the receiver with null status, its presence is enough.
+const lagQuery = `
+    SELECT
+        CASE
+            WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+            ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+        END::FLOAT8,
+        NOT pg_is_in_recovery() OR EXISTS (
+            SELECT 1 FROM pg_stat_wal_receiver WHERE COALESCE(status, 'streaming') = 'streaming'
+        )
+`
+
+// PostgresReplicas routes reads to replicas which are reachable and don't lag behind the primary
+// more than the limit. Replicas serve reads only after the first check of their lag.
+type PostgresReplicas struct {
+	replicas      []*postgresReplica
+	maxLag        time.Duration
+	checkInterval time.Duration
+	next          atomic.Uint64
+	log           *slog.Logger
+}
+
+type postgresReplica struct {
+	name      string
+	pool      *pgxpool.Pool
+	available atomic.Bool
+}
+
+// NewPostgresReplicas creates pools of the replicas of the config, nil if there are none.
+func NewPostgresReplicas(cfg PostgresConfig, log *slog.Logger) (*PostgresReplicas, error) {
+	if len(cfg.Replicas.DSNs) == 0 {
+		return nil, nil
+	}
+
+	replicas := &PostgresReplicas{
+		maxLag:        cfg.Replicas.MaxLag,
+		checkInterval: cfg.Replicas.CheckInterval,
+		log:           log,
+	}
+	for i, dsn := range cfg.Replicas.DSNs {
+		pool, err := newPostgresPool(dsn.Value(), cfg)
+		if err != nil {
+			replicas.Close()
+			return nil, fmt.Errorf("replica %d: %w", i, err)
+		}
+
+		name := fmt.Sprintf("%s:%d", pool.Config().ConnConfig.Host, pool.Config().ConnConfig.Port)
+		replicas.replicas = append(replicas.replicas, &postgresReplica{name: name, pool: pool})
+	}
+
+	return replicas, nil
+}
+
+// Reader returns the pool of the next available replica, nil if all of them are unavailable.
+func (r *PostgresReplicas) Reader() *pgxpool.Pool {
+	n := uint64(len(r.replicas))
+	start := r.next.Add(1)
+	for i := range n {
+		if replica := r.replicas[(start+i)%n]; replica.available.Load() {
+			return replica.pool
+		}
+	}
+	return nil
+}
+
+// Run checks the lag of the replicas until the context is done.
+func (r *PostgresReplicas) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, replica := range r.replicas {
+			r.check(ctx, replica)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *PostgresReplicas) check(ctx context.Context, replica *postgresReplica) {
+	checkCtx, cancel := context.WithTimeout(ctx, r.checkInterval)
+	defer cancel()
+
+	var (
+		lagSeconds float64
+		streaming  bool
+	)
+	err := replica.pool.QueryRow(checkCtx, lagQuery).Scan(&lagSeconds, &streaming)
+	if ctx.Err() != nil {
+		// the check is interrupted by shutdown
+		return
+	}
+
+	lag := time.Duration(lagSeconds * float64(time.Second))
+	available := err == nil && streaming && lag <= r.maxLag
+	if replica.available.Swap(available) == available {
+		return
+	}
+
+	switch {
+	case available:
+		r.log.Info("postgres replica serves reads", slog.String("replica", replica.name), slog.Duration("lag", lag))
+	case err != nil:
+		r.log.Warn("postgres replica is unavailable", slog.String("replica", replica.name), pkglog.Err(err))
+	case !streaming:
+		r.log.Warn("postgres replica doesn't receive changes", slog.String("replica", replica.name))
+	default:
+		r.log.Warn("postgres replica lags behind", slog.String("replica", replica.name), slog.Duration("lag", lag))
+	}
+}
+
+// Close closes pools of the replicas.
+func (r *PostgresReplicas) Close() {
+	for _, replica := range r.replicas {
+		replica.pool.Close()
+	}
+}
@@ -0,0 +1,262 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	pkglog "ozon_task/pkg/log"
+)
+
+var ErrKeyNotFound = errors.New("signing key not found")
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// KeySet is an immutable set of public keys indexed by key id.
+type KeySet struct {
+	keys map[string]signingKey
+}
+
+// signingKey is a public key with the algorithm it's restricted to by the JWK, empty if any.
+type signingKey struct {
+	key crypto.PublicKey
+	alg string
+}
+
+// ParseJWKS parses JSON Web Key Set document. Keys not intended for signatures are skipped.
+func ParseJWKS(data []byte) (*KeySet, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("ParseJWKS: invalid json: %w", err)
+	}
+
+	set := &KeySet{keys: make(map[string]signingKey, len(doc.Keys))}
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("ParseJWKS: key %q: %w", k.Kid, err)
+		}
+		set.keys[k.Kid] = signingKey{key: key, alg: k.Alg}
+	}
+
+	return set, nil
+}
+
+// Key returns public key by id. Token without kid is accepted only if the set has a single key.
+func (s *KeySet) Key(kid string) (crypto.PublicKey, error) {
+	key, err := s.signingKey(kid)
+	if err != nil {
+		return nil, err
+	}
+	return key.key, nil
+}
+
+func (s *KeySet) signingKey(kid string) (signingKey, error) {
+	if key, ok := s.keys[kid]; ok {
+		return key, nil
+	}
+
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, nil
+		}
+	}
+
+	return signingKey{}, fmt.Errorf("kid %q: %w", kid, ErrKeyNotFound)
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid base64url integer")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// KeySource loads JWKS from a local file or URL and refreshes it periodically.
+type KeySource struct {
+	file            string
+	url             string
+	refreshInterval time.Duration
+	client          *http.Client
+	logger          *slog.Logger
+	current         atomic.Pointer[KeySet]
+}
+
+// NewKeySource creates source and loads keys for the first time. Either file or url must be set.
+func NewKeySource(
+	ctx context.Context,
+	file, url string,
+	refreshInterval time.Duration,
+	logger *slog.Logger,
+) (*KeySource, error) {
+	if (file == "") == (url == "") {
+		return nil, errors.New("NewKeySource: exactly one of jwks file or url must be set")
+	}
+
+	const fetchTimeout = 10 * time.Second
+	s := &KeySource{
+		file:            file,
+		url:             url,
+		refreshInterval: refreshInterval,
+		client:          &http.Client{Timeout: fetchTimeout},
+		logger:          logger,
+	}
+
+	if err := s.Refresh(ctx); err != nil {
+		return nil, fmt.Errorf("NewKeySource: %w", err)
+	}
+
+	return s, nil
+}
+
+// Keys returns the latest successfully loaded key set.
+func (s *KeySource) Keys() *KeySet {
+	return s.current.Load()
+}
+
+// Refresh loads key set again. Previous keys are kept on failure.
+func (s *KeySource) Refresh(ctx context.Context) error {
+	data, err := s.fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("Refresh: %w", err)
+	}
+
+	set, err := ParseJWKS(data)
+	if err != nil {
+		return fmt.Errorf("Refresh: %w", err)
+	}
+	s.current.Store(set)
+
+	return nil
+}
+
+// Run refreshes keys until context cancellation. Nil KeySource stands for disabled JWT validation.
+func (s *KeySource) Run(ctx context.Context) error {
+	const op = "jwt.KeySource.Run"
+
+	if s == nil || s.refreshInterval <= 0 {
+		return nil
+	}
+
+	log := s.logger.With(slog.String("op", op))
+
+	ticker := time.NewTicker(s.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := s.Refresh(ctx); err != nil {
+				log.Error("failed to refresh jwks, keeping previous keys", pkglog.Err(err))
+			}
+		}
+	}
+}
+
+func (s *KeySource) fetch(ctx context.Context) ([]byte, error) {
+	if s.file != "" {
+		data, err := os.ReadFile(s.file)
+		if err != nil {
+			return nil, fmt.Errorf("can't read jwks file: %w", err)
+		}
+		return data, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("can't create jwks request: %w", err)
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("can't fetch jwks: %w", err)
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("can't fetch jwks: unexpected status %d", res.StatusCode)
+	}
+
+	const maxJWKSSize = 1 << 20
+	return io.ReadAll(io.LimitReader(res.Body, maxJWKSSize))
+}
@@ -0,0 +1,250 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // registers hashes used by RS256, PS256 and ES256
+	_ "crypto/sha512" // registers hashes used by *384 and *512 algorithms
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMalformed        = errors.New("malformed token")
+	ErrInvalidSignature = errors.New("invalid token signature")
+	ErrInvalidClaims    = errors.New("invalid token claims")
+	ErrExpired          = errors.New("token is expired")
+)
+
+// Claims of verified token. Registered claims are parsed, all of them are kept in Raw.
+type Claims struct {
+	Subject   string
+	Issuer    string
+	Audience  []string
+	ExpiresAt time.Time
+	NotBefore time.Time
+	Raw       map[string]any
+}
+
+// String returns string claim or empty string.
+func (c Claims) String(name string) string {
+	s, _ := c.Raw[name].(string)
+	return s
+}
+
+// Strings returns claim which is either space separated string (like `scope`) or array of strings (like `scp`).
+func (c Claims) Strings(name string) []string {
+	switch v := c.Raw[name].(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		res := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				res = append(res, s)
+			}
+		}
+		return res
+	default:
+		return nil
+	}
+}
+
+// KeyProvider returns current signing keys.
+type KeyProvider interface {
+	Keys() *KeySet
+}
+
+// Verifier validates signature, issuer, audience and lifetime of tokens.
+type Verifier struct {
+	keys     KeyProvider
+	issuer   string
+	audience string
+	leeway   time.Duration
+	now      func() time.Time
+}
+
+// NewVerifier creates verifier. Empty issuer or audience disables corresponding check.
+func NewVerifier(keys KeyProvider, issuer, audience string, leeway time.Duration) *Verifier {
+	return &Verifier{
+		keys:     keys,
+		issuer:   issuer,
+		audience: audience,
+		leeway:   leeway,
+		now:      time.Now,
+	}
+}
+
+// LooksLikeJWT reports whether token has JWS compact serialization shape.
+func LooksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+}
+
+func (v *Verifier) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, fmt.Errorf("Verify: %w", ErrMalformed)
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return Claims{}, fmt.Errorf("Verify: header: %w", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, fmt.Errorf("Verify: signature encoding: %w", ErrMalformed)
+	}
+
+	key, err := v.keys.Keys().signingKey(h.Kid)
+	if err != nil {
+		return Claims{}, fmt.Errorf("Verify: %w: %w", ErrInvalidSignature, err)
+	}
+
+	if err = verifySignature(h.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return Claims{}, fmt.Errorf("Verify: %w", err)
+	}
+
+	var raw map[string]any
+	if err = decodeSegment(parts[1], &raw); err != nil {
+		return Claims{}, fmt.Errorf("Verify: payload: %w", err)
+	}
+
+	claims, err := v.validate(raw)
+	if err != nil {
+		return Claims{}, fmt.Errorf("Verify: %w", err)
+	}
+
+	return claims, nil
+}
+
+func (v *Verifier) validate(raw map[string]any) (Claims, error) {
+	claims := Claims{Raw: raw}
+	claims.Subject = claims.String("sub")
+	claims.Issuer = claims.String("iss")
+	claims.Audience = claims.Strings("aud")
+
+	exp, ok := raw["exp"].(float64)
+	if !ok {
+		return Claims{}, fmt.Errorf("exp claim is required: %w", ErrInvalidClaims)
+	}
+	claims.ExpiresAt = time.Unix(int64(exp), 0)
+
+	now := v.now()
+	if now.After(claims.ExpiresAt.Add(v.leeway)) {
+		return Claims{}, ErrExpired
+	}
+
+	if nbf, ok := raw["nbf"].(float64); ok {
+		claims.NotBefore = time.Unix(int64(nbf), 0)
+		if now.Add(v.leeway).Before(claims.NotBefore) {
+			return Claims{}, fmt.Errorf("token is not valid yet: %w", ErrInvalidClaims)
+		}
+	}
+
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return Claims{}, fmt.Errorf("unexpected issuer %q: %w", claims.Issuer, ErrInvalidClaims)
+	}
+
+	if v.audience != "" && !slices.Contains(claims.Audience, v.audience) {
+		return Claims{}, fmt.Errorf("audience %q is missing: %w", v.audience, ErrInvalidClaims)
+	}
+
+	return claims, nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return ErrMalformed
+	}
+
+	if err = json.Unmarshal(data, v); err != nil {
+		return ErrMalformed
+	}
+
+	return nil
+}
+
+// verifySignature checks signature by the key, alg of the header must match the key type, its curve
+// and alg of the JWK, so a key can't be used with an algorithm it isn't meant for.
+func verifySignature(alg string, key signingKey, signed, signature []byte) error {
+	hash, err := hashFor(alg)
+	if err != nil {
+		return err
+	}
+	if key.alg != "" && key.alg != alg {
+		return fmt.Errorf("alg %q of the key doesn't match %q: %w", key.alg, alg, ErrInvalidSignature)
+	}
+
+	var digest []byte
+	if hash != 0 {
+		h := hash.New()
+		h.Write(signed)
+		digest = h.Sum(nil)
+	}
+
+	valid := false
+	switch k := key.key.(type) {
+	case *rsa.PublicKey:
+		switch {
+		case strings.HasPrefix(alg, "RS"):
+			valid = rsa.VerifyPKCS1v15(k, hash, digest, signature) == nil
+		case strings.HasPrefix(alg, "PS"):
+			valid = rsa.VerifyPSS(k, hash, digest, signature, nil) == nil
+		}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if ecdsaCurves[alg] == k.Curve && len(signature) == 2*size {
+			r := new(big.Int).SetBytes(signature[:size])
+			s := new(big.Int).SetBytes(signature[size:])
+			valid = ecdsa.Verify(k, digest, r, s)
+		}
+	case ed25519.PublicKey:
+		valid = alg == "EdDSA" && ed25519.Verify(k, signed, signature)
+	}
+
+	if !valid {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// ecdsaCurves are curves of ECDSA algorithms by RFC 7518.
+var ecdsaCurves = map[string]elliptic.Curve{
+	"ES256": elliptic.P256(),
+	"ES384": elliptic.P384(),
+	"ES512": elliptic.P521(),
+}
+
+func hashFor(alg string) (crypto.Hash, error) {
+	switch alg {
+	case "RS256", "PS256", "ES256":
+		return crypto.SHA256, nil
+	case "RS384", "PS384", "ES384":
+		return crypto.SHA384, nil
+	case "RS512", "PS512", "ES512":
+		return crypto.SHA512, nil
+	case "EdDSA":
+		return 0, nil
+	default:
+		// "none" and HMAC algorithms are rejected, only asymmetric keys from JWKS are trusted
+		return 0, fmt.Errorf("unsupported alg %q: %w", alg, ErrInvalidSignature)
+	}
+}
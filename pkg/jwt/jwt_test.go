@@ -0,0 +1,173 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var dummyLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func encodeSegment(t *testing.T, v any) string {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+
+	return b64(data)
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+
+	signed := encodeSegment(t, map[string]string{"alg": "RS256", "kid": kid}) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+
+	return signed + "." + b64(signature)
+}
+
+func signPS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+
+	signed := encodeSegment(t, map[string]string{"alg": "PS256", "kid": kid}) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPSS(rand.Reader, key, crypto.SHA256, digest[:], nil)
+	require.NoError(t, err)
+
+	return signed + "." + b64(signature)
+}
+
+func signES256(t *testing.T, key *ecdsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+
+	return signECDSA(t, "ES256", crypto.SHA256, key, kid, claims)
+}
+
+// signECDSA signs token by the key with any alg and hash, the signature has size of the key curve.
+func signECDSA(
+	t *testing.T,
+	alg string,
+	hash crypto.Hash,
+	key *ecdsa.PrivateKey,
+	kid string,
+	claims map[string]any,
+) string {
+	t.Helper()
+
+	signed := encodeSegment(t, map[string]string{"alg": alg, "kid": kid}) + "." + encodeSegment(t, claims)
+	h := hash.New()
+	h.Write([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, key, h.Sum(nil))
+	require.NoError(t, err)
+
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	return signed + "." + b64(signature)
+}
+
+func writeJWKS(t *testing.T, rsaKey *rsa.PublicKey, ecKey *ecdsa.PublicKey) string {
+	t.Helper()
+
+	doc := map[string]any{"keys": []map[string]string{
+		{
+			"kty": "RSA",
+			"kid": "rsa",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   b64(rsaKey.N.Bytes()),
+			"e":   b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+		},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecKey.X.Bytes()), "y": b64(ecKey.Y.Bytes())},
+	}}
+	data, err := json.Marshal(doc)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, data, 0600))
+
+	return path
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	source, err := NewKeySource(context.Background(), writeJWKS(t, &rsaKey.PublicKey, &ecKey.PublicKey), "", 0, dummyLogger)
+	require.NoError(t, err)
+	verifier := NewVerifier(source, "https://idp", "shortener", time.Second)
+
+	claims := func(overrides map[string]any) map[string]any {
+		c := map[string]any{
+			"sub":   "user",
+			"iss":   "https://idp",
+			"aud":   []string{"shortener", "other"},
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"scope": "links:read links:write",
+		}
+		for k, v := range overrides {
+			c[k] = v
+		}
+		return c
+	}
+
+	tests := []struct {
+		name        string
+		token       string
+		expectedErr error
+	}{
+		{"RS256", signRS256(t, rsaKey, "rsa", claims(nil)), nil},
+		{"ES256", signES256(t, ecKey, "ec", claims(nil)), nil},
+		{"Unknown key", signES256(t, otherKey, "ec", claims(nil)), ErrInvalidSignature},
+		{"Unknown kid", signES256(t, ecKey, "missing", claims(nil)), ErrInvalidSignature},
+		{"Expired", signES256(t, ecKey, "ec", claims(map[string]any{"exp": time.Now().Add(-time.Minute).Unix()})), ErrExpired},
+		{"Wrong issuer", signES256(t, ecKey, "ec", claims(map[string]any{"iss": "https://evil"})), ErrInvalidClaims},
+		{"Wrong audience", signES256(t, ecKey, "ec", claims(map[string]any{"aud": "other"})), ErrInvalidClaims},
+		{"Not yet valid", signES256(t, ecKey, "ec", claims(map[string]any{"nbf": time.Now().Add(time.Hour).Unix()})), ErrInvalidClaims},
+		{"Alg of other key type", signECDSA(t, "RS256", crypto.SHA256, ecKey, "ec", claims(nil)), ErrInvalidSignature},
+		{"Alg of other curve", signECDSA(t, "ES384", crypto.SHA384, ecKey, "ec", claims(nil)), ErrInvalidSignature},
+		{"Alg not allowed by key", signPS256(t, rsaKey, "rsa", claims(nil)), ErrInvalidSignature},
+		{"Alg none", encodeSegment(t, map[string]string{"alg": "none"}) + "." + encodeSegment(t, claims(nil)) + ".", ErrInvalidSignature},
+		{"Malformed", "not-a-token", ErrMalformed},
+	}
+
+	for _, test := range tests {
+		res, err := verifier.Verify(test.token)
+		if test.expectedErr != nil {
+			require.ErrorIs(t, err, test.expectedErr, test.name)
+			continue
+		}
+
+		require.NoError(t, err, test.name)
+		require.Equal(t, "user", res.Subject, test.name)
+		require.Equal(t, []string{"links:read", "links:write"}, res.Strings("scope"), test.name)
+	}
+}
@@ -0,0 +1,81 @@
+// Package migrate applies embedded SQL migrations to PostgreSQL and tracks applied versions.
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// ErrInvalidMigrations is returned for malformed sets of migration files.
+var ErrInvalidMigrations = errors.New("invalid migrations")
+
+// fileName matches files like `1_init.up.sql` and `1_init.down.sql`.
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a pair of scripts changing the schema to Version and back.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Load reads migrations from the root of fsys, every version must have both up and down scripts.
+// Returns migrations ordered by version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("Load: read directory failed: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration, len(entries)/2)
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.Atoi(match[1])
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("Load: %w: bad version of %q", ErrInvalidMigrations, entry.Name())
+		}
+
+		script, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("Load: read %q failed: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("Load: %w: version %d is used by %q and %q",
+				ErrInvalidMigrations, version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(script)
+		} else {
+			migration.Down = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("Load: %w: version %d must have up and down scripts",
+				ErrInvalidMigrations, migration.Version)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
@@ -0,0 +1,74 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+	fsys := fstest.MapFS{
+		"2_users.up.sql":   {Data: []byte("CREATE TABLE users();")},
+		"2_users.down.sql": {Data: []byte("DROP TABLE users;")},
+		"10_tags.up.sql":   {Data: []byte("CREATE TABLE tags();")},
+		"10_tags.down.sql": {Data: []byte("DROP TABLE tags;")},
+		"1_init.up.sql":    {Data: []byte("CREATE TABLE links();")},
+		"1_init.down.sql":  {Data: []byte("DROP TABLE links;")},
+		"README.md":        {Data: []byte("not a migration")},
+	}
+
+	migrations, err := Load(fsys)
+	require.NoError(t, err)
+	require.Equal(t, []Migration{
+		{Version: 1, Name: "init", Up: "CREATE TABLE links();", Down: "DROP TABLE links;"},
+		{Version: 2, Name: "users", Up: "CREATE TABLE users();", Down: "DROP TABLE users;"},
+		{Version: 10, Name: "tags", Up: "CREATE TABLE tags();", Down: "DROP TABLE tags;"},
+	}, migrations)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{
+			name: "missing down",
+			fsys: fstest.MapFS{"1_init.up.sql": {Data: []byte("CREATE TABLE links();")}},
+		},
+		{
+			name: "empty script",
+			fsys: fstest.MapFS{
+				"1_init.up.sql":   {Data: []byte("CREATE TABLE links();")},
+				"1_init.down.sql": {},
+			},
+		},
+		{
+			name: "same version",
+			fsys: fstest.MapFS{
+				"1_init.up.sql":    {Data: []byte("CREATE TABLE links();")},
+				"1_init.down.sql":  {Data: []byte("DROP TABLE links;")},
+				"1_users.up.sql":   {Data: []byte("CREATE TABLE users();")},
+				"1_users.down.sql": {Data: []byte("DROP TABLE users;")},
+			},
+		},
+		{
+			name: "zero version",
+			fsys: fstest.MapFS{
+				"0_init.up.sql":   {Data: []byte("CREATE TABLE links();")},
+				"0_init.down.sql": {Data: []byte("DROP TABLE links;")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := Load(tt.fsys)
+			require.ErrorIs(t, err, ErrInvalidMigrations)
+		})
+	}
+}
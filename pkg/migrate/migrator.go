@@ -0,0 +1,339 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrSchemaOutdated is returned by Check if migrations required by the binary aren't applied.
+var ErrSchemaOutdated = errors.New("database schema is outdated")
+
+// lockKey is the key of the advisory lock held while migrations are applied, so replicas
+// started at once apply them one by one.
+const lockKey int64 = 7_283_064_111
+
+// legacyTable is the table of sql-migrate used before the migrations were embedded.
+// Its versions are imported once, so existing databases aren't migrated again.
+const legacyTable = "gorp_migrations"
+
+const createTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations(
+		version INT PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`
+
+// Status of the migration, AppliedAt is zero for pending migrations.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+type Migrator struct {
+	pool       *pgxpool.Pool
+	migrations []Migration
+	log        *slog.Logger
+}
+
+func New(pool *pgxpool.Pool, migrations []Migration, log *slog.Logger) *Migrator {
+	return &Migrator{
+		pool:       pool,
+		migrations: migrations,
+		log:        log,
+	}
+}
+
+// Latest returns the version of the schema required by the migrations.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies pending migrations in order, each one in its own transaction.
+// Returns the number of applied migrations.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	count := 0
+	err := m.locked(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, migration.Up); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`,
+					migration.Version, migration.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+
+			m.log.Info("Migration applied", slog.Int("version", migration.Version), slog.String("name", migration.Name))
+			count++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return count, fmt.Errorf("Up: %w", err)
+	}
+
+	return count, nil
+}
+
+// Down reverts up to steps latest applied migrations. Returns the number of reverted migrations.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	byVersion := make(map[int]Migration, len(m.migrations))
+	for _, migration := range m.migrations {
+		byVersion[migration.Version] = migration
+	}
+
+	count := 0
+	err := m.locked(ctx, func(conn *pgxpool.Conn) error {
+		for ; count < steps; count++ {
+			var version int
+			err := conn.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+			if err != nil {
+				return fmt.Errorf("read version failed: %w", err)
+			}
+			if version == 0 {
+				return nil
+			}
+
+			migration, ok := byVersion[version]
+			if !ok {
+				return fmt.Errorf("migration %d is unknown to this binary", version)
+			}
+
+			err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, migration.Down); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+
+			m.log.Info("Migration reverted", slog.Int("version", migration.Version), slog.String("name", migration.Name))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return count, fmt.Errorf("Down: %w", err)
+	}
+
+	return count, nil
+}
+
+// Status returns states of the known migrations ordered by version.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx, m.pool)
+	if err != nil {
+		return nil, fmt.Errorf("Status: %w", err)
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		statuses = append(statuses, Status{Migration: migration, Applied: ok, AppliedAt: appliedAt})
+	}
+
+	return statuses, nil
+}
+
+// Check returns ErrSchemaOutdated if any of the known migrations isn't applied.
+// Migrations unknown to the binary, e.g. applied by its newer version, are allowed.
+func (m *Migrator) Check(ctx context.Context) error {
+	applied, err := m.applied(ctx, m.pool)
+	if err != nil {
+		return fmt.Errorf("Check: %w", err)
+	}
+
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			return fmt.Errorf("Check: %w: migration %d_%s isn't applied, required version is %d",
+				ErrSchemaOutdated, migration.Version, migration.Name, m.Latest())
+		}
+	}
+
+	return nil
+}
+
+// locked runs fn holding the advisory lock on a single connection, the table of versions is created before.
+// statement_timeout of the pool is disabled for the session: waiting for the lock and migrations of big
+// tables take longer than queries of the service.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection failed: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err = conn.Exec(ctx, `SET statement_timeout = 0`); err != nil {
+		return fmt.Errorf("disable statement timeout failed: %w", err)
+	}
+	defer func() {
+		// the connection returns to the pool with its timeout, it's closed if the timeout isn't restored
+		if _, err := conn.Exec(context.Background(), `RESET statement_timeout`); err != nil {
+			_ = conn.Conn().Close(context.Background())
+		}
+	}()
+
+	if _, err = conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("lock failed: %w", err)
+	}
+	defer func() {
+		// the lock is released with the session anyway, so the context of the caller isn't needed
+		_, _ = conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey)
+	}()
+
+	if err = m.createTable(ctx, conn); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// createTable creates the table of versions, versions applied by sql-migrate are imported into the new table.
+func (m *Migrator) createTable(ctx context.Context, conn *pgxpool.Conn) error {
+	exists, err := tableExists(ctx, conn, "schema_migrations")
+	if err != nil || exists {
+		return err
+	}
+
+	legacy, err := legacyVersions(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, createTable); err != nil {
+			return fmt.Errorf("create table failed: %w", err)
+		}
+
+		for _, migration := range m.migrations {
+			appliedAt, ok := legacy[migration.Version]
+			if !ok {
+				continue
+			}
+			if appliedAt.IsZero() {
+				appliedAt = time.Now()
+			}
+			_, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`,
+				migration.Version, migration.Name, appliedAt)
+			if err != nil {
+				return fmt.Errorf("import legacy versions failed: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// applied returns applied versions with their time. Before the first migration with the runner
+// versions of sql-migrate are reported.
+func (m *Migrator) applied(ctx context.Context, q querier) (map[int]time.Time, error) {
+	exists, err := tableExists(ctx, q, "schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return legacyVersions(ctx, q)
+	}
+
+	rows, err := q.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("read versions failed: %w", err)
+	}
+
+	applied := make(map[int]time.Time)
+	var (
+		version   int
+		appliedAt time.Time
+	)
+	_, err = pgx.ForEachRow(rows, []any{&version, &appliedAt}, func() error {
+		applied[version] = appliedAt
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read versions failed: %w", err)
+	}
+
+	return applied, nil
+}
+
+// legacyVersions reads versions applied by sql-migrate, it tracks applied files, e.g. `1_init.up.sql`.
+func legacyVersions(ctx context.Context, q querier) (map[int]time.Time, error) {
+	applied := make(map[int]time.Time)
+
+	exists, err := tableExists(ctx, q, legacyTable)
+	if err != nil || !exists {
+		return applied, err
+	}
+
+	rows, err := q.Query(ctx, `SELECT id, applied_at FROM `+legacyTable)
+	if err != nil {
+		return nil, fmt.Errorf("read legacy versions failed: %w", err)
+	}
+
+	var (
+		id        string
+		appliedAt *time.Time
+	)
+	_, err = pgx.ForEachRow(rows, []any{&id, &appliedAt}, func() error {
+		match := fileName.FindStringSubmatch(id)
+		if match == nil || match[3] != "up" {
+			return nil
+		}
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil
+		}
+		applied[version] = time.Time{}
+		if appliedAt != nil {
+			applied[version] = *appliedAt
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read legacy versions failed: %w", err)
+	}
+
+	return applied, nil
+}
+
+func tableExists(ctx context.Context, q querier, table string) (bool, error) {
+	var exists bool
+	if err := q.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, table).Scan(&exists); err != nil {
+		return false, fmt.Errorf("check table %s failed: %w", table, err)
+	}
+	return exists, nil
+}
@@ -0,0 +1,141 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepEvery is number of calls between removals of idle buckets and outdated counters.
+const sweepEvery = 1024
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time
+}
+
+type counter struct {
+	day   string
+	count int
+}
+
+// InMem keeps buckets and quota counters in process memory.
+// Limits are applied per instance of the service.
+type InMem struct {
+	mu       sync.Mutex
+	buckets  map[string]*bucket
+	counters map[string]*counter
+	calls    int
+	now      func() time.Time
+}
+
+func NewInMem() *InMem {
+	return &InMem{
+		buckets:  make(map[string]*bucket),
+		counters: make(map[string]*counter),
+		now:      time.Now,
+	}
+}
+
+func (m *InMem) Allow(_ context.Context, key string, limit Limit) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	burst := float64(limit.burst())
+	interval := limit.perToken()
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, updated: now}
+		m.buckets[key] = b
+	}
+
+	elapsed := float64(now.Sub(b.updated))
+	b.tokens = math.Min(burst, b.tokens+elapsed/interval)
+	b.updated = now
+
+	res := Result{Limit: limit.burst()}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = ceilDuration((1 - b.tokens) * interval)
+	}
+
+	res.Remaining = int(b.tokens)
+	res.ResetAfter = ceilDuration((burst - b.tokens) * interval)
+	b.full = now.Add(res.ResetAfter)
+
+	return res, nil
+}
+
+func (m *InMem) Consume(_ context.Context, key string, limit int) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	window, resetAfter := day(now)
+
+	c, ok := m.counters[key]
+	if !ok || c.day != window {
+		c = &counter{day: window}
+		m.counters[key] = c
+	}
+
+	res := Result{Limit: limit, ResetAfter: resetAfter}
+	if c.count >= limit {
+		res.RetryAfter = resetAfter
+		return res, nil
+	}
+
+	c.count++
+	res.Allowed = true
+	res.Remaining = limit - c.count
+
+	return res, nil
+}
+
+func (m *InMem) Refund(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	window, _ := day(m.now())
+	if c, ok := m.counters[key]; ok && c.day == window && c.count > 0 {
+		c.count--
+	}
+
+	return nil
+}
+
+// sweep drops full buckets and counters of previous days so memory is bounded by active keys.
+func (m *InMem) sweep(now time.Time) {
+	m.calls++
+	if m.calls%sweepEvery != 0 {
+		return
+	}
+
+	for key, b := range m.buckets {
+		if !now.Before(b.full) {
+			delete(m.buckets, key)
+		}
+	}
+
+	window, _ := day(now)
+	for key, c := range m.counters {
+		if c.day != window {
+			delete(m.counters, key)
+		}
+	}
+}
+
+// ceilDuration rounds nanoseconds up to milliseconds, so clients never retry too early.
+func ceilDuration(ns float64) time.Duration {
+	return time.Duration(math.Ceil(ns/float64(time.Millisecond))) * time.Millisecond
+}
@@ -0,0 +1,61 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Limit describes token bucket: Rate tokens are added every Period, up to Burst tokens.
+type Limit struct {
+	Rate   int           `yaml:"rate"`
+	Period time.Duration `yaml:"period" env-default:"1s"`
+	Burst  int           `yaml:"burst"`
+}
+
+// Enabled reports whether limit is configured. Zero rate disables limiting.
+func (l Limit) Enabled() bool {
+	return l.Rate > 0 && l.Period > 0
+}
+
+func (l Limit) burst() int {
+	if l.Burst < l.Rate {
+		return l.Rate
+	}
+	return l.Burst
+}
+
+// perToken is time needed to refill a single token, it's fractional for periods shorter than the rate.
+func (l Limit) perToken() float64 {
+	return float64(l.Period) / float64(l.Rate)
+}
+
+// Result of taking a token or a unit of quota.
+type Result struct {
+	Allowed bool
+	// Limit is the size of the bucket or the quota.
+	Limit     int
+	Remaining int
+	// RetryAfter is set when request is not allowed.
+	RetryAfter time.Duration
+	// ResetAfter is time until bucket is full again or quota window ends.
+	ResetAfter time.Duration
+}
+
+// Limiter takes tokens from buckets identified by key.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// Quota counts units consumed by key within a UTC day.
+type Quota interface {
+	Consume(ctx context.Context, key string, limit int) (Result, error)
+	// Refund returns a consumed unit of the current day to the key.
+	Refund(ctx context.Context, key string) error
+}
+
+// day returns identifier of the quota window and its remaining time.
+func day(now time.Time) (string, time.Duration) {
+	now = now.UTC()
+	next := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	return now.Format(time.DateOnly), next.Sub(now)
+}
@@ -0,0 +1,135 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMem_Allow(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewInMem()
+	limiter.now = func() time.Time { return now }
+	limit := Limit{Rate: 2, Period: time.Second, Burst: 3}
+
+	for i := range 3 {
+		res, err := limiter.Allow(context.Background(), "client", limit)
+		require.NoError(t, err)
+		require.True(t, res.Allowed)
+		require.Equal(t, 2-i, res.Remaining)
+	}
+
+	res, err := limiter.Allow(context.Background(), "client", limit)
+	require.NoError(t, err)
+	require.False(t, res.Allowed)
+	require.Equal(t, 500*time.Millisecond, res.RetryAfter)
+
+	res, err = limiter.Allow(context.Background(), "other", limit)
+	require.NoError(t, err)
+	require.True(t, res.Allowed, "buckets are independent")
+
+	now = now.Add(500 * time.Millisecond)
+	res, err = limiter.Allow(context.Background(), "client", limit)
+	require.NoError(t, err)
+	require.True(t, res.Allowed, "token is refilled")
+}
+
+func TestInMem_Allow_SubMillisecondPeriod(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewInMem()
+	limiter.now = func() time.Time { return now }
+	limit := Limit{Rate: 1, Period: 500 * time.Microsecond}
+
+	res, err := limiter.Allow(context.Background(), "client", limit)
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+
+	res, err = limiter.Allow(context.Background(), "client", limit)
+	require.NoError(t, err)
+	require.False(t, res.Allowed)
+	require.Equal(t, time.Millisecond, res.RetryAfter, "retry is rounded up to milliseconds")
+
+	now = now.Add(500 * time.Microsecond)
+	res, err = limiter.Allow(context.Background(), "client", limit)
+	require.NoError(t, err)
+	require.True(t, res.Allowed, "token is refilled")
+}
+
+func TestInMem_Consume(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2025, 1, 1, 23, 0, 0, 0, time.UTC)
+	quota := NewInMem()
+	quota.now = func() time.Time { return now }
+
+	for range 2 {
+		res, err := quota.Consume(context.Background(), "key", 2)
+		require.NoError(t, err)
+		require.True(t, res.Allowed)
+	}
+
+	res, err := quota.Consume(context.Background(), "key", 2)
+	require.NoError(t, err)
+	require.False(t, res.Allowed)
+	require.Equal(t, time.Hour, res.RetryAfter)
+
+	require.NoError(t, quota.Refund(context.Background(), "key"))
+	res, err = quota.Consume(context.Background(), "key", 2)
+	require.NoError(t, err)
+	require.True(t, res.Allowed, "refunded unit is consumed again")
+
+	now = now.Add(time.Hour)
+	res, err = quota.Consume(context.Background(), "key", 2)
+	require.NoError(t, err)
+	require.True(t, res.Allowed, "quota is reset next day")
+}
+
+func TestRedis(t *testing.T) {
+	t.Parallel()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	r := NewRedis(client, "test:")
+	ctx := context.Background()
+	limit := Limit{Rate: 1, Period: time.Hour, Burst: 2}
+
+	for i := range 2 {
+		res, err := r.Allow(ctx, "client", limit)
+		require.NoError(t, err)
+		require.True(t, res.Allowed)
+		require.Equal(t, 1-i, res.Remaining)
+	}
+
+	res, err := r.Allow(ctx, "client", limit)
+	require.NoError(t, err)
+	require.False(t, res.Allowed)
+	require.Positive(t, res.RetryAfter)
+
+	for range 3 {
+		res, err = r.Consume(ctx, "key", 3)
+		require.NoError(t, err)
+		require.True(t, res.Allowed)
+	}
+
+	res, err = r.Consume(ctx, "key", 3)
+	require.NoError(t, err)
+	require.False(t, res.Allowed)
+	require.Zero(t, res.Remaining)
+
+	require.NoError(t, r.Refund(ctx, "key"))
+	res, err = r.Consume(ctx, "key", 3)
+	require.NoError(t, err)
+	require.True(t, res.Allowed, "refunded unit is consumed again")
+	require.NoError(t, r.Refund(ctx, "missing"))
+
+	res, err = r.Allow(ctx, "fast", Limit{Rate: 1, Period: 500 * time.Microsecond})
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+	require.Equal(t, time.Millisecond, res.ResetAfter)
+}
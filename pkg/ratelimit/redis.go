@@ -0,0 +1,134 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucketScript refills bucket by time elapsed since the last call and takes a token.
+// Redis clock is used so all instances share the same time source. Time is counted in microseconds,
+// so limits refilling a token in less than a millisecond work too.
+// Returns {allowed, remaining, retry after ms, reset after ms}.
+var tokenBucketScript = redis.NewScript(`
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) / interval)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) * interval / 1000)
+end
+
+local reset = math.ceil((burst - tokens) * interval / 1000)
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', string.format('%d', now))
+redis.call('PEXPIRE', KEYS[1], reset + 1000)
+
+return {allowed, math.floor(tokens), retry, reset}
+`)
+
+// quotaScript increments counter unless limit is reached. Counter expires with its window.
+// Returns {allowed, count}.
+var quotaScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local count = tonumber(redis.call('GET', KEYS[1]) or '0')
+if count >= limit then
+	return {0, count}
+end
+
+count = redis.call('INCR', KEYS[1])
+if count == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+
+return {1, count}
+`)
+
+// refundScript returns a unit to the counter unless it's already empty or expired.
+var refundScript = redis.NewScript(`
+local count = tonumber(redis.call('GET', KEYS[1]) or '0')
+if count > 0 then
+	redis.call('DECR', KEYS[1])
+end
+
+return count
+`)
+
+// Redis is a distributed limiter shared by all instances of the service.
+type Redis struct {
+	client *redis.Client
+	prefix string
+	now    func() time.Time
+}
+
+func NewRedis(client *redis.Client, prefix string) *Redis {
+	return &Redis{
+		client: client,
+		prefix: prefix,
+		now:    time.Now,
+	}
+}
+
+func (r *Redis) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	values, err := tokenBucketScript.Run(ctx, r.client,
+		[]string{r.prefix + "bucket:" + key},
+		limit.perToken()/float64(time.Microsecond), limit.burst(),
+	).Int64Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("Allow: %w", err)
+	}
+
+	return Result{
+		Allowed:    values[0] == 1,
+		Limit:      limit.burst(),
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+		ResetAfter: time.Duration(values[3]) * time.Millisecond,
+	}, nil
+}
+
+func (r *Redis) Consume(ctx context.Context, key string, limit int) (Result, error) {
+	window, resetAfter := day(r.now())
+
+	values, err := quotaScript.Run(ctx, r.client,
+		[]string{r.prefix + "quota:" + key + ":" + window},
+		limit, resetAfter.Milliseconds()+1,
+	).Int64Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("Consume: %w", err)
+	}
+
+	res := Result{
+		Allowed:    values[0] == 1,
+		Limit:      limit,
+		Remaining:  max(0, limit-int(values[1])),
+		ResetAfter: resetAfter,
+	}
+	if !res.Allowed {
+		res.RetryAfter = resetAfter
+	}
+
+	return res, nil
+}
+
+func (r *Redis) Refund(ctx context.Context, key string) error {
+	window, _ := day(r.now())
+
+	if err := refundScript.Run(ctx, r.client, []string{r.prefix + "quota:" + key + ":" + window}).Err(); err != nil {
+		return fmt.Errorf("Refund: %w", err)
+	}
+
+	return nil
+}
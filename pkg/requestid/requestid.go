@@ -0,0 +1,35 @@
+// Package requestid passes id of the request through the context.
+package requestid
+
+import (
+	"context"
+	"ozon_task/pkg/random"
+)
+
+const (
+	idSize     = 20
+	idAlphabet = "0123456789abcdefghijklmnopqrstuvwxyz"
+)
+
+type ctxKey struct{}
+
+// With returns copy of the context with id of the request.
+func With(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns id of the request, empty if it wasn't set.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// New generates random id of the request.
+func New() string {
+	id, err := random.NewRandomString(idSize, idAlphabet)
+	if err != nil {
+		// crypto/rand doesn't fail on supported platforms
+		return ""
+	}
+	return id
+}
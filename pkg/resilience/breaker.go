@@ -0,0 +1,144 @@
+package resilience
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling the dependency while the breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type State int
+
+const (
+	StateClosed State = iota
+	StateHalfOpen
+	StateOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// Outcome of the call reported to the breaker.
+type Outcome int
+
+const (
+	// OutcomeSuccess means the dependency responded, even with an error of the request.
+	OutcomeSuccess Outcome = iota
+	// OutcomeFailure means the dependency is unhealthy.
+	OutcomeFailure
+	// OutcomeIgnored means the call tells nothing about the dependency, e.g. it was cancelled by the caller.
+	OutcomeIgnored
+)
+
+// Breaker stops calls to the failing dependency, so callers fail fast instead of waiting for timeouts.
+type Breaker struct {
+	cfg BreakerConfig
+	now func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probes   int
+
+	opened   atomic.Uint64
+	rejected atomic.Uint64
+}
+
+func NewBreaker(cfg BreakerConfig) *Breaker {
+	return &Breaker{
+		cfg: cfg,
+		now: time.Now,
+	}
+}
+
+// Allow returns ErrCircuitOpen if the call can't be made. Allowed calls must report their outcome with Record.
+func (b *Breaker) Allow() error {
+	if b.cfg.Disabled {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.cfg.OpenTimeout {
+		b.state = StateHalfOpen
+		b.probes = 0
+	}
+
+	switch b.state {
+	case StateClosed:
+		return nil
+	case StateHalfOpen:
+		if b.probes < max(b.cfg.HalfOpenRequests, 1) {
+			b.probes++
+			return nil
+		}
+	}
+
+	b.rejected.Add(1)
+	return ErrCircuitOpen
+}
+
+// Record reports outcome of the allowed call.
+func (b *Breaker) Record(outcome Outcome) {
+	if b.cfg.Disabled {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateClosed:
+		switch outcome {
+		case OutcomeSuccess:
+			b.failures = 0
+		case OutcomeFailure:
+			b.failures++
+			if b.failures >= b.cfg.FailureThreshold {
+				b.open()
+			}
+		}
+	case StateHalfOpen:
+		switch outcome {
+		case OutcomeSuccess:
+			b.state = StateClosed
+			b.failures = 0
+		case OutcomeFailure:
+			b.open()
+		case OutcomeIgnored:
+			b.probes--
+		}
+	}
+}
+
+func (b *Breaker) open() {
+	b.state = StateOpen
+	b.openedAt = b.now()
+	b.opened.Add(1)
+}
+
+// State returns current state, open breaker reports half-open state once its timeout passes.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.cfg.OpenTimeout {
+		return StateHalfOpen
+	}
+	return b.state
+}
@@ -0,0 +1,56 @@
+package resilience
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreaker(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	breaker := NewBreaker(BreakerConfig{FailureThreshold: 2, OpenTimeout: time.Second, HalfOpenRequests: 1})
+	breaker.now = func() time.Time { return now }
+
+	require.NoError(t, breaker.Allow())
+	breaker.Record(OutcomeFailure)
+	require.NoError(t, breaker.Allow())
+	breaker.Record(OutcomeSuccess)
+	require.Equal(t, StateClosed, breaker.State(), "success resets consecutive failures")
+
+	for range 2 {
+		require.NoError(t, breaker.Allow())
+		breaker.Record(OutcomeFailure)
+	}
+	require.Equal(t, StateOpen, breaker.State())
+	require.ErrorIs(t, breaker.Allow(), ErrCircuitOpen)
+
+	now = now.Add(time.Second)
+	require.Equal(t, StateHalfOpen, breaker.State())
+	require.NoError(t, breaker.Allow())
+	require.ErrorIs(t, breaker.Allow(), ErrCircuitOpen, "only one probe is allowed")
+	breaker.Record(OutcomeFailure)
+	require.Equal(t, StateOpen, breaker.State(), "failed probe opens the breaker again")
+
+	now = now.Add(time.Second)
+	require.NoError(t, breaker.Allow())
+	breaker.Record(OutcomeIgnored)
+	require.NoError(t, breaker.Allow(), "ignored probe is returned")
+	breaker.Record(OutcomeSuccess)
+	require.Equal(t, StateClosed, breaker.State())
+
+	require.EqualValues(t, 2, breaker.opened.Load())
+	require.EqualValues(t, 2, breaker.rejected.Load())
+}
+
+func TestBreaker_Disabled(t *testing.T) {
+	t.Parallel()
+	breaker := NewBreaker(BreakerConfig{Disabled: true, FailureThreshold: 1})
+
+	for range 3 {
+		require.NoError(t, breaker.Allow())
+		breaker.Record(OutcomeFailure)
+	}
+	require.Equal(t, StateClosed, breaker.State())
+}
@@ -0,0 +1,31 @@
+package resilience
+
+import "time"
+
+// Config of the policy protecting calls to a single dependency.
+type Config struct {
+	Retry   RetryConfig   `yaml:"retry"`
+	Breaker BreakerConfig `yaml:"breaker"`
+}
+
+// RetryConfig describes exponential backoff: delay before the n-th retry is
+// InitialBackoff * Multiplier^(n-1) limited by MaxBackoff, randomized by ±Jitter of itself.
+type RetryConfig struct {
+	// MaxAttempts counts the first call as well, 1 disables retries.
+	MaxAttempts    int           `yaml:"max_attempts" env-default:"3"`
+	InitialBackoff time.Duration `yaml:"initial_backoff" env-default:"50ms"`
+	MaxBackoff     time.Duration `yaml:"max_backoff" env-default:"1s"`
+	Multiplier     float64       `yaml:"multiplier" env-default:"2"`
+	Jitter         float64       `yaml:"jitter" env-default:"0.2"`
+}
+
+// BreakerConfig describes circuit breaker opened by FailureThreshold consecutive failures.
+// Disabled breaker is always closed.
+// After OpenTimeout up to HalfOpenRequests calls probe the dependency, the breaker is closed
+// by a successful probe and opened again by a failed one.
+type BreakerConfig struct {
+	Disabled         bool          `yaml:"disabled"`
+	FailureThreshold int           `yaml:"failure_threshold" env-default:"5"`
+	OpenTimeout      time.Duration `yaml:"open_timeout" env-default:"5s"`
+	HalfOpenRequests int           `yaml:"half_open_requests" env-default:"1"`
+}
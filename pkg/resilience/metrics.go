@@ -0,0 +1,69 @@
+package resilience
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Health returns state of the breaker, the dependency is unhealthy while the breaker is open.
+func (p *Policy) Health() (string, bool) {
+	state := p.breaker.State()
+	return state.String(), state != StateOpen
+}
+
+// MetricsHandler serves state of the policies in Prometheus text format.
+func MetricsHandler(policies ...*Policy) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		WriteMetrics(w, policies...)
+	})
+}
+
+// WriteMetrics writes state of the policies in Prometheus text format.
+func WriteMetrics(w io.Writer, policies ...*Policy) {
+	metrics := []struct {
+		name, kind, help string
+		value            func(p *Policy) uint64
+	}{
+		{
+			name: "resilience_circuit_breaker_state",
+			kind: "gauge",
+			help: "State of the circuit breaker: 0 closed, 1 half-open, 2 open.",
+			value: func(p *Policy) uint64 {
+				return uint64(p.breaker.State())
+			},
+		},
+		{
+			name: "resilience_circuit_breaker_opened_total",
+			kind: "counter",
+			help: "Number of times the circuit breaker was opened.",
+			value: func(p *Policy) uint64 {
+				return p.breaker.opened.Load()
+			},
+		},
+		{
+			name: "resilience_circuit_breaker_rejected_total",
+			kind: "counter",
+			help: "Calls rejected by the open circuit breaker.",
+			value: func(p *Policy) uint64 {
+				return p.breaker.rejected.Load()
+			},
+		},
+		{
+			name: "resilience_retries_total",
+			kind: "counter",
+			help: "Calls retried after transient failures.",
+			value: func(p *Policy) uint64 {
+				return p.retries.Load()
+			},
+		},
+	}
+
+	for _, metric := range metrics {
+		_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", metric.name, metric.help, metric.name, metric.kind)
+		for _, p := range policies {
+			_, _ = fmt.Fprintf(w, "%s{dependency=%q} %d\n", metric.name, p.name, metric.value(p))
+		}
+	}
+}
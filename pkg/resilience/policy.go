@@ -0,0 +1,96 @@
+package resilience
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Classifier reports how the error of the call is handled.
+type Classifier func(err error) Class
+
+type Class int
+
+const (
+	// Permanent errors are returned as is, e.g. not found.
+	Permanent Class = iota
+	// Retryable errors are retried but don't open the breaker, e.g. conflicts of concurrent transactions.
+	Retryable
+	// Failure errors are retried and counted by the breaker, e.g. connection failures.
+	Failure
+)
+
+// Policy retries transient failures of calls to the dependency and stops calling it while it's failing.
+type Policy struct {
+	name     string
+	cfg      Config
+	classify Classifier
+	breaker  *Breaker
+
+	retries atomic.Uint64
+}
+
+// NewPolicy creates policy of the named dependency.
+func NewPolicy(name string, cfg Config, classify Classifier) *Policy {
+	return &Policy{
+		name:     name,
+		cfg:      cfg,
+		classify: classify,
+		breaker:  NewBreaker(cfg.Breaker),
+	}
+}
+
+// Name of the dependency.
+func (p *Policy) Name() string {
+	return p.name
+}
+
+// Breaker returns breaker of the dependency.
+func (p *Policy) Breaker() *Breaker {
+	return p.breaker
+}
+
+// DoOnce calls fn if the breaker allows it, without retries. It's used for calls which can't be repeated
+// safely when their result is unknown, e.g. non-idempotent writes.
+func (p *Policy) DoOnce(ctx context.Context, fn func(ctx context.Context) error) error {
+	return p.do(ctx, 1, fn)
+}
+
+// Do calls fn until it succeeds, fails with non-transient error or attempts run out.
+// Returns ErrCircuitOpen if the breaker doesn't allow the call.
+// Retries are stopped when the context is done, the last error is returned then.
+func (p *Policy) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return p.do(ctx, p.cfg.Retry.MaxAttempts, fn)
+}
+
+func (p *Policy) do(ctx context.Context, maxAttempts int, fn func(ctx context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		if err := p.breaker.Allow(); err != nil {
+			return err
+		}
+
+		err := fn(ctx)
+		class := Permanent
+		if err != nil {
+			class = p.classify(err)
+		}
+
+		switch {
+		case ctx.Err() != nil:
+			p.breaker.Record(OutcomeIgnored)
+			return err
+		case class == Failure:
+			p.breaker.Record(OutcomeFailure)
+		default:
+			p.breaker.Record(OutcomeSuccess)
+		}
+
+		if class == Permanent || attempt >= maxAttempts {
+			return err
+		}
+
+		if !Sleep(ctx, p.cfg.Retry.Backoff(attempt)) {
+			return err
+		}
+		p.retries.Add(1)
+	}
+}
@@ -0,0 +1,146 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	errFailure   = errors.New("connection refused")
+	errRetryable = errors.New("serialization failure")
+	errPermanent = errors.New("not found")
+)
+
+func classify(err error) Class {
+	switch {
+	case errors.Is(err, errFailure):
+		return Failure
+	case errors.Is(err, errRetryable):
+		return Retryable
+	default:
+		return Permanent
+	}
+}
+
+func newTestPolicy() *Policy {
+	return NewPolicy("storage", Config{
+		Retry:   RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, Multiplier: 2},
+		Breaker: BreakerConfig{FailureThreshold: 3, OpenTimeout: time.Minute, HalfOpenRequests: 1},
+	}, classify)
+}
+
+// failing returns errors in order and then succeeds, counting calls.
+func failing(calls *int, errs ...error) func(context.Context) error {
+	return func(context.Context) error {
+		*calls++
+		if *calls <= len(errs) {
+			return errs[*calls-1]
+		}
+		return nil
+	}
+}
+
+func TestPolicy_Do(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantErr   error
+		wantCalls int
+	}{
+		{name: "success", wantCalls: 1},
+		{name: "failure is retried", errs: []error{errFailure, errFailure}, wantCalls: 3},
+		{name: "conflict is retried", errs: []error{errRetryable}, wantCalls: 2},
+		{name: "permanent error isn't retried", errs: []error{errPermanent}, wantErr: errPermanent, wantCalls: 1},
+		{
+			name:      "attempts run out",
+			errs:      []error{errRetryable, errRetryable, errRetryable},
+			wantErr:   errRetryable,
+			wantCalls: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			policy := newTestPolicy()
+			calls := 0
+
+			err := policy.Do(context.Background(), failing(&calls, tt.errs...))
+			require.ErrorIs(t, err, tt.wantErr)
+			require.Equal(t, tt.wantCalls, calls)
+			require.EqualValues(t, tt.wantCalls-1, policy.retries.Load())
+		})
+	}
+}
+
+func TestPolicy_DoOnce(t *testing.T) {
+	t.Parallel()
+	policy := newTestPolicy()
+	calls := 0
+
+	err := policy.DoOnce(context.Background(), failing(&calls, errFailure))
+	require.ErrorIs(t, err, errFailure)
+	require.Equal(t, 1, calls)
+}
+
+func TestPolicy_OpensBreaker(t *testing.T) {
+	t.Parallel()
+	policy := newTestPolicy()
+	calls := 0
+
+	err := policy.Do(context.Background(), failing(&calls, errFailure, errFailure, errFailure))
+	require.ErrorIs(t, err, errFailure)
+	require.Equal(t, StateOpen, policy.Breaker().State())
+
+	state, healthy := policy.Health()
+	require.Equal(t, "open", state)
+	require.False(t, healthy)
+
+	err = policy.Do(context.Background(), failing(&calls))
+	require.ErrorIs(t, err, ErrCircuitOpen)
+	require.Equal(t, 3, calls, "dependency isn't called while the breaker is open")
+}
+
+func TestPolicy_CanceledContext(t *testing.T) {
+	t.Parallel()
+	policy := newTestPolicy()
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+
+	err := policy.Do(ctx, func(context.Context) error {
+		calls++
+		cancel()
+		return errFailure
+	})
+	require.ErrorIs(t, err, errFailure)
+	require.Equal(t, 1, calls, "canceled call isn't retried")
+	require.Equal(t, StateClosed, policy.Breaker().State())
+}
+
+func TestWriteMetrics(t *testing.T) {
+	t.Parallel()
+	policy := newTestPolicy()
+	calls := 0
+	_ = policy.Do(context.Background(), failing(&calls, errFailure, errFailure, errFailure))
+	_ = policy.Do(context.Background(), failing(&calls))
+
+	var sb strings.Builder
+	WriteMetrics(&sb, policy)
+
+	for _, line := range []string{
+		"# TYPE resilience_circuit_breaker_state gauge",
+		`resilience_circuit_breaker_state{dependency="storage"} 2`,
+		`resilience_circuit_breaker_opened_total{dependency="storage"} 1`,
+		`resilience_circuit_breaker_rejected_total{dependency="storage"} 1`,
+		`resilience_retries_total{dependency="storage"} 2`,
+	} {
+		require.Contains(t, sb.String(), line+"\n")
+	}
+}
@@ -0,0 +1,40 @@
+package resilience
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff returns delay before the retry following the attempt, attempts are counted from 1.
+func (c RetryConfig) Backoff(attempt int) time.Duration {
+	delay := float64(c.InitialBackoff) * math.Pow(c.Multiplier, float64(attempt-1))
+	if c.MaxBackoff > 0 && delay > float64(c.MaxBackoff) {
+		delay = float64(c.MaxBackoff)
+	}
+
+	// spread retries of concurrent calls failed at the same moment
+	if c.Jitter > 0 {
+		delay += delay * c.Jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(delay)
+}
+
+// Sleep waits for the delay, returns false if the context is done earlier.
+func Sleep(ctx context.Context, delay time.Duration) bool {
+	if delay <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
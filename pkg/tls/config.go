@@ -0,0 +1,91 @@
+package tls
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	ClientAuthNone             = "none"
+	ClientAuthRequest          = "request"
+	ClientAuthRequireAny       = "require_any"
+	ClientAuthVerifyIfGiven    = "verify_if_given"
+	ClientAuthRequireAndVerify = "require_and_verify"
+)
+
+var ErrInvalidConfig = errors.New("invalid tls config")
+
+// Config describes server side TLS settings. Mutual TLS is enabled by setting
+// CAFile and one of the verifying client auth modes.
+type Config struct {
+	Enabled        bool          `yaml:"enabled" env-default:"false"`
+	CertFile       string        `yaml:"cert_file"`
+	KeyFile        string        `yaml:"key_file"`
+	CAFile         string        `yaml:"ca_file"`
+	ClientAuth     string        `yaml:"client_auth" env-default:"none"`
+	MinVersion     string        `yaml:"min_version" env-default:"1.2"`
+	ReloadInterval time.Duration `yaml:"reload_interval" env-default:"30s"`
+}
+
+// ClientConfig describes client side TLS settings.
+type ClientConfig struct {
+	CAFile     string `yaml:"ca_file"`
+	CertFile   string `yaml:"cert_file"`
+	KeyFile    string `yaml:"key_file"`
+	ServerName string `yaml:"server_name"`
+}
+
+func (c Config) validate() error {
+	if len(c.CertFile) == 0 || len(c.KeyFile) == 0 {
+		return fmt.Errorf("cert_file and key_file are required: %w", ErrInvalidConfig)
+	}
+
+	clientAuth, err := parseClientAuth(c.ClientAuth)
+	if err != nil {
+		return err
+	}
+
+	if clientAuth >= tls.VerifyClientCertIfGiven && len(c.CAFile) == 0 {
+		return fmt.Errorf("ca_file is required for client_auth %q: %w", c.ClientAuth, ErrInvalidConfig)
+	}
+
+	if _, err = parseVersion(c.MinVersion); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func parseClientAuth(mode string) (tls.ClientAuthType, error) {
+	switch mode {
+	case "", ClientAuthNone:
+		return tls.NoClientCert, nil
+	case ClientAuthRequest:
+		return tls.RequestClientCert, nil
+	case ClientAuthRequireAny:
+		return tls.RequireAnyClientCert, nil
+	case ClientAuthVerifyIfGiven:
+		return tls.VerifyClientCertIfGiven, nil
+	case ClientAuthRequireAndVerify:
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return 0, fmt.Errorf("unknown client_auth %q: %w", mode, ErrInvalidConfig)
+	}
+}
+
+func parseVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	default:
+		return 0, fmt.Errorf("unknown min_version %q: %w", version, ErrInvalidConfig)
+	}
+}
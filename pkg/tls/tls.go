@@ -0,0 +1,256 @@
+package tls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+
+	pkglog "ozon_task/pkg/log"
+)
+
+type keyMaterial struct {
+	cert    tls.Certificate
+	pool    *x509.CertPool
+	modTime time.Time
+}
+
+// Reloader serves certificates from disk and picks up changes of
+// cert, key and CA files without restarting listeners.
+// A nil Reloader stands for disabled TLS.
+type Reloader struct {
+	cfg        Config
+	clientAuth tls.ClientAuthType
+	minVersion uint16
+	logger     *slog.Logger
+	current    atomic.Pointer[keyMaterial]
+}
+
+func NewReloader(cfg Config, logger *slog.Logger) (*Reloader, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("NewReloader: %w", err)
+	}
+
+	clientAuth, _ := parseClientAuth(cfg.ClientAuth)
+	minVersion, _ := parseVersion(cfg.MinVersion)
+
+	r := &Reloader{
+		cfg:        cfg,
+		clientAuth: clientAuth,
+		minVersion: minVersion,
+		logger:     logger,
+	}
+
+	material, err := r.load()
+	if err != nil {
+		return nil, fmt.Errorf("NewReloader: %w", err)
+	}
+	r.current.Store(material)
+
+	return r, nil
+}
+
+// ServerConfig returns tls.Config which resolves key material on every handshake.
+// Returns nil if TLS is disabled.
+func (r *Reloader) ServerConfig() *tls.Config {
+	if r == nil {
+		return nil
+	}
+
+	return &tls.Config{
+		MinVersion:         r.minVersion,
+		GetConfigForClient: r.configForClient,
+	}
+}
+
+func (r *Reloader) configForClient(_ *tls.ClientHelloInfo) (*tls.Config, error) {
+	material := r.current.Load()
+
+	return &tls.Config{
+		MinVersion:   r.minVersion,
+		Certificates: []tls.Certificate{material.cert},
+		ClientCAs:    material.pool,
+		ClientAuth:   r.clientAuth,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}, nil
+}
+
+// ClientConfig returns tls.Config for connections to peers sharing the key material, e.g. nodes of a cluster:
+// the certificate is presented to them and their certificates are verified by the CA of the config.
+// Both are resolved on every handshake, so reloaded files are picked up. Returns nil if TLS is disabled.
+func (r *Reloader) ClientConfig() *tls.Config {
+	if r == nil {
+		return nil
+	}
+
+	return &tls.Config{
+		MinVersion: r.minVersion,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return &r.current.Load().cert, nil
+		},
+		// the default verification uses fixed roots, the chain is verified by verifyServer instead
+		InsecureSkipVerify: true,
+		VerifyConnection:   r.verifyServer,
+	}
+}
+
+func (r *Reloader) verifyServer(state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return fmt.Errorf("server has no certificate: %w", ErrInvalidConfig)
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         r.current.Load().pool,
+		DNSName:       state.ServerName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range state.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	if _, err := state.PeerCertificates[0].Verify(opts); err != nil {
+		return fmt.Errorf("failed to verify server certificate: %w", err)
+	}
+	return nil
+}
+
+// Watch polls files for modifications until context cancellation.
+func (r *Reloader) Watch(ctx context.Context) error {
+	const op = "tls.Reloader.Watch"
+
+	if r == nil || r.cfg.ReloadInterval <= 0 {
+		return nil
+	}
+
+	log := r.logger.With(slog.String("op", op), slog.String("cert_file", r.cfg.CertFile))
+
+	ticker := time.NewTicker(r.cfg.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			reloaded, err := r.Reload()
+			if err != nil {
+				log.Error("failed to reload certificates, keeping previous ones", pkglog.Err(err))
+				continue
+			}
+			if reloaded {
+				log.Info("certificates reloaded")
+			}
+		}
+	}
+}
+
+// Reload loads key material again if any of the files changed since the last load.
+func (r *Reloader) Reload() (bool, error) {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return false, fmt.Errorf("Reload: %w", err)
+	}
+
+	if !modTime.After(r.current.Load().modTime) {
+		return false, nil
+	}
+
+	material, err := r.load()
+	if err != nil {
+		return false, fmt.Errorf("Reload: %w", err)
+	}
+	r.current.Store(material)
+
+	return true, nil
+}
+
+func (r *Reloader) load() (*keyMaterial, error) {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("can't load key pair: %w", err)
+	}
+
+	var pool *x509.CertPool
+	if len(r.cfg.CAFile) != 0 {
+		pool, err = loadCertPool(r.cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &keyMaterial{
+		cert:    cert,
+		pool:    pool,
+		modTime: modTime,
+	}, nil
+}
+
+func (r *Reloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+
+	for _, path := range []string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.CAFile} {
+		if len(path) == 0 {
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("can't stat %s: %w", path, err)
+		}
+
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
+
+// NewClientConfig builds tls.Config for clients. Client certificate is optional
+// and used only when both CertFile and KeyFile are set.
+func NewClientConfig(cfg ClientConfig) (*tls.Config, error) {
+	tlsCfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: cfg.ServerName,
+	}
+
+	if len(cfg.CAFile) != 0 {
+		pool, err := loadCertPool(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("NewClientConfig: %w", err)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if len(cfg.CertFile) != 0 && len(cfg.KeyFile) != 0 {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("NewClientConfig: can't load key pair: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsCfg, nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pemBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read CA file: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemBytes) {
+		return nil, fmt.Errorf("no certificates found in %s: %w", path, ErrInvalidConfig)
+	}
+
+	return pool, nil
+}
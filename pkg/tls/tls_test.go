@@ -0,0 +1,263 @@
+package tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var dummyLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return testCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+func (ca testCA) issue(t *testing.T, serial int64, usages ...x509.ExtKeyUsage) (certPEM, keyPEM []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  usages,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, data, 0600))
+
+	return path
+}
+
+func handshake(t *testing.T, serverCfg, clientCfg *tls.Config) (*x509.Certificate, error) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = l.Close() }()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		serverErr <- tls.Server(conn, serverCfg).Handshake()
+	}()
+
+	client, err := tls.Dial("tcp", l.Addr().String(), clientCfg)
+	if err != nil {
+		<-serverErr
+		return nil, err
+	}
+	defer func() { _ = client.Close() }()
+
+	if err = <-serverErr; err != nil {
+		return nil, err
+	}
+
+	return client.ConnectionState().PeerCertificates[0], nil
+}
+
+func TestReloader_MutualTLS(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	ca := newTestCA(t)
+
+	serverCert, serverKey := ca.issue(t, 2, x509.ExtKeyUsageServerAuth)
+	clientCert, clientKey := ca.issue(t, 3, x509.ExtKeyUsageClientAuth)
+
+	reloader, err := NewReloader(Config{
+		Enabled:    true,
+		CertFile:   writeFile(t, dir, "server.crt", serverCert),
+		KeyFile:    writeFile(t, dir, "server.key", serverKey),
+		CAFile:     writeFile(t, dir, "ca.crt", ca.pem),
+		ClientAuth: ClientAuthRequireAndVerify,
+	}, dummyLogger)
+	require.NoError(t, err)
+
+	clientCfg, err := NewClientConfig(ClientConfig{
+		CAFile:     filepath.Join(dir, "ca.crt"),
+		CertFile:   writeFile(t, dir, "client.crt", clientCert),
+		KeyFile:    writeFile(t, dir, "client.key", clientKey),
+		ServerName: "localhost",
+	})
+	require.NoError(t, err)
+
+	_, err = handshake(t, reloader.ServerConfig(), clientCfg)
+	require.NoError(t, err)
+
+	noCertCfg, err := NewClientConfig(ClientConfig{
+		CAFile:     filepath.Join(dir, "ca.crt"),
+		ServerName: "localhost",
+	})
+	require.NoError(t, err)
+
+	_, err = handshake(t, reloader.ServerConfig(), noCertCfg)
+	require.Error(t, err)
+}
+
+func TestReloader_ClientConfig(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caPath := writeFile(t, dir, "ca.crt", ca.pem)
+
+	newPeer := func(name string, serial int64, ca testCA) *Reloader {
+		cert, key := ca.issue(t, serial, x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth)
+		reloader, err := NewReloader(Config{
+			Enabled:    true,
+			CertFile:   writeFile(t, dir, name+".crt", cert),
+			KeyFile:    writeFile(t, dir, name+".key", key),
+			CAFile:     caPath,
+			ClientAuth: ClientAuthRequireAndVerify,
+		}, dummyLogger)
+		require.NoError(t, err)
+		return reloader
+	}
+	first, second := newPeer("first", 2, ca), newPeer("second", 3, ca)
+
+	clientCfg := second.ClientConfig()
+	clientCfg.ServerName = "localhost"
+	peer, err := handshake(t, first.ServerConfig(), clientCfg)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), peer.SerialNumber.Int64())
+
+	// peers of another CA are refused both ways
+	stranger := newPeer("stranger", 4, newTestCA(t))
+	clientCfg = stranger.ClientConfig()
+	clientCfg.ServerName = "localhost"
+	_, err = handshake(t, first.ServerConfig(), clientCfg)
+	require.Error(t, err)
+
+	clientCfg = first.ClientConfig()
+	clientCfg.ServerName = "localhost"
+	_, err = handshake(t, stranger.ServerConfig(), clientCfg)
+	require.Error(t, err)
+}
+
+func TestReloader_Reload(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	ca := newTestCA(t)
+
+	firstCert, firstKey := ca.issue(t, 10, x509.ExtKeyUsageServerAuth)
+	certPath := writeFile(t, dir, "server.crt", firstCert)
+	keyPath := writeFile(t, dir, "server.key", firstKey)
+
+	reloader, err := NewReloader(Config{
+		Enabled:  true,
+		CertFile: certPath,
+		KeyFile:  keyPath,
+	}, dummyLogger)
+	require.NoError(t, err)
+
+	clientCfg, err := NewClientConfig(ClientConfig{
+		CAFile:     writeFile(t, dir, "ca.crt", ca.pem),
+		ServerName: "localhost",
+	})
+	require.NoError(t, err)
+
+	reloaded, err := reloader.Reload()
+	require.NoError(t, err)
+	require.False(t, reloaded)
+
+	secondCert, secondKey := ca.issue(t, 20, x509.ExtKeyUsageServerAuth)
+	writeFile(t, dir, "server.crt", secondCert)
+	writeFile(t, dir, "server.key", secondKey)
+
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certPath, future, future))
+
+	reloaded, err = reloader.Reload()
+	require.NoError(t, err)
+	require.True(t, reloaded)
+
+	peer, err := handshake(t, reloader.ServerConfig(), clientCfg)
+	require.NoError(t, err)
+	require.Equal(t, int64(20), peer.SerialNumber.Int64())
+}
+
+func TestNewReloader_InvalidConfig(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{name: "No key pair", cfg: Config{Enabled: true}},
+		{name: "Verify without CA", cfg: Config{CertFile: "a", KeyFile: "b", ClientAuth: ClientAuthRequireAndVerify}},
+		{name: "Unknown client auth", cfg: Config{CertFile: "a", KeyFile: "b", ClientAuth: "always"}},
+		{name: "Unknown version", cfg: Config{CertFile: "a", KeyFile: "b", MinVersion: "2.0"}},
+	}
+
+	for _, test := range tests {
+		_, err := NewReloader(test.cfg, dummyLogger)
+		require.ErrorIs(t, err, ErrInvalidConfig, test.name)
+	}
+}
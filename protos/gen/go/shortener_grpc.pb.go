@@ -19,8 +19,14 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	URLShortener_ShortenURL_FullMethodName = "/shortener.URLShortener/ShortenURL"
-	URLShortener_ResolveURL_FullMethodName = "/shortener.URLShortener/ResolveURL"
+	URLShortener_ShortenURL_FullMethodName       = "/shortener.URLShortener/ShortenURL"
+	URLShortener_ResolveURL_FullMethodName       = "/shortener.URLShortener/ResolveURL"
+	URLShortener_ListLinks_FullMethodName        = "/shortener.URLShortener/ListLinks"
+	URLShortener_UpdateLink_FullMethodName       = "/shortener.URLShortener/UpdateLink"
+	URLShortener_ListLinkVersions_FullMethodName = "/shortener.URLShortener/ListLinkVersions"
+	URLShortener_RollbackLink_FullMethodName     = "/shortener.URLShortener/RollbackLink"
+	URLShortener_StreamShorten_FullMethodName    = "/shortener.URLShortener/StreamShorten"
+	URLShortener_ExportLinks_FullMethodName      = "/shortener.URLShortener/ExportLinks"
 )
 
 // URLShortenerClient is the client API for URLShortener service.
@@ -29,6 +35,14 @@ const (
 type URLShortenerClient interface {
 	ShortenURL(ctx context.Context, in *ShortenURLRequest, opts ...grpc.CallOption) (*ShortenURLResponse, error)
 	ResolveURL(ctx context.Context, in *ResolveURLRequest, opts ...grpc.CallOption) (*ResolveURLResponse, error)
+	ListLinks(ctx context.Context, in *ListLinksRequest, opts ...grpc.CallOption) (*ListLinksResponse, error)
+	UpdateLink(ctx context.Context, in *UpdateLinkRequest, opts ...grpc.CallOption) (*UpdateLinkResponse, error)
+	ListLinkVersions(ctx context.Context, in *ListLinkVersionsRequest, opts ...grpc.CallOption) (*ListLinkVersionsResponse, error)
+	RollbackLink(ctx context.Context, in *RollbackLinkRequest, opts ...grpc.CallOption) (*RollbackLinkResponse, error)
+	// Shortens urls in order of requests, one response per request.
+	StreamShorten(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ShortenURLRequest, StreamShortenResponse], error)
+	// Streams all links matching the filter, newest first.
+	ExportLinks(ctx context.Context, in *ExportLinksRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Link], error)
 }
 
 type uRLShortenerClient struct {
@@ -59,12 +73,92 @@ func (c *uRLShortenerClient) ResolveURL(ctx context.Context, in *ResolveURLReque
 	return out, nil
 }
 
+func (c *uRLShortenerClient) ListLinks(ctx context.Context, in *ListLinksRequest, opts ...grpc.CallOption) (*ListLinksResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListLinksResponse)
+	err := c.cc.Invoke(ctx, URLShortener_ListLinks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uRLShortenerClient) UpdateLink(ctx context.Context, in *UpdateLinkRequest, opts ...grpc.CallOption) (*UpdateLinkResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateLinkResponse)
+	err := c.cc.Invoke(ctx, URLShortener_UpdateLink_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uRLShortenerClient) ListLinkVersions(ctx context.Context, in *ListLinkVersionsRequest, opts ...grpc.CallOption) (*ListLinkVersionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListLinkVersionsResponse)
+	err := c.cc.Invoke(ctx, URLShortener_ListLinkVersions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uRLShortenerClient) RollbackLink(ctx context.Context, in *RollbackLinkRequest, opts ...grpc.CallOption) (*RollbackLinkResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RollbackLinkResponse)
+	err := c.cc.Invoke(ctx, URLShortener_RollbackLink_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uRLShortenerClient) StreamShorten(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ShortenURLRequest, StreamShortenResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &URLShortener_ServiceDesc.Streams[0], URLShortener_StreamShorten_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ShortenURLRequest, StreamShortenResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type URLShortener_StreamShortenClient = grpc.BidiStreamingClient[ShortenURLRequest, StreamShortenResponse]
+
+func (c *uRLShortenerClient) ExportLinks(ctx context.Context, in *ExportLinksRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Link], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &URLShortener_ServiceDesc.Streams[1], URLShortener_ExportLinks_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ExportLinksRequest, Link]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type URLShortener_ExportLinksClient = grpc.ServerStreamingClient[Link]
+
 // URLShortenerServer is the server API for URLShortener service.
 // All implementations must embed UnimplementedURLShortenerServer
 // for forward compatibility.
 type URLShortenerServer interface {
 	ShortenURL(context.Context, *ShortenURLRequest) (*ShortenURLResponse, error)
 	ResolveURL(context.Context, *ResolveURLRequest) (*ResolveURLResponse, error)
+	ListLinks(context.Context, *ListLinksRequest) (*ListLinksResponse, error)
+	UpdateLink(context.Context, *UpdateLinkRequest) (*UpdateLinkResponse, error)
+	ListLinkVersions(context.Context, *ListLinkVersionsRequest) (*ListLinkVersionsResponse, error)
+	RollbackLink(context.Context, *RollbackLinkRequest) (*RollbackLinkResponse, error)
+	// Shortens urls in order of requests, one response per request.
+	StreamShorten(grpc.BidiStreamingServer[ShortenURLRequest, StreamShortenResponse]) error
+	// Streams all links matching the filter, newest first.
+	ExportLinks(*ExportLinksRequest, grpc.ServerStreamingServer[Link]) error
 	mustEmbedUnimplementedURLShortenerServer()
 }
 
@@ -81,6 +175,24 @@ func (UnimplementedURLShortenerServer) ShortenURL(context.Context, *ShortenURLRe
 func (UnimplementedURLShortenerServer) ResolveURL(context.Context, *ResolveURLRequest) (*ResolveURLResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ResolveURL not implemented")
 }
+func (UnimplementedURLShortenerServer) ListLinks(context.Context, *ListLinksRequest) (*ListLinksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListLinks not implemented")
+}
+func (UnimplementedURLShortenerServer) UpdateLink(context.Context, *UpdateLinkRequest) (*UpdateLinkResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateLink not implemented")
+}
+func (UnimplementedURLShortenerServer) ListLinkVersions(context.Context, *ListLinkVersionsRequest) (*ListLinkVersionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListLinkVersions not implemented")
+}
+func (UnimplementedURLShortenerServer) RollbackLink(context.Context, *RollbackLinkRequest) (*RollbackLinkResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RollbackLink not implemented")
+}
+func (UnimplementedURLShortenerServer) StreamShorten(grpc.BidiStreamingServer[ShortenURLRequest, StreamShortenResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamShorten not implemented")
+}
+func (UnimplementedURLShortenerServer) ExportLinks(*ExportLinksRequest, grpc.ServerStreamingServer[Link]) error {
+	return status.Errorf(codes.Unimplemented, "method ExportLinks not implemented")
+}
 func (UnimplementedURLShortenerServer) mustEmbedUnimplementedURLShortenerServer() {}
 func (UnimplementedURLShortenerServer) testEmbeddedByValue()                      {}
 
@@ -138,6 +250,96 @@ func _URLShortener_ResolveURL_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _URLShortener_ListLinks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListLinksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).ListLinks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_ListLinks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).ListLinks(ctx, req.(*ListLinksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _URLShortener_UpdateLink_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateLinkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).UpdateLink(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_UpdateLink_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).UpdateLink(ctx, req.(*UpdateLinkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _URLShortener_ListLinkVersions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListLinkVersionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).ListLinkVersions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_ListLinkVersions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).ListLinkVersions(ctx, req.(*ListLinkVersionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _URLShortener_RollbackLink_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RollbackLinkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).RollbackLink(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_RollbackLink_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).RollbackLink(ctx, req.(*RollbackLinkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _URLShortener_StreamShorten_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(URLShortenerServer).StreamShorten(&grpc.GenericServerStream[ShortenURLRequest, StreamShortenResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type URLShortener_StreamShortenServer = grpc.BidiStreamingServer[ShortenURLRequest, StreamShortenResponse]
+
+func _URLShortener_ExportLinks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportLinksRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(URLShortenerServer).ExportLinks(m, &grpc.GenericServerStream[ExportLinksRequest, Link]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type URLShortener_ExportLinksServer = grpc.ServerStreamingServer[Link]
+
 // URLShortener_ServiceDesc is the grpc.ServiceDesc for URLShortener service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -153,7 +355,35 @@ var URLShortener_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ResolveURL",
 			Handler:    _URLShortener_ResolveURL_Handler,
 		},
+		{
+			MethodName: "ListLinks",
+			Handler:    _URLShortener_ListLinks_Handler,
+		},
+		{
+			MethodName: "UpdateLink",
+			Handler:    _URLShortener_UpdateLink_Handler,
+		},
+		{
+			MethodName: "ListLinkVersions",
+			Handler:    _URLShortener_ListLinkVersions_Handler,
+		},
+		{
+			MethodName: "RollbackLink",
+			Handler:    _URLShortener_RollbackLink_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamShorten",
+			Handler:       _URLShortener_StreamShorten_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "ExportLinks",
+			Handler:       _URLShortener_ExportLinks_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "shortener.proto",
 }
@@ -0,0 +1,7 @@
+// Package openapi embeds OpenAPI document generated by protoc-gen-openapiv2 from the shortener protos.
+package openapi
+
+import _ "embed"
+
+//go:embed shortener.swagger.json
+var Shortener []byte
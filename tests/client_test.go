@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"ozon_task/domain"
+	"ozon_task/pkg/random"
+	"ozon_task/tests/suite"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_LinkLifecycle(t *testing.T) {
+	t.Parallel()
+
+	for _, transport := range []string{"grpc", "http"} {
+		t.Run(transport, func(t *testing.T) {
+			t.Parallel()
+			ctx, st := suite.NewClientSuite(t, transport)
+
+			suffix, err := random.NewRandomString(8, "abcdefghijklmnopqrstuvwxyz")
+			require.NoError(t, err)
+			original := "https://finance.ozon.ru/client/" + suffix
+
+			link, err := st.Client.CreateLink(ctx, domain.Link{Original: original, Tags: []domain.Tag{"sdk"}})
+			require.NoError(t, err)
+			assert.Equal(t, original, link.Original)
+			assert.Equal(t, 1, link.Version)
+
+			shortened, err := st.Client.Shorten(ctx, original)
+			require.NoError(t, err)
+			assert.Equal(t, link.Shortened, shortened)
+
+			resolved, err := st.Client.Resolve(ctx, shortened)
+			require.NoError(t, err)
+			assert.Equal(t, original, resolved)
+
+			updated := original + "/v2"
+			link, err = st.Client.UpdateLink(ctx, shortened, domain.LinkUpdate{Original: &updated})
+			require.NoError(t, err)
+			assert.Equal(t, 2, link.Version)
+
+			require.NoError(t, st.Client.DeleteLink(ctx, shortened))
+
+			_, err = st.Client.Resolve(ctx, shortened)
+			require.ErrorIs(t, err, domain.ErrOriginalNotFound)
+			_, err = st.Client.GetLink(ctx, shortened)
+			require.ErrorIs(t, err, domain.ErrShortenedNotFound)
+		})
+	}
+}
+
+func TestClient_InvalidURL(t *testing.T) {
+	t.Parallel()
+
+	for _, transport := range []string{"grpc", "http"} {
+		t.Run(transport, func(t *testing.T) {
+			t.Parallel()
+			ctx, st := suite.NewClientSuite(t, transport)
+
+			_, err := st.Client.Shorten(ctx, "https://ozon")
+			require.ErrorIs(t, err, domain.ErrInvalidOriginal)
+		})
+	}
+}
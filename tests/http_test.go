@@ -8,7 +8,6 @@ import (
 	"github.com/stretchr/testify/require"
 	"net/http"
 	"ozon_task/domain"
-	"ozon_task/internal/api/http/types"
 	"ozon_task/pkg/random"
 	"ozon_task/tests/suite"
 	"testing"
@@ -21,11 +20,24 @@ const (
 	GetOriginalURL = "api/v1/resolve"
 )
 
+// Bodies of the v1 api, the same as JSON of its messages.
+type (
+	ShortenRequest struct {
+		OriginalURL domain.URL `json:"original_url"`
+	}
+	ShortenResponse struct {
+		ShortenedURL domain.ShortURL `json:"shortened_url"`
+	}
+	ResolveResponse struct {
+		OriginalURL domain.URL `json:"original_url"`
+	}
+)
+
 func SendPostRequest(
 	ctx context.Context,
 	client *http.Client,
 	baseURL, path string,
-	payload types.PostShortURLRequest,
+	payload ShortenRequest,
 ) (*http.Response, error) {
 	fullURL := fmt.Sprintf("%s/%s", baseURL, path)
 
@@ -82,12 +94,12 @@ func TestPostShortURL_SuccessURLs(t *testing.T) {
 				st.Client,
 				st.BaseURL,
 				PostShortPath,
-				types.PostShortURLRequest{OriginalURL: test.url},
+				ShortenRequest{OriginalURL: test.url},
 			)
 			require.NoError(t, err)
 			assert.Equal(t, test.expectedStatus, res.StatusCode)
 
-			var response types.PostShortURLResponse
+			var response ShortenResponse
 			err = json.NewDecoder(res.Body).Decode(&response)
 			require.NoError(t, err)
 
@@ -107,12 +119,12 @@ func TestPostShortURL_SameURL(t *testing.T) {
 		st.Client,
 		st.BaseURL,
 		PostShortPath,
-		types.PostShortURLRequest{OriginalURL: validURL},
+		ShortenRequest{OriginalURL: validURL},
 	)
 	require.NoError(t, err)
 	assert.Equal(t, http.StatusOK, res.StatusCode)
 
-	var firstResponse types.PostShortURLResponse
+	var firstResponse ShortenResponse
 	err = json.NewDecoder(res.Body).Decode(&firstResponse)
 	require.NoError(t, err)
 
@@ -121,12 +133,12 @@ func TestPostShortURL_SameURL(t *testing.T) {
 		st.Client,
 		st.BaseURL,
 		PostShortPath,
-		types.PostShortURLRequest{OriginalURL: validURL},
+		ShortenRequest{OriginalURL: validURL},
 	)
 	require.NoError(t, err)
 	assert.Equal(t, http.StatusOK, resSecond.StatusCode)
 
-	var secondResponse types.PostShortURLResponse
+	var secondResponse ShortenResponse
 	err = json.NewDecoder(resSecond.Body).Decode(&secondResponse)
 	require.NoError(t, err)
 
@@ -156,7 +168,7 @@ func TestPostShortURL_InvalidURLs(t *testing.T) {
 				st.Client,
 				st.BaseURL,
 				PostShortPath,
-				types.PostShortURLRequest{OriginalURL: test.url},
+				ShortenRequest{OriginalURL: test.url},
 			)
 			require.NoError(t, err)
 			assert.Equal(t, test.expectedStatus, res.StatusCode)
@@ -175,12 +187,12 @@ func TestGetOriginalURL_Success(t *testing.T) {
 		st.Client,
 		st.BaseURL,
 		PostShortPath,
-		types.PostShortURLRequest{OriginalURL: validURL},
+		ShortenRequest{OriginalURL: validURL},
 	)
 	require.NoError(t, err)
 	assert.Equal(t, http.StatusOK, res.StatusCode)
 
-	var response types.PostShortURLResponse
+	var response ShortenResponse
 	err = json.NewDecoder(res.Body).Decode(&response)
 	require.NoError(t, err)
 
@@ -194,7 +206,7 @@ func TestGetOriginalURL_Success(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, http.StatusOK, resolveResp.StatusCode)
 
-	var resolveResponse types.GetOriginalURLResponse
+	var resolveResponse ResolveResponse
 	err = json.NewDecoder(resolveResp.Body).Decode(&resolveResponse)
 	require.NoError(t, err)
 
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"github.com/stretchr/testify/require"
 	"net/http"
-	"ozon_task/internal/api/http/types"
 	urlshortenerv1 "ozon_task/protos/gen/go"
 	"ozon_task/tests/suite"
 	"testing"
@@ -40,7 +39,7 @@ func Test_PutGRPCGetHTTP(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, http.StatusOK, gRPCResponse.StatusCode)
 
-	var resolveResponse types.GetOriginalURLResponse
+	var resolveResponse ResolveResponse
 	err = json.NewDecoder(gRPCResponse.Body).Decode(&resolveResponse)
 	require.NoError(t, err)
 
@@ -56,12 +55,12 @@ func Test_PutHTTPGetGRPC(t *testing.T) {
 		stHTTP.Client,
 		stHTTP.BaseURL,
 		PostShortPath,
-		types.PostShortURLRequest{OriginalURL: originalURL},
+		ShortenRequest{OriginalURL: originalURL},
 	)
 	require.NoError(t, err)
 	require.Equal(t, http.StatusOK, httpResponse.StatusCode)
 
-	var postResponse types.PostShortURLResponse
+	var postResponse ShortenResponse
 	err = json.NewDecoder(httpResponse.Body).Decode(&postResponse)
 	require.NoError(t, err)
 
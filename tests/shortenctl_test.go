@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"encoding/json"
+	"ozon_task/tests/suite"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type cliResult struct {
+	OriginalURL  string `json:"original_url"`
+	ShortenedURL string `json:"shortened_url"`
+	Error        string `json:"error"`
+	Code         string `json:"code"`
+}
+
+func parseCLIResults(t *testing.T, stdout string) []cliResult {
+	t.Helper()
+
+	var results []cliResult
+	for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		var res cliResult
+		require.NoError(t, json.Unmarshal([]byte(line), &res))
+		results = append(results, res)
+	}
+	return results
+}
+
+func TestShortenctl_ShortenAndResolve(t *testing.T) {
+	t.Parallel()
+
+	for _, transport := range []string{"grpc", "http"} {
+		t.Run(transport, func(t *testing.T) {
+			t.Parallel()
+			ctx, st := suite.NewCLISuite(t, transport)
+
+			const original = "https://finance.ozon.ru/shortenctl"
+			res := st.Run(ctx, "", "-o", "plain", "shorten", original)
+			require.Equal(t, 0, res.ExitCode, res.Stderr)
+			shortened := strings.TrimSpace(res.Stdout)
+			require.NotEmpty(t, shortened)
+
+			res = st.Run(ctx, "", "-o", "plain", "resolve", shortened)
+			require.Equal(t, 0, res.ExitCode, res.Stderr)
+			assert.Equal(t, original, strings.TrimSpace(res.Stdout))
+		})
+	}
+}
+
+func TestShortenctl_BatchReportsFailedItems(t *testing.T) {
+	t.Parallel()
+	ctx, st := suite.NewCLISuite(t, "grpc")
+
+	res := st.Run(ctx, "https://finance.ozon.ru/batch\nhttps://ozon\n", "-o", "json", "shorten")
+
+	require.Equal(t, 1, res.ExitCode, res.Stderr)
+	results := parseCLIResults(t, res.Stdout)
+	require.Len(t, results, 2)
+	assert.NotEmpty(t, results[0].ShortenedURL)
+	assert.Empty(t, results[0].Error)
+	assert.Empty(t, results[1].ShortenedURL)
+	assert.Equal(t, "INVALID_ORIGINAL", results[1].Code)
+}
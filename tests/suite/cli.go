@@ -0,0 +1,120 @@
+package suite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// shortenctlPath is the package of the client CLI relative to the suite.
+const shortenctlPath = "../../cmd/shortenctl"
+
+var (
+	buildOnce sync.Once
+	binary    string
+	buildErr  error
+)
+
+// CLISuite runs shortenctl against the service, flags of the address, TLS and token
+// are taken from the same environment variables as other suites.
+type CLISuite struct {
+	*testing.T
+	flags []string
+}
+
+// CLIResult is the outcome of a shortenctl run.
+type CLIResult struct {
+	ExitCode int
+	Stdout   string
+	Stderr   string
+}
+
+// NewCLISuite builds shortenctl once per test binary. Transport is grpc or http.
+// Only WithToken and WithInsecure options are applied, TLS files are read from env.
+func NewCLISuite(t *testing.T, transport string, opts ...Option) (context.Context, *CLISuite) {
+	t.Helper()
+
+	const operationsTimeout = time.Second * 30
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationsTimeout)
+
+	t.Cleanup(func() {
+		t.Helper()
+		cancel()
+	})
+
+	buildOnce.Do(buildShortenctl)
+	if buildErr != nil {
+		t.Fatalf("failed to build shortenctl: %v", buildErr)
+	}
+
+	o := newOptions(t, opts...)
+
+	host := grpcHost
+	if transport == "http" {
+		host = httpHost
+	}
+	flags := []string{"-transport", transport, "-addr", host, "-token", o.token}
+	if o.tlsConfig != nil {
+		flags = append(flags,
+			"-ca", os.Getenv(tlsCAFileEnvVar),
+			"-cert", os.Getenv(tlsCertFileEnvVar),
+			"-key", os.Getenv(tlsKeyFileEnvVar),
+			"-server-name", os.Getenv(tlsServerNameEnvVar),
+		)
+	}
+
+	return ctx, &CLISuite{T: t, flags: flags}
+}
+
+// Run executes shortenctl with the args after the connection flags, stdin may be empty.
+func (s *CLISuite) Run(ctx context.Context, stdin string, args ...string) CLIResult {
+	s.Helper()
+
+	cmd := exec.CommandContext(ctx, binary, append(append([]string{}, s.flags...), args...)...)
+	cmd.Env = append(os.Environ(), "SHORTENCTL_CONFIG="+filepath.Join(s.TempDir(), "config.yml"))
+	cmd.Stdin = bytes.NewBufferString(stdin)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+
+	res := CLIResult{}
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		res.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		s.Fatalf("failed to run shortenctl: %v", err)
+	}
+	res.Stdout, res.Stderr = stdout.String(), stderr.String()
+
+	return res
+}
+
+func buildShortenctl() {
+	dir, err := os.MkdirTemp("", "shortenctl")
+	if err != nil {
+		buildErr = err
+		return
+	}
+
+	binary = filepath.Join(dir, "shortenctl")
+	if runtime.GOOS == "windows" {
+		binary += ".exe"
+	}
+
+	_, file, _, _ := runtime.Caller(0)
+	cmd := exec.Command("go", "build", "-o", binary, ".")
+	cmd.Dir = filepath.Join(filepath.Dir(file), shortenctlPath)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		buildErr = errors.Join(err, errors.New(string(out)))
+	}
+}
@@ -0,0 +1,52 @@
+package suite
+
+import (
+	"context"
+	"ozon_task/pkg/client"
+	"testing"
+	"time"
+)
+
+// ClientSuite calls the service by the Go client over grpc or http transport.
+type ClientSuite struct {
+	*testing.T
+	Client client.Client
+}
+
+func NewClientSuite(t *testing.T, transport string, opts ...Option) (context.Context, *ClientSuite) {
+	t.Helper()
+
+	const operationsTimeout = time.Second * 10
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationsTimeout)
+
+	o := newOptions(t, opts...)
+
+	// the service of config/docker.yml accepts idempotency keys
+	clientOpts := []client.Option{client.WithToken(o.token), client.WithIdempotency()}
+	if o.tlsConfig != nil {
+		clientOpts = append(clientOpts, client.WithTLS(o.tlsConfig))
+	}
+
+	var (
+		c   client.Client
+		err error
+	)
+	if transport == "http" {
+		c, err = client.NewHTTP(httpHost, clientOpts...)
+	} else {
+		c, err = client.NewGRPC(grpcHost, clientOpts...)
+	}
+	if err != nil {
+		cancel()
+		t.Fatalf("failed to create %s client: %v", transport, err)
+	}
+
+	t.Cleanup(func() {
+		t.Helper()
+		cancel()
+		_ = c.Close()
+	})
+
+	return ctx, &ClientSuite{T: t, Client: c}
+}
@@ -2,33 +2,110 @@ package suite
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net/http"
 	"os"
+	pkgtls "ozon_task/pkg/tls"
 	urlshortenerv1 "ozon_task/protos/gen/go"
 	"testing"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
 const (
 	grpcHostEnvVar = "GRPC_HOST"
 	httpHostEnvVar = "HTTP_HOST"
+
+	// TLS is enabled for clients when CA file is set.
+	tlsCAFileEnvVar     = "TLS_CA_FILE"
+	tlsCertFileEnvVar   = "TLS_CERT_FILE"
+	tlsKeyFileEnvVar    = "TLS_KEY_FILE"
+	tlsServerNameEnvVar = "TLS_SERVER_NAME"
+
+	// Bearer token sent by clients when auth is enabled.
+	authTokenEnvVar = "AUTH_TOKEN"
 )
 
 var (
 	grpcHost = os.Getenv(grpcHostEnvVar)
-	httpHost = fmt.Sprintf("http://%s", os.Getenv(httpHostEnvVar))
+	httpHost = os.Getenv(httpHostEnvVar)
 )
 
+type options struct {
+	tlsConfig *tls.Config
+	token     string
+}
+
+// Option configures client credentials of suites.
+type Option func(*options)
+
+// WithTLS makes suite clients use given TLS config, e.g. with client certificate for mTLS.
+func WithTLS(cfg *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = cfg
+	}
+}
+
+// WithInsecure makes suite clients use plaintext connections even if TLS env vars are set.
+func WithInsecure() Option {
+	return func(o *options) {
+		o.tlsConfig = nil
+	}
+}
+
+// WithToken makes suite clients send `Authorization: Bearer <token>`.
+// Empty token disables authorization header.
+func WithToken(token string) Option {
+	return func(o *options) {
+		o.token = token
+	}
+}
+
+func newOptions(t *testing.T, opts ...Option) options {
+	t.Helper()
+
+	o := options{
+		tlsConfig: tlsConfigFromEnv(t),
+		token:     os.Getenv(authTokenEnvVar),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+func tlsConfigFromEnv(t *testing.T) *tls.Config {
+	t.Helper()
+
+	caFile := os.Getenv(tlsCAFileEnvVar)
+	if caFile == "" {
+		return nil
+	}
+
+	cfg, err := pkgtls.NewClientConfig(pkgtls.ClientConfig{
+		CAFile:     caFile,
+		CertFile:   os.Getenv(tlsCertFileEnvVar),
+		KeyFile:    os.Getenv(tlsKeyFileEnvVar),
+		ServerName: os.Getenv(tlsServerNameEnvVar),
+	})
+	if err != nil {
+		t.Fatalf("failed to load client TLS config: %v", err)
+	}
+
+	return cfg
+}
+
 type GRPCSuite struct {
 	*testing.T
 	URLClient urlshortenerv1.URLShortenerClient
 }
 
-func NewGRPCSuite(t *testing.T) (context.Context, *GRPCSuite) {
+func NewGRPCSuite(t *testing.T, opts ...Option) (context.Context, *GRPCSuite) {
 	t.Helper()
 
 	const operationsTimeout = time.Second * 10
@@ -40,10 +117,22 @@ func NewGRPCSuite(t *testing.T) (context.Context, *GRPCSuite) {
 		cancel()
 	})
 
-	cc, err := grpc.NewClient(
-		grpcHost,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-	)
+	o := newOptions(t, opts...)
+
+	creds := insecure.NewCredentials()
+	if o.tlsConfig != nil {
+		creds = credentials.NewTLS(o.tlsConfig)
+	}
+
+	dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	if o.token != "" {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(bearerCredentials{
+			token:  o.token,
+			secure: o.tlsConfig != nil,
+		}))
+	}
+
+	cc, err := grpc.NewClient(grpcHost, dialOpts...)
 	if err != nil {
 		t.Fatalf("gRPC server connection failed: %v", err)
 	}
@@ -60,7 +149,7 @@ type HTTPSuite struct {
 	BaseURL string
 }
 
-func NewHTTPSuite(t *testing.T) (context.Context, *HTTPSuite) {
+func NewHTTPSuite(t *testing.T, opts ...Option) (context.Context, *HTTPSuite) {
 	t.Helper()
 
 	const operationsTimeout = time.Second * 10
@@ -72,13 +161,53 @@ func NewHTTPSuite(t *testing.T) (context.Context, *HTTPSuite) {
 		cancel()
 	})
 
+	o := newOptions(t, opts...)
+
 	client := &http.Client{
 		Timeout: operationsTimeout,
 	}
 
+	scheme := "http"
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if o.tlsConfig != nil {
+		scheme = "https"
+		transport.TLSClientConfig = o.tlsConfig
+	}
+
+	client.Transport = bearerTransport{token: o.token, next: transport}
+
 	return ctx, &HTTPSuite{
 		T:       t,
 		Client:  client,
-		BaseURL: httpHost,
+		BaseURL: fmt.Sprintf("%s://%s", scheme, httpHost),
 	}
 }
+
+type bearerCredentials struct {
+	token  string
+	secure bool
+}
+
+func (c bearerCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + c.token}, nil
+}
+
+func (c bearerCredentials) RequireTransportSecurity() bool {
+	return c.secure
+}
+
+type bearerTransport struct {
+	token string
+	next  http.RoundTripper
+}
+
+func (t bearerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if t.token == "" || r.Header.Get("Authorization") != "" {
+		return t.next.RoundTrip(r)
+	}
+
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+t.token)
+
+	return t.next.RoundTrip(r)
+}